@@ -1,132 +1,618 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 	fiberlogger "github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/hibiken/asynq"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
+	"github.com/pricecompare/api/internal/audit"
+	"github.com/pricecompare/api/internal/canonicalurl"
 	"github.com/pricecompare/api/internal/compliance/robots"
 	"github.com/pricecompare/api/internal/config"
+	"github.com/pricecompare/api/internal/debugserver"
+	"github.com/pricecompare/api/internal/embedding"
+	"github.com/pricecompare/api/internal/events"
+	"github.com/pricecompare/api/internal/fx"
 	"github.com/pricecompare/api/internal/handlers"
 	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/httpfixture"
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/ingest"
 	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
 	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/ratelimit"
 	"github.com/pricecompare/api/internal/repository"
+	"github.com/pricecompare/api/internal/repository/memory"
+	"github.com/pricecompare/api/internal/respcache"
+	"github.com/pricecompare/api/internal/searchindex"
 	"github.com/pricecompare/api/internal/shipping"
+	"github.com/pricecompare/api/internal/snapshots"
+	"github.com/pricecompare/api/internal/tracing"
+	"github.com/pricecompare/api/internal/translate"
 )
 
 func main() {
 	// Load .env file if exists
 	_ = godotenv.Load()
 
-	// Initialize logger
-	logger, err := zap.NewProduction()
+	checkOnly := flag.Bool("check", false, "run the self-test against every dependency, print a pass/fail matrix and exit")
+	flag.Parse()
+
+	// Initialize logger. The level is set from LOG_LEVEL and can be changed by a reload.
+	logLevels := newLogLevels()
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = logLevels.zap
+	logger, err := zapConfig.Build()
 	if err != nil {
 		log.Fatal("Failed to initialize logger:", err)
 	}
 	defer logger.Sync()
 
-	// Load configuration
+	// Load configuration and fail fast with every problem found
 	cfg := config.Load()
+	httpClientCfg := httpclient.LoadConfig()
+	configErr := errors.Join(cfg.Validate(), httpClientCfg.Validate())
+	if *checkOnly {
+		os.Exit(runSelfTestCommand(cfg, httpClientCfg, configErr, os.Stdout))
+	}
+	if configErr != nil {
+		logger.Fatal("Invalid configuration", zap.Strings("problems", strings.Split(configErr.Error(), "\n")))
+	}
+	logLevels.set(cfg.LogLevel)
 
-	// Initialize database
-	db, err := repository.NewDB(cfg.DatabaseURL())
+	// Initialize tracing (no-op unless OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    cfg.OTelEndpoint,
+		ServiceName: cfg.OTelServiceName,
+		SampleRatio: cfg.OTelSampleRatio,
+	})
 	if err != nil {
-		logger.Fatal("Failed to connect to database", zap.Error(err))
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
 	}
-	defer db.Close()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
 
-	// Initialize Redis for asynq
+	// Initialize database; REPOSITORY_BACKEND=memory runs without one
+	var db *repository.DB
+	if cfg.RepositoryBackend == "postgres" {
+		db, err = repository.NewDB(cfg.DatabaseURL())
+		if err != nil {
+			logger.Fatal("Failed to connect to database", zap.Error(err))
+		}
+		defer db.Close()
+	}
+
+	// Job queue: asynq on Redis, or in-process goroutines with QUEUE_MODE=inline (the
+	// inline queue needs the job handlers and is created further down)
 	redisOpt := asynq.RedisClientOpt{
 		Addr:     cfg.RedisAddr(),
 		Password: cfg.RedisPassword,
 	}
-	asynqClient := asynq.NewClient(redisOpt)
-	defer asynqClient.Close()
+	var queue jobs.Enqueuer
+	if cfg.QueueMode == "asynq" {
+		asynqClient := asynq.NewClient(redisOpt)
+		defer asynqClient.Close()
+		queue = asynqClient
+	}
 
-	asynqServer := asynq.NewServer(redisOpt, asynq.Config{
-		Concurrency: 10,
-	})
+	// Notification channels; job failures are alerted once retries are exhausted
+	var jobErrorHandler asynq.ErrorHandler
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize notifications", zap.Error(err))
+	}
+	if notifier != nil {
+		cooldown := time.Duration(cfg.NotifyJobFailureCooldownMinutes) * time.Minute
+		jobErrorHandler = jobs.NewFailureAlerter(notifier, cooldown, logger)
+		logger.Info("Notifications enabled", zap.Strings("job_failure_channels", notifier.Channels(notifications.KindJobFailure)))
+	}
 
-	// Initialize Redis client for httpclient (robots.txt cache)
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr(),
-		Password: cfg.RedisPassword,
-		DB:       0,
-	})
-	defer redisClient.Close()
+	// Initialize Redis client for httpclient (robots.txt and conditional request cache), the
+	// FX rate cache and EVENT_BUS=redis. With QUEUE_MODE=inline, robots.txt files are only
+	// cached in memory and requests are never conditional.
+	var redisClient *redis.Client
+	var robotsCache httpclient.RedisClientOptional
+	if cfg.QueueMode == "asynq" || cfg.EventBus == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr(),
+			Password: cfg.RedisPassword,
+			DB:       0,
+		})
+		defer redisClient.Close()
+	}
+	if cfg.QueueMode == "asynq" {
+		robotsCache = robots.NewRedisCache(redisClient)
+	}
 
-	// Create slog logger for httpclient (structured logging)
-	slogLogger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	// Create slog logger for httpclient (structured logging). Audit records are sent
+	// to the AUDIT_SINK sink, everything else to stdout.
+	slogHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: logLevels.slog,
+	})
+	auditSink, err := newAuditSink(context.Background(), cfg, db, slog.New(slogHandler))
+	if err != nil {
+		logger.Fatal("Failed to initialize audit sink", zap.String("sink", cfg.AuditSink), zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := auditSink.Close(ctx); err != nil {
+			logger.Error("Failed to flush audit events", zap.Error(err))
+		}
+	}()
+	slogLogger := slog.New(audit.NewHandler(slogHandler, auditSink))
+	logger.Info("Audit sink initialized", zap.String("sink", cfg.AuditSink))
 
 	// Initialize HTTP client with compliance features
-	httpClientCfg := httpclient.LoadConfig()
-	httpClient := httpclient.New(httpClientCfg, slogLogger, robots.NewRedisCache(redisClient))
+	httpClient := httpclient.New(httpClientCfg, slogLogger, robotsCache)
+	go httpClient.RunRateLimitGC(context.Background(), 10*time.Minute)
+	if cfg.ProviderFixtureMode != "" {
+		transport, err := httpfixture.New(cfg.ProviderFixtureMode, cfg.ProviderFixtureDir, nil)
+		if err != nil {
+			logger.Fatal("Invalid provider fixture configuration", zap.Error(err))
+		}
+		httpClient.SetTransport(transport)
+		logger.Warn("Provider HTTP fixtures enabled",
+			zap.String("mode", cfg.ProviderFixtureMode),
+			zap.String("dir", cfg.ProviderFixtureDir),
+		)
+	}
+
+	if len(httpClientCfg.Proxies) > 0 {
+		logger.Info("Outbound proxies enabled",
+			zap.Int("proxies", len(httpClientCfg.Proxies)),
+			zap.String("check_url", httpClientCfg.ProxyCheckURL),
+		)
+	}
+	go httpClient.RunProxyHealthChecks(context.Background())
+	// Per-host rate limits hold across the instances sharing Redis
+	if redisClient != nil {
+		httpClient.ShareHostRateLimits(ratelimit.NewRedisHostBuckets(redisClient))
+	}
+
+	// pprof and expvar on a separate port (DEBUG_ADDR), never on the public API port
+	if cfg.DebugAddr != "" {
+		debugServer, err := debugserver.New(cfg.DebugAddr, cfg.DebugToken)
+		if err != nil {
+			logger.Fatal("Invalid debug server configuration", zap.Error(err))
+		}
+		debugserver.Publish("robots_memory_cache_entries", func() any { return httpClient.RobotsMemoryCacheSize() })
+		go func() {
+			if err := debugServer.ListenAndServe(); err != nil {
+				logger.Error("Debug server stopped", zap.Error(err))
+			}
+		}()
+		logger.Info("Debug server enabled", zap.String("addr", cfg.DebugAddr))
+	}
 
 	// Initialize repositories
-	productRepo := repository.NewProductRepository(db)
-	offerRepo := repository.NewOfferRepository(db)
-	identifierRepo := repository.NewProductIdentifierRepository(db)
-	sourceProductRepo := repository.NewSourceProductRepository(db)
+	var (
+		productRepo          repository.ProductStore
+		offerRepo            repository.OfferStore
+		identifierRepo       repository.ProductIdentifierStore
+		sourceProductRepo    repository.SourceProductStore
+		shippingOptionRepo   repository.OfferShippingOptionStore
+		mergeCandidateRepo   repository.MergeCandidateStore
+		productImageRepo     repository.ProductImageStore
+		productEmbeddingRepo repository.ProductEmbeddingStore
+		providerFetchRepo    repository.ProviderFetchStore
+		priceChangeRepo      repository.OfferPriceChangeStore
+		stockEventRepo       repository.StockEventStore
+		offerMergeRepo       repository.OfferMergeLogStore
+		priceAlertRepo       repository.PriceAlertStore
+		revisionRepo         repository.ProductRevisionStore
+		tagRepo              repository.ProductTagStore
+		fetchScheduleRepo    repository.FetchScheduleStore
+		searchQueryRepo      repository.SearchQueryStore
+		apiKeyRepo           repository.APIKeyStore
+		comparisonSetRepo    repository.ComparisonSetStore
+		jobRunRepo           repository.JobRunStore
+	)
+	if db == nil {
+		store := memory.New()
+		productRepo = store.Products()
+		offerRepo = store.Offers()
+		identifierRepo = store.ProductIdentifiers()
+		sourceProductRepo = store.SourceProducts()
+		shippingOptionRepo = store.OfferShippingOptions()
+		mergeCandidateRepo = store.MergeCandidates()
+		productImageRepo = store.ProductImages()
+		productEmbeddingRepo = store.ProductEmbeddings()
+		providerFetchRepo = store.ProviderFetches()
+		priceChangeRepo = store.OfferPriceChanges()
+		stockEventRepo = store.StockEvents()
+		offerMergeRepo = store.OfferMergeLog()
+		priceAlertRepo = store.PriceAlerts()
+		revisionRepo = store.ProductRevisions()
+		tagRepo = store.ProductTags()
+		fetchScheduleRepo = store.FetchSchedules()
+		searchQueryRepo = store.SearchQueries()
+		apiKeyRepo = store.APIKeys()
+		comparisonSetRepo = store.ComparisonSets()
+		jobRunRepo = store.JobRuns()
+		logger.Warn("Using in-memory repositories; all data is lost when the server stops")
+	} else {
+		productRepo = repository.NewProductRepository(db)
+		offerRepo = repository.NewOfferRepository(db)
+		identifierRepo = repository.NewProductIdentifierRepository(db)
+		sourceProductRepo = repository.NewSourceProductRepository(db)
+		shippingOptionRepo = repository.NewOfferShippingOptionRepository(db)
+		mergeCandidateRepo = repository.NewMergeCandidateRepository(db)
+		productImageRepo = repository.NewProductImageRepository(db)
+		productEmbeddingRepo = repository.NewProductEmbeddingRepository(db)
+		providerFetchRepo = repository.NewProviderFetchRepository(db)
+		priceChangeRepo = repository.NewOfferPriceChangeRepository(db)
+		stockEventRepo = repository.NewStockEventRepository(db)
+		offerMergeRepo = repository.NewOfferMergeLogRepository(db)
+		priceAlertRepo = repository.NewPriceAlertRepository(db)
+		revisionRepo = repository.NewProductRevisionRepository(db)
+		tagRepo = repository.NewProductTagRepository(db)
+		fetchScheduleRepo = repository.NewFetchScheduleRepository(db)
+		searchQueryRepo = repository.NewSearchQueryRepository(db)
+		apiKeyRepo = repository.NewAPIKeyRepository(db)
+		comparisonSetRepo = repository.NewComparisonSetRepository(db)
+		jobRunRepo = repository.NewJobRunRepository(db)
+	}
+
+	// Raw HTML snapshots of live pages (SNAPSHOT_S3_BUCKET)
+	var snapshotStore snapshots.Store
+	if cfg.SnapshotS3Bucket != "" {
+		client, err := newS3Client(context.Background(), cfg.SnapshotS3Endpoint)
+		if err != nil {
+			logger.Fatal("Failed to initialize snapshot storage", zap.Error(err))
+		}
+		snapshotStore = snapshots.NewS3Store(client, cfg.SnapshotS3Bucket, cfg.SnapshotS3Prefix)
+		logger.Info("Page snapshots enabled", zap.String("bucket", cfg.SnapshotS3Bucket), zap.String("prefix", cfg.SnapshotS3Prefix))
+	}
 
 	// Initialize providers
 	providerManager := providers.NewManager()
+	providerManager.Replace(newProviders(cfg, httpClient, snapshotStore, logger, slog.New(slogHandler)))
+	if cfg.ProviderCircuitFailures > 0 {
+		providerManager.EnableCircuitBreaker(providers.NewBreaker(cfg.ProviderCircuitFailures, time.Duration(cfg.ProviderCircuitCooldownSeconds)*time.Second))
+	}
 
-	// Demo / PublicHTML providers are development-only. They can be enabled explicitly
-	// via ENABLE_DEMO_PROVIDERS=true.
-	if os.Getenv("ENABLE_DEMO_PROVIDERS") == "true" {
-		providerManager.Register("demo", providers.NewDemoProvider())
-		providerManager.Register("public_html", providers.NewPublicHTMLProvider(cfg.UserAgent))
+	// Initialize shipping calculator
+	shippingConfig, err := newShippingConfig(cfg)
+	if err != nil {
+		logger.Fatal("Failed to load shipping tables file", zap.String("path", cfg.ShippingTablesFile), zap.Error(err))
 	}
+	shippingCalc := shipping.NewCalculator(shippingConfig)
 
-	// Live provider is the only provider intended for production use.
-	providerManager.Register("live", providers.NewLiveProvider(httpClient))
+	// FX rates: providers in FX_PROVIDERS order, falling back to the last cached rates
+	fxProviders := make([]fx.Provider, 0, len(cfg.FXProviders))
+	for _, name := range cfg.FXProviders {
+		switch name {
+		case "static":
+			fxProviders = append(fxProviders, fx.NewStaticProvider(map[string]float64{"JPY": cfg.FXUSDJPY}))
+		case "http":
+			fxProviders = append(fxProviders, fx.NewHTTPProvider("http", cfg.FXAPIURL, httpClient))
+		case "ecb":
+			fxProviders = append(fxProviders, fx.NewECBProvider(cfg.FXECBURL, httpClient))
+		case "exchangerate_host":
+			fxProviders = append(fxProviders, fx.NewExchangeRateHostProvider(cfg.FXExchangeRateHostURL, cfg.FXExchangeRateHostAccessKey, httpClient))
+		default:
+			logger.Fatal("Unknown FX provider", zap.String("provider", name))
+		}
+	}
+	fxResolver := fx.NewResolver(fxProviders, time.Duration(cfg.FXMaxAgeHours)*time.Hour, slogLogger)
+	if redisClient != nil {
+		// Instances share the last good rates, so one whose providers fail keeps pricing
+		fxResolver.SetCache(fx.NewRedisCache(redisClient))
+	}
+	if err := fxResolver.Refresh(context.Background()); err != nil {
+		logger.Error("Failed to load FX rates, non-USD offers cannot be priced until a refresh succeeds", zap.Error(err))
+	}
+	if cfg.FXRefreshMinutes > 0 {
+		go fxResolver.Run(context.Background(), time.Duration(cfg.FXRefreshMinutes)*time.Minute)
+	}
+	shippingCalc.SetRateSource(fxResolver)
 
-	// Official API providers (Walmart and Amazon)
-	walmartProvider := providers.NewWalmartOfficialProvider(httpClient)
-	if walmartProvider.IsEnabled() {
-		providerManager.Register("walmart", walmartProvider)
-		logger.Info("Walmart API provider enabled")
-	} else {
-		logger.Info("Walmart API provider disabled (WALMART_API_KEY not set)")
+	// Fee rules: fee_rules table first, then FEE_RULES_FILE, else SHIPPING_FEE_PERCENT
+	feeRules, err := loadFeeRules(context.Background(), db, cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to load fee rules file", zap.String("path", cfg.FeeRulesFile), zap.Error(err))
 	}
+	if err := shippingCalc.SetFeeRules(feeRules); err != nil {
+		logger.Fatal("Invalid fee rules", zap.Error(err))
+	}
+	logger.Info("Fee rules loaded", zap.Int("count", len(feeRules)))
 
-	amazonProvider := providers.NewAmazonOfficialProvider(httpClient)
-	if amazonProvider.IsEnabled() {
-		providerManager.Register("amazon", amazonProvider)
-		logger.Info("Amazon API provider enabled")
+	// Initialize job processor
+	jobProcessor := jobs.NewProcessor(productRepo, offerRepo, identifierRepo, sourceProductRepo, mergeCandidateRepo, shippingOptionRepo, providerFetchRepo, priceChangeRepo, providerManager, shippingCalc, cfg.MatchScoreThreshold, cfg.OfferAnomalyDropPercent, logger)
+	jobProcessor.SetCrawlBudget(jobs.CrawlBudget{
+		MaxCandidatesPerQuery: cfg.FetchMaxCandidatesPerQuery,
+		MaxOffersPerProduct:   cfg.FetchMaxOffersPerProduct,
+		MaxRequests:           cfg.FetchMaxRequests,
+	})
+	jobProcessor.SetProviderTimeouts(cfg.ProviderTimeouts())
+	jobProcessor.EnableCuratedFields(revisionRepo)
+	jobProcessor.EnableStockTracking(stockEventRepo)
+	jobProcessor.EnableJobRuns(jobRunRepo)
+	jobProcessor.EnableOfferMergeLog(offerMergeRepo)
+	// Workers of the asynq queue may run in several processes
+	if redisClient != nil {
+		jobProcessor.EnableProductLocks(jobs.NewRedisProductLocker(redisClient, logger))
 	} else {
-		logger.Info("Amazon API provider disabled (AMAZON_ACCESS_KEY, AMAZON_SECRET_KEY, or AMAZON_ASSOCIATE_TAG not set)")
+		jobProcessor.EnableProductLocks(jobs.NewLocalProductLocker())
 	}
-
-	// Initialize shipping calculator
-	shippingConfig := cfg.ShippingConfig()
-	shippingCalc := shipping.NewCalculator(shipping.Config{
-		Mode:       shippingConfig.Mode,
-		FeePercent: shippingConfig.FeePercent,
-		FXUSDJPY:   shippingConfig.FXUSDJPY,
+	jobProcessor.EnableSearchQueries(searchQueryRepo)
+	// Cached search, offers and compare responses, retired when a run writes a product's
+	// offers. Without Redis (QUEUE_MODE=inline) the jobs run in this process.
+	var responseCache *respcache.Cache
+	if cfg.ResponseCacheSearchTTLSeconds > 0 || cfg.ResponseCacheOffersTTLSeconds > 0 {
+		var store respcache.Store = respcache.NewMemoryStore()
+		if redisClient != nil {
+			store = respcache.NewRedisStore(redisClient)
+		}
+		responseCache = respcache.New(store, logger)
+		jobProcessor.EnableResponseCacheInvalidation(responseCache)
+		logger.Info("Response cache enabled",
+			zap.Int("search_ttl_seconds", cfg.ResponseCacheSearchTTLSeconds),
+			zap.Int("offers_ttl_seconds", cfg.ResponseCacheOffersTTLSeconds),
+		)
+	}
+	jobProcessor.EnableIngestionRules(ingest.Rules{
+		MinTitleLength:       cfg.IngestMinTitleLength,
+		BannedKeywords:       cfg.IngestBannedKeywords,
+		RequirePriceSources:  cfg.IngestRequirePriceSources,
+		MaxListingsPerSource: cfg.IngestMaxListingsPerSource,
+		DailyListingQuota:    cfg.IngestDailyListingQuota,
 	})
-
-	// Initialize job processor
-	jobProcessor := jobs.NewProcessor(productRepo, offerRepo, identifierRepo, providerManager, shippingCalc, logger)
+	var embedder embedding.Embedder
+	switch cfg.EmbeddingBackend {
+	case "":
+		// Embedding matching disabled
+	case "openai":
+		if cfg.OpenAIAPIKey == "" {
+			logger.Fatal("EMBEDDING_BACKEND=openai requires OPENAI_API_KEY")
+		}
+		embedder = embedding.NewOpenAIEmbedder(cfg.OpenAIAPIKey, cfg.EmbeddingModel)
+	case "tei", "local":
+		embedder = embedding.NewTEIEmbedder(cfg.EmbeddingLocalURL, cfg.EmbeddingModel)
+	default:
+		logger.Fatal("Unknown EMBEDDING_BACKEND", zap.String("backend", cfg.EmbeddingBackend))
+	}
+	if embedder != nil && db != nil {
+		// Migration 012 skips the table on servers without pgvector
+		var tableExists bool
+		if err := db.QueryRowContext(context.Background(), "SELECT to_regclass('product_embeddings') IS NOT NULL").Scan(&tableExists); err != nil || !tableExists {
+			logger.Fatal("EMBEDDING_BACKEND requires the pgvector extension and the product_embeddings table (migration 012)", zap.Error(err))
+		}
+	}
+	if embedder != nil {
+		jobProcessor.EnableEmbeddingMatching(embedder, productEmbeddingRepo, cfg.EmbeddingMatchThreshold)
+		logger.Info("Embedding matching enabled",
+			zap.String("backend", cfg.EmbeddingBackend),
+			zap.String("model", cfg.EmbeddingModel),
+		)
+	}
+	switch cfg.TranslationBackend {
+	case "":
+		// Listing titles are stored untranslated
+	case "deepl":
+		jobProcessor.EnableTranslation(translate.NewDeepLTranslator(cfg.DeepLAPIKey, cfg.DeepLAPIURL))
+	case "openai":
+		jobProcessor.EnableTranslation(translate.NewOpenAITranslator(cfg.OpenAIAPIKey, cfg.TranslationModel))
+	default:
+		logger.Fatal("Unknown TRANSLATION_BACKEND", zap.String("backend", cfg.TranslationBackend))
+	}
+	if cfg.TranslationBackend != "" {
+		logger.Info("Listing title translation enabled", zap.String("backend", cfg.TranslationBackend))
+	}
+	var imageHasher, imageQueryHasher *imagehash.Hasher
+	var imageQueryClient *httpclient.Client
+	if cfg.ImageHashEnabled {
+		imageHasher = imagehash.NewHasher(httpClient)
+		// image_url queries come from callers, so they get a client that only connects to
+		// public addresses, robots.txt fetches included
+		imageQueryClient = httpclient.New(httpClientCfg, slogLogger, robotsCache)
+		imageQueryClient.SetTransport(httpclient.NewPublicTransport())
+		if redisClient != nil {
+			imageQueryClient.ShareHostRateLimits(ratelimit.NewRedisHostBuckets(redisClient))
+		}
+		go imageQueryClient.RunRateLimitGC(context.Background(), 10*time.Minute)
+		imageQueryHasher = imagehash.NewHasher(imageQueryClient)
+		jobProcessor.EnableImageMatching(imageHasher, productImageRepo, cfg.ImageMatchMaxDistance)
+		logger.Info("Image hash matching enabled", zap.Int("max_distance", cfg.ImageMatchMaxDistance))
+	}
+	if cfg.EventBus != "" {
+		eventPublisher, err := newEventPublisher(cfg, redisClient)
+		if err != nil {
+			logger.Fatal("Failed to initialize event bus", zap.String("bus", cfg.EventBus), zap.Error(err))
+		}
+		defer eventPublisher.Close()
+		jobProcessor.EnableEventPublishing(eventPublisher)
+		logger.Info("Event publishing enabled", zap.String("bus", cfg.EventBus), zap.String("topic", cfg.EventBusTopic))
+	}
+	// External search index (Meilisearch / Elasticsearch), kept in sync by the fetch_prices
+	// and reindex_search jobs
+	var searchIndex searchindex.Index
+	switch cfg.SearchBackend {
+	case "":
+	case "meilisearch":
+		searchIndex = searchindex.NewMeilisearch(cfg.SearchURL, cfg.SearchAPIKey, cfg.SearchIndex)
+	case "elasticsearch":
+		searchIndex = searchindex.NewElasticsearch(cfg.SearchURL, cfg.SearchAPIKey, cfg.SearchIndex)
+	default:
+		logger.Fatal("Unknown SEARCH_BACKEND", zap.String("backend", cfg.SearchBackend))
+	}
+	var searchIndexer *jobs.SearchIndexer
+	if searchIndex != nil {
+		if err := searchIndex.EnsureIndex(context.Background()); err != nil {
+			logger.Error("Failed to prepare search index, it is retried by the next reindex_search job", zap.Error(err))
+		}
+		searchIndexer = jobs.NewSearchIndexer(productRepo, searchIndex, logger)
+		jobProcessor.EnableSearchIndexing(searchIndexer)
+		logger.Info("Search index enabled", zap.String("backend", cfg.SearchBackend), zap.String("index", cfg.SearchIndex))
+	}
 	mux := asynq.NewServeMux()
+	mux.Use(jobs.TracingMiddleware)
 	mux.HandleFunc(jobs.TypeFetchPrices, jobProcessor.HandleFetchPrices)
+	duplicateDetector := jobs.NewDuplicateDetector(mergeCandidateRepo, cfg.TitleMatchThreshold, logger)
+	mux.HandleFunc(jobs.TypeDetectDuplicates, duplicateDetector.HandleDetectDuplicates)
+	if searchIndexer != nil {
+		mux.HandleFunc(jobs.TypeReindexSearch, searchIndexer.HandleReindexSearch)
+	}
+	catalogReporter := jobs.NewCatalogReporter(
+		productRepo,
+		offerRepo,
+		priceChangeRepo,
+		providerFetchRepo,
+		notifier,
+		cfg.CatalogReportPriceChangePercent,
+		time.Duration(cfg.CatalogReportStaleHours)*time.Hour,
+		logger,
+	)
+	mux.HandleFunc(jobs.TypeCatalogReport, catalogReporter.HandleCatalogReport)
+	// Audit events, VACUUM and ANALYZE only exist in Postgres
+	var maintenanceRepo repository.MaintenanceStore
+	if db != nil {
+		maintenanceRepo = repository.NewMaintenanceRepository(db)
+	}
+	maintainer := jobs.NewMaintainer(
+		offerRepo,
+		sourceProductRepo,
+		maintenanceRepo,
+		time.Duration(cfg.AuditRetentionDays)*24*time.Hour,
+		time.Duration(cfg.OfferMergeLogRetentionDays)*24*time.Hour,
+		logger,
+	)
+	if responseCache != nil {
+		maintainer.EnableResponseCacheInvalidation(responseCache)
+	}
+	mux.HandleFunc(jobs.TypeMaintenance, maintainer.HandleMaintenance)
+	reprocessor := jobs.NewReprocessor(sourceProductRepo, identifierRepo, providerManager, logger)
+	mux.HandleFunc(jobs.TypeReprocessRaw, reprocessor.HandleReprocessRaw)
+	// Price drop alerts are delivered to each subscriber; email alerts reuse the
+	// NOTIFY_SMTP_* server and link to their confirmation endpoint on PUBLIC_API_URL
+	var alertMailer *notifications.SMTPSender
+	if cfg.NotifySMTPAddr != "" {
+		alertMailer, err = notifications.NewSMTPSender(cfg.NotifySMTPAddr, cfg.NotifySMTPUsername, cfg.NotifySMTPPassword, cfg.NotifyEmailFrom, nil)
+		if err != nil {
+			logger.Fatal("Failed to initialize alert email", zap.Error(err))
+		}
+	}
+	alertConfirmURL := ""
+	if cfg.PublicAPIURL != "" {
+		alertConfirmURL = strings.TrimRight(cfg.PublicAPIURL, "/") + "/api/alerts/confirm/"
+	} else if alertMailer != nil {
+		logger.Warn("PUBLIC_API_URL is not set, email price alerts are disabled")
+	}
+	alertDispatcher := notifications.NewDispatcher(alertMailer, alertConfirmURL)
+	priceAlertEvaluator := jobs.NewPriceAlertEvaluator(priceAlertRepo, productRepo, alertDispatcher, logger)
+	mux.HandleFunc(jobs.TypeEvaluateAlerts, priceAlertEvaluator.HandleEvaluateAlerts)
+	if imageHasher != nil {
+		imageHashBackfiller := jobs.NewImageHashBackfiller(productImageRepo, imageHasher, logger)
+		mux.HandleFunc(jobs.TypeBackfillImageHashes, imageHashBackfiller.HandleBackfillImageHashes)
+	}
+	if embedder != nil {
+		embeddingBackfiller := jobs.NewEmbeddingBackfiller(productEmbeddingRepo, embedder, logger)
+		mux.HandleFunc(jobs.TypeBackfillEmbeddings, embeddingBackfiller.HandleBackfillEmbeddings)
+	}
 
 	// Start job processor in background
+	var queueInspector jobs.QueueInspector
+	if cfg.QueueMode == "inline" {
+		inlineQueue := jobs.NewInlineQueue(mux, cfg.QueueConcurrency, cfg.InlineQueueSize, jobErrorHandler, logger)
+		queue = inlineQueue
+		queueInspector = inlineQueue
+		go inlineQueue.Run(context.Background())
+		logger.Info("Inline job queue enabled", zap.Int("concurrency", cfg.QueueConcurrency), zap.Int("size", cfg.InlineQueueSize))
+	} else {
+		inspector := asynq.NewInspector(redisOpt)
+		defer inspector.Close()
+		queueInspector = jobs.NewAsynqInspector(inspector)
+		asynqServer := asynq.NewServer(redisOpt, asynq.Config{
+			Concurrency:  cfg.QueueConcurrency,
+			ErrorHandler: jobErrorHandler,
+		})
+		go func() {
+			if err := asynqServer.Run(mux); err != nil {
+				logger.Fatal("Failed to start job processor", zap.Error(err))
+			}
+		}()
+	}
+
+	jobProcessor.EnablePriceAlerts(queue)
+
+	// Schedule periodic duplicate detection, search index rebuilds, catalog reports,
+	// database maintenance and price fetches (FETCH_CRON_<SOURCE> and /api/admin/schedules)
+	var scheduler taskScheduler
+	if cfg.QueueMode == "inline" {
+		scheduler = jobs.NewInlineScheduler(queue, logger)
+	} else {
+		scheduler = asynq.NewScheduler(redisOpt, &asynq.SchedulerOpts{
+			// Every instance schedules the same tasks; all but one are rejected as duplicates
+			EnqueueErrorHandler: func(task *asynq.Task, _ []asynq.Option, err error) {
+				if !errors.Is(err, asynq.ErrDuplicateTask) {
+					logger.Error("Failed to enqueue scheduled task", zap.String("type", task.Type()), zap.Error(err))
+				}
+			},
+		})
+	}
+	// The cron specs were checked by cfg.Validate
+	periodic := []struct {
+		enabled bool
+		spec    string
+		task    string
+	}{
+		{true, cfg.DuplicateScanCron, jobs.TypeDetectDuplicates},
+		{searchIndexer != nil, cfg.SearchReindexCron, jobs.TypeReindexSearch},
+		{notifier != nil, cfg.CatalogReportCron, jobs.TypeCatalogReport},
+		{true, cfg.MaintenanceCron, jobs.TypeMaintenance},
+	}
+	for _, job := range periodic {
+		if !job.enabled || job.spec == "" {
+			continue
+		}
+		if _, err := jobs.RegisterUnique(scheduler, job.spec, asynq.NewTask(job.task, nil)); err != nil {
+			logger.Error("Failed to schedule task", zap.String("type", job.task), zap.String("cron", job.spec), zap.Error(err))
+		}
+	}
+	for source := range cfg.FetchCrons {
+		if !slices.Contains(jobs.FetchSources, source) {
+			logger.Fatal("Unknown source in FETCH_CRON_"+strings.ToUpper(source), zap.Strings("sources", jobs.FetchSources))
+		}
+	}
+	if err := fetchScheduleRepo.ReplaceConfigured(context.Background(), cfg.FetchCrons); err != nil {
+		logger.Fatal("Failed to save FETCH_CRON schedules", zap.Error(err))
+	}
+	fetchScheduler := jobs.NewFetchScheduler(fetchScheduleRepo, scheduler, logger)
+	if err := fetchScheduler.Sync(context.Background()); err != nil {
+		logger.Error("Failed to schedule price fetches", zap.Error(err))
+	}
+	// Picks up schedules changed through another instance
+	go fetchScheduler.Run(context.Background(), time.Minute)
 	go func() {
-		if err := asynqServer.Run(mux); err != nil {
-			logger.Fatal("Failed to start job processor", zap.Error(err))
+		if err := scheduler.Run(); err != nil {
+			logger.Fatal("Failed to start scheduler", zap.Error(err))
 		}
 	}()
 
@@ -136,24 +622,102 @@ func main() {
 		offerRepo,
 		identifierRepo,
 		sourceProductRepo,
+		shippingOptionRepo,
+		mergeCandidateRepo,
+		productImageRepo,
+		providerFetchRepo,
 		providerManager,
-		asynqClient,
+		queue,
 		shippingCalc,
 		logger,
 	)
 
+	// Reload rate limits, shipping fees, provider toggles and log level on SIGHUP or
+	// POST /api/admin/config/reload
+	applier := &configApplier{
+		db:               db,
+		httpClient:       httpClient,
+		imageQueryClient: imageQueryClient,
+		providerManager:  providerManager,
+		shippingCalc:     shippingCalc,
+		snapshotStore:    snapshotStore,
+		logLevels:        logLevels,
+		logger:           logger,
+		slogLogger:       slog.New(slogHandler),
+	}
+	configWatcher := config.NewWatcher(cfg, ".env", applier.apply)
+	go configWatcher.WatchSignals(context.Background(), func(reloaded *config.Config, err error) {
+		if err != nil {
+			logger.Error("Config reload rejected", zap.Strings("problems", strings.Split(err.Error(), "\n")))
+			return
+		}
+		logger.Info("Config reloaded", zap.String("trigger", "SIGHUP"), zap.String("log_level", reloaded.LogLevel))
+	})
+	h.EnableConfigReload(configWatcher)
+	if searchIndex != nil {
+		h.EnableSearchIndex(searchIndex, searchIndexer)
+	}
+	if snapshotStore != nil {
+		h.EnableSnapshots(snapshotStore)
+	}
+	h.EnableCatalogReport(catalogReporter)
+	h.EnablePriceAlerts(priceAlertRepo, alertDispatcher.Channels(), alertDispatcher)
+	h.EnableProductEditing(revisionRepo)
+	h.EnableProductTags(tagRepo)
+	h.EnableStockHistory(stockEventRepo)
+	h.EnableJobRuns(jobRunRepo)
+	h.EnableOfferMergeLog(offerMergeRepo)
+	h.EnableSearchQueries(searchQueryRepo)
+	h.EnableFetchSchedules(fetchScheduleRepo, fetchScheduler)
+	h.EnableFreshnessSLA(cfg.OfferFreshnessSLA())
+	h.EnableRateLimitStats(httpClient.RateLimitStats)
+	h.EnableRateLimitUpdates(httpClient.SetProviderRateLimit)
+	h.EnableAPIKeys(apiKeyRepo)
+	h.EnableComparisonSets(comparisonSetRepo)
+	h.EnablePriceDrops(priceChangeRepo)
+	if cfg.AmazonAssociateTag != "" {
+		h.EnableAffiliateTags(canonicalurl.AffiliateTags{"tag": cfg.AmazonAssociateTag})
+	}
+	if cfg.SiteURL != "" {
+		h.EnableCatalogFeeds(cfg.SiteURL, time.Duration(cfg.FeedCacheTTLSeconds)*time.Second)
+	}
+	if responseCache != nil {
+		h.EnableResponseCacheInvalidation(responseCache)
+	}
+	if imageHasher != nil {
+		h.EnableImageHashing(imageHasher, imageQueryHasher)
+	}
+	if embedder != nil {
+		h.EnableEmbeddingBackfill()
+	}
+	h.EnableQueueHealth(queueInspector, time.Duration(cfg.QueueStuckAfterSeconds)*time.Second)
+	check := &selfTest{
+		db:              db,
+		redisClient:     redisClient,
+		providerManager: providerManager,
+	}
+	if db == nil {
+		check.dbErr = errNoDatabase
+	}
+	h.EnableSelfTest(check.run)
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: handlers.ErrorHandler,
+		// Anonymous callers are rate limited by c.IP()
+		ProxyHeader:        cfg.ProxyHeader,
+		EnableIPValidation: true,
 	})
 
 	// Middleware
 	app.Use(recover.New())
+	app.Use(handlers.Tracing())
+	app.Use(handlers.RequestTimeouts(time.Duration(cfg.RequestTimeoutSeconds)*time.Second, cfg.RouteRequestTimeouts()))
 	app.Use(fiberlogger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,OPTIONS",
-		AllowHeaders: "Content-Type",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders: "Content-Type, Authorization, X-API-Key, traceparent, tracestate",
 	}))
 
 	// Routes
@@ -172,16 +736,101 @@ func main() {
 		})
 	})
 	app.Get("/health", h.Health)
+	app.Get("/health/deep", h.DeepHealth)
+	// Crawlers and merchant centers do not send API keys; the feeds are served from the
+	// catalog cache (FEED_CACHE_TTL_SECONDS)
+	app.Get("/sitemap.xml", h.Sitemap)
+	app.Get("/feeds/products.xml", h.ProductFeedXML)
+	app.Get("/feeds/products.csv", h.ProductFeedCSV)
 
 	api := app.Group("/api")
 	{
-		api.Get("/search", h.Search)
-		api.Get("/products/:id", h.GetProduct)
-		api.Get("/products/:id/offers", h.GetProductOffers)
-		api.Get("/products/:id/compare", h.CompareProductOffers)
-		api.Post("/resolve-url", h.ResolveURL)
+		// API keys protect the admin, resolve-url and image-search routes and own the
+		// saved comparison sets, and meter the public read endpoints of requests that send one
+		requireRead := func(c *fiber.Ctx) error { return c.Next() }
+		requireKey := requireRead
+		public := requireRead
+		if cfg.APIAuthEnabled {
+			auth := handlers.NewAuthenticator(apiKeyRepo, cfg.AdminAPIKey, cfg.APIKeyRateLimitPerMinute, cfg.PublicAPIKeyRateLimitPerMinute, cfg.AnonymousRateLimitPerMinute, logger)
+			api.Use("/admin", auth.Admin())
+			requireRead = auth.Require(models.APIKeyRoleRead)
+			requireKey = auth.Require(models.APIKeyRolePublic)
+			public = auth.Public(cfg.PublicAPIKeyRequired)
+			if cfg.AdminAPIKey == "" {
+				logger.Warn("ADMIN_API_KEY is not set; only keys stored in api_keys are accepted")
+			}
+		} else {
+			logger.Warn("API_AUTH_ENABLED=false; admin routes are not protected")
+		}
+
+		cacheSearch := responseCache.Middleware(time.Duration(cfg.ResponseCacheSearchTTLSeconds)*time.Second, func(*fiber.Ctx) string { return respcache.ScopeSearch })
+		cacheOffers := responseCache.Middleware(time.Duration(cfg.ResponseCacheOffersTTLSeconds)*time.Second, respcache.ScopeProduct)
+
+		api.Get("/search", public, cacheSearch, h.Search)
+		api.Get("/search/index", public, h.SearchIndex)
+		api.Get("/products/:id", public, h.GetProduct)
+		api.Get("/products/:id/offers", public, cacheOffers, h.GetProductOffers)
+		api.Get("/products/:id/compare", public, cacheOffers, h.CompareProductOffers)
+		api.Get("/products/:id/stock-history", public, h.GetStockHistory)
+		api.Get("/deals/price-drops", public, cacheSearch, h.GetPriceDrops)
+		api.Get("/comparisons/shared/:token", public, h.GetSharedComparisonSet)
+		api.Post("/comparisons", requireKey, h.CreateComparisonSet)
+		api.Get("/comparisons", requireKey, h.ListComparisonSets)
+		api.Get("/comparisons/:id", requireKey, h.GetComparisonSet)
+		api.Delete("/comparisons/:id", requireKey, h.DeleteComparisonSet)
+		api.Post("/resolve-url", requireRead, h.ResolveURL)
+		api.Post("/shipping/estimate", public, h.EstimateShipping)
+		api.Post("/alerts", public, h.CreatePriceAlert)
+		api.Get("/alerts/confirm/:token", public, h.ConfirmPriceAlert)
+		api.Get("/alerts/:id", public, h.GetPriceAlert)
+		api.Delete("/alerts/:id", public, h.DeletePriceAlert)
 		api.Post("/admin/jobs/fetch_prices", h.FetchPrices)
-		api.Post("/image-search", h.ImageSearch) // Stub
+		api.Post("/admin/jobs/detect_duplicates", h.DetectDuplicates)
+		api.Post("/admin/jobs/reindex_search", h.ReindexSearch)
+		api.Post("/admin/jobs/catalog_report", h.SendCatalogReport)
+		api.Post("/admin/jobs/backfill_image_hashes", h.BackfillImageHashes)
+		api.Post("/admin/jobs/backfill_embeddings", h.BackfillEmbeddings)
+		api.Post("/admin/jobs/db_maintenance", h.RunMaintenance)
+		api.Post("/admin/jobs/reprocess_raw", h.RunReprocessRaw)
+		api.Get("/admin/jobs/:id", h.GetJobRun)
+		api.Get("/admin/schedules", h.ListFetchSchedules)
+		api.Post("/admin/schedules", h.CreateFetchSchedule)
+		api.Post("/admin/schedules/:id/pause", h.PauseFetchSchedule)
+		api.Post("/admin/schedules/:id/resume", h.ResumeFetchSchedule)
+		api.Delete("/admin/schedules/:id", h.DeleteFetchSchedule)
+		api.Get("/admin/queries", h.ListSearchQueries)
+		api.Post("/admin/queries", h.CreateSearchQuery)
+		api.Patch("/admin/queries/:id", h.UpdateSearchQuery)
+		api.Delete("/admin/queries/:id", h.DeleteSearchQuery)
+		api.Get("/admin/merge-candidates", h.ListMergeCandidates)
+		api.Post("/admin/merge-candidates/:id/merge", h.MergeCandidate)
+		api.Post("/admin/merge-candidates/:id/dismiss", h.DismissMergeCandidate)
+		api.Get("/admin/offers/quarantined", h.ListQuarantinedOffers)
+		api.Post("/admin/offers", h.CreateManualOffer)
+		api.Patch("/admin/offers/:id", h.UpdateOffer)
+		api.Patch("/admin/products/:id", h.UpdateProduct)
+		api.Get("/admin/products/:id/revisions", h.ListProductRevisions)
+		api.Get("/admin/products/:id/offer-merges", h.ListOfferMerges)
+		api.Post("/admin/products/:id/merge", h.MergeProduct)
+		api.Post("/admin/products/:id/tags", h.AddProductTags)
+		api.Delete("/admin/products/:id/tags/:tag", h.RemoveProductTag)
+		api.Post("/admin/products/bulk", h.BulkUpdateProducts)
+		api.Get("/admin/tags", h.ListTags)
+		api.Get("/admin/source-products/low-confidence", h.ListLowConfidenceSourceProducts)
+		api.Post("/admin/source-products/:id/relink", h.RelinkSourceProduct)
+		api.Get("/admin/source-products/:id/snapshot", h.GetSourceProductSnapshot)
+		api.Get("/admin/snapshots", h.ListSnapshots)
+		api.Get("/admin/snapshots/html", h.GetSnapshot)
+		api.Get("/admin/stats/providers", h.ProviderStats)
+		api.Get("/admin/metrics", h.Metrics)
+		api.Put("/admin/rate-limits/:provider", h.SetProviderRateLimit)
+		api.Get("/admin/reports/catalog", h.CatalogReport)
+		api.Post("/admin/config/reload", h.ReloadConfig)
+		api.Post("/admin/selftest", h.SelfTest)
+		api.Get("/admin/api-keys", h.ListAPIKeys)
+		api.Post("/admin/api-keys", h.CreateAPIKey)
+		api.Delete("/admin/api-keys/:id", h.RevokeAPIKey)
+		api.Post("/image-search", requireRead, h.ImageSearch)
 	}
 
 	// Start server
@@ -195,3 +844,88 @@ func main() {
 		logger.Fatal("Failed to start server", zap.Error(err))
 	}
 }
+
+// taskScheduler is implemented by asynq.Scheduler and jobs.InlineScheduler
+type taskScheduler interface {
+	jobs.PeriodicScheduler
+	Run() error
+}
+
+// newAuditSink creates the audit sink selected by AUDIT_SINK. errorLogger reports
+// delivery failures and must not itself write to the sink.
+func newAuditSink(ctx context.Context, cfg *config.Config, db *repository.DB, errorLogger *slog.Logger) (audit.Sink, error) {
+	flushInterval := time.Duration(cfg.AuditFlushSeconds) * time.Second
+	switch cfg.AuditSink {
+	case "stdout":
+		return audit.NewJSONSink(os.Stdout), nil
+	case "postgres":
+		return audit.NewBatchSink(repository.NewAuditEventRepository(db), cfg.AuditBatchSize, flushInterval, errorLogger), nil
+	case "s3":
+		if cfg.AuditS3Bucket == "" {
+			return nil, fmt.Errorf("AUDIT_SINK=s3 requires AUDIT_S3_BUCKET")
+		}
+		client, err := newS3Client(ctx, cfg.AuditS3Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		writer := audit.NewS3Writer(client, cfg.AuditS3Bucket, cfg.AuditS3Prefix)
+		return audit.NewBatchSink(writer, cfg.AuditBatchSize, flushInterval, errorLogger), nil
+	case "http":
+		if cfg.AuditHTTPURL == "" {
+			return nil, fmt.Errorf("AUDIT_SINK=http requires AUDIT_HTTP_URL")
+		}
+		writer := audit.NewHTTPWriter(cfg.AuditHTTPURL, cfg.AuditHTTPToken)
+		return audit.NewBatchSink(writer, cfg.AuditBatchSize, flushInterval, errorLogger), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.AuditSink)
+	}
+}
+
+// newS3Client creates an S3 client from the default AWS configuration. A non-empty
+// endpoint selects an S3-compatible service (e.g. MinIO) with path-style URLs.
+func newS3Client(ctx context.Context, endpoint string) (*s3.Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+// newEventPublisher creates the domain event publisher selected by EVENT_BUS
+func newEventPublisher(cfg *config.Config, redisClient *redis.Client) (events.Publisher, error) {
+	switch cfg.EventBus {
+	case "redis":
+		return events.NewRedisPublisher(redisClient, cfg.EventBusTopic, int64(cfg.EventBusStreamMaxLen)), nil
+	case "nats":
+		return events.NewNATSPublisher(cfg.EventBusURL, cfg.EventBusTopic)
+	case "kafka":
+		return events.NewKafkaPublisher(cfg.EventBusBrokers, cfg.EventBusTopic), nil
+	default:
+		return nil, fmt.Errorf("unknown event bus %q", cfg.EventBus)
+	}
+}
+
+// newNotifier creates the notifier for the configured channels, or nil when none is
+// configured
+func newNotifier(cfg *config.Config) (*notifications.Notifier, error) {
+	senders := make(map[string]notifications.Sender)
+	if cfg.NotifySMTPAddr != "" {
+		sender, err := notifications.NewSMTPSender(cfg.NotifySMTPAddr, cfg.NotifySMTPUsername, cfg.NotifySMTPPassword, cfg.NotifyEmailFrom, cfg.NotifyEmailTo)
+		if err != nil {
+			return nil, err
+		}
+		senders[notifications.ChannelEmail] = sender
+	}
+	if cfg.NotifySlackWebhookURL != "" {
+		senders[notifications.ChannelSlack] = notifications.NewSlackSender(cfg.NotifySlackWebhookURL)
+	}
+	if len(senders) == 0 {
+		return nil, nil
+	}
+	return notifications.NewNotifier(senders, cfg.NotifyRoutes), nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/pricecompare/api/internal/config"
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/render"
+	"github.com/pricecompare/api/internal/repository"
+	"github.com/pricecompare/api/internal/shipping"
+	"github.com/pricecompare/api/internal/snapshots"
+)
+
+// Components built from configuration at startup and rebuilt on a config reload
+// (SIGHUP or POST /api/admin/config/reload)
+
+// logLevels holds the levels of the zap and slog loggers so LOG_LEVEL can change at runtime
+type logLevels struct {
+	zap  zap.AtomicLevel
+	slog *slog.LevelVar
+}
+
+func newLogLevels() *logLevels {
+	return &logLevels{zap: zap.NewAtomicLevel(), slog: new(slog.LevelVar)}
+}
+
+// set applies a validated LOG_LEVEL value
+func (l *logLevels) set(level string) {
+	zapLevel, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return
+	}
+	l.zap.SetLevel(zapLevel)
+
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(level)); err == nil {
+		l.slog.Set(slogLevel)
+	}
+}
+
+// newProviders builds the providers enabled by the configuration
+func newProviders(cfg *config.Config, httpClient *httpclient.Client, snapshotStore snapshots.Store, logger *zap.Logger, slogLogger *slog.Logger) map[string]providers.Provider {
+	enabled := make(map[string]providers.Provider)
+
+	// Demo / PublicHTML providers are development-only. They can be enabled explicitly
+	// via ENABLE_DEMO_PROVIDERS=true.
+	if cfg.EnableDemoProviders {
+		enabled["demo"] = providers.NewDemoProvider()
+		enabled["public_html"] = providers.NewPublicHTMLProvider(httpClient.UserAgent("public_html"))
+	}
+
+	// Live provider is the only provider intended for production use.
+	liveProvider := providers.NewLiveProvider(httpClient)
+	liveProvider.SetLocale(cfg.ProviderLocales["live"])
+	if cfg.LiveProviderMode == "sitemap" {
+		liveProvider.EnableSitemapMode(cfg.LiveSitemapPatterns(), cfg.LiveSitemapMaxPages)
+	}
+	if cfg.LiveRenderMode == "browserless" {
+		liveProvider.EnableRendering(render.NewBrowserless(render.Config{
+			URL:          cfg.RenderBrowserlessURL,
+			Token:        cfg.RenderBrowserlessToken,
+			Timeout:      time.Duration(cfg.RenderTimeoutSeconds) * time.Second,
+			WaitSelector: cfg.LiveRenderWaitSelector,
+		}, httpClient, httpClient.AuditLogger()))
+		logger.Info("Live provider renders pages in a headless browser", zap.String("endpoint", cfg.RenderBrowserlessURL))
+	}
+	if snapshotStore != nil {
+		liveProvider.EnableSnapshots(snapshotStore, slogLogger)
+	}
+	enabled["live"] = liveProvider
+
+	// Official API providers (Walmart, Amazon, Rakuten and AliExpress)
+	walmartProvider := providers.NewWalmartOfficialProvider(httpClient)
+	if walmartProvider.IsEnabled() {
+		enabled["walmart"] = walmartProvider
+		logger.Info("Walmart API provider enabled")
+	} else {
+		logger.Info("Walmart API provider disabled (WALMART_API_KEY not set)")
+	}
+
+	amazonProvider := providers.NewAmazonOfficialProvider(httpClient)
+	amazonProvider.SetLocale(cfg.ProviderLocales["amazon"])
+	if amazonProvider.IsEnabled() {
+		enabled["amazon"] = amazonProvider
+		logger.Info("Amazon API provider enabled")
+	} else {
+		logger.Info("Amazon API provider disabled (AMAZON_ACCESS_KEY, AMAZON_SECRET_KEY, or AMAZON_ASSOCIATE_TAG not set)")
+	}
+
+	// Rakuten Ichiba lists prices in JPY, converted to USD with the FX rates
+	rakutenProvider := providers.NewRakutenProvider(httpClient)
+	if rakutenProvider.IsEnabled() {
+		enabled["rakuten"] = rakutenProvider
+		logger.Info("Rakuten API provider enabled")
+	} else {
+		logger.Info("Rakuten API provider disabled (RAKUTEN_APPLICATION_ID not set)")
+	}
+
+	// AliExpress ships from China; prices are requested in USD
+	aliExpressProvider := providers.NewAliExpressProvider(httpClient)
+	if aliExpressProvider.IsEnabled() {
+		enabled["aliexpress"] = aliExpressProvider
+		logger.Info("AliExpress API provider enabled")
+	} else {
+		logger.Info("AliExpress API provider disabled (ALIEXPRESS_APP_KEY or ALIEXPRESS_APP_SECRET not set)")
+	}
+
+	// Google Shopping (SerpAPI) lists many merchants' offers for one query
+	googleShoppingProvider := providers.NewGoogleShoppingProvider(httpClient)
+	if googleShoppingProvider.IsEnabled() {
+		enabled["google_shopping"] = googleShoppingProvider
+		logger.Info("Google Shopping API provider enabled")
+	} else {
+		logger.Info("Google Shopping API provider disabled (SERPAPI_API_KEY not set)")
+	}
+
+	return enabled
+}
+
+// newShippingConfig builds the shipping calculator configuration, loading
+// SHIPPING_TABLES_FILE if set
+func newShippingConfig(cfg *config.Config) (shipping.Config, error) {
+	shippingConfig := cfg.ShippingConfig()
+	freeShippingRules := make(map[string]shipping.FreeShippingRule, len(shippingConfig.FreeShippingThresholdsCents))
+	for source, minOrderCents := range shippingConfig.FreeShippingThresholdsCents {
+		freeShippingRules[source] = shipping.FreeShippingRule{MinOrderCents: minOrderCents}
+	}
+	var categoryTables map[string]shipping.ShippingTable // nil uses the built-in overrides
+	if cfg.ShippingTablesFile != "" {
+		var err error
+		categoryTables, err = shipping.LoadShippingTablesFile(cfg.ShippingTablesFile)
+		if err != nil {
+			return shipping.Config{}, err
+		}
+	}
+	return shipping.Config{
+		Mode:               shippingConfig.Mode,
+		FeePercent:         shippingConfig.FeePercent,
+		FXUSDJPY:           shippingConfig.FXUSDJPY,
+		FXMarkupPercent:    shippingConfig.FXMarkupPercent,
+		DutyDefaultPercent: shippingConfig.DutyDefaultPercent,
+		DutyDeMinimisCents: shippingConfig.DutyDeMinimisCents,
+		FreeShipping:       freeShippingRules,
+		CategoryTables:     categoryTables,
+	}, nil
+}
+
+// loadFeeRules returns the fee_rules table rules, else FEE_RULES_FILE, else none (the
+// SHIPPING_FEE_PERCENT fallback). db is nil with REPOSITORY_BACKEND=memory.
+func loadFeeRules(ctx context.Context, db *repository.DB, cfg *config.Config, logger *zap.Logger) ([]shipping.FeeRule, error) {
+	var feeRules []shipping.FeeRule
+	if db != nil {
+		var err error
+		feeRules, err = repository.NewFeeRuleRepository(db).ListEnabled(ctx)
+		if err != nil {
+			logger.Warn("Failed to load fee rules from database", zap.Error(err))
+		}
+	}
+	if len(feeRules) == 0 && cfg.FeeRulesFile != "" {
+		return shipping.LoadFeeRulesFile(cfg.FeeRulesFile)
+	}
+	return feeRules, nil
+}
+
+// configApplier applies reloadable settings to the running components
+type configApplier struct {
+	db               *repository.DB // nil with REPOSITORY_BACKEND=memory
+	httpClient       *httpclient.Client
+	imageQueryClient *httpclient.Client // nil unless IMAGE_HASH_ENABLED
+	providerManager  *providers.Manager
+	shippingCalc     *shipping.Calculator
+	snapshotStore    snapshots.Store // nil unless SNAPSHOT_S3_BUCKET is set
+	logLevels        *logLevels
+	logger           *zap.Logger
+	slogLogger       *slog.Logger
+}
+
+// apply rebuilds everything first and only then swaps it in, so an invalid file or
+// HTTP client setting leaves the running configuration untouched
+func (a *configApplier) apply(ctx context.Context, cfg *config.Config) error {
+	httpClientCfg := httpclient.LoadConfig()
+	if err := httpClientCfg.Validate(); err != nil {
+		return err
+	}
+	shippingConfig, err := newShippingConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("SHIPPING_TABLES_FILE: %w", err)
+	}
+	feeRules, err := loadFeeRules(ctx, a.db, cfg, a.logger)
+	if err != nil {
+		return fmt.Errorf("FEE_RULES_FILE: %w", err)
+	}
+	for _, rule := range feeRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	enabledProviders := newProviders(cfg, a.httpClient, a.snapshotStore, a.logger, a.slogLogger)
+
+	a.logLevels.set(cfg.LogLevel)
+	a.httpClient.SetRateLimits(httpClientCfg)
+	a.httpClient.SetCrawlWindows(httpClientCfg)
+	a.httpClient.SetDispatch(httpClientCfg)
+	a.httpClient.SetProxies(httpClientCfg)
+	if a.imageQueryClient != nil {
+		a.imageQueryClient.SetRateLimits(httpClientCfg)
+		a.imageQueryClient.SetCrawlWindows(httpClientCfg)
+		a.imageQueryClient.SetDispatch(httpClientCfg)
+	}
+	a.shippingCalc.SetConfig(shippingConfig)
+	if err := a.shippingCalc.SetFeeRules(feeRules); err != nil {
+		return err
+	}
+	a.providerManager.Replace(enabledProviders)
+	return nil
+}
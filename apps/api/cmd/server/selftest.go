@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/compliance/robots"
+	"github.com/pricecompare/api/internal/config"
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository"
+	"github.com/pricecompare/api/internal/selftest"
+)
+
+// selfTestTimeout bounds each check; provider pings are real API calls
+const selfTestTimeout = 20 * time.Second
+
+// selfTest checks the dependencies of the server. It backs both `server -check` and
+// GET /api/admin/selftest.
+// errNoDatabase skips the database checks with REPOSITORY_BACKEND=memory
+var errNoDatabase = selftest.Skip("REPOSITORY_BACKEND=memory")
+
+type selfTest struct {
+	configErr       error
+	db              *repository.DB
+	dbErr           error         // set when the database could not be opened
+	redisClient     *redis.Client // nil when Redis is not used (QUEUE_MODE=inline)
+	providerManager *providers.Manager
+}
+
+func (s *selfTest) run(ctx context.Context) selftest.Report {
+	return selftest.Run(ctx, s.checks(), selfTestTimeout)
+}
+
+func (s *selfTest) checks() []selftest.Check {
+	checks := []selftest.Check{
+		{Name: "config", Run: s.checkConfig},
+		{Name: "database", Run: s.checkDatabase},
+		{Name: "database_schema", Run: s.checkSchema},
+		{Name: "redis", Run: s.checkRedis},
+	}
+
+	// Each enabled provider, including the live provider whose ping exercises the
+	// robots.txt and rate limit wiring of the HTTP client
+	names := s.providerManager.List()
+	sort.Strings(names)
+	for _, name := range names {
+		provider, err := s.providerManager.Get(name)
+		if err != nil {
+			continue
+		}
+		checks = append(checks, selftest.Check{
+			Name: "provider:" + name,
+			Run: func(ctx context.Context) (string, error) {
+				pinger, ok := provider.(providers.Pinger)
+				if !ok {
+					return "", selftest.Skip("no credentials to verify")
+				}
+				if err := pinger.Ping(ctx); err != nil {
+					if errors.Is(err, httpclient.ErrLiveFetchDisabled) || errors.Is(err, httpclient.ErrOutsideCrawlWindow) {
+						return "", selftest.Skip(err.Error())
+					}
+					return "", err
+				}
+				return "reachable", nil
+			},
+		})
+	}
+	return checks
+}
+
+func (s *selfTest) checkConfig(ctx context.Context) (string, error) {
+	if s.configErr != nil {
+		return "", errors.New(strings.ReplaceAll(s.configErr.Error(), "\n", "; "))
+	}
+	return "valid", nil
+}
+
+func (s *selfTest) checkDatabase(ctx context.Context) (string, error) {
+	if s.db == nil {
+		return "", s.dbErr
+	}
+	if err := s.db.PingContext(ctx); err != nil {
+		return "", err
+	}
+	return "connected", nil
+}
+
+func (s *selfTest) checkSchema(ctx context.Context) (string, error) {
+	if s.db == nil {
+		return "", selftest.Skip("database unavailable")
+	}
+	version, dirty, err := s.db.SchemaVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema_migrations (has cmd/migrate run?): %w", err)
+	}
+	if dirty {
+		return "", fmt.Errorf("migration %d failed halfway (dirty); fix it and force the version", version)
+	}
+
+	latest, err := latestMigration(migrationsDir())
+	if err != nil {
+		return fmt.Sprintf("version %d (migrations directory not found)", version), nil
+	}
+	if version < latest {
+		return "", fmt.Errorf("schema is at version %d, latest migration is %d; run cmd/migrate", version, latest)
+	}
+	return fmt.Sprintf("version %d", version), nil
+}
+
+func (s *selfTest) checkRedis(ctx context.Context) (string, error) {
+	if s.redisClient == nil {
+		return "", selftest.Skip("not used with QUEUE_MODE=inline")
+	}
+	if err := s.redisClient.Ping(ctx).Err(); err != nil {
+		return "", err
+	}
+	return s.redisClient.Options().Addr, nil
+}
+
+// migrationsDir mirrors cmd/migrate: /app/migrations in Docker, else ./migrations
+func migrationsDir() string {
+	if _, err := os.Stat("/app/migrations"); err == nil {
+		return "/app/migrations"
+	}
+	return "migrations"
+}
+
+// latestMigration returns the highest NNN of the NNN_name.up.sql files in dir
+func latestMigration(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no migrations in %s", dir)
+	}
+	latest := 0
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		if version, err := strconv.Atoi(prefix); err == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
+}
+
+// runSelfTestCommand implements `server -check`: it connects to every dependency,
+// writes the pass/fail matrix to out and returns the process exit code. Unlike normal
+// startup, an unreachable dependency is reported rather than fatal.
+func runSelfTestCommand(cfg *config.Config, httpClientCfg *httpclient.Config, configErr error, out io.Writer) int {
+	var db *repository.DB
+	dbErr := errNoDatabase
+	if cfg.RepositoryBackend == "postgres" {
+		db, dbErr = repository.NewDB(cfg.DatabaseURL())
+		if db != nil {
+			defer db.Close()
+		}
+	}
+
+	var redisClient *redis.Client
+	var robotsCache httpclient.RedisClientOptional
+	if cfg.QueueMode == "asynq" || cfg.EventBus == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr(),
+			Password: cfg.RedisPassword,
+			DB:       0,
+		})
+		defer redisClient.Close()
+	}
+	if cfg.QueueMode == "asynq" {
+		robotsCache = robots.NewRedisCache(redisClient)
+	}
+
+	// Only warnings and errors; pings do not send audited requests
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	httpClient := httpclient.New(httpClientCfg, logger, robotsCache)
+
+	providerManager := providers.NewManager()
+	providerManager.Replace(newProviders(cfg, httpClient, nil, zap.NewNop(), nil))
+
+	check := &selfTest{
+		configErr:       configErr,
+		db:              db,
+		dbErr:           dbErr,
+		redisClient:     redisClient,
+		providerManager: providerManager,
+	}
+	report := check.run(context.Background())
+	if err := report.WriteTable(out); err != nil || !report.Passed {
+		return 1
+	}
+	return 0
+}
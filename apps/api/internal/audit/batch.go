@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchWriter delivers a batch of events to long-term storage
+type BatchWriter interface {
+	WriteBatch(ctx context.Context, events []Event) error
+}
+
+// BatchSink buffers events and hands them to a BatchWriter when batchSize events are
+// pending or every flushInterval. A failed batch is retried on the next flush; once more
+// than maxPendingBatches batches are pending the oldest events are dropped, so an
+// unreachable destination cannot grow memory without bound. Write never blocks the
+// request being audited: events are dropped (and counted) while the buffer is full.
+type BatchSink struct {
+	writer        BatchWriter
+	batchSize     int
+	flushInterval time.Duration
+	logger        *slog.Logger // for delivery failures; must not be an audit.Handler logger
+	events        chan Event
+	done          chan struct{}
+	dropped       atomic.Int64 // events not buffered since the last flush
+
+	mu     sync.RWMutex // guards closed, so Write never sends on the closed channel
+	closed bool
+}
+
+const maxPendingBatches = 10
+
+// ErrSinkClosed is returned by Write after Close
+var ErrSinkClosed = errors.New("audit sink closed")
+
+func NewBatchSink(writer BatchWriter, batchSize int, flushInterval time.Duration, logger *slog.Logger) *BatchSink {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if flushInterval <= 0 {
+		flushInterval = 10 * time.Second
+	}
+	s := &BatchSink{
+		writer:        writer,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		logger:        logger,
+		events:        make(chan Event, maxPendingBatches*batchSize),
+		done:          make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues an event, dropping it if the buffer is full
+func (s *BatchSink) Write(ctx context.Context, event Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+// Close flushes pending events and stops the sink; later writes return ErrSinkClosed
+func (s *BatchSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit sink not flushed: %w", ctx.Err())
+	}
+}
+
+func (s *BatchSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	pending := make([]Event, 0, s.batchSize)
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				s.flush(pending)
+				return
+			}
+			pending = append(pending, event)
+			if len(pending) >= s.batchSize {
+				pending = s.flush(pending)
+			}
+		case <-ticker.C:
+			pending = s.flush(pending)
+		}
+	}
+}
+
+// flush writes pending events in batches and returns the events still pending
+func (s *BatchSink) flush(pending []Event) []Event {
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		s.logger.Error("Dropping audit events", slog.Int64("dropped", dropped), slog.String("reason", "buffer full"))
+	}
+	for len(pending) > 0 {
+		n := min(len(pending), s.batchSize)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.writer.WriteBatch(ctx, pending[:n])
+		cancel()
+		if err != nil {
+			s.logger.Error("Failed to write audit events", slog.Int("pending", len(pending)), slog.String("error", err.Error()))
+			if limit := maxPendingBatches * s.batchSize; len(pending) > limit {
+				s.logger.Error("Dropping audit events", slog.Int("dropped", len(pending)-limit))
+				pending = append(pending[:0], pending[len(pending)-limit:]...)
+			}
+			return pending
+		}
+		pending = append(pending[:0], pending[n:]...)
+	}
+	return pending
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPWriter forwards batches as newline-delimited JSON to a log collector endpoint
+// (e.g. Vector, Fluent Bit or Logstash HTTP inputs)
+type HTTPWriter struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func NewHTTPWriter(url, token string) *HTTPWriter {
+	return &HTTPWriter{
+		url:        url,
+		token:      token,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (w *HTTPWriter) WriteBatch(ctx context.Context, events []Event) error {
+	body, err := encodeNDJSON(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit forwarder returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func encodeNDJSON(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -2,6 +2,8 @@ package audit
 
 import (
 	"log/slog"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -19,16 +21,48 @@ type Entry struct {
 	RobotsAllowed bool      `json:"robots_allowed"`
 	RobotsGroup   string    `json:"robots_group,omitempty"`
 	RetryCount    int       `json:"retry_count"`
+	Proxy         string    `json:"proxy,omitempty"` // name of the outbound proxy (HTTP_PROXIES), if any
+	ContentType   string    `json:"content_type,omitempty"`
 	Error         string    `json:"error,omitempty"`
 }
 
+// secretParams are query parameters that carry credentials, e.g. exchangerate.host's
+// access_key; they are redacted from logged URLs since audit events ship to external sinks
+var secretParams = map[string]bool{
+	"key": true, "api_key": true, "apikey": true, "access_key": true, "token": true,
+	"access_token": true, "signature": true, "sig": true, "secret": true, "password": true,
+	"applicationid": true, "app_key": true, "sign": true,
+}
+
+// RedactURL returns rawURL with the values of secret query parameters replaced
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	query := u.Query()
+	redacted := false
+	for param := range query {
+		if secretParams[strings.ToLower(param)] {
+			query[param] = []string{"REDACTED"}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawURL
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 // LogRequest logs an HTTP request to audit log
 func LogRequest(logger *slog.Logger, entry Entry) {
 	attrs := []any{
+		slog.String("audit", EventHTTPRequest),
 		slog.Time("ts", entry.Timestamp),
 		slog.String("provider", entry.Provider),
 		slog.String("method", entry.Method),
-		slog.String("url", entry.URL),
+		slog.String("url", RedactURL(entry.URL)),
 		slog.String("host", entry.Host),
 		slog.String("path", entry.Path),
 		slog.Int("status", entry.Status),
@@ -42,6 +76,14 @@ func LogRequest(logger *slog.Logger, entry Entry) {
 		attrs = append(attrs, slog.String("robots_group", entry.RobotsGroup))
 	}
 
+	if entry.Proxy != "" {
+		attrs = append(attrs, slog.String("proxy", entry.Proxy))
+	}
+
+	if entry.ContentType != "" {
+		attrs = append(attrs, slog.String("content_type", entry.ContentType))
+	}
+
 	if entry.Error != "" {
 		attrs = append(attrs, slog.String("error", entry.Error))
 	}
@@ -49,3 +91,52 @@ func LogRequest(logger *slog.Logger, entry Entry) {
 	logger.Info("HTTP request audit", attrs...)
 }
 
+// FX audit events
+const (
+	FXEventFallback = "fallback" // a higher-priority FX provider failed
+	FXEventStale    = "stale"    // a cached rate older than the max age was used
+)
+
+// FXEntry represents an FX rate audit log entry
+type FXEntry struct {
+	Timestamp       time.Time `json:"ts"`
+	Event           string    `json:"event"`
+	Currency        string    `json:"currency,omitempty"`
+	Source          string    `json:"source"`
+	Rate            float64   `json:"rate,omitempty"`
+	AgeSec          int64     `json:"age_sec,omitempty"`
+	FailedProviders []string  `json:"failed_providers,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// LogFXRate logs an FX fallback or stale-rate event to audit log
+func LogFXRate(logger *slog.Logger, entry FXEntry) {
+	attrs := []any{
+		slog.String("audit", EventFXRate),
+		slog.Time("ts", entry.Timestamp),
+		slog.String("event", entry.Event),
+		slog.String("source", entry.Source),
+	}
+
+	if entry.Currency != "" {
+		attrs = append(attrs, slog.String("currency", entry.Currency))
+	}
+
+	if entry.Rate > 0 {
+		attrs = append(attrs, slog.Float64("rate", entry.Rate))
+	}
+
+	if entry.AgeSec > 0 {
+		attrs = append(attrs, slog.Int64("age_sec", entry.AgeSec))
+	}
+
+	if len(entry.FailedProviders) > 0 {
+		attrs = append(attrs, slog.Any("failed_providers", entry.FailedProviders))
+	}
+
+	if entry.Error != "" {
+		attrs = append(attrs, slog.String("error", entry.Error))
+	}
+
+	logger.Warn("FX rate audit", attrs...)
+}
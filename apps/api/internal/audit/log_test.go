@@ -0,0 +1,19 @@
+package audit
+
+import "testing"
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://api.exchangerate.host/live?access_key=secret&source=USD", "https://api.exchangerate.host/live?access_key=REDACTED&source=USD"},
+		{"https://www.example.com/item?id=1", "https://www.example.com/item?id=1"},
+		{"https://www.example.com/item", "https://www.example.com/item"},
+	}
+	for _, tt := range tests {
+		if got := RedactURL(tt.url); got != tt.expected {
+			t.Errorf("RedactURL(%q) = %q, want %q", tt.url, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+)
+
+// S3API is the subset of the S3 client used by S3Writer
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Writer stores each batch as a gzipped newline-delimited JSON object under
+// <prefix>/YYYY/MM/DD/, so the bucket's lifecycle rules control retention
+type S3Writer struct {
+	client S3API
+	bucket string
+	prefix string
+	now    func() time.Time
+}
+
+func NewS3Writer(client S3API, bucket, prefix string) *S3Writer {
+	return &S3Writer{client: client, bucket: bucket, prefix: prefix, now: time.Now}
+}
+
+func (w *S3Writer) WriteBatch(ctx context.Context, events []Event) error {
+	body, err := encodeNDJSON(events)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(w.bucket),
+		Key:             aws.String(w.objectKey()),
+		Body:            bytes.NewReader(buf.Bytes()),
+		ContentType:     aws.String("application/x-ndjson"),
+		ContentEncoding: aws.String("gzip"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload audit batch: %w", err)
+	}
+	return nil
+}
+
+func (w *S3Writer) objectKey() string {
+	now := w.now().UTC()
+	name := now.Format("150405") + "-" + uuid.NewString() + ".ndjson.gz"
+	return path.Join(w.prefix, now.Format("2006/01/02"), name)
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Audit event types, set as the "audit" attribute of audit log records
+const (
+	EventHTTPRequest = "http_request"
+	EventFXRate      = "fx_rate"
+)
+
+// Event is an audit log record as delivered to a Sink
+type Event struct {
+	Time    time.Time
+	Type    string // EventHTTPRequest or EventFXRate
+	Level   string
+	Message string
+	Fields  map[string]any
+}
+
+// MarshalJSON encodes the event as a flat object in the same shape as the slog JSON
+// output: time, level, msg and audit alongside the entry fields
+func (e Event) MarshalJSON() ([]byte, error) {
+	object := make(map[string]any, len(e.Fields)+4)
+	for key, value := range e.Fields {
+		object[key] = value
+	}
+	object["time"] = e.Time
+	object["level"] = e.Level
+	object["msg"] = e.Message
+	object["audit"] = e.Type
+	return json.Marshal(object)
+}
+
+// Sink receives audit events so they can be retained outside the application host
+type Sink interface {
+	Write(ctx context.Context, event Event) error
+	// Close flushes buffered events
+	Close(ctx context.Context) error
+}
+
+// JSONSink writes each event as a JSON line, e.g. to stdout
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+func (s *JSONSink) Write(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+func (s *JSONSink) Close(ctx context.Context) error {
+	return nil
+}
+
+// Handler is a slog.Handler that sends audit records (those logged by LogRequest and
+// LogFXRate) to a Sink and everything else to the wrapped handler
+type Handler struct {
+	next  slog.Handler
+	sink  Sink
+	attrs []slog.Attr
+}
+
+func NewHandler(next slog.Handler, sink Sink) *Handler {
+	return &Handler{next: next, sink: sink}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true // audit records are kept regardless of the log level
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	event := Event{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+		Fields:  make(map[string]any, record.NumAttrs()+len(h.attrs)),
+	}
+	addField := func(attr slog.Attr) bool {
+		if attr.Key == "audit" {
+			event.Type = attr.Value.String()
+		} else {
+			event.Fields[attr.Key] = attr.Value.Resolve().Any()
+		}
+		return true
+	}
+	for _, attr := range h.attrs {
+		addField(attr)
+	}
+	record.Attrs(addField)
+
+	if event.Type == "" {
+		if !h.next.Enabled(ctx, record.Level) {
+			return nil
+		}
+		return h.next.Handle(ctx, record)
+	}
+	return h.sink.Write(ctx, event)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{
+		next:  h.next.WithAttrs(attrs),
+		sink:  h.sink,
+		attrs: append(append([]slog.Attr{}, h.attrs...), attrs...),
+	}
+}
+
+// WithGroup only applies to the wrapped handler; audit loggers do not use groups
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), sink: h.sink, attrs: h.attrs}
+}
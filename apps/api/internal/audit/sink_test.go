@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *memorySink) Write(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *memorySink) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestHandlerRoutesAuditRecordsToSink(t *testing.T) {
+	var stdout bytes.Buffer
+	sink := &memorySink{}
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&stdout, nil), sink))
+
+	LogRequest(logger, Entry{Provider: "walmart", Method: "GET", Status: 200, RobotsAllowed: true})
+	LogFXRate(logger, FXEntry{Event: FXEventStale, Source: "http", Currency: "JPY"})
+	logger.Warn("HTTP request failed, retrying", slog.String("url", "https://example.com"))
+
+	if len(sink.events) != 2 {
+		t.Fatalf("sink received %d events, want 2", len(sink.events))
+	}
+	if sink.events[0].Type != EventHTTPRequest || sink.events[0].Fields["provider"] != "walmart" {
+		t.Errorf("unexpected HTTP request event: %+v", sink.events[0])
+	}
+	if sink.events[1].Type != EventFXRate || sink.events[1].Level != "WARN" || sink.events[1].Fields["currency"] != "JPY" {
+		t.Errorf("unexpected FX event: %+v", sink.events[1])
+	}
+	if _, ok := sink.events[0].Fields["audit"]; ok {
+		t.Error("audit attribute should be the event type, not a field")
+	}
+
+	// Only the non-audit record reaches the wrapped handler
+	if lines := bytes.Count(stdout.Bytes(), []byte("\n")); lines != 1 || !bytes.Contains(stdout.Bytes(), []byte("retrying")) {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf)
+	event := Event{
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:    EventHTTPRequest,
+		Level:   "INFO",
+		Message: "HTTP request audit",
+		Fields:  map[string]any{"provider": "amazon", "status": 200},
+	}
+	if err := sink.Write(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"time":     "2024-05-01T12:00:00Z",
+		"level":    "INFO",
+		"msg":      "HTTP request audit",
+		"audit":    EventHTTPRequest,
+		"provider": "amazon",
+		"status":   float64(200),
+	}
+	for key, value := range want {
+		if decoded[key] != value {
+			t.Errorf("%s = %v, want %v", key, decoded[key], value)
+		}
+	}
+}
+
+type recordingWriter struct {
+	mu       sync.Mutex
+	batches  [][]Event
+	failures int // number of calls that fail before the destination recovers
+}
+
+func (w *recordingWriter) WriteBatch(ctx context.Context, events []Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("unavailable")
+	}
+	w.batches = append(w.batches, append([]Event{}, events...))
+	return nil
+}
+
+func (w *recordingWriter) sizes() []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sizes := []int{}
+	for _, batch := range w.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestBatchSink(t *testing.T) {
+	discard := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name     string
+		events   int
+		failures int
+		want     []int
+	}{
+		{name: "full batches and remainder on close", events: 7, want: []int{3, 3, 1}},
+		{name: "failed batches are retried on close", events: 4, failures: 2, want: []int{3, 1}},
+		{name: "no events", events: 0, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{failures: tt.failures}
+			sink := NewBatchSink(writer, 3, time.Hour, discard)
+			for i := 0; i < tt.events; i++ {
+				if err := sink.Write(context.Background(), Event{Type: EventHTTPRequest}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := sink.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+			got := writer.sizes()
+			if len(got) != len(tt.want) {
+				t.Fatalf("batch sizes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("batch sizes = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchSinkWriteAfterClose(t *testing.T) {
+	sink := NewBatchSink(&recordingWriter{}, 3, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sink.Write(context.Background(), Event{Type: EventHTTPRequest})
+			}
+		}()
+	}
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if err := sink.Write(context.Background(), Event{Type: EventHTTPRequest}); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("Write after Close = %v, want ErrSinkClosed", err)
+	}
+}
@@ -0,0 +1,280 @@
+// Package canonicalurl normalizes product and offer URLs, so the same page fetched with
+// different tracking parameters, host case or default port is stored under one URL.
+// Offers are unique per (product, source, seller, url), and listings without a provider
+// ID are keyed by their URL, so both depend on URLs being canonical.
+package canonicalurl
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/pricecompare/api/internal/resolver"
+)
+
+// ErrNotAbsolute is returned by Parse for URLs without an http(s) scheme and a host
+var ErrNotAbsolute = errors.New("not an absolute http(s) URL")
+
+// trackingParams are query parameters that only attribute traffic and never change the
+// page. Affiliate parameters (e.g. Amazon's tag) are kept, since links must carry them.
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"dclid":   true,
+	"gbraid":  true,
+	"wbraid":  true,
+	"fbclid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"spm":     true,
+}
+
+// trackingPrefixes are prefixes of tracking query parameters, e.g. utm_source
+var trackingPrefixes = []string{"utm_", "pk_", "mtm_"}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if trackingParams[name] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// affiliateParams are query parameters of affiliate networks that attribute a sale to
+// an affiliate or partner on any store. Canonicalize keeps them; WithoutAffiliate drops
+// them to compare listings.
+var affiliateParams = map[string]bool{
+	"affid":        true,
+	"aff_id":       true,
+	"affiliate":    true,
+	"affiliate_id": true,
+	"clickid":      true,
+	"irgwc":        true, // Impact (Walmart, Target, ...)
+	"irclickid":    true,
+	"subid":        true,
+	"sub_id":       true,
+	"cjevent":      true, // CJ
+	"awc":          true, // Awin
+	"ranmid":       true, // Rakuten
+	"raneaid":      true,
+	"ransiteid":    true,
+}
+
+// storeAffiliateParams are the parameters of a store's own affiliate program, keyed by
+// the store's domain label ("amazon" for www.amazon.co.jp). On other hosts names like
+// camp or ref_ are ordinary parameters and are kept.
+var storeAffiliateParams = map[string]map[string]bool{
+	"amazon": { // Amazon Associates
+		"tag":          true,
+		"ascsubtag":    true,
+		"linkcode":     true,
+		"linkid":       true,
+		"creative":     true,
+		"creativeasin": true,
+		"camp":         true,
+		"ref_":         true,
+	},
+	"walmart": {
+		"wmlspartner": true,
+		"veh":         true,
+		"sourceid":    true,
+	},
+}
+
+// isAffiliateParam reports whether the query parameter name attributes a sale on host
+func isAffiliateParam(host, name string) bool {
+	name = strings.ToLower(name)
+	if affiliateParams[name] {
+		return true
+	}
+	for _, label := range strings.Split(host, ".") {
+		if storeAffiliateParams[label][name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses an absolute http(s) URL and canonicalizes it: the scheme and host are
+// lowercased, the default port and the fragment are dropped, an empty path becomes "/",
+// tracking parameters are removed and the remaining parameters are sorted.
+func Parse(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	canonicalize(u)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrNotAbsolute
+	}
+	return u, nil
+}
+
+func canonicalize(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" && u.Host != "" {
+		u.Path = "/"
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if isTrackingParam(name) {
+				query.Del(name)
+			}
+		}
+		// Encode sorts by name
+		u.RawQuery = query.Encode()
+	}
+	u.ForceQuery = false
+}
+
+// Canonicalize returns the canonical form of raw, or raw with surrounding whitespace
+// trimmed when it is not an absolute http(s) URL (e.g. a sample file path)
+func Canonicalize(raw string) string {
+	u, err := Parse(raw)
+	if err != nil {
+		return strings.TrimSpace(raw)
+	}
+	return u.String()
+}
+
+// Resolve resolves a link found on the page at base (relative, root-relative or
+// protocol-relative) and canonicalizes it. It returns "" for an empty or unparsable link.
+func Resolve(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	baseURL, err := url.Parse(strings.TrimSpace(base))
+	if err != nil {
+		return Canonicalize(ref)
+	}
+	return Canonicalize(baseURL.ResolveReference(refURL).String())
+}
+
+// WithoutAffiliate returns the canonical form of raw without affiliate parameters, so
+// links to the same listing through different affiliate tags compare equal. It is not
+// meant to be stored, since links must carry their affiliate tag.
+func WithoutAffiliate(raw string) string {
+	u, err := Parse(raw)
+	if err != nil {
+		return strings.TrimSpace(raw)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if isAffiliateParam(u.Hostname(), name) {
+				query.Del(name)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// productStores recognizes the product pages of known stores for ProductURL
+var productStores = resolver.Default()
+
+// productPaths build the path of a product page from its identifier, for stores whose
+// page URLs also carry a slug that is not needed to open the page
+var productPaths = map[string]func(identifier string) string{
+	"amazon":  func(asin string) string { return "/dp/" + asin },
+	"walmart": func(itemID string) string { return "/ip/" + itemID },
+	"ebay":    func(itemNumber string) string { return "/itm/" + itemNumber },
+	"bestbuy": func(sku string) string { return "/site/" + sku + ".p" },
+}
+
+// ProductURL returns the clean link to the product page of an offer URL, to show instead
+// of the URL a provider returned with its search, session and affiliate parameters.
+// Product pages of known stores (see resolver.Default) lose their whole query, and
+// Amazon, Walmart, eBay and Best Buy pages are reduced to the path of their product ID;
+// other URLs lose their affiliate parameters (WithoutAffiliate). Like WithoutAffiliate,
+// the result is not meant to identify a listing.
+func ProductURL(raw string) string {
+	u, err := Parse(raw)
+	if err != nil {
+		return strings.TrimSpace(raw)
+	}
+	result, ok := productStores.Resolve(u)
+	if !ok {
+		return WithoutAffiliate(raw)
+	}
+	if path, ok := productPaths[result.Provider]; ok {
+		u.Path = path(result.Identifier)
+		u.RawPath = ""
+	}
+	u.RawQuery = ""
+	return u.String()
+}
+
+// affiliateRedirectHosts serve the affiliate links that affiliate APIs mint for the
+// operator's own credentials (AliExpress promotion links, Rakuten affiliate URLs). They
+// redirect to the product page, so the attribution cannot be moved onto it.
+var affiliateRedirectHosts = map[string]bool{
+	"s.click.aliexpress.com": true,
+	"hb.afl.rakuten.co.jp":   true,
+}
+
+// AffiliateTags are the operator's own affiliate parameters, e.g. {"tag": "pc-20"} for
+// AMAZON_ASSOCIATE_TAG. Link keeps them and drops everyone else's.
+type AffiliateTags map[string]string
+
+// Link returns the link to show for an offer fetched as raw, whose product page is
+// productURL (see ProductURL). Affiliate redirect links are returned as they are; other
+// links are productURL with those of the operator's parameters that raw carried, so a
+// third party's tag is dropped while the operator's attribution is kept.
+func (t AffiliateTags) Link(raw, productURL string) string {
+	u, err := Parse(raw)
+	if err != nil {
+		return productURL
+	}
+	if affiliateRedirectHosts[u.Hostname()] {
+		return u.String()
+	}
+	product, err := Parse(productURL)
+	if err != nil || len(t) == 0 {
+		return productURL
+	}
+	query, productQuery := u.Query(), product.Query()
+	kept := false
+	for name, values := range query {
+		value, ok := t[strings.ToLower(name)]
+		if ok && value != "" && len(values) > 0 && values[0] == value {
+			productQuery.Set(name, value)
+			kept = true
+		}
+	}
+	if !kept {
+		return productURL
+	}
+	product.RawQuery = productQuery.Encode()
+	return product.String()
+}
@@ -0,0 +1,140 @@
+package canonicalurl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"already canonical", "https://www.walmart.com/ip/5461164337", "https://www.walmart.com/ip/5461164337"},
+		{"host and scheme case", "HTTPS://WWW.Amazon.com/dp/B08N5WRWNW", "https://www.amazon.com/dp/B08N5WRWNW"},
+		{"path case is kept", "https://example.com/Product/ABC", "https://example.com/Product/ABC"},
+		{"default port", "https://example.com:443/item", "https://example.com/item"},
+		{"other port", "http://localhost:8080/item", "http://localhost:8080/item"},
+		{"empty path", "https://example.com", "https://example.com/"},
+		{"fragment", "https://example.com/item#reviews", "https://example.com/item"},
+		{"tracking params", "https://example.com/item?utm_source=news&id=7&gclid=abc&UTM_Medium=x", "https://example.com/item?id=7"},
+		{"only tracking params", "https://example.com/item?fbclid=abc", "https://example.com/item"},
+		{"affiliate tag is kept", "https://www.amazon.com/dp/B08N5WRWNW?tag=pc-20&linkCode=ogi", "https://www.amazon.com/dp/B08N5WRWNW?linkCode=ogi&tag=pc-20"},
+		{"sorted params", "https://example.com/search?q=tv&page=2", "https://example.com/search?page=2&q=tv"},
+		{"trailing dot", "https://example.com./item", "https://example.com/item"},
+		{"whitespace", "  https://example.com/item \n", "https://example.com/item"},
+		{"not a URL", "samples/product.html", "samples/product.html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Canonicalize(tt.raw); got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, raw := range []string{"/ip/123", "ftp://example.com/file", "mailto:buyer@example.com"} {
+		if _, err := Parse(raw); !errors.Is(err, ErrNotAbsolute) {
+			t.Errorf("Parse(%q) error = %v, want ErrNotAbsolute", raw, err)
+		}
+	}
+	u, err := Parse("https://WWW.AMAZON.COM/gp/product/B08N5WRWNW?utm_campaign=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "www.amazon.com" || u.RawQuery != "" {
+		t.Errorf("Parse() = %s", u)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	base := "https://shop.example.com/search?q=headphones"
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"/p/123?utm_source=list", "https://shop.example.com/p/123"},
+		{"p/123", "https://shop.example.com/p/123"},
+		{"//cdn.example.com/img/1.jpg", "https://cdn.example.com/img/1.jpg"},
+		{"https://Other.example.com/p/9", "https://other.example.com/p/9"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Resolve(base, tt.ref); got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestWithoutAffiliate(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://www.amazon.com/dp/B08N5WRWNW?tag=pc-20&linkCode=ogi&th=1", "https://www.amazon.com/dp/B08N5WRWNW?th=1"},
+		{"https://www.walmart.com/ip/123?wmlspartner=abc&veh=aff&utm_source=x", "https://www.walmart.com/ip/123"},
+		{"https://example.com/item?id=7", "https://example.com/item?id=7"},
+		{"https://www.amazon.co.jp/dp/B09XS7JWHH?ref_=ast_sto_dp&camp=1&creative=2", "https://www.amazon.co.jp/dp/B09XS7JWHH"},
+		{"https://shop.example.com/sale?camp=summer&creative=banner&ref_=home&irgwc=1", "https://shop.example.com/sale?camp=summer&creative=banner&ref_=home"},
+		{"https://www.target.com/p/-/A-86216166?tag=red&veh=aff&afid=x", "https://www.target.com/p/-/A-86216166?afid=x&tag=red&veh=aff"},
+		{"samples/product.html", "samples/product.html"},
+	}
+	for _, tt := range tests {
+		if got := WithoutAffiliate(tt.raw); got != tt.want {
+			t.Errorf("WithoutAffiliate(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestProductURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"amazon slug and affiliate tag", "https://www.amazon.com/Sony-WH-1000XM5-Canceling-Headphones/dp/B09XS7JWHH?tag=pc-20&linkCode=ogi&th=1&psc=1", "https://www.amazon.com/dp/B09XS7JWHH"},
+		{"amazon marketplace", "https://www.amazon.co.jp/gp/product/B09XS7JWHH?ref_=ast_sto_dp", "https://www.amazon.co.jp/dp/B09XS7JWHH"},
+		{"walmart search noise", "https://www.walmart.com/ip/Sony-WH-1000XM5/5461164337?athbdg=L1600&from=/search&wmlspartner=abc", "https://www.walmart.com/ip/5461164337"},
+		{"ebay listing", "https://www.ebay.com/itm/sony-wh1000xm5/296511234567?hash=item450a&var=0&mkcid=1", "https://www.ebay.com/itm/296511234567"},
+		{"best buy sku parameter", "https://www.bestbuy.com/site/sony-wh1000xm5-headphones/6505727.p?skuId=6505727", "https://www.bestbuy.com/site/6505727.p"},
+		{"other known store", "https://www.aliexpress.com/item/1005004878211234.html?spm=a2g0o&aff_fcid=abc&pdp_npi=4", "https://www.aliexpress.com/item/1005004878211234.html"},
+		{"unknown store keeps its parameters", "https://shop.example.com/item?id=7&affid=pc&utm_source=x", "https://shop.example.com/item?id=7"},
+		{"not a URL", "samples/product.html", "samples/product.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProductURL(tt.raw); got != tt.want {
+				t.Errorf("ProductURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAffiliateTagsLink(t *testing.T) {
+	tags := AffiliateTags{"tag": "pc-20"}
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"operator's tag is kept", "https://www.amazon.com/Sony-WH-1000XM5/dp/B09XS7JWHH?tag=pc-20&linkCode=ogi&th=1", "https://www.amazon.com/dp/B09XS7JWHH?tag=pc-20"},
+		{"third-party tag is dropped", "https://www.amazon.com/Sony-WH-1000XM5/dp/B09XS7JWHH?tag=rapid-20&th=1", "https://www.amazon.com/dp/B09XS7JWHH"},
+		{"aliexpress promotion link", "https://s.click.aliexpress.com/e/_DlXyZ12", "https://s.click.aliexpress.com/e/_DlXyZ12"},
+		{"rakuten affiliate URL", "https://hb.afl.rakuten.co.jp/hgc/abc/?pc=https%3A%2F%2Fitem.rakuten.co.jp%2Fshop%2Fitem%2F", "https://hb.afl.rakuten.co.jp/hgc/abc/?pc=https%3A%2F%2Fitem.rakuten.co.jp%2Fshop%2Fitem%2F"},
+		{"no affiliate parameters", "https://www.walmart.com/ip/Sony/5461164337?athbdg=L1600", "https://www.walmart.com/ip/5461164337"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tags.Link(tt.raw, ProductURL(tt.raw)); got != tt.want {
+				t.Errorf("Link(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+	if got := AffiliateTags(nil).Link("https://www.amazon.com/dp/B09XS7JWHH?tag=pc-20", "https://www.amazon.com/dp/B09XS7JWHH"); got != "https://www.amazon.com/dp/B09XS7JWHH" {
+		t.Errorf("Link() without tags = %q", got)
+	}
+}
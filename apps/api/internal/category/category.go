@@ -0,0 +1,81 @@
+package category
+
+import "strings"
+
+// Category slugs used across the catalog. Providers map their own taxonomies
+// onto these so that shipping tables and duty rates can be keyed consistently.
+const (
+	Electronics = "electronics"
+	Computers   = "computers"
+	Phones      = "phones"
+	Audio       = "audio"
+	Watches     = "watches"
+	Apparel     = "apparel"
+	Footwear    = "footwear"
+	Bags        = "bags"
+	Jewelry     = "jewelry"
+	Cosmetics   = "cosmetics"
+	Toys        = "toys"
+	Games       = "games"
+	Books       = "books"
+	Furniture   = "furniture"
+	Home        = "home"
+	Sports      = "sports"
+	Other       = "other"
+)
+
+// All lists every known category slug.
+var All = []string{
+	Electronics, Computers, Phones, Audio, Watches,
+	Apparel, Footwear, Bags, Jewelry, Cosmetics,
+	Toys, Games, Books, Furniture, Home, Sports, Other,
+}
+
+// aliases maps common provider/category labels to a slug
+var aliases = map[string]string{
+	"electronic":        Electronics,
+	"cameras":           Electronics,
+	"computer":          Computers,
+	"laptop":            Computers,
+	"laptops":           Computers,
+	"tablet":            Computers,
+	"tablets":           Computers,
+	"phone":             Phones,
+	"smartphone":        Phones,
+	"smartphones":       Phones,
+	"cell phones":       Phones,
+	"headphones":        Audio,
+	"speakers":          Audio,
+	"watch":             Watches,
+	"clothing":          Apparel,
+	"fashion":           Apparel,
+	"shoes":             Footwear,
+	"handbags":          Bags,
+	"luggage":           Bags,
+	"beauty":            Cosmetics,
+	"toy":               Toys,
+	"video games":       Games,
+	"game":              Games,
+	"book":              Books,
+	"home & kitchen":    Home,
+	"kitchen":           Home,
+	"sports & outdoors": Sports,
+}
+
+// Normalize maps a free-form category label to a known slug.
+// Unknown labels map to Other; an empty label stays empty.
+func Normalize(label string) string {
+	key := strings.ToLower(strings.TrimSpace(label))
+	if key == "" {
+		return ""
+	}
+	for _, slug := range All {
+		if key == slug {
+			return slug
+		}
+	}
+	if slug, ok := aliases[key]; ok {
+		return slug
+	}
+	return Other
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,11 +14,11 @@ import (
 
 // Checker checks robots.txt compliance
 type Checker struct {
-	cache     Cache
-	ttl       time.Duration
-	httpClient *http.Client
-	logger    *slog.Logger
-	mu        sync.RWMutex
+	cache       Cache
+	ttl         time.Duration
+	httpClient  *http.Client
+	logger      *slog.Logger
+	mu          sync.RWMutex
 	memoryCache map[string]cacheEntry
 }
 
@@ -29,15 +30,22 @@ type cacheEntry struct {
 // NewChecker creates a new robots.txt checker
 func NewChecker(cache Cache, ttl time.Duration, httpClient *http.Client, logger *slog.Logger) *Checker {
 	checker := &Checker{
-		cache:      cache,
-		ttl:        ttl,
-		httpClient: httpClient,
-		logger:     logger,
+		cache:       cache,
+		ttl:         ttl,
+		httpClient:  httpClient,
+		logger:      logger,
 		memoryCache: make(map[string]cacheEntry),
 	}
 	return checker
 }
 
+// MemoryCacheSize returns the number of robots.txt files held in the in-memory cache
+func (c *Checker) MemoryCacheSize() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.memoryCache)
+}
+
 // CanFetch checks if a URL can be fetched according to robots.txt
 // Returns: (allowed, ruleGroup, error)
 // ruleGroup is the User-agent group that matched (e.g., "User-agent: *")
@@ -66,16 +74,74 @@ func (c *Checker) CanFetch(ctx context.Context, targetURL, userAgent string) (bo
 	// Parse robots.txt
 	rules := c.parseRobotsTxt(robotsContent, userAgent)
 
-	// Check if path is allowed
-	path := u.Path
-	if path == "" {
-		path = "/"
-	}
+	// Rules match the path with its query (e.g. "Disallow: /*?sort="), escaped as sent
+	path := u.RequestURI()
 
 	allowed, ruleGroup := c.checkPath(path, rules, userAgent)
 	return allowed, ruleGroup, nil
 }
 
+// Sitemaps returns the URLs of the Sitemap lines in the robots.txt of the site of
+// siteURL. Sitemap lines are not part of a User-agent group and apply to every crawler.
+func (c *Checker) Sitemaps(ctx context.Context, siteURL string) ([]string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	robotsURL := fmt.Sprintf("%s://%s/robots.txt", u.Scheme, u.Host)
+	cacheKey := fmt.Sprintf("robots:%s://%s", u.Scheme, u.Host)
+
+	robotsContent, err := c.getRobotsTxt(ctx, cacheKey, robotsURL)
+	if err != nil {
+		return nil, fmt.Errorf("robots.txt fetch failed: %w", err)
+	}
+	return parseSitemaps(robotsContent, robotsURL), nil
+}
+
+// parseSitemaps returns the values of the Sitemap lines of a robots.txt. Relative ones,
+// which some sites list although the protocol asks for absolute URLs, are resolved
+// against robotsURL.
+func parseSitemaps(content []byte, robotsURL string) []string {
+	base, _ := url.Parse(robotsURL)
+	var sitemaps []string
+	for _, line := range strings.Split(string(content), "\n") {
+		directive, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || strings.ToLower(strings.TrimSpace(directive)) != "sitemap" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if ref, err := url.Parse(value); err == nil && !ref.IsAbs() && base != nil {
+			value = base.ResolveReference(ref).String()
+		}
+		sitemaps = append(sitemaps, value)
+	}
+	return sitemaps
+}
+
+// CrawlDelay returns the Crawl-delay of the User-agent group of the robots.txt of the
+// site of targetURL that applies to userAgent, or 0 if the group has none
+func (c *Checker) CrawlDelay(ctx context.Context, targetURL, userAgent string) (time.Duration, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid URL: %w", err)
+	}
+	robotsURL := fmt.Sprintf("%s://%s/robots.txt", u.Scheme, u.Host)
+	cacheKey := fmt.Sprintf("robots:%s://%s", u.Scheme, u.Host)
+
+	robotsContent, err := c.getRobotsTxt(ctx, cacheKey, robotsURL)
+	if err != nil {
+		return 0, fmt.Errorf("robots.txt fetch failed: %w", err)
+	}
+	var delay time.Duration
+	for _, rule := range matchingRules(c.parseRobotsTxt(robotsContent, userAgent), userAgent) {
+		delay = max(delay, rule.CrawlDelay)
+	}
+	return delay, nil
+}
+
 func (c *Checker) getRobotsTxt(ctx context.Context, cacheKey, robotsURL string) ([]byte, error) {
 	// Try cache first
 	if c.cache != nil {
@@ -188,9 +254,10 @@ func (c *Checker) fetchRobotsTxt(ctx context.Context, robotsURL string) ([]byte,
 
 // RobotsRule represents a single rule from robots.txt
 type RobotsRule struct {
-	UserAgent string
-	Disallow  []string
-	Allow     []string
+	UserAgent  string
+	Disallow   []string
+	Allow      []string
+	CrawlDelay time.Duration // minimum time between requests, 0 if the group has none
 }
 
 func (c *Checker) parseRobotsTxt(content []byte, userAgent string) []RobotsRule {
@@ -232,6 +299,12 @@ func (c *Checker) parseRobotsTxt(content []byte, userAgent string) []RobotsRule
 			if currentRule != nil {
 				currentRule.Allow = append(currentRule.Allow, value)
 			}
+		case "crawl-delay":
+			// Seconds, possibly fractional; malformed values are ignored
+			seconds, err := strconv.ParseFloat(value, 64)
+			if currentRule != nil && err == nil && seconds > 0 {
+				currentRule.CrawlDelay = time.Duration(seconds * float64(time.Second))
+			}
 		}
 	}
 
@@ -243,9 +316,9 @@ func (c *Checker) parseRobotsTxt(content []byte, userAgent string) []RobotsRule
 	return rules
 }
 
-func (c *Checker) checkPath(path string, rules []RobotsRule, userAgent string) (bool, string) {
-	// Find matching user-agent rules
-	// Priority: exact match > * (wildcard)
+// matchingRules returns the rules of the User-agent groups that apply to userAgent
+// Priority: exact match > * (wildcard)
+func matchingRules(rules []RobotsRule, userAgent string) []RobotsRule {
 	var matchedRules []RobotsRule
 	var wildcardRule *RobotsRule
 
@@ -261,71 +334,67 @@ func (c *Checker) checkPath(path string, rules []RobotsRule, userAgent string) (
 	if len(matchedRules) == 0 && wildcardRule != nil {
 		matchedRules = append(matchedRules, *wildcardRule)
 	}
+	return matchedRules
+}
+
+// checkPath applies the Allow and Disallow rules of the groups that apply to userAgent
+// the way Google does: the longest matching pattern wins, and Allow wins a tie. A path
+// no rule matches is allowed.
+func (c *Checker) checkPath(path string, rules []RobotsRule, userAgent string) (bool, string) {
+	matchedRules := matchingRules(rules, userAgent)
 
 	// If no rules match, allow by default (per robots.txt spec)
 	if len(matchedRules) == 0 {
 		return true, ""
 	}
 
-	// Check all matched rules
-	// If any rule allows, it's allowed
-	// If all rules disallow, it's disallowed
+	// Groups naming the same User-agent are combined
+	allowed, longest := true, -1
 	for _, rule := range matchedRules {
-		allowed, ruleGroup := c.checkPathAgainstRule(path, rule)
-		if allowed {
-			return true, ruleGroup
-		}
-	}
-
-	// All rules disallow
-	return false, matchedRules[0].UserAgent
-}
-
-func (c *Checker) checkPathAgainstRule(path string, rule RobotsRule) (bool, string) {
-	// Check Allow rules first (they take precedence)
-	for _, allowPath := range rule.Allow {
-		if c.pathMatches(path, allowPath) {
-			return true, rule.UserAgent
+		for _, pattern := range rule.Disallow {
+			if len(pattern) > longest && c.pathMatches(path, pattern) {
+				allowed, longest = false, len(pattern)
+			}
 		}
-	}
-
-	// Check Disallow rules
-	for _, disallowPath := range rule.Disallow {
-		if c.pathMatches(path, disallowPath) {
-			return false, rule.UserAgent
+		for _, pattern := range rule.Allow {
+			if len(pattern) >= longest && c.pathMatches(path, pattern) {
+				allowed, longest = true, len(pattern)
+			}
 		}
 	}
-
-	// If no disallow matches, allow
-	return true, rule.UserAgent
+	return allowed, matchedRules[0].UserAgent
 }
 
+// pathMatches reports whether a robots.txt path pattern matches path. Patterns match as
+// prefixes; "*" matches any sequence of characters and a trailing "$" anchors the pattern
+// at the end of the path. An empty pattern matches nothing ("Disallow:" allows all).
 func (c *Checker) pathMatches(path, pattern string) bool {
-	// Empty pattern means allow all
 	if pattern == "" {
 		return false
 	}
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
 
-	// Pattern must be a prefix of path
-	if !strings.HasPrefix(path, pattern) {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
 		return false
 	}
-
-	// Exact match
-	if path == pattern {
-		return true
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
 	}
 
-	// Pattern ends with /, so any path starting with it matches
-	if strings.HasSuffix(pattern, "/") {
-		return true
+	// The leftmost match of each part leaves the most room for the parts after it
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
 	}
-
-	// Pattern doesn't end with /, so only exact match or path starting with pattern/
-	if strings.HasPrefix(path, pattern+"/") {
-		return true
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
 	}
-
-	return false
+	return strings.Contains(rest, last)
 }
-
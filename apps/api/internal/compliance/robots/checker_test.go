@@ -108,6 +108,22 @@ func TestChecker_pathMatches(t *testing.T) {
 		{"no match", "/admin", "/products", false},
 		{"empty pattern allows all", "/anything", "", false},
 		{"root path", "/", "/", true},
+		{"plain prefix", "/productsale", "/products", true},
+		{"wildcard in the middle", "/shop/42/cart", "/shop/*/cart", true},
+		{"wildcard needs the rest", "/shop/42/wishlist", "/shop/*/cart", false},
+		{"wildcard before a query", "/search?q=tv&sort=price", "/*?sort=", false},
+		{"wildcard anywhere in the query", "/search?sort=price", "/*?sort=", true},
+		{"query parameter anywhere", "/search?q=tv&sort=price", "/*sort=", true},
+		{"trailing wildcard is a prefix", "/fish.html", "/fish*", true},
+		{"anchor matches the end", "/file.php", "/*.php$", true},
+		{"anchor rejects a longer path", "/file.php?id=1", "/*.php$", false},
+		{"anchor without wildcard", "/", "/$", true},
+		{"anchor without wildcard rejects subpaths", "/index.html", "/$", false},
+		{"consecutive wildcards", "/a/b/c", "/a/**/c", true},
+		{"wildcard matches empty", "/ab", "/a*b", true},
+		{"repeated part uses a later occurrence", "/a.php.bak.php", "/*.php$", true},
+		{"dollar inside is literal", "/price$low", "/price$low", true},
+		{"case sensitive", "/Products", "/products", false},
 	}
 
 	for _, tt := range tests {
@@ -120,3 +136,115 @@ func TestChecker_pathMatches(t *testing.T) {
 	}
 }
 
+func TestChecker_checkPath(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	checker := NewChecker(nil, 1*time.Hour, &http.Client{}, logger)
+	rules := checker.parseRobotsTxt([]byte(`User-agent: *
+Disallow: /*?sort=
+Disallow: /shop/
+Allow: /shop/products/
+Disallow: /shop/products/*.pdf$
+Allow: /page
+Disallow: /*.htm
+Disallow: /folder
+Allow: /folder
+
+User-agent: PriceCompareBot
+Disallow: /private/
+
+User-agent: PriceCompareBot
+Allow: /private/public/
+`), "")
+
+	tests := []struct {
+		name      string
+		path      string
+		userAgent string
+		want      bool
+	}{
+		{"no rule matches", "/about", "Mozilla/5.0", true},
+		{"wildcard query rule", "/search?sort=price", "Mozilla/5.0", false},
+		{"query rule needs its literal ?", "/search?q=tv&sort=price", "Mozilla/5.0", true},
+		{"longer allow beats shorter disallow", "/shop/products/123", "Mozilla/5.0", true},
+		{"longer disallow beats shorter allow", "/shop/products/manual.pdf", "Mozilla/5.0", false},
+		{"anchored disallow only matches the end", "/shop/products/manual.pdf?v=2", "Mozilla/5.0", true},
+		{"disallowed prefix", "/shop/cart", "Mozilla/5.0", false},
+		{"longer wildcard disallow wins", "/page.htm", "Mozilla/5.0", false},
+		{"equal length allow wins", "/folder/page", "Mozilla/5.0", true},
+		{"groups of the same agent are combined", "/private/public/x", "PriceCompareBot", true},
+		{"specific group replaces the wildcard group", "/shop/cart", "PriceCompareBot", true},
+		{"specific group disallow", "/private/x", "PriceCompareBot", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed, _ := checker.checkPath(tt.path, rules, tt.userAgent); allowed != tt.want {
+				t.Errorf("checkPath(%q, %q) = %v, want %v", tt.path, tt.userAgent, allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_Sitemaps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`Sitemap: https://shop.example.com/sitemap_index.xml
+User-agent: *
+Disallow: /admin/
+sitemap:https://shop.example.com/products.xml
+Sitemap:
+Sitemap: /sitemaps/categories.xml
+`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	checker := NewChecker(nil, time.Hour, &http.Client{Timeout: 5 * time.Second}, logger)
+
+	sitemaps, err := checker.Sitemaps(context.Background(), server.URL+"/products/123")
+	if err != nil {
+		t.Fatalf("Sitemaps() error = %v", err)
+	}
+	// Relative sitemaps are resolved against the robots.txt URL
+	want := []string{"https://shop.example.com/sitemap_index.xml", "https://shop.example.com/products.xml", server.URL + "/sitemaps/categories.xml"}
+	if len(sitemaps) != len(want) || sitemaps[0] != want[0] || sitemaps[1] != want[1] || sitemaps[2] != want[2] {
+		t.Errorf("Sitemaps() = %v, want %v", sitemaps, want)
+	}
+}
+
+func TestChecker_CrawlDelay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`User-agent: *
+Crawl-delay: 10
+Disallow: /admin/
+
+User-agent: PriceCompareBot
+Crawl-delay: 2.5
+
+User-agent: OtherBot
+Crawl-delay: soon
+`))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	checker := NewChecker(nil, time.Hour, &http.Client{Timeout: 5 * time.Second}, logger)
+
+	tests := []struct {
+		userAgent string
+		want      time.Duration
+	}{
+		{"PriceCompareBot", 2500 * time.Millisecond},
+		{"Mozilla/5.0", 10 * time.Second},
+		{"OtherBot", 0},
+	}
+	for _, tt := range tests {
+		delay, err := checker.CrawlDelay(context.Background(), server.URL+"/products/123", tt.userAgent)
+		if err != nil {
+			t.Fatalf("CrawlDelay(%q) error = %v", tt.userAgent, err)
+		}
+		if delay != tt.want {
+			t.Errorf("CrawlDelay(%q) = %v, want %v", tt.userAgent, delay, tt.want)
+		}
+	}
+}
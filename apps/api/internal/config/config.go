@@ -1,52 +1,298 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	APIPort           string
-	APIHost           string
-	PostgresHost      string
-	PostgresPort      string
-	PostgresUser      string
-	PostgresPassword  string
-	PostgresDB        string
-	PostgresSSLMode   string
-	RedisHost         string
-	RedisPort         string
-	RedisPassword     string
-	RedisDB           string
-	ShippingMode      string
-	ShippingFeePercent float64
-	FXUSDJPY          float64
-	UserAgent         string
-	RateLimitRPS      int
-	RateLimitBurst    int
+	AppEnv                          string // "development" or "production"
+	LogLevel                        string // "debug", "info", "warn" or "error"
+	APIPort                         string
+	APIHost                         string
+	RepositoryBackend               string // "postgres" or "memory" (in-memory, development and tests only)
+	PostgresHost                    string
+	PostgresPort                    string
+	PostgresUser                    string
+	PostgresPassword                string
+	PostgresDB                      string
+	PostgresSSLMode                 string
+	RedisHost                       string
+	RedisPort                       string
+	RedisPassword                   string
+	RedisDB                         string
+	QueueMode                       string // "asynq" (Redis) or "inline" (in-process goroutines, no Redis)
+	QueueConcurrency                int    // jobs processed in parallel
+	InlineQueueSize                 int    // QUEUE_MODE=inline: jobs waiting for a worker before enqueueing fails
+	QueueStuckAfterSeconds          int    // GET /health/deep fails when a pending job has waited longer
+	ShippingMode                    string
+	ShippingFeePercent              float64
+	FXUSDJPY                        float64
+	FXMarkupPercent                 float64
+	FXProviders                     []string // FX providers in priority order ("http", "ecb", "exchangerate_host", "static")
+	FXAPIURL                        string
+	FXECBURL                        string
+	FXExchangeRateHostURL           string
+	FXExchangeRateHostAccessKey     string
+	FXRefreshMinutes                int
+	FXMaxAgeHours                   int
+	DutyDefaultPercent              float64
+	DutyDeMinimisUSD                float64
+	FreeShippingThresholds          map[string]float64 // source -> minimum order in USD (0 = always free)
+	OfferFreshnessSLAHours          map[string]float64 // source ("*" for others) -> hours after which its offers are reported stale
+	FeeRulesFile                    string             // optional YAML file with fee rules; the fee_rules table takes precedence
+	ShippingTablesFile              string             // optional YAML file with per-category TABLE mode overrides
+	TitleMatchThreshold             float64            // minimum pg_trgm similarity of title duplicates found by the duplicate scan
+	MatchScoreThreshold             float64            // minimum matching.Score for a listing to join an existing product by title
+	OfferAnomalyDropPercent         float64            // offers this far below the product's median price are quarantined; 0 disables the check
+	IngestMinTitleLength            int                // candidates with shorter titles are not turned into products
+	IngestBannedKeywords            []string           // candidates whose title contains one of these words are skipped
+	IngestRequirePriceSources       []string           // sources whose candidates must show a price in the search listing
+	IngestMaxListingsPerSource      map[string]int     // source -> listings it may have in the catalog; others are unlimited
+	IngestDailyListingQuota         map[string]int     // source -> new listings it may add in 24 hours
+	FetchMaxCandidatesPerQuery      int                // crawl budget of one fetch_prices run (0 = unlimited): candidates processed per search query
+	FetchMaxOffersPerProduct        int                // offers saved per product and source
+	FetchMaxRequests                int                // provider calls (searches and offer fetches) across all sources
+	ProviderLocales                 map[string]string  // provider -> BCP 47 locale its listings are requested in (e.g. "ja-JP")
+	ProviderTimeoutSeconds          map[string]float64 // provider ("*" for others) -> deadline of one Search or FetchOffers call across all its HTTP requests; 0 = none
+	ProviderCircuitFailures         int                // consecutive failed calls after which fan-outs skip a provider; 0 disables the circuit breaker
+	ProviderCircuitCooldownSeconds  int                // how long a provider is skipped before it is tried again
+	LiveProviderMode                string             // "search" (the site's search page) or "sitemap" (product pages from its sitemaps)
+	LiveSitemapURLPatterns          []string           // LIVE_PROVIDER_MODE=sitemap: regular expressions; pages matching one are products
+	LiveSitemapMaxPages             int                // LIVE_PROVIDER_MODE=sitemap: product pages read per search query (0 = unlimited)
+	LiveRenderMode                  string             // "" (fetch pages) or "browserless" (render them in a headless browser, see internal/render)
+	LiveRenderWaitSelector          string             // LIVE_RENDER_MODE: CSS selector the browser waits for before returning the page
+	RenderBrowserlessURL            string             // browserless endpoint of LIVE_RENDER_MODE=browserless
+	RenderBrowserlessToken          string
+	RenderTimeoutSeconds            int               // page load deadline in the browser
+	ProviderFixtureMode             string            // "" (off), "record" (save provider HTTP responses) or "replay" (answer from saved ones only)
+	ProviderFixtureDir              string            // directory of recorded provider fixtures
+	DuplicateScanCron               string            // cron spec for the detect_duplicates job; empty disables scheduling
+	CatalogReportCron               string            // cron spec for the catalog_report job (needs notification channels); empty disables it
+	MaintenanceCron                 string            // cron spec for the db_maintenance job; empty disables scheduling
+	FetchCrons                      map[string]string // source -> cron spec of its scheduled fetch_prices job, from FETCH_CRON_<SOURCE>
+	CatalogReportPriceChangePercent float64           // price changes of at least this much (either way) are reported
+	CatalogReportStaleHours         int               // offers not refreshed for this long are reported as stale
+	EmbeddingBackend                string            // "openai", "tei" (text-embeddings-inference server), or empty to disable embedding matching
+	EmbeddingModel                  string
+	EmbeddingLocalURL               string
+	EmbeddingMatchThreshold         float64
+	OpenAIAPIKey                    string
+	TranslationBackend              string // "deepl", "openai", or empty to store listing titles untranslated
+	TranslationModel                string // OpenAI chat model of TRANSLATION_BACKEND=openai
+	DeepLAPIKey                     string
+	DeepLAPIURL                     string // https://api-free.deepl.com for free keys, https://api.deepl.com for paid ones
+	ImageHashEnabled                bool   // hash product images during ingestion and match on them
+	ImageMatchMaxDistance           int    // maximum pHash Hamming distance (0..64) for an image match
+	OTelEndpoint                    string // OTLP/HTTP endpoint for traces; empty disables export
+	OTelServiceName                 string
+	OTelSampleRatio                 float64
+	DebugAddr                       string             // listen address for pprof/expvar; empty disables the debug server
+	DebugToken                      string             // bearer token for the debug server; required unless DebugAddr is loopback
+	APIAuthEnabled                  bool               // require an API key on the admin, resolve-url and image-search routes; only development may disable it
+	AdminAPIKey                     string             // key with the admin role that is not stored in api_keys, used to create the first keys
+	APIKeyRateLimitPerMinute        int                // requests per minute of an API key without its own limit (0 = unlimited)
+	PublicAPIKeyRateLimitPerMinute  int                // the same for keys with the public role
+	PublicAPIKeyRequired            bool               // require a key on the search, product and compare endpoints too
+	AnonymousRateLimitPerMinute     int                // requests per minute and client IP without a key to those endpoints (0 = unlimited)
+	ProxyHeader                     string             // header holding the client IP set by a reverse proxy in front of the API (e.g. X-Real-IP)
+	ResponseCacheSearchTTLSeconds   int                // how long /api/search responses are cached (0 = not cached)
+	ResponseCacheOffersTTLSeconds   int                // how long the offers and compare responses of a product are cached (0 = not cached)
+	FeedCacheTTLSeconds             int                // how long the catalog read for /sitemap.xml and the product feeds is reused
+	SiteURL                         string             // public URL of the web app, linked from /sitemap.xml and the product feeds; empty disables them
+	PublicAPIURL                    string             // public URL of this API, linked from the confirmation emails of price alerts; empty disables email alerts
+	AmazonAssociateTag              string             // the operator's Amazon Associates tag, kept on the Amazon links returned to clients
+	RequestTimeoutSeconds           int                // deadline of a request's repository and provider calls (0 = none)
+	RouteRequestTimeoutSeconds      map[string]float64 // RequestTimeoutSeconds of the routes under a path prefix
+	AuditSink                       string             // "stdout", "postgres", "s3" or "http"
+	AuditBatchSize                  int
+	AuditFlushSeconds               int
+	AuditS3Bucket                   string
+	AuditS3Prefix                   string
+	AuditS3Endpoint                 string // optional S3-compatible endpoint (e.g. MinIO); uses path-style URLs
+	AuditHTTPURL                    string
+	AuditHTTPToken                  string
+	AuditRetentionDays              int    // AUDIT_SINK=postgres: audit events older than this are pruned by db_maintenance (0 = kept)
+	OfferMergeLogRetentionDays      int    // offer_merge_log rows older than this are pruned by db_maintenance (0 = kept)
+	SnapshotS3Bucket                string // bucket for raw HTML snapshots of live pages; empty disables archiving
+	SnapshotS3Prefix                string
+	SnapshotS3Endpoint              string // optional S3-compatible endpoint (e.g. MinIO); uses path-style URLs
+	SearchBackend                   string // "meilisearch", "elasticsearch", or empty to use the SQL search only
+	SearchURL                       string
+	SearchAPIKey                    string
+	SearchIndex                     string
+	SearchReindexCron               string   // cron spec for the reindex_search job; empty disables scheduling
+	EventBus                        string   // "redis", "nats", "kafka", or empty to disable domain events
+	EventBusTopic                   string   // Redis stream, NATS subject prefix or Kafka topic
+	EventBusURL                     string   // NATS server URL
+	EventBusBrokers                 []string // Kafka bootstrap brokers
+	EventBusStreamMaxLen            int      // approximate maximum length of the Redis stream
+	NotifySMTPAddr                  string   // host:port; empty disables email notifications
+	NotifySMTPUsername              string
+	NotifySMTPPassword              string
+	NotifyEmailFrom                 string
+	NotifyEmailTo                   []string
+	NotifySlackWebhookURL           string              // empty disables Slack notifications
+	NotifyRoutes                    map[string][]string // alert kind ("*" for the rest) -> channels; empty sends every alert everywhere
+	NotifyJobFailureCooldownMinutes int                 // minimum time between job failure alerts of the same task type
+	EnableDemoProviders             bool                // demo / public_html providers; not allowed in production
+	UserAgent                       string
+	RateLimitRPS                    int
+	RateLimitBurst                  int
+
+	loadErrors []error // malformed values, reported by Validate
 }
 
 func Load() *Config {
-	return &Config{
-		APIPort:           getEnv("API_PORT", "8080"),
-		APIHost:           getEnv("API_HOST", "0.0.0.0"),
-		PostgresHost:      getEnv("POSTGRES_HOST", "localhost"),
-		PostgresPort:      getEnv("POSTGRES_PORT", "5432"),
-		PostgresUser:      getEnv("POSTGRES_USER", "pricecompare"),
-		PostgresPassword:  getEnv("POSTGRES_PASSWORD", "password"),
-		PostgresDB:        getEnv("POSTGRES_DB", "pricecompare"),
-		PostgresSSLMode:   getEnv("POSTGRES_SSLMODE", "disable"),
-		RedisHost:         getEnv("REDIS_HOST", "localhost"),
-		RedisPort:         getEnv("REDIS_PORT", "6379"),
-		RedisPassword:     getEnv("REDIS_PASSWORD", ""),
-		RedisDB:           getEnv("REDIS_DB", "0"),
-		ShippingMode:      getEnv("US_SHIP_MODE", "TABLE"),
-		ShippingFeePercent: getFloatEnv("SHIPPING_FEE_PERCENT", 3.0),
-		FXUSDJPY:          getFloatEnv("FX_USDJPY", 150.0),
-		UserAgent:         getEnv("USER_AGENT", "PriceCompareBot/1.0"),
-		RateLimitRPS:      getIntEnv("RATE_LIMIT_REQUESTS_PER_SECOND", 10),
-		RateLimitBurst:    getIntEnv("RATE_LIMIT_BURST", 20),
+	return load(nil)
+}
+
+// load reads the configuration from the environment, with overrides taking precedence
+func load(overrides map[string]string) *Config {
+	l := &envLoader{overrides: overrides}
+	cfg := &Config{
+		AppEnv:                          l.getEnv("APP_ENV", "development"),
+		LogLevel:                        l.getEnv("LOG_LEVEL", "info"),
+		APIPort:                         l.getEnv("API_PORT", "8080"),
+		APIHost:                         l.getEnv("API_HOST", "0.0.0.0"),
+		RepositoryBackend:               l.getEnv("REPOSITORY_BACKEND", "postgres"),
+		PostgresHost:                    l.getEnv("POSTGRES_HOST", "localhost"),
+		PostgresPort:                    l.getEnv("POSTGRES_PORT", "5432"),
+		PostgresUser:                    l.getEnv("POSTGRES_USER", "pricecompare"),
+		PostgresPassword:                l.getEnv("POSTGRES_PASSWORD", "password"),
+		PostgresDB:                      l.getEnv("POSTGRES_DB", "pricecompare"),
+		PostgresSSLMode:                 l.getEnv("POSTGRES_SSLMODE", "disable"),
+		RedisHost:                       l.getEnv("REDIS_HOST", "localhost"),
+		RedisPort:                       l.getEnv("REDIS_PORT", "6379"),
+		RedisPassword:                   l.getEnv("REDIS_PASSWORD", ""),
+		RedisDB:                         l.getEnv("REDIS_DB", "0"),
+		QueueMode:                       l.getEnv("QUEUE_MODE", "asynq"),
+		QueueConcurrency:                l.getIntEnv("QUEUE_CONCURRENCY", 10),
+		InlineQueueSize:                 l.getIntEnv("INLINE_QUEUE_SIZE", 100),
+		QueueStuckAfterSeconds:          l.getIntEnv("QUEUE_STUCK_AFTER_SECONDS", 600),
+		ShippingMode:                    l.getEnv("US_SHIP_MODE", "TABLE"),
+		ShippingFeePercent:              l.getFloatEnv("SHIPPING_FEE_PERCENT", 3.0),
+		FXUSDJPY:                        l.getFloatEnv("FX_USDJPY", 150.0),
+		FXMarkupPercent:                 l.getFloatEnv("FX_MARKUP_PERCENT", 0.0),
+		FXProviders:                     l.getListEnv("FX_PROVIDERS", []string{"static"}),
+		FXAPIURL:                        l.getEnv("FX_API_URL", "https://open.er-api.com/v6/latest/USD"),
+		FXECBURL:                        l.getEnv("FX_ECB_URL", "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"),
+		FXExchangeRateHostURL:           l.getEnv("FX_EXCHANGERATE_HOST_URL", "https://api.exchangerate.host/live"),
+		FXExchangeRateHostAccessKey:     l.getEnv("FX_EXCHANGERATE_HOST_ACCESS_KEY", ""),
+		FXRefreshMinutes:                l.getIntEnv("FX_REFRESH_INTERVAL_MINUTES", 60),
+		FXMaxAgeHours:                   l.getIntEnv("FX_MAX_AGE_HOURS", 24),
+		DutyDefaultPercent:              l.getFloatEnv("DUTY_DEFAULT_PERCENT", 5.0),
+		DutyDeMinimisUSD:                l.getFloatEnv("DUTY_DE_MINIMIS_USD", 800.0),
+		FreeShippingThresholds:          l.getFloatMapEnv("FREE_SHIPPING_THRESHOLDS", map[string]float64{"walmart": 35.0}),
+		OfferFreshnessSLAHours:          l.getFloatMapEnv("OFFER_FRESHNESS_SLA_HOURS", map[string]float64{"walmart": 6, "amazon": 6, "*": 24}),
+		FeeRulesFile:                    l.getEnv("FEE_RULES_FILE", ""),
+		ShippingTablesFile:              l.getEnv("SHIPPING_TABLES_FILE", ""),
+		TitleMatchThreshold:             l.getFloatEnv("TITLE_MATCH_THRESHOLD", 0.6),
+		MatchScoreThreshold:             l.getFloatEnv("MATCH_SCORE_THRESHOLD", 0.75),
+		OfferAnomalyDropPercent:         l.getFloatEnv("OFFER_ANOMALY_DROP_PERCENT", 95),
+		IngestMinTitleLength:            l.getIntEnv("INGEST_MIN_TITLE_LENGTH", 3),
+		IngestBannedKeywords:            l.getListEnv("INGEST_BANNED_KEYWORDS", []string{"sponsored", "advertisement", "sign in", "log in", "view all", "shop all", "see all"}),
+		IngestRequirePriceSources:       l.getListEnv("INGEST_REQUIRE_PRICE_SOURCES", []string{"live"}),
+		IngestMaxListingsPerSource:      l.getIntMapEnv("INGEST_MAX_LISTINGS_PER_SOURCE"),
+		IngestDailyListingQuota:         l.getIntMapEnv("INGEST_DAILY_LISTING_QUOTA"),
+		FetchMaxCandidatesPerQuery:      l.getIntEnv("FETCH_MAX_CANDIDATES_PER_QUERY", 5),
+		FetchMaxOffersPerProduct:        l.getIntEnv("FETCH_MAX_OFFERS_PER_PRODUCT", 20),
+		FetchMaxRequests:                l.getIntEnv("FETCH_MAX_REQUESTS_PER_RUN", 200),
+		ProviderLocales:                 l.getStringMapEnv("PROVIDER_LOCALES", map[string]string{"amazon": "en-US"}),
+		ProviderTimeoutSeconds:          l.getFloatMapEnv("PROVIDER_TIMEOUT_SECONDS", map[string]float64{"*": 60}),
+		ProviderCircuitFailures:         l.getIntEnv("PROVIDER_CIRCUIT_FAILURES", 5),
+		ProviderCircuitCooldownSeconds:  l.getIntEnv("PROVIDER_CIRCUIT_COOLDOWN_SECONDS", 300),
+		LiveProviderMode:                l.getEnv("LIVE_PROVIDER_MODE", "search"),
+		LiveSitemapURLPatterns:          l.getListEnv("LIVE_SITEMAP_URL_PATTERNS", []string{`/products?/`, `/dp/`, `/ip/`}),
+		LiveSitemapMaxPages:             l.getIntEnv("LIVE_SITEMAP_MAX_PAGES", 10),
+		LiveRenderMode:                  l.getEnv("LIVE_RENDER_MODE", ""),
+		LiveRenderWaitSelector:          l.getEnv("LIVE_RENDER_WAIT_SELECTOR", ""),
+		RenderBrowserlessURL:            l.getEnv("RENDER_BROWSERLESS_URL", ""),
+		RenderBrowserlessToken:          l.getEnv("RENDER_BROWSERLESS_TOKEN", ""),
+		RenderTimeoutSeconds:            l.getIntEnv("RENDER_TIMEOUT_SECONDS", 30),
+		ProviderFixtureMode:             l.getEnv("PROVIDER_FIXTURE_MODE", ""),
+		ProviderFixtureDir:              l.getEnv("PROVIDER_FIXTURE_DIR", "testdata/fixtures"),
+		DuplicateScanCron:               l.getEnv("DUPLICATE_SCAN_CRON", "0 3 * * *"),
+		CatalogReportCron:               l.getEnv("CATALOG_REPORT_CRON", "0 7 * * *"),
+		MaintenanceCron:                 l.getEnv("MAINTENANCE_CRON", "0 4 * * *"),
+		FetchCrons:                      l.getPrefixedEnv("FETCH_CRON_"),
+		CatalogReportPriceChangePercent: l.getFloatEnv("CATALOG_REPORT_PRICE_CHANGE_PERCENT", 10),
+		CatalogReportStaleHours:         l.getIntEnv("CATALOG_REPORT_STALE_HOURS", 48),
+		EmbeddingBackend:                l.getEnv("EMBEDDING_BACKEND", ""),
+		EmbeddingModel:                  l.getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingLocalURL:               l.getEnv("EMBEDDING_LOCAL_URL", "http://localhost:8081"),
+		EmbeddingMatchThreshold:         l.getFloatEnv("EMBEDDING_MATCH_THRESHOLD", 0.9),
+		OpenAIAPIKey:                    l.getEnv("OPENAI_API_KEY", ""),
+		TranslationBackend:              l.getEnv("TRANSLATION_BACKEND", ""),
+		TranslationModel:                l.getEnv("TRANSLATION_MODEL", "gpt-4o-mini"),
+		DeepLAPIKey:                     l.getEnv("DEEPL_API_KEY", ""),
+		DeepLAPIURL:                     l.getEnv("DEEPL_API_URL", "https://api-free.deepl.com"),
+		ImageHashEnabled:                l.getBoolEnv("IMAGE_HASH_ENABLED", false),
+		ImageMatchMaxDistance:           l.getIntEnv("IMAGE_MATCH_MAX_DISTANCE", 6),
+		OTelEndpoint:                    l.getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTelServiceName:                 l.getEnv("OTEL_SERVICE_NAME", "pricecompare-api"),
+		OTelSampleRatio:                 l.getFloatEnv("OTEL_TRACES_SAMPLE_RATIO", 1.0),
+		DebugAddr:                       l.getEnv("DEBUG_ADDR", ""),
+		DebugToken:                      l.getEnv("DEBUG_TOKEN", ""),
+		APIAuthEnabled:                  l.getBoolEnv("API_AUTH_ENABLED", true),
+		AdminAPIKey:                     l.getEnv("ADMIN_API_KEY", ""),
+		APIKeyRateLimitPerMinute:        l.getIntEnv("API_KEY_RATE_LIMIT_PER_MINUTE", 120),
+		PublicAPIKeyRateLimitPerMinute:  l.getIntEnv("PUBLIC_API_KEY_RATE_LIMIT_PER_MINUTE", 600),
+		PublicAPIKeyRequired:            l.getBoolEnv("PUBLIC_API_KEY_REQUIRED", false),
+		AnonymousRateLimitPerMinute:     l.getIntEnv("ANONYMOUS_RATE_LIMIT_PER_MINUTE", 60),
+		ProxyHeader:                     l.getEnv("PROXY_HEADER", ""),
+		ResponseCacheSearchTTLSeconds:   l.getIntEnv("RESPONSE_CACHE_SEARCH_TTL_SECONDS", 0),
+		ResponseCacheOffersTTLSeconds:   l.getIntEnv("RESPONSE_CACHE_OFFERS_TTL_SECONDS", 0),
+		FeedCacheTTLSeconds:             l.getIntEnv("FEED_CACHE_TTL_SECONDS", 3600),
+		SiteURL:                         l.getEnv("SITE_URL", ""),
+		PublicAPIURL:                    l.getEnv("PUBLIC_API_URL", ""),
+		AmazonAssociateTag:              l.getEnv("AMAZON_ASSOCIATE_TAG", ""),
+		RequestTimeoutSeconds:           l.getIntEnv("REQUEST_TIMEOUT_SECONDS", 30),
+		RouteRequestTimeoutSeconds: l.getFloatMapEnv("ROUTE_REQUEST_TIMEOUT_SECONDS", map[string]float64{
+			"/sitemap.xml": 300, "/feeds/": 300, "/api/admin/reports/": 120, "/api/admin/selftest": 120,
+		}),
+		AuditSink:                       l.getEnv("AUDIT_SINK", "stdout"),
+		AuditBatchSize:                  l.getIntEnv("AUDIT_BATCH_SIZE", 100),
+		AuditFlushSeconds:               l.getIntEnv("AUDIT_FLUSH_INTERVAL_SECONDS", 10),
+		AuditS3Bucket:                   l.getEnv("AUDIT_S3_BUCKET", ""),
+		AuditS3Prefix:                   l.getEnv("AUDIT_S3_PREFIX", "audit"),
+		AuditS3Endpoint:                 l.getEnv("AUDIT_S3_ENDPOINT", ""),
+		AuditHTTPURL:                    l.getEnv("AUDIT_HTTP_URL", ""),
+		AuditHTTPToken:                  l.getEnv("AUDIT_HTTP_TOKEN", ""),
+		AuditRetentionDays:              l.getIntEnv("AUDIT_RETENTION_DAYS", 90),
+		OfferMergeLogRetentionDays:      l.getIntEnv("OFFER_MERGE_LOG_RETENTION_DAYS", 90),
+		SnapshotS3Bucket:                l.getEnv("SNAPSHOT_S3_BUCKET", ""),
+		SnapshotS3Prefix:                l.getEnv("SNAPSHOT_S3_PREFIX", "snapshots"),
+		SnapshotS3Endpoint:              l.getEnv("SNAPSHOT_S3_ENDPOINT", ""),
+		SearchBackend:                   l.getEnv("SEARCH_BACKEND", ""),
+		SearchURL:                       l.getEnv("SEARCH_URL", "http://localhost:7700"),
+		SearchAPIKey:                    l.getEnv("SEARCH_API_KEY", ""),
+		SearchIndex:                     l.getEnv("SEARCH_INDEX", "products"),
+		SearchReindexCron:               l.getEnv("SEARCH_REINDEX_CRON", "30 3 * * *"),
+		EventBus:                        l.getEnv("EVENT_BUS", ""),
+		EventBusTopic:                   l.getEnv("EVENT_BUS_TOPIC", "pricecompare.events"),
+		EventBusURL:                     l.getEnv("EVENT_BUS_URL", "nats://localhost:4222"),
+		EventBusBrokers:                 l.getListEnv("EVENT_BUS_BROKERS", []string{"localhost:9092"}),
+		EventBusStreamMaxLen:            l.getIntEnv("EVENT_BUS_STREAM_MAXLEN", 100000),
+		NotifySMTPAddr:                  l.getEnv("NOTIFY_SMTP_ADDR", ""),
+		NotifySMTPUsername:              l.getEnv("NOTIFY_SMTP_USERNAME", ""),
+		NotifySMTPPassword:              l.getEnv("NOTIFY_SMTP_PASSWORD", ""),
+		NotifyEmailFrom:                 l.getEnv("NOTIFY_EMAIL_FROM", ""),
+		NotifyEmailTo:                   l.getListEnv("NOTIFY_EMAIL_TO", nil),
+		NotifySlackWebhookURL:           l.getEnv("NOTIFY_SLACK_WEBHOOK_URL", ""),
+		NotifyRoutes:                    l.getListMapEnv("NOTIFY_ROUTES"),
+		NotifyJobFailureCooldownMinutes: l.getIntEnv("NOTIFY_JOB_FAILURE_COOLDOWN_MINUTES", 30),
+		EnableDemoProviders:             l.getBoolEnv("ENABLE_DEMO_PROVIDERS", false),
+		UserAgent:                       l.getEnv("USER_AGENT", "PriceCompareBot/1.0"),
+		RateLimitRPS:                    l.getIntEnv("RATE_LIMIT_REQUESTS_PER_SECOND", 10),
+		RateLimitBurst:                  l.getIntEnv("RATE_LIMIT_BURST", 20),
 	}
+	cfg.loadErrors = l.errs
+	return cfg
 }
 
 func (c *Config) DatabaseURL() string {
@@ -60,48 +306,259 @@ func (c *Config) RedisAddr() string {
 }
 
 func (c *Config) ShippingConfig() ShippingConfig {
+	thresholdsCents := make(map[string]int, len(c.FreeShippingThresholds))
+	for source, usd := range c.FreeShippingThresholds {
+		thresholdsCents[source] = int(usd * 100)
+	}
 	return ShippingConfig{
-		Mode:       c.ShippingMode,
-		FeePercent: c.ShippingFeePercent,
-		FXUSDJPY:   c.FXUSDJPY,
+		Mode:                        c.ShippingMode,
+		FeePercent:                  c.ShippingFeePercent,
+		FXUSDJPY:                    c.FXUSDJPY,
+		FXMarkupPercent:             c.FXMarkupPercent,
+		DutyDefaultPercent:          c.DutyDefaultPercent,
+		DutyDeMinimisCents:          int(c.DutyDeMinimisUSD * 100),
+		FreeShippingThresholdsCents: thresholdsCents,
+	}
+}
+
+// OfferFreshnessSLA returns OFFER_FRESHNESS_SLA_HOURS as durations, keyed by source
+func (c *Config) OfferFreshnessSLA() map[string]time.Duration {
+	sla := make(map[string]time.Duration, len(c.OfferFreshnessSLAHours))
+	for source, hours := range c.OfferFreshnessSLAHours {
+		sla[source] = time.Duration(hours * float64(time.Hour))
+	}
+	return sla
+}
+
+// LiveSitemapPatterns returns LIVE_SITEMAP_URL_PATTERNS compiled. Invalid patterns are
+// reported by Validate and left out here.
+func (c *Config) LiveSitemapPatterns() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(c.LiveSitemapURLPatterns))
+	for _, pattern := range c.LiveSitemapURLPatterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			patterns = append(patterns, re)
+		}
+	}
+	return patterns
+}
+
+// ProviderTimeouts returns PROVIDER_TIMEOUT_SECONDS as durations, keyed by provider
+func (c *Config) ProviderTimeouts() map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(c.ProviderTimeoutSeconds))
+	for provider, seconds := range c.ProviderTimeoutSeconds {
+		timeouts[provider] = time.Duration(seconds * float64(time.Second))
+	}
+	return timeouts
+}
+
+// RouteRequestTimeouts returns ROUTE_REQUEST_TIMEOUT_SECONDS as durations, keyed by path prefix
+func (c *Config) RouteRequestTimeouts() map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(c.RouteRequestTimeoutSeconds))
+	for prefix, seconds := range c.RouteRequestTimeoutSeconds {
+		timeouts[prefix] = time.Duration(seconds * float64(time.Second))
 	}
+	return timeouts
 }
 
 type ShippingConfig struct {
-	Mode       string
-	FeePercent float64
-	FXUSDJPY   float64
+	Mode                        string
+	FeePercent                  float64
+	FXUSDJPY                    float64
+	FXMarkupPercent             float64
+	DutyDefaultPercent          float64
+	DutyDeMinimisCents          int
+	FreeShippingThresholdsCents map[string]int
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
+// envLoader reads typed environment variables. Malformed values fall back to the default
+// and are collected, so Validate reports them instead of silently using the default.
+type envLoader struct {
+	overrides map[string]string // e.g. a re-read .env file; takes precedence over the environment
+	errs      []error
+}
+
+// lookup returns the value of the variable key
+func (l *envLoader) lookup(key string) string {
+	if value, ok := l.overrides[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+func (l *envLoader) invalid(key, value, expected string) {
+	l.errs = append(l.errs, fmt.Errorf("%s=%q is not %s", key, value, expected))
+}
+
+func (l *envLoader) getEnv(key, defaultValue string) string {
+	value := l.lookup(key)
 	if value == "" {
 		return defaultValue
 	}
 	return value
 }
 
-func getIntEnv(key string, defaultValue int) int {
-	value := os.Getenv(key)
+func (l *envLoader) getIntEnv(key string, defaultValue int) int {
+	value := l.lookup(key)
 	if value == "" {
 		return defaultValue
 	}
 	intValue, err := strconv.Atoi(value)
 	if err != nil {
+		l.invalid(key, value, "an integer")
 		return defaultValue
 	}
 	return intValue
 }
 
-func getFloatEnv(key string, defaultValue float64) float64 {
-	value := os.Getenv(key)
+func (l *envLoader) getFloatEnv(key string, defaultValue float64) float64 {
+	value := l.lookup(key)
 	if value == "" {
 		return defaultValue
 	}
 	floatValue, err := strconv.ParseFloat(value, 64)
 	if err != nil {
+		l.invalid(key, value, "a number")
 		return defaultValue
 	}
 	return floatValue
 }
 
+func (l *envLoader) getBoolEnv(key string, defaultValue bool) bool {
+	value := l.lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		l.invalid(key, value, "a boolean")
+		return defaultValue
+	}
+	return boolValue
+}
+
+// getListEnv parses a comma-separated list (e.g. "http,static")
+func (l *envLoader) getListEnv(key string, defaultValue []string) []string {
+	value := l.lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	result := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getFloatMapEnv parses "key:value,key:value" pairs (e.g. "walmart:35,amazon:25")
+func (l *envLoader) getFloatMapEnv(key string, defaultValue map[string]float64) map[string]float64 {
+	value := l.lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	result := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			l.invalid(key, pair, "a key:value pair")
+			continue
+		}
+		floatValue, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			l.invalid(key, pair, "a key:number pair")
+			continue
+		}
+		result[strings.TrimSpace(parts[0])] = floatValue
+	}
+	return result
+}
+
+// getIntMapEnv parses "key:value,key:value" pairs (e.g. "live:5000,walmart:20000")
+func (l *envLoader) getIntMapEnv(key string) map[string]int {
+	value := l.lookup(key)
+	if value == "" {
+		return nil
+	}
+	result := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			l.invalid(key, pair, "a key:value pair")
+			continue
+		}
+		intValue, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			l.invalid(key, pair, "a key:integer pair")
+			continue
+		}
+		result[strings.TrimSpace(parts[0])] = intValue
+	}
+	return result
+}
+
+// getStringMapEnv parses "key:value,key:value" pairs (e.g. "live:ja-JP,amazon:en-US")
+func (l *envLoader) getStringMapEnv(key string, defaultValue map[string]string) map[string]string {
+	value := l.lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			l.invalid(key, pair, "a key:value pair")
+			continue
+		}
+		result[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return result
+}
+
+// getPrefixedEnv collects the non-empty variables named prefix+NAME, keyed by the
+// lowercased NAME (e.g. FETCH_CRON_WALMART -> "walmart")
+func (l *envLoader) getPrefixedEnv(prefix string) map[string]string {
+	result := make(map[string]string)
+	keys := make(map[string]bool, len(l.overrides))
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		keys[key] = true
+	}
+	for key := range l.overrides {
+		keys[key] = true
+	}
+	for key := range keys {
+		value := l.lookup(key)
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" || strings.TrimSpace(value) == "" {
+			continue
+		}
+		result[strings.ToLower(name)] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// getListMapEnv parses "key:a|b,key:c" pairs (e.g. "job_failure:slack|email,*:email").
+// A key with nothing after the colon maps to an empty list.
+func (l *envLoader) getListMapEnv(key string) map[string][]string {
+	value := l.lookup(key)
+	if value == "" {
+		return nil
+	}
+	result := make(map[string][]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			l.invalid(key, pair, "a key:value|value pair")
+			continue
+		}
+		items := []string{}
+		for _, item := range strings.Split(parts[1], "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		result[strings.TrimSpace(parts[0])] = items
+	}
+	return result
+}
@@ -0,0 +1,336 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/pricecompare/api/internal/locale"
+)
+
+// Validate checks the loaded configuration and returns every problem found, joined with
+// errors.Join, so a misconfigured deployment fails at startup with one complete report
+// rather than running with silent defaults.
+func (c *Config) Validate() error {
+	var v validator
+	v.errs = append(v.errs, c.loadErrors...)
+
+	v.check(c.AppEnv == "development" || c.AppEnv == "production", `APP_ENV must be "development" or "production"`)
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		v.errorf(`LOG_LEVEL must be "debug", "info", "warn" or "error", got %q`, c.LogLevel)
+	}
+	v.port("API_PORT", c.APIPort)
+	switch c.RepositoryBackend {
+	case "postgres":
+	case "memory":
+		v.check(c.AuditSink != "postgres", "AUDIT_SINK=postgres requires REPOSITORY_BACKEND=postgres")
+	default:
+		v.errorf(`REPOSITORY_BACKEND must be "postgres" or "memory", got %q`, c.RepositoryBackend)
+	}
+	v.port("POSTGRES_PORT", c.PostgresPort)
+	v.port("REDIS_PORT", c.RedisPort)
+	if _, err := strconv.Atoi(c.RedisDB); err != nil {
+		v.errorf("REDIS_DB=%q is not an integer", c.RedisDB)
+	}
+	switch c.QueueMode {
+	case "asynq":
+	case "inline":
+		v.check(c.InlineQueueSize > 0, "INLINE_QUEUE_SIZE must be greater than 0")
+	default:
+		v.errorf(`QUEUE_MODE must be "asynq" or "inline", got %q`, c.QueueMode)
+	}
+	v.check(c.QueueConcurrency > 0, "QUEUE_CONCURRENCY must be greater than 0")
+	v.check(c.QueueStuckAfterSeconds > 0, "QUEUE_STUCK_AFTER_SECONDS must be greater than 0")
+
+	// Pricing
+	v.check(c.ShippingMode == "TABLE" || c.ShippingMode == "FLAT", `US_SHIP_MODE must be "TABLE" or "FLAT"`)
+	v.percent("SHIPPING_FEE_PERCENT", c.ShippingFeePercent)
+	v.percent("FX_MARKUP_PERCENT", c.FXMarkupPercent)
+	v.percent("DUTY_DEFAULT_PERCENT", c.DutyDefaultPercent)
+	v.check(c.FXUSDJPY > 0, "FX_USDJPY must be greater than 0")
+	v.check(c.DutyDeMinimisUSD >= 0, "DUTY_DE_MINIMIS_USD must not be negative")
+	for source, usd := range c.FreeShippingThresholds {
+		v.check(usd >= 0, fmt.Sprintf("FREE_SHIPPING_THRESHOLDS for %q must not be negative", source))
+	}
+	for source, hours := range c.OfferFreshnessSLAHours {
+		v.check(hours > 0, fmt.Sprintf("OFFER_FRESHNESS_SLA_HOURS for %q must be greater than 0", source))
+	}
+	v.check(len(c.FXProviders) > 0, "FX_PROVIDERS must list at least one provider")
+	for _, name := range c.FXProviders {
+		switch name {
+		case "static":
+		case "http":
+			v.url("FX_API_URL", c.FXAPIURL)
+		case "ecb":
+			v.url("FX_ECB_URL", c.FXECBURL)
+		case "exchangerate_host":
+			v.url("FX_EXCHANGERATE_HOST_URL", c.FXExchangeRateHostURL)
+			v.check(c.FXExchangeRateHostAccessKey != "", "FX_PROVIDERS=exchangerate_host requires FX_EXCHANGERATE_HOST_ACCESS_KEY")
+		default:
+			v.errorf("FX_PROVIDERS: unknown provider %q", name)
+		}
+	}
+	v.check(c.FXRefreshMinutes >= 0, "FX_REFRESH_INTERVAL_MINUTES must not be negative")
+	v.check(c.FXMaxAgeHours > 0, "FX_MAX_AGE_HOURS must be greater than 0")
+	v.file("FEE_RULES_FILE", c.FeeRulesFile)
+	v.file("SHIPPING_TABLES_FILE", c.ShippingTablesFile)
+	v.percent("OFFER_ANOMALY_DROP_PERCENT", c.OfferAnomalyDropPercent)
+	v.check(c.IngestMinTitleLength >= 0, "INGEST_MIN_TITLE_LENGTH must not be negative")
+	for source, limit := range c.IngestMaxListingsPerSource {
+		v.check(limit >= 0, fmt.Sprintf("INGEST_MAX_LISTINGS_PER_SOURCE for %q must not be negative", source))
+	}
+	for source, limit := range c.IngestDailyListingQuota {
+		v.check(limit >= 0, fmt.Sprintf("INGEST_DAILY_LISTING_QUOTA for %q must not be negative", source))
+	}
+	v.check(c.FetchMaxCandidatesPerQuery >= 0, "FETCH_MAX_CANDIDATES_PER_QUERY must not be negative")
+	v.check(c.FetchMaxOffersPerProduct >= 0, "FETCH_MAX_OFFERS_PER_PRODUCT must not be negative")
+	v.check(c.FetchMaxRequests >= 0, "FETCH_MAX_REQUESTS_PER_RUN must not be negative")
+	for source, spec := range c.FetchCrons {
+		v.cron("FETCH_CRON_"+strings.ToUpper(source), spec)
+	}
+	v.cron("DUPLICATE_SCAN_CRON", c.DuplicateScanCron)
+	v.cron("SEARCH_REINDEX_CRON", c.SearchReindexCron)
+	v.cron("CATALOG_REPORT_CRON", c.CatalogReportCron)
+	v.cron("MAINTENANCE_CRON", c.MaintenanceCron)
+	for provider, value := range c.ProviderLocales {
+		if provider != "live" && provider != "amazon" {
+			v.errorf("PROVIDER_LOCALES: %q does not support locales, only live and amazon do", provider)
+		} else if _, err := locale.Parse(value); err != nil {
+			v.errorf("PROVIDER_LOCALES: locale %q of %q is not a BCP 47 language tag", value, provider)
+		}
+	}
+	for provider, seconds := range c.ProviderTimeoutSeconds {
+		v.check(seconds >= 0, fmt.Sprintf("PROVIDER_TIMEOUT_SECONDS for %q must not be negative", provider))
+	}
+	v.check(c.ProviderCircuitFailures >= 0, "PROVIDER_CIRCUIT_FAILURES must not be negative")
+	if c.ProviderCircuitFailures > 0 {
+		v.check(c.ProviderCircuitCooldownSeconds > 0, "PROVIDER_CIRCUIT_COOLDOWN_SECONDS must be greater than 0")
+	}
+	switch c.LiveProviderMode {
+	case "search":
+	case "sitemap":
+		v.check(len(c.LiveSitemapURLPatterns) > 0, "LIVE_PROVIDER_MODE=sitemap requires LIVE_SITEMAP_URL_PATTERNS")
+		for _, pattern := range c.LiveSitemapURLPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				v.errorf("LIVE_SITEMAP_URL_PATTERNS: %q is not a regular expression: %v", pattern, err)
+			}
+		}
+		v.check(c.LiveSitemapMaxPages >= 0, "LIVE_SITEMAP_MAX_PAGES must not be negative")
+	default:
+		v.errorf(`LIVE_PROVIDER_MODE must be "search" or "sitemap", got %q`, c.LiveProviderMode)
+	}
+	switch c.LiveRenderMode {
+	case "":
+	case "browserless":
+		v.url("RENDER_BROWSERLESS_URL", c.RenderBrowserlessURL)
+		v.check(c.RenderTimeoutSeconds >= 1 && c.RenderTimeoutSeconds <= 120, "RENDER_TIMEOUT_SECONDS must be between 1 and 120")
+	default:
+		v.errorf(`LIVE_RENDER_MODE must be "browserless" or empty, got %q`, c.LiveRenderMode)
+	}
+	switch c.ProviderFixtureMode {
+	case "":
+	case "record", "replay":
+		v.check(c.ProviderFixtureDir != "", "PROVIDER_FIXTURE_MODE requires PROVIDER_FIXTURE_DIR")
+	default:
+		v.errorf(`PROVIDER_FIXTURE_MODE must be "record" or "replay", got %q`, c.ProviderFixtureMode)
+	}
+
+	// Matching
+	v.ratio("TITLE_MATCH_THRESHOLD", c.TitleMatchThreshold)
+	v.ratio("MATCH_SCORE_THRESHOLD", c.MatchScoreThreshold)
+	switch c.EmbeddingBackend {
+	case "":
+	case "openai":
+		v.check(c.OpenAIAPIKey != "", "EMBEDDING_BACKEND=openai requires OPENAI_API_KEY")
+		v.ratio("EMBEDDING_MATCH_THRESHOLD", c.EmbeddingMatchThreshold)
+	case "tei", "local": // "local" is the former name of "tei"
+		v.url("EMBEDDING_LOCAL_URL", c.EmbeddingLocalURL)
+		v.ratio("EMBEDDING_MATCH_THRESHOLD", c.EmbeddingMatchThreshold)
+	default:
+		v.errorf(`EMBEDDING_BACKEND must be empty, "openai" or "tei", got %q`, c.EmbeddingBackend)
+	}
+	switch c.TranslationBackend {
+	case "":
+	case "deepl":
+		v.check(c.DeepLAPIKey != "", "TRANSLATION_BACKEND=deepl requires DEEPL_API_KEY")
+		v.url("DEEPL_API_URL", c.DeepLAPIURL)
+	case "openai":
+		v.check(c.OpenAIAPIKey != "", "TRANSLATION_BACKEND=openai requires OPENAI_API_KEY")
+		v.check(c.TranslationModel != "", "TRANSLATION_MODEL must not be empty")
+	default:
+		v.errorf(`TRANSLATION_BACKEND must be empty, "deepl" or "openai", got %q`, c.TranslationBackend)
+	}
+	switch c.SearchBackend {
+	case "":
+	case "meilisearch", "elasticsearch":
+		v.url("SEARCH_URL", c.SearchURL)
+		v.check(c.SearchIndex != "", "SEARCH_INDEX must not be empty")
+	default:
+		v.errorf(`SEARCH_BACKEND must be empty, "meilisearch" or "elasticsearch", got %q`, c.SearchBackend)
+	}
+	if c.ImageHashEnabled {
+		v.check(c.ImageMatchMaxDistance >= 0 && c.ImageMatchMaxDistance <= 64, "IMAGE_MATCH_MAX_DISTANCE must be between 0 and 64")
+	}
+
+	// Observability
+	if c.OTelEndpoint != "" {
+		v.check(c.OTelSampleRatio >= 0 && c.OTelSampleRatio <= 1, "OTEL_TRACES_SAMPLE_RATIO must be between 0 and 1")
+	}
+	switch c.AuditSink {
+	case "stdout", "postgres":
+	case "s3":
+		v.check(c.AuditS3Bucket != "", "AUDIT_SINK=s3 requires AUDIT_S3_BUCKET")
+		if c.AuditS3Endpoint != "" {
+			v.url("AUDIT_S3_ENDPOINT", c.AuditS3Endpoint)
+		}
+	case "http":
+		v.url("AUDIT_HTTP_URL", c.AuditHTTPURL)
+	default:
+		v.errorf(`AUDIT_SINK must be "stdout", "postgres", "s3" or "http", got %q`, c.AuditSink)
+	}
+	if c.AuditSink != "stdout" {
+		v.check(c.AuditBatchSize > 0, "AUDIT_BATCH_SIZE must be greater than 0")
+		v.check(c.AuditFlushSeconds > 0, "AUDIT_FLUSH_INTERVAL_SECONDS must be greater than 0")
+	}
+	v.check(c.AuditRetentionDays >= 0, "AUDIT_RETENTION_DAYS must not be negative")
+	v.check(c.OfferMergeLogRetentionDays >= 0, "OFFER_MERGE_LOG_RETENTION_DAYS must not be negative")
+	if c.SnapshotS3Bucket != "" && c.SnapshotS3Endpoint != "" {
+		v.url("SNAPSHOT_S3_ENDPOINT", c.SnapshotS3Endpoint)
+	}
+	switch c.EventBus {
+	case "":
+	case "redis":
+		v.check(c.EventBusTopic != "", "EVENT_BUS_TOPIC must not be empty")
+		v.check(c.EventBusStreamMaxLen > 0, "EVENT_BUS_STREAM_MAXLEN must be greater than 0")
+	case "nats":
+		v.check(c.EventBusTopic != "", "EVENT_BUS_TOPIC must not be empty")
+		v.check(strings.HasPrefix(c.EventBusURL, "nats://") || strings.HasPrefix(c.EventBusURL, "tls://"), fmt.Sprintf("EVENT_BUS_URL=%q is not a nats:// or tls:// URL", c.EventBusURL))
+	case "kafka":
+		v.check(c.EventBusTopic != "", "EVENT_BUS_TOPIC must not be empty")
+		v.check(len(c.EventBusBrokers) > 0, "EVENT_BUS=kafka requires EVENT_BUS_BROKERS")
+	default:
+		v.errorf(`EVENT_BUS must be empty, "redis", "nats" or "kafka", got %q`, c.EventBus)
+	}
+	if c.NotifySMTPAddr != "" {
+		if _, port, err := net.SplitHostPort(c.NotifySMTPAddr); err != nil {
+			v.errorf("NOTIFY_SMTP_ADDR=%q is not host:port", c.NotifySMTPAddr)
+		} else {
+			v.port("NOTIFY_SMTP_ADDR", port)
+		}
+		v.check(strings.Contains(c.NotifyEmailFrom, "@"), "NOTIFY_SMTP_ADDR requires NOTIFY_EMAIL_FROM")
+		v.check(len(c.NotifyEmailTo) > 0, "NOTIFY_SMTP_ADDR requires NOTIFY_EMAIL_TO")
+	}
+	if c.NotifySlackWebhookURL != "" {
+		v.url("NOTIFY_SLACK_WEBHOOK_URL", c.NotifySlackWebhookURL)
+	}
+	for kind, channels := range c.NotifyRoutes {
+		for _, channel := range channels {
+			switch channel {
+			case "email":
+				v.check(c.NotifySMTPAddr != "", fmt.Sprintf("NOTIFY_ROUTES routes %q to email, which requires NOTIFY_SMTP_ADDR", kind))
+			case "slack":
+				v.check(c.NotifySlackWebhookURL != "", fmt.Sprintf("NOTIFY_ROUTES routes %q to slack, which requires NOTIFY_SLACK_WEBHOOK_URL", kind))
+			default:
+				v.errorf(`NOTIFY_ROUTES: unknown channel %q for %q (want "email" or "slack")`, channel, kind)
+			}
+		}
+	}
+	v.check(c.NotifyJobFailureCooldownMinutes >= 0, "NOTIFY_JOB_FAILURE_COOLDOWN_MINUTES must not be negative")
+	v.check(c.CatalogReportPriceChangePercent > 0, "CATALOG_REPORT_PRICE_CHANGE_PERCENT must be greater than 0")
+	v.check(c.CatalogReportStaleHours > 0, "CATALOG_REPORT_STALE_HOURS must be greater than 0")
+
+	v.check(c.AdminAPIKey == "" || len(c.AdminAPIKey) >= 32, "ADMIN_API_KEY must be at least 32 characters")
+	v.check(c.APIKeyRateLimitPerMinute >= 0, "API_KEY_RATE_LIMIT_PER_MINUTE must not be negative")
+	v.check(c.PublicAPIKeyRateLimitPerMinute >= 0, "PUBLIC_API_KEY_RATE_LIMIT_PER_MINUTE must not be negative")
+	v.check(c.AnonymousRateLimitPerMinute >= 0, "ANONYMOUS_RATE_LIMIT_PER_MINUTE must not be negative")
+	v.check(!c.PublicAPIKeyRequired || c.APIAuthEnabled, "PUBLIC_API_KEY_REQUIRED=true requires API_AUTH_ENABLED=true")
+	v.check(c.ResponseCacheSearchTTLSeconds >= 0 && c.ResponseCacheSearchTTLSeconds <= 3600, "RESPONSE_CACHE_SEARCH_TTL_SECONDS must be between 0 and 3600")
+	v.check(c.ResponseCacheOffersTTLSeconds >= 0 && c.ResponseCacheOffersTTLSeconds <= 3600, "RESPONSE_CACHE_OFFERS_TTL_SECONDS must be between 0 and 3600")
+	v.check(c.FeedCacheTTLSeconds >= 0 && c.FeedCacheTTLSeconds <= 86400, "FEED_CACHE_TTL_SECONDS must be between 0 and 86400")
+	if c.SiteURL != "" {
+		v.url("SITE_URL", c.SiteURL)
+	}
+	if c.PublicAPIURL != "" {
+		v.url("PUBLIC_API_URL", c.PublicAPIURL)
+	}
+	v.check(c.RequestTimeoutSeconds >= 0, "REQUEST_TIMEOUT_SECONDS must not be negative")
+	for prefix, seconds := range c.RouteRequestTimeoutSeconds {
+		v.check(strings.HasPrefix(prefix, "/"), fmt.Sprintf("ROUTE_REQUEST_TIMEOUT_SECONDS: %q is not a path prefix", prefix))
+		v.check(seconds >= 0, fmt.Sprintf("ROUTE_REQUEST_TIMEOUT_SECONDS for %q must not be negative", prefix))
+	}
+
+	v.check(c.UserAgent != "", "USER_AGENT must not be empty")
+	v.check(c.RateLimitRPS > 0, "RATE_LIMIT_REQUESTS_PER_SECOND must be greater than 0")
+	v.check(c.RateLimitBurst > 0, "RATE_LIMIT_BURST must be greater than 0")
+
+	// Development-only settings
+	if c.AppEnv == "production" {
+		v.check(!c.EnableDemoProviders, "ENABLE_DEMO_PROVIDERS=true is not allowed when APP_ENV=production")
+		v.check(c.ProviderFixtureMode == "", "PROVIDER_FIXTURE_MODE is not allowed when APP_ENV=production")
+		v.check(c.RepositoryBackend != "memory", "REPOSITORY_BACKEND=memory is not allowed when APP_ENV=production")
+		v.check(c.APIAuthEnabled, "API_AUTH_ENABLED=false is not allowed when APP_ENV=production")
+		v.check(c.PostgresPassword != "password", "POSTGRES_PASSWORD must be changed from the default when APP_ENV=production")
+	}
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) errorf(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) check(ok bool, message string) {
+	if !ok {
+		v.errs = append(v.errs, errors.New(message))
+	}
+}
+
+func (v *validator) port(key, value string) {
+	port, err := strconv.Atoi(value)
+	v.check(err == nil && port > 0 && port <= 65535, fmt.Sprintf("%s=%q is not a valid port", key, value))
+}
+
+func (v *validator) percent(key string, value float64) {
+	v.check(value >= 0 && value <= 100, key+" must be between 0 and 100")
+}
+
+func (v *validator) ratio(key string, value float64) {
+	v.check(value > 0 && value <= 1, key+" must be greater than 0 and at most 1")
+}
+
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", fmt.Sprintf("%s=%q is not an http(s) URL", key, value))
+}
+
+// cron checks an optional cron spec as the schedulers parse it
+func (v *validator) cron(key, spec string) {
+	if spec == "" {
+		return
+	}
+	if _, err := cron.ParseStandard(spec); err != nil {
+		v.errorf("%s=%q is not a cron spec: %v", key, spec, err)
+	}
+}
+
+func (v *validator) file(key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.errorf("%s: %v", key, err)
+	}
+}
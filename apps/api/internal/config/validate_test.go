@@ -0,0 +1,176 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string // substrings of expected problems; empty means valid
+	}{
+		{name: "defaults are valid"},
+		{
+			name: "malformed numbers are reported instead of defaulted",
+			env:  map[string]string{"FX_USDJPY": "abc", "AUDIT_BATCH_SIZE": "10x"},
+			want: []string{`FX_USDJPY="abc" is not a number`, `AUDIT_BATCH_SIZE="10x" is not an integer`},
+		},
+		{
+			name: "out of range values",
+			env:  map[string]string{"TITLE_MATCH_THRESHOLD": "1.5", "MATCH_SCORE_THRESHOLD": "-0.1", "SHIPPING_FEE_PERCENT": "-1", "API_PORT": "70000", "OFFER_ANOMALY_DROP_PERCENT": "120", "OFFER_FRESHNESS_SLA_HOURS": "amazon:1,*:0", "INGEST_MIN_TITLE_LENGTH": "-1", "FETCH_MAX_REQUESTS_PER_RUN": "-5", "PROVIDER_LOCALES": "live:ja-JP,amazon:english please", "FETCH_CRON_WALMART": "every 6 hours", "PROVIDER_TIMEOUT_SECONDS": "live:-1,*:30", "PROVIDER_CIRCUIT_COOLDOWN_SECONDS": "0"},
+			want: []string{"PROVIDER_CIRCUIT_COOLDOWN_SECONDS", `FETCH_CRON_WALMART="every 6 hours"`, `PROVIDER_TIMEOUT_SECONDS for "live"`, "INGEST_MIN_TITLE_LENGTH", "FETCH_MAX_REQUESTS_PER_RUN", `PROVIDER_LOCALES: locale "english please" of "amazon"`, "TITLE_MATCH_THRESHOLD", "MATCH_SCORE_THRESHOLD", "SHIPPING_FEE_PERCENT", "API_PORT", "OFFER_ANOMALY_DROP_PERCENT", `OFFER_FRESHNESS_SLA_HOURS for "*"`},
+		},
+		{
+			name: "enabled features require their keys",
+			env:  map[string]string{"EMBEDDING_BACKEND": "openai", "AUDIT_SINK": "s3", "FX_PROVIDERS": "http,ecb,exchangerate_host,oanda", "SNAPSHOT_S3_BUCKET": "pages", "SNAPSHOT_S3_ENDPOINT": "minio:9000"},
+			want: []string{"OPENAI_API_KEY", "AUDIT_S3_BUCKET", "FX_EXCHANGERATE_HOST_ACCESS_KEY", `unknown provider "oanda"`, "SNAPSHOT_S3_ENDPOINT"},
+		},
+		{
+			name: "demo providers and default password in production",
+			env:  map[string]string{"APP_ENV": "production", "ENABLE_DEMO_PROVIDERS": "true", "PROVIDER_FIXTURE_MODE": "replay"},
+			want: []string{"ENABLE_DEMO_PROVIDERS", "PROVIDER_FIXTURE_MODE is not allowed", "POSTGRES_PASSWORD"},
+		},
+		{
+			name: "production with a real password",
+			env:  map[string]string{"APP_ENV": "production", "POSTGRES_PASSWORD": "s3cret"},
+		},
+		{
+			name: "periodic job schedules",
+			env:  map[string]string{"MAINTENANCE_CRON": "0 4 * *", "DUPLICATE_SCAN_CRON": ""},
+			want: []string{`MAINTENANCE_CRON="0 4 * *" is not a cron spec`},
+		},
+		{
+			name: "provider locales",
+			env:  map[string]string{"PROVIDER_LOCALES": "walmart:en-US,amazon:ja-JP"},
+			want: []string{`PROVIDER_LOCALES: "walmart" does not support locales`},
+		},
+		{
+			name: "event bus",
+			env:  map[string]string{"EVENT_BUS": "nats", "EVENT_BUS_URL": "http://localhost:4222"},
+			want: []string{"EVENT_BUS_URL"},
+		},
+		{
+			name: "live sitemap mode",
+			env:  map[string]string{"LIVE_PROVIDER_MODE": "sitemap", "LIVE_SITEMAP_URL_PATTERNS": "/product/,/item/(", "LIVE_SITEMAP_MAX_PAGES": "-1"},
+			want: []string{`LIVE_SITEMAP_URL_PATTERNS: "/item/("`, "LIVE_SITEMAP_MAX_PAGES"},
+		},
+		{
+			name: "live rendering",
+			env:  map[string]string{"LIVE_RENDER_MODE": "browserless", "RENDER_TIMEOUT_SECONDS": "0"},
+			want: []string{"RENDER_BROWSERLESS_URL", "RENDER_TIMEOUT_SECONDS"},
+		},
+		{
+			name: "translation",
+			env:  map[string]string{"TRANSLATION_BACKEND": "deepl", "DEEPL_API_URL": "api.deepl.com"},
+			want: []string{"DEEPL_API_KEY", "DEEPL_API_URL"},
+		},
+		{
+			name: "provider fixtures",
+			env:  map[string]string{"PROVIDER_FIXTURE_MODE": "rewind"},
+			want: []string{`PROVIDER_FIXTURE_MODE must be "record" or "replay", got "rewind"`},
+		},
+		{
+			name: "search backend",
+			env:  map[string]string{"SEARCH_BACKEND": "solr"},
+			want: []string{"SEARCH_BACKEND"},
+		},
+		{
+			name: "notification channels",
+			env: map[string]string{
+				"NOTIFY_SMTP_ADDR": "smtp.example.com",
+				"NOTIFY_ROUTES":    "job_failure:slack|sms,*:email",
+			},
+			want: []string{"NOTIFY_SMTP_ADDR=", "NOTIFY_EMAIL_FROM", "NOTIFY_EMAIL_TO", "NOTIFY_SLACK_WEBHOOK_URL", `unknown channel "sms"`},
+		},
+		{
+			name: "memory repositories",
+			env:  map[string]string{"REPOSITORY_BACKEND": "memory", "AUDIT_SINK": "postgres", "APP_ENV": "production", "POSTGRES_PASSWORD": "s3cret"},
+			want: []string{"AUDIT_SINK=postgres", "REPOSITORY_BACKEND=memory is not allowed"},
+		},
+		{
+			name: "unknown repository backend",
+			env:  map[string]string{"REPOSITORY_BACKEND": "sqlite"},
+			want: []string{"REPOSITORY_BACKEND"},
+		},
+		{
+			name: "inline queue",
+			env:  map[string]string{"QUEUE_MODE": "inline", "QUEUE_CONCURRENCY": "0", "INLINE_QUEUE_SIZE": "0", "QUEUE_STUCK_AFTER_SECONDS": "0"},
+			want: []string{"INLINE_QUEUE_SIZE", "QUEUE_CONCURRENCY", "QUEUE_STUCK_AFTER_SECONDS"},
+		},
+		{
+			name: "catalog report",
+			env:  map[string]string{"CATALOG_REPORT_PRICE_CHANGE_PERCENT": "0", "CATALOG_REPORT_STALE_HOURS": "-1"},
+			want: []string{"CATALOG_REPORT_PRICE_CHANGE_PERCENT", "CATALOG_REPORT_STALE_HOURS"},
+		},
+		{
+			name: "audit retention",
+			env:  map[string]string{"AUDIT_RETENTION_DAYS": "-30", "OFFER_MERGE_LOG_RETENTION_DAYS": "-1"},
+			want: []string{"AUDIT_RETENTION_DAYS", "OFFER_MERGE_LOG_RETENTION_DAYS"},
+		},
+		{
+			name: "API keys",
+			env:  map[string]string{"ADMIN_API_KEY": "too-short", "API_KEY_RATE_LIMIT_PER_MINUTE": "-1", "API_AUTH_ENABLED": "false", "APP_ENV": "production", "POSTGRES_PASSWORD": "s3cret"},
+			want: []string{"ADMIN_API_KEY", "API_KEY_RATE_LIMIT_PER_MINUTE", "API_AUTH_ENABLED=false"},
+		},
+		{
+			name: "public API keys",
+			env:  map[string]string{"PUBLIC_API_KEY_RATE_LIMIT_PER_MINUTE": "-1", "PUBLIC_API_KEY_REQUIRED": "true", "API_AUTH_ENABLED": "false"},
+			want: []string{"PUBLIC_API_KEY_RATE_LIMIT_PER_MINUTE", "PUBLIC_API_KEY_REQUIRED"},
+		},
+		{
+			name: "response cache",
+			env:  map[string]string{"RESPONSE_CACHE_SEARCH_TTL_SECONDS": "-1", "RESPONSE_CACHE_OFFERS_TTL_SECONDS": "86400"},
+			want: []string{"RESPONSE_CACHE_SEARCH_TTL_SECONDS", "RESPONSE_CACHE_OFFERS_TTL_SECONDS"},
+		},
+		{
+			name: "listing quotas",
+			env:  map[string]string{"INGEST_MAX_LISTINGS_PER_SOURCE": "live:-1", "INGEST_DAILY_LISTING_QUOTA": "live:many"},
+			want: []string{`INGEST_MAX_LISTINGS_PER_SOURCE for "live" must not be negative`, "INGEST_DAILY_LISTING_QUOTA"},
+		},
+		{
+			name: "site URL",
+			env:  map[string]string{"SITE_URL": "pricecompare.example.com"},
+			want: []string{`SITE_URL="pricecompare.example.com" is not an http(s) URL`},
+		},
+		{
+			name: "request timeouts",
+			env:  map[string]string{"REQUEST_TIMEOUT_SECONDS": "-1", "ROUTE_REQUEST_TIMEOUT_SECONDS": "feeds:60,/api/admin/:-5"},
+			want: []string{"REQUEST_TIMEOUT_SECONDS must not be negative", `"feeds" is not a path prefix`, `ROUTE_REQUEST_TIMEOUT_SECONDS for "/api/admin/" must not be negative`},
+		},
+		{
+			name: "missing fee rules file",
+			env:  map[string]string{"FEE_RULES_FILE": "/nonexistent/fees.yaml"},
+			want: []string{"FEE_RULES_FILE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			err := Load().Validate()
+
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want problems %v", tt.want)
+			}
+			problems := strings.Split(err.Error(), "\n")
+			if len(problems) != len(tt.want) {
+				t.Errorf("got %d problems, want %d: %q", len(problems), len(tt.want), problems)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+// ApplyFunc applies the reloadable parts of a new configuration (rate limits, fees,
+// provider toggles, log level). It should check everything it needs before changing
+// anything, so a failed reload leaves the running configuration untouched.
+type ApplyFunc func(ctx context.Context, cfg *Config) error
+
+// Watcher reloads the configuration on SIGHUP or on Reload and hands valid
+// configurations to an ApplyFunc. The environment of a running process cannot be changed
+// from outside, so envFile (e.g. ".env") is read again first and its values override the
+// environment. They are only written to the process environment (which providers read
+// when they are rebuilt) once the configuration they make up is valid. Settings that are
+// only read at startup (database, Redis, ports, sinks) still require a restart.
+type Watcher struct {
+	mu      sync.Mutex // serializes reloads
+	current atomic.Pointer[Config]
+	envFile string
+	load    func(overrides map[string]string) *Config
+	apply   ApplyFunc
+}
+
+func NewWatcher(initial *Config, envFile string, apply ApplyFunc) *Watcher {
+	w := &Watcher{envFile: envFile, load: load, apply: apply}
+	w.current.Store(initial)
+	return w
+}
+
+// Current returns the configuration of the last successful reload
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Reload loads and validates the configuration and applies it. On error the current
+// configuration and the process environment stay in effect.
+func (w *Watcher) Reload(ctx context.Context) (*Config, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var overrides map[string]string
+	if w.envFile != "" {
+		values, err := godotenv.Read(w.envFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", w.envFile, err)
+		}
+		overrides = values
+	}
+	cfg := w.load(overrides)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	restore := setEnv(overrides)
+	if err := w.apply(ctx, cfg); err != nil {
+		restore()
+		return nil, err
+	}
+	w.current.Store(cfg)
+	return cfg, nil
+}
+
+// setEnv sets the variables of values in the process environment and returns a function
+// that restores their previous values
+func setEnv(values map[string]string) func() {
+	type previous struct {
+		value string
+		set   bool
+	}
+	saved := make(map[string]previous, len(values))
+	for key, value := range values {
+		old, set := os.LookupEnv(key)
+		saved[key] = previous{value: old, set: set}
+		os.Setenv(key, value)
+	}
+	return func() {
+		for key, old := range saved {
+			if old.set {
+				os.Setenv(key, old.value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}
+}
+
+// WatchSignals reloads on every SIGHUP until ctx is done, reporting each outcome to
+// onReload (err is nil on success)
+func (w *Watcher) WatchSignals(ctx context.Context, onReload func(cfg *Config, err error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			onReload(w.Reload(ctx))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatcherReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		applyErr  error
+		wantErr   bool
+		wantLevel string // LogLevel of Current() after the reload
+	}{
+		{name: "valid config is applied", env: map[string]string{"LOG_LEVEL": "debug"}, wantLevel: "debug"},
+		{name: "invalid config is rejected", env: map[string]string{"LOG_LEVEL": "verbose"}, wantErr: true, wantLevel: "info"},
+		{name: "apply failure keeps current", env: map[string]string{"LOG_LEVEL": "warn"}, applyErr: errors.New("bad tables file"), wantErr: true, wantLevel: "info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initial := Load()
+			applied := 0
+			watcher := NewWatcher(initial, "", func(ctx context.Context, cfg *Config) error {
+				if tt.applyErr != nil {
+					return tt.applyErr
+				}
+				applied++
+				return nil
+			})
+
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := watcher.Reload(context.Background())
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := watcher.Current().LogLevel; got != tt.wantLevel {
+				t.Errorf("Current().LogLevel = %q, want %q", got, tt.wantLevel)
+			}
+			if tt.wantErr && applied != 0 {
+				t.Error("apply must not succeed for a rejected reload")
+			}
+		})
+	}
+}
+
+func TestWatcherReloadEnvFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		envFile  string
+		applyErr error
+		wantEnv  string // LOG_LEVEL in the process environment after the reload
+	}{
+		{name: "valid file is applied", envFile: "LOG_LEVEL=debug\n", wantEnv: "debug"},
+		{name: "invalid file leaves the environment", envFile: "LOG_LEVEL=verbose\n", wantEnv: "info"},
+		{name: "apply failure restores the environment", envFile: "LOG_LEVEL=warn\n", applyErr: errors.New("bad tables file"), wantEnv: "info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_LEVEL", "info")
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tt.envFile), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			watcher := NewWatcher(Load(), path, func(ctx context.Context, cfg *Config) error {
+				return tt.applyErr
+			})
+
+			watcher.Reload(context.Background())
+
+			if got := os.Getenv("LOG_LEVEL"); got != tt.wantEnv {
+				t.Errorf("LOG_LEVEL = %q, want %q", got, tt.wantEnv)
+			}
+		})
+	}
+}
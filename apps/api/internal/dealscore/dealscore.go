@@ -0,0 +1,91 @@
+// Package dealscore rates how good a deal an offer is, from 0 to 100, so the compare
+// endpoint can rank offers by more than their price. The score weighs the offer's total
+// against the product's median price over HistoryWindow, the seller's rating and the
+// delivery speed. A component without data (no price history, no rating, no delivery
+// estimate) scores neutral.
+package dealscore
+
+import (
+	"math"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+// HistoryWindow is how far back the median price an offer is measured against goes
+const HistoryWindow = 90 * 24 * time.Hour
+
+// Weights are the shares of the components in the score; they need not sum to 1
+type Weights struct {
+	Price    float64
+	Seller   float64
+	Delivery float64
+}
+
+// DefaultWeights make the price the main factor
+var DefaultWeights = Weights{Price: 0.6, Seller: 0.2, Delivery: 0.2}
+
+const (
+	// priceSpread is the distance from the median (as a share of it) at which the price
+	// component reaches 0 or 1: 50% under the median is the best score, 50% over the worst
+	priceSpread = 0.5
+	// fastDays and slowDays bound the delivery component: delivery within fastDays scores
+	// 1, delivery in slowDays or more 0
+	fastDays = 2
+	slowDays = 30
+	// maxRating is the top of the 0-5 star seller rating scale
+	maxRating = 5
+	neutral   = 0.5
+)
+
+type Scorer struct {
+	weights Weights
+}
+
+func New(weights Weights) *Scorer {
+	return &Scorer{weights: weights}
+}
+
+// Score rates an offer against medianTotal, the median US total (cents) of its product's
+// price history; 0 means there is no history
+func (s *Scorer) Score(offer *models.Offer, medianTotal int) float64 {
+	total := s.weights.Price + s.weights.Seller + s.weights.Delivery
+	if total <= 0 {
+		return 0
+	}
+	score := s.weights.Price*priceComponent(offer.TotalToUSAmount, medianTotal) +
+		s.weights.Seller*sellerComponent(offer.SellerRating) +
+		s.weights.Delivery*deliveryComponent(offer)
+	// One decimal is enough to rank by and keeps the responses stable
+	return math.Round(score/total*1000) / 10
+}
+
+func priceComponent(total, median int) float64 {
+	if median <= 0 {
+		return neutral
+	}
+	below := 1 - float64(total)/float64(median)
+	return clamp(neutral + below*neutral/priceSpread)
+}
+
+func sellerComponent(rating *float64) float64 {
+	if rating == nil {
+		return neutral
+	}
+	return clamp(*rating / maxRating)
+}
+
+func deliveryComponent(offer *models.Offer) float64 {
+	days := offer.EstDeliveryDaysMin
+	if days == nil {
+		days = offer.EstDeliveryDaysMax
+	}
+	if days == nil {
+		return neutral
+	}
+	return clamp(float64(slowDays-*days) / float64(slowDays-fastDays))
+}
+
+func clamp(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
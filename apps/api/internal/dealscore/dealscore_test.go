@@ -0,0 +1,38 @@
+package dealscore
+
+import (
+	"testing"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+func TestScore(t *testing.T) {
+	days := func(n int) *int { return &n }
+	rating := func(r float64) *float64 { return &r }
+	tests := []struct {
+		name   string
+		offer  models.Offer
+		median int
+		want   float64
+	}{
+		{name: "no data is neutral", offer: models.Offer{TotalToUSAmount: 10000}, want: 50},
+		{name: "at the median", offer: models.Offer{TotalToUSAmount: 10000}, median: 10000, want: 50},
+		{name: "25% under the median", offer: models.Offer{TotalToUSAmount: 7500}, median: 10000, want: 65},
+		{name: "half the median is the best price", offer: models.Offer{TotalToUSAmount: 2000}, median: 10000, want: 80},
+		{name: "over the median", offer: models.Offer{TotalToUSAmount: 20000}, median: 10000, want: 20},
+		{name: "top rated seller", offer: models.Offer{TotalToUSAmount: 10000, SellerRating: rating(5)}, median: 10000, want: 60},
+		{name: "fast delivery", offer: models.Offer{TotalToUSAmount: 10000, EstDeliveryDaysMin: days(1)}, median: 10000, want: 60},
+		{name: "slow delivery by its maximum", offer: models.Offer{TotalToUSAmount: 10000, EstDeliveryDaysMax: days(45)}, median: 10000, want: 40},
+		{
+			name:   "everything at its best",
+			offer:  models.Offer{TotalToUSAmount: 5000, SellerRating: rating(5), EstDeliveryDaysMin: days(2)},
+			median: 10000, want: 100,
+		},
+	}
+	scorer := New(DefaultWeights)
+	for _, tt := range tests {
+		if got := scorer.Score(&tt.offer, tt.median); got != tt.want {
+			t.Errorf("%s: Score = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
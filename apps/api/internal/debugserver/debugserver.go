@@ -0,0 +1,88 @@
+// Package debugserver serves pprof profiles and expvar runtime metrics on a separate
+// listener, so long-running processes can be profiled in production without exposing
+// those endpoints on the public API port.
+package debugserver
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	startTime   = time.Now()
+	publishOnce sync.Once
+)
+
+// New returns a server for addr with /debug/pprof/* and /debug/vars. Requests must send
+// "Authorization: Bearer <token>"; without a token the server may only listen on a
+// loopback address.
+func New(addr, token string) (*http.Server, error) {
+	if token == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid debug address %q: %w", addr, err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("debug server on non-loopback address %q requires a token", addr)
+		}
+	}
+
+	publishOnce.Do(publishRuntimeMetrics)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           requireToken(token, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}, nil
+}
+
+// Publish registers a metric under /debug/vars, e.g. the size of an in-memory cache
+func Publish(name string, f func() any) {
+	expvar.Publish(name, expvar.Func(f))
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// publishRuntimeMetrics adds scheduler-level metrics next to the memstats and cmdline
+// variables that expvar publishes by itself
+func publishRuntimeMetrics() {
+	Publish("runtime", func() any {
+		return map[string]any{
+			"goroutines":     runtime.NumGoroutine(),
+			"gomaxprocs":     runtime.GOMAXPROCS(0),
+			"num_cpu":        runtime.NumCPU(),
+			"cgo_calls":      runtime.NumCgoCall(),
+			"go_version":     runtime.Version(),
+			"uptime_seconds": int64(time.Since(startTime).Seconds()),
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package debugserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewRequiresTokenOffLoopback(t *testing.T) {
+	if _, err := New("0.0.0.0:6060", ""); err == nil {
+		t.Error("New() without token on 0.0.0.0 should fail")
+	}
+	if _, err := New("127.0.0.1:6060", ""); err != nil {
+		t.Errorf("New() without token on loopback error = %v", err)
+	}
+	if _, err := New(":6060", "secret"); err != nil {
+		t.Errorf("New() with token error = %v", err)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	server, err := New(":6060", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"No token", "", http.StatusUnauthorized},
+		{"Wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"Valid token", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package embedding computes text embeddings for product titles with a pluggable backend.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Embedder turns texts into vectors. Vectors from different models are not comparable,
+// so callers store and search them per Model().
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+const defaultTimeout = 15 * time.Second
+
+// OpenAIEmbedder calls the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenAIEmbedder(apiKey, model string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: "https://api.openai.com/v1",
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (e *OpenAIEmbedder) Model() string {
+	return e.model
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + e.apiKey}
+	body := map[string]any{"model": e.model, "input": texts}
+	if err := postJSON(ctx, e.client, e.baseURL+"/embeddings", headers, body, &response); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("missing embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// TEIEmbedder calls a self-hosted text-embeddings-inference server (or one compatible with
+// its API: POST /embed {"inputs": [...]} -> [[...], ...]); the model runs in the server
+type TEIEmbedder struct {
+	url    string
+	model  string
+	client *http.Client
+}
+
+func NewTEIEmbedder(url, model string) *TEIEmbedder {
+	return &TEIEmbedder{
+		url:    strings.TrimRight(url, "/"),
+		model:  model,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (e *TEIEmbedder) Model() string {
+	return e.model
+}
+
+func (e *TEIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	body := map[string]any{"inputs": texts, "normalize": true}
+	if err := postJSON(ctx, e.client, e.url+"/embed", nil, body, &vectors); err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding server returned %d vectors for %d inputs", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("embedding API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	return nil
+}
+
+// VectorLiteral formats a vector in pgvector's text representation, e.g. "[0.1,0.2]"
+func VectorLiteral(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVectorLiteral(t *testing.T) {
+	got := VectorLiteral([]float32{0.5, -1, 0.25})
+	if got != "[0.5,-1,0.25]" {
+		t.Errorf("VectorLiteral() = %q, want %q", got, "[0.5,-1,0.25]")
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		// Results may come back out of order; Embed must sort them by index
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{
+				{"index": 1, "embedding": []float32{0, 1}},
+				{"index": 0, "embedding": []float32{1, 0}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	embedder := NewOpenAIEmbedder("test-key", "text-embedding-3-small")
+	embedder.baseURL = server.URL
+
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Embed() = %v", vectors)
+	}
+}
+
+func TestTEIEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode([][]float32{{0.1, 0.2}})
+	}))
+	defer server.Close()
+
+	embedder := NewTEIEmbedder(server.URL+"/", "all-MiniLM-L6-v2")
+	vectors, err := embedder.Embed(context.Background(), []string{"a"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) != 2 {
+		t.Errorf("Embed() = %v", vectors)
+	}
+
+	if _, err := embedder.Embed(context.Background(), []string{"a", "b"}); err == nil {
+		t.Error("Embed() expected error when the server returns fewer vectors than inputs")
+	}
+}
@@ -0,0 +1,127 @@
+// Package events publishes domain events about product and offer changes to a message
+// bus (Redis streams, NATS or Kafka), so downstream systems can react to changes
+// without polling the database.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+// Event types
+const (
+	TypeProductCreated    = "product.created"
+	TypeOfferPriceChanged = "offer.price_changed"
+	TypeOfferOutOfStock   = "offer.out_of_stock"
+)
+
+// Event is the envelope published to the bus. Data is one of the payload types below.
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Key        string    `json:"key"` // product ID; events of one product keep their order (Kafka partition key)
+	Data       any       `json:"data"`
+}
+
+// ProductCreated is the payload of product.created
+type ProductCreated struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Title     string    `json:"title"`
+	Brand     *string   `json:"brand,omitempty"`
+	Model     *string   `json:"model,omitempty"`
+	Category  *string   `json:"category,omitempty"`
+	Source    string    `json:"source"` // provider whose listing created the product
+}
+
+// OfferPriceChanged is the payload of offer.price_changed. Prices are in minor units of
+// their currency; totals are in US cents.
+type OfferPriceChanged struct {
+	OfferID            uuid.UUID `json:"offer_id"`
+	ProductID          uuid.UUID `json:"product_id"`
+	Source             string    `json:"source"`
+	Seller             string    `json:"seller"`
+	URL                *string   `json:"url,omitempty"`
+	OldPriceAmount     int       `json:"old_price_amount"`
+	OldCurrency        string    `json:"old_currency"`
+	NewPriceAmount     int       `json:"new_price_amount"`
+	NewCurrency        string    `json:"new_currency"`
+	OldTotalToUSAmount int       `json:"old_total_to_us_amount"`
+	NewTotalToUSAmount int       `json:"new_total_to_us_amount"`
+}
+
+// OfferOutOfStock is the payload of offer.out_of_stock
+type OfferOutOfStock struct {
+	OfferID            uuid.UUID `json:"offer_id"`
+	ProductID          uuid.UUID `json:"product_id"`
+	Source             string    `json:"source"`
+	Seller             string    `json:"seller"`
+	URL                *string   `json:"url,omitempty"`
+	AvailabilityStatus *string   `json:"availability_status,omitempty"`
+}
+
+// Publisher delivers events to a bus
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// New returns an event of eventType for a product
+func New(eventType string, productID uuid.UUID, data any) Event {
+	return Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Key:        productID.String(),
+		Data:       data,
+	}
+}
+
+// NewProductCreated returns the product.created event for a product created from a
+// listing of source
+func NewProductCreated(product *models.Product, source string) Event {
+	return New(TypeProductCreated, product.ID, ProductCreated{
+		ProductID: product.ID,
+		Title:     product.Title,
+		Brand:     product.Brand,
+		Model:     product.Model,
+		Category:  product.Category,
+		Source:    source,
+	})
+}
+
+// OfferChanges compares a refreshed offer with its previous state and returns the
+// offer.price_changed and offer.out_of_stock events it causes
+func OfferChanges(previous, current *models.Offer) []Event {
+	var changes []Event
+	if current.PriceAmount != previous.PriceAmount || current.Currency != previous.Currency {
+		changes = append(changes, New(TypeOfferPriceChanged, current.ProductID, OfferPriceChanged{
+			OfferID:            current.ID,
+			ProductID:          current.ProductID,
+			Source:             current.Source,
+			Seller:             current.Seller,
+			URL:                current.URL,
+			OldPriceAmount:     previous.PriceAmount,
+			OldCurrency:        previous.Currency,
+			NewPriceAmount:     current.PriceAmount,
+			NewCurrency:        current.Currency,
+			OldTotalToUSAmount: previous.TotalToUSAmount,
+			NewTotalToUSAmount: current.TotalToUSAmount,
+		}))
+	}
+	if previous.InStock && !current.InStock {
+		changes = append(changes, New(TypeOfferOutOfStock, current.ProductID, OfferOutOfStock{
+			OfferID:            current.ID,
+			ProductID:          current.ProductID,
+			Source:             current.Source,
+			Seller:             current.Seller,
+			URL:                current.URL,
+			AvailabilityStatus: current.AvailabilityStatus,
+		}))
+	}
+	return changes
+}
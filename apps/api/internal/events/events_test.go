@@ -0,0 +1,87 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+func TestOfferChanges(t *testing.T) {
+	productID := uuid.New()
+	offer := func(priceAmount int, currency string, inStock bool) *models.Offer {
+		return &models.Offer{
+			ID:          uuid.New(),
+			ProductID:   productID,
+			Source:      "walmart",
+			Seller:      "Walmart",
+			PriceAmount: priceAmount,
+			Currency:    currency,
+			InStock:     inStock,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		previous  *models.Offer
+		current   *models.Offer
+		wantTypes []string
+	}{
+		{name: "unchanged", previous: offer(1999, "USD", true), current: offer(1999, "USD", true)},
+		{name: "price drop", previous: offer(1999, "USD", true), current: offer(1799, "USD", true), wantTypes: []string{TypeOfferPriceChanged}},
+		{name: "currency change", previous: offer(1999, "USD", true), current: offer(1999, "JPY", true), wantTypes: []string{TypeOfferPriceChanged}},
+		{name: "went out of stock", previous: offer(1999, "USD", true), current: offer(1999, "USD", false), wantTypes: []string{TypeOfferOutOfStock}},
+		{name: "back in stock is not an event", previous: offer(1999, "USD", false), current: offer(1999, "USD", true)},
+		{
+			name:      "price change and out of stock",
+			previous:  offer(1999, "USD", true),
+			current:   offer(2499, "USD", false),
+			wantTypes: []string{TypeOfferPriceChanged, TypeOfferOutOfStock},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := OfferChanges(tt.previous, tt.current)
+			if len(changes) != len(tt.wantTypes) {
+				t.Fatalf("got %d events, want %v", len(changes), tt.wantTypes)
+			}
+			for i, event := range changes {
+				if event.Type != tt.wantTypes[i] {
+					t.Errorf("event %d type = %q, want %q", i, event.Type, tt.wantTypes[i])
+				}
+				if event.Key != productID.String() {
+					t.Errorf("event %d key = %q, want product ID %q", i, event.Key, productID)
+				}
+			}
+		})
+	}
+}
+
+func TestEvent_JSON(t *testing.T) {
+	current := &models.Offer{ID: uuid.New(), ProductID: uuid.New(), Source: "amazon", Seller: "Amazon", PriceAmount: 900, Currency: "USD", TotalToUSAmount: 1200}
+	previous := &models.Offer{PriceAmount: 1000, Currency: "USD", TotalToUSAmount: 1300}
+	event := OfferChanges(previous, current)[0]
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded struct {
+		Type string         `json:"type"`
+		Key  string         `json:"key"`
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if decoded.Type != TypeOfferPriceChanged || decoded.Key != current.ProductID.String() {
+		t.Errorf("envelope = %s", data)
+	}
+	if decoded.Data["old_price_amount"] != float64(1000) || decoded.Data["new_price_amount"] != float64(900) || decoded.Data["offer_id"] != current.ID.String() {
+		t.Errorf("data = %v", decoded.Data)
+	}
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes events to the subject <prefix>.<type>, e.g.
+// "pricecompare.events.offer.price_changed". The event ID is sent as Nats-Msg-Id so a
+// JetStream stream on those subjects deduplicates retries.
+type NATSPublisher struct {
+	conn          *nats.Conn
+	subjectPrefix string
+}
+
+// NewNATSPublisher connects to the NATS server at url
+func NewNATSPublisher(url, subjectPrefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("pricecompare-api"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSPublisher{conn: conn, subjectPrefix: subjectPrefix}, nil
+}
+
+// Publish sends the event. Core NATS publishing is buffered by the client, so ctx is
+// only checked before sending.
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	msg := nats.NewMsg(p.subjectPrefix + "." + event.Type)
+	msg.Header.Set(nats.MsgIdHdr, event.ID.String())
+	msg.Data = data
+	return p.conn.PublishMsg(msg)
+}
+
+// Close flushes pending messages and closes the connection
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
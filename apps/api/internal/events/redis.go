@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPublisher appends events to a Redis stream. Each entry has the fields "type",
+// "key" and "event" (the JSON envelope); consumers read it with XREADGROUP.
+type RedisPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisPublisher returns a publisher for stream, trimmed to about maxLen entries.
+// The client is owned by the caller.
+func NewRedisPublisher(client *redis.Client, stream string, maxLen int64) *RedisPublisher {
+	return &RedisPublisher{client: client, stream: stream, maxLen: maxLen}
+}
+
+// Publish adds the event to the stream
+func (p *RedisPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: true,
+		Values: map[string]any{"type": event.Type, "key": event.Key, "event": data},
+	}).Err()
+}
+
+// Close is a no-op; the Redis client is shared with the rest of the server
+func (p *RedisPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache shares the last good quotes between API instances and across restarts, so an
+// instance whose providers fail can still price offers with rates another instance fetched
+type Cache interface {
+	Load(ctx context.Context) (map[string]Quote, error)
+	Store(ctx context.Context, quotes map[string]Quote) error
+}
+
+const (
+	redisCacheKey = "fx:quotes"
+	redisCacheTTL = 7 * 24 * time.Hour
+)
+
+// RedisCache implements Cache with one JSON value in Redis
+type RedisCache struct {
+	client *redis.Client
+}
+
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+// Load returns the cached quotes, or nil if there are none
+func (c *RedisCache) Load(ctx context.Context) (map[string]Quote, error) {
+	data, err := c.client.Get(ctx, redisCacheKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var quotes map[string]Quote
+	if err := json.Unmarshal(data, &quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+func (c *RedisCache) Store(ctx context.Context, quotes map[string]Quote) error {
+	data, err := json.Marshal(quotes)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, redisCacheKey, data, redisCacheTTL).Err()
+}
@@ -0,0 +1,245 @@
+// Package fx resolves currency exchange rates from a prioritized list of providers.
+// All rates are expressed as units of the currency per 1 USD (e.g. JPY: 150).
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pricecompare/api/internal/httpclient"
+)
+
+// Provider fetches exchange rates against USD
+type Provider interface {
+	Name() string
+	FetchRates(ctx context.Context) (map[string]float64, error)
+}
+
+// StaticProvider returns fixed rates from configuration (e.g. FX_USDJPY)
+type StaticProvider struct {
+	rates map[string]float64
+}
+
+func NewStaticProvider(rates map[string]float64) *StaticProvider {
+	return &StaticProvider{rates: rates}
+}
+
+func (p *StaticProvider) Name() string {
+	return "static"
+}
+
+func (p *StaticProvider) FetchRates(ctx context.Context) (map[string]float64, error) {
+	rates := make(map[string]float64, len(p.rates))
+	for currency, rate := range p.rates {
+		if rate > 0 {
+			rates[strings.ToUpper(currency)] = rate
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no static FX rates configured")
+	}
+	return rates, nil
+}
+
+// HTTPProvider fetches rates from a JSON endpoint with a USD base, in the
+// {"rates": {"JPY": 150.1, ...}} format used by open.er-api.com and similar services
+type HTTPProvider struct {
+	name       string
+	url        string
+	httpClient *httpclient.Client
+}
+
+func NewHTTPProvider(name, url string, httpClient *httpclient.Client) *HTTPProvider {
+	return &HTTPProvider{name: name, url: url, httpClient: httpClient}
+}
+
+func (p *HTTPProvider) Name() string {
+	return p.name
+}
+
+func (p *HTTPProvider) FetchRates(ctx context.Context) (map[string]float64, error) {
+	resp, err := p.httpClient.GetExpecting(ctx, "fx", p.url, httpclient.ContentJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch FX rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("FX API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var payload struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode FX rates: %w", err)
+	}
+	if len(payload.Rates) == 0 {
+		return nil, fmt.Errorf("FX API returned no rates")
+	}
+	return payload.Rates, nil
+}
+
+// ECBProvider reads the European Central Bank's daily reference rates. The feed quotes
+// rates per EUR, so they are rebased on its USD rate.
+type ECBProvider struct {
+	url        string
+	httpClient *httpclient.Client
+}
+
+func NewECBProvider(url string, httpClient *httpclient.Client) *ECBProvider {
+	return &ECBProvider{url: url, httpClient: httpClient}
+}
+
+func (p *ECBProvider) Name() string {
+	return "ecb"
+}
+
+func (p *ECBProvider) FetchRates(ctx context.Context) (map[string]float64, error) {
+	// The feed is served as text/xml, which the content type check does not classify
+	resp, err := p.httpClient.Get(ctx, "fx", p.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ECB rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ECB returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return parseECBRates(resp.Body)
+}
+
+// parseECBRates converts the <Cube currency="JPY" rate="..."/> entries of the ECB feed
+// from rates per EUR to rates per USD
+func parseECBRates(r io.Reader) (map[string]float64, error) {
+	var envelope struct {
+		Cube struct {
+			Cube struct {
+				Rates []struct {
+					Currency string  `xml:"currency,attr"`
+					Rate     float64 `xml:"rate,attr"`
+				} `xml:"Cube"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	}
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode ECB rates: %w", err)
+	}
+
+	perEUR := map[string]float64{"EUR": 1}
+	for _, rate := range envelope.Cube.Cube.Rates {
+		if rate.Rate > 0 {
+			perEUR[strings.ToUpper(rate.Currency)] = rate.Rate
+		}
+	}
+	usdPerEUR, ok := perEUR["USD"]
+	if !ok {
+		return nil, fmt.Errorf("ECB rates have no USD rate")
+	}
+	rates := make(map[string]float64, len(perEUR))
+	for currency, rate := range perEUR {
+		if currency != "USD" {
+			rates[currency] = rate / usdPerEUR
+		}
+	}
+	return rates, nil
+}
+
+// ExchangeRateHostProvider fetches live rates from exchangerate.host, which returns
+// {"success": true, "quotes": {"USDJPY": 150.1, ...}} for source=USD
+type ExchangeRateHostProvider struct {
+	url        string
+	accessKey  string
+	httpClient *httpclient.Client
+}
+
+func NewExchangeRateHostProvider(url, accessKey string, httpClient *httpclient.Client) *ExchangeRateHostProvider {
+	return &ExchangeRateHostProvider{url: url, accessKey: accessKey, httpClient: httpClient}
+}
+
+func (p *ExchangeRateHostProvider) Name() string {
+	return "exchangerate_host"
+}
+
+func (p *ExchangeRateHostProvider) FetchRates(ctx context.Context) (map[string]float64, error) {
+	target, err := url.Parse(p.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchangerate.host URL: %w", err)
+	}
+	query := target.Query()
+	query.Set("source", "USD")
+	if p.accessKey != "" {
+		query.Set("access_key", p.accessKey)
+	}
+	target.RawQuery = query.Encode()
+
+	// exchangerate.host only takes the key as a query parameter, so it is kept out of
+	// errors that quote the URL (they are logged and recorded in FX audit events)
+	resp, err := p.httpClient.GetExpecting(ctx, "fx", target.String(), httpclient.ContentJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch exchangerate.host rates: %w", redactedError{err: err, secret: p.accessKey})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("exchangerate.host returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return parseExchangeRateHostRates(resp.Body)
+}
+
+// redactedError hides a secret in the message of the error it wraps
+type redactedError struct {
+	err    error
+	secret string
+}
+
+func (e redactedError) Error() string {
+	if e.secret == "" {
+		return e.err.Error()
+	}
+	return strings.ReplaceAll(e.err.Error(), e.secret, "REDACTED")
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// parseExchangeRateHostRates strips the USD prefix of each quote ("USDJPY" -> "JPY")
+func parseExchangeRateHostRates(r io.Reader) (map[string]float64, error) {
+	var payload struct {
+		Success bool               `json:"success"`
+		Quotes  map[string]float64 `json:"quotes"`
+		Error   *struct {
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode exchangerate.host rates: %w", err)
+	}
+	if !payload.Success {
+		if payload.Error != nil && payload.Error.Info != "" {
+			return nil, fmt.Errorf("exchangerate.host error: %s", payload.Error.Info)
+		}
+		return nil, fmt.Errorf("exchangerate.host request failed")
+	}
+
+	rates := make(map[string]float64, len(payload.Quotes))
+	for pair, rate := range payload.Quotes {
+		if currency, ok := strings.CutPrefix(strings.ToUpper(pair), "USD"); ok && currency != "" {
+			rates[currency] = rate
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("exchangerate.host returned no rates")
+	}
+	return rates, nil
+}
@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/pricecompare/api/internal/httpclient"
+)
+
+func TestParseECBRates(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2026-10-15">
+			<Cube currency="USD" rate="1.0800"/>
+			<Cube currency="JPY" rate="162.00"/>
+			<Cube currency="GBP" rate="0.8640"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+	rates, err := parseECBRates(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parseECBRates() error = %v", err)
+	}
+	want := map[string]float64{"JPY": 150, "GBP": 0.8, "EUR": 1 / 1.08}
+	if len(rates) != len(want) {
+		t.Fatalf("rates = %v, want %v", rates, want)
+	}
+	for currency, rate := range want {
+		if math.Abs(rates[currency]-rate) > 1e-9 {
+			t.Errorf("rates[%s] = %v, want %v", currency, rates[currency], rate)
+		}
+	}
+
+	if _, err := parseECBRates(strings.NewReader(`<Envelope><Cube><Cube><Cube currency="JPY" rate="162"/></Cube></Cube></Envelope>`)); err == nil {
+		t.Error("parseECBRates() expected error without a USD rate")
+	}
+}
+
+func TestParseExchangeRateHostRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    map[string]float64
+		wantErr string
+	}{
+		{
+			name: "quotes",
+			body: `{"success":true,"source":"USD","quotes":{"USDJPY":150.5,"USDEUR":0.92}}`,
+			want: map[string]float64{"JPY": 150.5, "EUR": 0.92},
+		},
+		{
+			name:    "api error",
+			body:    `{"success":false,"error":{"code":101,"info":"You have not supplied an API Access Key."}}`,
+			wantErr: "You have not supplied an API Access Key.",
+		},
+		{
+			name:    "no quotes",
+			body:    `{"success":true,"quotes":{}}`,
+			wantErr: "no rates",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := parseExchangeRateHostRates(strings.NewReader(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if len(rates) != len(tt.want) {
+				t.Fatalf("rates = %v, want %v", rates, tt.want)
+			}
+			for currency, rate := range tt.want {
+				if rates[currency] != rate {
+					t.Errorf("rates[%s] = %v, want %v", currency, rates[currency], rate)
+				}
+			}
+		})
+	}
+}
+
+func TestExchangeRateHostProviderRedactsAccessKey(t *testing.T) {
+	client := httpclient.New(&httpclient.Config{
+		AllowLiveFetch:     false,
+		ProviderRateLimits: make(map[string]httpclient.RateLimitConfig),
+		DefaultRateLimit:   httpclient.RateLimitConfig{RPS: 10, Burst: 10},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	provider := NewExchangeRateHostProvider("https://api.exchangerate.host/live", "secret-key", client)
+
+	_, err := provider.FetchRates(context.Background())
+	if err == nil {
+		t.Fatal("FetchRates() error = nil with live fetch disabled")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("FetchRates() error = %q, contains the access key", err)
+	}
+}
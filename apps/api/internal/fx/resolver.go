@@ -0,0 +1,216 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pricecompare/api/internal/audit"
+)
+
+// Quote is a resolved exchange rate and where it came from
+type Quote struct {
+	Currency  string
+	Rate      float64 // units of Currency per 1 USD
+	Source    string  // provider name
+	FetchedAt time.Time
+}
+
+// Resolver tries providers in priority order and keeps the last good quotes.
+// When every provider fails, the last cached quotes keep being served and each
+// use of a quote older than maxAge is written to the audit log.
+type Resolver struct {
+	providers []Provider
+	maxAge    time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+	cache     Cache // optional, see SetCache
+
+	mu          sync.RWMutex
+	quotes      map[string]Quote
+	staleLogged map[string]bool
+}
+
+func NewResolver(providers []Provider, maxAge time.Duration, logger *slog.Logger) *Resolver {
+	return &Resolver{
+		providers:   providers,
+		maxAge:      maxAge,
+		logger:      logger,
+		now:         time.Now,
+		quotes:      make(map[string]Quote),
+		staleLogged: make(map[string]bool),
+	}
+}
+
+// SetCache stores every successful refresh in cache and falls back to it when all
+// providers fail
+func (r *Resolver) SetCache(cache Cache) {
+	r.cache = cache
+}
+
+// Refresh fetches rates from the first provider that succeeds. Falling back past a
+// failing provider is audited. If all providers fail, newer quotes from the shared
+// cache are used if there are any, and the quotes in memory are kept otherwise.
+func (r *Resolver) Refresh(ctx context.Context) error {
+	var failed []string
+	var errs []string
+	for _, provider := range r.providers {
+		rates, err := provider.FetchRates(ctx)
+		if err != nil {
+			failed = append(failed, provider.Name())
+			errs = append(errs, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
+
+		now := r.now()
+		r.mu.Lock()
+		for currency, rate := range rates {
+			if rate <= 0 {
+				continue
+			}
+			currency = strings.ToUpper(currency)
+			r.quotes[currency] = Quote{Currency: currency, Rate: rate, Source: provider.Name(), FetchedAt: now}
+			delete(r.staleLogged, currency)
+		}
+		r.mu.Unlock()
+		r.storeCache(ctx)
+
+		if len(failed) > 0 {
+			audit.LogFXRate(r.logger, audit.FXEntry{
+				Timestamp:       now,
+				Event:           audit.FXEventFallback,
+				Source:          provider.Name(),
+				FailedProviders: failed,
+				Error:           strings.Join(errs, "; "),
+			})
+		}
+		return nil
+	}
+
+	if r.loadCache(ctx) > 0 {
+		audit.LogFXRate(r.logger, audit.FXEntry{
+			Timestamp:       r.now(),
+			Event:           audit.FXEventFallback,
+			Source:          "cache",
+			FailedProviders: failed,
+			Error:           strings.Join(errs, "; "),
+		})
+		return nil
+	}
+	return fmt.Errorf("all FX providers failed: %s", strings.Join(errs, "; "))
+}
+
+// storeCache writes the current quotes to the cache. A cache outage must not fail the
+// refresh, so errors are only logged.
+func (r *Resolver) storeCache(ctx context.Context) {
+	if r.cache == nil {
+		return
+	}
+	r.mu.RLock()
+	quotes := make(map[string]Quote, len(r.quotes))
+	for currency, quote := range r.quotes {
+		quotes[currency] = quote
+	}
+	r.mu.RUnlock()
+
+	if err := r.cache.Store(ctx, quotes); err != nil {
+		r.logger.Warn("Failed to cache FX rates", slog.String("error", err.Error()))
+	}
+}
+
+// loadCache adopts cached quotes that are newer than the ones in memory and returns
+// how many it adopted
+func (r *Resolver) loadCache(ctx context.Context) int {
+	if r.cache == nil {
+		return 0
+	}
+	quotes, err := r.cache.Load(ctx)
+	if err != nil {
+		r.logger.Warn("Failed to load cached FX rates", slog.String("error", err.Error()))
+		return 0
+	}
+
+	adopted := 0
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for currency, quote := range quotes {
+		if quote.Rate <= 0 {
+			continue
+		}
+		if current, ok := r.quotes[currency]; ok && !quote.FetchedAt.After(current.FetchedAt) {
+			continue
+		}
+		r.quotes[currency] = quote
+		delete(r.staleLogged, currency)
+		adopted++
+	}
+	return adopted
+}
+
+// Run refreshes rates every interval until ctx is done
+func (r *Resolver) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				r.logger.Warn("FX refresh failed, serving cached rates", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// Quote returns the current quote for a currency
+func (r *Resolver) Quote(currency string) (Quote, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == "USD" {
+		return Quote{Currency: "USD", Rate: 1, Source: "identity", FetchedAt: r.now()}, nil
+	}
+
+	r.mu.RLock()
+	quote, ok := r.quotes[currency]
+	r.mu.RUnlock()
+	if !ok {
+		return Quote{}, fmt.Errorf("no FX rate available for %s", currency)
+	}
+
+	if age := r.now().Sub(quote.FetchedAt); r.maxAge > 0 && age > r.maxAge {
+		r.logStale(quote, age)
+	}
+	return quote, nil
+}
+
+// Rate returns units of currency per 1 USD
+func (r *Resolver) Rate(currency string) (float64, error) {
+	quote, err := r.Quote(currency)
+	if err != nil {
+		return 0, err
+	}
+	return quote.Rate, nil
+}
+
+// logStale audits the first use of a stale quote per currency until the next successful refresh
+func (r *Resolver) logStale(quote Quote, age time.Duration) {
+	r.mu.Lock()
+	if r.staleLogged[quote.Currency] {
+		r.mu.Unlock()
+		return
+	}
+	r.staleLogged[quote.Currency] = true
+	r.mu.Unlock()
+
+	audit.LogFXRate(r.logger, audit.FXEntry{
+		Timestamp: r.now(),
+		Event:     audit.FXEventStale,
+		Currency:  quote.Currency,
+		Source:    quote.Source,
+		Rate:      quote.Rate,
+		AgeSec:    int64(age.Seconds()),
+	})
+}
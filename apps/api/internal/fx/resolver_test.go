@@ -0,0 +1,137 @@
+package fx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	name  string
+	rates map[string]float64
+	err   error
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) FetchRates(ctx context.Context) (map[string]float64, error) {
+	return p.rates, p.err
+}
+
+func TestResolverFallback(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	primary := &fakeProvider{name: "http", err: errors.New("timeout")}
+	static := &fakeProvider{name: "static", rates: map[string]float64{"JPY": 150}}
+	resolver := NewResolver([]Provider{primary, static}, time.Hour, logger)
+
+	if err := resolver.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	quote, err := resolver.Quote("jpy")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	if quote.Rate != 150 || quote.Source != "static" {
+		t.Errorf("Quote() = %+v, want rate 150 from static", quote)
+	}
+	if !strings.Contains(buf.String(), `"event":"fallback"`) {
+		t.Errorf("expected fallback audit entry, got %s", buf.String())
+	}
+}
+
+func TestResolverServesStaleRates(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	provider := &fakeProvider{name: "http", rates: map[string]float64{"JPY": 148}}
+	resolver := NewResolver([]Provider{provider}, time.Hour, logger)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver.now = func() time.Time { return now }
+
+	if err := resolver.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// Provider goes down and the cached rate ages past maxAge
+	provider.err = errors.New("unavailable")
+	if err := resolver.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh() expected error when all providers fail")
+	}
+	now = now.Add(2 * time.Hour)
+
+	for i := 0; i < 2; i++ {
+		rate, err := resolver.Rate("JPY")
+		if err != nil || rate != 148 {
+			t.Fatalf("Rate() = (%v, %v), want (148, nil)", rate, err)
+		}
+	}
+	if got := strings.Count(buf.String(), `"event":"stale"`); got != 1 {
+		t.Errorf("expected one stale audit entry, got %d: %s", got, buf.String())
+	}
+}
+
+func TestResolverUnknownCurrency(t *testing.T) {
+	resolver := NewResolver(nil, time.Hour, slog.Default())
+
+	if rate, err := resolver.Rate("USD"); err != nil || rate != 1 {
+		t.Errorf("Rate(USD) = (%v, %v), want (1, nil)", rate, err)
+	}
+	if _, err := resolver.Rate("EUR"); err == nil {
+		t.Error("Rate(EUR) expected error without any quotes")
+	}
+}
+
+// mapCache is an in-memory Cache
+type mapCache struct {
+	quotes map[string]Quote
+}
+
+func (c *mapCache) Load(ctx context.Context) (map[string]Quote, error) { return c.quotes, nil }
+
+func (c *mapCache) Store(ctx context.Context, quotes map[string]Quote) error {
+	c.quotes = quotes
+	return nil
+}
+
+func TestResolverSharedCache(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	cache := &mapCache{}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// One instance refreshes and fills the cache
+	writer := NewResolver([]Provider{&fakeProvider{name: "ecb", rates: map[string]float64{"EUR": 0.9}}}, time.Hour, logger)
+	writer.now = func() time.Time { return now }
+	writer.SetCache(cache)
+	if err := writer.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if cache.quotes["EUR"].Rate != 0.9 {
+		t.Fatalf("cached quotes = %+v, want EUR 0.9", cache.quotes)
+	}
+
+	// Another instance whose providers are down uses the cached quotes
+	reader := NewResolver([]Provider{&fakeProvider{name: "ecb", err: errors.New("timeout")}}, time.Hour, logger)
+	reader.SetCache(cache)
+	if err := reader.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() with cached quotes error = %v", err)
+	}
+	if quote, err := reader.Quote("EUR"); err != nil || quote.Rate != 0.9 || quote.Source != "ecb" {
+		t.Errorf("Quote(EUR) = (%+v, %v), want the cached ecb quote", quote, err)
+	}
+	if !strings.Contains(buf.String(), `"source":"cache"`) {
+		t.Errorf("expected a fallback to cache audit entry, got %s", buf.String())
+	}
+
+	// Nothing newer in the cache: the failure is reported
+	if err := reader.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() expected error when the cache has nothing newer")
+	}
+}
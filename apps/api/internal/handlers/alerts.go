@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/mail"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// AlertConfirmer emails the confirmation link of an email alert; implemented by
+// *notifications.Dispatcher
+type AlertConfirmer interface {
+	SendConfirmation(ctx context.Context, target, token string, confirmation notifications.AlertConfirmation) error
+}
+
+// EnablePriceAlerts serves the /api/alerts routes. channels are the alert channels that
+// can be delivered (email needs SMTP); confirmer sends the confirmation links of email
+// alerts and may be nil without the email channel.
+func (h *Handlers) EnablePriceAlerts(alertRepo repository.PriceAlertStore, channels []string, confirmer AlertConfirmer) {
+	h.alertRepo = alertRepo
+	h.alertChannels = channels
+	h.alertConfirmer = confirmer
+}
+
+type CreatePriceAlertRequest struct {
+	ProductID        string `json:"product_id"`
+	Channel          string `json:"channel"` // "email" or "webhook"
+	Target           string `json:"target"`  // email address or http(s) URL
+	TargetPriceCents int    `json:"target_price_cents"`
+}
+
+// CreatePriceAlert registers an alert that fires once the cheapest US total of the
+// product is at or below target_price_cents. Email alerts are sent a confirmation link
+// and stay inactive until it is opened, so addresses cannot be signed up by others.
+func (h *Handlers) CreatePriceAlert(c *fiber.Ctx) error {
+	if h.alertRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price alerts are not enabled",
+		})
+	}
+
+	var req CreatePriceAlertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	productID, err := uuid.Parse(req.ProductID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+	if !slices.Contains(h.alertChannels, req.Channel) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    "unsupported channel",
+			"channels": h.alertChannels,
+		})
+	}
+	if !validAlertTarget(req.Channel, req.Target) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "target must be an email address for email alerts and an http(s) URL for webhook alerts",
+		})
+	}
+	if req.TargetPriceCents <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "target_price_cents must be positive",
+		})
+	}
+
+	product, err := h.productRepo.GetByID(c.UserContext(), productID)
+	if err != nil {
+		h.logger.Error("Failed to get product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get product",
+		})
+	}
+	if product == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+
+	alert := &models.PriceAlert{
+		ProductID:        productID,
+		Channel:          req.Channel,
+		Target:           req.Target,
+		TargetPriceCents: req.TargetPriceCents,
+	}
+	if alert.Channel == models.AlertChannelEmail {
+		alert.ConfirmToken, err = newURLToken()
+		if err != nil {
+			h.logger.Error("Failed to generate confirmation token", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create price alert",
+			})
+		}
+	}
+	if err := h.alertRepo.Create(c.UserContext(), alert); err != nil {
+		h.logger.Error("Failed to create price alert", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create price alert",
+		})
+	}
+
+	if alert.Channel == models.AlertChannelEmail {
+		err := h.alertConfirmer.SendConfirmation(c.UserContext(), alert.Target, alert.ConfirmToken, notifications.AlertConfirmation{
+			AlertID:          alert.ID.String(),
+			ProductTitle:     product.Title,
+			TargetPriceCents: alert.TargetPriceCents,
+		})
+		if err != nil {
+			h.logger.Error("Failed to send price alert confirmation", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+			// An alert that can never be confirmed is not kept
+			if err := h.alertRepo.Delete(c.UserContext(), alert.ID); err != nil {
+				h.logger.Warn("Failed to delete unconfirmed price alert", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to send confirmation email",
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(alert)
+}
+
+// ConfirmPriceAlert activates the email alert of the token in its confirmation link
+func (h *Handlers) ConfirmPriceAlert(c *fiber.Ctx) error {
+	if h.alertRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price alerts are not enabled",
+		})
+	}
+
+	alert, err := h.alertRepo.Confirm(c.UserContext(), c.Params("token"), time.Now())
+	if err != nil {
+		h.logger.Error("Failed to confirm price alert", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to confirm price alert",
+		})
+	}
+	if alert == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "confirmation link is invalid or the alert was deleted",
+		})
+	}
+
+	return c.JSON(alert)
+}
+
+// GetPriceAlert returns an alert, including when it last fired
+func (h *Handlers) GetPriceAlert(c *fiber.Ctx) error {
+	if h.alertRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price alerts are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid alert id",
+		})
+	}
+
+	alert, err := h.alertRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get price alert", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get price alert",
+		})
+	}
+	if alert == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price alert not found",
+		})
+	}
+
+	return c.JSON(alert)
+}
+
+// DeletePriceAlert unsubscribes an alert
+func (h *Handlers) DeletePriceAlert(c *fiber.Ctx) error {
+	if h.alertRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price alerts are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid alert id",
+		})
+	}
+
+	err = h.alertRepo.Delete(c.UserContext(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price alert not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete price alert", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete price alert",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func validAlertTarget(channel, target string) bool {
+	switch channel {
+	case models.AlertChannelEmail:
+		address, err := mail.ParseAddress(target)
+		// A bare address only, not "Name <address>"
+		return err == nil && address.Address == target
+	case models.AlertChannelWebhook:
+		u, err := url.Parse(target)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	default:
+		return false
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// EnableAPIKeys serves the /api/admin/api-keys routes
+func (h *Handlers) EnableAPIKeys(keyRepo repository.APIKeyStore) {
+	h.apiKeyRepo = keyRepo
+}
+
+type CreateAPIKeyRequest struct {
+	Name               string `json:"name"`
+	Role               string `json:"role"`                  // "public", "read" or "admin"
+	RateLimitPerMinute *int   `json:"rate_limit_per_minute"` // omitted uses the role's default limit, 0 is unlimited
+}
+
+// CreateAPIKeyResponse is the new key; Key is only returned here
+type CreateAPIKeyResponse struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey generates a key. Only its hash is stored, so the response is the only
+// place the key can be read.
+func (h *Handlers) CreateAPIKey(c *fiber.Ctx) error {
+	if h.apiKeyRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API keys are not enabled",
+		})
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+	if _, ok := roleRanks[req.Role]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "role must be 'public', 'read' or 'admin'",
+		})
+	}
+	if req.RateLimitPerMinute != nil && *req.RateLimitPerMinute < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "rate_limit_per_minute must not be negative",
+		})
+	}
+
+	raw, err := newAPIKey()
+	if err != nil {
+		h.logger.Error("Failed to generate API key", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create API key",
+		})
+	}
+	key := &models.APIKey{
+		Name:               req.Name,
+		Prefix:             raw[:apiKeyDisplayLength],
+		KeyHash:            hashAPIKey(raw),
+		Role:               req.Role,
+		RateLimitPerMinute: req.RateLimitPerMinute,
+	}
+	if err := h.apiKeyRepo.Create(c.UserContext(), key); err != nil {
+		h.logger.Error("Failed to create API key", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create API key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateAPIKeyResponse{APIKey: key, Key: raw})
+}
+
+// ListAPIKeys returns all keys, revoked ones included, without the keys themselves
+func (h *Handlers) ListAPIKeys(c *fiber.Ctx) error {
+	if h.apiKeyRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API keys are not enabled",
+		})
+	}
+
+	keys, err := h.apiKeyRepo.List(c.UserContext())
+	if err != nil {
+		h.logger.Error("Failed to list API keys", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list API keys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"api_keys": keys,
+	})
+}
+
+// RevokeAPIKey disables a key immediately
+func (h *Handlers) RevokeAPIKey(c *fiber.Ctx) error {
+	if h.apiKeyRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API keys are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid API key id",
+		})
+	}
+
+	err = h.apiKeyRepo.Revoke(c.UserContext(), id, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API key not found or already revoked",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to revoke API key", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to revoke API key",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
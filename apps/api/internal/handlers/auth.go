@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// apiKeyPrefix starts every generated key, so leaked keys are easy to search for
+const apiKeyPrefix = "pc_"
+
+// apiKeyDisplayLength is the number of leading characters stored as models.APIKey.Prefix
+const apiKeyDisplayLength = 10
+
+// lastUsedInterval is how often the last use of a key is written back at most
+const lastUsedInterval = time.Minute
+
+// limiterSweepInterval is how often idle rate limiters are dropped at most
+const limiterSweepInterval = time.Minute
+
+// apiKeyLocal is the fiber.Ctx local holding the authenticated *models.APIKey
+const apiKeyLocal = "api_key"
+
+// Authenticator checks the API key of requests to protected routes and limits the
+// requests per key, and those of anonymous callers per IP. Keys are sent as
+// "X-API-Key: <key>" or "Authorization: Bearer <key>".
+type Authenticator struct {
+	keyRepo      repository.APIKeyStore
+	bootstrapKey string // ADMIN_API_KEY, accepted with the admin role without being stored
+	defaultLimit int    // requests per minute of keys without their own limit; 0 is unlimited
+	publicLimit  int    // defaultLimit of public keys
+	anonLimit    int    // requests per minute and IP of anonymous calls to public routes
+	logger       *zap.Logger
+	now          func() time.Time
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter // by "key:<id>" or "ip:<address>"
+	lastUsed map[uuid.UUID]time.Time
+	swept    time.Time // last sweep of limiters and lastUsed
+}
+
+func NewAuthenticator(keyRepo repository.APIKeyStore, bootstrapKey string, defaultLimit, publicLimit, anonLimit int, logger *zap.Logger) *Authenticator {
+	return &Authenticator{
+		keyRepo:      keyRepo,
+		bootstrapKey: bootstrapKey,
+		defaultLimit: defaultLimit,
+		publicLimit:  publicLimit,
+		anonLimit:    anonLimit,
+		logger:       logger,
+		now:          time.Now,
+		limiters:     make(map[string]*rate.Limiter),
+		lastUsed:     make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Require rejects requests without a valid key of at least role
+func (a *Authenticator) Require(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return a.authenticate(c, role)
+	}
+}
+
+// Admin protects the admin routes: reading them needs the read role, everything else
+// the admin role
+func (a *Authenticator) Admin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role := models.APIKeyRoleAdmin
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			role = models.APIKeyRoleRead
+		}
+		return a.authenticate(c, role)
+	}
+}
+
+// Public protects the public read endpoints (search, products, compare), which accept
+// keys of every role. Requests without a key are served anonymously unless required and
+// count against the rate limit of their IP; requests with one are checked and count
+// against its rate limit.
+func (a *Authenticator) Public(required bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !required && requestAPIKey(c) == "" {
+			if wait := a.take("ip:"+c.IP(), a.anonLimit); wait > 0 {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+					"error": "rate limit exceeded; send an API key for a higher limit",
+				})
+			}
+			return c.Next()
+		}
+		return a.authenticate(c, models.APIKeyRolePublic)
+	}
+}
+
+func (a *Authenticator) authenticate(c *fiber.Ctx, role string) error {
+	raw := requestAPIKey(c)
+	if raw == "" {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "API key required",
+		})
+	}
+
+	key, err := a.lookup(c, raw)
+	if err != nil {
+		a.logger.Error("Failed to look up API key", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to check API key",
+		})
+	}
+	if key == nil || key.RevokedAt != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid API key",
+		})
+	}
+	if !roleAllows(key.Role, role) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "API key role " + key.Role + " may not call this route",
+		})
+	}
+	if wait := a.reserve(key); wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "API key rate limit exceeded",
+		})
+	}
+
+	c.Locals(apiKeyLocal, key)
+	return c.Next()
+}
+
+// lookup returns the key matching raw, or nil if there is none
+func (a *Authenticator) lookup(c *fiber.Ctx, raw string) (*models.APIKey, error) {
+	if a.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(raw), []byte(a.bootstrapKey)) == 1 {
+		return &models.APIKey{Name: "ADMIN_API_KEY", Role: models.APIKeyRoleAdmin}, nil
+	}
+	if a.keyRepo == nil {
+		return nil, nil
+	}
+	key, err := a.keyRepo.GetByHash(c.UserContext(), hashAPIKey(raw))
+	// Revoked keys are returned for the caller to reject, without recording their use
+	if err != nil || key == nil || key.RevokedAt != nil {
+		return key, err
+	}
+	a.touch(c, key)
+	return key, nil
+}
+
+// touch records the use of a key, at most once per lastUsedInterval
+func (a *Authenticator) touch(c *fiber.Ctx, key *models.APIKey) {
+	now := a.now()
+	a.mu.Lock()
+	if now.Sub(a.lastUsed[key.ID]) < lastUsedInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastUsed[key.ID] = now
+	a.mu.Unlock()
+
+	if err := a.keyRepo.TouchLastUsed(c.UserContext(), key.ID, now); err != nil {
+		a.logger.Warn("Failed to record API key use", zap.String("api_key_id", key.ID.String()), zap.Error(err))
+	}
+}
+
+// reserve takes a request from the key's budget and returns how long to wait if it is
+// used up. The bootstrap key shares the uuid.Nil limiter.
+func (a *Authenticator) reserve(key *models.APIKey) time.Duration {
+	perMinute := a.defaultLimit
+	if key.Role == models.APIKeyRolePublic {
+		perMinute = a.publicLimit
+	}
+	if key.RateLimitPerMinute != nil {
+		perMinute = *key.RateLimitPerMinute
+	}
+	return a.take("key:"+key.ID.String(), perMinute)
+}
+
+// take takes a request from the budget of perMinute requests of id and returns how long
+// to wait if it is used up. perMinute <= 0 is unlimited.
+func (a *Authenticator) take(id string, perMinute int) time.Duration {
+	if perMinute <= 0 {
+		return 0
+	}
+
+	limit := rate.Limit(float64(perMinute) / 60)
+	a.mu.Lock()
+	a.sweep(a.now())
+	limiter, ok := a.limiters[id]
+	if !ok {
+		limiter = rate.NewLimiter(limit, perMinute)
+		a.limiters[id] = limiter
+	} else if limiter.Limit() != limit {
+		limiter.SetLimit(limit)
+		limiter.SetBurst(perMinute)
+	}
+	a.mu.Unlock()
+
+	reservation := limiter.ReserveN(a.now(), 1)
+	if delay := reservation.DelayFrom(a.now()); delay > 0 {
+		reservation.CancelAt(a.now())
+		return delay
+	}
+	return 0
+}
+
+// sweep drops the limiters whose budget has refilled, which behave like new ones, and
+// the last uses that no longer hold back a write. a.mu must be held.
+func (a *Authenticator) sweep(now time.Time) {
+	if now.Sub(a.swept) < limiterSweepInterval {
+		return
+	}
+	a.swept = now
+	for id, limiter := range a.limiters {
+		if limiter.TokensAt(now) >= float64(limiter.Burst()) {
+			delete(a.limiters, id)
+		}
+	}
+	for id, used := range a.lastUsed {
+		if now.Sub(used) >= lastUsedInterval {
+			delete(a.lastUsed, id)
+		}
+	}
+}
+
+// requestKeyID is the ID of the API key the request was authenticated with: uuid.Nil for
+// ADMIN_API_KEY and for requests without a key (API_AUTH_ENABLED=false)
+func requestKeyID(c *fiber.Ctx) uuid.UUID {
+	if key, ok := c.Locals(apiKeyLocal).(*models.APIKey); ok {
+		return key.ID
+	}
+	return uuid.Nil
+}
+
+// requestAPIKey returns the key sent with the request, or "" if there is none
+func requestAPIKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if key, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// roleRanks orders the roles; a key may call the routes of its role and of lower ones
+var roleRanks = map[string]int{
+	models.APIKeyRolePublic: 1,
+	models.APIKeyRoleRead:   2,
+	models.APIKeyRoleAdmin:  3,
+}
+
+func roleAllows(have, want string) bool {
+	return roleRanks[have] >= roleRanks[want] && roleRanks[have] > 0
+}
+
+// newAPIKey returns a random key
+func newAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+const testBootstrapKey = "bootstrap-key-with-at-least-32-characters"
+
+// newAuthTestApp protects the API key routes and a stand-in for resolve-url
+func newAuthTestApp(t *testing.T, store *memory.Store) *fiber.App {
+	h := newTestHandlers(t, store)
+	h.EnableAPIKeys(store.APIKeys())
+	auth := NewAuthenticator(store.APIKeys(), testBootstrapKey, 0, 0, 0, zap.NewNop())
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	api := app.Group("/api")
+	api.Use("/admin", auth.Admin())
+	api.Get("/search", h.Search)
+	api.Post("/resolve-url", auth.Require(models.APIKeyRoleRead), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	api.Get("/admin/api-keys", h.ListAPIKeys)
+	api.Post("/admin/api-keys", h.CreateAPIKey)
+	api.Delete("/admin/api-keys/:id", h.RevokeAPIKey)
+	return app
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	store := memory.New()
+	app := newAuthTestApp(t, store)
+
+	if status, _ := doKeyRequest(t, app, "GET", "/api/admin/api-keys", "", ""); status != fiber.StatusUnauthorized {
+		t.Errorf("no key status = %d, want 401", status)
+	}
+	if status, _ := doKeyRequest(t, app, "GET", "/api/admin/api-keys", "pc_unknown", ""); status != fiber.StatusUnauthorized {
+		t.Errorf("unknown key status = %d, want 401", status)
+	}
+	if status, _ := doKeyRequest(t, app, "GET", "/api/search?query=sony", "", ""); status != fiber.StatusOK {
+		t.Errorf("public route status = %d, want 200", status)
+	}
+
+	status, body := doKeyRequest(t, app, "POST", "/api/admin/api-keys", testBootstrapKey, `{"name":"dashboard","role":"read"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("create status = %d: %s", status, body)
+	}
+	var created CreateAPIKeyResponse
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Key, apiKeyPrefix) || !strings.HasPrefix(created.Key, created.Prefix) || created.Role != models.APIKeyRoleRead {
+		t.Errorf("created = %+v", created)
+	}
+	readKey := created.Key
+
+	// The read role may read admin routes and resolve URLs, but not change anything
+	status, body = doKeyRequest(t, app, "GET", "/api/admin/api-keys", readKey, "")
+	if status != fiber.StatusOK || strings.Contains(body, readKey) || strings.Contains(body, hashAPIKey(readKey)) {
+		t.Errorf("list = %d %s, want the keys without secrets", status, body)
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/resolve-url", "Bearer "+readKey, ""); status != fiber.StatusOK {
+		t.Errorf("resolve-url with a bearer read key status = %d, want 200", status)
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/admin/api-keys", readKey, `{"name":"mine","role":"admin"}`); status != fiber.StatusForbidden {
+		t.Errorf("create with a read key status = %d, want 403", status)
+	}
+	if keys, _ := store.APIKeys().List(context.Background()); len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("keys = %+v, want the read key's use recorded", keys)
+	}
+
+	if status, _ := doKeyRequest(t, app, "DELETE", "/api/admin/api-keys/"+created.ID.String(), testBootstrapKey, ""); status != fiber.StatusNoContent {
+		t.Errorf("revoke status = %d, want 204", status)
+	}
+	if status, _ := doKeyRequest(t, app, "GET", "/api/admin/api-keys", readKey, ""); status != fiber.StatusUnauthorized {
+		t.Errorf("revoked key status = %d, want 401", status)
+	}
+	if status, _ := doKeyRequest(t, app, "DELETE", "/api/admin/api-keys/"+created.ID.String(), testBootstrapKey, ""); status != fiber.StatusNotFound {
+		t.Errorf("second revoke status = %d, want 404", status)
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/admin/api-keys", testBootstrapKey, `{"name":"x","role":"owner"}`); status != fiber.StatusBadRequest {
+		t.Errorf("unknown role status = %d, want 400", status)
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T) {
+	store := memory.New()
+	app := newAuthTestApp(t, store)
+
+	_, body := doKeyRequest(t, app, "POST", "/api/admin/api-keys", testBootstrapKey, `{"name":"batch","role":"read","rate_limit_per_minute":2}`)
+	var created CreateAPIKeyResponse
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if status, _ := doKeyRequest(t, app, "GET", "/api/admin/api-keys", created.Key, ""); status != fiber.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, status)
+		}
+	}
+	resp := keyRequest(t, app, "GET", "/api/admin/api-keys", created.Key, "")
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Errorf("third request = %d (Retry-After %q), want 429", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	// Other keys have their own budget; the bootstrap key is unlimited here
+	if status, _ := doKeyRequest(t, app, "GET", "/api/admin/api-keys", testBootstrapKey, ""); status != fiber.StatusOK {
+		t.Errorf("bootstrap key status = %d, want 200", status)
+	}
+}
+
+// doKeyRequest sends the request with key as X-API-Key, or as the Authorization header
+// if it starts with "Bearer "
+func doKeyRequest(t *testing.T, app *fiber.App, method, path, key, body string) (int, string) {
+	t.Helper()
+	resp := keyRequest(t, app, method, path, key, body)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(respBody)
+}
+
+func keyRequest(t *testing.T, app *fiber.App, method, path, key, body string) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	}
+	switch {
+	case strings.HasPrefix(key, "Bearer "):
+		req.Header.Set(fiber.HeaderAuthorization, key)
+	case key != "":
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAPIKeyPublicTier(t *testing.T) {
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	h.EnableAPIKeys(store.APIKeys())
+	auth := NewAuthenticator(store.APIKeys(), testBootstrapKey, 0, 1, 1, zap.NewNop())
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	api := app.Group("/api")
+	api.Use("/admin", auth.Admin())
+	api.Get("/search", auth.Public(false), h.Search)
+	api.Get("/search/required", auth.Public(true), h.Search)
+	api.Post("/resolve-url", auth.Require(models.APIKeyRoleRead), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	api.Get("/admin/api-keys", h.ListAPIKeys)
+	api.Post("/admin/api-keys", h.CreateAPIKey)
+
+	_, body := doKeyRequest(t, app, "POST", "/api/admin/api-keys", testBootstrapKey, `{"name":"partner","role":"public"}`)
+	var created CreateAPIKeyResponse
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Role != models.APIKeyRolePublic {
+		t.Fatalf("created = %s, want a public key", body)
+	}
+	_, body = doKeyRequest(t, app, "POST", "/api/admin/api-keys", testBootstrapKey, `{"name":"dashboard","role":"read"}`)
+	var readKey CreateAPIKeyResponse
+	if err := json.Unmarshal([]byte(body), &readKey); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, method, path, key string
+		want                    int
+	}{
+		{"anonymous search", "GET", "/api/search?query=sony", "", fiber.StatusOK},
+		{"search with an unknown key", "GET", "/api/search?query=sony", "pc_unknown", fiber.StatusUnauthorized},
+		{"anonymous search where a key is required", "GET", "/api/search/required?query=sony", "", fiber.StatusUnauthorized},
+		{"public key where a key is required", "GET", "/api/search/required?query=sony", created.Key, fiber.StatusOK},
+		{"read key on a public route", "GET", "/api/search/required?query=sony", readKey.Key, fiber.StatusOK},
+		{"public key on an admin GET", "GET", "/api/admin/api-keys", created.Key, fiber.StatusForbidden},
+		{"public key on resolve-url", "POST", "/api/resolve-url", created.Key, fiber.StatusForbidden},
+		// The public default limit of 1 per minute is used up by now
+		{"public key over its quota", "GET", "/api/search?query=sony", created.Key, fiber.StatusTooManyRequests},
+		{"read key keeps the unlimited default", "GET", "/api/search?query=sony", readKey.Key, fiber.StatusOK},
+		// So is the anonymous limit of 1 per minute and IP of the first request
+		{"anonymous search over the IP quota", "GET", "/api/search?query=sony", "", fiber.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		if status, body := doKeyRequest(t, app, tt.method, tt.path, tt.key, ""); status != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, status, tt.want, body)
+		}
+	}
+}
+
+func TestAuthenticatorDropsIdleLimiters(t *testing.T) {
+	auth := NewAuthenticator(nil, "", 0, 0, 0, zap.NewNop())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	auth.now = func() time.Time { return now }
+
+	auth.take("ip:192.0.2.2", 60)
+	now = start.Add(50 * time.Second)
+	for i := 0; i < 3; i++ {
+		if wait := auth.take("ip:192.0.2.1", 2); i < 2 && wait > 0 {
+			t.Fatalf("request %d waits %v", i, wait)
+		} else if i == 2 && wait == 0 {
+			t.Fatal("third request within the minute was allowed")
+		}
+	}
+
+	// At the next sweep the first budget is still refilling; the second one is full
+	now = start.Add(time.Minute)
+	auth.take("ip:192.0.2.3", 60)
+	if _, ok := auth.limiters["ip:192.0.2.2"]; ok {
+		t.Error("the idle limiter was kept")
+	}
+	if _, ok := auth.limiters["ip:192.0.2.1"]; !ok {
+		t.Error("the exhausted limiter was dropped")
+	}
+}
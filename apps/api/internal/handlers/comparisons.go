@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	maxComparisonProducts = 20  // the most products a comparison set may hold
+	maxComparisonSets     = 100 // the most comparison sets an API key may save
+)
+
+// EnableComparisonSets serves the /api/comparisons routes
+func (h *Handlers) EnableComparisonSets(setRepo repository.ComparisonSetStore) {
+	h.comparisonRepo = setRepo
+}
+
+type CreateComparisonSetRequest struct {
+	Name       string   `json:"name"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// ComparisonProduct is a product of a comparison set with the cheapest published offer
+// of each source
+type ComparisonProduct struct {
+	*models.Product
+	MinPriceCents *int             `json:"min_price_cents,omitempty"`
+	Offers        []*OfferResponse `json:"offers"`
+}
+
+// CreateComparisonSet saves a named list of products for the request's API key
+func (h *Handlers) CreateComparisonSet(c *fiber.Ctx) error {
+	if h.comparisonRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison sets are not enabled",
+		})
+	}
+	owner := requestKeyID(c)
+	if owner == uuid.Nil {
+		return comparisonKeyRequired(c)
+	}
+
+	var req CreateComparisonSetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+	var productIDs []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, value := range req.ProductIDs {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid product id: " + value,
+			})
+		}
+		if !seen[id] {
+			seen[id] = true
+			productIDs = append(productIDs, id)
+		}
+	}
+	if len(productIDs) == 0 || len(productIDs) > maxComparisonProducts {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "product_ids must list 1 to 20 products",
+		})
+	}
+
+	summaries, err := h.productRepo.GetSummaries(c.UserContext(), productIDs)
+	if err != nil {
+		h.logger.Error("Failed to get products", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get products",
+		})
+	}
+	if len(summaries) != len(productIDs) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+
+	sets, err := h.comparisonRepo.ListByOwner(c.UserContext(), owner)
+	if err != nil {
+		h.logger.Error("Failed to list comparison sets", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create comparison set",
+		})
+	}
+	if len(sets) >= maxComparisonSets {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "an API key may save at most 100 comparison sets; delete one first",
+		})
+	}
+
+	token, err := newURLToken()
+	if err != nil {
+		h.logger.Error("Failed to generate share token", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create comparison set",
+		})
+	}
+	set := &models.ComparisonSet{
+		OwnerKeyID: owner,
+		Name:       req.Name,
+		ProductIDs: productIDs,
+		ShareToken: token,
+	}
+	if err := h.comparisonRepo.Create(c.UserContext(), set); err != nil {
+		h.logger.Error("Failed to create comparison set", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create comparison set",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"comparison": set,
+		"share_url":  shareURL(set),
+	})
+}
+
+// ListComparisonSets returns the sets of the request's API key, without offers
+func (h *Handlers) ListComparisonSets(c *fiber.Ctx) error {
+	if h.comparisonRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison sets are not enabled",
+		})
+	}
+	owner := requestKeyID(c)
+	if owner == uuid.Nil {
+		return comparisonKeyRequired(c)
+	}
+
+	sets, err := h.comparisonRepo.ListByOwner(c.UserContext(), owner)
+	if err != nil {
+		h.logger.Error("Failed to list comparison sets", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list comparison sets",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"comparisons": sets,
+	})
+}
+
+// GetComparisonSet returns a set of the request's API key with the current cheapest
+// offers of its products
+func (h *Handlers) GetComparisonSet(c *fiber.Ctx) error {
+	if h.comparisonRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison sets are not enabled",
+		})
+	}
+	owner := requestKeyID(c)
+	if owner == uuid.Nil {
+		return comparisonKeyRequired(c)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid comparison set id",
+		})
+	}
+
+	set, err := h.comparisonRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get comparison set", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get comparison set",
+		})
+	}
+	// Other keys' sets are only readable through their share URL
+	if set == nil || set.OwnerKeyID != owner {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison set not found",
+		})
+	}
+	return h.comparisonResponse(c, set)
+}
+
+// GetSharedComparisonSet is GetComparisonSet for anyone with the set's share token
+func (h *Handlers) GetSharedComparisonSet(c *fiber.Ctx) error {
+	if h.comparisonRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison sets are not enabled",
+		})
+	}
+
+	set, err := h.comparisonRepo.GetByShareToken(c.UserContext(), c.Params("token"))
+	if err != nil {
+		h.logger.Error("Failed to get comparison set", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get comparison set",
+		})
+	}
+	if set == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison set not found",
+		})
+	}
+	return h.comparisonResponse(c, set)
+}
+
+// DeleteComparisonSet deletes a set of the request's API key; its share URL stops working
+func (h *Handlers) DeleteComparisonSet(c *fiber.Ctx) error {
+	if h.comparisonRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison sets are not enabled",
+		})
+	}
+	owner := requestKeyID(c)
+	if owner == uuid.Nil {
+		return comparisonKeyRequired(c)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid comparison set id",
+		})
+	}
+
+	err = h.comparisonRepo.Delete(c.UserContext(), id, owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "comparison set not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete comparison set", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete comparison set",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// comparisonKeyRequired rejects requests without an API key. Without API_AUTH_ENABLED
+// every caller would own the same sets.
+func comparisonKeyRequired(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "comparison sets need an API key; enable API_AUTH_ENABLED",
+	})
+}
+
+// comparisonResponse loads the products of a set and the cheapest published offer of
+// each source for all of them in one query. Products merged or deleted since the set was
+// saved are listed in missing_product_ids. ?currency= converts the totals like the
+// offers endpoint.
+func (h *Handlers) comparisonResponse(c *fiber.Ctx, set *models.ComparisonSet) error {
+	currency, rate, err := h.displayCurrency(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	summaries, err := h.productRepo.GetSummaries(c.UserContext(), set.ProductIDs)
+	if err != nil {
+		h.logger.Error("Failed to get products", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get comparison set",
+		})
+	}
+	offers, err := h.offerRepo.GetCheapestBySource(c.UserContext(), set.ProductIDs)
+	if err != nil {
+		h.logger.Error("Failed to get offers", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get comparison set",
+		})
+	}
+	h.setOfferURLs(c, offers)
+	offersByProduct := make(map[uuid.UUID][]*OfferResponse)
+	for _, response := range h.offerResponses(offers) {
+		if currency != "" {
+			response.ConvertTotals(currency, rate)
+		}
+		offersByProduct[response.ProductID] = append(offersByProduct[response.ProductID], response)
+	}
+
+	byID := make(map[uuid.UUID]*repository.ProductSummary, len(summaries))
+	for _, summary := range summaries {
+		byID[summary.Product.ID] = summary
+	}
+	products := make([]ComparisonProduct, 0, len(set.ProductIDs))
+	missing := []uuid.UUID{}
+	for _, id := range set.ProductIDs {
+		summary, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		productOffers := offersByProduct[id]
+		if productOffers == nil {
+			productOffers = []*OfferResponse{}
+		}
+		products = append(products, ComparisonProduct{
+			Product:       summary.Product,
+			MinPriceCents: summary.MinPriceCents,
+			Offers:        productOffers,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"comparison":          set,
+		"share_url":           shareURL(set),
+		"products":            products,
+		"missing_product_ids": missing,
+	})
+}
+
+// shareURL is the path of a set's public read-only view
+func shareURL(set *models.ComparisonSet) string {
+	return "/api/comparisons/shared/" + set.ShareToken
+}
+
+// newURLToken returns a random, URL-safe token for share and confirmation links
+func newURLToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
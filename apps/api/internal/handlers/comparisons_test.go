@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestComparisonSets(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	h.EnableAPIKeys(store.APIKeys())
+	h.EnableComparisonSets(store.ComparisonSets())
+	auth := NewAuthenticator(store.APIKeys(), testBootstrapKey, 0, 0, 0, zap.NewNop())
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	api := app.Group("/api")
+	api.Use("/admin", auth.Admin())
+	api.Post("/admin/api-keys", h.CreateAPIKey)
+	api.Get("/comparisons/shared/:token", auth.Public(false), h.GetSharedComparisonSet)
+	api.Post("/comparisons", auth.Require(models.APIKeyRolePublic), h.CreateComparisonSet)
+	api.Get("/comparisons", auth.Require(models.APIKeyRolePublic), h.ListComparisonSets)
+	api.Get("/comparisons/:id", auth.Require(models.APIKeyRolePublic), h.GetComparisonSet)
+	api.Delete("/comparisons/:id", auth.Require(models.APIKeyRolePublic), h.DeleteComparisonSet)
+
+	var keys []string
+	var keyIDs []uuid.UUID
+	for _, name := range []string{"alice", "bob"} {
+		_, body := doKeyRequest(t, app, "POST", "/api/admin/api-keys", testBootstrapKey, `{"name":"`+name+`","role":"public"}`)
+		var created CreateAPIKeyResponse
+		if err := json.Unmarshal([]byte(body), &created); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, created.Key)
+		keyIDs = append(keyIDs, created.ID)
+	}
+	alice, bob := keys[0], keys[1]
+
+	var productIDs []string
+	for _, title := range []string{"Sony WH-1000XM5", "Bose QuietComfort Ultra"} {
+		product := &models.Product{Title: title}
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		productIDs = append(productIDs, `"`+product.ID.String()+`"`)
+		for i, offer := range []*models.Offer{
+			{Source: "amazon", Seller: "a", TotalToUSAmount: 32000},
+			{Source: "amazon", Seller: "b", TotalToUSAmount: 30000},
+			{Source: "walmart", Seller: "c", TotalToUSAmount: 31000},
+		} {
+			offer.ProductID = product.ID
+			if err := store.Offers().Create(ctx, offer); err != nil {
+				t.Fatalf("offer %d: %v", i, err)
+			}
+		}
+	}
+
+	if status, _ := doKeyRequest(t, app, "POST", "/api/comparisons", "", `{"name":"x","product_ids":[`+productIDs[0]+`]}`); status != fiber.StatusUnauthorized {
+		t.Errorf("create without a key status = %d, want 401", status)
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/comparisons", alice, `{"name":"x","product_ids":["`+uuid.New().String()+`"]}`); status != fiber.StatusNotFound {
+		t.Errorf("create with an unknown product status = %d, want 404", status)
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/comparisons", alice, `{"name":"x","product_ids":[]}`); status != fiber.StatusBadRequest {
+		t.Errorf("create without products status = %d, want 400", status)
+	}
+
+	status, body := doKeyRequest(t, app, "POST", "/api/comparisons", alice, `{"name":"Headphones","product_ids":[`+strings.Join(productIDs, ",")+`]}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("create status = %d: %s", status, body)
+	}
+	var created struct {
+		Comparison models.ComparisonSet `json:"comparison"`
+		ShareURL   string               `json:"share_url"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	setPath := "/api/comparisons/" + created.Comparison.ID.String()
+
+	status, body = doKeyRequest(t, app, "GET", setPath, alice, "")
+	if status != fiber.StatusOK {
+		t.Fatalf("get status = %d: %s", status, body)
+	}
+	// One offer per source and product, the cheapest of each
+	if strings.Count(body, `"source":"amazon"`) != 2 || strings.Count(body, `"source":"walmart"`) != 2 ||
+		strings.Contains(body, `"total_to_us_amount":32000`) || !strings.Contains(body, `"min_price_cents":30000`) {
+		t.Errorf("get body %s, want the cheapest offer of each source", body)
+	}
+
+	if status, body := doKeyRequest(t, app, "GET", "/api/comparisons", bob, ""); status != fiber.StatusOK || body != `{"comparisons":[]}` {
+		t.Errorf("bob's list = %d %s, want no sets", status, body)
+	}
+	if status, _ := doKeyRequest(t, app, "GET", setPath, bob, ""); status != fiber.StatusNotFound {
+		t.Errorf("get by another key status = %d, want 404", status)
+	}
+	if status, _ := doKeyRequest(t, app, "DELETE", setPath, bob, ""); status != fiber.StatusNotFound {
+		t.Errorf("delete by another key status = %d, want 404", status)
+	}
+	if status, body := doKeyRequest(t, app, "GET", created.ShareURL, "", ""); status != fiber.StatusOK || !strings.Contains(body, `"name":"Headphones"`) {
+		t.Errorf("shared = %d %s, want the set without a key", status, body)
+	}
+
+	if status, _ := doKeyRequest(t, app, "DELETE", setPath, alice, ""); status != fiber.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", status)
+	}
+	if status, _ := doKeyRequest(t, app, "GET", created.ShareURL, "", ""); status != fiber.StatusNotFound {
+		t.Errorf("shared after delete status = %d, want 404", status)
+	}
+
+	// Each key may save a bounded number of sets
+	for range maxComparisonSets {
+		set := &models.ComparisonSet{OwnerKeyID: keyIDs[0], Name: "filler", ProductIDs: created.Comparison.ProductIDs, ShareToken: uuid.NewString()}
+		if err := store.ComparisonSets().Create(ctx, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/comparisons", alice, `{"name":"x","product_ids":[`+productIDs[0]+`]}`); status != fiber.StatusConflict {
+		t.Errorf("create past the limit status = %d, want 409", status)
+	}
+	if status, _ := doKeyRequest(t, app, "POST", "/api/comparisons", bob, `{"name":"x","product_ids":[`+productIDs[0]+`]}`); status != fiber.StatusCreated {
+		t.Errorf("create by another key status = %d, want 201", status)
+	}
+}
+
+func TestComparisonSetsNeedAPIKey(t *testing.T) {
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	h.EnableComparisonSets(store.ComparisonSets())
+
+	// Without API_AUTH_ENABLED all callers would share the owner uuid.Nil
+	app := fiber.New()
+	app.Post("/api/comparisons", h.CreateComparisonSet)
+	app.Get("/api/comparisons", h.ListComparisonSets)
+	for _, method := range []string{"POST", "GET"} {
+		if status, _ := doKeyRequest(t, app, method, "/api/comparisons", "", `{"name":"x","product_ids":["`+uuid.NewString()+`"]}`); status != fiber.StatusForbidden {
+			t.Errorf("%s without a key status = %d, want 403", method, status)
+		}
+	}
+}
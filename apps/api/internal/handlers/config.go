@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/config"
+)
+
+// EnableConfigReload exposes the config watcher on POST /api/admin/config/reload
+func (h *Handlers) EnableConfigReload(watcher *config.Watcher) {
+	h.configWatcher = watcher
+}
+
+// ReloadConfig re-reads the environment and applies the reloadable settings (rate limits,
+// shipping fees, provider toggles, log level), like sending SIGHUP to the process
+func (h *Handlers) ReloadConfig(c *fiber.Ctx) error {
+	if h.configWatcher == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "config reload is not enabled",
+		})
+	}
+
+	cfg, err := h.configWatcher.Reload(c.UserContext())
+	if err != nil {
+		h.logger.Warn("Config reload rejected", zap.Error(err))
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":    "invalid configuration, the running configuration was kept",
+			"problems": strings.Split(err.Error(), "\n"),
+		})
+	}
+	h.logger.Info("Config reloaded", zap.String("trigger", "api"), zap.String("log_level", cfg.LogLevel))
+
+	return c.JSON(fiber.Map{
+		"status":      "reloaded",
+		"reloaded_at": time.Now(),
+		"providers":   h.providerManager.List(),
+		"log_level":   cfg.LogLevel,
+	})
+}
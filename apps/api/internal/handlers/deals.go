@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	maxPriceDropWindow = 90 * 24 * time.Hour
+	maxPriceDrops      = 100
+)
+
+// EnablePriceDrops serves GET /api/deals/price-drops from the recorded price changes
+func (h *Handlers) EnablePriceDrops(priceChangeRepo repository.OfferPriceChangeStore) {
+	h.priceDropRepo = priceChangeRepo
+}
+
+// GetPriceDrops returns the products whose offers dropped the most in the last window
+// (default 24h, e.g. "6h" or "7d"), by at least min_drop_pct percent (default 10),
+// largest drop first
+func (h *Handlers) GetPriceDrops(c *fiber.Ctx) error {
+	if h.priceDropRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "price drops are not enabled",
+		})
+	}
+	window, err := parseWindow(c.Query("window", "24h"))
+	if err != nil || window <= 0 || window > maxPriceDropWindow {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "window must be a positive duration of at most 90d, e.g. 24h or 7d",
+		})
+	}
+	minDrop, err := strconv.ParseFloat(c.Query("min_drop_pct", "10"), 64)
+	if err != nil || minDrop <= 0 || minDrop > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "min_drop_pct must be a number greater than 0 and at most 100",
+		})
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 || limit > maxPriceDrops {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and 100",
+		})
+	}
+
+	since := time.Now().Add(-window)
+	drops, err := h.priceDropRepo.ListDropsSince(c.UserContext(), since, minDrop, limit)
+	if err != nil {
+		h.logger.Error("Failed to list price drops", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get price drops",
+		})
+	}
+	// Like offers, drops link to the product page unless raw_urls=true (see setOfferURLs)
+	if !c.QueryBool("raw_urls") {
+		for _, drop := range drops {
+			if drop.URL != nil {
+				link := h.affiliateTags.Link(*drop.URL, canonicalurl.ProductURL(*drop.URL))
+				drop.URL = &link
+			}
+		}
+	}
+	return c.JSON(fiber.Map{
+		"since":        since,
+		"min_drop_pct": minDrop,
+		"drops":        drops,
+	})
+}
+
+// parseWindow parses a time.ParseDuration duration or a number of days ("7d"). Days
+// past maxPriceDropWindow are rejected before they can overflow a time.Duration.
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n > int(maxPriceDropWindow/(24*time.Hour)) {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestGetPriceDrops(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	// Each offer is stored at its current total after the changes in totals
+	for _, item := range []struct {
+		title  string
+		seller string
+		totals []int
+	}{
+		{"Sony WH-1000XM5", "steady", []int{10000, 9000, 7000}},
+		{"Sony WH-1000XM5", "other", []int{5000, 4900}},
+		{"Bose QuietComfort", "seller", []int{20000, 18000}},
+		{"Nintendo Switch", "seller", []int{10000, 12000}},
+	} {
+		product, err := store.Products().FindByTitle(ctx, item.title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product == nil {
+			product = &models.Product{Title: item.title}
+			if err := store.Products().Create(ctx, product); err != nil {
+				t.Fatal(err)
+			}
+		}
+		current := item.totals[len(item.totals)-1]
+		offer := &models.Offer{ProductID: product.ID, Source: "demo", Seller: item.seller, PriceAmount: current, Currency: "USD", TotalToUSAmount: current}
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < len(item.totals); i++ {
+			change := &models.OfferPriceChange{OfferID: offer.ID, ProductID: product.ID, Source: "demo", Seller: item.seller,
+				OldTotalToUSAmount: item.totals[i-1], NewTotalToUSAmount: item.totals[i]}
+			if err := store.OfferPriceChanges().Record(ctx, change); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/deals/price-drops", h.GetPriceDrops)
+
+	if code, _ := doRequest(t, app, "GET", "/api/deals/price-drops"); code != fiber.StatusNotFound {
+		t.Errorf("price drops without EnablePriceDrops = %d, want 404", code)
+	}
+	h.EnablePriceDrops(store.OfferPriceChanges())
+
+	_, body := doRequest(t, app, "GET", "/api/deals/price-drops?window=7d")
+	// The first total in the window against the current one, the largest drop per product
+	for _, want := range []string{
+		`"product_title":"Sony WH-1000XM5"`, `"seller":"steady"`, `"old_total_to_us_amount":10000`, `"new_total_to_us_amount":7000`, `"drop_percent":30`,
+		`"product_title":"Bose QuietComfort"`, `"drop_percent":10`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("price drops = %s, want %s", body, want)
+		}
+	}
+	if strings.Index(body, "Sony") > strings.Index(body, "Bose") || strings.Contains(body, "Nintendo") || strings.Contains(body, `"seller":"other"`) {
+		t.Errorf("price drops = %s, want Sony then Bose only", body)
+	}
+
+	if _, body := doRequest(t, app, "GET", "/api/deals/price-drops?min_drop_pct=20"); strings.Contains(body, "Bose") || !strings.Contains(body, "Sony") {
+		t.Errorf("price drops of at least 20%% = %s, want Sony only", body)
+	}
+	// 281474976710657 days overflow to exactly one day
+	for _, query := range []string{"window=abc", "window=120d", "window=281474976710657d", "window=-1h", "min_drop_pct=0", "min_drop_pct=101", "limit=0"} {
+		if code, body := doRequest(t, app, "GET", "/api/deals/price-drops?"+query); code != fiber.StatusBadRequest {
+			t.Errorf("price drops?%s = %d %s, want 400", query, code, body)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+)
+
+// EnableEmbeddingBackfill allows POST /api/admin/jobs/backfill_embeddings, for when
+// EMBEDDING_BACKEND is set
+func (h *Handlers) EnableEmbeddingBackfill() {
+	h.embeddings = true
+}
+
+// BackfillEmbeddings enqueues the backfill_embeddings job, which embeds the titles of
+// products ingested before embedding matching was enabled or the model changed
+func (h *Handlers) BackfillEmbeddings(c *fiber.Ctx) error {
+	if !h.embeddings {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "embedding matching is not enabled",
+		})
+	}
+
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeBackfillEmbeddings, &jobs.BackfillEmbeddingsPayload{})
+	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to enqueue job",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id": info.ID,
+		"status": "enqueued",
+	})
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	feedBatchSize = 500
+	// sitemapMaxURLs is the limit of the sitemap protocol; larger catalogs are split into
+	// pages listed by a sitemap index
+	sitemapMaxURLs = 50000
+)
+
+// EnableCatalogFeeds serves GET /sitemap.xml and the product feeds
+// (GET /feeds/products.xml and /feeds/products.csv), linking to the compare pages of the
+// web app at siteURL (SITE_URL). The catalog is read again once the last read is older
+// than ttl (FEED_CACHE_TTL_SECONDS).
+func (h *Handlers) EnableCatalogFeeds(siteURL string, ttl time.Duration) {
+	h.siteURL = strings.TrimRight(siteURL, "/")
+	h.catalogCache = &catalogCache{ttl: ttl}
+}
+
+// catalogCache holds the sitemap URLs and feed items of the last catalog walk. The feeds
+// need no API key, so every request, page and query string is served from it.
+type catalogCache struct {
+	ttl time.Duration
+	mu  sync.Mutex // also serializes walks, so concurrent requests share one
+
+	sitemapURLs    []sitemapURL
+	sitemapBuiltAt time.Time
+	feedItems      []feedItem
+	feedBuiltAt    time.Time
+}
+
+// cachedSitemapURLs returns the URLs of the sitemap, walking the catalog when the cached
+// ones are older than the TTL
+func (h *Handlers) cachedSitemapURLs(ctx context.Context) ([]sitemapURL, error) {
+	cache := h.catalogCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if !cache.sitemapBuiltAt.IsZero() && time.Since(cache.sitemapBuiltAt) < cache.ttl {
+		return cache.sitemapURLs, nil
+	}
+	urls, err := h.catalogSitemapURLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.sitemapURLs, cache.sitemapBuiltAt = urls, time.Now()
+	return urls, nil
+}
+
+// cachedFeedItems is cachedSitemapURLs for the product feed items
+func (h *Handlers) cachedFeedItems(ctx context.Context) ([]feedItem, error) {
+	cache := h.catalogCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if !cache.feedBuiltAt.IsZero() && time.Since(cache.feedBuiltAt) < cache.ttl {
+		return cache.feedItems, nil
+	}
+	items, err := h.catalogFeedItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.feedItems, cache.feedBuiltAt = items, time.Now()
+	return items, nil
+}
+
+// productPageURL is the web app's compare page of a product
+func (h *Handlers) productPageURL(id uuid.UUID) string {
+	return h.siteURL + "/compare?productId=" + id.String()
+}
+
+// walkCatalog calls fn with the summaries of the products that have published offers,
+// in batches ordered by product ID
+func (h *Handlers) walkCatalog(ctx context.Context, fn func([]*repository.ProductSummary) error) error {
+	afterID := uuid.Nil
+	for {
+		summaries, err := h.productRepo.ListSummariesAfter(ctx, afterID, feedBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(summaries) == 0 {
+			return nil
+		}
+		afterID = summaries[len(summaries)-1].Product.ID
+
+		listed := make([]*repository.ProductSummary, 0, len(summaries))
+		for _, summary := range summaries {
+			// A compare page without offers is not worth indexing or advertising
+			if summary.OfferCount > 0 {
+				listed = append(listed, summary)
+			}
+		}
+		if err := fn(listed); err != nil {
+			return err
+		}
+	}
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Sitemap lists the compare pages of the products with offers. Above 50,000 products it
+// is a sitemap index of ?page=1, ?page=2, ... The web app proxies its
+// /sitemap.xml here (see next.config.js), so the index links to SITE_URL.
+func (h *Handlers) Sitemap(c *fiber.Ctx) error {
+	if h.siteURL == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "catalog feeds are not enabled",
+		})
+	}
+	page := c.QueryInt("page", 0)
+	if page < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "page must be a positive integer",
+		})
+	}
+
+	urls, err := h.cachedSitemapURLs(c.UserContext())
+	if err != nil {
+		h.logger.Error("Failed to list products for the sitemap", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to build sitemap",
+		})
+	}
+
+	pages := (len(urls) + sitemapMaxURLs - 1) / sitemapMaxURLs
+	var document any
+	switch {
+	case page == 0 && pages <= 1:
+		document = sitemapURLSet{XMLNS: sitemapNamespace, URLs: urls}
+	case page == 0:
+		index := sitemapIndex{XMLNS: sitemapNamespace}
+		for i := 1; i <= pages; i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", h.siteURL, i)})
+		}
+		document = index
+	case page <= pages:
+		end := min(page*sitemapMaxURLs, len(urls))
+		document = sitemapURLSet{XMLNS: sitemapNamespace, URLs: urls[(page-1)*sitemapMaxURLs : end]}
+	default:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "sitemap page not found",
+		})
+	}
+	return sendXML(c, document)
+}
+
+// catalogSitemapURLs returns the home and search pages and the compare page of every
+// product with offers
+func (h *Handlers) catalogSitemapURLs(ctx context.Context) ([]sitemapURL, error) {
+	urls := []sitemapURL{{Loc: h.siteURL + "/"}, {Loc: h.siteURL + "/search"}}
+	err := h.walkCatalog(ctx, func(summaries []*repository.ProductSummary) error {
+		for _, summary := range summaries {
+			urls = append(urls, sitemapURL{
+				Loc:     h.productPageURL(summary.Product.ID),
+				LastMod: summary.Product.UpdatedAt.UTC().Format(time.DateOnly),
+			})
+		}
+		return nil
+	})
+	return urls, err
+}
+
+// feedItem is one product of the product feeds, with the attributes of a Google
+// Merchant Center product data feed
+type feedItem struct {
+	ID           string       `xml:"g:id"`
+	Title        string       `xml:"title"`
+	Description  string       `xml:"description"`
+	Link         string       `xml:"link"`
+	ImageLink    string       `xml:"g:image_link,omitempty"`
+	Availability string       `xml:"g:availability"`
+	Price        string       `xml:"g:price"`
+	Brand        string       `xml:"g:brand,omitempty"`
+	GTIN         string       `xml:"g:gtin,omitempty"`
+	MPN          string       `xml:"g:mpn,omitempty"`
+	Condition    string       `xml:"g:condition"`
+	Shipping     feedShipping `xml:"g:shipping"`
+}
+
+type feedShipping struct {
+	Country string `xml:"g:country"`
+	Price   string `xml:"g:price"`
+}
+
+// feedItems builds the feed items of a batch of products from their cheapest offer: the
+// cheapest in-stock one, or the cheapest of all if none is in stock. Prices are the US
+// totals without shipping, which is its own attribute.
+func (h *Handlers) feedItems(ctx context.Context, summaries []*repository.ProductSummary) ([]feedItem, error) {
+	if len(summaries) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.Product.ID)
+	}
+	offers, err := h.offerRepo.GetCheapestBySource(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offers: %w", err)
+	}
+	identifiers, err := h.identifierRepo.ListByProductIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identifiers: %w", err)
+	}
+
+	cheapest := make(map[uuid.UUID]*models.Offer)
+	for _, offer := range offers {
+		best, ok := cheapest[offer.ProductID]
+		if !ok || (offer.InStock && !best.InStock) ||
+			(offer.InStock == best.InStock && offer.TotalToUSAmount < best.TotalToUSAmount) {
+			cheapest[offer.ProductID] = offer
+		}
+	}
+
+	items := make([]feedItem, 0, len(summaries))
+	for _, summary := range summaries {
+		product := summary.Product
+		offer, ok := cheapest[product.ID]
+		if !ok {
+			continue
+		}
+		item := feedItem{
+			ID:           product.ID.String(),
+			Title:        product.Title,
+			Description:  fmt.Sprintf("%s: compare %d offers from %s", product.Title, summary.OfferCount, strings.Join(summary.Sources, ", ")),
+			Link:         h.productPageURL(product.ID),
+			Availability: "out_of_stock",
+			Price:        money.USD(offer.TotalToUSAmount - offer.ShippingToUSAmount).String(),
+			Condition:    "new",
+			Shipping:     feedShipping{Country: "US", Price: money.USD(offer.ShippingToUSAmount).String()},
+		}
+		if offer.InStock {
+			item.Availability = "in_stock"
+		}
+		if product.ImageURL != nil {
+			item.ImageLink = *product.ImageURL
+		}
+		if product.Brand != nil {
+			item.Brand = *product.Brand
+		}
+		if product.Model != nil {
+			item.MPN = *product.Model
+		}
+		for _, identifier := range identifiers[product.ID] {
+			if gtinIdentifierTypes[identifier.Type] {
+				item.GTIN = identifier.Value
+				break
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// catalogFeedItems returns the feed items of every product with offers
+func (h *Handlers) catalogFeedItems(ctx context.Context) ([]feedItem, error) {
+	var items []feedItem
+	err := h.walkCatalog(ctx, func(summaries []*repository.ProductSummary) error {
+		batch, err := h.feedItems(ctx, summaries)
+		items = append(items, batch...)
+		return err
+	})
+	return items, err
+}
+
+type productFeedRSS struct {
+	XMLName xml.Name        `xml:"rss"`
+	Version string          `xml:"version,attr"`
+	XMLNSG  string          `xml:"xmlns:g,attr"`
+	Channel productFeedChan `xml:"channel"`
+}
+
+type productFeedChan struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	Items       []feedItem `xml:"item"`
+}
+
+// ProductFeedXML is the product feed as Google Merchant Center RSS 2.0
+func (h *Handlers) ProductFeedXML(c *fiber.Ctx) error {
+	if h.siteURL == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "catalog feeds are not enabled",
+		})
+	}
+	items, err := h.cachedFeedItems(c.UserContext())
+	if err != nil {
+		h.logger.Error("Failed to build the product feed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to build product feed",
+		})
+	}
+	return sendXML(c, productFeedRSS{
+		Version: "2.0",
+		XMLNSG:  "http://base.google.com/ns/1.0",
+		Channel: productFeedChan{
+			Title:       "Price Compare",
+			Link:        h.siteURL + "/",
+			Description: "Products and their cheapest offer, shipped to the US",
+			Items:       items,
+		},
+	})
+}
+
+// productFeedColumns are the CSV header of the product feed, in Google Merchant Center
+// attribute names
+var productFeedColumns = []string{"id", "title", "description", "link", "image_link", "availability", "price", "brand", "gtin", "mpn", "condition", "shipping"}
+
+// ProductFeedCSV is the product feed as a Google Merchant Center CSV file
+func (h *Handlers) ProductFeedCSV(c *fiber.Ctx) error {
+	if h.siteURL == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "catalog feeds are not enabled",
+		})
+	}
+	items, err := h.cachedFeedItems(c.UserContext())
+	if err != nil {
+		h.logger.Error("Failed to build the product feed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to build product feed",
+		})
+	}
+
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	w.Write(productFeedColumns)
+	for _, item := range items {
+		w.Write([]string{
+			item.ID, item.Title, item.Description, item.Link, item.ImageLink, item.Availability, item.Price,
+			item.Brand, item.GTIN, item.MPN, item.Condition,
+			// country:region:service:price
+			item.Shipping.Country + ":::" + item.Shipping.Price,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	return c.SendString(buf.String())
+}
+
+func sendXML(c *fiber.Ctx, document any) error {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+	return c.Send(append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestCatalogFeeds(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	brand, model, image := "Sony", "WH-1000XM5", "https://img.example.com/wh1000xm5.jpg"
+	headphones := &models.Product{Title: "Sony WH-1000XM5 & case", Brand: &brand, Model: &model, ImageURL: &image}
+	withoutOffers := &models.Product{Title: "Discontinued speaker"}
+	soldOut := &models.Product{Title: "Nintendo Switch"}
+	for _, product := range []*models.Product{headphones, withoutOffers, soldOut} {
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.ProductIdentifiers().Create(ctx, &models.ProductIdentifier{ProductID: headphones.ID, Type: "EAN", Value: "4548736132610"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, offer := range []*models.Offer{
+		// The cheaper offer is out of stock, so the in-stock one is advertised
+		{ProductID: headphones.ID, Source: "amazon", Seller: "Amazon", PriceAmount: 27999, Currency: "USD", TotalToUSAmount: 28999, ShippingToUSAmount: 0, InStock: false},
+		{ProductID: headphones.ID, Source: "walmart", Seller: "Walmart", PriceAmount: 29999, Currency: "USD", TotalToUSAmount: 31498, ShippingToUSAmount: 1499, InStock: true},
+		{ProductID: soldOut.ID, Source: "demo", Seller: "Demo", PriceAmount: 29999, Currency: "USD", TotalToUSAmount: 29999, InStock: false},
+	} {
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/sitemap.xml", h.Sitemap)
+	app.Get("/feeds/products.xml", h.ProductFeedXML)
+	app.Get("/feeds/products.csv", h.ProductFeedCSV)
+
+	for _, path := range []string{"/sitemap.xml", "/feeds/products.xml", "/feeds/products.csv"} {
+		if code, _ := doRequest(t, app, "GET", path); code != fiber.StatusNotFound {
+			t.Errorf("%s without EnableCatalogFeeds = %d, want 404", path, code)
+		}
+	}
+	h.EnableCatalogFeeds("https://pricecompare.example.com/", time.Hour)
+	headphonesURL := "https://pricecompare.example.com/compare?productId=" + headphones.ID.String()
+
+	_, body := doRequest(t, app, "GET", "/sitemap.xml")
+	var sitemap sitemapURLSet
+	if err := xml.Unmarshal([]byte(body), &sitemap); err != nil {
+		t.Fatalf("sitemap = %s: %v", body, err)
+	}
+	locs := make(map[string]bool)
+	for _, u := range sitemap.URLs {
+		locs[u.Loc] = true
+	}
+	if len(sitemap.URLs) != 4 || !locs["https://pricecompare.example.com/"] || !locs[headphonesURL] ||
+		locs["https://pricecompare.example.com/compare?productId="+withoutOffers.ID.String()] {
+		t.Errorf("sitemap = %s, want the home, search and two product pages", body)
+	}
+	if code, _ := doRequest(t, app, "GET", "/sitemap.xml?page=2"); code != fiber.StatusNotFound {
+		t.Errorf("sitemap page 2 = %d, want 404", code)
+	}
+
+	_, body = doRequest(t, app, "GET", "/feeds/products.xml")
+	for _, want := range []string{
+		`xmlns:g="http://base.google.com/ns/1.0"`,
+		"<title>Sony WH-1000XM5 &amp; case</title>",
+		"<g:price>299.99 USD</g:price>",
+		"<g:availability>in_stock</g:availability>",
+		"<g:gtin>4548736132610</g:gtin>",
+		"<g:mpn>WH-1000XM5</g:mpn>",
+		"<g:image_link>https://img.example.com/wh1000xm5.jpg</g:image_link>",
+		"<g:price>14.99 USD</g:price>",
+		"<g:availability>out_of_stock</g:availability>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("product feed = %s, want %s", body, want)
+		}
+	}
+	if strings.Contains(body, "Discontinued speaker") {
+		t.Errorf("product feed = %s, want no product without offers", body)
+	}
+
+	_, body = doRequest(t, app, "GET", "/feeds/products.csv")
+	rows, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV feed = %s: %v", body, err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(productFeedColumns, ",") {
+		t.Fatalf("CSV feed = %v, want a header and two products", rows)
+	}
+	for _, row := range rows[1:] {
+		if row[0] == headphones.ID.String() && (row[3] != headphonesURL || row[6] != "299.99 USD" || row[11] != "US:::14.99 USD") {
+			t.Errorf("CSV row = %v", row)
+		}
+	}
+
+	// Products listed after the catalog was read wait for the cache to expire
+	late := &models.Product{Title: "Bose QuietComfort Ultra"}
+	if err := store.Products().Create(ctx, late); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Offers().Create(ctx, &models.Offer{ProductID: late.ID, Source: "demo", Seller: "Demo", PriceAmount: 42900, Currency: "USD", TotalToUSAmount: 42900, InStock: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, cached := doRequest(t, app, "GET", "/sitemap.xml?utm_source=x"); strings.Contains(cached, late.ID.String()) {
+		t.Errorf("sitemap = %s, want the cached catalog", cached)
+	}
+	h.catalogCache.sitemapBuiltAt = time.Now().Add(-2 * time.Hour)
+	if _, fresh := doRequest(t, app, "GET", "/sitemap.xml"); !strings.Contains(fresh, late.ID.String()) {
+		t.Errorf("sitemap = %s, want the product after the cache expired", fresh)
+	}
+}
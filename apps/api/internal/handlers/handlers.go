@@ -1,53 +1,228 @@
 package handlers
 
 import (
-	"encoding/json"
-	"net/url"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/config"
+	"github.com/pricecompare/api/internal/dealscore"
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/imagesearch"
 	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/locale"
 	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
 	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/ratelimit"
 	"github.com/pricecompare/api/internal/repository"
+	"github.com/pricecompare/api/internal/resolver"
+	"github.com/pricecompare/api/internal/searchindex"
+	"github.com/pricecompare/api/internal/selftest"
 	"github.com/pricecompare/api/internal/shipping"
+	"github.com/pricecompare/api/internal/snapshots"
 )
 
 type Handlers struct {
-	productRepo        *repository.ProductRepository
-	offerRepo          *repository.OfferRepository
-	identifierRepo     *repository.ProductIdentifierRepository
-	sourceProductRepo  *repository.SourceProductRepository
+	productRepo        repository.ProductStore
+	offerRepo          repository.OfferStore
+	identifierRepo     repository.ProductIdentifierStore
+	sourceProductRepo  repository.SourceProductStore
+	shippingOptionRepo repository.OfferShippingOptionStore
+	mergeCandidateRepo repository.MergeCandidateStore
+	productImageRepo   repository.ProductImageStore
+	providerFetchRepo  repository.ProviderFetchStore
 	providerManager    *providers.Manager
-	asynqClient        *asynq.Client
+	queue              jobs.Enqueuer // *asynq.Client, or *jobs.InlineQueue with QUEUE_MODE=inline
 	shippingCalc       *shipping.Calculator
 	logger             *zap.Logger
+
+	configWatcher   *config.Watcher                           // see EnableConfigReload
+	selfTest        func(ctx context.Context) selftest.Report // see EnableSelfTest
+	selfTestCache   *selfTestCache
+	searchIndex     searchindex.Index // see EnableSearchIndex
+	searchIndexer   *jobs.SearchIndexer
+	snapshots       snapshots.Store          // see EnableSnapshots
+	catalogReporter *jobs.CatalogReporter    // see EnableCatalogReport
+	freshnessSLA    map[string]time.Duration // see EnableFreshnessSLA
+	queueInspector  jobs.QueueInspector      // see EnableQueueHealth
+	queueStuckAfter time.Duration
+	alertRepo       repository.PriceAlertStore // see EnablePriceAlerts
+	alertChannels   []string
+	alertConfirmer  AlertConfirmer
+	urlResolver     *resolver.Registry // product page URL matchers, see providers.URLMatcher
+	imageSearch     *imagesearch.Searcher
+	imageHasher     *imagehash.Hasher               // see EnableImageHashing
+	revisionRepo    repository.ProductRevisionStore // see EnableProductEditing
+	tagRepo         repository.ProductTagStore      // see EnableProductTags
+	scheduleRepo    repository.FetchScheduleStore   // see EnableFetchSchedules
+	stockEventRepo  repository.StockEventStore      // see EnableStockHistory
+	searchQueryRepo repository.SearchQueryStore     // see EnableSearchQueries
+	fetchScheduler  *jobs.FetchScheduler
+	offerMergeRepo  repository.OfferMergeLogStore // see EnableOfferMergeLog
+	apiKeyRepo      repository.APIKeyStore        // see EnableAPIKeys
+	responseCache   jobs.ResponseCacheInvalidator // see EnableResponseCacheInvalidation
+	comparisonRepo  repository.ComparisonSetStore // see EnableComparisonSets
+	dealScorer      *dealscore.Scorer
+	priceDropRepo   repository.OfferPriceChangeStore        // see EnablePriceDrops
+	siteURL         string                                  // see EnableCatalogFeeds
+	affiliateTags   canonicalurl.AffiliateTags              // see EnableAffiliateTags
+	catalogCache    *catalogCache                           // see EnableCatalogFeeds
+	rateLimitStats  func() []ratelimit.LimiterStats         // see EnableRateLimitStats
+	setRateLimit    func(string, ratelimit.RateLimitConfig) // see EnableRateLimitUpdates
+	jobRunRepo      repository.JobRunStore                  // see EnableJobRuns
+	embeddings      bool                                    // see EnableEmbeddingBackfill
 }
 
 func New(
-	productRepo *repository.ProductRepository,
-	offerRepo *repository.OfferRepository,
-	identifierRepo *repository.ProductIdentifierRepository,
-	sourceProductRepo *repository.SourceProductRepository,
+	productRepo repository.ProductStore,
+	offerRepo repository.OfferStore,
+	identifierRepo repository.ProductIdentifierStore,
+	sourceProductRepo repository.SourceProductStore,
+	shippingOptionRepo repository.OfferShippingOptionStore,
+	mergeCandidateRepo repository.MergeCandidateStore,
+	productImageRepo repository.ProductImageStore,
+	providerFetchRepo repository.ProviderFetchStore,
 	providerManager *providers.Manager,
-	asynqClient *asynq.Client,
+	queue jobs.Enqueuer,
 	shippingCalc *shipping.Calculator,
 	logger *zap.Logger,
 ) *Handlers {
+	urlResolver := resolver.Default()
+	if providerManager != nil {
+		urlResolver = providerManager.URLResolver()
+	}
 	return &Handlers{
-		productRepo:       productRepo,
-		offerRepo:         offerRepo,
-		identifierRepo:    identifierRepo,
-		sourceProductRepo: sourceProductRepo,
-		providerManager:   providerManager,
-		asynqClient:       asynqClient,
-		shippingCalc:      shippingCalc,
-		logger:            logger,
+		productRepo:        productRepo,
+		offerRepo:          offerRepo,
+		identifierRepo:     identifierRepo,
+		sourceProductRepo:  sourceProductRepo,
+		shippingOptionRepo: shippingOptionRepo,
+		mergeCandidateRepo: mergeCandidateRepo,
+		productImageRepo:   productImageRepo,
+		providerFetchRepo:  providerFetchRepo,
+		providerManager:    providerManager,
+		queue:              queue,
+		shippingCalc:       shippingCalc,
+		logger:             logger,
+		dealScorer:         dealscore.New(dealscore.DefaultWeights),
+		urlResolver:        urlResolver,
+		imageSearch:        imagesearch.NewSearcher(productImageRepo, nil),
+	}
+}
+
+// EnableFreshnessSLA marks offers older than their source's SLA ("*" for sources not
+// listed) as stale in the offers and compare responses
+func (h *Handlers) EnableFreshnessSLA(sla map[string]time.Duration) {
+	h.freshnessSLA = sla
+}
+
+// offerResponses wraps offers for a response, with their freshness and source kind
+// filled in
+func (h *Handlers) offerResponses(offers []*models.Offer) []*OfferResponse {
+	responses := make([]*OfferResponse, 0, len(offers))
+	for _, offer := range offers {
+		kind := providers.SourceKind(offer.Source)
+		responses = append(responses, &OfferResponse{Offer: offer, SourceKind: kind, Demo: kind == providers.KindDemo})
+	}
+	h.setFreshness(responses)
+	return responses
+}
+
+// offerResponse is offerResponses of a single offer
+func (h *Handlers) offerResponse(offer *models.Offer) *OfferResponse {
+	return h.offerResponses([]*models.Offer{offer})[0]
+}
+
+// setFreshness fills in the computed age_seconds and stale fields of offers
+func (h *Handlers) setFreshness(responses []*OfferResponse) {
+	now := time.Now()
+	for _, response := range responses {
+		sla, ok := h.freshnessSLA[response.Source]
+		if !ok {
+			sla = h.freshnessSLA["*"]
+		}
+		response.setFreshness(now, sla)
+	}
+}
+
+// displayCurrency reads the ?currency= parameter and returns the currency with its rate
+// in units per USD. An empty currency means the totals are only returned in USD.
+func (h *Handlers) displayCurrency(c *fiber.Ctx) (currency string, rate float64, err error) {
+	currency = c.Query("currency")
+	if currency == "" {
+		return "", 0, nil
+	}
+	currency = money.NormalizeCurrency(currency)
+	if len(currency) != 3 {
+		return "", 0, fmt.Errorf("invalid currency: %s", currency)
+	}
+	if h.shippingCalc == nil {
+		return "", 0, fmt.Errorf("currency conversion is not available")
+	}
+	if rate, err = h.shippingCalc.FXRate(currency); err != nil {
+		return "", 0, fmt.Errorf("unsupported currency: %s", currency)
+	}
+	return currency, rate, nil
+}
+
+// EnableAffiliateTags keeps the operator's own affiliate parameters (e.g. the
+// AMAZON_ASSOCIATE_TAG tag) on the offer links returned to clients
+func (h *Handlers) EnableAffiliateTags(tags canonicalurl.AffiliateTags) {
+	h.affiliateTags = tags
+}
+
+// setOfferURLs sets the url of offers to their canonical_url, the product page link
+// without the search and tracking parameters provider URLs carry, unless ?raw_urls=true
+// asks for the URLs as fetched. The operator's affiliate attribution is kept (see
+// canonicalurl.AffiliateTags.Link). Offers stored before canonical URLs get theirs computed.
+func (h *Handlers) setOfferURLs(c *fiber.Ctx, offers []*models.Offer) {
+	raw := c.QueryBool("raw_urls")
+	for _, offer := range offers {
+		if offer.URL == nil {
+			continue
+		}
+		if offer.CanonicalURL == nil {
+			productURL := canonicalurl.ProductURL(*offer.URL)
+			offer.CanonicalURL = &productURL
+		}
+		if !raw {
+			link := h.affiliateTags.Link(*offer.URL, *offer.CanonicalURL)
+			offer.URL = &link
+		}
+	}
+}
+
+// maxPerPage caps the per_page parameter of paginated endpoints
+const maxPerPage = 100
+
+// maxPage caps the page parameter, so (page-1)*perPage stays a valid offset
+const maxPage = 10000
+
+// pagination reads the page (1-based) and per_page query parameters, falling back to the
+// first page and defaultPerPage for missing or out of range values. Pages past maxPage
+// read as maxPage.
+func pagination(c *fiber.Ctx, defaultPerPage int) (page, perPage int) {
+	page = c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	page = min(page, maxPage)
+	perPage = c.QueryInt("per_page", defaultPerPage)
+	if perPage <= 0 || perPage > maxPerPage {
+		perPage = defaultPerPage
 	}
+	return page, perPage
 }
 
 func ErrorHandler(c *fiber.Ctx, err error) error {
@@ -69,58 +244,130 @@ func (h *Handlers) Health(c *fiber.Ctx) error {
 
 func (h *Handlers) Search(c *fiber.Ctx) error {
 	query := c.Query("query", "")
-	if query == "" {
+	tag := normalizeTag(c.Query("tag"))
+	if query == "" && tag == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "query parameter is required",
 		})
 	}
 
-	limit := 20
-	products, err := h.productRepo.Search(query, limit)
+	filter, err := searchFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.Tag = tag
+
+	page, perPage := pagination(c, 20)
+	matches, total, err := h.productRepo.SearchWithMinPrice(c.UserContext(), query, filter, perPage, (page-1)*perPage)
 	if err != nil {
 		h.logger.Error("Search failed", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to search products",
 		})
 	}
+	facets, err := h.productRepo.SearchFacets(c.UserContext(), query, filter)
+	if err != nil {
+		h.logger.Error("Search facets failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to search products",
+		})
+	}
+	products := make([]*models.Product, 0, len(matches))
+	aggregates := make(map[uuid.UUID]*repository.ProductSearchResult, len(matches))
+	for _, match := range matches {
+		products = append(products, match.Product)
+		aggregates[match.Product.ID] = match
+	}
+
+	// Collapse products sharing an identifier that are still waiting to be merged, on
+	// request (dedupe=true)
+	var duplicates map[uuid.UUID][]uuid.UUID
+	if c.QueryBool("dedupe", false) && len(products) > 1 {
+		ids := make([]uuid.UUID, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+		identifiers, err := h.identifierRepo.ListByProductIDs(c.UserContext(), ids)
+		if err != nil {
+			h.logger.Warn("Failed to get identifiers for search deduplication", zap.Error(err))
+		} else {
+			products, duplicates = groupDuplicates(products, identifiers)
+		}
+	}
 
-	// Get cheapest offer for each product
+	// The offer aggregates come from the search query itself
 	type ProductWithMinPrice struct {
 		*models.Product
-		MinPriceCents *int `json:"min_price_cents,omitempty"`
+		MinPriceCents *int        `json:"min_price_cents,omitempty"`
+		OfferCount    int         `json:"offer_count"`
+		InStockCount  int         `json:"in_stock_count"`
+		Duplicates    []uuid.UUID `json:"duplicates,omitempty"`
 	}
 
 	results := make([]ProductWithMinPrice, 0, len(products))
 	for _, product := range products {
-		offers, err := h.offerRepo.GetByProductID(product.ID)
-		if err != nil {
-			h.logger.Warn("Failed to get offers", zap.Error(err))
-			results = append(results, ProductWithMinPrice{Product: product})
-			continue
-		}
-
-		var minPrice *int
-		if len(offers) > 0 {
-			min := offers[0].TotalToUSAmount
-			for _, offer := range offers {
-				if offer.TotalToUSAmount < min {
-					min = offer.TotalToUSAmount
-				}
-			}
-			minPrice = &min
-		}
-
+		match := aggregates[product.ID]
 		results = append(results, ProductWithMinPrice{
 			Product:       product,
-			MinPriceCents: minPrice,
+			MinPriceCents: match.MinPriceCents,
+			OfferCount:    match.OfferCount,
+			InStockCount:  match.InStockCount,
+			Duplicates:    duplicates[product.ID],
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"products": results,
+		"total":    total,
+		"facets":   facets,
+		"page":     page,
+		"per_page": perPage,
 	})
 }
 
+// searchFilter parses the brand, source, price range and in_stock filters of Search. The
+// price range is given in cents (min_price_cents, max_price_cents) or in US dollars
+// (min_price, max_price).
+func searchFilter(c *fiber.Ctx) (repository.ProductSearchFilter, error) {
+	filter := repository.ProductSearchFilter{
+		Brand:   strings.TrimSpace(c.Query("brand")),
+		Source:  strings.TrimSpace(c.Query("source")),
+		InStock: c.QueryBool("in_stock", false),
+	}
+	for _, bound := range []struct {
+		name  string
+		value **int
+	}{
+		{"min_price", &filter.MinPriceCents},
+		{"max_price", &filter.MaxPriceCents},
+	} {
+		cents, dollars := c.Query(bound.name+"_cents"), c.Query(bound.name)
+		switch {
+		case cents != "" && dollars != "":
+			return filter, fmt.Errorf("%s and %s_cents must not both be set", bound.name, bound.name)
+		case cents != "":
+			amount, err := strconv.Atoi(cents)
+			if err != nil || amount < 0 {
+				return filter, fmt.Errorf("%s_cents must be a non-negative integer", bound.name)
+			}
+			*bound.value = &amount
+		case dollars != "":
+			major, err := strconv.ParseFloat(dollars, 64)
+			if err != nil || major < 0 || math.IsInf(major, 0) {
+				return filter, fmt.Errorf("%s must be a non-negative number", bound.name)
+			}
+			amount := money.FromMajor(major, money.DefaultCurrency).Amount
+			*bound.value = &amount
+		}
+	}
+	if filter.MinPriceCents != nil && filter.MaxPriceCents != nil && *filter.MinPriceCents > *filter.MaxPriceCents {
+		return filter, fmt.Errorf("the minimum price must not be greater than the maximum price")
+	}
+	return filter, nil
+}
+
 func (h *Handlers) GetProduct(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := uuid.Parse(idStr)
@@ -130,7 +377,7 @@ func (h *Handlers) GetProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	product, err := h.productRepo.GetByID(id)
+	product, err := h.productRepo.GetByID(c.UserContext(), id)
 	if err != nil {
 		h.logger.Error("Get product failed", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -144,7 +391,98 @@ func (h *Handlers) GetProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(product)
+	response := &ProductResponse{Product: product}
+	if lang := c.Query("lang"); lang != "" {
+		language := locale.Language(lang)
+		if language == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid lang",
+			})
+		}
+		title, err := h.localizedTitle(c.UserContext(), product, language)
+		if err != nil {
+			h.logger.Error("Failed to get product listings", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get product",
+			})
+		}
+		response.LocalizedTitle = title
+	}
+
+	if h.tagRepo != nil {
+		tags, err := h.tagRepo.ListByProductID(c.UserContext(), product.ID)
+		if err != nil {
+			h.logger.Error("Failed to get product tags", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get product",
+			})
+		}
+		response.Tags = tags
+	}
+
+	return c.JSON(response)
+}
+
+// localizedTitle returns the title of the most recently updated listing of a product in
+// language, or the product title if it is in that language, or else the most recent
+// translation of a listing title into language. It returns nil if there is none.
+func (h *Handlers) localizedTitle(ctx context.Context, product *models.Product, language string) (*string, error) {
+	listings, err := h.sourceProductRepo.ListByProductID(ctx, product.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, listing := range listings {
+		if listing.Language != nil && *listing.Language == language && listing.Title != nil && *listing.Title != "" {
+			return listing.Title, nil
+		}
+	}
+	if locale.Detect(product.Title) == language {
+		return &product.Title, nil
+	}
+	for _, listing := range listings {
+		if title := listing.Titles[language]; title != "" {
+			return &title, nil
+		}
+	}
+	return nil, nil
+}
+
+// listingTitle returns the title of a listing in language: its own title if it is in
+// that language, or its translation. It returns nil if it has neither.
+func listingTitle(listing *models.SourceProduct, language string) *string {
+	if listing.Language != nil && *listing.Language == language && listing.Title != nil && *listing.Title != "" {
+		return listing.Title
+	}
+	if title := listing.Titles[language]; title != "" {
+		return &title
+	}
+	return nil
+}
+
+// setDisplayTitles sets the display title of each offer from its listing, the listing of
+// its source at its URL or else the most recently updated listing of its source
+func setDisplayTitles(offers []*OfferResponse, listings []*models.SourceProduct, language string) {
+	for _, offer := range offers {
+		var match, latest *models.SourceProduct
+		for _, listing := range listings {
+			if listing.Provider != offer.Source {
+				continue
+			}
+			if offer.URL != nil && canonicalurl.Canonicalize(*offer.URL) == listing.URL {
+				match = listing
+				break
+			}
+			if latest == nil || listing.UpdatedAt.After(latest.UpdatedAt) {
+				latest = listing
+			}
+		}
+		if match == nil {
+			match = latest
+		}
+		if match != nil {
+			offer.DisplayTitle = listingTitle(match, language)
+		}
+	}
 }
 
 func (h *Handlers) GetProductOffers(c *fiber.Ctx) error {
@@ -156,21 +494,46 @@ func (h *Handlers) GetProductOffers(c *fiber.Ctx) error {
 		})
 	}
 
-	offers, err := h.offerRepo.GetByProductID(id)
+	currency, rate, err := h.displayCurrency(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Delisted offers follow the listed ones, for how long a listing was available
+	page, perPage := pagination(c, 50)
+	offers, total, err := h.offerRepo.GetPageByProductID(c.UserContext(), id, c.QueryBool("include_delisted"), perPage, (page-1)*perPage)
 	if err != nil {
 		h.logger.Error("Get offers failed", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to get offers",
 		})
 	}
+	responses := h.offerResponses(offers)
+	h.setOfferURLs(c, offers)
+	if currency != "" {
+		for _, offer := range offers {
+			offer.ConvertTotals(currency, rate)
+		}
+	}
 
 	return c.JSON(fiber.Map{
-		"offers": offers,
+		"offers":   responses,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
 	})
 }
 
 // CompareProductOffers returns offers for a product with sorting options.
-// Supported sort keys: total, fastest, newest, in_stock
+// Supported sort keys: total, landed_cost, fastest, newest, in_stock, deal_score
+// (highest deal score first, see dealscore).
+// An optional speed (economy, standard, express) applies that shipping option to the totals.
+// An optional dest (ISO country code, see shipping.DestinationMultipliers) recomputes
+// shipping, duty and totals for that destination instead of the stored US ones.
+// Optional fee_percent and fx (see priceOverrides) reprice the offers with those fee and
+// exchange rate assumptions; nothing is stored.
 func (h *Handlers) CompareProductOffers(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := uuid.Parse(idStr)
@@ -181,13 +544,82 @@ func (h *Handlers) CompareProductOffers(c *fiber.Ctx) error {
 	}
 
 	sortKey := c.Query("sort", "total")
-	if sortKey != "total" && sortKey != "fastest" && sortKey != "newest" && sortKey != "in_stock" {
+	if sortKey != "total" && sortKey != "landed_cost" && sortKey != "fastest" && sortKey != "newest" && sortKey != "in_stock" && sortKey != "deal_score" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid sort key. must be one of: total, landed_cost, fastest, newest, in_stock, deal_score",
+		})
+	}
+
+	speed := c.Query("speed", "")
+	if speed != "" && !shipping.IsValidSpeed(speed) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid speed. must be one of: economy, standard, express",
+		})
+	}
+
+	destination, err := shipping.NormalizeDestination(c.Query("dest"))
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid sort key. must be one of: total, fastest, newest, in_stock",
+			"error": err.Error(),
 		})
 	}
 
-	offers, err := h.offerRepo.GetByProductIDWithSort(id, sortKey)
+	overrides, err := priceOverrides(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	calc := h.shippingCalc
+	if calc == nil && (destination != "US" || !overrides.IsZero()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "repricing is not available",
+		})
+	}
+	if !overrides.IsZero() {
+		calc = calc.WithOverrides(overrides)
+	}
+
+	currency, rate, err := h.displayCurrency(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	// Offer titles are shown in English to the US and in Japanese to Japan, unless ?lang= says otherwise
+	displayLanguage := locale.English
+	if destination == "JP" {
+		displayLanguage = locale.Japanese
+	}
+	if lang := c.Query("lang"); lang != "" {
+		displayLanguage = locale.Language(lang)
+		if displayLanguage == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid lang",
+			})
+		}
+	}
+	if overrideRate, ok := overrides.FXRates[currency]; ok {
+		rate = overrideRate
+	}
+
+	// Stored totals are to the US at the configured fees and rates; other destinations and
+	// overrides are priced per request
+	productCategory := ""
+	if destination != "US" || !overrides.IsZero() {
+		product, err := h.productRepo.GetByID(c.UserContext(), id)
+		if err != nil {
+			h.logger.Error("Get product for compare failed", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get product",
+			})
+		}
+		if product != nil && product.Category != nil {
+			productCategory = *product.Category
+		}
+	}
+
+	offers, err := h.offerRepo.GetByProductIDWithSort(c.UserContext(), id, sortKey)
 	if err != nil {
 		h.logger.Error("Get offers for compare failed", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -195,9 +627,277 @@ func (h *Handlers) CompareProductOffers(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"offers": offers,
-	})
+	offerIDs := make([]uuid.UUID, 0, len(offers))
+	for _, offer := range offers {
+		offerIDs = append(offerIDs, offer.ID)
+	}
+	optionsByOffer, err := h.shippingOptionRepo.GetByOfferIDs(c.UserContext(), offerIDs)
+	if err != nil {
+		// Options are supplementary; fall back to the stored totals
+		h.logger.Warn("Get shipping options failed", zap.Error(err))
+		optionsByOffer = nil
+	}
+
+	// Deal scores compare the stored US totals with the median of the price history, which
+	// is of US totals too, so they are taken before the offers are repriced below
+	medianTotal, _, err := h.offerRepo.PriceHistoryMedian(c.UserContext(), id, time.Now().Add(-dealscore.HistoryWindow))
+	if err != nil {
+		// Without a median the price scores neutral
+		h.logger.Warn("Get price history median for deal scores failed", zap.Error(err))
+		medianTotal = 0
+	}
+	responses := h.offerResponses(offers)
+	for _, response := range responses {
+		score := h.dealScorer.Score(response.Offer, medianTotal)
+		response.DealScore = &score
+	}
+
+	for _, response := range responses {
+		offer := response.Offer
+		offer.ShippingOptions = optionsByOffer[offer.ID]
+		if !overrides.IsZero() {
+			if err := jobs.PriceOffer(calc, offer, productCategory); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+		if destination != "US" {
+			if err := applyDestination(calc, response, productCategory, destination); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+		if speed != "" {
+			applyShippingOption(response, speed)
+		}
+	}
+	if speed != "" || destination != "US" || !overrides.IsZero() || sortKey == "deal_score" {
+		sortOffers(responses, sortKey)
+	}
+	h.setOfferURLs(c, offers)
+	setDeliveryWindows(responses, destination, time.Now())
+	if currency != "" {
+		for _, response := range responses {
+			response.convertTotals(currency, rate)
+		}
+	}
+	listings, err := h.sourceProductRepo.ListByProductID(c.UserContext(), id)
+	if err != nil {
+		// Titles are supplementary; the offers are shown without them
+		h.logger.Warn("Get product listings for display titles failed", zap.Error(err))
+		listings = nil
+	}
+	setDisplayTitles(responses, listings, displayLanguage)
+
+	response := fiber.Map{
+		"offers":      responses,
+		"destination": destination,
+		"language":    displayLanguage,
+	}
+	if !overrides.IsZero() {
+		response["overrides"] = fiber.Map{
+			"fee_percent": overrides.FeePercent,
+			"fx":          overrides.FXRates,
+		}
+	}
+	return c.JSON(response)
+}
+
+// priceOverrides reads the compare endpoint's what-if pricing parameters:
+// fee_percent (0-100) replaces the fee rules with a single percentage fee, and fx
+// ("JPY:150,EUR:0.92", units per USD) replaces the exchange rates of those currencies
+func priceOverrides(c *fiber.Ctx) (shipping.Overrides, error) {
+	var overrides shipping.Overrides
+	if value := c.Query("fee_percent"); value != "" {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return overrides, fmt.Errorf("invalid fee_percent: must be a number from 0 to 100")
+		}
+		overrides.FeePercent = &percent
+	}
+	if value := c.Query("fx"); value != "" {
+		overrides.FXRates = make(map[string]float64)
+		for _, entry := range strings.Split(value, ",") {
+			currency, rateValue, ok := strings.Cut(entry, ":")
+			currency = money.NormalizeCurrency(currency)
+			rate, err := strconv.ParseFloat(strings.TrimSpace(rateValue), 64)
+			if !ok || len(currency) != 3 || currency == "USD" || err != nil || rate <= 0 {
+				return overrides, fmt.Errorf("invalid fx %q: must be <currency>:<units per USD>, e.g. JPY:150", entry)
+			}
+			overrides.FXRates[currency] = rate
+		}
+	}
+	return overrides, nil
+}
+
+// applyDestination sets the destination totals of an offer for shipping to destination
+// and rewrites its shipping options and delivery estimate to that destination. The item
+// price and fees are kept.
+func applyDestination(calc *shipping.Calculator, response *OfferResponse, productCategory, destination string) error {
+	offer := response.Offer
+	itemUSD := offer.TotalToUSAmount - offer.ShippingToUSAmount - offer.FeeAmount
+	options, err := calc.CalculateOptionsTo(destination, offer.Source, productCategory, itemUSD, offer.FreeShipping)
+	if err != nil {
+		return err
+	}
+
+	totals := &DestinationTotals{Country: destination}
+	offer.ShippingOptions = make([]*models.OfferShippingOption, 0, len(options))
+	for _, option := range options {
+		daysMin, daysMax := option.DaysMin, option.DaysMax
+		offer.ShippingOptions = append(offer.ShippingOptions, &models.OfferShippingOption{
+			OfferID:            offer.ID,
+			Speed:              option.Speed,
+			CostAmount:         option.CostCents,
+			EstDeliveryDaysMin: &daysMin,
+			EstDeliveryDaysMax: &daysMax,
+		})
+		if option.Speed == shipping.SpeedStandard {
+			totals.ShippingAmount = option.CostCents
+			offer.EstDeliveryDaysMin = &daysMin
+			offer.EstDeliveryDaysMax = &daysMax
+		}
+	}
+	totals.FreeShipping = totals.ShippingAmount == 0
+
+	originCountry := ""
+	if offer.ShipsFromCountry != nil {
+		originCountry = *offer.ShipsFromCountry
+	}
+	totals.DutyAmount = calc.EstimateDutyTo(itemUSD, productCategory, originCountry, destination)
+	totals.TotalAmount = itemUSD + totals.ShippingAmount + offer.FeeAmount
+	totals.LandedCostAmount = calc.CalculateLandedCost(totals.TotalAmount, totals.DutyAmount)
+	response.Destination = totals
+	return nil
+}
+
+// applyShippingOption rewrites an offer's shipping, totals (to its destination, if set)
+// and delivery estimate using the option for the given speed. Offers without that option
+// are left unchanged.
+func applyShippingOption(response *OfferResponse, speed string) {
+	offer := response.Offer
+	for _, option := range offer.ShippingOptions {
+		if option.Speed != speed {
+			continue
+		}
+		if totals := response.Destination; totals != nil {
+			totals.TotalAmount += option.CostAmount - totals.ShippingAmount
+			totals.LandedCostAmount = totals.TotalAmount + totals.DutyAmount
+			totals.ShippingAmount = option.CostAmount
+		} else {
+			// Totals are in USD while the price may be in another currency, so adjust by the delta
+			offer.TotalToUSAmount += option.CostAmount - offer.ShippingToUSAmount
+			offer.LandedCostAmount = offer.TotalToUSAmount + offer.DutyAmount
+			offer.ShippingToUSAmount = option.CostAmount
+			if offer.CostBreakdown != nil {
+				breakdown := *offer.CostBreakdown
+				breakdown.ShippingAmount = offer.ShippingToUSAmount
+				breakdown.TotalAmount = offer.TotalToUSAmount
+				breakdown.LandedCostAmount = offer.LandedCostAmount
+				offer.CostBreakdown = &breakdown
+			}
+		}
+		offer.EstDeliveryDaysMin = option.EstDeliveryDaysMin
+		offer.EstDeliveryDaysMax = option.EstDeliveryDaysMax
+		selected := option.Speed
+		offer.SelectedSpeed = &selected
+		return
+	}
+}
+
+// setDeliveryWindows fills in the delivery dates of offers and their shipping options
+// from their day ranges, for an order placed at now. The stored estimated delivery date
+// (if a source reported one) is kept; otherwise it is the latest date of the window.
+func setDeliveryWindows(offers []*OfferResponse, destination string, now time.Time) {
+	window := func(daysMin, daysMax *int) (*DeliveryWindow, *time.Time) {
+		if daysMin == nil && daysMax == nil {
+			return nil, nil
+		}
+		if daysMin == nil {
+			daysMin = daysMax
+		}
+		if daysMax == nil {
+			daysMax = daysMin
+		}
+		dates, err := shipping.EstimateDeliveryWindow(destination, now, *daysMin, *daysMax)
+		if err != nil {
+			return nil, nil
+		}
+		return &DeliveryWindow{
+			Earliest: dates.Earliest.Format(time.DateOnly),
+			Latest:   dates.Latest.Format(time.DateOnly),
+		}, &dates.Latest
+	}
+
+	for _, offer := range offers {
+		var latest *time.Time
+		offer.DeliveryWindow, latest = window(offer.EstDeliveryDaysMin, offer.EstDeliveryDaysMax)
+		if offer.EstimatedDelivery == nil {
+			offer.EstimatedDelivery = latest
+		}
+		offer.ShippingOptions = nil
+		for _, option := range offer.Offer.ShippingOptions {
+			response := &ShippingOptionResponse{OfferShippingOption: option}
+			response.DeliveryWindow, _ = window(option.EstDeliveryDaysMin, option.EstDeliveryDaysMax)
+			offer.ShippingOptions = append(offer.ShippingOptions, response)
+		}
+	}
+}
+
+// sortOffers re-sorts offers in memory after totals were changed by applyShippingOption.
+// It mirrors the ORDER BY clauses of OfferRepository.GetByProductIDWithSort.
+func sortOffers(offers []*OfferResponse, sortKey string) {
+	deliveryDays := func(o *OfferResponse) int {
+		if o.EstDeliveryDaysMin != nil {
+			return *o.EstDeliveryDaysMin
+		}
+		if o.EstDeliveryDaysMax != nil {
+			return *o.EstDeliveryDaysMax
+		}
+		return 9999
+	}
+
+	switch sortKey {
+	case "total":
+		sort.SliceStable(offers, func(i, j int) bool {
+			return offers[i].totalAmount() < offers[j].totalAmount()
+		})
+	case "landed_cost":
+		sort.SliceStable(offers, func(i, j int) bool {
+			return offers[i].landedCostAmount() < offers[j].landedCostAmount()
+		})
+	case "fastest":
+		sort.SliceStable(offers, func(i, j int) bool {
+			di, dj := deliveryDays(offers[i]), deliveryDays(offers[j])
+			if di != dj {
+				return di < dj
+			}
+			return offers[i].totalAmount() < offers[j].totalAmount()
+		})
+	case "in_stock":
+		sort.SliceStable(offers, func(i, j int) bool {
+			if offers[i].InStock != offers[j].InStock {
+				return offers[i].InStock
+			}
+			return offers[i].totalAmount() < offers[j].totalAmount()
+		})
+	case "deal_score":
+		score := func(o *OfferResponse) float64 {
+			if o.DealScore == nil {
+				return 0
+			}
+			return *o.DealScore
+		}
+		sort.SliceStable(offers, func(i, j int) bool {
+			si, sj := score(offers[i]), score(offers[j])
+			if si != sj {
+				return si > sj
+			}
+			return offers[i].totalAmount() < offers[j].totalAmount()
+		})
+	}
 }
 
 type ResolveURLRequest struct {
@@ -227,49 +927,28 @@ func (h *Handlers) ResolveURL(c *fiber.Ctx) error {
 		rawURL = "https://" + rawURL
 	}
 
-	parsed, err := url.Parse(rawURL)
-	if err != nil || parsed.Host == "" {
+	// Tracking parameters and host case must not create a second source product
+	parsed, err := canonicalurl.Parse(rawURL)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "URLの形式が正しくありません",
 		})
 	}
+	rawURL = parsed.String()
 
-	host := strings.ToLower(parsed.Host)
-	path := parsed.Path
-
-	var (
-		provider       string
-		identifierType string
-		identifier     string
-		sourceID       string
-	)
-
-	// Very small, explicit set of supported URL patterns.
-	// Example: https://www.amazon.com/dp/B08N5WRWNW
-	if strings.Contains(host, "amazon.") {
-		provider = "amazon"
-		parts := strings.Split(path, "/")
-		for i, p := range parts {
-			if p == "dp" || (p == "product" && i > 0 && parts[i-1] == "gp") {
-				if i+1 < len(parts) && parts[i+1] != "" {
-					identifierType = "ASIN"
-					identifier = parts[i+1]
-					sourceID = parts[i+1]
-				}
-				break
-			}
-		}
-	}
-
-	if provider == "" || identifierType == "" || identifier == "" {
+	resolved, ok := h.urlResolver.Resolve(parsed)
+	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":       "このURLは現在のバージョンでは解析対象外です",
-			"description": "Amazonの商品詳細URL (https://www.amazon.com/dp/ASIN) のみ対応しています。",
+			"description": "対応しているプロバイダの商品詳細URLのみ解析できます。",
+			"providers":   h.urlResolver.Providers(),
 		})
 	}
+	provider, identifierType, identifier := resolved.Provider, resolved.IdentifierType, resolved.Identifier
+	sourceID := identifier
 
 	// Try to find an existing product via identifier
-	_, existingProduct, err := h.identifierRepo.FindByTypeAndValue(identifierType, identifier)
+	_, existingProduct, err := h.identifierRepo.FindByTypeAndValue(c.UserContext(), identifierType, identifier)
 	if err != nil {
 		h.logger.Error("ResolveURL: failed to lookup identifier", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -278,14 +957,16 @@ func (h *Handlers) ResolveURL(c *fiber.Ctx) error {
 	}
 
 	product := existingProduct
+	matchMethod := models.MatchMethodIdentifier
 	if product == nil {
+		matchMethod = models.MatchMethodNew
 		// Create a minimal product placeholder. In a future iteration this can be
 		// populated by a dedicated provider without violating robots/ALLOW_LIVE_FETCH.
 		title := "URLから登録された商品 (" + identifierType + ": " + identifier + ")"
 		product = &models.Product{
 			Title: title,
 		}
-		if err := h.productRepo.Create(product); err != nil {
+		if err := h.productRepo.Create(c.UserContext(), product); err != nil {
 			h.logger.Error("ResolveURL: failed to create product", zap.Error(err))
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "failed to create product from url",
@@ -293,7 +974,7 @@ func (h *Handlers) ResolveURL(c *fiber.Ctx) error {
 		}
 
 		// Save identifier mapping
-		if err := h.identifierRepo.Create(&models.ProductIdentifier{
+		if err := h.identifierRepo.Create(c.UserContext(), &models.ProductIdentifier{
 			ProductID: product.ID,
 			Type:      identifierType,
 			Value:     identifier,
@@ -305,12 +986,14 @@ func (h *Handlers) ResolveURL(c *fiber.Ctx) error {
 	// Upsert source product info
 	if sourceID != "" {
 		sp := &models.SourceProduct{
-			ProductID: product.ID,
-			Provider:  provider,
-			SourceID:  sourceID,
-			URL:       rawURL,
+			ProductID:       product.ID,
+			Provider:        provider,
+			SourceID:        sourceID,
+			URL:             rawURL,
+			MatchMethod:     matchMethod,
+			MatchConfidence: 1,
 		}
-		if err := h.sourceProductRepo.Upsert(sp); err != nil {
+		if err := h.sourceProductRepo.Upsert(c.UserContext(), sp); err != nil {
 			h.logger.Warn("ResolveURL: failed to upsert source product", zap.Error(err))
 		}
 	}
@@ -325,6 +1008,21 @@ func (h *Handlers) ResolveURL(c *fiber.Ctx) error {
 
 type FetchPricesRequest struct {
 	Source string `json:"source"` // "demo", "public_html", or "all"
+
+	// Optional crawl budget overrides for this run; 0 is unlimited
+	MaxCandidatesPerQuery *int `json:"max_candidates_per_query,omitempty"`
+	MaxOffersPerProduct   *int `json:"max_offers_per_product,omitempty"`
+	MaxRequests           *int `json:"max_requests,omitempty"`
+}
+
+// fetchSourceList lists jobs.FetchSources for error messages: 'demo', ..., or 'all'
+func fetchSourceList() string {
+	quoted := make([]string, len(jobs.FetchSources))
+	for i, source := range jobs.FetchSources {
+		quoted[i] = "'" + source + "'"
+	}
+	last := len(quoted) - 1
+	return strings.Join(quoted[:last], ", ") + ", or " + quoted[last]
 }
 
 func (h *Handlers) FetchPrices(c *fiber.Ctx) error {
@@ -339,21 +1037,60 @@ func (h *Handlers) FetchPrices(c *fiber.Ctx) error {
 		req.Source = "all"
 	}
 
-	if req.Source != "demo" && req.Source != "public_html" && req.Source != "live" && req.Source != "walmart" && req.Source != "amazon" && req.Source != "all" {
+	if !slices.Contains(jobs.FetchSources, req.Source) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid source. must be 'demo', 'public_html', 'live', 'walmart', 'amazon', or 'all'",
+			"error": "invalid source. must be " + fetchSourceList(),
 		})
 	}
 
-	payload, err := json.Marshal(jobs.FetchPricesPayload{Source: req.Source})
+	for _, limit := range []*int{req.MaxCandidatesPerQuery, req.MaxOffersPerProduct, req.MaxRequests} {
+		if limit != nil && *limit < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "max_candidates_per_query, max_offers_per_product and max_requests must not be negative",
+			})
+		}
+	}
+
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeFetchPrices, &jobs.FetchPricesPayload{
+		Source:                req.Source,
+		MaxCandidatesPerQuery: req.MaxCandidatesPerQuery,
+		MaxOffersPerProduct:   req.MaxOffersPerProduct,
+		MaxRequests:           req.MaxRequests,
+	})
 	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to create job payload",
+			"error": "failed to enqueue job",
 		})
 	}
 
-	task := asynq.NewTask(jobs.TypeFetchPrices, payload)
-	info, err := h.asynqClient.Enqueue(task)
+	// Which providers the job will consult, as far as known now
+	sources := []string{req.Source}
+	if req.Source == "all" {
+		sources = h.providerManager.List()
+		sort.Strings(sources)
+	}
+	statuses := make([]providers.ProviderStatus, 0, len(sources))
+	for _, source := range sources {
+		status := h.providerManager.Status(source)
+		if req.Source != "all" {
+			// A single source is called even while its circuit is open
+			status.Status = providers.StatusAvailable
+		}
+		statuses = append(statuses, status)
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id":    info.ID,
+		"status":    "enqueued",
+		"source":    req.Source,
+		"providers": statuses,
+	})
+}
+
+// RunMaintenance enqueues a database maintenance run outside the regular schedule
+func (h *Handlers) RunMaintenance(c *fiber.Ctx) error {
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeMaintenance, &jobs.MaintenancePayload{})
 	if err != nil {
 		h.logger.Error("Failed to enqueue task", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -364,15 +1101,44 @@ func (h *Handlers) FetchPrices(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"job_id": info.ID,
 		"status": "enqueued",
-		"source": req.Source,
 	})
 }
 
-func (h *Handlers) ImageSearch(c *fiber.Ctx) error {
-	// Stub implementation
+// ReprocessRawRequest is the body of RunReprocessRaw
+type ReprocessRawRequest struct {
+	Provider string `json:"provider"` // empty or "all" re-parses every provider
+}
+
+// RunReprocessRaw enqueues the reprocess_raw job, which re-parses stored raw provider
+// payloads whose schema version is older than the provider's current one
+func (h *Handlers) RunReprocessRaw(c *fiber.Ctx) error {
+	var req ReprocessRawRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+	if req.Provider == "all" {
+		req.Provider = ""
+	}
+	if req.Provider != "" && !slices.Contains(jobs.FetchSources, req.Provider) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid provider. must be " + fetchSourceList(),
+		})
+	}
+
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeReprocessRaw, &jobs.ReprocessRawPayload{Provider: req.Provider})
+	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to enqueue job",
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"message": "Image search is not yet implemented",
-		"products": []interface{}{},
+		"job_id": info.ID,
+		"status": "enqueued",
 	})
 }
-
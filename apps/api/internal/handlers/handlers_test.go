@@ -0,0 +1,1552 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
+	"github.com/pricecompare/api/internal/repository"
+	"github.com/pricecompare/api/internal/repository/memory"
+	"github.com/pricecompare/api/internal/searchindex"
+	"github.com/pricecompare/api/internal/selftest"
+	"github.com/pricecompare/api/internal/shipping"
+)
+
+// newTestHandlers builds handlers over the in-memory repositories with no provider
+// manager, queue or shipping calculator
+func newTestHandlers(t *testing.T, store *memory.Store) *Handlers {
+	t.Helper()
+	return New(
+		store.Products(),
+		store.Offers(),
+		store.ProductIdentifiers(),
+		store.SourceProducts(),
+		store.OfferShippingOptions(),
+		store.MergeCandidates(),
+		store.ProductImages(),
+		store.ProviderFetches(),
+		nil,
+		nil,
+		nil,
+		zap.NewNop(),
+	)
+}
+
+// newTestApp serves the catalog and merge candidate routes from in-memory repositories
+func newTestApp(t *testing.T, store *memory.Store) *fiber.App {
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/search", h.Search)
+	app.Get("/api/products/:id", h.GetProduct)
+	app.Get("/api/products/:id/offers", h.GetProductOffers)
+	app.Get("/api/admin/merge-candidates", h.ListMergeCandidates)
+	app.Get("/api/admin/offers/quarantined", h.ListQuarantinedOffers)
+	app.Post("/api/admin/merge-candidates/:id/merge", h.MergeCandidate)
+	app.Post("/api/admin/merge-candidates/:id/dismiss", h.DismissMergeCandidate)
+	return app
+}
+
+func TestCatalogRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	for _, total := range []int{12000, 9900} {
+		if err := store.Offers().Create(ctx, &models.Offer{ProductID: product.ID, Source: "demo", Seller: "seller", TotalToUSAmount: total}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reason := models.OfferQuarantinePriceUnknown
+	// Stored without a canonical URL, as offers fetched before canonical_url are
+	amazonURL := "https://www.amazon.com/Sony-WH-1000XM5/dp/B09XS7JWHH?tag=pc-20"
+	if err := store.Offers().Create(ctx, &models.Offer{ProductID: product.ID, Source: "amazon", Seller: "Amazon", TotalToUSAmount: 500, URL: &amazonURL, QuarantineReason: &reason}); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, store)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"search without query", "/api/search", fiber.StatusBadRequest, `"query parameter is required"`},
+		{"search", "/api/search?query=headphones", fiber.StatusOK, `"min_price_cents":9900`},
+		{"search without match", "/api/search?query=toaster", fiber.StatusOK, `"products":[],"total":0`},
+		{"search page", "/api/search?query=headphones&page=2&per_page=1", fiber.StatusOK, `"page":2,"per_page":1,"products":[],"total":1`},
+		{"search past the last page", "/api/search?query=headphones&page=9223372036854775807", fiber.StatusOK, `"page":10000,"per_page":20,"products":[],"total":1`},
+		{"product", "/api/products/" + product.ID.String(), fiber.StatusOK, `"title":"Sony WH-1000XM5 Headphones"`},
+		{"unknown product", "/api/products/" + uuid.NewString(), fiber.StatusNotFound, `"product not found"`},
+		{"invalid product id", "/api/products/123", fiber.StatusBadRequest, `"invalid product id"`},
+		{"offers", "/api/products/" + product.ID.String() + "/offers", fiber.StatusOK, `"total_to_us_amount":9900`},
+		{"offers page", "/api/products/" + product.ID.String() + "/offers?page=2&per_page=1", fiber.StatusOK, `"total_to_us_amount":12000`},
+		{"offers total", "/api/products/" + product.ID.String() + "/offers?per_page=1", fiber.StatusOK, `"page":1,"per_page":1,"total":2`},
+		{"offer freshness", "/api/products/" + product.ID.String() + "/offers", fiber.StatusOK, `"age_seconds":0,"stale":false`},
+		{"quarantined offers", "/api/admin/offers/quarantined", fiber.StatusOK, `"quarantine_reason":"price_unknown"`},
+		{"demo offers are attributed", "/api/products/" + product.ID.String() + "/offers", fiber.StatusOK, `"source_kind":"demo","demo":true`},
+		{"official api offers are attributed", "/api/admin/offers/quarantined", fiber.StatusOK, `"source_kind":"official_api","demo":false`},
+		{"offers link to the product page", "/api/admin/offers/quarantined", fiber.StatusOK, `"url":"https://www.amazon.com/dp/B09XS7JWHH","canonical_url":"https://www.amazon.com/dp/B09XS7JWHH"`},
+		{"raw offer urls", "/api/admin/offers/quarantined?raw_urls=true", fiber.StatusOK, `"url":"` + amazonURL + `","canonical_url":"https://www.amazon.com/dp/B09XS7JWHH"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, app, "GET", tt.path)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestOfferCurrencyConversion(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	offer := &models.Offer{ProductID: product.ID, Source: "demo", Seller: "seller", PriceAmount: 9000, Currency: "USD",
+		ShippingToUSAmount: 1000, TotalToUSAmount: 10000, LandedCostAmount: 10550}
+	if err := store.Offers().Create(ctx, offer); err != nil {
+		t.Fatal(err)
+	}
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), nil, nil,
+		shipping.NewCalculator(shipping.Config{Mode: "TABLE", FXUSDJPY: 150}), zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id/offers", h.GetProductOffers)
+	app.Get("/api/products/:id/compare", h.CompareProductOffers)
+
+	path := "/api/products/" + product.ID.String()
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"offers in yen", path + "/offers?currency=jpy", fiber.StatusOK, `"converted":{"currency":"JPY","fx_rate":150,"shipping_amount":1500,"total_amount":15000,"landed_cost_amount":15825}`},
+		{"compare in yen", path + "/compare?currency=JPY", fiber.StatusOK, `"total_amount":15000`},
+		{"usd totals are kept", path + "/compare?currency=JPY", fiber.StatusOK, `"total_to_us_amount":10000`},
+		{"unsupported currency", path + "/offers?currency=EUR", fiber.StatusBadRequest, `"unsupported currency: EUR"`},
+		{"invalid currency", path + "/compare?currency=euro", fiber.StatusBadRequest, `"invalid currency: EURO"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, app, "GET", tt.path)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+	if _, body := doRequest(t, app, "GET", path+"/offers"); strings.Contains(body, "converted") {
+		t.Errorf("offers without currency = %s, want no converted totals", body)
+	}
+}
+
+func TestCompareDestination(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	offer := &models.Offer{ProductID: product.ID, Source: "walmart", Seller: "seller", PriceAmount: 5000, Currency: "USD",
+		FreeShipping: true, TotalToUSAmount: 5000, LandedCostAmount: 5000}
+	if err := store.Offers().Create(ctx, offer); err != nil {
+		t.Fatal(err)
+	}
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), nil, nil,
+		shipping.NewCalculator(shipping.Config{Mode: "TABLE", FXUSDJPY: 150}), zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id/compare", h.CompareProductOffers)
+
+	path := "/api/products/" + product.ID.String() + "/compare"
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"stored totals are to the US", path, fiber.StatusOK, `"total_to_us_amount":5000`},
+		{"totals to Japan", path + "?dest=jp", fiber.StatusOK, `"country":"JP","shipping_amount":3598,"duty_amount":0,"total_amount":8598`},
+		{"US totals are kept", path + "?dest=JP", fiber.StatusOK, `"total_to_us_amount":5000`},
+		{"express to Japan", path + "?dest=JP&speed=express", fiber.StatusOK, `"shipping_amount":7196`},
+		{"shipping options have delivery dates", path + "?dest=JP", fiber.StatusOK, `"updated_at":"0001-01-01T00:00:00Z","delivery_window":{"earliest":`},
+		{"unsupported destination", path + "?dest=ZZ", fiber.StatusBadRequest, `"unsupported destination: ZZ"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, app, "GET", tt.path)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCompareOverrides(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	calc := shipping.NewCalculator(shipping.Config{Mode: "TABLE", FXUSDJPY: 150})
+	for _, offer := range []*models.Offer{
+		{ProductID: product.ID, Source: "walmart", Seller: "us", PriceAmount: 11000, Currency: "USD", FreeShipping: true},
+		{ProductID: product.ID, Source: "amazon", Seller: "jp", PriceAmount: 15000, Currency: "JPY", FreeShipping: true},
+	} {
+		if err := jobs.PriceOffer(calc, offer, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), nil, nil, calc, zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id/compare", h.CompareProductOffers)
+	path := "/api/products/" + product.ID.String() + "/compare"
+
+	// The yen offer is the cheaper one at 150 JPY/USD, but not at 100
+	_, body := doRequest(t, app, "GET", path)
+	if strings.Index(body, `"seller":"jp"`) > strings.Index(body, `"seller":"us"`) || strings.Contains(body, `"overrides"`) {
+		t.Errorf("compare = %s, want the yen offer first and no overrides", body)
+	}
+	code, body := doRequest(t, app, "GET", path+"?fx=jpy:100&fee_percent=10&currency=JPY")
+	if code != fiber.StatusOK {
+		t.Fatalf("compare with overrides = %d %s", code, body)
+	}
+	for _, want := range []string{
+		`"total_to_us_amount":12100`, `"total_to_us_amount":16500`, `"fee_amount":1500`,
+		`"converted":{"currency":"JPY","fx_rate":100,"shipping_amount":0,"total_amount":16500`, `"overrides":{"fee_percent":10,"fx":{"JPY":100}}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("compare with overrides = %s, want %s", body, want)
+		}
+	}
+	if strings.Index(body, `"seller":"us"`) > strings.Index(body, `"seller":"jp"`) {
+		t.Errorf("compare with overrides = %s, want the dollar offer first", body)
+	}
+
+	// Nothing is stored
+	offers, _ := store.Offers().GetByProductID(ctx, product.ID)
+	for _, offer := range offers {
+		if offer.FeeAmount != 0 {
+			t.Errorf("stored offer %s fee = %d, want 0", offer.Seller, offer.FeeAmount)
+		}
+	}
+
+	for _, query := range []string{"?fee_percent=150", "?fee_percent=abc", "?fx=JPY", "?fx=USD:2", "?fx=JPY:0"} {
+		if code, body := doRequest(t, app, "GET", path+query); code != fiber.StatusBadRequest {
+			t.Errorf("compare%s = %d %s, want 400", query, code, body)
+		}
+	}
+}
+
+func TestManualOffers(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	fetched := &models.Offer{ProductID: product.ID, Source: "walmart", Seller: "Walmart", PriceAmount: 30000, Currency: "USD", TotalToUSAmount: 30000}
+	if err := store.Offers().Create(ctx, fetched); err != nil {
+		t.Fatal(err)
+	}
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), nil, nil,
+		shipping.NewCalculator(shipping.Config{Mode: "TABLE", FXUSDJPY: 150}), zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id/offers", h.GetProductOffers)
+	app.Post("/api/admin/offers", h.CreateManualOffer)
+	app.Patch("/api/admin/offers/:id", h.UpdateOffer)
+
+	body := `{"product_id":"` + product.ID.String() + `","seller":"Corner Store","price_amount":27900,"notes":"quoted by phone"}`
+	code, resp := doJSONRequest(t, app, "POST", "/api/admin/offers", body)
+	if code != fiber.StatusCreated || !strings.Contains(resp, `"source":"manual"`) || !strings.Contains(resp, `"source_kind":"manual"`) ||
+		!strings.Contains(resp, `"total_to_us_amount":29899`) || !strings.Contains(resp, `"expires_at"`) {
+		t.Fatalf("create = %d %s", code, resp)
+	}
+	var created models.Offer
+	if err := json.Unmarshal([]byte(resp), &created); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := doJSONRequest(t, app, "POST", "/api/admin/offers", body); code != fiber.StatusConflict {
+		t.Errorf("duplicate create = %d %s, want 409", code, resp)
+	}
+
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"missing seller", created.ID, `{"seller":" "}`, fiber.StatusBadRequest, "seller is required"},
+		{"past expiry", created.ID, `{"expires_at":"2020-01-01T00:00:00Z"}`, fiber.StatusBadRequest, "expires_at must be in the future"},
+		{"reprice", created.ID, `{"price_amount":25000}`, fiber.StatusOK, `"total_to_us_amount":26999`},
+		{"notes on a fetched offer", fetched.ID, `{"notes":"price matched in store"}`, fiber.StatusOK, `"notes":"price matched in store"`},
+		{"price of a fetched offer", fetched.ID, `{"price_amount":1}`, fiber.StatusBadRequest, "only notes can be edited"},
+		{"unknown offer", uuid.New(), `{"notes":"x"}`, fiber.StatusNotFound, "offer not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doJSONRequest(t, app, "PATCH", "/api/admin/offers/"+tt.id.String(), tt.body)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	// An expired manual offer is no longer published
+	expired := time.Now().Add(-time.Hour)
+	offer, _ := store.Offers().GetByID(ctx, created.ID)
+	offer.ExpiresAt = &expired
+	if err := store.Offers().Update(ctx, offer); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := doRequest(t, app, "GET", "/api/products/"+product.ID.String()+"/offers"); strings.Contains(body, "Corner Store") {
+		t.Errorf("offers = %s, want the expired manual offer left out", body)
+	}
+}
+
+func TestQuarantinedOffersAreNotPublished(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	reason := models.OfferQuarantinePriceBelowMedian
+	if err := store.Offers().Create(ctx, &models.Offer{ProductID: product.ID, Source: "demo", Seller: "seller", TotalToUSAmount: 100, QuarantineReason: &reason}); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, store)
+
+	if code, body := doRequest(t, app, "GET", "/api/products/"+product.ID.String()+"/offers"); code != fiber.StatusOK || body != `{"offers":[],"page":1,"per_page":50,"total":0}` {
+		t.Errorf("offers = %d %s, want no offers", code, body)
+	}
+	if code, body := doRequest(t, app, "GET", "/api/search?query=headphones"); code != fiber.StatusOK || strings.Contains(body, "min_price_cents") {
+		t.Errorf("search = %d %s, want the product without a price", code, body)
+	}
+}
+
+func TestSearchGroupsSharedIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	var products []*models.Product
+	for _, title := range []string{"Sony WH-1000XM5 Headphones", "SONY WH1000XM5 Wireless Headphones", "Sony WH-CH720N Headphones"} {
+		product := &models.Product{Title: title}
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		products = append(products, product)
+	}
+	for i, ident := range []*models.ProductIdentifier{
+		{ProductID: products[0].ID, Type: "UPC", Value: "027242923782"},
+		{ProductID: products[1].ID, Type: "EAN", Value: "0027242923782"},
+		{ProductID: products[2].ID, Type: "UPC", Value: "027242925847"},
+	} {
+		if err := store.ProductIdentifiers().Create(ctx, ident); err != nil {
+			t.Fatalf("identifier %d: %v", i, err)
+		}
+	}
+	app := newTestApp(t, store)
+
+	code, body := doRequest(t, app, "GET", "/api/search?query=headphones&dedupe=true")
+	if code != fiber.StatusOK {
+		t.Fatalf("search = %d %s", code, body)
+	}
+	if got := strings.Count(body, `"title"`); got != 2 {
+		t.Errorf("search returned %d products, want 2 (body %s)", got, body)
+	}
+	if !strings.Contains(body, `"duplicates":["`) || strings.Count(body, `"duplicates"`) != 1 {
+		t.Errorf("body %s should list one group of duplicates", body)
+	}
+	if !strings.Contains(body, `"total":3`) {
+		t.Errorf("body %s should keep the total of matching products", body)
+	}
+
+	for _, path := range []string{"/api/search?query=headphones", "/api/search?query=headphones&dedupe=false"} {
+		code, body = doRequest(t, app, "GET", path)
+		if code != fiber.StatusOK || strings.Count(body, `"title"`) != 3 || strings.Contains(body, "duplicates") {
+			t.Errorf("%s = %d %s, want every product", path, code, body)
+		}
+	}
+}
+
+func TestSearchOfferAggregates(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	delisted := time.Now().Add(-time.Hour)
+	for i, offer := range []*models.Offer{
+		{Seller: "a", TotalToUSAmount: 32000, InStock: true},
+		{Seller: "b", TotalToUSAmount: 29900, InStock: false},
+		{Seller: "c", TotalToUSAmount: 31000, InStock: true},
+		{Seller: "d", TotalToUSAmount: 10000, InStock: true, DelistedAt: &delisted},
+	} {
+		offer.ProductID = product.ID
+		offer.Source = "demo"
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatalf("offer %d: %v", i, err)
+		}
+	}
+	bare := &models.Product{Title: "Sony WH-CH720N Headphones"}
+	if err := store.Products().Create(ctx, bare); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, store)
+
+	code, body := doRequest(t, app, "GET", "/api/search?query=headphones")
+	if code != fiber.StatusOK {
+		t.Fatalf("search = %d %s", code, body)
+	}
+	for _, want := range []string{
+		`"min_price_cents":29900,"offer_count":3,"in_stock_count":2`,
+		`"offer_count":0,"in_stock_count":0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body %s should contain %s", body, want)
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	sony := "Sony"
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones", Brand: &sony}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Offers().Create(ctx, &models.Offer{ProductID: product.ID, Source: "amazon", Seller: "seller", TotalToUSAmount: 32000}); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, store)
+
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantTotal string
+	}{
+		{"query=headph&brand=sony&source=amazon", fiber.StatusOK, `"total":1`},
+		{"query=headphones&brand=bose", fiber.StatusOK, `"total":0`},
+		{"query=headphones&min_price_cents=30000&max_price_cents=35000", fiber.StatusOK, `"total":1`},
+		{"query=headphones&max_price_cents=30000", fiber.StatusOK, `"total":0`},
+		{"query=headphones&min_price_cents=-1", fiber.StatusBadRequest, ""},
+		{"query=headphones&max_price_cents=cheap", fiber.StatusBadRequest, ""},
+		{"query=headphones&min_price_cents=500&max_price_cents=100", fiber.StatusBadRequest, ""},
+		{"query=headphones&min_price=300&max_price=320", fiber.StatusOK, `"total":1`},
+		{"query=headphones&max_price=319.99", fiber.StatusOK, `"total":0`},
+		{"query=headphones&in_stock=true", fiber.StatusOK, `"total":0`},
+		{"query=headphones&min_price=-5", fiber.StatusBadRequest, ""},
+		{"query=headphones&max_price=300&max_price_cents=30000", fiber.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		code, body := doRequest(t, app, "GET", "/api/search?"+tt.query)
+		if code != tt.wantCode || !strings.Contains(body, tt.wantTotal) {
+			t.Errorf("search?%s = %d %s, want %d %s", tt.query, code, body, tt.wantCode, tt.wantTotal)
+		}
+	}
+}
+
+func TestSearchFacets(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	sony, bose := "Sony", "Bose"
+	for _, item := range []struct {
+		product *models.Product
+		offers  []*models.Offer
+	}{
+		{&models.Product{Title: "Sony WH-1000XM5 Headphones", Brand: &sony}, []*models.Offer{
+			{Source: "amazon", Seller: "a", TotalToUSAmount: 32000, InStock: true},
+			{Source: "walmart", Seller: "w", TotalToUSAmount: 34000},
+		}},
+		{&models.Product{Title: "Sony WH-CH520 Headphones", Brand: &sony}, []*models.Offer{
+			{Source: "amazon", Seller: "a", TotalToUSAmount: 4500},
+		}},
+		{&models.Product{Title: "Bose QuietComfort Headphones", Brand: &bose}, []*models.Offer{
+			{Source: "walmart", Seller: "w", TotalToUSAmount: 60000, InStock: true},
+		}},
+	} {
+		if err := store.Products().Create(ctx, item.product); err != nil {
+			t.Fatal(err)
+		}
+		for _, offer := range item.offers {
+			offer.ProductID = item.product.ID
+			if err := store.Offers().Create(ctx, offer); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	app := newTestApp(t, store)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"query=headphones&per_page=1", `"facets":{"brand":{"Bose":1,"Sony":2},"in_stock":{"false":1,"true":2},` +
+			`"price":{"2500-5000":1,"25000-50000":1,"50000-":1},"sources":{"amazon":2,"walmart":2}}`},
+		// Facets count the offers passing the filters
+		{"query=headphones&in_stock=true&source=amazon", `"facets":{"brand":{"Sony":1},"in_stock":{"true":1},` +
+			`"price":{"25000-50000":1},"sources":{"amazon":1}}`},
+	}
+	for _, tt := range tests {
+		code, body := doRequest(t, app, "GET", "/api/search?"+tt.query)
+		if code != fiber.StatusOK || !strings.Contains(body, tt.want) {
+			t.Errorf("search?%s = %d %s, want %s", tt.query, code, body, tt.want)
+		}
+	}
+}
+
+func TestGetStockHistory(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Nintendo Switch OLED Model"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	for _, inStock := range []bool{false, true, false, true} {
+		if err := store.StockEvents().Record(ctx, &models.StockEvent{OfferID: uuid.New(), ProductID: product.ID, Source: "demo", Seller: "seller", InStock: inStock}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id/stock-history", h.GetStockHistory)
+
+	path := "/api/products/" + product.ID.String() + "/stock-history"
+	if code, _ := doRequest(t, app, "GET", path); code != fiber.StatusNotFound {
+		t.Errorf("stock history without EnableStockHistory = %d, want 404", code)
+	}
+	h.EnableStockHistory(store.StockEvents())
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"history", path, fiber.StatusOK, `"out_of_stocks":2,"product_id":"` + product.ID.String() + `","restocks":2`},
+		{"newest first", path, fiber.StatusOK, `"events":[{"id":4,`},
+		{"unknown product", "/api/products/" + uuid.NewString() + "/stock-history", fiber.StatusOK, `"events":[],"out_of_stocks":0`},
+		{"invalid product id", "/api/products/123/stock-history", fiber.StatusBadRequest, `"invalid product id"`},
+		{"invalid days", path + "?days=400", fiber.StatusBadRequest, `"days must be between 1 and 365"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, app, "GET", tt.path)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGetJobRun(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/admin/jobs/:id", h.GetJobRun)
+
+	if code, _ := doRequest(t, app, "GET", "/api/admin/jobs/task-1"); code != fiber.StatusNotFound {
+		t.Errorf("job run without EnableJobRuns = %d, want 404", code)
+	}
+	h.EnableJobRuns(store.JobRuns())
+
+	productID := uuid.New()
+	running := &models.JobRun{ID: "task-1", Type: "fetch_prices", Source: "demo"}
+	finished := &models.JobRun{ID: "task-2", Type: "fetch_prices", Source: "demo"}
+	for _, jobRun := range []*models.JobRun{running, finished} {
+		if err := store.JobRuns().Start(ctx, jobRun); err != nil {
+			t.Fatal(err)
+		}
+	}
+	finished.Status = models.JobRunStatusCompleted
+	finished.Products = models.JobRunProducts{
+		{Source: "demo", Title: "Nintendo Switch OLED Model", ProductID: productID, Action: models.JobRunProductCreated, MatchMethod: models.MatchMethodNew},
+		{Source: "demo", Title: "Sony WH-1000XM5", ProductID: uuid.New(), Action: models.JobRunProductUpdated, MatchMethod: "title"},
+	}
+	if err := store.JobRuns().Finish(ctx, finished); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"running", "/api/admin/jobs/task-1", fiber.StatusOK, `"status":"running","products":[]`},
+		{"finished", "/api/admin/jobs/task-2", fiber.StatusOK, `"product_id":"` + productID.String() + `","action":"created"`},
+		{"counts", "/api/admin/jobs/task-2", fiber.StatusOK, `"products_created":1,"products_updated":1`},
+		{"unknown job", "/api/admin/jobs/task-3", fiber.StatusNotFound, `"job run not found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, app, "GET", tt.path)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListOfferMerges(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	merge := &models.OfferMerge{ProductID: product.ID, Source: "demo", KeptOfferID: uuid.New(), KeptSeller: "Best Buy", MergedSeller: "BestBuy", MergedPriceAmount: 32999, MergedCurrency: "USD", Reason: models.OfferMergeReasonSellerName}
+	if _, err := store.OfferMergeLog().Record(ctx, merge); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/admin/products/:id/offer-merges", h.ListOfferMerges)
+
+	path := "/api/admin/products/" + product.ID.String() + "/offer-merges"
+	if code, _ := doRequest(t, app, "GET", path); code != fiber.StatusNotFound {
+		t.Errorf("offer merges without EnableOfferMergeLog = %d, want 404", code)
+	}
+	h.EnableOfferMergeLog(store.OfferMergeLog())
+
+	code, body := doRequest(t, app, "GET", path)
+	if code != fiber.StatusOK || !strings.Contains(body, `"merged_seller":"BestBuy"`) || !strings.Contains(body, `"reason":"seller_name"`) {
+		t.Errorf("offer merges = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, app, "GET", "/api/admin/products/123/offer-merges"); code != fiber.StatusBadRequest {
+		t.Errorf("invalid product id = %d, want 400", code)
+	}
+}
+
+func TestMergeCandidateRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	kept := &models.Product{Title: "Widget"}
+	duplicate := &models.Product{Title: "Widget"}
+	for _, product := range []*models.Product{kept, duplicate} {
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	candidate := &models.MergeCandidate{ProductID: kept.ID, DuplicateProductID: duplicate.ID, Reason: models.MergeReasonTitle, Score: 1}
+	if err := store.MergeCandidates().UpsertPending(ctx, candidate); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, store)
+
+	code, body := doRequest(t, app, "GET", "/api/admin/merge-candidates")
+	if code != fiber.StatusOK || !strings.Contains(body, candidate.ID.String()) {
+		t.Fatalf("list = %d %s, want the pending candidate", code, body)
+	}
+	if code, body := doRequest(t, app, "POST", "/api/admin/merge-candidates/"+candidate.ID.String()+"/merge"); code != fiber.StatusOK {
+		t.Fatalf("merge = %d %s, want 200", code, body)
+	}
+	if code, _ := doRequest(t, app, "GET", "/api/products/"+duplicate.ID.String()); code != fiber.StatusNotFound {
+		t.Errorf("merged duplicate = %d, want 404", code)
+	}
+	if code, _ := doRequest(t, app, "POST", "/api/admin/merge-candidates/"+candidate.ID.String()+"/dismiss"); code != fiber.StatusConflict {
+		t.Errorf("dismissing a merged candidate = %d, want 409", code)
+	}
+}
+
+// recordingIndex records the documents upserted into and deleted from a search index
+type recordingIndex struct {
+	searchindex.Index
+	upserted []string
+	deleted  []string
+}
+
+func (r *recordingIndex) Upsert(ctx context.Context, docs []searchindex.Document) error {
+	for _, doc := range docs {
+		r.upserted = append(r.upserted, doc.ID)
+	}
+	return nil
+}
+
+func (r *recordingIndex) Delete(ctx context.Context, ids []string) error {
+	r.deleted = append(r.deleted, ids...)
+	return nil
+}
+
+func TestMergeProduct(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	kept := &models.Product{Title: "Sony WH-1000XM4"}
+	duplicate := &models.Product{Title: "Sony WH1000XM4 Headphones"}
+	other := &models.Product{Title: "Sony WH-1000XM5"}
+	for _, product := range []*models.Product{kept, duplicate, other} {
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dismissed := &models.MergeCandidate{ProductID: kept.ID, DuplicateProductID: other.ID, Reason: models.MergeReasonTitle, Score: 0.8}
+	if err := store.MergeCandidates().UpsertPending(ctx, dismissed); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MergeCandidates().Dismiss(ctx, dismissed.ID); err != nil {
+		t.Fatal(err)
+	}
+	index := &recordingIndex{}
+	h := newTestHandlers(t, store)
+	h.EnableSearchIndex(index, jobs.NewSearchIndexer(store.Products(), index, zap.NewNop()))
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id", h.GetProduct)
+	app.Post("/api/admin/products/:id/merge", h.MergeProduct)
+
+	path := "/api/admin/products/" + kept.ID.String() + "/merge"
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"invalid product id", "/api/admin/products/abc/merge", `{"duplicate_product_id":"` + duplicate.ID.String() + `"}`, fiber.StatusBadRequest},
+		{"missing duplicate", path, `{}`, fiber.StatusBadRequest},
+		{"into itself", path, `{"duplicate_product_id":"` + kept.ID.String() + `"}`, fiber.StatusBadRequest},
+		{"unknown duplicate", path, `{"duplicate_product_id":"` + uuid.New().String() + `"}`, fiber.StatusNotFound},
+		{"dismissed pair", path, `{"duplicate_product_id":"` + other.ID.String() + `"}`, fiber.StatusConflict},
+		{"merge", path, `{"duplicate_product_id":"` + duplicate.ID.String() + `"}`, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := doJSONRequest(t, app, "POST", tt.path, tt.body); code != tt.wantCode {
+				t.Errorf("status = %d %s, want %d", code, body, tt.wantCode)
+			}
+		})
+	}
+
+	if code, _ := doRequest(t, app, "GET", "/api/products/"+duplicate.ID.String()); code != fiber.StatusNotFound {
+		t.Errorf("merged duplicate = %d, want 404", code)
+	}
+	merged, err := store.MergeCandidates().ListByStatus(ctx, models.MergeStatusMerged, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 1 || merged[0].Reason != models.MergeReasonManual {
+		t.Errorf("merged candidates = %+v, want one manual merge", merged)
+	}
+	if !slices.Equal(index.deleted, []string{duplicate.ID.String()}) || !slices.Equal(index.upserted, []string{kept.ID.String()}) {
+		t.Errorf("search index deleted %v and upserted %v, want the duplicate deleted and the kept product refreshed", index.deleted, index.upserted)
+	}
+}
+
+func TestPriceAlertRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/api/alerts", h.CreatePriceAlert)
+	app.Get("/api/alerts/:id", h.GetPriceAlert)
+	app.Delete("/api/alerts/:id", h.DeletePriceAlert)
+
+	if code, _ := doJSONRequest(t, app, "POST", "/api/alerts", `{}`); code != fiber.StatusNotFound {
+		t.Errorf("create without EnablePriceAlerts = %d, want 404", code)
+	}
+	h.EnablePriceAlerts(store.PriceAlerts(), []string{models.AlertChannelWebhook}, nil)
+
+	productID := product.ID.String()
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"webhook", `{"product_id":"` + productID + `","channel":"webhook","target":"https://example.com/hook","target_price_cents":25000}`, fiber.StatusCreated, `"target_price_cents":25000`},
+		{"email without SMTP", `{"product_id":"` + productID + `","channel":"email","target":"buyer@example.com","target_price_cents":25000}`, fiber.StatusBadRequest, `"unsupported channel"`},
+		{"invalid webhook URL", `{"product_id":"` + productID + `","channel":"webhook","target":"ftp://example.com","target_price_cents":25000}`, fiber.StatusBadRequest, `"target must be`},
+		{"non-positive price", `{"product_id":"` + productID + `","channel":"webhook","target":"https://example.com/hook","target_price_cents":0}`, fiber.StatusBadRequest, `"target_price_cents must be positive"`},
+		{"unknown product", `{"product_id":"` + uuid.NewString() + `","channel":"webhook","target":"https://example.com/hook","target_price_cents":25000}`, fiber.StatusNotFound, `"product not found"`},
+		{"invalid product id", `{"product_id":"123","channel":"webhook","target":"https://example.com/hook","target_price_cents":25000}`, fiber.StatusBadRequest, `"invalid product id"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doJSONRequest(t, app, "POST", "/api/alerts", tt.body)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	alerts, _ := store.PriceAlerts().List(ctx)
+	if len(alerts) != 1 {
+		t.Fatalf("stored %d alerts, want 1", len(alerts))
+	}
+	path := "/api/alerts/" + alerts[0].ID.String()
+	if code, body := doRequest(t, app, "GET", path); code != fiber.StatusOK || !strings.Contains(body, `"channel":"webhook"`) {
+		t.Errorf("get = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, app, "DELETE", path); code != fiber.StatusNoContent {
+		t.Errorf("delete = %d, want 204", code)
+	}
+	if code, _ := doRequest(t, app, "GET", path); code != fiber.StatusNotFound {
+		t.Errorf("get after delete = %d, want 404", code)
+	}
+}
+
+type recordingConfirmer struct {
+	tokens []string
+	err    error
+}
+
+func (r *recordingConfirmer) SendConfirmation(ctx context.Context, target, token string, confirmation notifications.AlertConfirmation) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func TestPriceAlertEmailConfirmation(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	confirmer := &recordingConfirmer{}
+	h := newTestHandlers(t, store)
+	h.EnablePriceAlerts(store.PriceAlerts(), []string{models.AlertChannelEmail, models.AlertChannelWebhook}, confirmer)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/api/alerts", h.CreatePriceAlert)
+	app.Get("/api/alerts/confirm/:token", h.ConfirmPriceAlert)
+
+	body := `{"product_id":"` + product.ID.String() + `","channel":"email","target":"buyer@example.com","target_price_cents":25000}`
+	code, created := doJSONRequest(t, app, "POST", "/api/alerts", body)
+	if code != fiber.StatusCreated || strings.Contains(created, "confirmed_at") {
+		t.Fatalf("create = %d %s, want 201 without confirmed_at", code, created)
+	}
+	if len(confirmer.tokens) != 1 || strings.Contains(created, confirmer.tokens[0]) {
+		t.Fatalf("sent tokens %v, want one that is not in the response", confirmer.tokens)
+	}
+	alerts, _ := store.PriceAlerts().List(ctx)
+	if len(alerts) != 1 || alerts[0].Active() {
+		t.Fatalf("stored alerts %+v, want one inactive alert", alerts)
+	}
+
+	if code, _ := doRequest(t, app, "GET", "/api/alerts/confirm/unknown"); code != fiber.StatusNotFound {
+		t.Errorf("confirm with an unknown token = %d, want 404", code)
+	}
+	if code, body := doRequest(t, app, "GET", "/api/alerts/confirm/"+confirmer.tokens[0]); code != fiber.StatusOK || !strings.Contains(body, `"confirmed_at"`) {
+		t.Errorf("confirm = %d %s", code, body)
+	}
+	if stored, _ := store.PriceAlerts().GetByID(ctx, alerts[0].ID); !stored.Active() {
+		t.Errorf("alert = %+v, want active after confirming", stored)
+	}
+
+	// An alert whose confirmation could not be sent is not kept
+	confirmer.err = errors.New("connection refused")
+	if code, _ := doJSONRequest(t, app, "POST", "/api/alerts", body); code != fiber.StatusInternalServerError {
+		t.Errorf("create with a failed confirmation = %d, want 500", code)
+	}
+	if alerts, _ := store.PriceAlerts().List(ctx); len(alerts) != 1 {
+		t.Errorf("stored %d alerts, want 1", len(alerts))
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	brand := "Sony"
+	product := &models.Product{Title: "Sony WH1000XM5 Headphone", Brand: &brand}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	index := &recordingIndex{}
+	h := newTestHandlers(t, store)
+	h.EnableSearchIndex(index, jobs.NewSearchIndexer(store.Products(), index, zap.NewNop()))
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Patch("/api/admin/products/:id", h.UpdateProduct)
+	app.Get("/api/admin/products/:id/revisions", h.ListProductRevisions)
+
+	path := "/api/admin/products/" + product.ID.String()
+	if code, _ := doJSONRequest(t, app, "PATCH", path, `{"title":"x"}`); code != fiber.StatusNotFound {
+		t.Errorf("update without EnableProductEditing = %d, want 404", code)
+	}
+	h.EnableProductEditing(store.ProductRevisions())
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"no fields", path, `{"reason":"typo"}`, fiber.StatusBadRequest, `"no fields to update"`},
+		{"empty title", path, `{"title":"  "}`, fiber.StatusBadRequest, `"title must not be empty"`},
+		{"invalid image URL", path, `{"image_url":"ftp://example.com/a.jpg"}`, fiber.StatusBadRequest, `"image_url must be an http(s) URL"`},
+		{"unknown category", path, `{"category":"gadgets"}`, fiber.StatusBadRequest, `"unknown category"`},
+		{"unknown product", "/api/admin/products/" + uuid.NewString(), `{"title":"Sony WH-1000XM5"}`, fiber.StatusNotFound, `"product not found"`},
+		{"invalid product id", "/api/admin/products/123", `{"title":"Sony WH-1000XM5"}`, fiber.StatusBadRequest, `"invalid product id"`},
+		{"unchanged", path, `{"brand":"Sony"}`, fiber.StatusOK, `"revision":null`},
+		{"edit", path, `{"title":" Sony WH-1000XM5 Headphones ","brand":"","category":"audio","reason":"fix title"}`, fiber.StatusOK, `"title":{"old":"Sony WH1000XM5 Headphone","new":"Sony WH-1000XM5 Headphones"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doJSONRequest(t, app, "PATCH", tt.path, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	stored, _ := store.Products().GetByID(ctx, product.ID)
+	if stored.Title != "Sony WH-1000XM5 Headphones" || stored.Brand != nil || stored.Category == nil || *stored.Category != "audio" {
+		t.Errorf("stored product = %+v", stored)
+	}
+	code, body := doRequest(t, app, "GET", path+"/revisions")
+	if code != fiber.StatusOK || strings.Count(body, `"changes"`) != 1 ||
+		!strings.Contains(body, `"brand":{"old":"Sony","new":null}`) || !strings.Contains(body, `"reason":"fix title"`) {
+		t.Errorf("revisions = %d %s", code, body)
+	}
+	if len(index.upserted) != 1 || index.upserted[0] != product.ID.String() {
+		t.Errorf("reindexed %v, want the edited product", index.upserted)
+	}
+
+	// An edit racing another one is applied on top of it
+	h.EnableProductEditing(&racingRevisions{ProductRevisionStore: store.ProductRevisions(), store: store})
+	if code, body := doJSONRequest(t, app, "PATCH", path, `{"model":"WH-1000XM5"}`); code != fiber.StatusOK {
+		t.Fatalf("racing edit = %d %s", code, body)
+	}
+	stored, _ = store.Products().GetByID(ctx, product.ID)
+	if stored.Model == nil || *stored.Model != "WH-1000XM5" || stored.Brand == nil || *stored.Brand != "Sony (racing)" {
+		t.Errorf("stored product after racing edits = %+v, want both edits", stored)
+	}
+}
+
+// racingRevisions edits the brand of a product just before the first edit it applies,
+// like a concurrent request
+type racingRevisions struct {
+	repository.ProductRevisionStore
+	store *memory.Store
+	raced bool
+}
+
+func (r *racingRevisions) Apply(ctx context.Context, product *models.Product, revision *models.ProductRevision) error {
+	if !r.raced {
+		r.raced = true
+		current, err := r.store.Products().GetByID(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+		brand := "Sony (racing)"
+		current.Brand = &brand
+		racing := &models.ProductRevision{Changes: models.FieldChanges{models.ProductFieldBrand: {New: &brand}}}
+		if err := r.ProductRevisionStore.Apply(ctx, current, racing); err != nil {
+			return err
+		}
+	}
+	return r.ProductRevisionStore.Apply(ctx, product, revision)
+}
+
+func TestProductTags(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	var products []*models.Product
+	for _, title := range []string{"Sony WH-1000XM5 Headphones", "Sony WF-1000XM5 Earbuds", "Apple AirPods Pro"} {
+		product := &models.Product{Title: title}
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		products = append(products, product)
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/search", h.Search)
+	app.Get("/api/products/:id", h.GetProduct)
+	app.Get("/api/admin/tags", h.ListTags)
+	app.Post("/api/admin/products/bulk", h.BulkUpdateProducts)
+	app.Post("/api/admin/products/:id/tags", h.AddProductTags)
+	app.Delete("/api/admin/products/:id/tags/:tag", h.RemoveProductTag)
+
+	if code, _ := doRequest(t, app, "GET", "/api/admin/tags"); code != fiber.StatusNotFound {
+		t.Errorf("tags without EnableProductTags = %d, want 404", code)
+	}
+	h.EnableProductTags(store.ProductTags())
+	h.EnableProductEditing(store.ProductRevisions())
+
+	airpods := "/api/admin/products/" + products[2].ID.String()
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"tag product", "POST", airpods + "/tags", `{"tags":["Black  Friday Deals","gift"]}`, fiber.StatusOK, `"tags":["black friday deals","gift"]`},
+		{"empty tag", "POST", airpods + "/tags", `{"tags":[" "]}`, fiber.StatusBadRequest, `"tags must not be empty"`},
+		{"tag unknown product", "POST", "/api/admin/products/" + uuid.NewString() + "/tags", `{"tags":["gift"]}`, fiber.StatusNotFound, `"product not found"`},
+		{"bulk by search", "POST", "/api/admin/products/bulk", `{"query":"sony","add_tags":["black friday deals"],"category":"audio"}`, fiber.StatusOK, `"matched":2,"recategorized":2,"tags_added":2,"tags_removed":0`},
+		{"bulk by id", "POST", "/api/admin/products/bulk", `{"product_ids":["` + products[0].ID.String() + `"],"remove_tags":["black friday deals"]}`, fiber.StatusOK, `"matched":1,"recategorized":0,"tags_added":0,"tags_removed":1`},
+		{"bulk unknown id", "POST", "/api/admin/products/bulk", `{"product_ids":["` + products[0].ID.String() + `","` + uuid.Nil.String() + `"],"add_tags":["gift"]}`, fiber.StatusNotFound, `"product not found"`},
+		{"bulk without selection", "POST", "/api/admin/products/bulk", `{"add_tags":["gift"]}`, fiber.StatusBadRequest, `"either product_ids or query/tag is required"`},
+		{"bulk without changes", "POST", "/api/admin/products/bulk", `{"query":"sony"}`, fiber.StatusBadRequest, `"no changes requested"`},
+		{"bulk unknown category", "POST", "/api/admin/products/bulk", `{"query":"sony","category":"gadgets"}`, fiber.StatusBadRequest, `"unknown category"`},
+		{"search by tag", "GET", "/api/search?tag=Black%20Friday%20Deals", "", fiber.StatusOK, `"total":2`},
+		{"search by query and tag", "GET", "/api/search?query=sony&tag=black%20friday%20deals", "", fiber.StatusOK, `"total":1`},
+		{"list tags", "GET", "/api/admin/tags", "", fiber.StatusOK, `{"tags":[{"tag":"black friday deals","product_count":2},{"tag":"gift","product_count":1}]}`},
+		{"remove tag", "DELETE", airpods + "/tags/gift", "", fiber.StatusNoContent, ""},
+		{"remove missing tag", "DELETE", airpods + "/tags/gift", "", fiber.StatusNotFound, `"tag not found"`},
+		{"product tags", "GET", "/api/products/" + products[2].ID.String(), "", fiber.StatusOK, `"tags":["black friday deals"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doJSONRequest(t, app, tt.method, tt.path, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	if revisions, _ := store.ProductRevisions().ListByProductID(ctx, products[1].ID, 10); len(revisions) != 1 {
+		t.Errorf("bulk re-categorization recorded %d revisions, want 1", len(revisions))
+	}
+}
+
+func TestFetchScheduleRoutes(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	if err := store.FetchSchedules().ReplaceConfigured(ctx, map[string]string{"walmart": "0 */6 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/admin/schedules", h.ListFetchSchedules)
+	app.Post("/api/admin/schedules", h.CreateFetchSchedule)
+	app.Post("/api/admin/schedules/:id/pause", h.PauseFetchSchedule)
+	app.Post("/api/admin/schedules/:id/resume", h.ResumeFetchSchedule)
+	app.Delete("/api/admin/schedules/:id", h.DeleteFetchSchedule)
+
+	if code, _ := doRequest(t, app, "GET", "/api/admin/schedules"); code != fiber.StatusNotFound {
+		t.Errorf("list without EnableFetchSchedules = %d, want 404", code)
+	}
+	scheduler := jobs.NewInlineScheduler(jobs.NewInlineQueue(nil, 1, 1, nil, zap.NewNop()), zap.NewNop())
+	h.EnableFetchSchedules(store.FetchSchedules(), jobs.NewFetchScheduler(store.FetchSchedules(), scheduler, zap.NewNop()))
+
+	code, body := doJSONRequest(t, app, "POST", "/api/admin/schedules", `{"source":"amazon","cron":"@every 12h"}`)
+	if code != fiber.StatusCreated || !strings.Contains(body, `"origin":"api"`) {
+		t.Fatalf("create = %d %s", code, body)
+	}
+	var created models.FetchSchedule
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := store.FetchSchedules().List(ctx)
+	envSchedule := list[0]
+	path := "/api/admin/schedules/" + created.ID.String()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"invalid source", "POST", "/api/admin/schedules", `{"source":"etsy","cron":"0 * * * *"}`, fiber.StatusBadRequest, `"invalid source"`},
+		{"invalid cron", "POST", "/api/admin/schedules", `{"source":"amazon","cron":"every hour"}`, fiber.StatusBadRequest, `"invalid cron: `},
+		{"list", "GET", "/api/admin/schedules", "", fiber.StatusOK, `"source":"walmart","cron":"0 */6 * * *","origin":"env","paused":false`},
+		{"pause", "POST", path + "/pause", "", fiber.StatusOK, `"paused":true`},
+		{"resume", "POST", path + "/resume", "", fiber.StatusOK, `"paused":false`},
+		{"pause unknown", "POST", "/api/admin/schedules/" + uuid.NewString() + "/pause", "", fiber.StatusNotFound, `"schedule not found"`},
+		{"delete env schedule", "DELETE", "/api/admin/schedules/" + envSchedule.ID.String(), "", fiber.StatusConflict, `FETCH_CRON_WALMART`},
+		{"delete", "DELETE", path, "", fiber.StatusNoContent, ""},
+		{"delete again", "DELETE", path, "", fiber.StatusNotFound, `"schedule not found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doJSONRequest(t, app, tt.method, tt.path, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestResolveURLCanonicalizesURL(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/api/resolve-url", h.ResolveURL)
+
+	code, body := doJSONRequest(t, app, "POST", "/api/resolve-url", `{"url":"WWW.Amazon.com/dp/B08N5WRWNW?utm_source=mail#reviews"}`)
+	if code != fiber.StatusOK || !strings.Contains(body, `"identifier_value":"B08N5WRWNW"`) {
+		t.Fatalf("resolve = %d %s", code, body)
+	}
+	sp, err := store.SourceProducts().FindByProviderAndSourceID(ctx, "amazon", "B08N5WRWNW")
+	if err != nil || sp == nil {
+		t.Fatalf("source product = %v, %v", sp, err)
+	}
+	if sp.URL != "https://www.amazon.com/dp/B08N5WRWNW" {
+		t.Errorf("source product URL = %q, want the canonical URL", sp.URL)
+	}
+}
+
+func TestResolveURLProviders(t *testing.T) {
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/api/resolve-url", h.ResolveURL)
+
+	code, body := doJSONRequest(t, app, "POST", "/api/resolve-url", `{"url":"https://www.walmart.com/ip/Sony-WH-1000XM4/5461164337"}`)
+	if code != fiber.StatusOK || !strings.Contains(body, `"provider":"walmart"`) || !strings.Contains(body, `"identifier_value":"5461164337"`) {
+		t.Errorf("resolve walmart = %d %s", code, body)
+	}
+	code, body = doJSONRequest(t, app, "POST", "/api/resolve-url", `{"url":"https://example.com/item/1"}`)
+	if code != fiber.StatusBadRequest || !strings.Contains(body, `"providers":["aliexpress","amazon","bestbuy","ebay","google_shopping","rakuten","walmart"]`) {
+		t.Errorf("resolve unsupported = %d %s", code, body)
+	}
+}
+
+func TestGetProductLocalizedTitle(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	app := newTestApp(t, store)
+	product := &models.Product{Title: "Sony WH-1000XM5 Wireless Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	japanese, title := "ja", "ソニー ワイヤレスヘッドホン WH-1000XM5"
+	listing := &models.SourceProduct{ProductID: product.ID, Provider: "amazon", SourceID: "B09XS7JWHH", Title: &title, Language: &japanese}
+	if err := store.SourceProducts().Upsert(ctx, listing); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		wantCode int
+		want     string
+		notWant  string
+	}{
+		{"?lang=ja-JP", fiber.StatusOK, `"localized_title":"ソニー ワイヤレスヘッドホン WH-1000XM5"`, ""},
+		{"?lang=en", fiber.StatusOK, `"localized_title":"Sony WH-1000XM5 Wireless Headphones"`, ""},
+		{"?lang=de", fiber.StatusOK, "", "localized_title"},
+		{"", fiber.StatusOK, "", "localized_title"},
+		{"?lang=???", fiber.StatusBadRequest, "invalid lang", ""},
+	}
+	for _, tt := range tests {
+		code, body := doRequest(t, app, "GET", "/api/products/"+product.ID.String()+tt.query)
+		if code != tt.wantCode || !strings.Contains(body, tt.want) || (tt.notWant != "" && strings.Contains(body, tt.notWant)) {
+			t.Errorf("GET %s = %d %s", tt.query, code, body)
+		}
+	}
+}
+
+func TestCompareDisplayTitles(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "ソニー ワイヤレスヘッドホン WH-1000XM5"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	japanese, title := "ja", "ソニー ワイヤレスヘッドホン WH-1000XM5"
+	listing := &models.SourceProduct{ProductID: product.ID, Provider: "amazon", SourceID: "B09XS7JWHH", URL: "https://www.amazon.co.jp/dp/B09XS7JWHH",
+		Title: &title, Language: &japanese, Titles: models.LocalizedTitles{"ja": title, "en": "Sony Wireless Headphones WH-1000XM5"}}
+	if err := store.SourceProducts().Upsert(ctx, listing); err != nil {
+		t.Fatal(err)
+	}
+	url := "https://www.amazon.co.jp/dp/B09XS7JWHH?tag=aff-22"
+	for _, offer := range []*models.Offer{
+		{ProductID: product.ID, Source: "amazon", Seller: "Amazon.co.jp", PriceAmount: 40000, Currency: "JPY", TotalToUSAmount: 30000, URL: &url},
+		{ProductID: product.ID, Source: "walmart", Seller: "Walmart", PriceAmount: 32000, Currency: "USD", TotalToUSAmount: 32000},
+	} {
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id", h.GetProduct)
+	app.Get("/api/products/:id/compare", h.CompareProductOffers)
+	path := "/api/products/" + product.ID.String()
+
+	tests := []struct {
+		query    string
+		wantCode int
+		want     string
+	}{
+		// US-facing by default: the Japanese listing shows its English translation
+		{"/compare", fiber.StatusOK, `"display_title":"Sony Wireless Headphones WH-1000XM5"`},
+		{"/compare", fiber.StatusOK, `"language":"en"`},
+		{"/compare?lang=ja", fiber.StatusOK, `"display_title":"ソニー ワイヤレスヘッドホン WH-1000XM5"`},
+		{"/compare?lang=???", fiber.StatusBadRequest, "invalid lang"},
+		{"?lang=en", fiber.StatusOK, `"localized_title":"Sony Wireless Headphones WH-1000XM5"`},
+	}
+	for _, tt := range tests {
+		code, body := doRequest(t, app, "GET", path+tt.query)
+		if code != tt.wantCode || !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s = %d %s, want %s", tt.query, code, body, tt.want)
+		}
+	}
+	// The walmart offer has no listing
+	if _, body := doRequest(t, app, "GET", path+"/compare"); strings.Count(body, "display_title") != 1 {
+		t.Errorf("compare = %s, want one display title", body)
+	}
+}
+
+func TestSetDisplayTitles(t *testing.T) {
+	now := time.Now()
+	english, older, newer, atURL := "en", "Older listing", "Newer listing", "Listing at the offer URL"
+	listings := []*models.SourceProduct{
+		{Provider: "live", URL: "https://shop.example.com/a", Title: &older, Language: &english, UpdatedAt: now.Add(-time.Hour)},
+		{Provider: "live", URL: "https://shop.example.com/b", Title: &newer, Language: &english, UpdatedAt: now},
+		{Provider: "live", URL: "https://shop.example.com/c", Title: &atURL, Language: &english, UpdatedAt: now.Add(-2 * time.Hour)},
+	}
+	url := "https://shop.example.com/c?utm_source=feed"
+	offers := []*OfferResponse{
+		{Offer: &models.Offer{Source: "live", URL: &url}},
+		{Offer: &models.Offer{Source: "live"}},
+		{Offer: &models.Offer{Source: "walmart"}},
+	}
+	setDisplayTitles(offers, listings, "en")
+
+	want := []*string{&atURL, &newer, nil}
+	for i, offer := range offers {
+		if (offer.DisplayTitle == nil) != (want[i] == nil) || (want[i] != nil && *offer.DisplayTitle != *want[i]) {
+			t.Errorf("offers[%d] display title = %v, want %v", i, offer.DisplayTitle, want[i])
+		}
+	}
+}
+
+func TestSetDeliveryWindows(t *testing.T) {
+	// Wednesday before Thanksgiving, New York time
+	now := time.Date(2026, 11, 25, 17, 0, 0, 0, time.UTC)
+	daysMin, daysMax, expressDays := 1, 3, 2
+	stored := time.Date(2026, 12, 4, 0, 0, 0, 0, time.UTC)
+	offers := []*OfferResponse{
+		{Offer: &models.Offer{EstDeliveryDaysMin: &daysMin, EstDeliveryDaysMax: &daysMax, ShippingOptions: []*models.OfferShippingOption{
+			{Speed: "express", EstDeliveryDaysMin: &expressDays, EstDeliveryDaysMax: &expressDays},
+		}}},
+		{Offer: &models.Offer{EstDeliveryDaysMax: &daysMax, EstimatedDelivery: &stored}},
+		{Offer: &models.Offer{}},
+	}
+	setDeliveryWindows(offers, "US", now)
+
+	if window := offers[0].DeliveryWindow; window == nil || window.Earliest != "2026-11-27" || window.Latest != "2026-12-01" {
+		t.Errorf("delivery window = %+v, want 2026-11-27 to 2026-12-01", window)
+	}
+	if offers[0].EstimatedDelivery == nil || offers[0].EstimatedDelivery.Format(time.DateOnly) != "2026-12-01" {
+		t.Errorf("estimated delivery = %v, want the latest date", offers[0].EstimatedDelivery)
+	}
+	if window := offers[0].ShippingOptions[0].DeliveryWindow; window == nil || window.Earliest != "2026-11-30" {
+		t.Errorf("express delivery window = %+v, want 2026-11-30", window)
+	}
+	// A date reported by the source is kept; a lone maximum is a single day
+	if offers[1].EstimatedDelivery != &stored || offers[1].DeliveryWindow.Earliest != "2026-12-01" {
+		t.Errorf("offer with stored date = %v %+v", offers[1].EstimatedDelivery, offers[1].DeliveryWindow)
+	}
+	if offers[2].DeliveryWindow != nil || offers[2].EstimatedDelivery != nil {
+		t.Errorf("offer without a day range got %+v", offers[2].DeliveryWindow)
+	}
+}
+
+func TestImageSearchUpload(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x*3 + y)})
+		}
+	}
+	product := &models.Product{Title: "Sony WH-1000XM5"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ProductImages().Upsert(ctx, product.ID, "https://img.example.com/xm5.png", imagehash.Compute(img)); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandlers(t, store)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/api/image-search", h.ImageSearch)
+	app.Post("/api/admin/jobs/backfill_image_hashes", h.BackfillImageHashes)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("image", "query.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	form.WriteField("max_distance", "4")
+	form.Close()
+	req := httptest.NewRequest("POST", "/api/image-search", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(respBody), `"distance":0,"dhash_distance":0`) {
+		t.Errorf("upload = %d %s", resp.StatusCode, respBody)
+	}
+
+	code, respText := doJSONRequest(t, app, "POST", "/api/image-search", `{"image_url":"https://img.example.com/query.png"}`)
+	if code != fiber.StatusBadRequest || !strings.Contains(respText, "image_url search is not enabled") {
+		t.Errorf("image_url without hashing = %d %s", code, respText)
+	}
+	if code, _ := doRequest(t, app, "POST", "/api/admin/jobs/backfill_image_hashes"); code != fiber.StatusNotFound {
+		t.Errorf("backfill without hashing = %d, want 404", code)
+	}
+
+	// Download failures do not tell the caller what the URL answered
+	h.EnableImageHashing(nil, imagehash.NewHasher(failingFetcher{}))
+	code, respText = doJSONRequest(t, app, "POST", "/api/image-search", `{"image_url":"https://img.example.com/query.png"}`)
+	if code != fiber.StatusUnprocessableEntity || respText != `{"error":"failed to download image"}` {
+		t.Errorf("failed download = %d %s", code, respText)
+	}
+}
+
+// failingFetcher fails every download with an error naming an internal service
+type failingFetcher struct{}
+
+func (failingFetcher) Get(ctx context.Context, providerKey, targetURL string) (*http.Response, error) {
+	return nil, errors.New("unexpected status 401 from http://10.0.0.5/admin")
+}
+
+func TestSetFreshness(t *testing.T) {
+	h := &Handlers{freshnessSLA: map[string]time.Duration{"amazon": time.Hour, "*": 24 * time.Hour}}
+	now := time.Now()
+	offers := h.offerResponses([]*models.Offer{
+		{Source: "amazon", FetchedAt: now.Add(-90 * time.Minute)},
+		{Source: "live", FetchedAt: now.Add(-90 * time.Minute)},
+		{Source: "live", FetchedAt: now.Add(-25 * time.Hour)},
+	})
+
+	wantStale := []bool{true, false, true}
+	for i, offer := range offers {
+		if offer.Stale != wantStale[i] {
+			t.Errorf("offers[%d] (%s) stale = %v, want %v", i, offer.Source, offer.Stale, wantStale[i])
+		}
+	}
+	if offers[0].AgeSeconds < 5400 || offers[0].AgeSeconds > 5410 {
+		t.Errorf("age_seconds = %d, want about 5400", offers[0].AgeSeconds)
+	}
+}
+
+type fakeQueueInspector struct {
+	stats []jobs.QueueStats
+	err   error
+}
+
+func (f fakeQueueInspector) QueueStats(ctx context.Context) ([]jobs.QueueStats, error) {
+	return f.stats, f.err
+}
+
+func TestDeepHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		inspector fakeQueueInspector
+		wantCode  int
+		wantBody  string
+	}{
+		{"idle", fakeQueueInspector{stats: []jobs.QueueStats{{Queue: "default"}}}, fiber.StatusOK, `"status":"ok"`},
+		{"busy", fakeQueueInspector{stats: []jobs.QueueStats{{Queue: "default", Pending: 3, Active: 10, OldestPendingSeconds: 30}}}, fiber.StatusOK, `"pending":3`},
+		{"stuck", fakeQueueInspector{stats: []jobs.QueueStats{{Queue: "default", Pending: 3, OldestPendingSeconds: 900}}}, fiber.StatusServiceUnavailable, `oldest pending job has waited 900s`},
+		// The Redis error is logged, not returned
+		{"redis down", fakeQueueInspector{err: errors.New("dial tcp 10.0.0.5:6379: connection refused")}, fiber.StatusServiceUnavailable, `"problems":["failed to inspect job queues"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, zap.NewNop())
+			h.EnableQueueHealth(tt.inspector, 10*time.Minute)
+			app := fiber.New()
+			app.Get("/health/deep", h.DeepHealth)
+
+			code, body := doRequest(t, app, "GET", "/health/deep")
+			if code != tt.wantCode || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("GET /health/deep = %d %s, want %d containing %s", code, body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSelfTestCachesReport(t *testing.T) {
+	store := memory.New()
+	h := newTestHandlers(t, store)
+	runs := 0
+	h.EnableSelfTest(func(ctx context.Context) selftest.Report {
+		runs++
+		return selftest.Report{Passed: true, Results: []selftest.Result{{Name: "config", Status: selftest.StatusPass}}}
+	})
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/api/admin/selftest", h.SelfTest)
+
+	for i := 0; i < 2; i++ {
+		if code, body := doRequest(t, app, "POST", "/api/admin/selftest"); code != fiber.StatusOK {
+			t.Fatalf("selftest = %d %s, want 200", code, body)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("self-test ran %d times, want the second request served from the cache", runs)
+	}
+}
+
+func doRequest(t *testing.T, app *fiber.App, method, path string) (int, string) {
+	t.Helper()
+	return doJSONRequest(t, app, method, path, "")
+}
+
+// doJSONRequest sends body (if not empty) as JSON
+func doJSONRequest(t *testing.T, app *fiber.App, method, path, body string) (int, string) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") == fiber.MIMEApplicationJSON && !json.Valid(respBody) {
+		t.Fatalf("invalid JSON response: %s", respBody)
+	}
+	return resp.StatusCode, string(respBody)
+}
+
+func TestCompareDealScore(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	days := func(n int) *int { return &n }
+	rating := func(r float64) *float64 { return &r }
+	for _, offer := range []*models.Offer{
+		{ProductID: product.ID, Source: "demo", Seller: "cheap", PriceAmount: 9000, Currency: "USD", TotalToUSAmount: 9000,
+			SellerRating: rating(2), EstDeliveryDaysMin: days(25)},
+		{ProductID: product.ID, Source: "demo", Seller: "reliable", PriceAmount: 10000, Currency: "USD", TotalToUSAmount: 10000,
+			SellerRating: rating(5), EstDeliveryDaysMin: days(2)},
+	} {
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), nil, nil,
+		shipping.NewCalculator(shipping.Config{Mode: "TABLE", FXUSDJPY: 150}), zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/products/:id/compare", h.CompareProductOffers)
+	path := "/api/products/" + product.ID.String() + "/compare"
+
+	_, body := doRequest(t, app, "GET", path)
+	if strings.Index(body, `"seller":"cheap"`) > strings.Index(body, `"seller":"reliable"`) {
+		t.Errorf("compare = %s, want the cheaper offer first", body)
+	}
+	for _, want := range []string{`"deal_score":44.7`, `"deal_score":66.8`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("compare = %s, want %s", body, want)
+		}
+	}
+	code, body := doRequest(t, app, "GET", path+"?sort=deal_score")
+	if code != fiber.StatusOK {
+		t.Fatalf("compare by deal score = %d %s", code, body)
+	}
+	if strings.Index(body, `"seller":"reliable"`) > strings.Index(body, `"seller":"cheap"`) {
+		t.Errorf("compare by deal score = %s, want the better deal first", body)
+	}
+
+	// Scores are of the stored US totals, which the price history median is of
+	_, body = doRequest(t, app, "GET", path+"?dest=JP")
+	for _, want := range []string{`"country":"JP"`, `"deal_score":44.7`, `"deal_score":66.8`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("compare to JP = %s, want %s", body, want)
+		}
+	}
+}
+
+func TestFetchSourceList(t *testing.T) {
+	list := fetchSourceList()
+	for _, source := range jobs.FetchSources {
+		if !strings.Contains(list, "'"+source+"'") {
+			t.Errorf("fetchSourceList() = %q, want %q listed", list, source)
+		}
+	}
+	if !strings.HasPrefix(list, "'demo', ") || !strings.HasSuffix(list, ", or 'all'") {
+		t.Errorf("fetchSourceList() = %q, want 'demo', ..., or 'all'", list)
+	}
+}
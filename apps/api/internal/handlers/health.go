@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+)
+
+// EnableQueueHealth reports the job queues on GET /health/deep, which fails when a
+// pending job has waited longer than stuckAfter (e.g. all workers are hung)
+func (h *Handlers) EnableQueueHealth(inspector jobs.QueueInspector, stuckAfter time.Duration) {
+	h.queueInspector = inspector
+	h.queueStuckAfter = stuckAfter
+}
+
+// DeepHealth returns queue depth, running jobs and the age of the oldest pending job per
+// queue. The status is 503 with the problems listed when the queues cannot be inspected
+// or a queue is stuck; the inspection error itself is only logged.
+func (h *Handlers) DeepHealth(c *fiber.Ctx) error {
+	if h.queueInspector == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "queue health is not enabled",
+		})
+	}
+
+	stats, err := h.queueInspector.QueueStats(c.UserContext())
+	if err != nil {
+		h.logger.Warn("Failed to inspect job queues", zap.Error(err))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":   "unhealthy",
+			"problems": []string{"failed to inspect job queues"},
+		})
+	}
+
+	problems := []string{}
+	for _, queue := range stats {
+		if queue.Pending > 0 && queue.OldestPendingSeconds > h.queueStuckAfter.Seconds() {
+			problems = append(problems, fmt.Sprintf("queue %q: oldest pending job has waited %.0fs (%d pending, %d active)",
+				queue.Queue, queue.OldestPendingSeconds, queue.Pending, queue.Active))
+		}
+	}
+
+	status := "ok"
+	code := fiber.StatusOK
+	if len(problems) > 0 {
+		status = "unhealthy"
+		code = fiber.StatusServiceUnavailable
+		h.logger.Warn("Job queues are stuck", zap.Strings("problems", problems))
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status":              status,
+		"queues":              stats,
+		"stuck_after_seconds": int(h.queueStuckAfter.Seconds()),
+		"problems":            problems,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/imagesearch"
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// ImageSearchRequest is sent as JSON, or as multipart/form-data with the image as a file
+type ImageSearchRequest struct {
+	Image       string `json:"image" form:"-"`                             // base64 encoded GIF/JPEG/PNG, optionally as a data URL
+	ImageURL    string `json:"image_url,omitempty" form:"image_url"`       // downloaded instead of image, requires IMAGE_HASH_ENABLED
+	MaxDistance *int   `json:"max_distance,omitempty" form:"max_distance"` // maximum pHash Hamming distance (0..64), default 10
+	Limit       int    `json:"limit,omitempty" form:"limit"`
+}
+
+// ImageSearchResult is a product whose image is perceptually similar to the query image
+type ImageSearchResult struct {
+	*models.Product
+	MatchedImageURL string  `json:"matched_image_url"`
+	Distance        int     `json:"distance"`                 // pHash Hamming distance, 0 = same image
+	DHashDistance   *int    `json:"dhash_distance,omitempty"` // dHash Hamming distance, breaks ties
+	Similarity      float64 `json:"similarity"`               // 0..1
+}
+
+// EnableImageHashing enables the backfill_image_hashes job endpoint, which hashes with
+// hasher, and lets image search download image_url queries with queryHasher. queryHasher
+// must only reach public addresses (see httpclient.NewPublicTransport), since its URLs
+// come from callers.
+func (h *Handlers) EnableImageHashing(hasher, queryHasher *imagehash.Hasher) {
+	h.imageHasher = hasher
+	h.imageSearch = imagesearch.NewSearcher(h.productImageRepo, queryHasher)
+}
+
+// ImageSearch finds products by the perceptual hashes of an uploaded or linked image, using
+// the hashes stored in product_images during ingestion
+func (h *Handlers) ImageSearch(c *fiber.Ctx) error {
+	var req ImageSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	query := imagesearch.Query{ImageURL: req.ImageURL}
+	if form, err := c.MultipartForm(); err == nil && len(form.File["image"]) > 0 {
+		data, err := readFormFile(c, "image")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "failed to read image",
+			})
+		}
+		query.Image = data
+	} else if req.Image != "" {
+		encoded := req.Image
+		if i := strings.Index(encoded, ";base64,"); strings.HasPrefix(encoded, "data:") && i >= 0 {
+			encoded = encoded[i+len(";base64,"):]
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "image must be base64 encoded",
+			})
+		}
+		query.Image = data
+	}
+
+	query.MaxDistance = 10
+	if req.MaxDistance != nil {
+		if *req.MaxDistance < 0 || *req.MaxDistance > imagehash.MaxDistance {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "max_distance must be between 0 and 64",
+			})
+		}
+		query.MaxDistance = *req.MaxDistance
+	}
+	query.Limit = req.Limit
+	if query.Limit <= 0 || query.Limit > 50 {
+		query.Limit = 20
+	}
+
+	matches, err := h.imageSearch.Search(c.UserContext(), query)
+	switch {
+	case errors.Is(err, imagesearch.ErrNoImage), errors.Is(err, imagesearch.ErrInvalidImage),
+		errors.Is(err, imagesearch.ErrInvalidImageURL), errors.Is(err, imagesearch.ErrImageURLDisabled):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, imagesearch.ErrDownload):
+		// The cause (upstream status, resolved address) is only logged
+		h.logger.Warn("Image search download failed", zap.String("image_url", req.ImageURL), zap.Error(err))
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": imagesearch.ErrDownload.Error(),
+		})
+	case err != nil:
+		h.logger.Error("Image search failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to search products",
+		})
+	}
+
+	results := make([]ImageSearchResult, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, ImageSearchResult{
+			Product:         match.Product,
+			MatchedImageURL: match.MatchedImageURL,
+			Distance:        match.Distance,
+			DHashDistance:   match.DHashDistance,
+			Similarity:      match.Similarity,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"products": results,
+	})
+}
+
+// BackfillImageHashes enqueues the backfill_image_hashes job, which hashes the images of
+// products ingested before image hashing was enabled
+func (h *Handlers) BackfillImageHashes(c *fiber.Ctx) error {
+	if h.imageHasher == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "image hashing is not enabled",
+		})
+	}
+
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeBackfillImageHashes, &jobs.BackfillImageHashesPayload{})
+	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to enqueue job",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id": info.ID,
+		"status": "enqueued",
+	})
+}
+
+// readFormFile reads an uploaded multipart file
+func readFormFile(c *fiber.Ctx, field string) ([]byte, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// EnableJobRuns serves the runs recorded by the fetch_prices job (jobs.EnableJobRuns)
+func (h *Handlers) EnableJobRuns(jobRunRepo repository.JobRunStore) {
+	h.jobRunRepo = jobRunRepo
+}
+
+// GetJobRun returns the run of a job by the job_id returned when it was enqueued: its
+// status and the products its candidates were created or matched as. A job that has not
+// started yet is not found.
+func (h *Handlers) GetJobRun(c *fiber.Ctx) error {
+	if h.jobRunRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "job runs are not enabled",
+		})
+	}
+
+	run, err := h.jobRunRepo.GetByID(c.UserContext(), c.Params("id"))
+	if err != nil {
+		h.logger.Error("Failed to get job run", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get job run",
+		})
+	}
+	if run == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "job run not found",
+		})
+	}
+
+	if run.Products == nil {
+		run.Products = models.JobRunProducts{}
+	}
+	created, updated := 0, 0
+	for _, product := range run.Products {
+		if product.Action == models.JobRunProductCreated {
+			created++
+		} else {
+			updated++
+		}
+	}
+	return c.JSON(fiber.Map{
+		"run":              run,
+		"products_created": created,
+		"products_updated": updated,
+	})
+}
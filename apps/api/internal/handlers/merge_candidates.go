@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// ListMergeCandidates returns duplicate-product suggestions with both products attached
+func (h *Handlers) ListMergeCandidates(c *fiber.Ctx) error {
+	status := c.Query("status", models.MergeStatusPending)
+	if status != models.MergeStatusPending && status != models.MergeStatusMerged && status != models.MergeStatusDismissed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid status. must be 'pending', 'merged', or 'dismissed'",
+		})
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	candidates, err := h.mergeCandidateRepo.ListByStatus(c.UserContext(), status, limit)
+	if err != nil {
+		h.logger.Error("Failed to list merge candidates", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list merge candidates",
+		})
+	}
+
+	// Merged duplicates no longer exist, so a missing product is left empty
+	for _, candidate := range candidates {
+		if candidate.Product, err = h.productRepo.GetByID(c.UserContext(), candidate.ProductID); err != nil {
+			h.logger.Warn("Failed to load product", zap.Error(err))
+		}
+		if candidate.DuplicateProduct, err = h.productRepo.GetByID(c.UserContext(), candidate.DuplicateProductID); err != nil {
+			h.logger.Warn("Failed to load duplicate product", zap.Error(err))
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"merge_candidates": candidates,
+	})
+}
+
+// MergeCandidate merges the duplicate product of a suggestion into the kept product
+func (h *Handlers) MergeCandidate(c *fiber.Ctx) error {
+	return h.resolveMergeCandidate(c, h.mergeCandidateRepo.Merge, models.MergeStatusMerged)
+}
+
+// DismissMergeCandidate rejects a suggestion so it is not suggested again
+func (h *Handlers) DismissMergeCandidate(c *fiber.Ctx) error {
+	return h.resolveMergeCandidate(c, h.mergeCandidateRepo.Dismiss, models.MergeStatusDismissed)
+}
+
+func (h *Handlers) resolveMergeCandidate(c *fiber.Ctx, resolve func(context.Context, uuid.UUID) error, status string) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid merge candidate id",
+		})
+	}
+
+	err = resolve(c.UserContext(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "merge candidate not found",
+		})
+	}
+	if errors.Is(err, repository.ErrMergeCandidateResolved) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to resolve merge candidate",
+			zap.String("merge_candidate_id", id.String()),
+			zap.String("status", status),
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to resolve merge candidate",
+		})
+	}
+
+	if status == models.MergeStatusMerged {
+		h.refreshMerged(c.UserContext(), id)
+	}
+
+	return c.JSON(fiber.Map{
+		"id":     id,
+		"status": status,
+	})
+}
+
+// refreshMerged retires the cached responses of the products of a merged candidate and
+// updates their search documents
+func (h *Handlers) refreshMerged(ctx context.Context, candidateID uuid.UUID) {
+	if h.responseCache == nil && h.searchIndexer == nil {
+		return
+	}
+	candidate, err := h.mergeCandidateRepo.GetByID(ctx, candidateID)
+	if err != nil || candidate == nil {
+		h.logger.Warn("Failed to get merged candidate", zap.String("merge_candidate_id", candidateID.String()), zap.Error(err))
+		return
+	}
+	h.invalidateResponses(ctx, candidate.ProductID, candidate.DuplicateProductID)
+	h.reindexMerge(ctx, candidate.ProductID, candidate.DuplicateProductID)
+}
+
+type MergeProductRequest struct {
+	DuplicateProductID uuid.UUID `json:"duplicate_product_id"`
+}
+
+// MergeProduct merges a duplicate product into the product of the URL, for listings the
+// matcher filed under separate products. The merge is recorded as a merged candidate, so
+// a pair dismissed before is not merged.
+func (h *Handlers) MergeProduct(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+	var req MergeProductRequest
+	if err := c.BodyParser(&req); err != nil || req.DuplicateProductID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "duplicate_product_id is required",
+		})
+	}
+	if req.DuplicateProductID == id {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "a product cannot be merged into itself",
+		})
+	}
+
+	for _, productID := range []uuid.UUID{id, req.DuplicateProductID} {
+		product, err := h.productRepo.GetByID(c.UserContext(), productID)
+		if err != nil {
+			h.logger.Error("Failed to get product", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get product",
+			})
+		}
+		if product == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "product not found",
+			})
+		}
+	}
+
+	candidate := &models.MergeCandidate{
+		ProductID:          id,
+		DuplicateProductID: req.DuplicateProductID,
+		Reason:             models.MergeReasonManual,
+		Score:              1,
+	}
+	err = h.mergeCandidateRepo.UpsertPending(c.UserContext(), candidate)
+	if err == nil {
+		err = h.mergeCandidateRepo.Merge(c.UserContext(), candidate.ID)
+	}
+	if errors.Is(err, repository.ErrMergeCandidateResolved) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to merge products",
+			zap.String("product_id", id.String()),
+			zap.String("duplicate_product_id", req.DuplicateProductID.String()),
+			zap.Error(err),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to merge products",
+		})
+	}
+
+	h.invalidateResponses(c.UserContext(), id, req.DuplicateProductID)
+	h.reindexMerge(c.UserContext(), id, req.DuplicateProductID)
+
+	return c.JSON(fiber.Map{
+		"id":                   id,
+		"duplicate_product_id": req.DuplicateProductID,
+		"merge_candidate_id":   candidate.ID,
+		"status":               models.MergeStatusMerged,
+	})
+}
+
+// DetectDuplicates enqueues a duplicate detection run outside the regular schedule
+func (h *Handlers) DetectDuplicates(c *fiber.Ctx) error {
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeDetectDuplicates, &jobs.DetectDuplicatesPayload{})
+	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to enqueue job",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id": info.ID,
+		"status": "enqueued",
+	})
+}
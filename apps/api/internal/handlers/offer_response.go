@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+)
+
+// OfferResponse is an offer as the offers, compare and comparison set endpoints return
+// it: the stored offer, repriced for the request by compare, and the fields computed for
+// the request
+type OfferResponse struct {
+	*models.Offer
+	// AgeSeconds and Stale are computed from FetchedAt, see setFreshness
+	AgeSeconds int64 `json:"age_seconds"`
+	Stale      bool  `json:"stale"`
+	// SourceKind tells where the data came from (official_api, shopping_api, live_fetch,
+	// demo, unknown) and Demo flags demo data; both are derived from Source
+	SourceKind string `json:"source_kind"`
+	Demo       bool   `json:"demo"`
+	// DealScore rates the offer on its stored US total, see the dealscore package
+	DealScore *float64 `json:"deal_score,omitempty"`
+	// Destination holds the shipping, duty and totals to the country of ?dest= when it is
+	// not the US; the *_to_us amounts of the offer stay those to the US
+	Destination *DestinationTotals `json:"destination,omitempty"`
+	// DisplayTitle is the title of the offer's listing in the compare endpoint's display
+	// language, translated if the listing is in another one, see setDisplayTitles
+	DisplayTitle *string `json:"display_title,omitempty"`
+	// DeliveryWindow is the date range EstDeliveryDaysMin/Max arrive in for an order placed
+	// now, on the destination's business-day calendar, see setDeliveryWindows
+	DeliveryWindow *DeliveryWindow `json:"delivery_window,omitempty"`
+	// ShippingOptions are the offer's shipping options with their delivery windows; they
+	// replace the offer's own shipping_options in the JSON
+	ShippingOptions []*ShippingOptionResponse `json:"shipping_options,omitempty"`
+}
+
+// ShippingOptionResponse is a shipping option as the compare endpoint returns it
+type ShippingOptionResponse struct {
+	*models.OfferShippingOption
+	DeliveryWindow *DeliveryWindow `json:"delivery_window,omitempty"`
+}
+
+// DeliveryWindow is a range of delivery dates (YYYY-MM-DD) in the destination's time zone
+type DeliveryWindow struct {
+	Earliest string `json:"earliest"`
+	Latest   string `json:"latest"`
+}
+
+// DestinationTotals are an offer's amounts (USD cents) for shipping to Country
+type DestinationTotals struct {
+	Country          string `json:"country"`
+	ShippingAmount   int    `json:"shipping_amount"`
+	DutyAmount       int    `json:"duty_amount"`
+	TotalAmount      int    `json:"total_amount"`
+	LandedCostAmount int    `json:"landed_cost_amount"`
+	FreeShipping     bool   `json:"free_shipping"`
+}
+
+// totalAmount is the total to the request's destination
+func (r *OfferResponse) totalAmount() int {
+	if r.Destination != nil {
+		return r.Destination.TotalAmount
+	}
+	return r.TotalToUSAmount
+}
+
+// landedCostAmount is the landed cost at the request's destination
+func (r *OfferResponse) landedCostAmount() int {
+	if r.Destination != nil {
+		return r.Destination.LandedCostAmount
+	}
+	return r.LandedCostAmount
+}
+
+// convertTotals is models.Offer.ConvertTotals of the totals to the request's destination
+func (r *OfferResponse) convertTotals(currency string, rate float64) {
+	r.ConvertTotals(currency, rate)
+	if r.Destination == nil {
+		return
+	}
+	convert := func(cents int) int { return money.USD(cents).Convert(currency, rate).Amount }
+	r.Converted.ShippingAmount = convert(r.Destination.ShippingAmount)
+	r.Converted.TotalAmount = convert(r.Destination.TotalAmount)
+	r.Converted.LandedCostAmount = convert(r.Destination.LandedCostAmount)
+}
+
+// setFreshness sets AgeSeconds from FetchedAt and marks the offer stale when it is older
+// than sla (its source's freshness SLA; 0 means it never goes stale)
+func (r *OfferResponse) setFreshness(now time.Time, sla time.Duration) {
+	age := now.Sub(r.FetchedAt)
+	if age < 0 {
+		age = 0
+	}
+	r.AgeSeconds = int64(age / time.Second)
+	r.Stale = sla > 0 && age > sla
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// ListQuarantinedOffers returns offers withheld from the offers, compare and search
+// results because the fetch_prices job found them implausible
+func (h *Handlers) ListQuarantinedOffers(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	offers, err := h.offerRepo.ListQuarantined(c.UserContext(), limit)
+	if err != nil {
+		h.logger.Error("Failed to list quarantined offers", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list quarantined offers",
+		})
+	}
+
+	h.setOfferURLs(c, offers)
+
+	return c.JSON(fiber.Map{
+		"offers": h.offerResponses(offers),
+	})
+}
+
+// EnableResponseCacheInvalidation invalidates the cached responses of a product when an
+// admin creates or edits one of its offers, edits it or merges it
+func (h *Handlers) EnableResponseCacheInvalidation(cache jobs.ResponseCacheInvalidator) {
+	h.responseCache = cache
+}
+
+// invalidateResponses retires the cached responses of products and the cached search
+// responses; they expire on their own if this fails
+func (h *Handlers) invalidateResponses(ctx context.Context, productIDs ...uuid.UUID) {
+	if h.responseCache == nil {
+		return
+	}
+	for _, productID := range productIDs {
+		if err := h.responseCache.InvalidateProduct(ctx, productID); err != nil {
+			h.logger.Warn("Failed to invalidate cached responses", zap.String("product_id", productID.String()), zap.Error(err))
+		}
+	}
+	if err := h.responseCache.InvalidateSearch(ctx); err != nil {
+		h.logger.Warn("Failed to invalidate cached search responses", zap.Error(err))
+	}
+}
+
+// maxOfferMerges caps the merge decisions listed per product
+const maxOfferMerges = 500
+
+// EnableOfferMergeLog serves GET /api/admin/products/:id/offer-merges
+func (h *Handlers) EnableOfferMergeLog(offerMergeRepo repository.OfferMergeLogStore) {
+	h.offerMergeRepo = offerMergeRepo
+}
+
+// ListOfferMerges returns the offers the fetch_prices job collapsed into other offers of
+// a product as duplicates, newest first
+func (h *Handlers) ListOfferMerges(c *fiber.Ctx) error {
+	if h.offerMergeRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "offer merge log is not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > maxOfferMerges {
+		limit = 100
+	}
+
+	merges, err := h.offerMergeRepo.ListByProductID(c.UserContext(), id, limit)
+	if err != nil {
+		h.logger.Error("Failed to list offer merges", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list offer merges",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id": id,
+		"merges":     merges,
+	})
+}
+
+const (
+	manualOfferSource = jobs.ManualOfferSource
+	// manualOfferTTL is how long a manual offer is published without an expires_at
+	manualOfferTTL      = 30 * 24 * time.Hour
+	maxOfferNotesLength = 1000
+)
+
+// ManualOfferFields are the fields an admin sets on a manual offer; omitted fields are
+// kept (or defaulted on creation)
+type ManualOfferFields struct {
+	Seller             *string    `json:"seller"`
+	PriceAmount        *int       `json:"price_amount"` // minor units of currency
+	Currency           *string    `json:"currency"`     // default USD
+	URL                *string    `json:"url"`
+	InStock            *bool      `json:"in_stock"` // default true
+	EstDeliveryDaysMin *int       `json:"est_delivery_days_min"`
+	EstDeliveryDaysMax *int       `json:"est_delivery_days_max"`
+	ShipsFromCountry   *string    `json:"ships_from_country"`
+	FreeShipping       *bool      `json:"free_shipping"`
+	ExpiresAt          *time.Time `json:"expires_at"` // default manualOfferTTL from now
+	Notes              *string    `json:"notes"`
+}
+
+type CreateManualOfferRequest struct {
+	ProductID uuid.UUID `json:"product_id"`
+	ManualOfferFields
+}
+
+// CreateManualOffer adds an offer of a source without a provider, e.g. a price quoted
+// over the phone. It is priced like fetched offers and published until it expires.
+func (h *Handlers) CreateManualOffer(c *fiber.Ctx) error {
+	var req CreateManualOfferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	product, err := h.productRepo.GetByID(c.UserContext(), req.ProductID)
+	if err != nil {
+		h.logger.Error("Get product for manual offer failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get product",
+		})
+	}
+	if product == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(manualOfferTTL)
+	offer := &models.Offer{
+		ProductID: product.ID,
+		Source:    manualOfferSource,
+		Currency:  money.DefaultCurrency,
+		InStock:   true,
+		ExpiresAt: &expiresAt,
+	}
+	reason := req.apply(offer, now)
+	if reason == "" {
+		reason = manualOfferProblem(offer)
+	}
+	if reason != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": reason,
+		})
+	}
+	if err := jobs.PriceOffer(h.shippingCalc, offer, stringValue(product.Category)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	existing, err := h.offerRepo.GetByProductIDAndSource(c.UserContext(), product.ID, manualOfferSource)
+	if err != nil {
+		h.logger.Error("Get manual offers failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create offer",
+		})
+	}
+	for _, other := range existing {
+		if other.Seller == offer.Seller && stringValue(other.URL) == stringValue(offer.URL) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":    repository.ErrOfferExists.Error(),
+				"offer_id": other.ID,
+			})
+		}
+	}
+
+	err = h.offerRepo.Create(c.UserContext(), offer)
+	if errors.Is(err, repository.ErrOfferExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to create manual offer", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create offer",
+		})
+	}
+
+	h.invalidateResponses(c.UserContext(), offer.ProductID)
+	h.setOfferURLs(c, []*models.Offer{offer})
+	return c.Status(fiber.StatusCreated).JSON(h.offerResponse(offer))
+}
+
+// UpdateOffer edits an offer. Any offer takes notes; the other fields can only be
+// changed on manual offers, which are then priced again.
+func (h *Handlers) UpdateOffer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid offer id",
+		})
+	}
+	var req ManualOfferFields
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	offer, err := h.offerRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get offer", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get offer",
+		})
+	}
+	if offer == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "offer not found",
+		})
+	}
+	manual := offer.Source == manualOfferSource
+	if !manual && req.editsListing() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "only notes can be edited on offers fetched by a provider",
+		})
+	}
+
+	reason := req.apply(offer, time.Now())
+	if reason == "" && manual {
+		reason = manualOfferProblem(offer)
+	}
+	if reason != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": reason,
+		})
+	}
+	if manual {
+		product, err := h.productRepo.GetByID(c.UserContext(), offer.ProductID)
+		if err != nil {
+			h.logger.Error("Get product for manual offer failed", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get product",
+			})
+		}
+		productCategory := ""
+		if product != nil {
+			productCategory = stringValue(product.Category)
+		}
+		if err := jobs.PriceOffer(h.shippingCalc, offer, productCategory); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	err = h.offerRepo.Update(c.UserContext(), offer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "offer not found",
+		})
+	}
+	if errors.Is(err, repository.ErrOfferExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to update offer", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update offer",
+		})
+	}
+
+	h.invalidateResponses(c.UserContext(), offer.ProductID)
+	h.setOfferURLs(c, []*models.Offer{offer})
+	return c.JSON(h.offerResponse(offer))
+}
+
+// editsListing reports whether any field besides the notes is set
+func (f ManualOfferFields) editsListing() bool {
+	return f.Seller != nil || f.PriceAmount != nil || f.Currency != nil || f.URL != nil || f.InStock != nil ||
+		f.EstDeliveryDaysMin != nil || f.EstDeliveryDaysMax != nil || f.ShipsFromCountry != nil ||
+		f.FreeShipping != nil || f.ExpiresAt != nil
+}
+
+// apply copies the set fields to offer and returns the reason the result is invalid, if any
+func (f ManualOfferFields) apply(offer *models.Offer, now time.Time) string {
+	if f.Notes != nil {
+		notes := strings.TrimSpace(*f.Notes)
+		if utf8.RuneCountInString(notes) > maxOfferNotesLength {
+			return "notes are too long"
+		}
+		offer.Notes = nil
+		if notes != "" {
+			offer.Notes = &notes
+		}
+	}
+	if f.Seller != nil {
+		offer.Seller = strings.TrimSpace(*f.Seller)
+	}
+	if f.PriceAmount != nil && *f.PriceAmount != offer.PriceAmount {
+		offer.PriceAmount = *f.PriceAmount
+		offer.PriceUpdatedAt = now
+	}
+	if f.Currency != nil {
+		offer.Currency = money.NormalizeCurrency(*f.Currency)
+	}
+	if f.URL != nil {
+		offer.URL, offer.CanonicalURL = nil, nil
+		if url := strings.TrimSpace(*f.URL); url != "" {
+			canonical := canonicalurl.Canonicalize(url)
+			productURL := canonicalurl.ProductURL(canonical)
+			offer.URL, offer.CanonicalURL = &canonical, &productURL
+		}
+	}
+	if f.InStock != nil {
+		offer.InStock = *f.InStock
+	}
+	if f.EstDeliveryDaysMin != nil {
+		offer.EstDeliveryDaysMin = f.EstDeliveryDaysMin
+	}
+	if f.EstDeliveryDaysMax != nil {
+		offer.EstDeliveryDaysMax = f.EstDeliveryDaysMax
+	}
+	if f.ShipsFromCountry != nil {
+		offer.ShipsFromCountry = nil
+		if country := strings.ToUpper(strings.TrimSpace(*f.ShipsFromCountry)); country != "" {
+			offer.ShipsFromCountry = &country
+		}
+	}
+	if f.FreeShipping != nil {
+		offer.FreeShipping = *f.FreeShipping
+	}
+	if f.ExpiresAt != nil {
+		if !f.ExpiresAt.After(now) {
+			return "expires_at must be in the future"
+		}
+		offer.ExpiresAt = f.ExpiresAt
+	}
+	return ""
+}
+
+// manualOfferProblem returns the reason a manual offer is invalid, if any
+func manualOfferProblem(offer *models.Offer) string {
+	switch {
+	case offer.Seller == "":
+		return "seller is required"
+	case offer.PriceAmount <= 0:
+		return "price_amount must be a positive integer"
+	case offer.EstDeliveryDaysMin != nil && *offer.EstDeliveryDaysMin < 0,
+		offer.EstDeliveryDaysMax != nil && *offer.EstDeliveryDaysMax < 0:
+		return "delivery days must not be negative"
+	case offer.EstDeliveryDaysMin != nil && offer.EstDeliveryDaysMax != nil && *offer.EstDeliveryDaysMin > *offer.EstDeliveryDaysMax:
+		return "est_delivery_days_min must not exceed est_delivery_days_max"
+	}
+	return ""
+}
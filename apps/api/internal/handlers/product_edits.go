@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/category"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	maxProductTitleLength = 500
+	maxProductFieldLength = 200 // brand and model
+)
+
+// EnableProductEditing serves PATCH /api/admin/products/:id and the revision history
+func (h *Handlers) EnableProductEditing(revisionRepo repository.ProductRevisionStore) {
+	h.revisionRepo = revisionRepo
+}
+
+// UpdateProductRequest holds the fields to change; omitted fields are kept and an empty
+// string clears brand, model, image_url or category
+type UpdateProductRequest struct {
+	Title    *string `json:"title"`
+	Brand    *string `json:"brand"`
+	Model    *string `json:"model"`
+	ImageURL *string `json:"image_url"`
+	Category *string `json:"category"` // a slug of internal/category
+	Reason   *string `json:"reason"`   // stored with the revision
+}
+
+// UpdateProduct edits a product's metadata and records a revision of the changed
+// fields. Edited fields are no longer overwritten by ingestion.
+func (h *Handlers) UpdateProduct(c *fiber.Ctx) error {
+	if h.revisionRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product editing is not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+
+	var req UpdateProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if req.Title == nil && req.Brand == nil && req.Model == nil && req.ImageURL == nil && req.Category == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "no fields to update",
+		})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	product, err := h.productRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get product",
+		})
+	}
+	if product == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+
+	product, revision, err := h.applyProductEdit(c.UserContext(), product, &req)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+	if errors.Is(err, repository.ErrProductModified) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "product was modified concurrently, retry the edit",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to update product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update product",
+		})
+	}
+	if revision == nil {
+		// Nothing differs from the stored values, so there is nothing to record
+		return c.JSON(fiber.Map{
+			"product":  product,
+			"revision": nil,
+		})
+	}
+
+	h.invalidateResponses(c.UserContext(), product.ID)
+	h.reindexProducts(c.UserContext(), product.ID)
+
+	return c.JSON(fiber.Map{
+		"product":  product,
+		"revision": revision,
+	})
+}
+
+// maxProductEditAttempts bounds how often applyProductEdit rereads a product that keeps
+// changing under it
+const maxProductEditAttempts = 3
+
+// applyProductEdit sets the fields of edit on product and saves them with a revision of
+// the changes. A product updated since it was read is read again and edited anew, so
+// concurrent writes are not overwritten with stale values. It returns the saved product
+// and its revision, nil when nothing changed, and sql.ErrNoRows if the product is gone.
+func (h *Handlers) applyProductEdit(ctx context.Context, product *models.Product, edit *UpdateProductRequest) (*models.Product, *models.ProductRevision, error) {
+	for attempt := 1; ; attempt++ {
+		changes := edit.apply(product)
+		if len(changes) == 0 {
+			return product, nil, nil
+		}
+		revision := &models.ProductRevision{Changes: changes, Reason: optionalString(edit.Reason)}
+		err := h.revisionRepo.Apply(ctx, product, revision)
+		if !errors.Is(err, repository.ErrProductModified) || attempt == maxProductEditAttempts {
+			return product, revision, err
+		}
+		product, err = h.productRepo.GetByID(ctx, product.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if product == nil {
+			return nil, nil, sql.ErrNoRows
+		}
+	}
+}
+
+// ListProductRevisions returns the edit history of a product, newest first
+func (h *Handlers) ListProductRevisions(c *fiber.Ctx) error {
+	if h.revisionRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product editing is not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	revisions, err := h.revisionRepo.ListByProductID(c.UserContext(), id, limit)
+	if err != nil {
+		h.logger.Error("Failed to list product revisions", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list product revisions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id": id,
+		"revisions":  revisions,
+	})
+}
+
+// validate returns the reason the request is invalid, or "" when it is valid
+func (req *UpdateProductRequest) validate() string {
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return "title must not be empty"
+		}
+		if utf8.RuneCountInString(title) > maxProductTitleLength {
+			return "title is too long"
+		}
+	}
+	if req.Brand != nil && utf8.RuneCountInString(strings.TrimSpace(*req.Brand)) > maxProductFieldLength {
+		return "brand is too long"
+	}
+	if req.Model != nil && utf8.RuneCountInString(strings.TrimSpace(*req.Model)) > maxProductFieldLength {
+		return "model is too long"
+	}
+	if req.ImageURL != nil {
+		if imageURL := strings.TrimSpace(*req.ImageURL); imageURL != "" {
+			u, err := url.Parse(imageURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "image_url must be an http(s) URL"
+			}
+		}
+	}
+	if req.Category != nil {
+		if slug := strings.TrimSpace(*req.Category); slug != "" && !slices.Contains(category.All, slug) {
+			return "unknown category"
+		}
+	}
+	return ""
+}
+
+// apply sets the requested fields on product and returns the fields whose value changed
+func (req *UpdateProductRequest) apply(product *models.Product) models.FieldChanges {
+	changes := models.FieldChanges{}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title != product.Title {
+			old := product.Title
+			changes[models.ProductFieldTitle] = models.FieldChange{Old: &old, New: &title}
+			product.Title = title
+		}
+	}
+	setOptional := func(field string, value *string, target **string) {
+		if value == nil {
+			return
+		}
+		updated := optionalString(value)
+		if stringValue(updated) == stringValue(*target) && (updated == nil) == (*target == nil) {
+			return
+		}
+		changes[field] = models.FieldChange{Old: *target, New: updated}
+		*target = updated
+	}
+	setOptional(models.ProductFieldBrand, req.Brand, &product.Brand)
+	setOptional(models.ProductFieldModel, req.Model, &product.Model)
+	setOptional(models.ProductFieldImageURL, req.ImageURL, &product.ImageURL)
+	setOptional(models.ProductFieldCategory, req.Category, &product.Category)
+	return changes
+}
+
+// optionalString trims value and returns nil when it is missing or empty
+func optionalString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import "github.com/pricecompare/api/internal/models"
+
+// ProductResponse is a product as GET /api/products/:id returns it: the stored product
+// and the fields computed for the request
+type ProductResponse struct {
+	*models.Product
+	// Tags are the curator labels of the product, see EnableProductTags
+	Tags []string `json:"tags,omitempty"`
+	// LocalizedTitle is the title in the language requested with ?lang=, see localizedTitle
+	LocalizedTitle *string `json:"localized_title,omitempty"`
+}
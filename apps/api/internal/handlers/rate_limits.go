@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/ratelimit"
+)
+
+// EnableRateLimitUpdates serves PUT /api/admin/rate-limits/:provider with set, which
+// changes a provider's rate limit until the next config reload
+func (h *Handlers) EnableRateLimitUpdates(set func(providerKey string, config ratelimit.RateLimitConfig)) {
+	h.setRateLimit = set
+}
+
+type SetRateLimitRequest struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// SetProviderRateLimit changes the rate limit of an enabled provider at runtime, e.g.
+// to back off a site that started throttling. PROVIDER_RATE_LIMIT_* applies again on
+// the next config reload.
+func (h *Handlers) SetProviderRateLimit(c *fiber.Ctx) error {
+	if h.setRateLimit == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "rate limit updates are not enabled",
+		})
+	}
+	provider := c.Params("provider")
+	if h.providerManager != nil && !slices.Contains(h.providerManager.List(), provider) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "provider not found",
+		})
+	}
+	var req SetRateLimitRequest
+	if err := c.BodyParser(&req); err != nil || req.RPS <= 0 || req.Burst < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "rps must be positive and burst at least 1",
+		})
+	}
+
+	h.setRateLimit(provider, ratelimit.RateLimitConfig{RPS: req.RPS, Burst: req.Burst})
+	h.logger.Info("Provider rate limit changed",
+		zap.String("provider", provider),
+		zap.Float64("rps", req.RPS),
+		zap.Int("burst", req.Burst),
+	)
+
+	return c.JSON(fiber.Map{
+		"provider": provider,
+		"rps":      req.RPS,
+		"burst":    req.Burst,
+	})
+}
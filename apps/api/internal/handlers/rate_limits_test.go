@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/ratelimit"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestSetProviderRateLimit(t *testing.T) {
+	store := memory.New()
+	manager := providers.NewManager()
+	manager.Register("walmart", providers.NewDemoProvider())
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), manager, nil, nil, zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Put("/api/admin/rate-limits/:provider", h.SetProviderRateLimit)
+
+	if code, _ := doJSONRequest(t, app, "PUT", "/api/admin/rate-limits/walmart", `{"rps":1,"burst":1}`); code != fiber.StatusNotFound {
+		t.Errorf("without EnableRateLimitUpdates = %d, want 404", code)
+	}
+	got := map[string]ratelimit.RateLimitConfig{}
+	h.EnableRateLimitUpdates(func(providerKey string, config ratelimit.RateLimitConfig) {
+		got[providerKey] = config
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"unknown provider", "/api/admin/rate-limits/ebay", `{"rps":1,"burst":1}`, fiber.StatusNotFound},
+		{"zero rps", "/api/admin/rate-limits/walmart", `{"rps":0,"burst":1}`, fiber.StatusBadRequest},
+		{"zero burst", "/api/admin/rate-limits/walmart", `{"rps":1}`, fiber.StatusBadRequest},
+		{"set", "/api/admin/rate-limits/walmart", `{"rps":0.5,"burst":3}`, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := doJSONRequest(t, app, "PUT", tt.path, tt.body); code != tt.wantCode {
+				t.Errorf("status = %d %s, want %d", code, body, tt.wantCode)
+			}
+		})
+	}
+
+	if len(got) != 1 || got["walmart"] != (ratelimit.RateLimitConfig{RPS: 0.5, Burst: 3}) {
+		t.Errorf("rate limits set = %v, want walmart at 0.5 rps and burst 3", got)
+	}
+}
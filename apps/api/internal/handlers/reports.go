@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+)
+
+// maxCatalogReportHours bounds the period of an on-demand catalog report
+const maxCatalogReportHours = 24 * 31
+
+// EnableCatalogReport serves GET /api/admin/reports/catalog and allows sending the
+// report with POST /api/admin/jobs/catalog_report
+func (h *Handlers) EnableCatalogReport(reporter *jobs.CatalogReporter) {
+	h.catalogReporter = reporter
+}
+
+// CatalogReport builds the catalog report for the last hours (default 24) without
+// sending it
+func (h *Handlers) CatalogReport(c *fiber.Ctx) error {
+	if h.catalogReporter == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "catalog report is not enabled",
+		})
+	}
+	hours := c.QueryInt("hours", 24)
+	if hours <= 0 || hours > maxCatalogReportHours {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "hours must be between 1 and 744",
+		})
+	}
+
+	now := time.Now()
+	report, err := h.catalogReporter.Build(c.UserContext(), now.Add(-time.Duration(hours)*time.Hour), now)
+	if err != nil {
+		h.logger.Error("Failed to build catalog report", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to build catalog report",
+		})
+	}
+	return c.JSON(report)
+}
+
+// SendCatalogReport enqueues the catalog_report job, which sends the last 24 hours'
+// report to the notification channels
+func (h *Handlers) SendCatalogReport(c *fiber.Ctx) error {
+	if h.catalogReporter == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "catalog report is not enabled",
+		})
+	}
+	if !h.catalogReporter.CanNotify() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": jobs.ErrNotificationsDisabled.Error(),
+		})
+	}
+
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeCatalogReport, &jobs.CatalogReportPayload{})
+	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to enqueue job",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id": info.ID,
+		"status": "enqueued",
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// EnableFetchSchedules serves the /api/admin/schedules routes. Changes are applied to
+// scheduler right away.
+func (h *Handlers) EnableFetchSchedules(scheduleRepo repository.FetchScheduleStore, scheduler *jobs.FetchScheduler) {
+	h.scheduleRepo = scheduleRepo
+	h.fetchScheduler = scheduler
+}
+
+// ListFetchSchedules returns every fetch schedule, including paused ones
+func (h *Handlers) ListFetchSchedules(c *fiber.Ctx) error {
+	if h.scheduleRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "fetch schedules are not enabled",
+		})
+	}
+
+	schedules, err := h.scheduleRepo.List(c.UserContext())
+	if err != nil {
+		h.logger.Error("Failed to list fetch schedules", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list fetch schedules",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"schedules": schedules,
+	})
+}
+
+type CreateFetchScheduleRequest struct {
+	Source string `json:"source"`
+	Cron   string `json:"cron"`
+	Paused bool   `json:"paused"`
+}
+
+// CreateFetchSchedule adds a recurring fetch_prices run
+func (h *Handlers) CreateFetchSchedule(c *fiber.Ctx) error {
+	if h.scheduleRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "fetch schedules are not enabled",
+		})
+	}
+
+	var req CreateFetchScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if !slices.Contains(jobs.FetchSources, req.Source) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid source",
+			"sources": jobs.FetchSources,
+		})
+	}
+	req.Cron = strings.TrimSpace(req.Cron)
+	if err := jobs.ValidateCron(req.Cron); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid cron: " + err.Error(),
+		})
+	}
+
+	schedule := &models.FetchSchedule{Source: req.Source, Cron: req.Cron, Paused: req.Paused}
+	if err := h.scheduleRepo.Create(c.UserContext(), schedule); err != nil {
+		h.logger.Error("Failed to create fetch schedule", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create fetch schedule",
+		})
+	}
+	h.syncFetchSchedules(c)
+
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// PauseFetchSchedule stops running a schedule without deleting it
+func (h *Handlers) PauseFetchSchedule(c *fiber.Ctx) error {
+	return h.setFetchSchedulePaused(c, true)
+}
+
+// ResumeFetchSchedule runs a paused schedule again
+func (h *Handlers) ResumeFetchSchedule(c *fiber.Ctx) error {
+	return h.setFetchSchedulePaused(c, false)
+}
+
+func (h *Handlers) setFetchSchedulePaused(c *fiber.Ctx, paused bool) error {
+	if h.scheduleRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "fetch schedules are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid schedule id",
+		})
+	}
+
+	err = h.scheduleRepo.SetPaused(c.UserContext(), id, paused)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "schedule not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to update fetch schedule", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update fetch schedule",
+		})
+	}
+	h.syncFetchSchedules(c)
+
+	schedule, err := h.scheduleRepo.GetByID(c.UserContext(), id)
+	if err != nil || schedule == nil {
+		h.logger.Error("Failed to get fetch schedule", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get fetch schedule",
+		})
+	}
+	return c.JSON(schedule)
+}
+
+// DeleteFetchSchedule deletes a schedule created through the API. Schedules from
+// FETCH_CRON_<SOURCE> come back on restart, so they can only be paused.
+func (h *Handlers) DeleteFetchSchedule(c *fiber.Ctx) error {
+	if h.scheduleRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "fetch schedules are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid schedule id",
+		})
+	}
+
+	schedule, err := h.scheduleRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get fetch schedule", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get fetch schedule",
+		})
+	}
+	if schedule == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "schedule not found",
+		})
+	}
+	if schedule.Origin == models.ScheduleOriginEnv {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "schedule is configured with FETCH_CRON_" + strings.ToUpper(schedule.Source) + "; pause it instead",
+		})
+	}
+
+	err = h.scheduleRepo.Delete(c.UserContext(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "schedule not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete fetch schedule", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete fetch schedule",
+		})
+	}
+	h.syncFetchSchedules(c)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// syncFetchSchedules applies a stored change to the scheduler. The change is already
+// saved, so a failure is only logged; the periodic sync retries it.
+func (h *Handlers) syncFetchSchedules(c *fiber.Ctx) {
+	if h.fetchScheduler == nil {
+		return
+	}
+	if err := h.fetchScheduler.Sync(c.UserContext()); err != nil {
+		h.logger.Warn("Failed to sync fetch schedules", zap.Error(err))
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// gtinIdentifierTypes share one number space, like in the merge candidate detection
+var gtinIdentifierTypes = map[string]bool{"UPC": true, "EAN": true, "JAN": true, "GTIN": true}
+
+// identifierKey normalizes an identifier the way merge candidate detection compares them:
+// values ignoring case and leading zeros, and every GTIN type as one type
+func identifierKey(ident *models.ProductIdentifier) string {
+	idType := strings.ToUpper(ident.Type)
+	if gtinIdentifierTypes[idType] {
+		idType = "GTIN"
+	}
+	return idType + ":" + strings.TrimLeft(strings.ToUpper(ident.Value), "0")
+}
+
+// groupDuplicates groups products that share an identifier, directly or through other
+// products in the list. The first product of each group is kept and the rest are returned
+// keyed by it, so search results show one row per group until the products are merged.
+func groupDuplicates(products []*models.Product, identifiers map[uuid.UUID][]*models.ProductIdentifier) ([]*models.Product, map[uuid.UUID][]uuid.UUID) {
+	parent := make([]int, len(products))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	owner := make(map[string]int)
+	for i, product := range products {
+		for _, ident := range identifiers[product.ID] {
+			key := identifierKey(ident)
+			j, ok := owner[key]
+			if !ok {
+				owner[key] = i
+				continue
+			}
+			// Keep the earlier product as the root so it stays the primary row
+			a, b := find(i), find(j)
+			if a < b {
+				a, b = b, a
+			}
+			parent[a] = b
+		}
+	}
+
+	kept := make([]*models.Product, 0, len(products))
+	duplicates := make(map[uuid.UUID][]uuid.UUID)
+	for i, product := range products {
+		root := find(i)
+		if root == i {
+			kept = append(kept, product)
+			continue
+		}
+		primary := products[root].ID
+		duplicates[primary] = append(duplicates[primary], product.ID)
+	}
+	return kept, duplicates
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+func TestGroupDuplicates(t *testing.T) {
+	products := make([]*models.Product, 4)
+	for i := range products {
+		products[i] = &models.Product{ID: uuid.New()}
+	}
+	ident := func(i int, idType, value string) *models.ProductIdentifier {
+		return &models.ProductIdentifier{ProductID: products[i].ID, Type: idType, Value: value}
+	}
+
+	tests := []struct {
+		name           string
+		identifiers    []*models.ProductIdentifier
+		wantKept       []int
+		wantDuplicates map[int][]int
+	}{
+		{"no shared identifiers", []*models.ProductIdentifier{ident(0, "UPC", "1"), ident(1, "UPC", "2")}, []int{0, 1, 2, 3}, map[int][]int{}},
+		{"gtin types and leading zeros", []*models.ProductIdentifier{ident(0, "UPC", "012345"), ident(2, "ean", "0012345")}, []int{0, 1, 3}, map[int][]int{0: {2}}},
+		{"different types", []*models.ProductIdentifier{ident(0, "MPN", "WH1000"), ident(1, "ASIN", "WH1000")}, []int{0, 1, 2, 3}, map[int][]int{}},
+		{"transitive", []*models.ProductIdentifier{ident(1, "UPC", "1"), ident(2, "UPC", "1"), ident(2, "MPN", "X"), ident(3, "MPN", "x")}, []int{0, 1}, map[int][]int{1: {2, 3}}},
+		{"later products join the first", []*models.ProductIdentifier{ident(3, "MPN", "A"), ident(2, "MPN", "B"), ident(0, "MPN", "B"), ident(3, "MPN", "B")}, []int{0, 1}, map[int][]int{0: {2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identifiers := make(map[uuid.UUID][]*models.ProductIdentifier)
+			for _, ident := range tt.identifiers {
+				identifiers[ident.ProductID] = append(identifiers[ident.ProductID], ident)
+			}
+			kept, duplicates := groupDuplicates(products, identifiers)
+
+			if len(kept) != len(tt.wantKept) {
+				t.Fatalf("kept %d products, want %d", len(kept), len(tt.wantKept))
+			}
+			for i, want := range tt.wantKept {
+				if kept[i] != products[want] {
+					t.Errorf("kept[%d] is not product %d", i, want)
+				}
+			}
+			if len(duplicates) != len(tt.wantDuplicates) {
+				t.Fatalf("duplicates = %v, want %d groups", duplicates, len(tt.wantDuplicates))
+			}
+			for primary, want := range tt.wantDuplicates {
+				got := duplicates[products[primary].ID]
+				if len(got) != len(want) {
+					t.Fatalf("duplicates of product %d = %v, want %v", primary, got, want)
+				}
+				for i, j := range want {
+					if got[i] != products[j].ID {
+						t.Errorf("duplicates of product %d [%d] is not product %d", primary, i, j)
+					}
+				}
+			}
+		})
+	}
+}
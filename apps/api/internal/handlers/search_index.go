@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/searchindex"
+)
+
+// EnableSearchIndex serves GET /api/search/index from the external search index and
+// allows rebuilding it with POST /api/admin/jobs/reindex_search. Merges and edits update
+// the documents of the products through indexer.
+func (h *Handlers) EnableSearchIndex(index searchindex.Index, indexer *jobs.SearchIndexer) {
+	h.searchIndex = index
+	h.searchIndexer = indexer
+}
+
+// reindexMerge refreshes the search document of a kept product and deletes the one of
+// the duplicate merged into it; the next full reindex repairs documents that fail here
+func (h *Handlers) reindexMerge(ctx context.Context, productID, duplicateID uuid.UUID) {
+	if h.searchIndexer == nil {
+		return
+	}
+	if err := h.searchIndexer.DeleteProducts(ctx, duplicateID); err != nil {
+		h.logger.Warn("Failed to delete merged product from search index", zap.String("product_id", duplicateID.String()), zap.Error(err))
+	}
+	if err := h.searchIndexer.IndexProducts(ctx, productID); err != nil {
+		h.logger.Warn("Failed to update search index", zap.String("product_id", productID.String()), zap.Error(err))
+	}
+}
+
+// reindexProducts refreshes the search documents of edited products; the next full
+// reindex repairs documents that fail here
+func (h *Handlers) reindexProducts(ctx context.Context, ids ...uuid.UUID) {
+	if h.searchIndexer == nil || len(ids) == 0 {
+		return
+	}
+	if err := h.searchIndexer.IndexProducts(ctx, ids...); err != nil {
+		h.logger.Warn("Failed to update search index", zap.Int("products", len(ids)), zap.Error(err))
+	}
+}
+
+// SearchIndex is the search index backed alternative to Search, with typo tolerance,
+// filters and facet counts. An empty query with filters browses the catalog.
+func (h *Handlers) SearchIndex(c *fiber.Ctx) error {
+	if h.searchIndex == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "search index is not enabled",
+		})
+	}
+
+	query := searchindex.Query{
+		Text:        c.Query("query"),
+		Category:    c.Query("category"),
+		Brand:       c.Query("brand"),
+		Source:      c.Query("source"),
+		InStockOnly: c.QueryBool("in_stock"),
+		Sort:        c.Query("sort", searchindex.SortRelevance),
+		Limit:       c.QueryInt("limit", 20),
+		Offset:      c.QueryInt("offset", 0),
+	}
+	switch query.Sort {
+	case searchindex.SortRelevance, searchindex.SortPriceAsc, searchindex.SortPriceDesc, searchindex.SortNewest:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "sort must be one of relevance, price_asc, price_desc, newest",
+		})
+	}
+	if value := c.Query("max_price_cents"); value != "" {
+		maxPrice, err := strconv.Atoi(value)
+		if err != nil || maxPrice < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "max_price_cents must be a non-negative integer",
+			})
+		}
+		query.MaxPriceCents = &maxPrice
+	}
+	if query.Limit <= 0 || query.Limit > 100 {
+		query.Limit = 20
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	result, err := h.searchIndex.Search(c.UserContext(), query)
+	if err != nil {
+		h.logger.Error("Search index query failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to search products",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"products": result.Hits,
+		"total":    result.Total,
+		"facets":   result.Facets,
+	})
+}
+
+// ReindexSearch enqueues a full rebuild of the search index outside the regular schedule
+func (h *Handlers) ReindexSearch(c *fiber.Ctx) error {
+	if h.searchIndex == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "search index is not enabled",
+		})
+	}
+
+	info, err := jobs.Enqueue(c.UserContext(), h.queue, jobs.TypeReindexSearch, &jobs.ReindexSearchPayload{})
+	if err != nil {
+		h.logger.Error("Failed to enqueue task", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to enqueue job",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id": info.ID,
+		"status": "enqueued",
+	})
+}
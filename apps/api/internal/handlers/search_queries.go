@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/jobs"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const maxSearchQueryLength = 200
+
+// EnableSearchQueries serves the /api/admin/queries routes
+func (h *Handlers) EnableSearchQueries(searchQueryRepo repository.SearchQueryStore) {
+	h.searchQueryRepo = searchQueryRepo
+}
+
+// searchQueryProviders are the providers the fetch job searches with queries
+func searchQueryProviders() []string {
+	providers := make([]string, 0, len(jobs.DefaultSearchQueries))
+	for provider := range jobs.DefaultSearchQueries {
+		providers = append(providers, provider)
+	}
+	slices.Sort(providers)
+	return providers
+}
+
+// ListSearchQueries returns the stored queries of ?provider= (default every provider),
+// including disabled ones, with the defaults used by providers without stored queries
+func (h *Handlers) ListSearchQueries(c *fiber.Ctx) error {
+	if h.searchQueryRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "search queries are not enabled",
+		})
+	}
+	provider := c.Query("provider")
+	if provider != "" && !slices.Contains(searchQueryProviders(), provider) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "invalid provider",
+			"providers": searchQueryProviders(),
+		})
+	}
+
+	queries, err := h.searchQueryRepo.List(c.UserContext(), provider)
+	if err != nil {
+		h.logger.Error("Failed to list search queries", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list search queries",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"queries":  queries,
+		"defaults": jobs.DefaultSearchQueries,
+	})
+}
+
+type CreateSearchQueryRequest struct {
+	Provider string `json:"provider"`
+	Query    string `json:"query"`
+	Priority int    `json:"priority"`
+	Enabled  *bool  `json:"enabled"` // default true
+}
+
+// CreateSearchQuery adds a query to a provider. Once a provider has a stored query,
+// the fetch job stops using its defaults.
+func (h *Handlers) CreateSearchQuery(c *fiber.Ctx) error {
+	if h.searchQueryRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "search queries are not enabled",
+		})
+	}
+
+	var req CreateSearchQueryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if !slices.Contains(searchQueryProviders(), req.Provider) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "invalid provider",
+			"providers": searchQueryProviders(),
+		})
+	}
+	text, reason := normalizeSearchQuery(req.Query)
+	if reason != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": reason,
+		})
+	}
+
+	query := &models.SearchQuery{Provider: req.Provider, Query: text, Priority: req.Priority, Enabled: req.Enabled == nil || *req.Enabled}
+	err := h.searchQueryRepo.Create(c.UserContext(), query)
+	if errors.Is(err, repository.ErrSearchQueryExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to create search query", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create search query",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(query)
+}
+
+// UpdateSearchQueryRequest holds the fields to change; omitted fields are kept
+type UpdateSearchQueryRequest struct {
+	Query    *string `json:"query"`
+	Priority *int    `json:"priority"`
+	Enabled  *bool   `json:"enabled"`
+}
+
+// UpdateSearchQuery changes the text, priority or enabled flag of a query
+func (h *Handlers) UpdateSearchQuery(c *fiber.Ctx) error {
+	if h.searchQueryRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "search queries are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid query id",
+		})
+	}
+	var req UpdateSearchQueryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	query, err := h.searchQueryRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get search query", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get search query",
+		})
+	}
+	if query == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "query not found",
+		})
+	}
+	if req.Query != nil {
+		text, reason := normalizeSearchQuery(*req.Query)
+		if reason != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": reason,
+			})
+		}
+		query.Query = text
+	}
+	if req.Priority != nil {
+		query.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		query.Enabled = *req.Enabled
+	}
+
+	err = h.searchQueryRepo.Update(c.UserContext(), query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "query not found",
+		})
+	}
+	if errors.Is(err, repository.ErrSearchQueryExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to update search query", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update search query",
+		})
+	}
+
+	return c.JSON(query)
+}
+
+// DeleteSearchQuery removes a query. A provider whose last query is deleted is searched
+// with its defaults again; disable queries to stop searching a provider.
+func (h *Handlers) DeleteSearchQuery(c *fiber.Ctx) error {
+	if h.searchQueryRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "search queries are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid query id",
+		})
+	}
+
+	err = h.searchQueryRepo.Delete(c.UserContext(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "query not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete search query", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete search query",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// normalizeSearchQuery trims a query and returns the reason it is invalid, if any
+func normalizeSearchQuery(query string) (string, string) {
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return "", "query must not be empty"
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return "", "query is too long"
+	}
+	return query, ""
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/selftest"
+)
+
+// selfTestCacheTTL is how long a self-test report is served again: provider checks are
+// real (metered) API calls, so repeated requests must not repeat them
+const selfTestCacheTTL = 5 * time.Minute
+
+// selfTestCache holds the last self-test report
+type selfTestCache struct {
+	mu     sync.Mutex // also serializes runs, so concurrent requests share one
+	report selftest.Report
+	ranAt  time.Time
+}
+
+// EnableSelfTest exposes the dependency self-test on POST /api/admin/selftest
+func (h *Handlers) EnableSelfTest(run func(ctx context.Context) selftest.Report) {
+	h.selfTest = run
+	h.selfTestCache = &selfTestCache{}
+}
+
+// SelfTest checks the database, schema, Redis and every enabled provider and returns the
+// pass/fail matrix; the status is 503 when any check failed. A report younger than
+// selfTestCacheTTL is returned again, with its age in the Age header.
+func (h *Handlers) SelfTest(c *fiber.Ctx) error {
+	if h.selfTest == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "self-test is not enabled",
+		})
+	}
+
+	cache := h.selfTestCache
+	cache.mu.Lock()
+	if cache.ranAt.IsZero() || time.Since(cache.ranAt) >= selfTestCacheTTL {
+		cache.report = h.selfTest(c.UserContext())
+		cache.ranAt = time.Now()
+	}
+	report, age := cache.report, time.Since(cache.ranAt)
+	cache.mu.Unlock()
+	c.Set(fiber.HeaderAge, strconv.Itoa(int(age.Seconds())))
+
+	if !report.Passed {
+		for _, result := range report.Results {
+			if result.Status == selftest.StatusFail {
+				h.logger.Warn("Self-test check failed", zap.String("check", result.Name), zap.String("detail", result.Detail))
+			}
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pricecompare/api/internal/shipping"
+)
+
+type EstimateShippingRequest struct {
+	PriceCents  int    `json:"price_cents"`
+	WeightGrams *int   `json:"weight_g,omitempty"`
+	Destination string `json:"destination"`
+	Source      string `json:"source,omitempty"`   // optional, enables per-source free-shipping and fee rules
+	Category    string `json:"category,omitempty"` // optional, enables per-category fee rules and shipping tables
+}
+
+// EstimateShipping exposes the shipping calculator directly so that frontends can
+// recompute totals for other destinations/weights without refetching offers.
+func (h *Handlers) EstimateShipping(c *fiber.Ctx) error {
+	var req EstimateShippingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if req.PriceCents <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "price_cents must be a positive integer",
+		})
+	}
+	if req.WeightGrams != nil && *req.WeightGrams < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "weight_g must not be negative",
+		})
+	}
+
+	estimate, err := h.shippingCalc.Estimate(shipping.EstimateInput{
+		Source:      req.Source,
+		Category:    req.Category,
+		PriceCents:  req.PriceCents,
+		WeightGrams: req.WeightGrams,
+		Destination: req.Destination,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	options := make([]fiber.Map, 0, len(estimate.Options))
+	for _, option := range estimate.Options {
+		options = append(options, fiber.Map{
+			"speed":                 option.Speed,
+			"cost_cents":            option.CostCents,
+			"total_cents":           req.PriceCents + option.CostCents + estimate.FeeCents,
+			"est_delivery_days_min": option.DaysMin,
+			"est_delivery_days_max": option.DaysMax,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"destination":    estimate.Destination,
+		"price_cents":    req.PriceCents,
+		"shipping_cents": estimate.ShippingCents,
+		"fee_cents":      estimate.FeeCents,
+		"total_cents":    estimate.TotalCents,
+		"free_shipping":  estimate.FreeShipping,
+		"options":        options,
+	})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/snapshots"
+)
+
+// EnableSnapshots serves the raw HTML snapshots archived by the live provider
+func (h *Handlers) EnableSnapshots(store snapshots.Store) {
+	h.snapshots = store
+}
+
+// ListSnapshots lists the archived snapshots of a page URL, oldest first
+func (h *Handlers) ListSnapshots(c *fiber.Ctx) error {
+	if h.snapshots == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "snapshots are not enabled",
+		})
+	}
+	pageURL := c.Query("url")
+	if pageURL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "url is required",
+		})
+	}
+
+	list, err := h.snapshots.List(c.UserContext(), pageURL)
+	if err != nil {
+		h.logger.Error("Failed to list snapshots", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list snapshots",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"url":       pageURL,
+		"snapshots": list,
+	})
+}
+
+// GetSnapshot returns the archived HTML of a snapshot key as text/html
+func (h *Handlers) GetSnapshot(c *fiber.Ctx) error {
+	if h.snapshots == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "snapshots are not enabled",
+		})
+	}
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "key is required",
+		})
+	}
+	return h.sendSnapshot(c, key)
+}
+
+// GetSourceProductSnapshot returns the latest archived HTML of the page a source
+// listing was parsed from
+func (h *Handlers) GetSourceProductSnapshot(c *fiber.Ctx) error {
+	if h.snapshots == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "snapshots are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid source product id",
+		})
+	}
+
+	sp, err := h.sourceProductRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get source product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get source product",
+		})
+	}
+	if sp == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "source product not found",
+		})
+	}
+	if sp.SnapshotKey == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "source product has no snapshot",
+		})
+	}
+	return h.sendSnapshot(c, *sp.SnapshotKey)
+}
+
+func (h *Handlers) sendSnapshot(c *fiber.Ctx, key string) error {
+	html, err := h.snapshots.Get(c.UserContext(), key)
+	if errors.Is(err, snapshots.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "snapshot not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to get snapshot", zap.String("key", key), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get snapshot",
+		})
+	}
+
+	// The archived page is served as-is; a restrictive CSP keeps its scripts from running
+	c.Set(fiber.HeaderContentType, "text/html; charset=utf-8")
+	c.Set(fiber.HeaderContentSecurityPolicy, "sandbox")
+	c.Set("X-Snapshot-Key", key)
+	return c.Send(html)
+}
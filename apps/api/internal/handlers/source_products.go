@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ListLowConfidenceSourceProducts returns source listings whose product link is uncertain
+func (h *Handlers) ListLowConfidenceSourceProducts(c *fiber.Ctx) error {
+	maxConfidence := 0.8
+	if value := c.Query("max_confidence"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "max_confidence must be a number between 0 and 1",
+			})
+		}
+		maxConfidence = parsed
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	sourceProducts, err := h.sourceProductRepo.ListLowConfidence(c.UserContext(), maxConfidence, limit)
+	if err != nil {
+		h.logger.Error("Failed to list source products", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list source products",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"source_products": sourceProducts,
+	})
+}
+
+type RelinkSourceProductRequest struct {
+	ProductID string `json:"product_id"`
+}
+
+// RelinkSourceProduct manually links a source listing to a product
+func (h *Handlers) RelinkSourceProduct(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid source product id",
+		})
+	}
+
+	var req RelinkSourceProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	productID, err := uuid.Parse(req.ProductID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+
+	product, err := h.productRepo.GetByID(c.UserContext(), productID)
+	if err != nil {
+		h.logger.Error("Failed to get product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get product",
+		})
+	}
+	if product == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+
+	err = h.sourceProductRepo.Relink(c.UserContext(), id, productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "source product not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to relink source product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to relink source product",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"id":         id,
+		"product_id": productID,
+	})
+}
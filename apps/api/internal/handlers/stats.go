@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/ratelimit"
+)
+
+// ProviderStats returns per-provider data freshness and call error rates for ops
+// dashboards. Registered providers without any data are listed with zero counts.
+func (h *Handlers) ProviderStats(c *fiber.Ctx) error {
+	windowHours := c.QueryInt("window_hours", 24)
+	if windowHours <= 0 || windowHours > 24*30 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "window_hours must be between 1 and 720",
+		})
+	}
+	now := time.Now()
+
+	stats, err := h.providerFetchRepo.Stats(c.UserContext(), now.Add(-time.Duration(windowHours)*time.Hour))
+	if err != nil {
+		h.logger.Error("Failed to load provider stats", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to load provider stats",
+		})
+	}
+
+	seen := make(map[string]bool, len(stats))
+	for _, s := range stats {
+		seen[s.Provider] = true
+	}
+	for _, name := range h.providerManager.List() {
+		if !seen[name] {
+			stats = append(stats, &models.ProviderStats{Provider: name})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	for _, s := range stats {
+		s.Circuit = h.providerManager.Status(s.Provider).Circuit
+	}
+
+	return c.JSON(fiber.Map{
+		"window_hours": windowHours,
+		"generated_at": now,
+		"providers":    stats,
+	})
+}
+
+// EnableRateLimitStats adds the provider rate limiters' stats to Metrics
+func (h *Handlers) EnableRateLimitStats(stats func() []ratelimit.LimiterStats) {
+	h.rateLimitStats = stats
+}
+
+// Metrics exports data freshness gauges in the Prometheus text format, so standard
+// alerting can page when the refresh pipeline silently stops producing fresh prices:
+// the seconds since each provider's last successful call (+Inf without one in the
+// retained provider_fetches) and the share of each source's listed offers older than
+// its freshness SLA (OFFER_FRESHNESS_SLA_HOURS). With EnableRateLimitStats it also
+// exports each rate limiter's tokens and wait counters.
+func (h *Handlers) Metrics(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	now := time.Now()
+
+	lastSuccess, err := h.providerFetchRepo.LastSuccessAt(ctx)
+	if err != nil {
+		h.logger.Error("Failed to load last successful provider calls", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to load metrics",
+		})
+	}
+	listed, err := h.offerRepo.CountListed(ctx)
+	if err != nil {
+		h.logger.Error("Failed to count listed offers", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to load metrics",
+		})
+	}
+
+	providerNames := h.providerManager.List()
+	for provider := range lastSuccess {
+		providerNames = append(providerNames, provider)
+	}
+	sort.Strings(providerNames)
+
+	var b strings.Builder
+	writeMetricHeader(&b, "pricecompare_provider_last_success_age_seconds", "Seconds since the last successful call (search or offer fetch) of the provider")
+	for i, provider := range providerNames {
+		if i > 0 && provider == providerNames[i-1] {
+			continue
+		}
+		age := math.Inf(1)
+		if at, ok := lastSuccess[provider]; ok {
+			age = now.Sub(at).Seconds()
+		}
+		writeMetric(&b, "pricecompare_provider_last_success_age_seconds", "provider", provider, age)
+	}
+
+	sources := make([]string, 0, len(listed))
+	for source := range listed {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	// Offers older than each distinct SLA, counted once per SLA
+	staleBySLA := make(map[time.Duration]map[string]int)
+	slas := make(map[string]time.Duration, len(sources))
+	for _, source := range sources {
+		sla, ok := h.freshnessSLA[source]
+		if !ok {
+			sla = h.freshnessSLA["*"]
+		}
+		if sla <= 0 {
+			continue
+		}
+		slas[source] = sla
+		if _, ok := staleBySLA[sla]; ok {
+			continue
+		}
+		if staleBySLA[sla], err = h.offerRepo.CountFetchedBefore(ctx, now.Add(-sla)); err != nil {
+			h.logger.Error("Failed to count stale offers", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to load metrics",
+			})
+		}
+	}
+
+	writeMetricHeader(&b, "pricecompare_offers_listed", "Listed offers of the source")
+	for _, source := range sources {
+		writeMetric(&b, "pricecompare_offers_listed", "source", source, float64(listed[source]))
+	}
+	writeMetricHeader(&b, "pricecompare_offers_stale_percent", "Percentage of the source's listed offers last refreshed longer ago than its freshness SLA")
+	for _, source := range sources {
+		if sla, ok := slas[source]; ok {
+			stale := staleBySLA[sla][source]
+			writeMetric(&b, "pricecompare_offers_stale_percent", "source", source, float64(stale)/float64(listed[source])*100)
+		}
+	}
+	writeMetricHeader(&b, "pricecompare_offer_freshness_sla_seconds", "Freshness SLA of the source's offers")
+	for _, source := range sources {
+		if sla, ok := slas[source]; ok {
+			writeMetric(&b, "pricecompare_offer_freshness_sla_seconds", "source", source, sla.Seconds())
+		}
+	}
+
+	if h.rateLimitStats != nil {
+		writeRateLimitMetrics(&b, h.rateLimitStats())
+	}
+
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.SendString(b.String())
+}
+
+func writeRateLimitMetrics(b *strings.Builder, stats []ratelimit.LimiterStats) {
+	writeMetricHeader(b, "pricecompare_rate_limiter_tokens", "Tokens available in the provider's rate limiter, negative while requests wait")
+	for _, s := range stats {
+		writeMetric(b, "pricecompare_rate_limiter_tokens", "provider", s.Provider, s.TokensAvailable)
+	}
+	writeCounterHeader(b, "pricecompare_rate_limiter_waits_total", "Tokens taken from the provider's rate limiter")
+	for _, s := range stats {
+		writeMetric(b, "pricecompare_rate_limiter_waits_total", "provider", s.Provider, float64(s.Waits))
+	}
+	writeCounterHeader(b, "pricecompare_rate_limiter_wait_seconds_total", "Seconds requests waited for the provider's rate limiter")
+	for _, s := range stats {
+		writeMetric(b, "pricecompare_rate_limiter_wait_seconds_total", "provider", s.Provider, s.WaitTime.Seconds())
+	}
+	writeCounterHeader(b, "pricecompare_rate_limiter_budget_exceeded_total", "Requests refused because the provider's rate limiter wait exceeded RATE_LIMIT_MAX_WAIT_SECONDS")
+	for _, s := range stats {
+		writeMetric(b, "pricecompare_rate_limiter_budget_exceeded_total", "provider", s.Provider, float64(s.BudgetExceeded))
+	}
+}
+
+func writeMetricHeader(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+func writeCounterHeader(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
+
+// writeMetric writes one sample with a single label. Label values are quoted with Go
+// escapes, which match the exposition format's for backslashes, quotes and newlines.
+func writeMetric(b *strings.Builder, name, label, value string, sample float64) {
+	fmt.Fprintf(b, "%s{%s=%s} %s\n", name, label, strconv.Quote(value), strconv.FormatFloat(sample, 'g', -1, 64))
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/ratelimit"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5 Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{"amazon", "amazon", "walmart"} {
+		if err := store.Offers().Create(ctx, &models.Offer{ProductID: product.ID, Source: source, Seller: source, TotalToUSAmount: 9900}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.ProviderFetches().Record(ctx, "walmart", "search", time.Second, 3, nil)
+	store.ProviderFetches().Record(ctx, "amazon", "search", time.Second, 0, errors.New("throttled"))
+
+	manager := providers.NewManager()
+	manager.Register("amazon", providers.NewDemoProvider())
+	h := New(store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(), store.OfferShippingOptions(),
+		store.MergeCandidates(), store.ProductImages(), store.ProviderFetches(), manager, nil, nil, zap.NewNop())
+	// Every amazon offer is older than its SLA, no walmart offer is
+	h.EnableFreshnessSLA(map[string]time.Duration{"amazon": time.Nanosecond, "*": 24 * time.Hour})
+	h.EnableRateLimitStats(func() []ratelimit.LimiterStats {
+		return []ratelimit.LimiterStats{{Provider: "walmart", TokensAvailable: -1, Waits: 4, WaitTime: 1500 * time.Millisecond, BudgetExceeded: 2}}
+	})
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/api/admin/metrics", h.Metrics)
+
+	code, body := doRequest(t, app, "GET", "/api/admin/metrics")
+	if code != fiber.StatusOK {
+		t.Fatalf("GET /api/admin/metrics = %d: %s", code, body)
+	}
+	for _, want := range []string{
+		"# TYPE pricecompare_provider_last_success_age_seconds gauge\n",
+		// Registered, but without a successful call
+		"pricecompare_provider_last_success_age_seconds{provider=\"amazon\"} +Inf\n",
+		"pricecompare_offers_listed{source=\"amazon\"} 2\n",
+		"pricecompare_offers_stale_percent{source=\"amazon\"} 100\n",
+		"pricecompare_offers_stale_percent{source=\"walmart\"} 0\n",
+		"pricecompare_offer_freshness_sla_seconds{source=\"walmart\"} 86400\n",
+		"# TYPE pricecompare_rate_limiter_wait_seconds_total counter\n",
+		"pricecompare_rate_limiter_tokens{provider=\"walmart\"} -1\n",
+		"pricecompare_rate_limiter_waits_total{provider=\"walmart\"} 4\n",
+		"pricecompare_rate_limiter_wait_seconds_total{provider=\"walmart\"} 1.5\n",
+		"pricecompare_rate_limiter_budget_exceeded_total{provider=\"walmart\"} 2\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+	if !strings.Contains(body, "pricecompare_provider_last_success_age_seconds{provider=\"walmart\"} ") ||
+		strings.Contains(body, "pricecompare_provider_last_success_age_seconds{provider=\"walmart\"} +Inf") {
+		t.Errorf("walmart has no last success:\n%s", body)
+	}
+}
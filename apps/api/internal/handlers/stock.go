@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	maxStockHistoryDays   = 365
+	maxStockHistoryEvents = 1000
+)
+
+// EnableStockHistory serves GET /api/products/:id/stock-history
+func (h *Handlers) EnableStockHistory(stockEventRepo repository.StockEventStore) {
+	h.stockEventRepo = stockEventRepo
+}
+
+// GetStockHistory returns the in/out-of-stock transitions of a product's offers in the
+// last days (default 30), newest first, with how often it was restocked
+func (h *Handlers) GetStockHistory(c *fiber.Ctx) error {
+	if h.stockEventRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "stock history is not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+	days := c.QueryInt("days", 30)
+	if days <= 0 || days > maxStockHistoryDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "days must be between 1 and 365",
+		})
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	stockEvents, err := h.stockEventRepo.ListByProductID(c.UserContext(), id, since, maxStockHistoryEvents)
+	if err != nil {
+		h.logger.Error("Failed to list stock events", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get stock history",
+		})
+	}
+
+	restocks, outOfStocks := 0, 0
+	for _, event := range stockEvents {
+		if event.InStock {
+			restocks++
+		} else {
+			outOfStocks++
+		}
+	}
+	return c.JSON(fiber.Map{
+		"product_id":    id,
+		"since":         since,
+		"restocks":      restocks,
+		"out_of_stocks": outOfStocks,
+		"events":        stockEvents,
+	})
+}
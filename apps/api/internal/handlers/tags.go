@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	maxTagLength = 50 // product_tags.tag
+	// maxBulkProducts caps the products one bulk request can change
+	maxBulkProducts = 1000
+)
+
+// EnableProductTags serves the product tag routes and adds tags to GET /api/products/:id
+func (h *Handlers) EnableProductTags(tagRepo repository.ProductTagStore) {
+	h.tagRepo = tagRepo
+}
+
+// normalizeTag lowercases a tag and collapses its whitespace, so "Black  Friday" and
+// "black friday" are the same tag
+func normalizeTag(label string) string {
+	return strings.Join(strings.Fields(strings.ToLower(label)), " ")
+}
+
+// normalizeTags normalizes and deduplicates labels. It returns the reason the labels
+// are invalid, or "" when they are valid.
+func normalizeTags(labels []string) ([]string, string) {
+	tags := make([]string, 0, len(labels))
+	seen := make(map[string]bool)
+	for _, label := range labels {
+		tag := normalizeTag(label)
+		if tag == "" {
+			return nil, "tags must not be empty"
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, "tags must be at most 50 characters"
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, ""
+}
+
+// ListTags returns every tag in use with its number of products
+func (h *Handlers) ListTags(c *fiber.Ctx) error {
+	if h.tagRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product tags are not enabled",
+		})
+	}
+
+	tags, err := h.tagRepo.ListTags(c.UserContext())
+	if err != nil {
+		h.logger.Error("Failed to list tags", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list tags",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"tags": tags,
+	})
+}
+
+type ProductTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// AddProductTags tags a product and returns all of its tags
+func (h *Handlers) AddProductTags(c *fiber.Ctx) error {
+	if h.tagRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product tags are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+
+	var req ProductTagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if len(req.Tags) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "tags are required",
+		})
+	}
+	tags, msg := normalizeTags(req.Tags)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	product, err := h.productRepo.GetByID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get product",
+		})
+	}
+	if product == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product not found",
+		})
+	}
+
+	if _, err := h.tagRepo.Add(c.UserContext(), []uuid.UUID{id}, tags); err != nil {
+		h.logger.Error("Failed to tag product", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to tag product",
+		})
+	}
+	current, err := h.tagRepo.ListByProductID(c.UserContext(), id)
+	if err != nil {
+		h.logger.Error("Failed to get product tags", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get product tags",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id": id,
+		"tags":       current,
+	})
+}
+
+// RemoveProductTag removes one tag from a product
+func (h *Handlers) RemoveProductTag(c *fiber.Ctx) error {
+	if h.tagRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product tags are not enabled",
+		})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid product id",
+		})
+	}
+
+	// Tags contain spaces, which arrive percent-encoded
+	label, err := url.PathUnescape(c.Params("tag"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid tag",
+		})
+	}
+
+	removed, err := h.tagRepo.Remove(c.UserContext(), []uuid.UUID{id}, []string{normalizeTag(label)})
+	if err != nil {
+		h.logger.Error("Failed to remove product tag", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to remove product tag",
+		})
+	}
+	if removed == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "tag not found",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// BulkUpdateProductsRequest selects products either by ID or like GET /api/search
+// (query and/or tag) and applies every requested change to them
+type BulkUpdateProductsRequest struct {
+	ProductIDs []string `json:"product_ids"`
+	Query      string   `json:"query"`
+	Tag        string   `json:"tag"`
+
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	Category   *string  `json:"category"` // a slug of internal/category; "" clears
+	Reason     *string  `json:"reason"`   // stored with the category revisions
+}
+
+// BulkUpdateProducts tags, untags and re-categorizes up to maxBulkProducts products.
+// Category changes are recorded as product revisions like PATCH /api/admin/products/:id.
+func (h *Handlers) BulkUpdateProducts(c *fiber.Ctx) error {
+	if h.tagRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product tags are not enabled",
+		})
+	}
+
+	var req BulkUpdateProductsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	tag := normalizeTag(req.Tag)
+	if (len(req.ProductIDs) > 0) == (req.Query != "" || tag != "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "either product_ids or query/tag is required",
+		})
+	}
+	if len(req.AddTags) == 0 && len(req.RemoveTags) == 0 && req.Category == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "no changes requested",
+		})
+	}
+	addTags, msg := normalizeTags(req.AddTags)
+	if msg == "" {
+		req.RemoveTags, msg = normalizeTags(req.RemoveTags)
+	}
+	edit := UpdateProductRequest{Category: req.Category, Reason: req.Reason}
+	if msg == "" {
+		msg = edit.validate()
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if req.Category != nil && h.revisionRepo == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "product editing is not enabled",
+		})
+	}
+
+	var products []*models.Product
+	if len(req.ProductIDs) > 0 {
+		if len(req.ProductIDs) > maxBulkProducts {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "too many product_ids",
+			})
+		}
+		ids := make([]uuid.UUID, 0, len(req.ProductIDs))
+		for _, value := range req.ProductIDs {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid product id",
+				})
+			}
+			ids = append(ids, id)
+		}
+		var err error
+		products, err = h.productRepo.GetByIDs(c.UserContext(), ids)
+		if err != nil {
+			h.logger.Error("Failed to get products", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get product",
+			})
+		}
+		found := make(map[uuid.UUID]bool, len(products))
+		for _, product := range products {
+			found[product.ID] = true
+		}
+		var missing []string
+		for i, id := range ids {
+			if !found[id] {
+				missing = append(missing, req.ProductIDs[i])
+			}
+		}
+		if len(missing) > 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":       "product not found",
+				"product_ids": missing,
+			})
+		}
+	} else {
+		matches, total, err := h.productRepo.Search(c.UserContext(), req.Query, tag, maxBulkProducts, 0)
+		if err != nil {
+			h.logger.Error("Search failed", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to search products",
+			})
+		}
+		if total > maxBulkProducts {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "search matches too many products",
+				"total": total,
+			})
+		}
+		products = matches
+	}
+
+	ids := make([]uuid.UUID, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	added, err := h.tagRepo.Add(c.UserContext(), ids, addTags)
+	if err != nil {
+		h.logger.Error("Failed to tag products", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to tag products",
+		})
+	}
+	removed, err := h.tagRepo.Remove(c.UserContext(), ids, req.RemoveTags)
+	if err != nil {
+		h.logger.Error("Failed to untag products", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to untag products",
+		})
+	}
+	var recategorized []uuid.UUID
+	if req.Category != nil {
+		for _, product := range products {
+			_, revision, err := h.applyProductEdit(c.UserContext(), product, &edit)
+			if errors.Is(err, sql.ErrNoRows) {
+				// Deleted (e.g. merged) since it was selected
+				continue
+			}
+			if err != nil {
+				h.logger.Error("Failed to re-categorize product", zap.String("product_id", product.ID.String()), zap.Error(err))
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "failed to re-categorize products",
+				})
+			}
+			if revision != nil {
+				recategorized = append(recategorized, product.ID)
+			}
+		}
+		h.reindexProducts(c.UserContext(), recategorized...)
+	}
+
+	return c.JSON(fiber.Map{
+		"matched":       len(products),
+		"tags_added":    added,
+		"tags_removed":  removed,
+		"recategorized": len(recategorized),
+	})
+}
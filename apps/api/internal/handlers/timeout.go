@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestTimeouts puts a deadline on c.UserContext() of each request, so repository and
+// provider calls made with it give up instead of holding the worker: the timeout of the
+// longest path prefix in routes that matches, or defaultTimeout. 0 leaves requests
+// without a deadline. A request that fails after its deadline passed gets 503.
+func RequestTimeouts(defaultTimeout time.Duration, routes map[string]time.Duration) fiber.Handler {
+	prefixes := make([]string, 0, len(routes))
+	for prefix := range routes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	return func(c *fiber.Ctx) error {
+		timeout := defaultTimeout
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Path(), prefix) {
+				timeout = routes[prefix]
+				break
+			}
+		}
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+		// Handlers report a cancelled query as their own failure; only replace failures,
+		// not a response that completed just before the deadline
+		if err == nil && c.Response().StatusCode() < fiber.StatusInternalServerError {
+			return nil
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "request timed out",
+		})
+	}
+}
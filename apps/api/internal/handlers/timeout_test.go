@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestTimeouts(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(RequestTimeouts(20*time.Millisecond, map[string]time.Duration{
+		"/slow/":      time.Second,
+		"/unlimited/": 0,
+	}))
+	// Waits for a query that never returns, failing like a handler whose query is cancelled
+	stuck := func(c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get product"})
+	}
+	sleepy := func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			return c.UserContext().Err()
+		case <-time.After(50 * time.Millisecond):
+			return c.SendString("ok")
+		}
+	}
+	deadline := func(c *fiber.Ctx) error {
+		if _, ok := c.UserContext().Deadline(); ok {
+			return c.SendString("deadline")
+		}
+		return c.SendString("none")
+	}
+	app.Get("/stuck", stuck)
+	app.Get("/sleepy", sleepy)
+	app.Get("/slow/sleepy", sleepy)
+	app.Get("/unlimited/deadline", deadline)
+	app.Get("/deadline", deadline)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/stuck", http.StatusServiceUnavailable, "request timed out"},
+		{"/sleepy", http.StatusServiceUnavailable, "request timed out"},
+		{"/slow/sleepy", http.StatusOK, "ok"},
+		{"/deadline", http.StatusOK, "deadline"},
+		{"/unlimited/deadline", http.StatusOK, "none"},
+	}
+	for _, tt := range tests {
+		status, body := doRequest(t, app, http.MethodGet, tt.path)
+		if status != tt.wantStatus || !strings.Contains(body, tt.wantBody) {
+			t.Errorf("GET %s = %d %s, want %d with %q", tt.path, status, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pricecompare/api/internal/tracing"
+)
+
+// Tracing starts a server span for each request, continuing the caller's trace if the
+// request carries a traceparent header. Handlers get the span through c.UserContext().
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaderCarrier{c})
+
+		// c.Path() points into the request buffer, which fiber reuses once the handler
+		// returns, while the span is exported later
+		path := utils.CopyString(c.Path())
+		ctx, span := tracing.Tracer().Start(ctx, c.Method()+" "+path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", path),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		// Name the span by route template so requests for different IDs aggregate
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(attribute.String("http.route", route))
+
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return err
+	}
+}
+
+// requestHeaderCarrier adapts fiber request headers to propagation.TextMapCarrier
+type requestHeaderCarrier struct {
+	c *fiber.Ctx
+}
+
+var _ propagation.TextMapCarrier = requestHeaderCarrier{}
+
+func (r requestHeaderCarrier) Get(key string) string {
+	return r.c.Get(key)
+}
+
+func (r requestHeaderCarrier) Set(key, value string) {
+	r.c.Request().Header.Set(key, value)
+}
+
+func (r requestHeaderCarrier) Keys() []string {
+	keys := []string{}
+	for key := range r.c.GetReqHeaders() {
+		keys = append(keys, key)
+	}
+	return keys
+}
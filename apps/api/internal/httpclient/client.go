@@ -2,17 +2,23 @@ package httpclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/pricecompare/api/internal/audit"
 	"github.com/pricecompare/api/internal/compliance/robots"
 	"github.com/pricecompare/api/internal/ratelimit"
+	"github.com/pricecompare/api/internal/tracing"
 )
 
 // RedisClientOptional is an optional Redis client interface
@@ -21,13 +27,23 @@ type RedisClientOptional interface {
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
 }
 
+// ErrLiveFetchDisabled is returned for external URLs when ALLOW_LIVE_FETCH is false
+var ErrLiveFetchDisabled = errors.New("live fetch is disabled (ALLOW_LIVE_FETCH=false)")
+
 // Client is a compliant HTTP client with robots.txt checking, rate limiting, and audit logging
 type Client struct {
 	httpClient *http.Client
 	robots     *robots.Checker
 	limiter    *ratelimit.Manager
+	dispatcher *ratelimit.Dispatcher
 	cfg        *Config
 	logger     *slog.Logger
+
+	crawlWindows atomic.Pointer[map[string]CrawlWindow] // see SetCrawlWindows
+	proxies      atomic.Pointer[proxyPool]              // nil without proxies, see SetProxies
+	transport    http.RoundTripper                      // set by SetTransport, replacing the proxies
+	conditional  *conditionalCache                      // nil without Redis
+	now          func() time.Time
 }
 
 // New creates a new HTTP client with compliance features
@@ -51,34 +67,263 @@ func New(cfg *Config, logger *slog.Logger, redisClient RedisClientOptional) *Cli
 	)
 
 	// Create rate limiter
-	rateLimitConfigs := make(map[string]ratelimit.RateLimitConfig)
+	rateLimitConfigs, defaultRateLimit := rateLimitConfigs(cfg)
+	limiter := ratelimit.NewManager(rateLimitConfigs, defaultRateLimit, logger)
+	limiter.SetHostConfigs(hostRateLimitConfigs(cfg))
+	limiter.SetMaxWait(time.Duration(cfg.RateLimitMaxWaitSeconds) * time.Second)
+	dispatcher := ratelimit.NewDispatcher(cfg.MaxConcurrentRequests, cfg.ProviderWeights)
+
+	// Cache validators (ETag / Last-Modified) and bodies for conditional requests
+	conditional := newConditionalCache(redisClient, time.Duration(cfg.ConditionalCacheTTLHours)*time.Hour)
+
+	client := &Client{
+		httpClient:  httpClient,
+		robots:      robotsChecker,
+		limiter:     limiter,
+		dispatcher:  dispatcher,
+		conditional: conditional,
+		cfg:         cfg,
+		logger:      logger,
+		now:         time.Now,
+	}
+	// robots.txt and other requests without a provider rotate through all proxies
+	httpClient.Transport = &proxyTransport{client: client}
+	client.SetCrawlWindows(cfg)
+	client.SetProxies(cfg)
+	return client
+}
+
+// SetRateLimits applies the provider and host rate limits and the wait budget of cfg,
+// e.g. on a config reload
+func (c *Client) SetRateLimits(cfg *Config) {
+	c.limiter.SetConfigs(rateLimitConfigs(cfg))
+	c.limiter.SetHostConfigs(hostRateLimitConfigs(cfg))
+	c.limiter.SetMaxWait(time.Duration(cfg.RateLimitMaxWaitSeconds) * time.Second)
+}
+
+// SetProviderRateLimit changes the rate limit of one provider until the next config
+// reload, see ratelimit.Manager.SetConfig
+func (c *Client) SetProviderRateLimit(providerKey string, config ratelimit.RateLimitConfig) {
+	c.limiter.SetConfig(providerKey, config)
+}
+
+// RunRateLimitGC removes idle rate limiters every interval until ctx is done
+func (c *Client) RunRateLimitGC(ctx context.Context, interval time.Duration) {
+	c.limiter.RunGC(ctx, interval)
+}
+
+// RateLimitStats returns the state and counters of each provider's rate limiter
+func (c *Client) RateLimitStats() []ratelimit.LimiterStats {
+	return c.limiter.Stats()
+}
+
+// ShareHostRateLimits enforces the per-host rate limits across the server instances
+// sharing buckets (Redis), instead of per instance
+func (c *Client) ShareHostRateLimits(buckets ratelimit.HostBuckets) {
+	c.limiter.SetHostBuckets(buckets)
+}
+
+// SetDispatch applies the outbound request slots and provider weights of cfg, e.g. on a
+// config reload
+func (c *Client) SetDispatch(cfg *Config) {
+	c.dispatcher.SetConfig(cfg.MaxConcurrentRequests, cfg.ProviderWeights)
+}
+
+// SetCrawlWindows applies the crawl windows of cfg, e.g. on a config reload
+func (c *Client) SetCrawlWindows(cfg *Config) {
+	windows := cfg.CrawlWindows
+	c.crawlWindows.Store(&windows)
+}
+
+// checkCrawlWindow returns an ErrOutsideCrawlWindow error if the site of targetURL has a
+// crawl window (CRAWL_WINDOWS) that is closed now
+func (c *Client) checkCrawlWindow(targetURL string) error {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil
+	}
+	domain, window, ok := crawlWindowFor(*c.crawlWindows.Load(), u.Hostname())
+	if !ok {
+		return nil
+	}
+	now := c.now()
+	if window.Contains(now) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s may be crawled %s, next from %s",
+		ErrOutsideCrawlWindow, domain, window, window.Next(now).Format(time.RFC3339))
+}
+
+func rateLimitConfigs(cfg *Config) (map[string]ratelimit.RateLimitConfig, ratelimit.RateLimitConfig) {
+	configs := make(map[string]ratelimit.RateLimitConfig)
 	for k, v := range cfg.ProviderRateLimits {
-		rateLimitConfigs[k] = ratelimit.RateLimitConfig{
+		configs[k] = ratelimit.RateLimitConfig{
 			RPS:   v.RPS,
 			Burst: v.Burst,
 		}
 	}
-	defaultRateLimit := ratelimit.RateLimitConfig{
+	defaultConfig := ratelimit.RateLimitConfig{
 		RPS:   cfg.DefaultRateLimit.RPS,
 		Burst: cfg.DefaultRateLimit.Burst,
 	}
-	limiter := ratelimit.NewManager(rateLimitConfigs, defaultRateLimit, logger)
+	return configs, defaultConfig
+}
+
+func hostRateLimitConfigs(cfg *Config) (map[string]ratelimit.RateLimitConfig, ratelimit.RateLimitConfig) {
+	configs := make(map[string]ratelimit.RateLimitConfig)
+	for host, v := range cfg.HostRateLimits {
+		configs[host] = ratelimit.RateLimitConfig{RPS: v.RPS, Burst: v.Burst}
+	}
+	return configs, ratelimit.RateLimitConfig{RPS: cfg.HostRateLimit.RPS, Burst: cfg.HostRateLimit.Burst}
+}
+
+// SetTransport replaces the transport of all requests, including robots.txt fetches and
+// the proxies, e.g. with a fixture recorder or replayer. Call it before the client is used.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.transport = transport
+	c.httpClient.Transport = transport
+}
+
+// TransportFor returns the transport for API providers that send requests without the
+// compliance checks: the one set by SetTransport, or else the proxies of providerKey.
+// Requests of providerKey share the outbound request slots (HTTP_MAX_CONCURRENT_REQUESTS)
+// with the other providers.
+func (c *Client) TransportFor(providerKey string) http.RoundTripper {
+	base := c.transport
+	if base == nil {
+		base = &proxyTransport{client: c, providerKey: providerKey}
+	}
+	return &dispatchTransport{base: base, dispatcher: c.dispatcher, providerKey: providerKey}
+}
+
+// UserAgent returns the User-Agent of providerKey, for API providers that send requests
+// without the compliance checks
+func (c *Client) UserAgent(providerKey string) string {
+	return c.cfg.UserAgentFor(providerKey)
+}
+
+// AuditLogger returns the logger requests are audited to, for clients that fetch pages
+// by other means after Preflight (see internal/render)
+func (c *Client) AuditLogger() *slog.Logger {
+	return c.logger
+}
+
+// RobotsMemoryCacheSize returns the number of robots.txt files cached in memory
+func (c *Client) RobotsMemoryCacheSize() int {
+	return c.robots.MemoryCacheSize()
+}
+
+// Preflight runs the live fetch, robots.txt and rate limit checks Get would run for
+// targetURL without sending the request itself
+func (c *Client) Preflight(ctx context.Context, providerKey, targetURL string) error {
+	isExternal, err := IsExternalURL(targetURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if isExternal {
+		if !c.cfg.AllowLiveFetch {
+			return ErrLiveFetchDisabled
+		}
+		if err := c.checkCrawlWindow(targetURL); err != nil {
+			return err
+		}
+		allowed, group, err := c.robots.CanFetch(ctx, targetURL, c.cfg.UserAgentFor(providerKey))
+		if err != nil {
+			return fmt.Errorf("robots.txt check failed: %w", err)
+		}
+		if !allowed {
+			return fmt.Errorf("robots.txt disallows access to %s (matched rule: %s)", targetURL, group)
+		}
+	}
+	if err := c.limiter.Wait(ctx, providerKey); err != nil {
+		return fmt.Errorf("rate limit wait failed: %w", err)
+	}
+	if isExternal {
+		if err := c.waitCrawlDelay(ctx, targetURL, c.cfg.UserAgentFor(providerKey)); err != nil {
+			return fmt.Errorf("crawl delay wait failed: %w", err)
+		}
+		if err := c.limiter.WaitHostRateLimit(ctx, getHost(targetURL)); err != nil {
+			return fmt.Errorf("host rate limit wait failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// waitCrawlDelay waits for the Crawl-delay of the robots.txt of the site of targetURL,
+// per host across providers
+func (c *Client) waitCrawlDelay(ctx context.Context, targetURL, userAgent string) error {
+	delay, err := c.robots.CrawlDelay(ctx, targetURL, userAgent)
+	if err != nil {
+		// CanFetch read the same robots.txt just before
+		c.logger.Warn("Failed to read crawl delay", "url", targetURL, "error", err)
+		return nil
+	}
+	if delay > 0 {
+		trace.SpanFromContext(ctx).AddEvent("crawl delay wait", trace.WithAttributes(attribute.Int64("crawl_delay_ms", delay.Milliseconds())))
+	}
+	return c.limiter.WaitCrawlDelay(ctx, getHost(targetURL), delay)
+}
 
-	return &Client{
-		httpClient: httpClient,
-		robots:     robotsChecker,
-		limiter:    limiter,
-		cfg:        cfg,
-		logger:     logger,
+// Sitemaps returns the Sitemap URLs listed in the robots.txt of the site of siteURL.
+// Internal URLs have no robots.txt checks and return none.
+func (c *Client) Sitemaps(ctx context.Context, siteURL string) ([]string, error) {
+	isExternal, err := IsExternalURL(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if !isExternal {
+		return nil, nil
+	}
+	if !c.cfg.AllowLiveFetch {
+		return nil, ErrLiveFetchDisabled
+	}
+	if err := c.checkCrawlWindow(siteURL); err != nil {
+		return nil, err
 	}
+	return c.robots.Sitemaps(ctx, siteURL)
 }
 
 // Get performs a GET request with compliance checks
 func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.Response, error) {
+	return c.GetExpecting(ctx, providerKey, targetURL, ContentAny)
+}
+
+// GetExpecting is Get for callers that can only parse one kind of body. A successful response
+// of another content type is closed, audited and returned as a *ContentTypeError.
+func (c *Client) GetExpecting(ctx context.Context, providerKey, targetURL string, expected ContentKind) (*http.Response, error) {
+	return c.GetWithHeader(ctx, providerKey, targetURL, expected, nil)
+}
+
+// GetWithHeader is GetExpecting with additional request headers (e.g. Accept-Language).
+// User-Agent is always the configured one of the provider (see Config.UserAgentFor).
+func (c *Client) GetWithHeader(ctx context.Context, providerKey, targetURL string, expected ContentKind, header http.Header) (resp *http.Response, err error) {
+	// Trace headers are not sent to third-party sites; the span only covers our side
+	ctx, span := tracing.Tracer().Start(ctx, "httpclient.Get",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("provider", providerKey),
+			attribute.String("http.request.method", "GET"),
+			attribute.String("server.address", getHost(targetURL)),
+			attribute.String("url.path", getPath(targetURL)),
+		),
+	)
+	defer func() {
+		if resp != nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		}
+		tracing.End(span, err)
+	}()
+
+	return c.get(ctx, providerKey, targetURL, expected, header)
+}
+
+func (c *Client) get(ctx context.Context, providerKey, targetURL string, expected ContentKind, header http.Header) (*http.Response, error) {
 	startTime := time.Now()
+	userAgent := c.cfg.UserAgentFor(providerKey)
 	var retryCount int
 	var robotsAllowed bool
 	var robotsGroup string
+	var proxyName string
 	var lastErr error
 
 	// Check if URL is external
@@ -98,17 +343,38 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 			Path:          getPath(targetURL),
 			Status:        0,
 			DurationMs:    time.Since(startTime).Milliseconds(),
-			UserAgent:     c.cfg.UserAgent,
+			UserAgent:     userAgent,
 			RobotsAllowed: false,
 			RetryCount:    0,
 			Error:         "ALLOW_LIVE_FETCH is false, external URL access blocked",
 		})
-		return nil, fmt.Errorf("live fetch is disabled (ALLOW_LIVE_FETCH=false), cannot access external URL: %s", targetURL)
+		return nil, fmt.Errorf("%w, cannot access external URL: %s", ErrLiveFetchDisabled, targetURL)
+	}
+
+	// Sites with a crawl window are not contacted outside it, robots.txt included
+	if isExternal {
+		if err := c.checkCrawlWindow(targetURL); err != nil {
+			audit.LogRequest(c.logger, audit.Entry{
+				Timestamp:     startTime,
+				Provider:      providerKey,
+				Method:        "GET",
+				URL:           targetURL,
+				Host:          getHost(targetURL),
+				Path:          getPath(targetURL),
+				Status:        0,
+				DurationMs:    time.Since(startTime).Milliseconds(),
+				UserAgent:     userAgent,
+				RobotsAllowed: false,
+				RetryCount:    0,
+				Error:         err.Error(),
+			})
+			return nil, err
+		}
 	}
 
 	// Check robots.txt for external URLs
 	if isExternal {
-		allowed, group, err := c.robots.CanFetch(ctx, targetURL, c.cfg.UserAgent)
+		allowed, group, err := c.robots.CanFetch(ctx, targetURL, userAgent)
 		if err != nil {
 			audit.LogRequest(c.logger, audit.Entry{
 				Timestamp:     startTime,
@@ -119,7 +385,7 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 				Path:          getPath(targetURL),
 				Status:        0,
 				DurationMs:    time.Since(startTime).Milliseconds(),
-				UserAgent:     c.cfg.UserAgent,
+				UserAgent:     userAgent,
 				RobotsAllowed: false,
 				RetryCount:    0,
 				Error:         fmt.Sprintf("robots.txt check failed: %v", err),
@@ -136,7 +402,7 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 				Path:          getPath(targetURL),
 				Status:        0,
 				DurationMs:    time.Since(startTime).Milliseconds(),
-				UserAgent:     c.cfg.UserAgent,
+				UserAgent:     userAgent,
 				RobotsAllowed: false,
 				RobotsGroup:   group,
 				RetryCount:    0,
@@ -149,29 +415,68 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 	}
 
 	// Apply rate limiting
+	trace.SpanFromContext(ctx).AddEvent("rate limit wait")
 	if err := c.limiter.Wait(ctx, providerKey); err != nil {
 		return nil, fmt.Errorf("rate limit wait failed: %w", err)
 	}
 
+	// Send If-None-Match / If-Modified-Since for URLs fetched before, unless the caller
+	// sets its own validators
+	cacheKey := conditionalKey(targetURL, header)
+	var cached *conditionalEntry
+	if header.Get("If-None-Match") == "" && header.Get("If-Modified-Since") == "" {
+		cached = c.conditional.load(ctx, cacheKey)
+	}
+
 	// Perform request with retries
 	maxRetries := c.cfg.HTTPMaxRetries
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		retryCount = attempt
 
-		req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+		// Retries are requests to the site too
+		if isExternal {
+			if err := c.waitCrawlDelay(ctx, targetURL, userAgent); err != nil {
+				return nil, fmt.Errorf("crawl delay wait failed: %w", err)
+			}
+			if err := c.limiter.WaitHostRateLimit(ctx, getHost(targetURL)); err != nil {
+				return nil, fmt.Errorf("host rate limit wait failed: %w", err)
+			}
+		}
+
+		// Each attempt takes the next healthy proxy, so a retry leaves through another one
+		proxy, err := c.pickProxy(providerKey)
 		if err != nil {
 			lastErr = err
 			break
 		}
+		reqCtx := ctx
+		proxyName = ""
+		if proxy != nil {
+			reqCtx = context.WithValue(ctx, proxyContextKey{}, proxy)
+			proxyName = proxy.name
+		}
 
-		req.Header.Set("User-Agent", c.cfg.UserAgent)
+		req, err := http.NewRequestWithContext(reqCtx, "GET", targetURL, nil)
+		if err != nil {
+			lastErr = err
+			break
+		}
 
-		resp, err := c.httpClient.Do(req)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if cached != nil {
+			setConditionalHeaders(req, cached)
+		}
+
+		resp, err := c.clientFor(providerKey).Do(req)
 		if err != nil {
 			lastErr = err
 			// Retry on network errors
 			if attempt < maxRetries {
 				backoff := exponentialBackoff(attempt)
+				trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1), attribute.String("error", err.Error())))
 				c.logger.Warn("HTTP request failed, retrying",
 					"url", targetURL,
 					"attempt", attempt+1,
@@ -193,6 +498,7 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 		if shouldRetry && attempt < maxRetries {
 			resp.Body.Close()
 			backoff := exponentialBackoff(attempt)
+			trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1), attribute.Int("status", resp.StatusCode)))
 			c.logger.Warn("HTTP request returned retryable status, retrying",
 				"url", targetURL,
 				"status", resp.StatusCode,
@@ -207,7 +513,31 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 			}
 		}
 
-		// Success or non-retryable error
+		// An unchanged page is served from the cache, but audited as the 304 it was
+		status := resp.StatusCode
+		if status == http.StatusNotModified && cached != nil {
+			trace.SpanFromContext(ctx).AddEvent("not modified")
+			if err := c.conditional.refresh(ctx, cacheKey, cached, resp.Header); err != nil {
+				c.logger.Warn("Failed to refresh cached response", "url", targetURL, "error", err)
+			}
+			resp = notModifiedResponse(resp, cached)
+		}
+
+		// Success or non-retryable error. Error pages are left to the caller's status handling.
+		var contentErr error
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			contentErr = CheckContentType(resp, expected)
+		}
+		var errorMsg string
+		if contentErr != nil {
+			resp.Body.Close()
+			errorMsg = contentErr.Error()
+		} else if status == http.StatusOK {
+			if err := c.conditional.remember(ctx, cacheKey, resp); err != nil {
+				c.logger.Warn("Failed to cache response validators", "url", targetURL, "error", err)
+			}
+		}
+
 		duration := time.Since(startTime)
 		audit.LogRequest(c.logger, audit.Entry{
 			Timestamp:     startTime,
@@ -216,14 +546,20 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 			URL:           targetURL,
 			Host:          getHost(targetURL),
 			Path:          getPath(targetURL),
-			Status:        resp.StatusCode,
+			Status:        status,
 			DurationMs:    duration.Milliseconds(),
-			UserAgent:     c.cfg.UserAgent,
+			UserAgent:     userAgent,
 			RobotsAllowed: robotsAllowed,
 			RobotsGroup:   robotsGroup,
 			RetryCount:    retryCount,
+			Proxy:         proxyName,
+			ContentType:   resp.Header.Get("Content-Type"),
+			Error:         errorMsg,
 		})
 
+		if contentErr != nil {
+			return nil, contentErr
+		}
 		return resp, nil
 	}
 
@@ -242,16 +578,23 @@ func (c *Client) Get(ctx context.Context, providerKey, targetURL string) (*http.
 		Path:          getPath(targetURL),
 		Status:        0,
 		DurationMs:    duration.Milliseconds(),
-		UserAgent:     c.cfg.UserAgent,
+		UserAgent:     userAgent,
 		RobotsAllowed: robotsAllowed,
 		RobotsGroup:   robotsGroup,
 		RetryCount:    retryCount,
+		Proxy:         proxyName,
 		Error:         errorMsg,
 	})
 
 	return nil, fmt.Errorf("request failed after %d retries: %w", maxRetries, lastErr)
 }
 
+// clientFor returns an HTTP client whose requests (redirects included) take a slot of the
+// dispatcher for providerKey
+func (c *Client) clientFor(providerKey string) *http.Client {
+	return &http.Client{Timeout: c.httpClient.Timeout, Transport: c.TransportFor(providerKey)}
+}
+
 // exponentialBackoff calculates exponential backoff with jitter
 func exponentialBackoff(attempt int) time.Duration {
 	base := time.Second
@@ -288,4 +631,3 @@ func (r *redisCacheAdapter) Get(ctx context.Context, key string) ([]byte, error)
 func (r *redisCacheAdapter) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	return r.client.Set(ctx, key, value, ttl)
 }
-
@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxConditionalBodyBytes is the largest body kept for conditional requests; larger
+// responses are fetched in full every time
+const maxConditionalBodyBytes = 2 << 20
+
+// conditionalEntry is the last 200 response of a URL with its validators, replayed when
+// the site answers a conditional request with 304 Not Modified
+type conditionalEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// conditionalCache keeps conditionalEntry values in Redis. Without Redis (or with a TTL
+// of 0) every request is sent unconditionally.
+type conditionalCache struct {
+	redis RedisClientOptional
+	ttl   time.Duration
+}
+
+func newConditionalCache(redis RedisClientOptional, ttl time.Duration) *conditionalCache {
+	if redis == nil || ttl <= 0 {
+		return nil
+	}
+	return &conditionalCache{redis: redis, ttl: ttl}
+}
+
+// conditionalKey identifies a response by URL and the caller's extra request headers
+// (e.g. Accept-Language), which may change the body
+func conditionalKey(targetURL string, header http.Header) string {
+	var buf bytes.Buffer
+	buf.WriteString(targetURL)
+	buf.WriteByte('\n')
+	_ = header.Write(&buf) // sorted by key
+	sum := sha256.Sum256(buf.Bytes())
+	return "http_validators:" + hex.EncodeToString(sum[:])
+}
+
+// load returns the cached entry of key, or nil. Cache errors are treated as misses.
+func (c *conditionalCache) load(ctx context.Context, key string) *conditionalEntry {
+	if c == nil {
+		return nil
+	}
+	data, err := c.redis.Get(ctx, key)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var entry conditionalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+func (c *conditionalCache) store(ctx context.Context, key string, entry *conditionalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.redis.Set(ctx, key, data, c.ttl)
+}
+
+// setConditionalHeaders asks the site to answer 304 if entry is still current
+func setConditionalHeaders(req *http.Request, entry *conditionalEntry) {
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// notModifiedResponse turns a 304 into the cached 200 response. Headers of the 304 (e.g.
+// a new ETag) replace the cached ones.
+func notModifiedResponse(resp *http.Response, entry *conditionalEntry) *http.Response {
+	resp.Body.Close()
+	header := resp.Header.Clone()
+	if header.Get("Content-Type") == "" && entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       resp.Request,
+	}
+}
+
+// remember caches a 200 response that has validators. The body is read into memory (up
+// to maxConditionalBodyBytes) and resp.Body replaced, so the caller still reads all of it.
+func (c *conditionalCache) remember(ctx context.Context, key string, resp *http.Response) error {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if c == nil || resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return nil
+	}
+	if resp.ContentLength > maxConditionalBodyBytes {
+		return nil
+	}
+	body := resp.Body
+	head, err := io.ReadAll(io.LimitReader(body, maxConditionalBodyBytes+1))
+	if err != nil || len(head) > maxConditionalBodyBytes {
+		// Not cached; the caller reads what was read so far and then the rest (or the error)
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), body), body}
+		return nil
+	}
+	body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(head))
+	return c.store(ctx, key, &conditionalEntry{
+		ETag:         etag,
+		LastModified: lastModified,
+		ContentType:  resp.Header.Get("Content-Type"),
+		Body:         head,
+	})
+}
+
+// refresh stores the validators of a 304 response, which also restarts the TTL of entries
+// that are still crawled
+func (c *conditionalCache) refresh(ctx context.Context, key string, entry *conditionalEntry, header http.Header) error {
+	if etag := header.Get("ETag"); etag != "" {
+		entry.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		entry.LastModified = lastModified
+	}
+	return c.store(ctx, key, entry)
+}
@@ -0,0 +1,122 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapRedis is an in-memory RedisClientOptional
+type mapRedis struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (m *mapRedis) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[key], nil
+}
+
+func (m *mapRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+func TestClient_Get_Conditional(t *testing.T) {
+	var conditions []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditions = append(conditions, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 10 Mar 2026 12:00:00 GMT")
+		w.Write([]byte("<html>price</html>"))
+	}))
+	defer testServer.Close()
+
+	var logs bytes.Buffer
+	client := New(&Config{
+		AllowLiveFetch:           true,
+		UserAgent:                "TestBot/1.0",
+		RobotsCacheTTLHours:      24,
+		HTTPTimeoutSeconds:       10,
+		ProviderRateLimits:       make(map[string]RateLimitConfig),
+		DefaultRateLimit:         RateLimitConfig{RPS: 10, Burst: 10},
+		ConditionalCacheTTLHours: 1,
+	}, slog.New(slog.NewJSONHandler(&logs, nil)), &mapRedis{values: make(map[string][]byte)})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.GetExpecting(context.Background(), "live", testServer.URL+"/item", ContentHTML)
+		if err != nil {
+			t.Fatalf("request %d error = %v", i+1, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "<html>price</html>" {
+			t.Errorf("request %d = %d %q, want the page", i+1, resp.StatusCode, body)
+		}
+	}
+
+	want := []string{"|", `"v1"|Tue, 10 Mar 2026 12:00:00 GMT`}
+	if len(conditions) != 2 || conditions[0] != want[0] || conditions[1] != want[1] {
+		t.Errorf("conditional headers = %q, want %q", conditions, want)
+	}
+	if !strings.Contains(logs.String(), `"status":304`) {
+		t.Errorf("audit log has no 304 entry:\n%s", logs.String())
+	}
+
+	// Other request headers are cached separately
+	header := http.Header{"Accept-Language": {"ja"}}
+	resp, err := client.GetWithHeader(context.Background(), "live", testServer.URL+"/item", ContentAny, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if last := conditions[len(conditions)-1]; last != "|" {
+		t.Errorf("conditional headers with Accept-Language = %q, want none", last)
+	}
+}
+
+func TestClient_Get_ConditionalDisabledWithoutRedis(t *testing.T) {
+	var conditional bool
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = conditional || r.Header.Get("If-None-Match") != ""
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("OK"))
+	}))
+	defer testServer.Close()
+
+	client := New(&Config{
+		AllowLiveFetch:           true,
+		UserAgent:                "TestBot/1.0",
+		RobotsCacheTTLHours:      24,
+		HTTPTimeoutSeconds:       10,
+		ProviderRateLimits:       make(map[string]RateLimitConfig),
+		DefaultRateLimit:         RateLimitConfig{RPS: 10, Burst: 10},
+		ConditionalCacheTTLHours: 1,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), "live", testServer.URL+"/item")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if conditional {
+		t.Error("sent If-None-Match without a cache")
+	}
+}
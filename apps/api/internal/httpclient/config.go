@@ -1,21 +1,42 @@
 package httpclient
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 // Config holds HTTP client configuration
 type Config struct {
-	AllowLiveFetch      bool
-	UserAgent           string
-	RobotsCacheTTLHours int
-	ProviderRateLimits  map[string]RateLimitConfig
-	DefaultRateLimit    RateLimitConfig
-	HTTPTimeoutSeconds  int
-	HTTPMaxRetries      int
+	AllowLiveFetch          bool
+	UserAgent               string
+	ProviderUserAgents      map[string]string // provider -> User-Agent replacing UserAgent, see UserAgentFor
+	CrawlInfoURL            string            // page describing the crawler, appended to every User-Agent
+	RobotsCacheTTLHours     int
+	ProviderRateLimits      map[string]RateLimitConfig
+	DefaultRateLimit        RateLimitConfig
+	RateLimitMaxWaitSeconds int                        // longest wait for a provider's rate limit before the request fails; 0 is unlimited
+	HostRateLimit           RateLimitConfig            // per target host across providers (and instances, with Redis); RPS 0 disables
+	HostRateLimits          map[string]RateLimitConfig // host -> its own limit replacing HostRateLimit, from HOST_RATE_LIMITS
+	HTTPTimeoutSeconds      int
+	HTTPMaxRetries          int
+	CrawlWindows            map[string]CrawlWindow // domain -> time of day it may be crawled, from CRAWL_WINDOWS
+
+	ConditionalCacheTTLHours int // how long ETag / Last-Modified and bodies are kept in Redis; 0 (the default) disables conditional requests
+
+	MaxConcurrentRequests int                // outbound requests in flight at once across providers; 0 is unlimited
+	ProviderWeights       map[string]float64 // provider -> share of MaxConcurrentRequests while others wait (default 1)
+
+	Proxies                   []ProxyConfig       // outbound proxies requests rotate through, from HTTP_PROXIES; none sends them directly
+	ProviderProxies           map[string][]string // provider -> names of its proxies (or DirectProxy); others use all
+	ProxyCheckURL             string              // URL fetched through each proxy to check its health; empty disables the checks
+	ProxyCheckIntervalSeconds int
+
+	loadErrors []error // malformed values, reported by Validate
 }
 
 // RateLimitConfig holds rate limit configuration for a provider
@@ -26,46 +47,128 @@ type RateLimitConfig struct {
 
 // LoadConfig loads HTTP client configuration from environment variables
 func LoadConfig() *Config {
+	l := &envLoader{}
 	cfg := &Config{
-		AllowLiveFetch:      getBoolEnv("ALLOW_LIVE_FETCH", false),
-		UserAgent:           getEnv("USER_AGENT", "PriceCompareBot/1.0 (+contact@example.com)"),
-		RobotsCacheTTLHours: getIntEnv("ROBOTS_CACHE_TTL_HOURS", 24),
-		HTTPTimeoutSeconds:  getIntEnv("HTTP_TIMEOUT_SECONDS", 10),
-		HTTPMaxRetries:      getIntEnv("HTTP_MAX_RETRIES", 3),
-		ProviderRateLimits:  make(map[string]RateLimitConfig),
+		AllowLiveFetch:          l.getBoolEnv("ALLOW_LIVE_FETCH", false),
+		UserAgent:               l.getEnv("USER_AGENT", "PriceCompareBot/1.0 (+contact@example.com)"),
+		ProviderUserAgents:      make(map[string]string),
+		CrawlInfoURL:            l.getEnv("CRAWL_INFO_URL", ""),
+		RobotsCacheTTLHours:     l.getIntEnv("ROBOTS_CACHE_TTL_HOURS", 24),
+		HTTPTimeoutSeconds:      l.getIntEnv("HTTP_TIMEOUT_SECONDS", 10),
+		HTTPMaxRetries:          l.getIntEnv("HTTP_MAX_RETRIES", 3),
+		ProviderRateLimits:      make(map[string]RateLimitConfig),
+		RateLimitMaxWaitSeconds: l.getIntEnv("RATE_LIMIT_MAX_WAIT_SECONDS", 300),
+
+		ConditionalCacheTTLHours: l.getIntEnv("HTTP_CONDITIONAL_CACHE_TTL_HOURS", 0), // off: bodies take up to 2 MiB of Redis per URL
+
+		MaxConcurrentRequests: l.getIntEnv("HTTP_MAX_CONCURRENT_REQUESTS", 0),
+
+		ProxyCheckURL:             l.getEnv("HTTP_PROXY_CHECK_URL", ""),
+		ProxyCheckIntervalSeconds: l.getIntEnv("HTTP_PROXY_CHECK_INTERVAL_SECONDS", 60),
 	}
 
 	// Load provider-specific rate limits
 	cfg.ProviderRateLimits["demo"] = RateLimitConfig{
-		RPS:   getFloatEnv("PROVIDER_RATE_LIMIT_DEMO_RPS", 10),
-		Burst: getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_DEMO_RPS", 10),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
 	}
 	cfg.ProviderRateLimits["public_html"] = RateLimitConfig{
-		RPS:   getFloatEnv("PROVIDER_RATE_LIMIT_PUBLIC_HTML_RPS", 10),
-		Burst: getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_PUBLIC_HTML_RPS", 10),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
 	}
 	cfg.ProviderRateLimits["live"] = RateLimitConfig{
-		RPS:   getFloatEnv("PROVIDER_RATE_LIMIT_LIVE_RPS", 1),
-		Burst: getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_LIVE_RPS", 1),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
 	}
 	cfg.ProviderRateLimits["walmart"] = RateLimitConfig{
-		RPS:   getFloatEnv("PROVIDER_RATE_LIMIT_WALMART_RPS", 5),
-		Burst: getIntEnv("PROVIDER_RATE_LIMIT_BURST", 10),
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_WALMART_RPS", 5),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 10),
 	}
 	cfg.ProviderRateLimits["amazon"] = RateLimitConfig{
-		RPS:   getFloatEnv("PROVIDER_RATE_LIMIT_AMAZON_RPS", 1),
-		Burst: getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_AMAZON_RPS", 1),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+	}
+	cfg.ProviderRateLimits["rakuten"] = RateLimitConfig{
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_RAKUTEN_RPS", 1),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+	}
+	cfg.ProviderRateLimits["aliexpress"] = RateLimitConfig{
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_ALIEXPRESS_RPS", 1),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+	}
+	cfg.ProviderRateLimits["google_shopping"] = RateLimitConfig{
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_GOOGLE_SHOPPING_RPS", 1),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+	}
+
+	// Provider-specific User-Agents, for sites that require a registered bot name or token
+	for _, provider := range []string{"demo", "public_html", "live", "walmart", "amazon", "rakuten", "aliexpress", "google_shopping"} {
+		if userAgent := l.getEnv("PROVIDER_USER_AGENT_"+strings.ToUpper(provider), ""); userAgent != "" {
+			cfg.ProviderUserAgents[provider] = userAgent
+		}
+	}
+
+	// Sites that may only be crawled outside their peak hours
+	windows, err := parseCrawlWindows(l.getEnv("CRAWL_WINDOWS", ""))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("CRAWL_WINDOWS: %w", err))
 	}
+	cfg.CrawlWindows = windows
+
+	// Shares of the outbound request slots, see ratelimit.Dispatcher
+	weights, err := parseProviderWeights(l.getEnv("PROVIDER_WEIGHTS", ""))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("PROVIDER_WEIGHTS: %w", err))
+	}
+	cfg.ProviderWeights = weights
+
+	// Outbound proxies, e.g. approved egress IPs in the sites' regions
+	proxies, err := parseProxies(l.getEnv("HTTP_PROXIES", ""))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("HTTP_PROXIES: %w", err))
+	}
+	cfg.Proxies = proxies
+	providerProxies, err := parseProviderProxies(l.getEnv("PROVIDER_PROXIES", ""))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("PROVIDER_PROXIES: %w", err))
+	}
+	cfg.ProviderProxies = providerProxies
+
+	// Per-host limits, on top of the provider limits: the providers (and jobs) fetching
+	// from one site share them
+	cfg.HostRateLimit = RateLimitConfig{
+		RPS:   l.getFloatEnv("HOST_RATE_LIMIT_RPS", 5),
+		Burst: l.getIntEnv("HOST_RATE_LIMIT_BURST", 5),
+	}
+	hostRateLimits, err := parseHostRateLimits(l.getEnv("HOST_RATE_LIMITS", ""), cfg.HostRateLimit.Burst)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("HOST_RATE_LIMITS: %w", err))
+	}
+	cfg.HostRateLimits = hostRateLimits
 
 	// Default rate limit (fallback)
 	cfg.DefaultRateLimit = RateLimitConfig{
-		RPS:   getFloatEnv("PROVIDER_RATE_LIMIT_LIVE_RPS", 1),
-		Burst: getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
+		RPS:   l.getFloatEnv("PROVIDER_RATE_LIMIT_LIVE_RPS", 1),
+		Burst: l.getIntEnv("PROVIDER_RATE_LIMIT_BURST", 2),
 	}
 
+	cfg.loadErrors = l.errs
 	return cfg
 }
 
+// UserAgentFor returns the User-Agent sent for providerKey: its PROVIDER_USER_AGENT_*
+// override or USER_AGENT, followed by "(+CRAWL_INFO_URL)" unless it already contains it
+func (c *Config) UserAgentFor(providerKey string) string {
+	userAgent, ok := c.ProviderUserAgents[providerKey]
+	if !ok {
+		userAgent = c.UserAgent
+	}
+	if c.CrawlInfoURL != "" && !strings.Contains(userAgent, c.CrawlInfoURL) {
+		userAgent += " (+" + c.CrawlInfoURL + ")"
+	}
+	return userAgent
+}
+
 // IsExternalURL checks if a URL is external (http/https with a host)
 func IsExternalURL(targetURL string) (bool, error) {
 	u, err := url.Parse(targetURL)
@@ -112,7 +215,112 @@ func IsExternalURL(targetURL string) (bool, error) {
 	return true, nil
 }
 
-func getEnv(key, defaultValue string) string {
+// Validate returns every problem in the configuration, joined with errors.Join
+func (c *Config) Validate() error {
+	errs := append([]error{}, c.loadErrors...)
+	if c.UserAgent == "" {
+		errs = append(errs, errors.New("USER_AGENT must not be empty"))
+	}
+	if c.CrawlInfoURL != "" {
+		if u, err := url.Parse(c.CrawlInfoURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("CRAWL_INFO_URL=%q must be an absolute http(s) URL", c.CrawlInfoURL))
+		}
+	}
+	for provider, userAgent := range c.ProviderUserAgents {
+		if strings.ContainsAny(userAgent, "\r\n") {
+			errs = append(errs, fmt.Errorf("PROVIDER_USER_AGENT_%s must be a single line", strings.ToUpper(provider)))
+		}
+	}
+	if c.RobotsCacheTTLHours <= 0 {
+		errs = append(errs, errors.New("ROBOTS_CACHE_TTL_HOURS must be greater than 0"))
+	}
+	if c.HTTPTimeoutSeconds <= 0 {
+		errs = append(errs, errors.New("HTTP_TIMEOUT_SECONDS must be greater than 0"))
+	}
+	if c.HTTPMaxRetries < 0 {
+		errs = append(errs, errors.New("HTTP_MAX_RETRIES must not be negative"))
+	}
+	if c.RateLimitMaxWaitSeconds < 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_MAX_WAIT_SECONDS must not be negative"))
+	}
+	if c.ConditionalCacheTTLHours < 0 {
+		errs = append(errs, errors.New("HTTP_CONDITIONAL_CACHE_TTL_HOURS must not be negative"))
+	}
+	if c.MaxConcurrentRequests < 0 {
+		errs = append(errs, errors.New("HTTP_MAX_CONCURRENT_REQUESTS must not be negative"))
+	}
+	if c.ProxyCheckIntervalSeconds <= 0 {
+		errs = append(errs, errors.New("HTTP_PROXY_CHECK_INTERVAL_SECONDS must be greater than 0"))
+	}
+	if c.ProxyCheckURL != "" {
+		if u, err := url.Parse(c.ProxyCheckURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("HTTP_PROXY_CHECK_URL=%q must be an absolute http(s) URL", c.ProxyCheckURL))
+		}
+	}
+	proxyNames := make(map[string]bool, len(c.Proxies))
+	for _, proxy := range c.Proxies {
+		proxyNames[proxy.Name] = true
+	}
+	assigned := make([]string, 0, len(c.ProviderProxies))
+	for provider := range c.ProviderProxies {
+		assigned = append(assigned, provider)
+	}
+	sort.Strings(assigned)
+	for _, provider := range assigned {
+		for _, name := range c.ProviderProxies[provider] {
+			if name != DirectProxy && !proxyNames[name] {
+				errs = append(errs, fmt.Errorf("PROVIDER_PROXIES: proxy %q of %q is not in HTTP_PROXIES", name, provider))
+			}
+		}
+	}
+	providers := make([]string, 0, len(c.ProviderRateLimits))
+	for provider := range c.ProviderRateLimits {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		if limit := c.ProviderRateLimits[provider]; limit.RPS <= 0 || limit.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("rate limit for provider %q must have RPS and burst greater than 0", provider))
+		}
+	}
+	if c.HostRateLimit.RPS < 0 {
+		errs = append(errs, errors.New("HOST_RATE_LIMIT_RPS must not be negative"))
+	}
+	if c.HostRateLimit.Burst <= 0 {
+		errs = append(errs, errors.New("HOST_RATE_LIMIT_BURST must be greater than 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// parseHostRateLimits parses HOST_RATE_LIMITS: comma-separated "<host>=<rps>" entries
+// with the burst of HOST_RATE_LIMIT_BURST, e.g. "api.walmart.com=10,www.example.jp=0.5".
+// Hosts are matched as they appear in URLs, with the port if any.
+func parseHostRateLimits(value string, burst int) (map[string]RateLimitConfig, error) {
+	limits := make(map[string]RateLimitConfig)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		host, rawRPS, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("%q is not <host>=<rps>", entry)
+		}
+		rps, err := strconv.ParseFloat(strings.TrimSpace(rawRPS), 64)
+		if err != nil || rps <= 0 {
+			return nil, fmt.Errorf("%s: %q is not a positive number", host, rawRPS)
+		}
+		limits[host] = RateLimitConfig{RPS: rps, Burst: burst}
+	}
+	return limits, nil
+}
+
+// envLoader reads typed environment variables, collecting malformed values for Validate
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
@@ -120,36 +328,43 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func getIntEnv(key string, defaultValue int) int {
+func (l *envLoader) getIntEnv(key string, defaultValue int) int {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
 	intValue, err := strconv.Atoi(value)
 	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s=%q is not an integer", key, value))
 		return defaultValue
 	}
 	return intValue
 }
 
-func getFloatEnv(key string, defaultValue float64) float64 {
+func (l *envLoader) getFloatEnv(key string, defaultValue float64) float64 {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
 	floatValue, err := strconv.ParseFloat(value, 64)
 	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s=%q is not a number", key, value))
 		return defaultValue
 	}
 	return floatValue
 }
 
-func getBoolEnv(key string, defaultValue bool) bool {
+func (l *envLoader) getBoolEnv(key string, defaultValue bool) bool {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
-	value = strings.ToLower(strings.TrimSpace(value))
-	return value == "true" || value == "1" || value == "yes"
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "yes":
+		return true
+	case "false", "0", "no":
+		return false
+	}
+	l.errs = append(l.errs, fmt.Errorf("%s=%q is not a boolean", key, value))
+	return defaultValue
 }
-
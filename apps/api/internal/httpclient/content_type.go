@@ -0,0 +1,124 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentKind is the kind of body a caller is able to parse
+type ContentKind string
+
+const (
+	ContentAny  ContentKind = ""     // no check
+	ContentHTML ContentKind = "html" // text/html, application/xhtml+xml
+	ContentJSON ContentKind = "json" // application/json, text/json, */*+json
+	ContentXML  ContentKind = "xml"  // application/xml, text/xml, */*+xml (e.g. sitemaps)
+)
+
+// ErrUnexpectedContentType is wrapped by ContentTypeError so callers can use errors.Is
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// ContentTypeError is returned when a response body is not of the kind the caller parses
+type ContentTypeError struct {
+	URL         string
+	Expected    ContentKind
+	ContentType string // Content-Type header, or the sniffed type when the header is missing
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("%s: expected %s from %s, got %q", ErrUnexpectedContentType, e.Expected, e.URL, e.ContentType)
+}
+
+func (e *ContentTypeError) Unwrap() error {
+	return ErrUnexpectedContentType
+}
+
+// sniffLen matches the number of bytes http.DetectContentType considers
+const sniffLen = 512
+
+// CheckContentType returns a *ContentTypeError when resp does not carry the expected kind of body.
+// A missing, text/plain or application/octet-stream header is not conclusive, so the first bytes
+// of the body are sniffed instead; resp.Body is replaced so the caller still reads the whole body.
+func CheckContentType(resp *http.Response, expected ContentKind) error {
+	if expected == ContentAny {
+		return nil
+	}
+
+	header := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		mediaType = ""
+	}
+
+	kind, conclusive := classifyMediaType(mediaType)
+	got := header
+	if !conclusive {
+		buffered := bufio.NewReaderSize(resp.Body, sniffLen)
+		prefix, _ := buffered.Peek(sniffLen)
+		resp.Body = readCloser{Reader: buffered, Closer: resp.Body}
+		kind = sniffKind(prefix)
+		if kind == ContentAny {
+			// Plain text could be either; leave it to the parser
+			return nil
+		}
+		if got == "" {
+			got = http.DetectContentType(prefix)
+		}
+	}
+
+	if kind == expected {
+		return nil
+	}
+	targetURL := ""
+	if resp.Request != nil && resp.Request.URL != nil {
+		targetURL = resp.Request.URL.String()
+	}
+	return &ContentTypeError{URL: targetURL, Expected: expected, ContentType: got}
+}
+
+// classifyMediaType maps a media type to a kind; conclusive is false when the body has to be sniffed
+func classifyMediaType(mediaType string) (kind ContentKind, conclusive bool) {
+	switch {
+	case mediaType == "", mediaType == "text/plain", mediaType == "application/octet-stream":
+		return ContentAny, false
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return ContentHTML, true
+	case mediaType == "application/json", mediaType == "text/json", strings.HasSuffix(mediaType, "+json"):
+		return ContentJSON, true
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		return ContentXML, true
+	default:
+		// Images, PDFs and the like are none of the above
+		return ContentKind(mediaType), true
+	}
+}
+
+// sniffKind guesses the kind of a body from its first bytes; ContentAny means it cannot tell
+func sniffKind(prefix []byte) ContentKind {
+	trimmed := bytes.TrimLeft(prefix, " \t\r\n\uFEFF")
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return ContentJSON
+	}
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(prefix))
+	switch {
+	case detected == "text/html":
+		return ContentHTML
+	case detected == "text/xml":
+		return ContentXML
+	case strings.HasPrefix(detected, "text/"):
+		return ContentAny
+	default:
+		return ContentKind(detected)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    ContentKind
+		wantErr     bool
+	}{
+		{"html page", "text/html; charset=utf-8", "<html></html>", ContentHTML, false},
+		{"xhtml page", "application/xhtml+xml", "<html></html>", ContentHTML, false},
+		{"json when html expected", "application/json", `{"error":"rate limited"}`, ContentHTML, true},
+		{"image when html expected", "image/png", "\x89PNG\r\n\x1a\n", ContentHTML, true},
+		{"json api", "application/json", `{"items":[]}`, ContentJSON, false},
+		{"problem json", "application/problem+json", `{"title":"bad"}`, ContentJSON, false},
+		{"html when json expected", "text/html", "<html>captcha</html>", ContentJSON, true},
+		{"sniffed html without header", "", "<!DOCTYPE html><html></html>", ContentHTML, false},
+		{"sniffed json without header", "", ` {"rates":{}}`, ContentHTML, true},
+		{"sniffed json in text/plain", "text/plain", `{"rates":{}}`, ContentJSON, false},
+		{"sniffed pdf in octet-stream", "application/octet-stream", "%PDF-1.4", ContentHTML, true},
+		{"sitemap", "application/xml", `<?xml version="1.0"?><urlset></urlset>`, ContentXML, false},
+		{"sniffed sitemap without header", "", `<?xml version="1.0"?><urlset></urlset>`, ContentXML, false},
+		{"html when xml expected", "text/html", "<html>not found</html>", ContentXML, true},
+		{"undecidable plain text", "text/plain", "OK", ContentJSON, false},
+		{"any content", "image/png", "\x89PNG", ContentAny, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header:  http.Header{},
+				Body:    io.NopCloser(strings.NewReader(tt.body)),
+				Request: httptest.NewRequest(http.MethodGet, "https://shop.example.com/search", nil),
+			}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			err := CheckContentType(resp, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckContentType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var typed *ContentTypeError
+				if !errors.As(err, &typed) || !errors.Is(err, ErrUnexpectedContentType) {
+					t.Fatalf("CheckContentType() error = %T, want *ContentTypeError", err)
+				}
+				if typed.Expected != tt.expected || typed.URL != "https://shop.example.com/search" {
+					t.Errorf("ContentTypeError = %+v", typed)
+				}
+				return
+			}
+
+			// Sniffing must not consume the body
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.body {
+				t.Errorf("body after check = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestClient_GetExpecting_RejectsUnexpectedContentType(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"error":"blocked"}`))
+	}))
+	defer testServer.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	cfg := &Config{
+		AllowLiveFetch:      true,
+		UserAgent:           "TestBot/1.0",
+		RobotsCacheTTLHours: 24,
+		HTTPTimeoutSeconds:  10,
+		HTTPMaxRetries:      1,
+		ProviderRateLimits:  make(map[string]RateLimitConfig),
+		DefaultRateLimit:    RateLimitConfig{RPS: 10, Burst: 10},
+	}
+	client := New(cfg, logger, nil)
+
+	resp, err := client.GetExpecting(context.Background(), "live", testServer.URL+"/search", ContentHTML)
+	if !errors.Is(err, ErrUnexpectedContentType) {
+		t.Fatalf("GetExpecting() error = %v, want ErrUnexpectedContentType", err)
+	}
+	if resp != nil {
+		t.Error("GetExpecting() returned a response along with the error")
+	}
+	if out := logs.String(); !strings.Contains(out, "content_type=application/json") || !strings.Contains(out, "unexpected content type") {
+		t.Errorf("audit log does not record the rejected content type:\n%s", out)
+	}
+
+	resp, err = client.GetExpecting(context.Background(), "fx", testServer.URL+"/rates", ContentJSON)
+	if err != nil {
+		t.Fatalf("GetExpecting() error = %v", err)
+	}
+	resp.Body.Close()
+}
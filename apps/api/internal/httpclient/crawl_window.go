@@ -0,0 +1,135 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrOutsideCrawlWindow is returned for requests to a site outside its crawl window
+var ErrOutsideCrawlWindow = errors.New("outside the site's crawl window")
+
+// CrawlWindow is the time of day a site may be crawled, in the site's time zone, so
+// crawling stays out of its peak hours. An End before Start wraps past midnight.
+type CrawlWindow struct {
+	Start    time.Duration // since midnight
+	End      time.Duration
+	Location *time.Location
+}
+
+// ParseCrawlWindow parses "HH:MM-HH:MM" with an optional "@<IANA time zone>" (UTC
+// otherwise), e.g. "02:00-06:00@America/New_York"
+func ParseCrawlWindow(spec string) (CrawlWindow, error) {
+	window := CrawlWindow{Location: time.UTC}
+	hours, zone, hasZone := strings.Cut(strings.TrimSpace(spec), "@")
+	if hasZone {
+		location, err := time.LoadLocation(strings.TrimSpace(zone))
+		if err != nil {
+			return window, fmt.Errorf("unknown time zone %q", zone)
+		}
+		window.Location = location
+	}
+	start, end, ok := strings.Cut(hours, "-")
+	if !ok {
+		return window, fmt.Errorf("%q is not HH:MM-HH:MM", hours)
+	}
+	var err error
+	if window.Start, err = parseTimeOfDay(start); err != nil {
+		return window, err
+	}
+	if window.End, err = parseTimeOfDay(end); err != nil {
+		return window, err
+	}
+	if window.Start == window.End {
+		return window, fmt.Errorf("%q is an empty window", hours)
+	}
+	return window, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		// 24:00 ends a window at midnight
+		if value == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, fmt.Errorf("%q is not a time of day (HH:MM)", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t is inside the window
+func (w CrawlWindow) Contains(t time.Time) bool {
+	offset := sinceMidnight(t.In(w.Location))
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// Next returns the next time the window opens after t. The opening is a wall clock time,
+// so days with a DST change (23 or 25 hours long) keep it.
+func (w CrawlWindow) Next(t time.Time) time.Time {
+	local := t.In(w.Location)
+	hour, minute := int(w.Start/time.Hour), int(w.Start%time.Hour/time.Minute)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, w.Location)
+	if !next.After(local) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, hour, minute, 0, 0, w.Location)
+	}
+	return next
+}
+
+func (w CrawlWindow) String() string {
+	return fmt.Sprintf("%s-%s %s", formatTimeOfDay(w.Start), formatTimeOfDay(w.End), w.Location)
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// parseCrawlWindows parses CRAWL_WINDOWS: comma-separated "<domain>=<window>" entries,
+// e.g. "shop.example.com=02:00-06:00@America/New_York,example.jp=01:00-05:00@Asia/Tokyo"
+func parseCrawlWindows(value string) (map[string]CrawlWindow, error) {
+	windows := make(map[string]CrawlWindow)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		domain, spec, ok := strings.Cut(entry, "=")
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !ok || domain == "" {
+			return nil, fmt.Errorf("%q is not <domain>=<HH:MM-HH:MM[@time zone]>", entry)
+		}
+		window, err := ParseCrawlWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", domain, err)
+		}
+		windows[domain] = window
+	}
+	return windows, nil
+}
+
+// crawlWindowFor returns the window of host: that of the host itself or of the closest
+// parent domain, so "example.com" also covers "www.example.com"
+func crawlWindowFor(windows map[string]CrawlWindow, host string) (string, CrawlWindow, bool) {
+	host = strings.ToLower(host)
+	domains := make([]string, 0, len(windows))
+	for domain := range windows {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return "", CrawlWindow{}, false
+	}
+	// The longest (most specific) domain wins
+	sort.Slice(domains, func(i, j int) bool { return len(domains[i]) > len(domains[j]) })
+	return domains[0], windows[domains[0]], true
+}
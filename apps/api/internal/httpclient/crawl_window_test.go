@@ -0,0 +1,132 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCrawlWindow(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		spec     string
+		at       time.Time
+		contains bool
+		next     time.Time
+	}{
+		{
+			spec:     "02:00-06:00@America/New_York",
+			at:       time.Date(2026, 3, 10, 3, 30, 0, 0, newYork),
+			contains: true,
+			next:     time.Date(2026, 3, 11, 2, 0, 0, 0, newYork),
+		},
+		{
+			spec: "02:00-06:00@America/New_York",
+			at:   time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC), // 08:00 in New York
+			next: time.Date(2026, 3, 11, 2, 0, 0, 0, newYork),
+		},
+		{
+			spec:     "22:00-04:00",
+			at:       time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC),
+			contains: true,
+			next:     time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "22:00-04:00",
+			at:   time.Date(2026, 3, 10, 4, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			// DST ends: the day of the next opening has 25 hours
+			spec: "03:00-06:00@America/New_York",
+			at:   time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC), // 03:00 EST
+		},
+		{
+			// DST starts: the day of the next opening has 23 hours
+			spec: "04:00-06:00@America/New_York",
+			at:   time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC),
+			next: time.Date(2026, 3, 8, 8, 0, 0, 0, time.UTC), // 04:00 EDT
+		},
+		{
+			spec:     "20:00-24:00",
+			at:       time.Date(2026, 3, 10, 23, 59, 0, 0, time.UTC),
+			contains: true,
+			next:     time.Date(2026, 3, 11, 20, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		window, err := ParseCrawlWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseCrawlWindow(%q) error = %v", tt.spec, err)
+		}
+		if got := window.Contains(tt.at); got != tt.contains {
+			t.Errorf("%s Contains(%s) = %v, want %v", tt.spec, tt.at, got, tt.contains)
+		}
+		if got := window.Next(tt.at); !got.Equal(tt.next) {
+			t.Errorf("%s Next(%s) = %s, want %s", tt.spec, tt.at, got, tt.next)
+		}
+	}
+
+	for _, spec := range []string{"02:00", "2am-6am", "02:00-02:00", "02:00-06:00@Mars/Olympus"} {
+		if _, err := ParseCrawlWindow(spec); err == nil {
+			t.Errorf("ParseCrawlWindow(%q) accepted an invalid window", spec)
+		}
+	}
+}
+
+func TestParseCrawlWindows(t *testing.T) {
+	windows, err := parseCrawlWindows("Example.com=02:00-06:00, shop.example.com=01:00-03:00@Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("parseCrawlWindows() error = %v", err)
+	}
+	tests := map[string]string{
+		"example.com":      "example.com",
+		"www.example.com":  "example.com",
+		"shop.example.com": "shop.example.com",
+		"notexample.com":   "",
+	}
+	for host, want := range tests {
+		domain, _, ok := crawlWindowFor(windows, host)
+		if domain != want || ok != (want != "") {
+			t.Errorf("crawlWindowFor(%q) = %q, %v, want %q", host, domain, ok, want)
+		}
+	}
+
+	if _, err := parseCrawlWindows("example.com"); err == nil {
+		t.Error("parseCrawlWindows() accepted an entry without a window")
+	}
+}
+
+func TestClient_Preflight_CrawlWindow(t *testing.T) {
+	client := New(&Config{
+		AllowLiveFetch:      true,
+		UserAgent:           "TestBot/1.0",
+		RobotsCacheTTLHours: 24,
+		HTTPTimeoutSeconds:  10,
+		ProviderRateLimits:  make(map[string]RateLimitConfig),
+		DefaultRateLimit:    RateLimitConfig{RPS: 10, Burst: 10},
+		CrawlWindows:        map[string]CrawlWindow{"example.com": {Start: 2 * time.Hour, End: 6 * time.Hour, Location: time.UTC}},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	client.now = func() time.Time { return time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC) }
+
+	err := client.Preflight(context.Background(), "live", "https://www.example.com/search")
+	if !errors.Is(err, ErrOutsideCrawlWindow) {
+		t.Errorf("Preflight() error = %v, want ErrOutsideCrawlWindow", err)
+	}
+	if _, err := client.Get(context.Background(), "live", "https://www.example.com/search"); !errors.Is(err, ErrOutsideCrawlWindow) {
+		t.Errorf("Get() error = %v, want ErrOutsideCrawlWindow", err)
+	}
+
+	// Reloaded windows apply to the next request
+	client.SetCrawlWindows(&Config{})
+	if err := client.checkCrawlWindow("https://www.example.com/search"); err != nil {
+		t.Errorf("checkCrawlWindow() after removing the window = %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pricecompare/api/internal/ratelimit"
+)
+
+// dispatchTransport takes a slot of the dispatcher for each request of providerKey and
+// returns it when the response body is closed (or the request fails)
+type dispatchTransport struct {
+	base        http.RoundTripper
+	dispatcher  *ratelimit.Dispatcher
+	providerKey string
+}
+
+func (t *dispatchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.dispatcher.Acquire(req.Context(), t.providerKey)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// parseProviderWeights parses PROVIDER_WEIGHTS: comma-separated "<provider>=<weight>"
+// entries, e.g. "walmart=3,live=1"
+func parseProviderWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		provider, weight, ok := strings.Cut(entry, "=")
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if !ok || provider == "" {
+			return nil, fmt.Errorf("%q is not <provider>=<weight>", entry)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("%s: weight %q must be a number greater than 0", provider, weight)
+		}
+		weights[provider] = w
+	}
+	return weights, nil
+}
@@ -0,0 +1,65 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_DispatchSlots(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer testServer.Close()
+
+	client := New(&Config{
+		AllowLiveFetch:        true,
+		UserAgent:             "TestBot/1.0",
+		RobotsCacheTTLHours:   24,
+		HTTPTimeoutSeconds:    10,
+		ProviderRateLimits:    make(map[string]RateLimitConfig),
+		DefaultRateLimit:      RateLimitConfig{RPS: 10, Burst: 10},
+		MaxConcurrentRequests: 1,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	// The slot is held until the body is closed, also for API providers
+	resp, err := client.Get(context.Background(), "live", testServer.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &http.Client{Transport: client.TransportFor("walmart")}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testServer.URL+"/api", nil)
+	if _, err := api.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("request while the slot is taken error = %v, want DeadlineExceeded", err)
+	}
+
+	resp.Body.Close()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", testServer.URL+"/api", nil)
+	apiResp, err := api.Do(req)
+	if err != nil {
+		t.Fatalf("request after the body was closed error = %v", err)
+	}
+	apiResp.Body.Close()
+}
+
+func TestParseProviderWeights(t *testing.T) {
+	weights, err := parseProviderWeights("Walmart=3, live=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights["walmart"] != 3 || weights["live"] != 0.5 {
+		t.Errorf("weights = %v", weights)
+	}
+	for _, value := range []string{"walmart", "walmart=0", "walmart=fast"} {
+		if _, err := parseProviderWeights(value); err == nil {
+			t.Errorf("parseProviderWeights(%q) accepted an invalid weight", value)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress is returned when a connection to a user-supplied URL would reach a
+// loopback, private, link-local (e.g. the 169.254.169.254 metadata service) or otherwise
+// non-public address
+var ErrNonPublicAddress = errors.New("address is not public")
+
+// cgnatPrefix is the carrier-grade NAT range, which net.IP.IsPrivate does not cover
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddress reports whether ip is a globally routable unicast address
+func IsPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !cgnatPrefix.Contains(ip)
+}
+
+// NewPublicTransport returns a transport for URLs that users supply (alert webhooks,
+// image search by URL). Addresses are checked when each connection is made, after DNS
+// resolution and on every redirect, so a hostname cannot be pointed at an internal
+// service. No proxy is used, since it would connect on the transport's behalf.
+func NewPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		ControlContext: func(ctx context.Context, network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, address)
+			}
+			if !IsPublicAddress(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
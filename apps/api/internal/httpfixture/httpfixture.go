@@ -0,0 +1,241 @@
+// Package httpfixture records provider HTTP interactions as golden files and replays them
+// through a fake transport, so provider parsing can be tested deterministically without
+// calling RapidAPI, Amazon or live sites.
+//
+// Each interaction is stored as two files in the fixture directory: <name>.json with the
+// request method and URL and the response status and headers, and the response body as
+// <name>.json.body, <name>.html, <name>.xml or <name>.txt depending on its content type,
+// so HTML and JSON fixtures stay readable and editable. Credentials are never written:
+// request headers are not recorded and secret query parameters are redacted.
+package httpfixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Modes of a Transport
+const (
+	ModeRecord = "record" // pass requests through and save the responses
+	ModeReplay = "replay" // answer requests from saved responses only
+)
+
+// ErrNoFixture is returned in replay mode for a request that was never recorded
+var ErrNoFixture = errors.New("no recorded fixture for request")
+
+// redactedValue replaces secret query parameter values in keys and recorded URLs
+const redactedValue = "REDACTED"
+
+// secretParams are query parameters that carry credentials
+var secretParams = map[string]bool{
+	"key": true, "api_key": true, "apikey": true, "access_key": true, "token": true,
+	"access_token": true, "signature": true, "sig": true, "secret": true, "password": true,
+	"applicationid": true, "app_key": true, "sign": true,
+}
+
+// skippedHeaders are response headers that are not recorded
+var skippedHeaders = map[string]bool{"Set-Cookie": true, "Date": true, "Content-Length": true}
+
+// unsafeNameChars are replaced in fixture names derived from URLs
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// Interaction is the metadata file of a recorded request
+type Interaction struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	BodyFile string      `json:"body_file,omitempty"`
+}
+
+// Transport is an http.RoundTripper recording to or replaying from a fixture directory
+type Transport struct {
+	mode string
+	dir  string
+	next http.RoundTripper // used in record mode
+
+	mu sync.Mutex // serializes writes of the same fixture
+}
+
+// New returns a Transport for mode. In record mode requests are sent through next
+// (http.DefaultTransport if nil).
+func New(mode, dir string, next http.RoundTripper) (*Transport, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("unknown fixture mode %q", mode)
+	}
+	if dir == "" {
+		return nil, errors.New("fixture directory is required")
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{mode: mode, dir: dir, next: next}, nil
+}
+
+// NewReplayer returns a Transport replaying the fixtures of dir, for tests
+func NewReplayer(dir string) *Transport {
+	return &Transport{mode: ModeReplay, dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	name := Name(req.Method, req.URL, body)
+
+	if t.mode == ModeReplay {
+		return t.replay(req, name)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.record(req, name, resp)
+}
+
+// Name returns the fixture name of a request: the host and path for readability, and a
+// hash of method, redacted URL and body telling apart queries and POST payloads
+func Name(method string, u *url.URL, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + redactURL(u)))
+	hash.Write(body)
+	readable := strings.Trim(unsafeNameChars.ReplaceAllString(u.Host+u.Path, "_"), "_")
+	if len(readable) > 80 {
+		readable = readable[:80]
+	}
+	return strings.ToLower(method) + "_" + readable + "_" + hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// redactURL returns u with secret query parameters replaced and parameters sorted
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := u.Query()
+	for param := range query {
+		if secretParams[strings.ToLower(param)] {
+			query[param] = []string{redactedValue}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	redacted.Fragment, redacted.RawFragment = "", ""
+	return redacted.String()
+}
+
+func (t *Transport) replay(req *http.Request, name string) (*http.Response, error) {
+	data, err := os.ReadFile(filepath.Join(t.dir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s %s (%s)", ErrNoFixture, req.Method, redactURL(req.URL), name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var interaction Interaction
+	if err := json.Unmarshal(data, &interaction); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+	}
+
+	var body []byte
+	if interaction.BodyFile != "" {
+		if body, err = os.ReadFile(filepath.Join(t.dir, interaction.BodyFile)); err != nil {
+			return nil, err
+		}
+	}
+	header := interaction.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) record(req *http.Request, name string, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Method: req.Method,
+		URL:    redactURL(req.URL),
+		Status: resp.StatusCode,
+		Header: http.Header{},
+	}
+	keys := make([]string, 0, len(resp.Header))
+	for key := range resp.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !skippedHeaders[http.CanonicalHeaderKey(key)] {
+			interaction.Header[key] = resp.Header[key]
+		}
+	}
+	if len(body) > 0 {
+		interaction.BodyFile = name + bodyExtension(resp.Header.Get("Content-Type"))
+	}
+
+	meta, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if interaction.BodyFile != "" {
+		if err := os.WriteFile(filepath.Join(t.dir, interaction.BodyFile), body, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(t.dir, name+".json"), append(meta, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// bodyExtension picks the body file extension of a content type. JSON bodies get
+// ".json.body" so they are not mistaken for metadata files.
+func bodyExtension(contentType string) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "json"):
+		return ".json.body"
+	case strings.Contains(contentType, "html"):
+		return ".html"
+	case strings.Contains(contentType, "xml"):
+		return ".xml"
+	default:
+		return ".txt"
+	}
+}
@@ -0,0 +1,101 @@
+package httpfixture
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"q":"` + r.URL.Query().Get("q") + `"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := New(ModeRecord, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := get(t, &http.Client{Transport: recorder}, server.URL+"/search?q=sony&api_key=secret")
+	if recorded != `{"q":"sony"}` {
+		t.Fatalf("recorded body = %q", recorded)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 {
+		t.Fatalf("files = %v, want metadata and body", files)
+	}
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("%s contains a secret: %s", file, data)
+		}
+	}
+
+	// The key is ignored, so a replay works without credentials
+	server.Close()
+	replayer := NewReplayer(dir)
+	resp, err := (&http.Client{Transport: replayer}).Get(server.URL + "/search?q=sony&api_key=other")
+	if err != nil {
+		t.Fatalf("replay error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(body) != `{"q":"sony"}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("replayed = %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	_, err = (&http.Client{Transport: replayer}).Get(server.URL + "/search?q=bose")
+	if !errors.Is(err, ErrNoFixture) {
+		t.Errorf("unrecorded request error = %v, want ErrNoFixture", err)
+	}
+}
+
+func TestName(t *testing.T) {
+	request := func(method, rawURL, body string) string {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Name(method, u, []byte(body))
+	}
+
+	name := request("GET", "https://walmart-data.p.rapidapi.com/search?q=sony", "")
+	if !strings.HasPrefix(name, "get_walmart-data.p.rapidapi.com_search_") {
+		t.Errorf("Name() = %q, want a readable prefix", name)
+	}
+	if name != request("GET", "https://walmart-data.p.rapidapi.com/search?q=sony#top", "") {
+		t.Error("the fragment changed the name")
+	}
+	if name == request("GET", "https://walmart-data.p.rapidapi.com/search?q=bose", "") {
+		t.Error("different queries got the same name")
+	}
+	if request("POST", "https://webservices.amazon.com/paapi5/searchitems", `{"Keywords":"sony"}`) ==
+		request("POST", "https://webservices.amazon.com/paapi5/searchitems", `{"Keywords":"bose"}`) {
+		t.Error("different POST bodies got the same name")
+	}
+}
+
+func get(t *testing.T, client *http.Client, rawURL string) string {
+	t.Helper()
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
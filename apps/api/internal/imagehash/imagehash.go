@@ -0,0 +1,225 @@
+// Package imagehash computes perceptual hashes (pHash and dHash) of product images, so the
+// same product photo can be recognized across providers despite resizing and recompression.
+package imagehash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"math/bits"
+	"net/http"
+	"sort"
+)
+
+const (
+	sampleSize = 32 // images are reduced to sampleSize x sampleSize grayscale before the DCT
+	hashSize   = 8  // the hashSize x hashSize lowest frequencies make up the 64-bit hash
+
+	// MaxDistance is the largest possible Hamming distance between two hashes
+	MaxDistance = hashSize * hashSize
+
+	// maxImageBytes caps downloaded images; product photos are far smaller
+	maxImageBytes = 10 << 20
+
+	// maxImagePixels caps the dimensions of decoded images: a small compressed file can
+	// declare a huge canvas that would take gigabytes to decode
+	maxImagePixels = 50_000_000
+)
+
+// Hashes are the perceptual hashes of one image. PHash is the primary matching signal;
+// DHash (gradient based) breaks ties between images at the same pHash distance.
+type Hashes struct {
+	PHash uint64
+	DHash uint64
+}
+
+// Compute returns both hashes of img
+func Compute(img image.Image) Hashes {
+	return Hashes{PHash: PHash(img), DHash: DHash(img)}
+}
+
+// PHash returns the 64-bit perceptual hash of img. Similar images have hashes with a small
+// Hamming distance (see Distance).
+func PHash(img image.Image) uint64 {
+	pixels := grayscale(img, sampleSize, sampleSize)
+	coefficients := dct2D(pixels)
+
+	// Low frequencies without the DC term, which only reflects overall brightness
+	values := make([]float64, 0, hashSize*hashSize)
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			values = append(values, coefficients[y][x])
+		}
+	}
+	sorted := append([]float64(nil), values[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, value := range values {
+		if value > median {
+			hash |= 1 << uint(len(values)-1-i)
+		}
+	}
+	return hash
+}
+
+// DHash returns the 64-bit difference hash of img: each bit tells whether a pixel of the
+// image reduced to (hashSize+1) x hashSize is brighter than its right neighbour
+func DHash(img image.Image) uint64 {
+	pixels := grayscale(img, hashSize+1, hashSize)
+
+	var hash uint64
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			hash <<= 1
+			if pixels[y][x] > pixels[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Decode reads a GIF, JPEG or PNG image from r and returns its perceptual hash
+func Decode(r io.Reader) (uint64, error) {
+	hashes, err := DecodeHashes(r)
+	return hashes.PHash, err
+}
+
+// DecodeHashes reads a GIF, JPEG or PNG image from r and returns its hashes
+func DecodeHashes(r io.Reader) (Hashes, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImageBytes))
+	if err != nil {
+		return Hashes{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Hashes{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return Hashes{}, fmt.Errorf("failed to decode image: %dx%d pixels exceeds the limit", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Hashes{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return Compute(img), nil
+}
+
+// Distance returns the Hamming distance between two hashes (0 = identical, MaxDistance = inverse)
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similarity maps a Hamming distance to a 0..1 score, 1 for identical hashes
+func Similarity(distance int) float64 {
+	return 1 - float64(distance)/MaxDistance
+}
+
+// Fetcher downloads an image. It is satisfied by *httpclient.Client so image downloads get
+// the same robots.txt, rate limit and audit log handling as provider requests.
+type Fetcher interface {
+	Get(ctx context.Context, providerKey, targetURL string) (*http.Response, error)
+}
+
+// Hasher downloads images and computes their perceptual hash
+type Hasher struct {
+	fetcher Fetcher
+}
+
+func NewHasher(fetcher Fetcher) *Hasher {
+	return &Hasher{fetcher: fetcher}
+}
+
+// HashURL downloads the image at imageURL on behalf of providerKey and returns its hashes
+func (h *Hasher) HashURL(ctx context.Context, providerKey, imageURL string) (Hashes, error) {
+	resp, err := h.fetcher.Get(ctx, providerKey, imageURL)
+	if err != nil {
+		return Hashes{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Hashes{}, fmt.Errorf("image request returned status %d", resp.StatusCode)
+	}
+	return DecodeHashes(resp.Body)
+}
+
+// grayscale reduces img to cols x rows luminance values by averaging the source pixels
+// that fall into each cell
+func grayscale(img image.Image, cols, rows int) [][]float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([][]float64, rows)
+	for y := 0; y < rows; y++ {
+		pixels[y] = make([]float64, cols)
+		y0 := bounds.Min.Y + y*height/rows
+		y1 := max(bounds.Min.Y+(y+1)*height/rows, y0+1)
+		for x := 0; x < cols; x++ {
+			x0 := bounds.Min.X + x*width/cols
+			x1 := max(bounds.Min.X+(x+1)*width/cols, x0+1)
+
+			var sum float64
+			var count int
+			for sy := y0; sy < y1 && sy < bounds.Max.Y; sy++ {
+				for sx := x0; sx < x1 && sx < bounds.Max.X; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					// ITU-R BT.601 luma on 16-bit channels
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+			if count > 0 {
+				pixels[y][x] = sum / float64(count)
+			}
+		}
+	}
+	return pixels
+}
+
+// dct2D returns the type-II discrete cosine transform of a square matrix. Only the
+// lowest hashSize frequencies are needed, so only those rows and columns are computed.
+func dct2D(pixels [][]float64) [][]float64 {
+	n := len(pixels)
+	cosines := make([][]float64, hashSize)
+	for u := 0; u < hashSize; u++ {
+		cosines[u] = make([]float64, n)
+		for x := 0; x < n; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / float64(2*n))
+		}
+	}
+
+	// Rows first, then columns
+	rows := make([][]float64, n)
+	for y := 0; y < n; y++ {
+		rows[y] = make([]float64, hashSize)
+		for u := 0; u < hashSize; u++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				sum += pixels[y][x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+
+	coefficients := make([][]float64, hashSize)
+	for v := 0; v < hashSize; v++ {
+		coefficients[v] = make([]float64, hashSize)
+		for u := 0; u < hashSize; u++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients[v][u] = sum
+		}
+	}
+	return coefficients
+}
@@ -0,0 +1,138 @@
+package imagehash
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testImage draws a smooth low-frequency pattern scaled to size x size, so that it
+// has distinct low DCT coefficients like a real photo
+func testImage(size int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			fx, fy := float64(x)/float64(size), float64(y)/float64(size)
+			v := 128 + 60*math.Sin(3*math.Pi*fx)*math.Cos(2*math.Pi*fy) + 40*math.Cos(5*math.Pi*fx*fy) + 20*math.Sin(7*math.Pi*fy)
+			if invert {
+				v = 255 - v
+			}
+			c := uint8(v)
+			img.Set(x, y, color.RGBA{R: c, G: c, B: c, A: 255})
+		}
+	}
+	return img
+}
+
+func TestPHashIsStableAcrossResizeAndRecompression(t *testing.T) {
+	original := PHash(testImage(256, false))
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(120, false), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	resized, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if d := Distance(original, resized); d > 6 {
+		t.Errorf("Distance(original, resized) = %d, want <= 6", d)
+	}
+}
+
+func TestPHashDistinguishesDifferentImages(t *testing.T) {
+	a := PHash(testImage(128, false))
+	b := PHash(testImage(128, true))
+	if d := Distance(a, b); d < 20 {
+		t.Errorf("Distance(a, inverted a) = %d, want >= 20", d)
+	}
+}
+
+func TestDHash(t *testing.T) {
+	original := DHash(testImage(256, false))
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(120, false), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	resized, err := DecodeHashes(&buf)
+	if err != nil {
+		t.Fatalf("DecodeHashes() error = %v", err)
+	}
+	if d := Distance(original, resized.DHash); d > 6 {
+		t.Errorf("Distance(original, resized) = %d, want <= 6", d)
+	}
+	if d := Distance(original, DHash(testImage(128, true))); d < 20 {
+		t.Errorf("Distance(a, inverted a) = %d, want >= 20", d)
+	}
+}
+
+func TestDistanceAndSimilarity(t *testing.T) {
+	if d := Distance(0xFF, 0x0F); d != 4 {
+		t.Errorf("Distance() = %d, want 4", d)
+	}
+	if s := Similarity(0); s != 1 {
+		t.Errorf("Similarity(0) = %v, want 1", s)
+	}
+	if s := Similarity(MaxDistance); s != 0 {
+		t.Errorf("Similarity(MaxDistance) = %v, want 0", s)
+	}
+}
+
+type serverFetcher struct{}
+
+func (serverFetcher) Get(ctx context.Context, providerKey, targetURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestHasherHashURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, testImage(64, false))
+	}))
+	defer server.Close()
+
+	hasher := NewHasher(serverFetcher{})
+	hashes, err := hasher.HashURL(context.Background(), "demo", server.URL+"/image.png")
+	if err != nil {
+		t.Fatalf("HashURL() error = %v", err)
+	}
+	if want := Compute(testImage(64, false)); hashes != want {
+		t.Errorf("HashURL() = %x, want %x", hashes, want)
+	}
+
+	if _, err := hasher.HashURL(context.Background(), "demo", server.URL+"/missing.png"); err == nil {
+		t.Error("HashURL() expected an error for a 404 response")
+	}
+}
+
+func TestDecodeRejectsOversizedImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, testImage(8, false), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// The logical screen size follows the 6-byte signature, little endian
+	copy(data[6:10], []byte{0xff, 0xff, 0xff, 0xff})
+
+	if _, err := Decode(bytes.NewReader(data)); err == nil {
+		t.Error("Decode() of a 65535x65535 image: error = nil")
+	}
+}
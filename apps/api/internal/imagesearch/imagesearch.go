@@ -0,0 +1,114 @@
+// Package imagesearch finds products whose images look like a query image. Query images
+// are uploaded or downloaded from a URL; products are ranked by the Hamming distance of
+// their perceptual hashes, stored in product_images during ingestion and by the
+// backfill_image_hashes job.
+package imagesearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// urlProviderKey is the httpclient provider key (rate limit, audit log) of query images
+// downloaded from a URL
+const urlProviderKey = "image_search"
+
+var (
+	// ErrNoImage is returned for a query with neither an image nor an image URL
+	ErrNoImage = errors.New("image or image_url is required")
+	// ErrInvalidImage is returned when the query image is not a GIF, JPEG or PNG
+	ErrInvalidImage = errors.New("unsupported image format. must be GIF, JPEG, or PNG")
+	// ErrInvalidImageURL is returned for an image URL that is not absolute http(s)
+	ErrInvalidImageURL = errors.New("image_url must be an http or https URL")
+	// ErrImageURLDisabled is returned for image URL queries on a searcher without a hasher
+	ErrImageURLDisabled = errors.New("image_url search is not enabled")
+	// ErrDownload wraps failures to download the image of an image URL query
+	ErrDownload = errors.New("failed to download image")
+)
+
+// Query is an image search. Exactly one of Image and ImageURL is used; Image wins.
+type Query struct {
+	Image       []byte // GIF, JPEG or PNG
+	ImageURL    string
+	MaxDistance int // maximum pHash Hamming distance (0..imagehash.MaxDistance)
+	Limit       int
+}
+
+// Result is a product with an image similar to the query image
+type Result struct {
+	Product         *models.Product
+	MatchedImageURL string
+	Distance        int  // pHash Hamming distance, 0 = same image
+	DHashDistance   *int // nil if the product image has no dHash yet
+	Similarity      float64
+}
+
+// Searcher runs image searches against the stored product image hashes
+type Searcher struct {
+	images repository.ProductImageStore
+	hasher *imagehash.Hasher
+}
+
+// NewSearcher returns a searcher over images. hasher downloads the images of image URL
+// queries; without one only uploaded images are accepted.
+func NewSearcher(images repository.ProductImageStore, hasher *imagehash.Hasher) *Searcher {
+	return &Searcher{images: images, hasher: hasher}
+}
+
+// Search returns the products nearest to the query image, closest first
+func (s *Searcher) Search(ctx context.Context, q Query) ([]Result, error) {
+	hashes, err := s.hash(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := s.images.FindNearest(ctx, hashes, q.MaxDistance, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find products by image hash: %w", err)
+	}
+	results := make([]Result, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, Result{
+			Product:         match.Product,
+			MatchedImageURL: match.ImageURL,
+			Distance:        match.Distance,
+			DHashDistance:   match.DHashDistance,
+			Similarity:      imagehash.Similarity(match.Distance),
+		})
+	}
+	return results, nil
+}
+
+// hash returns the hashes of the query image, downloading it for image URL queries
+func (s *Searcher) hash(ctx context.Context, q Query) (imagehash.Hashes, error) {
+	if len(q.Image) > 0 {
+		hashes, err := imagehash.DecodeHashes(bytes.NewReader(q.Image))
+		if err != nil {
+			return imagehash.Hashes{}, ErrInvalidImage
+		}
+		return hashes, nil
+	}
+	if q.ImageURL == "" {
+		return imagehash.Hashes{}, ErrNoImage
+	}
+
+	u, err := url.Parse(q.ImageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return imagehash.Hashes{}, ErrInvalidImageURL
+	}
+	if s.hasher == nil {
+		return imagehash.Hashes{}, ErrImageURLDisabled
+	}
+	hashes, err := s.hasher.HashURL(ctx, urlProviderKey, u.String())
+	if err != nil {
+		return imagehash.Hashes{}, fmt.Errorf("%w: %v", ErrDownload, err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,119 @@
+package imagesearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// testImage draws a smooth pattern whose phase depends on seed, so different seeds give
+// perceptually different images
+func testImage(size int, seed float64) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			fx, fy := float64(x)/float64(size), float64(y)/float64(size)
+			v := 128 + 60*math.Sin(3*math.Pi*fx+seed)*math.Cos(2*math.Pi*fy) + 40*math.Cos(5*math.Pi*fx*fy+seed) + 20*math.Sin(7*math.Pi*fy)
+			c := uint8(v)
+			img.Set(x, y, color.RGBA{R: c, G: c, B: c, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type serverFetcher struct{}
+
+func (serverFetcher) Get(ctx context.Context, providerKey, targetURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	for i, title := range []string{"Sony WH-1000XM5", "Bose QC45"} {
+		product := &models.Product{Title: title}
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		hashes := imagehash.Compute(testImage(128, float64(i)*2))
+		if err := store.ProductImages().Upsert(ctx, product.ID, "https://img.example.com/"+title+".png", hashes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, testImage(64, 0))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		hasher    *imagehash.Hasher
+		query     Query
+		wantErr   error
+		wantFirst string
+	}{
+		{"uploaded image", nil, Query{Image: encodePNG(t, testImage(96, 0)), MaxDistance: 10, Limit: 5}, nil, "Sony WH-1000XM5"},
+		{"image URL", imagehash.NewHasher(serverFetcher{}), Query{ImageURL: server.URL + "/query.png", MaxDistance: 10, Limit: 5}, nil, "Sony WH-1000XM5"},
+		{"no image", nil, Query{MaxDistance: 10, Limit: 5}, ErrNoImage, ""},
+		{"not an image", nil, Query{Image: []byte("hello"), MaxDistance: 10, Limit: 5}, ErrInvalidImage, ""},
+		{"relative URL", imagehash.NewHasher(serverFetcher{}), Query{ImageURL: "/query.png", MaxDistance: 10, Limit: 5}, ErrInvalidImageURL, ""},
+		{"URL without hasher", nil, Query{ImageURL: server.URL + "/query.png", MaxDistance: 10, Limit: 5}, ErrImageURLDisabled, ""},
+		{"download fails", imagehash.NewHasher(serverFetcher{}), Query{ImageURL: server.URL + "/missing.png", MaxDistance: 10, Limit: 5}, ErrDownload, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := NewSearcher(store.ProductImages(), tt.hasher).Search(ctx, tt.query)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Search() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if len(results) == 0 || results[0].Product.Title != tt.wantFirst {
+				t.Fatalf("Search() = %+v, want %s first", results, tt.wantFirst)
+			}
+			if results[0].DHashDistance == nil || results[0].Similarity < 0.8 {
+				t.Errorf("first result = %+v, want a close match with a dHash distance", results[0])
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i].Distance < results[i-1].Distance {
+					t.Errorf("results are not ordered by distance: %+v", results)
+				}
+			}
+		})
+	}
+}
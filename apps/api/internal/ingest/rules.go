@@ -0,0 +1,84 @@
+// Package ingest decides which search candidates may become products. Generic HTML
+// selectors also match navigation links, ads and pagination, which would otherwise
+// be stored as junk products.
+package ingest
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pricecompare/api/internal/providers"
+)
+
+// Rejection reasons returned by Rules.Check
+const (
+	ReasonShortTitle    = "short_title"
+	ReasonBannedKeyword = "banned_keyword"
+	ReasonNoPrice       = "no_price"
+	ReasonSourceCap     = "source_cap"
+	ReasonDailyQuota    = "daily_quota"
+)
+
+// Rules are the INGEST_* settings
+type Rules struct {
+	MinTitleLength      int      // in characters, after trimming
+	BannedKeywords      []string // matched case-insensitively on word boundaries, e.g. "sponsored"
+	RequirePriceSources []string // sources whose candidates must show a price (ProductCandidate.HasPrice)
+
+	// Quotas of new listings per source, so a site with broken selectors cannot flood the
+	// catalog. Listings already in the catalog are always refreshed.
+	MaxListingsPerSource map[string]int // source -> listings it may have in total
+	DailyListingQuota    map[string]int // source -> listings it may add in 24 hours
+}
+
+// HasQuota reports whether new listings of source are limited
+func (r Rules) HasQuota(source string) bool {
+	return r.MaxListingsPerSource[source] > 0 || r.DailyListingQuota[source] > 0
+}
+
+// CheckQuota returns the reason a new listing of source must not be ingested, given the
+// listings it has (total) and added in the last 24 hours (today), or "" if it fits both
+// quotas
+func (r Rules) CheckQuota(source string, total, today int) string {
+	if limit := r.MaxListingsPerSource[source]; limit > 0 && total >= limit {
+		return ReasonSourceCap
+	}
+	if limit := r.DailyListingQuota[source]; limit > 0 && today >= limit {
+		return ReasonDailyQuota
+	}
+	return ""
+}
+
+// Check returns the reason a candidate from source must not be ingested, or "" if it
+// passes every rule
+func (r Rules) Check(candidate providers.ProductCandidate, source string) string {
+	title := strings.TrimSpace(candidate.Title)
+	if utf8.RuneCountInString(title) < r.MinTitleLength {
+		return ReasonShortTitle
+	}
+
+	words := " " + normalize(title) + " "
+	for _, keyword := range r.BannedKeywords {
+		if keyword = normalize(keyword); keyword != "" && strings.Contains(words, " "+keyword+" ") {
+			return ReasonBannedKeyword
+		}
+	}
+
+	if !candidate.HasPrice {
+		for _, s := range r.RequirePriceSources {
+			if s == source {
+				return ReasonNoPrice
+			}
+		}
+	}
+	return ""
+}
+
+// normalize lowercases s and turns every run of non-alphanumeric characters into a
+// single space, so keywords only match whole words ("cart" does not match "cartridge")
+func normalize(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
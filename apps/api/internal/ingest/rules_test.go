@@ -0,0 +1,63 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/pricecompare/api/internal/providers"
+)
+
+func TestRulesCheck(t *testing.T) {
+	rules := Rules{
+		MinTitleLength:      10,
+		BannedKeywords:      []string{"sponsored", "Sign in", "cart"},
+		RequirePriceSources: []string{"live"},
+	}
+	tests := []struct {
+		name      string
+		candidate providers.ProductCandidate
+		source    string
+		want      string
+	}{
+		{"product", providers.ProductCandidate{Title: "Sony WH-1000XM5 Headphones"}, "walmart", ""},
+		{"navigation text", providers.ProductCandidate{Title: "  Next  "}, "walmart", ReasonShortTitle},
+		{"multibyte title", providers.ProductCandidate{Title: "ソニー ワイヤレスヘッドホン"}, "walmart", ""},
+		{"banned keyword", providers.ProductCandidate{Title: "SPONSORED: Bose QuietComfort 45"}, "walmart", ReasonBannedKeyword},
+		{"banned phrase", providers.ProductCandidate{Title: "Sign-in to see your deals"}, "walmart", ReasonBannedKeyword},
+		{"keyword inside a word", providers.ProductCandidate{Title: "HP 67 Black Ink Cartridge"}, "walmart", ""},
+		{"live without price", providers.ProductCandidate{Title: "Sony WH-1000XM5 Headphones"}, "live", ReasonNoPrice},
+		{"live with price", providers.ProductCandidate{Title: "Sony WH-1000XM5 Headphones", HasPrice: true}, "live", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Check(tt.candidate, tt.source); got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesCheckQuota(t *testing.T) {
+	rules := Rules{
+		MaxListingsPerSource: map[string]int{"live": 100},
+		DailyListingQuota:    map[string]int{"live": 10, "walmart": 0},
+	}
+	tests := []struct {
+		source       string
+		total, today int
+		want         string
+	}{
+		{"live", 50, 9, ""},
+		{"live", 50, 10, ReasonDailyQuota},
+		{"live", 100, 0, ReasonSourceCap},
+		{"walmart", 1000000, 1000, ""},
+	}
+	for _, tt := range tests {
+		if got := rules.CheckQuota(tt.source, tt.total, tt.today); got != tt.want {
+			t.Errorf("CheckQuota(%q, %d, %d) = %q, want %q", tt.source, tt.total, tt.today, got, tt.want)
+		}
+	}
+	if !rules.HasQuota("live") || rules.HasQuota("walmart") || rules.HasQuota("amazon") {
+		t.Error("HasQuota() does not match the configured quotas")
+	}
+}
@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	// catalogReportPeriod is the period covered by the scheduled report
+	catalogReportPeriod = 24 * time.Hour
+	// catalogReportTopChanges is how many of the largest price changes a report lists
+	catalogReportTopChanges = 10
+)
+
+// ErrNotificationsDisabled is returned when a catalog report is to be sent without
+// notification channels
+var ErrNotificationsDisabled = errors.New("no notification channels are configured")
+
+// CatalogReporter computes the catalog summary (new products, large price changes,
+// providers returning nothing, stale offers) and sends it as a catalog_report notification
+type CatalogReporter struct {
+	productRepo        repository.ProductStore
+	offerRepo          repository.OfferStore
+	priceChangeRepo    repository.OfferPriceChangeStore
+	providerFetchRepo  repository.ProviderFetchStore
+	notifier           *notifications.Notifier // nil: reports are only built on request
+	priceChangePercent float64
+	staleAfter         time.Duration
+	logger             *zap.Logger
+}
+
+func NewCatalogReporter(
+	productRepo repository.ProductStore,
+	offerRepo repository.OfferStore,
+	priceChangeRepo repository.OfferPriceChangeStore,
+	providerFetchRepo repository.ProviderFetchStore,
+	notifier *notifications.Notifier,
+	priceChangePercent float64,
+	staleAfter time.Duration,
+	logger *zap.Logger,
+) *CatalogReporter {
+	return &CatalogReporter{
+		productRepo:        productRepo,
+		offerRepo:          offerRepo,
+		priceChangeRepo:    priceChangeRepo,
+		providerFetchRepo:  providerFetchRepo,
+		notifier:           notifier,
+		priceChangePercent: priceChangePercent,
+		staleAfter:         staleAfter,
+		logger:             logger,
+	}
+}
+
+// CanNotify reports whether HandleCatalogReport has channels to deliver to
+func (r *CatalogReporter) CanNotify() bool {
+	return r.notifier != nil
+}
+
+// Build computes the report for the period from..to. Stale offers are counted as of to.
+func (r *CatalogReporter) Build(ctx context.Context, from, to time.Time) (*models.CatalogReport, error) {
+	report := &models.CatalogReport{
+		From:                        from,
+		To:                          to,
+		PriceChangeThresholdPercent: r.priceChangePercent,
+		ZeroResultProviders:         []*models.ProviderStats{},
+		StaleAfterHours:             int(r.staleAfter / time.Hour),
+	}
+
+	var err error
+	if report.NewProducts, err = r.productRepo.CountCreatedSince(ctx, from); err != nil {
+		return nil, fmt.Errorf("failed to count new products: %w", err)
+	}
+	if report.PriceChanges, err = r.priceChangeRepo.CountSince(ctx, from, r.priceChangePercent); err != nil {
+		return nil, fmt.Errorf("failed to count price changes: %w", err)
+	}
+	if report.LargestPriceChanges, err = r.priceChangeRepo.ListLargestSince(ctx, from, r.priceChangePercent, catalogReportTopChanges); err != nil {
+		return nil, fmt.Errorf("failed to list price changes: %w", err)
+	}
+
+	stats, err := r.providerFetchRepo.Stats(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider stats: %w", err)
+	}
+	for _, s := range stats {
+		if s.Requests > 0 && s.Results == 0 {
+			report.ZeroResultProviders = append(report.ZeroResultProviders, s)
+		}
+	}
+
+	if report.StaleOffersBySource, err = r.offerRepo.CountFetchedBefore(ctx, to.Add(-r.staleAfter)); err != nil {
+		return nil, fmt.Errorf("failed to count stale offers: %w", err)
+	}
+	for _, count := range report.StaleOffersBySource {
+		report.StaleOffers += count
+	}
+	return report, nil
+}
+
+// HandleCatalogReport sends the report for the last 24 hours
+func (r *CatalogReporter) HandleCatalogReport(ctx context.Context, t *asynq.Task) error {
+	r.logger.Info("Processing catalog_report job")
+	if r.notifier == nil {
+		return fmt.Errorf("%w: %w", ErrNotificationsDisabled, asynq.SkipRetry)
+	}
+
+	now := time.Now()
+	report, err := r.Build(ctx, now.Add(-catalogReportPeriod), now)
+	if err != nil {
+		return err
+	}
+	if err := r.notifier.Notify(ctx, notifications.KindCatalogReport, report); err != nil {
+		return fmt.Errorf("failed to send catalog report: %w", err)
+	}
+
+	zeroResult := make([]string, 0, len(report.ZeroResultProviders))
+	for _, s := range report.ZeroResultProviders {
+		zeroResult = append(zeroResult, s.Provider)
+	}
+	r.logger.Info("Catalog report sent",
+		zap.Int("new_products", report.NewProducts),
+		zap.Int("price_changes", report.PriceChanges),
+		zap.Strings("zero_result_providers", zeroResult),
+		zap.Int("stale_offers", report.StaleOffers),
+	)
+	return nil
+}
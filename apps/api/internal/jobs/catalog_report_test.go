@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+type recordingSender struct {
+	messages []notifications.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg notifications.Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestCatalogReport(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Offers().Create(ctx, &models.Offer{ProductID: product.ID, Source: "walmart", Seller: "Walmart"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range []*models.OfferPriceChange{
+		{ProductID: product.ID, Source: "amazon", Seller: "Amazon", OldTotalToUSAmount: 30000, NewTotalToUSAmount: 24000},
+		{ProductID: product.ID, Source: "amazon", Seller: "Amazon", OldTotalToUSAmount: 24000, NewTotalToUSAmount: 24500},
+	} {
+		if err := store.OfferPriceChanges().Record(ctx, change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetches := store.ProviderFetches()
+	_ = fetches.Record(ctx, "walmart", models.ProviderOperationSearch, time.Second, 0, nil)
+	_ = fetches.Record(ctx, "amazon", models.ProviderOperationSearch, time.Second, 3, nil)
+
+	sender := &recordingSender{}
+	notifier := notifications.NewNotifier(map[string]notifications.Sender{notifications.ChannelSlack: sender}, nil)
+	reporter := NewCatalogReporter(store.Products(), store.Offers(), store.OfferPriceChanges(), store.ProviderFetches(), notifier, 10, 48*time.Hour, zap.NewNop())
+
+	// Three days from now, the offer fetched now is stale
+	now := time.Now()
+	report, err := reporter.Build(ctx, now.Add(-time.Hour), now.Add(72*time.Hour))
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if report.NewProducts != 1 {
+		t.Errorf("NewProducts = %d, want 1", report.NewProducts)
+	}
+	if report.PriceChanges != 1 || len(report.LargestPriceChanges) != 1 || report.LargestPriceChanges[0].ChangePercent != -20 {
+		t.Errorf("price changes = %d %+v, want only the -20%% change", report.PriceChanges, report.LargestPriceChanges)
+	}
+	if len(report.ZeroResultProviders) != 1 || report.ZeroResultProviders[0].Provider != "walmart" {
+		t.Errorf("ZeroResultProviders = %+v, want walmart", report.ZeroResultProviders)
+	}
+	if report.StaleOffers != 1 || report.StaleOffersBySource["walmart"] != 1 {
+		t.Errorf("stale offers = %d %v, want 1 walmart offer", report.StaleOffers, report.StaleOffersBySource)
+	}
+
+	if err := notifier.Notify(ctx, notifications.KindCatalogReport, report); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	body := sender.messages[0].Body
+	for _, want := range []string{
+		"New products: 1",
+		"Price changes of 10% or more: 1",
+		"-20.0% Sony WH-1000XM5 (amazon, Amazon): $300.00 -> $240.00",
+		"walmart: 1 calls, 0 errors",
+		"Offers not refreshed for 48 hours: 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("report body does not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestCatalogReportWithoutNotifier(t *testing.T) {
+	store := memory.New()
+	reporter := NewCatalogReporter(store.Products(), store.Offers(), store.OfferPriceChanges(), store.ProviderFetches(), nil, 10, 48*time.Hour, zap.NewNop())
+	if reporter.CanNotify() {
+		t.Error("CanNotify() = true without a notifier")
+	}
+	if err := reporter.HandleCatalogReport(context.Background(), nil); err == nil {
+		t.Error("HandleCatalogReport() succeeded without a notifier")
+	}
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+)
+
+// CrawlBudget bounds the work of one fetch_prices run, so a misbehaving site cannot turn
+// a run into a multi-hour crawl. Zero values are unlimited.
+type CrawlBudget struct {
+	MaxCandidatesPerQuery int // candidates processed per search query of remote providers
+	MaxOffersPerProduct   int // offers saved per product and source
+	MaxRequests           int // provider calls (searches and offer fetches) across all sources
+}
+
+// withOverrides returns the budget with the fields set in the payload replaced
+func (b CrawlBudget) withOverrides(payload FetchPricesPayload) CrawlBudget {
+	if payload.MaxCandidatesPerQuery != nil {
+		b.MaxCandidatesPerQuery = *payload.MaxCandidatesPerQuery
+	}
+	if payload.MaxOffersPerProduct != nil {
+		b.MaxOffersPerProduct = *payload.MaxOffersPerProduct
+	}
+	if payload.MaxRequests != nil {
+		b.MaxRequests = *payload.MaxRequests
+	}
+	return b
+}
+
+// crawlRun tracks the budget spent by one fetch_prices run. Runs process sources and
+// candidates sequentially, so it is not synchronized.
+type crawlRun struct {
+	budget   CrawlBudget
+	requests int
+
+	listings map[string]*listingCounts // source -> its listings, see checkListingQuota
+
+	products models.JobRunProducts // candidates linked to products, see linkProduct
+}
+
+// exhausted reports whether no provider call is left
+func (r *crawlRun) exhausted() bool {
+	return r.budget.MaxRequests > 0 && r.requests >= r.budget.MaxRequests
+}
+
+// take spends one provider call, or returns false when none is left
+func (r *crawlRun) take() bool {
+	if r.exhausted() {
+		return false
+	}
+	r.requests++
+	return true
+}
+
+// candidates returns the candidates of one query of source that may be processed. The
+// cap protects remote sites, so the built-in data of demo and public_html is not capped.
+func (r *crawlRun) candidates(source string, candidates []providers.ProductCandidate) []providers.ProductCandidate {
+	if providers.SourceKind(source) == providers.KindDemo {
+		return candidates
+	}
+	if r.budget.MaxCandidatesPerQuery > 0 && len(candidates) > r.budget.MaxCandidatesPerQuery {
+		return candidates[:r.budget.MaxCandidatesPerQuery]
+	}
+	return candidates
+}
+
+// offers returns the offers of one product that may be saved, in provider order
+func (r *crawlRun) offers(offers []*models.Offer) []*models.Offer {
+	if r.budget.MaxOffersPerProduct > 0 && len(offers) > r.budget.MaxOffersPerProduct {
+		return offers[:r.budget.MaxOffersPerProduct]
+	}
+	return offers
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// countingProvider returns 3 candidates per query and 4 offers per product, and counts calls
+type countingProvider struct {
+	searches     int
+	offerFetches int
+}
+
+func (p *countingProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	p.searches++
+	candidates := make([]providers.ProductCandidate, 3)
+	for i := range candidates {
+		candidates[i] = providers.ProductCandidate{Title: fmt.Sprintf("Gadget %s %d", query, i)}
+	}
+	return candidates, nil
+}
+
+func (p *countingProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	p.offerFetches++
+	offers := make([]*models.Offer, 4)
+	for i := range offers {
+		url := fmt.Sprintf("https://shop.example.com/%s/%d", product.ID, i)
+		offers[i] = &models.Offer{
+			ProductID:   product.ID,
+			Source:      "demo",
+			Seller:      fmt.Sprintf("Seller %d", i),
+			PriceAmount: 1000 + i*100,
+			Currency:    "USD",
+			InStock:     true,
+			URL:         &url,
+		}
+	}
+	return offers, nil
+}
+
+func TestHandleFetchPricesCrawlBudget(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name           string
+		source         string
+		budget         CrawlBudget
+		payload        FetchPricesPayload
+		wantSearches   int
+		wantFetches    int
+		wantOffersEach int
+	}{
+		{"unlimited", "live", CrawlBudget{}, FetchPricesPayload{}, 3, 9, 4},
+		{"configured limits", "live", CrawlBudget{MaxCandidatesPerQuery: 2, MaxOffersPerProduct: 1}, FetchPricesPayload{}, 3, 6, 1},
+		{"request budget", "live", CrawlBudget{MaxRequests: 5}, FetchPricesPayload{}, 2, 3, 4},
+		{"payload overrides", "live", CrawlBudget{MaxCandidatesPerQuery: 2, MaxRequests: 5}, FetchPricesPayload{MaxCandidatesPerQuery: intPtr(1), MaxRequests: intPtr(0)}, 3, 3, 4},
+		// Built-in data does not load a remote site, so only the other limits apply
+		{"demo ignores the per-query cap", "demo", CrawlBudget{MaxCandidatesPerQuery: 1, MaxOffersPerProduct: 1}, FetchPricesPayload{}, 3, 9, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New()
+			provider := &countingProvider{}
+			manager := providers.NewManager()
+			manager.Register(tt.source, provider)
+			processor := newTestProcessor(t, store, manager)
+			processor.SetCrawlBudget(tt.budget)
+
+			payload := tt.payload
+			payload.Source = tt.source
+			data, err := json.Marshal(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+				t.Fatalf("HandleFetchPrices() error = %v", err)
+			}
+
+			if provider.searches != tt.wantSearches || provider.offerFetches != tt.wantFetches {
+				t.Errorf("searches = %d, offer fetches = %d, want %d and %d", provider.searches, provider.offerFetches, tt.wantSearches, tt.wantFetches)
+			}
+			summaries, err := store.Products().ListSummariesAfter(ctx, uuid.Nil, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(summaries) != tt.wantFetches {
+				t.Errorf("%d products saved, want %d", len(summaries), tt.wantFetches)
+			}
+			for _, summary := range summaries {
+				if summary.OfferCount != tt.wantOffersEach {
+					t.Errorf("%s has %d offers, want %d", summary.Product.Title, summary.OfferCount, tt.wantOffersEach)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// brandProvider returns one product with the given brand and image
+type brandProvider struct {
+	brand, imageURL string
+}
+
+func (p *brandProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	return []providers.ProductCandidate{{Title: "Sony WH-1000XM5 Wireless Headphones", Brand: &p.brand, ImageURL: &p.imageURL}}, nil
+}
+
+func (p *brandProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	return nil, nil
+}
+
+func TestHandleFetchPricesKeepsCuratedFields(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	provider := &brandProvider{brand: "Sny", imageURL: "https://example.com/a.jpg"}
+	processor := newTestProcessor(t, store, demoProviders(provider))
+	processor.EnableCuratedFields(store.ProductRevisions())
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+
+	processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data))
+	products, _, err := store.Products().Search(ctx, "Sony", "", 10, 0)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("Search() = %v, %v, want one product", products, err)
+	}
+	product := products[0]
+
+	oldBrand, newBrand := "Sny", "Sony"
+	product.Brand = &newBrand
+	revision := &models.ProductRevision{Changes: models.FieldChanges{
+		models.ProductFieldBrand: {Old: &oldBrand, New: &newBrand},
+	}}
+	if err := store.ProductRevisions().Apply(ctx, product, revision); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.imageURL = "https://example.com/b.jpg"
+	processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data))
+	stored, _ := store.Products().GetByID(ctx, product.ID)
+	if stored.Brand == nil || *stored.Brand != "Sony" {
+		t.Errorf("brand = %v, want the curated Sony", stored.Brand)
+	}
+	if stored.ImageURL == nil || *stored.ImageURL != "https://example.com/b.jpg" {
+		t.Errorf("image_url = %v, want the fetched image", stored.ImageURL)
+	}
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// maxTitleDuplicates caps title-based suggestions per run so one scan stays cheap
+const maxTitleDuplicates = 500
+
+// DuplicateDetector scans for probable duplicate products and stores merge suggestions
+type DuplicateDetector struct {
+	mergeCandidateRepo  repository.MergeCandidateStore
+	titleMatchThreshold float64
+	logger              *zap.Logger
+}
+
+func NewDuplicateDetector(
+	mergeCandidateRepo repository.MergeCandidateStore,
+	titleMatchThreshold float64,
+	logger *zap.Logger,
+) *DuplicateDetector {
+	return &DuplicateDetector{
+		mergeCandidateRepo:  mergeCandidateRepo,
+		titleMatchThreshold: titleMatchThreshold,
+		logger:              logger,
+	}
+}
+
+func (d *DuplicateDetector) HandleDetectDuplicates(ctx context.Context, t *asynq.Task) error {
+	d.logger.Info("Processing detect_duplicates job")
+
+	titleDuplicates, err := d.mergeCandidateRepo.FindTitleDuplicates(ctx, d.titleMatchThreshold, maxTitleDuplicates)
+	if err != nil {
+		return err
+	}
+	identifierDuplicates, err := d.mergeCandidateRepo.FindIdentifierDuplicates(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Identifier matches are stored last so they take precedence over title matches
+	// for the same pair
+	candidates := append(titleDuplicates, identifierDuplicates...)
+	saved := 0
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.mergeCandidateRepo.UpsertPending(ctx, candidate); errors.Is(err, repository.ErrMergeCandidateResolved) {
+			continue // already merged or dismissed by an admin
+		} else if err != nil {
+			d.logger.Warn("Failed to save merge candidate",
+				zap.String("product_id", candidate.ProductID.String()),
+				zap.String("duplicate_product_id", candidate.DuplicateProductID.String()),
+				zap.Error(err),
+			)
+			continue
+		}
+		saved++
+	}
+
+	d.logger.Info("Duplicate detection finished",
+		zap.Int("title_matches", len(titleDuplicates)),
+		zap.Int("identifier_matches", len(identifierDuplicates)),
+		zap.Int("saved", saved),
+	)
+	return nil
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/embedding"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// embeddingBackfillBatchSize is the number of products read and embedded per request
+const embeddingBackfillBatchSize = 64
+
+// EmbeddingBackfiller embeds the titles of products that have no embedding for the
+// configured model, e.g. products ingested before EMBEDDING_BACKEND was set or before
+// EMBEDDING_MODEL changed, so embedding matching covers the whole catalog
+type EmbeddingBackfiller struct {
+	embeddingRepo repository.ProductEmbeddingStore
+	embedder      embedding.Embedder
+	logger        *zap.Logger
+}
+
+func NewEmbeddingBackfiller(embeddingRepo repository.ProductEmbeddingStore, embedder embedding.Embedder, logger *zap.Logger) *EmbeddingBackfiller {
+	return &EmbeddingBackfiller{
+		embeddingRepo: embeddingRepo,
+		embedder:      embedder,
+		logger:        logger,
+	}
+}
+
+// HandleBackfillEmbeddings embeds every product without an embedding once. A batch the
+// embedding backend rejects is logged and skipped; the next run tries it again.
+func (b *EmbeddingBackfiller) HandleBackfillEmbeddings(ctx context.Context, t *asynq.Task) error {
+	b.logger.Info("Processing backfill_embeddings job", zap.String("model", b.embedder.Model()))
+
+	embedded, failed := 0, 0
+	afterID := uuid.Nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		products, err := b.embeddingRepo.ListUnembedded(ctx, b.embedder.Model(), afterID, embeddingBackfillBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list products without embeddings: %w", err)
+		}
+		if len(products) == 0 {
+			break
+		}
+		afterID = products[len(products)-1].ID
+
+		titles := make([]string, len(products))
+		for i, product := range products {
+			titles[i] = product.Title
+		}
+		vectors, err := b.embedder.Embed(ctx, titles)
+		if err != nil {
+			b.logger.Warn("Failed to embed product titles", zap.Int("products", len(products)), zap.Error(err))
+			failed += len(products)
+			continue
+		}
+		for i, product := range products {
+			if err := b.embeddingRepo.Upsert(ctx, product.ID, b.embedder.Model(), vectors[i]); err != nil {
+				return fmt.Errorf("failed to save product embedding: %w", err)
+			}
+			embedded++
+		}
+	}
+
+	b.logger.Info("Product embeddings backfilled", zap.Int("embedded", embedded), zap.Int("failed", failed))
+	return nil
+}
@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// titleLengthEmbedder embeds a title as its length
+type titleLengthEmbedder struct {
+	calls int
+}
+
+func (e *titleLengthEmbedder) Model() string {
+	return "test-model"
+}
+
+func (e *titleLengthEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), 1}
+	}
+	return vectors, nil
+}
+
+func TestHandleBackfillEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	for _, title := range []string{"Sony WH-1000XM5", "Apple AirPods Pro", "Already embedded"} {
+		product := &models.Product{Title: title}
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		if title == "Already embedded" {
+			if err := store.ProductEmbeddings().Upsert(ctx, product.ID, "test-model", []float32{0, 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	embedder := &titleLengthEmbedder{}
+	backfiller := NewEmbeddingBackfiller(store.ProductEmbeddings(), embedder, zap.NewNop())
+	if err := backfiller.HandleBackfillEmbeddings(ctx, asynq.NewTask(TypeBackfillEmbeddings, nil)); err != nil {
+		t.Fatalf("HandleBackfillEmbeddings() error = %v", err)
+	}
+
+	if embedder.calls != 1 {
+		t.Errorf("Embed() called %d times, want one batch", embedder.calls)
+	}
+	unembedded, err := store.ProductEmbeddings().ListUnembedded(ctx, "test-model", uuid.Nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unembedded) != 0 {
+		t.Errorf("ListUnembedded() = %d products, want none left", len(unembedded))
+	}
+	other, _ := store.ProductEmbeddings().ListUnembedded(ctx, "other-model", uuid.Nil, 10)
+	if len(other) != 3 {
+		t.Errorf("ListUnembedded(other-model) = %d products, want 3", len(other))
+	}
+}
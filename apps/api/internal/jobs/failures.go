@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/notifications"
+)
+
+// failureNotifyTimeout bounds the delivery of one failure alert
+const failureNotifyTimeout = 30 * time.Second
+
+// FailureAlerter sends a job_failure notification when a task has exhausted its retries
+// (or was told to skip them). It is installed as the asynq server's ErrorHandler, which
+// runs after every failed attempt. Alerts are throttled per task type so a broken
+// provider does not flood the channels.
+type FailureAlerter struct {
+	notifier *notifications.Notifier
+	cooldown time.Duration
+	logger   *zap.Logger
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time // task type -> last alert
+	suppressed map[string]int       // task type -> failures since the last alert
+}
+
+func NewFailureAlerter(notifier *notifications.Notifier, cooldown time.Duration, logger *zap.Logger) *FailureAlerter {
+	return &FailureAlerter{
+		notifier:   notifier,
+		cooldown:   cooldown,
+		logger:     logger,
+		lastSent:   make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// HandleError implements asynq.ErrorHandler
+func (a *FailureAlerter) HandleError(ctx context.Context, task *asynq.Task, err error) {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried < maxRetry && !errors.Is(err, asynq.SkipRetry) {
+		return // asynq retries the task
+	}
+
+	now := time.Now()
+	suppressed, ok := a.shouldAlert(task.Type(), now)
+	if !ok {
+		return
+	}
+	taskID, _ := asynq.GetTaskID(ctx)
+	queue, _ := asynq.GetQueueName(ctx)
+	failure := notifications.JobFailure{
+		Type:       task.Type(),
+		TaskID:     taskID,
+		Queue:      queue,
+		Retried:    retried,
+		MaxRetry:   maxRetry,
+		Error:      err.Error(),
+		FailedAt:   now,
+		Suppressed: suppressed,
+	}
+
+	// The handler runs on the worker goroutine; delivery must not hold it up
+	go func() {
+		notifyCtx, cancel := context.WithTimeout(context.Background(), failureNotifyTimeout)
+		defer cancel()
+		if err := a.notifier.Notify(notifyCtx, notifications.KindJobFailure, failure); err != nil {
+			a.logger.Error("Failed to send job failure notification", zap.String("type", failure.Type), zap.Error(err))
+		}
+	}()
+}
+
+// shouldAlert reports whether a failure of taskType is alerted now and how many failures
+// were suppressed since the previous alert
+func (a *FailureAlerter) shouldAlert(taskType string, now time.Time) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if last, ok := a.lastSent[taskType]; ok && now.Sub(last) < a.cooldown {
+		a.suppressed[taskType]++
+		return 0, false
+	}
+	suppressed := a.suppressed[taskType]
+	a.lastSent[taskType] = now
+	delete(a.suppressed, taskType)
+	return suppressed, true
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFailureAlerter_ShouldAlert(t *testing.T) {
+	alerter := NewFailureAlerter(nil, 30*time.Minute, zap.NewNop())
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		taskType       string
+		at             time.Duration // since start
+		wantAlert      bool
+		wantSuppressed int
+	}{
+		{taskType: TypeFetchPrices, at: 0, wantAlert: true},
+		{taskType: TypeFetchPrices, at: 10 * time.Minute},
+		{taskType: TypeDetectDuplicates, at: 11 * time.Minute, wantAlert: true}, // cooldown is per type
+		{taskType: TypeFetchPrices, at: 20 * time.Minute},
+		{taskType: TypeFetchPrices, at: 30 * time.Minute, wantAlert: true, wantSuppressed: 2},
+		{taskType: TypeFetchPrices, at: 61 * time.Minute, wantAlert: true},
+	}
+
+	for i, step := range steps {
+		suppressed, ok := alerter.shouldAlert(step.taskType, start.Add(step.at))
+		if ok != step.wantAlert || suppressed != step.wantSuppressed {
+			t.Errorf("step %d (%s at %v): shouldAlert() = %d, %v, want %d, %v",
+				i, step.taskType, step.at, suppressed, ok, step.wantSuppressed, step.wantAlert)
+		}
+	}
+}
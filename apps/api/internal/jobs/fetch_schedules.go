@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// PeriodicScheduler enqueues tasks on cron schedules. It is implemented by
+// *asynq.Scheduler and *InlineScheduler.
+type PeriodicScheduler interface {
+	Register(cronspec string, task *asynq.Task, opts ...asynq.Option) (string, error)
+	Unregister(entryID string) error
+}
+
+// ValidateCron checks a cron spec as the schedulers parse it: 5 standard fields or a
+// descriptor such as "@daily" or "@every 6h"
+func ValidateCron(spec string) error {
+	_, err := cron.ParseStandard(spec)
+	return err
+}
+
+// RegisterUnique registers task on spec with asynq.Unique for one interval of the
+// schedule. Every instance runs a scheduler, so without it each would enqueue the task on
+// every tick.
+func RegisterUnique(scheduler PeriodicScheduler, spec string, task *asynq.Task) (string, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return "", err
+	}
+	next := schedule.Next(time.Now())
+	interval := max(schedule.Next(next).Sub(next), time.Second)
+	return scheduler.Register(spec, task, asynq.Unique(interval))
+}
+
+// FetchScheduler registers a fetch_prices task on a PeriodicScheduler for every active
+// fetch schedule. Sync applies stored changes; it runs after every change through the
+// admin API and periodically (see Run), so changes made on another instance are
+// picked up.
+type FetchScheduler struct {
+	schedules repository.FetchScheduleStore
+	scheduler PeriodicScheduler
+	logger    *zap.Logger
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]scheduledFetch // by schedule ID
+}
+
+type scheduledFetch struct {
+	entryID string
+	source  string
+	cron    string
+}
+
+func NewFetchScheduler(schedules repository.FetchScheduleStore, scheduler PeriodicScheduler, logger *zap.Logger) *FetchScheduler {
+	return &FetchScheduler{
+		schedules: schedules,
+		scheduler: scheduler,
+		logger:    logger,
+		entries:   make(map[uuid.UUID]scheduledFetch),
+	}
+}
+
+// Sync registers new and resumed schedules and unregisters paused, deleted and changed
+// ones. A schedule that fails to register is reported and retried on the next Sync.
+func (s *FetchScheduler) Sync(ctx context.Context) error {
+	schedules, err := s.schedules.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make(map[uuid.UUID]scheduledFetch)
+	for _, schedule := range schedules {
+		if !schedule.Paused {
+			active[schedule.ID] = scheduledFetch{source: schedule.Source, cron: schedule.Cron}
+		}
+	}
+	for id, entry := range s.entries {
+		if want, ok := active[id]; ok && want.source == entry.source && want.cron == entry.cron {
+			continue
+		}
+		if err := s.scheduler.Unregister(entry.entryID); err != nil {
+			s.logger.Warn("Failed to unregister fetch schedule", zap.String("schedule_id", id.String()), zap.Error(err))
+		}
+		delete(s.entries, id)
+	}
+
+	var errs []error
+	for id, want := range active {
+		if _, ok := s.entries[id]; ok {
+			continue
+		}
+		payload, err := json.Marshal(FetchPricesPayload{Source: want.source})
+		if err != nil {
+			return err
+		}
+		want.entryID, err = RegisterUnique(s.scheduler, want.cron, asynq.NewTask(TypeFetchPrices, payload))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fetch schedule %s (%q): %w", id, want.cron, err))
+			continue
+		}
+		s.entries[id] = want
+		s.logger.Info("Scheduled fetch_prices",
+			zap.String("schedule_id", id.String()),
+			zap.String("source", want.source),
+			zap.String("cron", want.cron),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// Run calls Sync every interval until ctx is canceled
+func (s *FetchScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx); err != nil {
+				s.logger.Error("Failed to sync fetch schedules", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// recordingScheduler keeps the registered tasks by entry ID
+type recordingScheduler struct {
+	entries map[string]*asynq.Task
+	specs   map[string]string
+	nextID  int
+}
+
+func (s *recordingScheduler) Register(cronspec string, task *asynq.Task, opts ...asynq.Option) (string, error) {
+	if err := ValidateCron(cronspec); err != nil {
+		return "", err
+	}
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.entries[id] = task
+	s.specs[id] = cronspec
+	return id, nil
+}
+
+func (s *recordingScheduler) Unregister(entryID string) error {
+	delete(s.entries, entryID)
+	delete(s.specs, entryID)
+	return nil
+}
+
+// sources returns "source cron" of every registered fetch_prices task
+func (s *recordingScheduler) sources(t *testing.T) map[string]bool {
+	t.Helper()
+	result := make(map[string]bool)
+	for id, task := range s.entries {
+		var payload FetchPricesPayload
+		if task.Type() != TypeFetchPrices || json.Unmarshal(task.Payload(), &payload) != nil {
+			t.Fatalf("registered task %s %s", task.Type(), task.Payload())
+		}
+		result[payload.Source+" "+s.specs[id]] = true
+	}
+	return result
+}
+
+func TestFetchSchedulerSync(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	schedules := store.FetchSchedules()
+	scheduler := &recordingScheduler{entries: map[string]*asynq.Task{}, specs: map[string]string{}}
+	fetchScheduler := NewFetchScheduler(schedules, scheduler, zap.NewNop())
+
+	if err := schedules.ReplaceConfigured(ctx, map[string]string{"walmart": "0 */6 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	amazon := &models.FetchSchedule{Source: "amazon", Cron: "@every 12h"}
+	if err := schedules.Create(ctx, amazon); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchScheduler.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := scheduler.sources(t); len(got) != 2 || !got["walmart 0 */6 * * *"] || !got["amazon @every 12h"] {
+		t.Errorf("after first sync registered %v", got)
+	}
+
+	// Pausing unregisters, a changed env spec is re-registered
+	if err := schedules.SetPaused(ctx, amazon.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := schedules.ReplaceConfigured(ctx, map[string]string{"walmart": "0 */3 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchScheduler.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := scheduler.sources(t); len(got) != 1 || !got["walmart 0 */3 * * *"] {
+		t.Errorf("after pause registered %v", got)
+	}
+
+	// Env schedules removed from the config are deleted
+	if err := schedules.ReplaceConfigured(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchScheduler.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := scheduler.sources(t); len(got) != 0 {
+		t.Errorf("after removing the env schedule registered %v", got)
+	}
+	if list, _ := schedules.List(ctx); len(list) != 1 || list[0].ID != amazon.ID {
+		t.Errorf("schedules = %v, want only the paused API schedule", list)
+	}
+}
+
+func TestRegisterUnique(t *testing.T) {
+	scheduler := &optionScheduler{}
+	if _, err := RegisterUnique(scheduler, "0 */6 * * *", asynq.NewTask(TypeMaintenance, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduler.opts) != 1 || scheduler.opts[0].Type() != asynq.UniqueOpt || scheduler.opts[0].Value() != 6*time.Hour {
+		t.Errorf("options = %v, want Unique(6h)", scheduler.opts)
+	}
+	if _, err := RegisterUnique(scheduler, "not a spec", asynq.NewTask(TypeMaintenance, nil)); err == nil {
+		t.Error("invalid spec was registered")
+	}
+}
+
+// optionScheduler keeps the options of the last registration
+type optionScheduler struct {
+	opts []asynq.Option
+}
+
+func (s *optionScheduler) Register(cronspec string, task *asynq.Task, opts ...asynq.Option) (string, error) {
+	s.opts = opts
+	return "1", nil
+}
+
+func (s *optionScheduler) Unregister(entryID string) error { return nil }
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+const (
+	// imageBackfillBatchSize is the number of products read per page in a backfill
+	imageBackfillBatchSize = 100
+
+	// imageBackfillProviderKey is the httpclient provider key (rate limit, audit log) of
+	// image downloads by the backfill; the source of a product's image is not stored
+	imageBackfillProviderKey = "image_backfill"
+)
+
+// ImageHashBackfiller hashes the images of products that have none stored, e.g. products
+// ingested before IMAGE_HASH_ENABLED or before dHash was stored, so image search and
+// image matching cover the whole catalog
+type ImageHashBackfiller struct {
+	imageRepo repository.ProductImageStore
+	hasher    *imagehash.Hasher
+	logger    *zap.Logger
+}
+
+func NewImageHashBackfiller(imageRepo repository.ProductImageStore, hasher *imagehash.Hasher, logger *zap.Logger) *ImageHashBackfiller {
+	return &ImageHashBackfiller{
+		imageRepo: imageRepo,
+		hasher:    hasher,
+		logger:    logger,
+	}
+}
+
+// HandleBackfillImageHashes hashes every unhashed product image once. Images that cannot
+// be downloaded or decoded are logged and skipped; the next run tries them again.
+func (b *ImageHashBackfiller) HandleBackfillImageHashes(ctx context.Context, t *asynq.Task) error {
+	b.logger.Info("Processing backfill_image_hashes job")
+
+	hashed, failed := 0, 0
+	afterID := uuid.Nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		products, err := b.imageRepo.ListUnhashed(ctx, afterID, imageBackfillBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list unhashed product images: %w", err)
+		}
+		if len(products) == 0 {
+			break
+		}
+
+		for _, product := range products {
+			hashes, err := b.hasher.HashURL(ctx, imageBackfillProviderKey, *product.ImageURL)
+			if err != nil {
+				b.logger.Warn("Failed to hash product image",
+					zap.String("product_id", product.ID.String()),
+					zap.String("image_url", *product.ImageURL),
+					zap.Error(err),
+				)
+				failed++
+				continue
+			}
+			if err := b.imageRepo.Upsert(ctx, product.ID, *product.ImageURL, hashes); err != nil {
+				return fmt.Errorf("failed to save product image hash: %w", err)
+			}
+			hashed++
+		}
+		afterID = products[len(products)-1].ID
+	}
+
+	b.logger.Info("Product image hashes backfilled", zap.Int("hashed", hashed), zap.Int("failed", failed))
+	return nil
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+type imageFetcher struct{}
+
+func (imageFetcher) Get(ctx context.Context, providerKey, targetURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestHandleBackfillImageHashes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.png" {
+			http.NotFound(w, r)
+			return
+		}
+		img := image.NewGray(image.Rect(0, 0, 32, 32))
+		for x := 0; x < 32; x++ {
+			for y := 0; y < 32; y++ {
+				img.SetGray(x, y, color.Gray{Y: uint8(x * y)})
+			}
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, img)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	store := memory.New()
+	imageURL := func(path string) *string {
+		url := server.URL + path
+		return &url
+	}
+	for _, product := range []*models.Product{
+		{Title: "Unhashed", ImageURL: imageURL("/a.png")},
+		{Title: "Broken image", ImageURL: imageURL("/broken.png")},
+		{Title: "No image"},
+		{Title: "Already hashed", ImageURL: imageURL("/c.png")},
+	} {
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		if product.Title == "Already hashed" {
+			if err := store.ProductImages().Upsert(ctx, product.ID, *product.ImageURL, imagehash.Hashes{PHash: 1, DHash: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	backfiller := NewImageHashBackfiller(store.ProductImages(), imagehash.NewHasher(imageFetcher{}), zap.NewNop())
+	if err := backfiller.HandleBackfillImageHashes(ctx, asynq.NewTask(TypeBackfillImageHashes, nil)); err != nil {
+		t.Fatalf("HandleBackfillImageHashes() error = %v", err)
+	}
+
+	if _, ok, _ := store.ProductImages().FindHashByURL(ctx, *imageURL("/a.png")); !ok {
+		t.Error("image of the unhashed product was not hashed")
+	}
+	if hashes, _, _ := store.ProductImages().FindHashByURL(ctx, *imageURL("/c.png")); hashes != (imagehash.Hashes{PHash: 1, DHash: 1}) {
+		t.Errorf("hashes of the already hashed product = %+v, want them unchanged", hashes)
+	}
+	unhashed, err := store.ProductImages().ListUnhashed(ctx, uuid.Nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unhashed) != 1 || unhashed[0].Title != "Broken image" {
+		t.Errorf("ListUnhashed() = %v, want only the broken image left", unhashed)
+	}
+}
@@ -0,0 +1,267 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// InlineQueueName is the queue reported in the TaskInfo of inline tasks
+const InlineQueueName = "inline"
+
+// ErrQueueFull is returned by InlineQueue.EnqueueContext when the backlog is full
+var ErrQueueFull = errors.New("inline queue is full")
+
+// ErrUnsupportedOption is returned by InlineQueue.EnqueueContext for options it cannot
+// honor, such as delayed processing
+var ErrUnsupportedOption = errors.New("option is not supported by the inline queue")
+
+// Enqueuer is implemented by *asynq.Client and by InlineQueue (QUEUE_MODE=inline)
+type Enqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// InlineQueue processes tasks in a pool of goroutines in this process, for single-node
+// deployments and tests without Redis. Tasks are neither persisted nor retried: the
+// backlog is lost on restart, and a failed task is logged and passed to the error
+// handler once. Of the asynq.Option values, Unique, TaskID, Timeout and Deadline are
+// honored, ProcessAt, ProcessIn and Group are rejected with ErrUnsupportedOption, and
+// MaxRetry, Queue and Retention are ignored.
+type InlineQueue struct {
+	handler      asynq.Handler
+	errorHandler asynq.ErrorHandler // optional
+	workers      int
+	tasks        chan inlineTask
+	logger       *zap.Logger
+
+	// For QueueStats: enqueue times of the backlog, oldest first, and running tasks
+	mu           sync.Mutex
+	pendingSince []time.Time
+	active       atomic.Int64
+
+	// Guarded by mu: IDs of the pending and running tasks, and the expiry of each
+	// uniqueness lock (see asynq.Unique)
+	taskIDs map[string]bool
+	unique  map[string]time.Time
+}
+
+// inlineTaskIDKey holds the ID of the inline task being processed, see TaskID
+type inlineTaskIDKey struct{}
+
+type inlineTask struct {
+	id        string
+	task      *asynq.Task
+	uniqueKey string // held until the task succeeds or the lock expires, "" without Unique
+	timeout   time.Duration
+	deadline  time.Time
+}
+
+// NewInlineQueue creates a queue running handler (usually an *asynq.ServeMux) on
+// workers goroutines, accepting up to backlog tasks that wait for a worker
+func NewInlineQueue(handler asynq.Handler, workers, backlog int, errorHandler asynq.ErrorHandler, logger *zap.Logger) *InlineQueue {
+	return &InlineQueue{
+		handler:      handler,
+		errorHandler: errorHandler,
+		workers:      workers,
+		tasks:        make(chan inlineTask, backlog),
+		logger:       logger,
+		taskIDs:      make(map[string]bool),
+		unique:       make(map[string]time.Time),
+	}
+}
+
+// EnqueueContext implements Enqueuer. It never blocks: when the backlog is full it
+// returns ErrQueueFull. Like asynq.Client it returns asynq.ErrDuplicateTask and
+// asynq.ErrTaskIDConflict for the Unique and TaskID options.
+func (q *InlineQueue) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	t := inlineTask{id: uuid.NewString(), task: task}
+	var uniqueTTL time.Duration
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.TaskIDOpt:
+			t.id = opt.Value().(string)
+		case asynq.UniqueOpt:
+			uniqueTTL = opt.Value().(time.Duration)
+		case asynq.TimeoutOpt:
+			t.timeout = opt.Value().(time.Duration)
+		case asynq.DeadlineOpt:
+			t.deadline = opt.Value().(time.Time)
+		case asynq.ProcessAtOpt, asynq.ProcessInOpt, asynq.GroupOpt:
+			return nil, fmt.Errorf("%s: %w", opt, ErrUnsupportedOption)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	if q.taskIDs[t.id] {
+		return nil, asynq.ErrTaskIDConflict
+	}
+	if uniqueTTL > 0 {
+		for key, expiry := range q.unique {
+			if !now.Before(expiry) {
+				delete(q.unique, key)
+			}
+		}
+		t.uniqueKey = task.Type() + "\x00" + string(task.Payload())
+		if _, ok := q.unique[t.uniqueKey]; ok {
+			return nil, asynq.ErrDuplicateTask
+		}
+	}
+	select {
+	case q.tasks <- t:
+		q.pendingSince = append(q.pendingSince, now)
+	default:
+		return nil, ErrQueueFull
+	}
+	q.taskIDs[t.id] = true
+	if t.uniqueKey != "" {
+		q.unique[t.uniqueKey] = now.Add(uniqueTTL)
+	}
+	return &asynq.TaskInfo{
+		ID:      t.id,
+		Queue:   InlineQueueName,
+		Type:    task.Type(),
+		Payload: task.Payload(),
+		State:   asynq.TaskStatePending,
+	}, nil
+}
+
+// Run processes tasks until ctx is canceled, then waits for the running tasks (which
+// see ctx canceled) to return. Tasks still in the backlog are dropped.
+func (q *InlineQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case t := <-q.tasks:
+					q.process(ctx, t)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *InlineQueue) process(ctx context.Context, t inlineTask) {
+	q.mu.Lock()
+	if len(q.pendingSince) > 0 {
+		q.pendingSince = q.pendingSince[1:]
+	}
+	q.mu.Unlock()
+	q.active.Add(1)
+	defer q.active.Add(-1)
+
+	taskCtx := context.WithValue(ctx, inlineTaskIDKey{}, t.id)
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		taskCtx, cancel = context.WithTimeout(taskCtx, t.timeout)
+		defer cancel()
+	}
+	if !t.deadline.IsZero() {
+		var cancel context.CancelFunc
+		taskCtx, cancel = context.WithDeadline(taskCtx, t.deadline)
+		defer cancel()
+	}
+	err := q.processTask(taskCtx, t.task)
+
+	q.mu.Lock()
+	delete(q.taskIDs, t.id)
+	// Like asynq, a failed task keeps its uniqueness lock until the TTL expires
+	if err == nil && t.uniqueKey != "" {
+		delete(q.unique, t.uniqueKey)
+	}
+	q.mu.Unlock()
+	if err == nil {
+		return
+	}
+	q.logger.Error("Inline task failed",
+		zap.String("type", t.task.Type()),
+		zap.String("task_id", t.id),
+		zap.Error(err),
+	)
+	if q.errorHandler != nil {
+		q.errorHandler.HandleError(ctx, t.task, err)
+	}
+}
+
+// QueueStats implements QueueInspector for the single inline queue
+func (q *InlineQueue) QueueStats(ctx context.Context) ([]QueueStats, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := QueueStats{
+		Queue:   InlineQueueName,
+		Pending: len(q.pendingSince),
+		Active:  int(q.active.Load()),
+	}
+	if len(q.pendingSince) > 0 {
+		stats.OldestPendingSeconds = time.Since(q.pendingSince[0]).Seconds()
+	}
+	return []QueueStats{stats}, nil
+}
+
+// processTask recovers panics like the asynq server does
+func (q *InlineQueue) processTask(ctx context.Context, task *asynq.Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return q.handler.ProcessTask(ctx, task)
+}
+
+// InlineScheduler enqueues periodic tasks on cron schedules, like asynq.Scheduler but
+// without Redis. With several processes each one runs every schedule.
+type InlineScheduler struct {
+	cron   *cron.Cron
+	queue  Enqueuer
+	logger *zap.Logger
+}
+
+func NewInlineScheduler(queue Enqueuer, logger *zap.Logger) *InlineScheduler {
+	return &InlineScheduler{cron: cron.New(), queue: queue, logger: logger}
+}
+
+// Register schedules task on cronspec (standard 5-field cron or a descriptor such as
+// "@every 1h") and returns the entry ID
+func (s *InlineScheduler) Register(cronspec string, task *asynq.Task, opts ...asynq.Option) (string, error) {
+	id, err := s.cron.AddFunc(cronspec, func() {
+		if _, err := s.queue.EnqueueContext(context.Background(), task, opts...); err != nil {
+			s.logger.Error("Failed to enqueue scheduled task", zap.String("type", task.Type()), zap.Error(err))
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(int(id)), nil
+}
+
+// Unregister removes the schedule with the entry ID returned by Register
+func (s *InlineScheduler) Unregister(entryID string) error {
+	id, err := strconv.Atoi(entryID)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID %q", entryID)
+	}
+	s.cron.Remove(cron.EntryID(id))
+	return nil
+}
+
+// Run runs the schedules; it blocks and never returns an error
+func (s *InlineScheduler) Run() error {
+	s.cron.Run()
+	return nil
+}
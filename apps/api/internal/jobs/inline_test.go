@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+type recordingErrorHandler struct {
+	mu     sync.Mutex
+	failed []string
+}
+
+func (r *recordingErrorHandler) HandleError(ctx context.Context, task *asynq.Task, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, task.Type()+": "+err.Error())
+}
+
+func TestInlineQueue(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed []string
+		done      = make(chan struct{}, 3)
+	)
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(TypeFetchPrices, func(ctx context.Context, task *asynq.Task) error {
+		mu.Lock()
+		processed = append(processed, string(task.Payload()))
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+	mux.HandleFunc(TypeDetectDuplicates, func(ctx context.Context, task *asynq.Task) error {
+		defer func() { done <- struct{}{} }()
+		return errors.New("boom")
+	})
+	mux.HandleFunc(TypeReindexSearch, func(ctx context.Context, task *asynq.Task) error {
+		defer func() { done <- struct{}{} }()
+		panic("index gone")
+	})
+	errorHandler := &recordingErrorHandler{}
+	queue := NewInlineQueue(mux, 2, 3, errorHandler, zap.NewNop())
+
+	info, err := queue.EnqueueContext(context.Background(), asynq.NewTask(TypeFetchPrices, []byte("demo")))
+	if err != nil {
+		t.Fatalf("EnqueueContext() error = %v", err)
+	}
+	if info.ID == "" || info.Queue != InlineQueueName || info.Type != TypeFetchPrices {
+		t.Errorf("unexpected task info %+v", info)
+	}
+	for _, taskType := range []string{TypeDetectDuplicates, TypeReindexSearch} {
+		if _, err := queue.EnqueueContext(context.Background(), asynq.NewTask(taskType, nil)); err != nil {
+			t.Fatalf("EnqueueContext(%s) error = %v", taskType, err)
+		}
+	}
+	if _, err := queue.EnqueueContext(context.Background(), asynq.NewTask(TypeFetchPrices, nil)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("EnqueueContext() on a full backlog error = %v, want ErrQueueFull", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(stopped)
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("tasks were not processed")
+		}
+	}
+	cancel()
+	<-stopped
+
+	if len(processed) != 1 || processed[0] != "demo" {
+		t.Errorf("processed = %v, want [demo]", processed)
+	}
+	if len(errorHandler.failed) != 2 {
+		t.Errorf("error handler got %v, want the failed and the panicking task", errorHandler.failed)
+	}
+}
+
+func TestInlineSchedulerRejectsInvalidSpec(t *testing.T) {
+	scheduler := NewInlineScheduler(NewInlineQueue(asynq.NewServeMux(), 1, 1, nil, zap.NewNop()), zap.NewNop())
+	if _, err := scheduler.Register("not a cron spec", asynq.NewTask(TypeDetectDuplicates, nil)); err == nil {
+		t.Error("Register() accepted an invalid cron spec")
+	}
+	if _, err := scheduler.Register("0 3 * * *", asynq.NewTask(TypeDetectDuplicates, nil)); err != nil {
+		t.Errorf("Register() error = %v", err)
+	}
+}
+
+func TestInlineQueueStats(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(TypeFetchPrices, func(ctx context.Context, task *asynq.Task) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	queue := NewInlineQueue(mux, 1, 3, nil, zap.NewNop())
+	for i := 0; i < 2; i++ {
+		if _, err := queue.EnqueueContext(context.Background(), asynq.NewTask(TypeFetchPrices, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, _ := queue.QueueStats(context.Background())
+	if len(stats) != 1 || stats[0].Pending != 2 || stats[0].Active != 0 || stats[0].OldestPendingSeconds <= 0 {
+		t.Fatalf("QueueStats() before Run = %+v, want 2 pending", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+	<-started
+	stats, _ = queue.QueueStats(context.Background())
+	if stats[0].Pending != 1 || stats[0].Active != 1 {
+		t.Errorf("QueueStats() while processing = %+v, want 1 pending and 1 active", stats)
+	}
+	close(release)
+}
+
+func TestInlineQueueOptions(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan error, 3)
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(TypeEvaluateAlerts, func(ctx context.Context, task *asynq.Task) error {
+		<-release
+		done <- nil
+		return nil
+	})
+	mux.HandleFunc(TypeReindexSearch, func(ctx context.Context, task *asynq.Task) error {
+		<-ctx.Done()
+		done <- ctx.Err()
+		return ctx.Err()
+	})
+	queue := NewInlineQueue(mux, 2, 5, nil, zap.NewNop())
+	ctx := context.Background()
+
+	// A unique task is not enqueued again until it succeeds
+	unique := asynq.NewTask(TypeEvaluateAlerts, []byte("{}"))
+	if _, err := queue.EnqueueContext(ctx, unique, asynq.Unique(time.Minute)); err != nil {
+		t.Fatalf("EnqueueContext() error = %v", err)
+	}
+	if _, err := queue.EnqueueContext(ctx, unique, asynq.Unique(time.Minute)); !errors.Is(err, asynq.ErrDuplicateTask) {
+		t.Errorf("EnqueueContext() of a duplicate error = %v, want asynq.ErrDuplicateTask", err)
+	}
+
+	// Task IDs are used as given and may not be reused while the task is queued
+	info, err := queue.EnqueueContext(ctx, asynq.NewTask(TypeReindexSearch, nil), asynq.TaskID("reindex"), asynq.Timeout(10*time.Millisecond))
+	if err != nil || info.ID != "reindex" {
+		t.Fatalf("EnqueueContext() with a task ID = %+v, %v, want ID reindex", info, err)
+	}
+	if _, err := queue.EnqueueContext(ctx, asynq.NewTask(TypeReindexSearch, nil), asynq.TaskID("reindex")); !errors.Is(err, asynq.ErrTaskIDConflict) {
+		t.Errorf("EnqueueContext() with a queued task ID error = %v, want asynq.ErrTaskIDConflict", err)
+	}
+
+	for _, opt := range []asynq.Option{asynq.ProcessIn(time.Minute), asynq.ProcessAt(time.Now()), asynq.Group("alerts")} {
+		if _, err := queue.EnqueueContext(ctx, asynq.NewTask(TypeDetectDuplicates, nil), opt); !errors.Is(err, ErrUnsupportedOption) {
+			t.Errorf("EnqueueContext(%s) error = %v, want ErrUnsupportedOption", opt, err)
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go queue.Run(runCtx)
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("task error = %v, want nil or the timeout", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("tasks were not processed, or the timeout was not applied")
+		}
+	}
+
+	// Once processed, the unique task and the task ID may be enqueued again
+	waitFor := func(enqueue func() error) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for err := enqueue(); err != nil; err = enqueue() {
+			if time.Now().After(deadline) {
+				t.Fatalf("EnqueueContext() after processing error = %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(func() error {
+		_, err := queue.EnqueueContext(ctx, unique, asynq.Unique(time.Minute))
+		return err
+	})
+	waitFor(func() error {
+		_, err := queue.EnqueueContext(ctx, asynq.NewTask(TypeEvaluateAlerts, nil), asynq.TaskID("reindex"))
+		return err
+	})
+}
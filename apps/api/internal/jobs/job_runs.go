@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// jobRunRetention is how long job runs are kept for the job status endpoint
+const jobRunRetention = 30 * 24 * time.Hour
+
+// EnableJobRuns records each fetch_prices run in job_runs under its task ID, with the
+// product each processed candidate was linked to (see models.JobRun)
+func (p *Processor) EnableJobRuns(jobRunRepo repository.JobRunStore) {
+	p.jobRunRepo = jobRunRepo
+}
+
+// TaskID returns the ID of the task being processed, from asynq or the InlineQueue
+func TaskID(ctx context.Context) (string, bool) {
+	if id, ok := asynq.GetTaskID(ctx); ok {
+		return id, true
+	}
+	id, ok := ctx.Value(inlineTaskIDKey{}).(string)
+	return id, ok
+}
+
+// startJobRun records the start of a run, or returns nil if job runs are not enabled or
+// the task has no ID. A failure to record is logged; the run goes on without a record.
+func (p *Processor) startJobRun(ctx context.Context, taskType, source string) *models.JobRun {
+	if p.jobRunRepo == nil {
+		return nil
+	}
+	id, ok := TaskID(ctx)
+	if !ok {
+		return nil
+	}
+	jobRun := &models.JobRun{ID: id, Type: taskType, Source: source}
+	if err := p.jobRunRepo.Start(ctx, jobRun); err != nil {
+		p.logger.Warn("Failed to record job run", zap.String("task_id", id), zap.Error(err))
+		return nil
+	}
+	return jobRun
+}
+
+// finishJobRun stores the products linked by run and prunes old runs. A run that did not
+// complete is recorded as failed. It is deferred, so the record is written without ctx's
+// cancellation.
+func (p *Processor) finishJobRun(ctx context.Context, jobRun *models.JobRun, run *crawlRun, completed bool) {
+	if jobRun == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	jobRun.Status = models.JobRunStatusFailed
+	if completed {
+		jobRun.Status = models.JobRunStatusCompleted
+	}
+	jobRun.Products = run.products
+	if err := p.jobRunRepo.Finish(ctx, jobRun); err != nil {
+		p.logger.Warn("Failed to record job run", zap.String("task_id", jobRun.ID), zap.Error(err))
+	}
+	if _, err := p.jobRunRepo.DeleteBefore(ctx, time.Now().Add(-jobRunRetention)); err != nil {
+		p.logger.Warn("Failed to prune job runs", zap.Error(err))
+	}
+}
+
+// linkProduct records that candidate was created or matched as product, see EnableJobRuns.
+// A product is recorded once per source: several queries of a run often return the same
+// listing, which is matched to the product its first result created.
+func (r *crawlRun) linkProduct(candidate providers.ProductCandidate, sourceName string, product *models.Product, matchMethod string) {
+	for _, linked := range r.products {
+		if linked.Source == sourceName && linked.ProductID == product.ID {
+			return
+		}
+	}
+	action := models.JobRunProductUpdated
+	if matchMethod == models.MatchMethodNew {
+		action = models.JobRunProductCreated
+	}
+	r.products = append(r.products, models.JobRunProduct{
+		Source:      sourceName,
+		Identifier:  candidate.Identifier,
+		Title:       candidate.Title,
+		ProductID:   product.ID,
+		Action:      action,
+		MatchMethod: matchMethod,
+	})
+}
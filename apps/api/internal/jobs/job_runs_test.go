@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestHandleFetchPricesRecordsJobRun(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	processor := newTestProcessor(t, store, demoProviders(&stockProvider{inStock: true}))
+	processor.EnableJobRuns(store.JobRuns())
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+
+	// The first run creates the product, the second matches the candidate to it
+	for i, taskID := range []string{"task-1", "task-2"} {
+		taskCtx := context.WithValue(ctx, inlineTaskIDKey{}, taskID)
+		if err := processor.HandleFetchPrices(taskCtx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+			t.Fatalf("HandleFetchPrices() error = %v", err)
+		}
+
+		jobRun, err := store.JobRuns().GetByID(ctx, taskID)
+		if err != nil || jobRun == nil {
+			t.Fatalf("GetByID(%q) = %v, %v, want the run", taskID, jobRun, err)
+		}
+		if jobRun.Status != models.JobRunStatusCompleted || jobRun.FinishedAt == nil || jobRun.Source != "demo" {
+			t.Errorf("run %q = %+v, want a completed demo run", taskID, jobRun)
+		}
+		if len(jobRun.Products) != 1 {
+			t.Fatalf("run %q linked %d products, want 1", taskID, len(jobRun.Products))
+		}
+		wantAction := models.JobRunProductCreated
+		if i > 0 {
+			wantAction = models.JobRunProductUpdated
+		}
+		products, _, _ := store.Products().Search(ctx, "Nintendo", "", 10, 0)
+		if linked := jobRun.Products[0]; linked.Action != wantAction || len(products) != 1 || linked.ProductID != products[0].ID {
+			t.Errorf("run %q linked %+v, want %s of %v", taskID, linked, wantAction, products)
+		}
+	}
+
+	// Without a task ID there is nothing to record the run under
+	if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+		t.Fatalf("HandleFetchPrices() error = %v", err)
+	}
+}
+
+// panicProvider panics on every search
+type panicProvider struct{ stockProvider }
+
+func (p *panicProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	panic("provider bug")
+}
+
+func TestHandleFetchPricesRecordsFailedJobRun(t *testing.T) {
+	ctx := context.Background()
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+	tests := []struct {
+		name     string
+		provider providers.Provider
+		cancel   bool
+	}{
+		{"panic", &panicProvider{}, false},
+		{"cancelled", &stockProvider{inStock: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			processor := newTestProcessor(t, store, demoProviders(tt.provider))
+			processor.EnableJobRuns(store.JobRuns())
+			taskCtx, cancel := context.WithCancel(context.WithValue(ctx, inlineTaskIDKey{}, "task-1"))
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			func() {
+				// asynq recovers the panic of a handler
+				defer func() { _ = recover() }()
+				processor.HandleFetchPrices(taskCtx, asynq.NewTask(TypeFetchPrices, data))
+			}()
+
+			jobRun, err := store.JobRuns().GetByID(ctx, "task-1")
+			if err != nil || jobRun == nil {
+				t.Fatalf("GetByID() = %v, %v, want the run", jobRun, err)
+			}
+			if jobRun.Status != models.JobRunStatusFailed || jobRun.FinishedAt == nil {
+				t.Errorf("run = %+v, want a finished failed run", jobRun)
+			}
+		})
+	}
+}
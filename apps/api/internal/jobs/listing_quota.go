@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/providers"
+)
+
+// listingQuotaWindow is the period of the daily listing quota
+const listingQuotaWindow = 24 * time.Hour
+
+// listingCounts are the listings of a source, counted once per run and then kept up to
+// date as the run adds listings. Concurrent runs of one source can overshoot a quota by
+// the listings the other run adds.
+type listingCounts struct {
+	total int
+	today int // in the last listingQuotaWindow
+}
+
+// checkListingQuota returns the reason a candidate must not be ingested because it would
+// add a listing beyond its source's quotas (INGEST_MAX_LISTINGS_PER_SOURCE,
+// INGEST_DAILY_LISTING_QUOTA), or "". Candidates of listings already in the catalog
+// always pass, so their offers stay fresh. If the listings cannot be counted the
+// candidate passes, as the quotas are a safeguard.
+func (p *Processor) checkListingQuota(ctx context.Context, run *crawlRun, candidate providers.ProductCandidate, sourceName string) string {
+	if !p.ingestRules.HasQuota(sourceName) {
+		return ""
+	}
+	if sourceID, _ := listingKey(candidate); sourceID != "" {
+		existing, err := p.sourceProductRepo.FindByProviderAndSourceID(ctx, sourceName, sourceID)
+		if err != nil {
+			p.logger.Warn("Failed to look up listing for its quota", zap.String("provider", sourceName), zap.Error(err))
+			return ""
+		}
+		if existing != nil {
+			return ""
+		}
+	}
+
+	counts, ok := run.listings[sourceName]
+	if !ok {
+		total, err := p.sourceProductRepo.CountByProvider(ctx, sourceName, time.Time{})
+		if err != nil {
+			p.logger.Warn("Failed to count listings for their quota", zap.String("provider", sourceName), zap.Error(err))
+			return ""
+		}
+		today, err := p.sourceProductRepo.CountByProvider(ctx, sourceName, time.Now().Add(-listingQuotaWindow))
+		if err != nil {
+			p.logger.Warn("Failed to count listings for their quota", zap.String("provider", sourceName), zap.Error(err))
+			return ""
+		}
+		counts = &listingCounts{total: total, today: today}
+		if run.listings == nil {
+			run.listings = make(map[string]*listingCounts)
+		}
+		run.listings[sourceName] = counts
+	}
+	if reason := p.ingestRules.CheckQuota(sourceName, counts.total, counts.today); reason != "" {
+		return reason
+	}
+	counts.total++
+	counts.today++
+	return ""
+}
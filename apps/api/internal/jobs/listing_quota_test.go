@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/ingest"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// listingProvider is a countingProvider whose candidates have listing IDs, so they are
+// saved as source_products
+type listingProvider struct {
+	countingProvider
+}
+
+func (p *listingProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	candidates, err := p.countingProvider.Search(ctx, query)
+	for i := range candidates {
+		id := fmt.Sprintf("%s-%d", query, i)
+		candidates[i].Identifier = &id
+	}
+	return candidates, err
+}
+
+func TestHandleFetchPricesListingQuotas(t *testing.T) {
+	tests := []struct {
+		name          string
+		rules         ingest.Rules
+		oldListings   int // listings of the source created two days ago
+		wantFirstRun  int // offer fetches of the first run
+		wantSecondRun int
+	}{
+		{"no quota", ingest.Rules{}, 0, 9, 9},
+		{"daily quota", ingest.Rules{DailyListingQuota: map[string]int{"demo": 4}}, 0, 4, 4},
+		{"daily quota ignores older listings", ingest.Rules{DailyListingQuota: map[string]int{"demo": 4}}, 3, 4, 4},
+		{"source cap", ingest.Rules{MaxListingsPerSource: map[string]int{"demo": 5}, DailyListingQuota: map[string]int{"demo": 4}}, 3, 2, 2},
+		{"other sources' quotas", ingest.Rules{MaxListingsPerSource: map[string]int{"live": 1}}, 0, 9, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New()
+			for i := 0; i < tt.oldListings; i++ {
+				product := &models.Product{Title: fmt.Sprintf("Old gadget %d", i)}
+				if err := store.Products().Create(ctx, product); err != nil {
+					t.Fatal(err)
+				}
+				if err := store.SourceProducts().Upsert(ctx, &models.SourceProduct{
+					ProductID: product.ID,
+					Provider:  "demo",
+					SourceID:  fmt.Sprintf("old-%d", i),
+					CreatedAt: time.Now().Add(-48 * time.Hour),
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			provider := &listingProvider{}
+			processor := newTestProcessor(t, store, demoProviders(provider))
+			processor.EnableIngestionRules(tt.rules)
+
+			data, err := json.Marshal(FetchPricesPayload{Source: "demo"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+				t.Fatalf("HandleFetchPrices() error = %v", err)
+			}
+			if provider.offerFetches != tt.wantFirstRun {
+				t.Errorf("first run fetched offers of %d candidates, want %d", provider.offerFetches, tt.wantFirstRun)
+			}
+
+			// The listings of the first run are refreshed, but the quotas are used up
+			provider.offerFetches = 0
+			if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+				t.Fatalf("HandleFetchPrices() error = %v", err)
+			}
+			if provider.offerFetches != tt.wantSecondRun {
+				t.Errorf("second run fetched offers of %d candidates, want %d", provider.offerFetches, tt.wantSecondRun)
+			}
+
+			summaries, err := store.Products().ListSummariesAfter(ctx, uuid.Nil, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(summaries) != tt.oldListings+tt.wantFirstRun {
+				t.Errorf("%d products, want %d", len(summaries), tt.oldListings+tt.wantFirstRun)
+			}
+		})
+	}
+}
+
+func TestListingKey(t *testing.T) {
+	productID := "4887235756540435899"
+	pageURL := "https://www.bestbuy.com/site/6505727.p?utm_source=google"
+	tests := []struct {
+		name      string
+		candidate providers.ProductCandidate
+		wantID    string
+	}{
+		{"provider ID", providers.ProductCandidate{Identifier: &productID, SourceURL: &pageURL}, productID},
+		{"merchant of a shared ID", providers.ProductCandidate{Identifier: &productID, Seller: "Best Buy", SourceURL: &pageURL}, productID + ":Best Buy"},
+		{"URL without an ID", providers.ProductCandidate{Seller: "Best Buy", SourceURL: &pageURL}, "https://www.bestbuy.com/site/6505727.p"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sourceID, _ := listingKey(tt.candidate); sourceID != tt.wantID {
+				t.Errorf("listingKey() = %q, want %q", sourceID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// ManualOfferSource is the source of offers entered through the admin API
+const ManualOfferSource = "manual"
+
+const (
+	// expiredOfferGrace is how long expired manual offers are kept, so they can still
+	// be renewed through PATCH /api/admin/offers/:id
+	expiredOfferGrace = 7 * 24 * time.Hour
+	// orphanedSourceProductAge is how long a listing without offers is kept, in case its
+	// provider lists it again
+	orphanedSourceProductAge = 30 * 24 * time.Hour
+	// analyzeMinChanges and analyzeChangedFraction: tables with more changed rows since
+	// their last ANALYZE are analyzed
+	analyzeMinChanges      = 1000
+	analyzeChangedFraction = 0.1
+	// vacuumMinDeadRows and vacuumDeadFraction: tables with more dead rows are reported,
+	// as autovacuum is not keeping up with them
+	vacuumMinDeadRows  = 1000
+	vacuumDeadFraction = 0.2
+)
+
+// MaintenanceReport is the outcome of one db_maintenance run
+type MaintenanceReport struct {
+	ExpiredOffersDeleted          int64
+	OrphanedSourceProductsDeleted int64
+	AuditEventsDeleted            int64
+	OfferMergesDeleted            int64
+	AnalyzedTables                []string
+	VacuumHints                   []string // tables with many dead rows
+}
+
+// Maintainer keeps the database healthy without hand-run SQL: it deletes expired manual
+// offers, orphaned source products, and audit events and offer merges past their
+// retention, analyzes tables with many changes and reports tables autovacuum is behind on
+type Maintainer struct {
+	offerRepo         repository.OfferStore
+	sourceProductRepo repository.SourceProductStore
+	maintenanceRepo   repository.MaintenanceStore // nil without Postgres
+	auditRetention    time.Duration               // 0 keeps audit events
+	mergeLogRetention time.Duration               // 0 keeps offer merges
+	responseCache     ResponseCacheInvalidator    // see EnableResponseCacheInvalidation
+	logger            *zap.Logger
+}
+
+func NewMaintainer(
+	offerRepo repository.OfferStore,
+	sourceProductRepo repository.SourceProductStore,
+	maintenanceRepo repository.MaintenanceStore,
+	auditRetention time.Duration,
+	mergeLogRetention time.Duration,
+	logger *zap.Logger,
+) *Maintainer {
+	return &Maintainer{
+		offerRepo:         offerRepo,
+		sourceProductRepo: sourceProductRepo,
+		maintenanceRepo:   maintenanceRepo,
+		auditRetention:    auditRetention,
+		mergeLogRetention: mergeLogRetention,
+		logger:            logger,
+	}
+}
+
+func (m *Maintainer) HandleMaintenance(ctx context.Context, t *asynq.Task) error {
+	m.logger.Info("Processing db_maintenance job")
+
+	report, err := m.Run(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, hint := range report.VacuumHints {
+		m.logger.Warn("Table needs VACUUM", zap.String("hint", hint))
+	}
+	m.logger.Info("Database maintenance finished",
+		zap.Int64("expired_offers_deleted", report.ExpiredOffersDeleted),
+		zap.Int64("orphaned_source_products_deleted", report.OrphanedSourceProductsDeleted),
+		zap.Int64("audit_events_deleted", report.AuditEventsDeleted),
+		zap.Int64("offer_merges_deleted", report.OfferMergesDeleted),
+		zap.Strings("analyzed_tables", report.AnalyzedTables),
+	)
+	return nil
+}
+
+// EnableResponseCacheInvalidation invalidates the cached responses of the products whose
+// expired offers are deleted
+func (m *Maintainer) EnableResponseCacheInvalidation(cache ResponseCacheInvalidator) {
+	m.responseCache = cache
+}
+
+// Run performs the maintenance as of now
+func (m *Maintainer) Run(ctx context.Context, now time.Time) (*MaintenanceReport, error) {
+	report := &MaintenanceReport{}
+
+	expired, err := m.offerRepo.DeleteExpired(ctx, ManualOfferSource, now.Add(-expiredOfferGrace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired offers: %w", err)
+	}
+	report.ExpiredOffersDeleted = int64(len(expired))
+	m.invalidateResponses(ctx, expired)
+	if report.OrphanedSourceProductsDeleted, err = m.sourceProductRepo.DeleteOrphaned(ctx, now.Add(-orphanedSourceProductAge)); err != nil {
+		return nil, fmt.Errorf("failed to delete orphaned source products: %w", err)
+	}
+	if m.maintenanceRepo == nil {
+		return report, nil
+	}
+
+	if m.auditRetention > 0 {
+		if report.AuditEventsDeleted, err = m.maintenanceRepo.DeleteAuditEventsBefore(ctx, now.Add(-m.auditRetention)); err != nil {
+			return nil, fmt.Errorf("failed to prune audit events: %w", err)
+		}
+	}
+	if m.mergeLogRetention > 0 {
+		if report.OfferMergesDeleted, err = m.maintenanceRepo.DeleteOfferMergesBefore(ctx, now.Add(-m.mergeLogRetention)); err != nil {
+			return nil, fmt.Errorf("failed to prune offer merges: %w", err)
+		}
+	}
+
+	// Statistics are read after the deletions, so they count towards the thresholds
+	stats, err := m.maintenanceRepo.TableStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table statistics: %w", err)
+	}
+	for _, table := range stats {
+		if needsAnalyze(table) {
+			if err := m.maintenanceRepo.Analyze(ctx, table.Table); err != nil {
+				return nil, fmt.Errorf("failed to analyze %s: %w", table.Table, err)
+			}
+			report.AnalyzedTables = append(report.AnalyzedTables, table.Table)
+		}
+		if needsVacuum(table) {
+			report.VacuumHints = append(report.VacuumHints, fmt.Sprintf("%s: %d dead of %d live rows", table.Table, table.DeadRows, table.LiveRows))
+		}
+	}
+	return report, nil
+}
+
+// invalidateResponses retires the cached responses of products; they expire on their own
+// if this fails
+func (m *Maintainer) invalidateResponses(ctx context.Context, productIDs []uuid.UUID) {
+	if m.responseCache == nil || len(productIDs) == 0 {
+		return
+	}
+	seen := make(map[uuid.UUID]bool)
+	for _, productID := range productIDs {
+		if seen[productID] {
+			continue
+		}
+		seen[productID] = true
+		if err := m.responseCache.InvalidateProduct(ctx, productID); err != nil {
+			m.logger.Warn("Failed to invalidate cached responses", zap.String("product_id", productID.String()), zap.Error(err))
+		}
+	}
+	if err := m.responseCache.InvalidateSearch(ctx); err != nil {
+		m.logger.Warn("Failed to invalidate cached search responses", zap.Error(err))
+	}
+}
+
+func needsAnalyze(table *models.TableStats) bool {
+	return table.ModifiedSinceAnalyze >= analyzeMinChanges &&
+		float64(table.ModifiedSinceAnalyze) >= analyzeChangedFraction*float64(table.LiveRows)
+}
+
+func needsVacuum(table *models.TableStats) bool {
+	return table.DeadRows >= vacuumMinDeadRows &&
+		float64(table.DeadRows) >= vacuumDeadFraction*float64(table.LiveRows)
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+type fakeMaintenanceStore struct {
+	stats       []*models.TableStats
+	auditBefore time.Time
+	mergeBefore time.Time
+	analyzed    []string
+}
+
+func (s *fakeMaintenanceStore) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.auditBefore = before
+	return 42, nil
+}
+
+func (s *fakeMaintenanceStore) DeleteOfferMergesBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mergeBefore = before
+	return 7, nil
+}
+
+func (s *fakeMaintenanceStore) TableStats(ctx context.Context) ([]*models.TableStats, error) {
+	return s.stats, nil
+}
+
+func (s *fakeMaintenanceStore) Analyze(ctx context.Context, table string) error {
+	s.analyzed = append(s.analyzed, table)
+	return nil
+}
+
+func TestMaintenance(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	longExpired := now.Add(-10 * 24 * time.Hour)
+	justExpired := now.Add(-24 * time.Hour)
+	offers := []*models.Offer{
+		{ProductID: product.ID, Source: ManualOfferSource, Seller: "Shop A", PriceAmount: 100, ExpiresAt: &longExpired},
+		{ProductID: product.ID, Source: ManualOfferSource, Seller: "Shop B", PriceAmount: 100, ExpiresAt: &justExpired},
+		{ProductID: product.ID, Source: "walmart", Seller: "Walmart", PriceAmount: 100},
+	}
+	for _, offer := range offers {
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listings := []*models.SourceProduct{
+		{ProductID: product.ID, Provider: "walmart", SourceID: "1", URL: "https://www.walmart.com/ip/1"},
+		{ProductID: product.ID, Provider: "amazon", SourceID: "B0001", URL: "https://www.amazon.com/dp/B0001"},
+	}
+	for _, sp := range listings {
+		if err := store.SourceProducts().Upsert(ctx, sp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	maintenanceRepo := &fakeMaintenanceStore{stats: []*models.TableStats{
+		{Table: "offers", LiveRows: 10000, ModifiedSinceAnalyze: 2000},
+		{Table: "products", LiveRows: 100000, ModifiedSinceAnalyze: 5000},
+		{Table: "audit_events", LiveRows: 1000, DeadRows: 5000},
+	}}
+	maintainer := NewMaintainer(store.Offers(), store.SourceProducts(), maintenanceRepo, 90*24*time.Hour, 30*24*time.Hour, zap.NewNop())
+	recorder := &invalidationRecorder{}
+	maintainer.EnableResponseCacheInvalidation(recorder)
+
+	report, err := maintainer.Run(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ExpiredOffersDeleted != 1 {
+		t.Errorf("ExpiredOffersDeleted = %d, want 1 (offers within the grace period are kept)", report.ExpiredOffersDeleted)
+	}
+	if got, _ := store.Offers().GetByID(ctx, offers[0].ID); got != nil {
+		t.Error("offer expired before the grace period was kept")
+	}
+	if len(recorder.productIDs) != 1 || recorder.productIDs[0] != product.ID || recorder.searches != 1 {
+		t.Errorf("invalidated %v and search %d times, want the product of the deleted offer", recorder.productIDs, recorder.searches)
+	}
+	if report.OrphanedSourceProductsDeleted != 0 {
+		t.Errorf("OrphanedSourceProductsDeleted = %d, want 0 (listings updated recently are kept)", report.OrphanedSourceProductsDeleted)
+	}
+	if report.AuditEventsDeleted != 42 || !maintenanceRepo.auditBefore.Equal(now.Add(-90*24*time.Hour)) {
+		t.Errorf("audit events pruned before %v (%d deleted), want 90 days ago", maintenanceRepo.auditBefore, report.AuditEventsDeleted)
+	}
+	if report.OfferMergesDeleted != 7 || !maintenanceRepo.mergeBefore.Equal(now.Add(-30*24*time.Hour)) {
+		t.Errorf("offer merges pruned before %v (%d deleted), want 30 days ago", maintenanceRepo.mergeBefore, report.OfferMergesDeleted)
+	}
+	if !reflect.DeepEqual(maintenanceRepo.analyzed, []string{"offers"}) || !reflect.DeepEqual(report.AnalyzedTables, []string{"offers"}) {
+		t.Errorf("analyzed %v, want [offers]", maintenanceRepo.analyzed)
+	}
+	if len(report.VacuumHints) != 1 || report.VacuumHints[0] != "audit_events: 5000 dead of 1000 live rows" {
+		t.Errorf("VacuumHints = %v", report.VacuumHints)
+	}
+
+	// A month later the amazon listing, which has no offers, is orphaned
+	report, err = maintainer.Run(ctx, now.Add(31*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OrphanedSourceProductsDeleted != 1 {
+		t.Errorf("OrphanedSourceProductsDeleted = %d, want 1", report.OrphanedSourceProductsDeleted)
+	}
+	if got, _ := store.SourceProducts().GetByID(ctx, listings[0].ID); got == nil {
+		t.Error("listing with offers was deleted")
+	}
+	if got, _ := store.Offers().GetByID(ctx, offers[2].ID); got == nil {
+		t.Error("offer without expiry was deleted")
+	}
+}
+
+func TestMaintenanceWithoutPostgres(t *testing.T) {
+	store := memory.New()
+	maintainer := NewMaintainer(store.Offers(), store.SourceProducts(), nil, 90*24*time.Hour, 30*24*time.Hour, zap.NewNop())
+	report, err := maintainer.Run(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.AuditEventsDeleted != 0 || len(report.AnalyzedTables) != 0 {
+		t.Errorf("report = %+v, want only the store cleanups", report)
+	}
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+const (
+	// priceHistoryWindow is how far back replaced prices count towards a product's median
+	priceHistoryWindow = 90 * 24 * time.Hour
+	// minMedianSamples is the number of prices a median needs before offers are judged by it
+	minMedianSamples = 3
+)
+
+// offerAnomaly returns the quarantine reason of a priced offer, or "" if it looks
+// plausible. previous is the stored offer from the last fetch (nil for a new offer);
+// median is the product's price history median over samples prices. dropPercent
+// (OFFER_ANOMALY_DROP_PERCENT) is how far below the median a total may fall; 0 disables
+// that check.
+func offerAnomaly(offer, previous *models.Offer, median, samples int, dropPercent float64) string {
+	if offer.PriceAmount <= 0 {
+		return models.OfferQuarantinePriceUnknown
+	}
+	if previous != nil && previous.Currency != offer.Currency {
+		return models.OfferQuarantineCurrencyMismatch
+	}
+	if dropPercent > 0 && samples >= minMedianSamples && median > 0 &&
+		float64(offer.TotalToUSAmount)*100 < float64(median)*(100-dropPercent) {
+		return models.OfferQuarantinePriceBelowMedian
+	}
+	return ""
+}
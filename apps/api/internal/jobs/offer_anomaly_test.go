@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+func TestOfferAnomaly(t *testing.T) {
+	usd := &models.Offer{PriceAmount: 10000, Currency: "USD", TotalToUSAmount: 10000}
+	tests := []struct {
+		name     string
+		offer    *models.Offer
+		previous *models.Offer
+		median   int
+		samples  int
+		want     string
+	}{
+		{name: "plausible", offer: usd, previous: usd, median: 11000, samples: 5},
+		{name: "zero price", offer: &models.Offer{Currency: "USD", TotalToUSAmount: 599}, want: models.OfferQuarantinePriceUnknown},
+		{
+			name:     "currency changed since the previous fetch",
+			offer:    &models.Offer{PriceAmount: 10000, Currency: "JPY", TotalToUSAmount: 67},
+			previous: usd,
+			want:     models.OfferQuarantineCurrencyMismatch,
+		},
+		{
+			name:    "far below the median",
+			offer:   &models.Offer{PriceAmount: 499, Currency: "USD", TotalToUSAmount: 499},
+			median:  10000,
+			samples: 3,
+			want:    models.OfferQuarantinePriceBelowMedian,
+		},
+		{
+			name:    "exactly at the drop limit",
+			offer:   &models.Offer{PriceAmount: 500, Currency: "USD", TotalToUSAmount: 500},
+			median:  10000,
+			samples: 3,
+		},
+		{
+			name:    "too little history",
+			offer:   &models.Offer{PriceAmount: 100, Currency: "USD", TotalToUSAmount: 100},
+			median:  10000,
+			samples: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := offerAnomaly(tt.offer, tt.previous, tt.median, tt.samples, 95); got != tt.want {
+				t.Errorf("offerAnomaly() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	offer := &models.Offer{PriceAmount: 100, Currency: "USD", TotalToUSAmount: 100}
+	if got := offerAnomaly(offer, nil, 10000, 10, 0); got != "" {
+		t.Errorf("offerAnomaly() with the median check disabled = %q, want none", got)
+	}
+}
@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// sellerSuffixes are trailing words of a seller name that do not tell sellers apart
+var sellerSuffixes = map[string]bool{
+	"inc": true, "llc": true, "ltd": true, "co": true, "corp": true, "corporation": true, "gmbh": true,
+}
+
+// dedupeOffers collapses offers of one fetch that list the same thing: the same page
+// once tracking and affiliate parameters are removed, sold by the same seller however
+// its name is spelled. Of each group the in-stock offer with the lowest US total is
+// kept. It returns the kept offers in their original order, and the offers merged into
+// each of them. Offers must be priced and have canonical URLs.
+func dedupeOffers(offers []*models.Offer) (kept []*models.Offer, merged map[*models.Offer][]*models.Offer) {
+	groups := make(map[string][]*models.Offer)
+	var keys []string
+	for _, offer := range offers {
+		key := dedupeKey(offer)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], offer)
+	}
+
+	merged = make(map[*models.Offer][]*models.Offer)
+	keep := make(map[*models.Offer]bool)
+	for _, key := range keys {
+		group := groups[key]
+		best := group[0]
+		for _, offer := range group[1:] {
+			if preferOffer(offer, best) {
+				best = offer
+			}
+		}
+		keep[best] = true
+		for _, offer := range group {
+			if offer != best {
+				merged[best] = append(merged[best], offer)
+			}
+		}
+	}
+	for _, offer := range offers {
+		if keep[offer] {
+			kept = append(kept, offer)
+		}
+	}
+	return kept, merged
+}
+
+// dedupeKey identifies the listing an offer is for within a product and source
+func dedupeKey(offer *models.Offer) string {
+	url := ""
+	if offer.URL != nil {
+		url = canonicalurl.WithoutAffiliate(*offer.URL)
+	}
+	return normalizeSeller(offer.Seller) + "\x00" + url
+}
+
+// preferOffer reports whether a duplicate offer should be kept over the current choice
+func preferOffer(offer, current *models.Offer) bool {
+	if offer.InStock != current.InStock {
+		return offer.InStock
+	}
+	return offer.TotalToUSAmount < current.TotalToUSAmount
+}
+
+// normalizeSeller reduces a seller name to its letters and digits, lowercased and
+// without a company suffix: "Best Buy, Inc." and "BestBuy" are both "bestbuy"
+func normalizeSeller(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && sellerSuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, "")
+}
+
+// offerMergeReason tells why duplicate was merged into kept
+func offerMergeReason(kept, duplicate *models.Offer) string {
+	switch {
+	case kept.Seller != duplicate.Seller:
+		return models.OfferMergeReasonSellerName
+	case offerURL(kept) != offerURL(duplicate):
+		return models.OfferMergeReasonAffiliateTag
+	default:
+		return models.OfferMergeReasonSameURL
+	}
+}
+
+func offerURL(offer *models.Offer) string {
+	if offer.URL == nil {
+		return ""
+	}
+	return *offer.URL
+}
+
+// recordOfferMerges stores the decisions to merge duplicates into a saved offer in
+// offer_merge_log; merges logged by an earlier fetch are not stored again
+func (p *Processor) recordOfferMerges(ctx context.Context, kept *models.Offer, duplicates []*models.Offer) {
+	if p.offerMergeRepo == nil {
+		return
+	}
+	// Cheapest first, so the log reads in a stable order
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].TotalToUSAmount < duplicates[j].TotalToUSAmount
+	})
+	for _, duplicate := range duplicates {
+		merge := &models.OfferMerge{
+			ProductID:         kept.ProductID,
+			Source:            kept.Source,
+			KeptOfferID:       kept.ID,
+			KeptSeller:        kept.Seller,
+			KeptURL:           kept.URL,
+			MergedSeller:      duplicate.Seller,
+			MergedURL:         duplicate.URL,
+			MergedPriceAmount: duplicate.PriceAmount,
+			MergedCurrency:    duplicate.Currency,
+			Reason:            offerMergeReason(kept, duplicate),
+		}
+		if _, err := p.offerMergeRepo.Record(ctx, merge); err != nil {
+			p.logger.Warn("Failed to record offer merge",
+				zap.String("offer_id", kept.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
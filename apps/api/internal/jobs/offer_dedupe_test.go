@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// duplicateOfferProvider returns one product whose offers repeat the same listings
+type duplicateOfferProvider struct{}
+
+func (p *duplicateOfferProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	return []providers.ProductCandidate{{Title: "Sony WH-1000XM5 Wireless Headphones"}}, nil
+}
+
+func (p *duplicateOfferProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	offer := func(seller, url string, price int, inStock bool) *models.Offer {
+		return &models.Offer{ProductID: product.ID, Source: "demo", Seller: seller, PriceAmount: price, Currency: "USD", InStock: inStock, URL: &url}
+	}
+	return []*models.Offer{
+		offer("Best Buy", "https://www.bestbuy.com/site/6505727.p?utm_source=feed", 32999, true),
+		offer("Best Buy", "https://www.bestbuy.com/site/6505727.p", 32999, true),
+		offer("BestBuy Inc.", "https://www.bestbuy.com/site/6505727.p?irgwc=1", 31999, true),
+		offer("Amazon", "https://www.amazon.com/dp/B09XS7JWHH?tag=pc-20", 29999, false),
+		offer("Amazon", "https://www.amazon.com/dp/B09XS7JWHH?tag=other-21", 34999, true),
+		offer("Target", "https://www.target.com/p/-/A-86216166", 33999, true),
+	}, nil
+}
+
+func TestHandleFetchPricesDedupesOffers(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	processor := newTestProcessor(t, store, demoProviders(&duplicateOfferProvider{}))
+	processor.EnableOfferMergeLog(store.OfferMergeLog())
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+	if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+		t.Fatalf("HandleFetchPrices() error = %v", err)
+	}
+
+	products, _, err := store.Products().Search(ctx, "Sony", "", 10, 0)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("Search() = %v, %v, want one product", products, err)
+	}
+	offers, err := store.Offers().GetByProductID(ctx, products[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sellers := make(map[string]*models.Offer)
+	for _, offer := range offers {
+		sellers[offer.Seller] = offer
+	}
+	if len(offers) != 3 {
+		t.Fatalf("got %d offers (%v), want one per listing", len(offers), sellers)
+	}
+	// The cheapest spelling of Best Buy and the in-stock Amazon link are kept
+	if offer := sellers["BestBuy Inc."]; offer == nil || offer.PriceAmount != 31999 {
+		t.Errorf("Best Buy offer = %+v, want the cheapest duplicate", offer)
+	}
+	if offer := sellers["Amazon"]; offer == nil || !offer.InStock || *offer.URL != "https://www.amazon.com/dp/B09XS7JWHH?tag=other-21" {
+		t.Errorf("Amazon offer = %+v, want the in-stock duplicate", offer)
+	}
+	// The provider URL is stored as fetched, next to the product page link
+	if offer := sellers["Amazon"]; offer != nil && (offer.CanonicalURL == nil || *offer.CanonicalURL != "https://www.amazon.com/dp/B09XS7JWHH") {
+		t.Errorf("Amazon canonical URL = %v, want the product page", offer.CanonicalURL)
+	}
+
+	// Each default search query fetches the product again; the merges are only logged
+	// by the first fetch, and the two Best Buy links without utm_source are one merge
+	merges, err := store.OfferMergeLog().ListByProductID(ctx, products[0].ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merges) != 2 {
+		t.Errorf("logged %d merges, want 2", len(merges))
+	}
+	reasons := make(map[string]int)
+	for _, merge := range merges {
+		reasons[merge.Reason]++
+		if merge.KeptOfferID == sellers[merge.KeptSeller].ID {
+			continue
+		}
+		t.Errorf("merge %+v does not point at the kept offer", merge)
+	}
+	if reasons[models.OfferMergeReasonSellerName] != 1 || reasons[models.OfferMergeReasonAffiliateTag] != 1 {
+		t.Errorf("merge reasons = %v, want 1 seller_name and 1 affiliate_tag", reasons)
+	}
+}
+
+func TestDedupeOffersSameURL(t *testing.T) {
+	url := "https://example.com/item"
+	a := &models.Offer{Seller: "Shop", URL: &url, InStock: true, TotalToUSAmount: 1000}
+	b := &models.Offer{Seller: "Shop", URL: &url, InStock: true, TotalToUSAmount: 900}
+	kept, merged := dedupeOffers([]*models.Offer{a, b})
+	if len(kept) != 1 || kept[0] != b || len(merged[b]) != 1 {
+		t.Fatalf("dedupeOffers() = %v, %v, want b with a merged", kept, merged)
+	}
+	if reason := offerMergeReason(b, a); reason != models.OfferMergeReasonSameURL {
+		t.Errorf("offerMergeReason() = %q, want same_url", reason)
+	}
+}
+
+func TestNormalizeSeller(t *testing.T) {
+	tests := map[string]string{
+		"Best Buy":       "bestbuy",
+		"BestBuy, Inc.":  "bestbuy",
+		"  best-buy  ":   "bestbuy",
+		"Co":             "co",
+		"Sony Store LLC": "sonystore",
+	}
+	for name, want := range tests {
+		if got := normalizeSeller(name); got != want {
+			t.Errorf("normalizeSeller(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// sellersProvider returns one product with an offer per seller, or fails its offer fetch
+type sellersProvider struct {
+	sellers []string
+	fail    bool
+}
+
+func (p *sellersProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	return []providers.ProductCandidate{{Title: "Sony WH-1000XM5 Wireless Headphones"}}, nil
+}
+
+func (p *sellersProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	if p.fail {
+		return nil, errors.New("provider unavailable")
+	}
+	offers := make([]*models.Offer, 0, len(p.sellers))
+	for _, seller := range p.sellers {
+		offers = append(offers, &models.Offer{
+			ProductID:   product.ID,
+			Source:      "demo",
+			Seller:      seller,
+			PriceAmount: 29999,
+			Currency:    "USD",
+			InStock:     true,
+		})
+	}
+	return offers, nil
+}
+
+func TestHandleFetchPricesOfferLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	provider := &sellersProvider{}
+	processor := newTestProcessor(t, store, demoProviders(provider))
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+
+	// fetch runs one fetch_prices job and returns the listed and delisted offers by seller
+	fetch := func(sellers []string, fail bool) (listed, delisted map[string]*models.Offer) {
+		t.Helper()
+		provider.sellers, provider.fail = sellers, fail
+		processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data))
+
+		products, _, err := store.Products().Search(ctx, "Sony", "", 10, 0)
+		if err != nil || len(products) != 1 {
+			t.Fatalf("Search() = %v, %v, want one product", products, err)
+		}
+		listed, delisted = map[string]*models.Offer{}, map[string]*models.Offer{}
+		offers, _ := store.Offers().GetByProductID(ctx, products[0].ID)
+		for _, offer := range offers {
+			listed[offer.Seller] = offer
+		}
+		offers, _, _ = store.Offers().GetPageByProductID(ctx, products[0].ID, true, 100, 0)
+		for _, offer := range offers {
+			if offer.Delisted() {
+				delisted[offer.Seller] = offer
+			}
+		}
+		return listed, delisted
+	}
+
+	listed, delisted := fetch([]string{"Alpha", "Beta"}, false)
+	if len(listed) != 2 || len(delisted) != 0 {
+		t.Fatalf("first fetch: listed %d, delisted %d, want 2 and 0", len(listed), len(delisted))
+	}
+	first := listed["Beta"]
+
+	listed, delisted = fetch([]string{"Alpha"}, false)
+	if len(listed) != 1 || delisted["Beta"] == nil {
+		t.Fatalf("second fetch: listed %v, delisted %v, want Beta delisted", listed, delisted)
+	}
+	if beta := delisted["Beta"]; !beta.FirstSeenAt.Equal(first.FirstSeenAt) || !beta.LastSeenAt.Equal(first.LastSeenAt) {
+		t.Errorf("delisted Beta seen %v..%v, want %v..%v", beta.FirstSeenAt, beta.LastSeenAt, first.FirstSeenAt, first.LastSeenAt)
+	}
+	if alpha := listed["Alpha"]; !alpha.LastSeenAt.After(alpha.FirstSeenAt) {
+		t.Errorf("Alpha last seen %v, want after first seen %v", alpha.LastSeenAt, alpha.FirstSeenAt)
+	}
+
+	// A failed fetch says nothing about the listings
+	listed, delisted = fetch(nil, true)
+	if len(listed) != 1 || len(delisted) != 1 {
+		t.Errorf("failed fetch: listed %d, delisted %d, want 1 and 1", len(listed), len(delisted))
+	}
+
+	listed, delisted = fetch([]string{"Alpha", "Beta"}, false)
+	if beta := listed["Beta"]; beta == nil || beta.ID != first.ID || !beta.FirstSeenAt.Equal(first.FirstSeenAt) || len(delisted) != 0 {
+		t.Errorf("relisted Beta = %+v, want ID %s first seen %v", beta, first.ID, first.FirstSeenAt)
+	}
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// AlertDispatcher delivers one price alert; implemented by *notifications.Dispatcher
+type AlertDispatcher interface {
+	Dispatch(ctx context.Context, channel, target string, alert notifications.PriceAlert) error
+}
+
+// PriceAlertEvaluator fires price alerts whose product's cheapest published offer is at
+// or below the target price, and re-arms fired alerts once the price rises above it
+type PriceAlertEvaluator struct {
+	alertRepo   repository.PriceAlertStore
+	productRepo repository.ProductStore
+	dispatcher  AlertDispatcher
+	logger      *zap.Logger
+	now         func() time.Time
+}
+
+func NewPriceAlertEvaluator(alertRepo repository.PriceAlertStore, productRepo repository.ProductStore, dispatcher AlertDispatcher, logger *zap.Logger) *PriceAlertEvaluator {
+	return &PriceAlertEvaluator{
+		alertRepo:   alertRepo,
+		productRepo: productRepo,
+		dispatcher:  dispatcher,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+// Evaluate checks every active alert (see models.PriceAlert.Active) and returns the
+// number fired. A failed delivery leaves the alert armed, so it is retried by the next
+// evaluation rather than failing the others.
+func (e *PriceAlertEvaluator) Evaluate(ctx context.Context) (int, error) {
+	alerts, err := e.alertRepo.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load price alerts: %w", err)
+	}
+	alerts = slices.DeleteFunc(alerts, func(alert *models.PriceAlert) bool { return !alert.Active() })
+	if len(alerts) == 0 {
+		return 0, nil
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	ids := []uuid.UUID{}
+	for _, alert := range alerts {
+		if !seen[alert.ProductID] {
+			seen[alert.ProductID] = true
+			ids = append(ids, alert.ProductID)
+		}
+	}
+	summaries, err := e.productRepo.GetSummaries(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load products: %w", err)
+	}
+	byProduct := make(map[uuid.UUID]*repository.ProductSummary, len(summaries))
+	for _, summary := range summaries {
+		byProduct[summary.Product.ID] = summary
+	}
+
+	fired := 0
+	for _, alert := range alerts {
+		summary := byProduct[alert.ProductID]
+		below := summary != nil && summary.MinPriceCents != nil && *summary.MinPriceCents <= alert.TargetPriceCents
+
+		if alert.TriggeredAt != nil {
+			if !below {
+				if err := e.alertRepo.MarkTriggered(ctx, alert.ID, nil, nil); err != nil {
+					e.logger.Warn("Failed to re-arm price alert", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+				}
+			}
+			continue
+		}
+		if !below {
+			continue
+		}
+
+		now := e.now()
+		price := *summary.MinPriceCents
+		err := e.dispatcher.Dispatch(ctx, alert.Channel, alert.Target, notifications.PriceAlert{
+			AlertID:          alert.ID.String(),
+			ProductID:        alert.ProductID.String(),
+			ProductTitle:     summary.Product.Title,
+			TargetPriceCents: alert.TargetPriceCents,
+			PriceCents:       price,
+			TriggeredAt:      now,
+		})
+		if err != nil {
+			e.logger.Warn("Failed to deliver price alert",
+				zap.String("alert_id", alert.ID.String()),
+				zap.String("channel", alert.Channel),
+				zap.Error(err),
+			)
+			continue
+		}
+		if err := e.alertRepo.MarkTriggered(ctx, alert.ID, &now, &price); err != nil {
+			// Delivered but not recorded; the alert fires again on the next evaluation
+			e.logger.Error("Failed to record price alert", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+			continue
+		}
+		fired++
+	}
+	return fired, nil
+}
+
+// HandleEvaluateAlerts runs Evaluate; it is enqueued after every fetch_prices run
+func (e *PriceAlertEvaluator) HandleEvaluateAlerts(ctx context.Context, t *asynq.Task) error {
+	fired, err := e.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	e.logger.Info("Price alerts evaluated", zap.Int("fired", fired))
+	return nil
+}
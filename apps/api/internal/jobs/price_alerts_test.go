@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/notifications"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+type recordingDispatcher struct {
+	sent []notifications.PriceAlert
+	err  error
+}
+
+func (d *recordingDispatcher) Dispatch(ctx context.Context, channel, target string, alert notifications.PriceAlert) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.sent = append(d.sent, alert)
+	return nil
+}
+
+func TestPriceAlertEvaluator(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony WH-1000XM5"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	offer := &models.Offer{ProductID: product.ID, Source: "amazon", Seller: "Amazon", TotalToUSAmount: 30000}
+	if err := store.Offers().Create(ctx, offer); err != nil {
+		t.Fatal(err)
+	}
+	alert := &models.PriceAlert{ProductID: product.ID, Channel: models.AlertChannelWebhook, Target: "https://example.com/hook", TargetPriceCents: 25000}
+	if err := store.PriceAlerts().Create(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher := &recordingDispatcher{}
+	evaluator := NewPriceAlertEvaluator(store.PriceAlerts(), store.Products(), dispatcher, zap.NewNop())
+
+	setPrice := func(total int) {
+		t.Helper()
+		offer.TotalToUSAmount = total
+		if err := store.Offers().Upsert(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evaluate := func(wantFired int) {
+		t.Helper()
+		fired, err := evaluator.Evaluate(ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if fired != wantFired {
+			t.Errorf("Evaluate() fired %d, want %d", fired, wantFired)
+		}
+	}
+
+	// Above the target
+	evaluate(0)
+
+	setPrice(24000)
+	evaluate(1)
+	if len(dispatcher.sent) != 1 || dispatcher.sent[0].PriceCents != 24000 || dispatcher.sent[0].ProductTitle != "Sony WH-1000XM5" {
+		t.Fatalf("sent = %+v, want one alert at $240", dispatcher.sent)
+	}
+	stored, _ := store.PriceAlerts().GetByID(ctx, alert.ID)
+	if stored.TriggeredAt == nil || *stored.TriggeredCents != 24000 {
+		t.Errorf("alert = %+v, want triggered at 24000", stored)
+	}
+
+	// Still below: not alerted again
+	setPrice(23000)
+	evaluate(0)
+
+	// Back above the target re-arms the alert, the next drop fires it again
+	setPrice(26000)
+	evaluate(0)
+	if stored, _ := store.PriceAlerts().GetByID(ctx, alert.ID); stored.TriggeredAt != nil {
+		t.Errorf("alert = %+v, want re-armed", stored)
+	}
+
+	// A failed delivery keeps the alert armed
+	setPrice(25000)
+	dispatcher.err = errors.New("connection refused")
+	evaluate(0)
+	dispatcher.err = nil
+	evaluate(1)
+	if len(dispatcher.sent) != 2 {
+		t.Errorf("sent %d alerts, want 2", len(dispatcher.sent))
+	}
+
+	// An email alert is only evaluated once its address is confirmed
+	emailAlert := &models.PriceAlert{ProductID: product.ID, Channel: models.AlertChannelEmail, Target: "buyer@example.com", TargetPriceCents: 25000, ConfirmToken: "confirm-token"}
+	if err := store.PriceAlerts().Create(ctx, emailAlert); err != nil {
+		t.Fatal(err)
+	}
+	evaluate(0)
+	if _, err := store.PriceAlerts().Confirm(ctx, "confirm-token", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	evaluate(1)
+}
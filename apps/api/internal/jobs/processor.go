@@ -3,46 +3,301 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/category"
+	"github.com/pricecompare/api/internal/embedding"
+	"github.com/pricecompare/api/internal/events"
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/ingest"
+	"github.com/pricecompare/api/internal/locale"
+	"github.com/pricecompare/api/internal/matching"
 	"github.com/pricecompare/api/internal/models"
 	"github.com/pricecompare/api/internal/providers"
 	"github.com/pricecompare/api/internal/repository"
 	"github.com/pricecompare/api/internal/shipping"
+	"github.com/pricecompare/api/internal/tracing"
+	"github.com/pricecompare/api/internal/translate"
+)
+
+// rateLimitBackoff is the wait before retrying a rate-limited search
+var rateLimitBackoff = 5 * time.Second
+
+const (
+	// similarTitlePrefilter is the minimum pg_trgm similarity of products scored against a
+	// candidate, matching pg_trgm's default similarity_threshold
+	similarTitlePrefilter = 0.3
+	// similarTitleCandidates bounds the products scored per candidate
+	similarTitleCandidates = 10
+	// evaluateAlertsUniqueTTL is how long an enqueued evaluate_alerts task absorbs the
+	// ones enqueued after it, if it has not completed before
+	evaluateAlertsUniqueTTL = 10 * time.Minute
 )
 
 type Processor struct {
-	productRepo      *repository.ProductRepository
-	offerRepo        *repository.OfferRepository
-	identifierRepo   *repository.ProductIdentifierRepository
-	providerManager  *providers.Manager
-	shippingCalc     *shipping.Calculator
-	logger           *zap.Logger
+	productRepo         repository.ProductStore
+	offerRepo           repository.OfferStore
+	identifierRepo      repository.ProductIdentifierStore
+	sourceProductRepo   repository.SourceProductStore
+	mergeCandidateRepo  repository.MergeCandidateStore
+	shippingOptionRepo  repository.OfferShippingOptionStore
+	providerFetchRepo   repository.ProviderFetchStore
+	priceChangeRepo     repository.OfferPriceChangeStore
+	providerManager     *providers.Manager
+	shippingCalc        *shipping.Calculator
+	matchScoreThreshold float64 // see findSimilarProduct
+	anomalyDropPercent  float64 // see offerAnomaly
+	logger              *zap.Logger
+
+	// Optional embedding-based matching, see EnableEmbeddingMatching
+	embedder           embedding.Embedder
+	embeddingRepo      repository.ProductEmbeddingStore
+	embeddingThreshold float64
+
+	// Optional perceptual image hash matching, see EnableImageMatching
+	imageHasher      *imagehash.Hasher
+	imageRepo        repository.ProductImageStore
+	imageMaxDistance int
+
+	// Optional domain event publishing, see EnableEventPublishing
+	events events.Publisher
+
+	// Optional search index mirroring, see EnableSearchIndexing
+	searchIndexer *SearchIndexer
+
+	// Optional candidate quality gate and listing quotas, see EnableIngestionRules
+	ingestRules *ingest.Rules
+
+	// Optional price alert evaluation after each run, see EnablePriceAlerts
+	alertQueue Enqueuer
+
+	// Optional protection of curator edits, see EnableCuratedFields
+	revisionRepo repository.ProductRevisionStore
+
+	// Optional stock history, see EnableStockTracking
+	stockEventRepo repository.StockEventStore
+
+	// Optional log of offer deduplication decisions, see EnableOfferMergeLog
+	offerMergeRepo repository.OfferMergeLogStore
+
+	// Optional serialization of offer refreshes per product, see EnableProductLocks
+	productLocker ProductLocker
+
+	// Optional operator-managed search queries, see EnableSearchQueries
+	searchQueryRepo repository.SearchQueryStore
+
+	// Optional invalidation of cached API responses, see EnableResponseCacheInvalidation
+	responseCache ResponseCacheInvalidator
+
+	// Optional translation of listing titles, see EnableTranslation
+	translator translate.Translator
+
+	// Optional record of each run's linked products, see EnableJobRuns
+	jobRunRepo repository.JobRunStore
+
+	// Default limits of each run, see SetCrawlBudget
+	crawlBudget CrawlBudget
+
+	// Deadline of each provider call, see SetProviderTimeouts
+	providerTimeouts map[string]time.Duration
 }
 
 func NewProcessor(
-	productRepo *repository.ProductRepository,
-	offerRepo *repository.OfferRepository,
-	identifierRepo *repository.ProductIdentifierRepository,
+	productRepo repository.ProductStore,
+	offerRepo repository.OfferStore,
+	identifierRepo repository.ProductIdentifierStore,
+	sourceProductRepo repository.SourceProductStore,
+	mergeCandidateRepo repository.MergeCandidateStore,
+	shippingOptionRepo repository.OfferShippingOptionStore,
+	providerFetchRepo repository.ProviderFetchStore,
+	priceChangeRepo repository.OfferPriceChangeStore,
 	providerManager *providers.Manager,
 	shippingCalc *shipping.Calculator,
+	matchScoreThreshold float64,
+	anomalyDropPercent float64,
 	logger *zap.Logger,
 ) *Processor {
 	return &Processor{
-		productRepo:     productRepo,
-		offerRepo:       offerRepo,
-		identifierRepo:  identifierRepo,
-		providerManager: providerManager,
-		shippingCalc:    shippingCalc,
-		logger:          logger,
+		productRepo:         productRepo,
+		offerRepo:           offerRepo,
+		identifierRepo:      identifierRepo,
+		sourceProductRepo:   sourceProductRepo,
+		mergeCandidateRepo:  mergeCandidateRepo,
+		shippingOptionRepo:  shippingOptionRepo,
+		providerFetchRepo:   providerFetchRepo,
+		priceChangeRepo:     priceChangeRepo,
+		providerManager:     providerManager,
+		shippingCalc:        shippingCalc,
+		matchScoreThreshold: matchScoreThreshold,
+		anomalyDropPercent:  anomalyDropPercent,
+		logger:              logger,
+	}
+}
+
+// EnableEmbeddingMatching adds nearest-neighbor search on title embeddings as the last
+// matcher in processCandidate. New products get their title embedding stored.
+func (p *Processor) EnableEmbeddingMatching(embedder embedding.Embedder, embeddingRepo repository.ProductEmbeddingStore, threshold float64) {
+	p.embedder = embedder
+	p.embeddingRepo = embeddingRepo
+	p.embeddingThreshold = threshold
+}
+
+// EnableImageMatching hashes candidate images (pHash) and adds a match on image hash
+// distance after title matching. Hashes are stored in product_images for image search.
+func (p *Processor) EnableImageMatching(hasher *imagehash.Hasher, imageRepo repository.ProductImageStore, maxDistance int) {
+	p.imageHasher = hasher
+	p.imageRepo = imageRepo
+	p.imageMaxDistance = maxDistance
+}
+
+// EnableEventPublishing publishes product.created, offer.price_changed and
+// offer.out_of_stock events as fetched listings are saved
+func (p *Processor) EnableEventPublishing(publisher events.Publisher) {
+	p.events = publisher
+}
+
+// EnableIngestionRules skips candidates that fail rules (short titles, banned keywords,
+// no price) or would exceed their source's listing quotas before they are matched or
+// turned into products
+func (p *Processor) EnableIngestionRules(rules ingest.Rules) {
+	p.ingestRules = &rules
+}
+
+// EnableSearchIndexing refreshes the search index document of each product after its
+// offers are saved and deletes the documents of merged duplicates
+func (p *Processor) EnableSearchIndexing(indexer *SearchIndexer) {
+	p.searchIndexer = indexer
+}
+
+// EnablePriceAlerts enqueues an evaluate_alerts task after every fetch_prices run
+func (p *Processor) EnablePriceAlerts(queue Enqueuer) {
+	p.alertQueue = queue
+}
+
+// EnableCuratedFields stops ingestion from overwriting product fields that curators
+// edited with PATCH /api/admin/products/:id
+func (p *Processor) EnableCuratedFields(revisionRepo repository.ProductRevisionStore) {
+	p.revisionRepo = revisionRepo
+}
+
+// EnableStockTracking records in/out-of-stock transitions of refreshed offers in
+// stock_events
+func (p *Processor) EnableStockTracking(stockEventRepo repository.StockEventStore) {
+	p.stockEventRepo = stockEventRepo
+}
+
+// EnableOfferMergeLog records in offer_merge_log which fetched offers were collapsed
+// into another offer as duplicates
+func (p *Processor) EnableOfferMergeLog(offerMergeRepo repository.OfferMergeLogStore) {
+	p.offerMergeRepo = offerMergeRepo
+}
+
+// EnableProductLocks refreshes the offers of one product in one job at a time. A job
+// that waits too long for a product skips it.
+func (p *Processor) EnableProductLocks(locker ProductLocker) {
+	p.productLocker = locker
+}
+
+// EnableSearchQueries searches each provider for its enabled search_queries rows in
+// priority order instead of DefaultSearchQueries
+func (p *Processor) EnableSearchQueries(searchQueryRepo repository.SearchQueryStore) {
+	p.searchQueryRepo = searchQueryRepo
+}
+
+// ResponseCacheInvalidator retires cached API responses (see respcache.Cache)
+type ResponseCacheInvalidator interface {
+	InvalidateProduct(ctx context.Context, productID uuid.UUID) error
+	InvalidateSearch(ctx context.Context) error
+}
+
+// EnableResponseCacheInvalidation invalidates the cached offers and compare responses
+// of each product whose offers a run writes or that it merges, and the cached search
+// responses once at the end of the run
+func (p *Processor) EnableResponseCacheInvalidation(cache ResponseCacheInvalidator) {
+	p.responseCache = cache
+}
+
+// EnableTranslation translates the title of each Japanese listing into English and of
+// each English listing into Japanese, and stores it with the listing's titles. A listing
+// whose title did not change since the last fetch keeps its translation.
+func (p *Processor) EnableTranslation(translator translate.Translator) {
+	p.translator = translator
+}
+
+// SetCrawlBudget sets the default limits of each fetch_prices run. A payload can
+// override each limit.
+func (p *Processor) SetCrawlBudget(budget CrawlBudget) {
+	p.crawlBudget = budget
+}
+
+// publish sends an event if publishing is enabled. A bus outage must not fail the job,
+// so errors are only logged.
+func (p *Processor) publish(ctx context.Context, event events.Event) {
+	if p.events == nil {
+		return
+	}
+	if err := p.events.Publish(ctx, event); err != nil {
+		p.logger.Warn("Failed to publish event",
+			zap.String("type", event.Type),
+			zap.String("key", event.Key),
+			zap.Error(err),
+		)
 	}
 }
 
+// curatedFields returns the fields of a product edited by curators. On a lookup error
+// nothing is overwritten, so a failing query cannot undo curator edits.
+func (p *Processor) curatedFields(ctx context.Context, productID uuid.UUID) map[string]bool {
+	if p.revisionRepo == nil {
+		return nil
+	}
+	fields, err := p.revisionRepo.EditedFields(ctx, productID)
+	if err != nil {
+		p.logger.Warn("Failed to get curated product fields", zap.String("product_id", productID.String()), zap.Error(err))
+		return map[string]bool{
+			models.ProductFieldBrand:    true,
+			models.ProductFieldModel:    true,
+			models.ProductFieldImageURL: true,
+			models.ProductFieldCategory: true,
+		}
+	}
+	return fields
+}
+
+// searchQueries returns the queries to search a provider for: its enabled stored
+// queries, or DefaultSearchQueries when it has none stored. On a lookup error the
+// defaults are used, so a failing query cannot stop fetching.
+func (p *Processor) searchQueries(ctx context.Context, sourceName string) []string {
+	defaults := DefaultSearchQueries[sourceName]
+	if p.searchQueryRepo == nil {
+		return defaults
+	}
+	stored, err := p.searchQueryRepo.List(ctx, sourceName)
+	if err != nil {
+		p.logger.Warn("Failed to load search queries, using defaults", zap.String("source", sourceName), zap.Error(err))
+		return defaults
+	}
+	if len(stored) == 0 {
+		return defaults
+	}
+	queries := make([]string, 0, len(stored))
+	for _, query := range stored {
+		if query.Enabled {
+			queries = append(queries, query.Query)
+		}
+	}
+	return queries
+}
+
 func (p *Processor) HandleFetchPrices(ctx context.Context, t *asynq.Task) error {
 	var payload FetchPricesPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
@@ -50,6 +305,11 @@ func (p *Processor) HandleFetchPrices(ctx context.Context, t *asynq.Task) error
 	}
 
 	p.logger.Info("Processing fetch_prices job", zap.String("source", payload.Source))
+	run := &crawlRun{budget: p.crawlBudget.withOverrides(payload)}
+	jobRun := p.startJobRun(ctx, TypeFetchPrices, payload.Source)
+	// A panic or cancellation leaves completed false, so the run is not left running
+	completed := false
+	defer func() { p.finishJobRun(ctx, jobRun, run, completed) }()
 
 	sources := []string{}
 	if payload.Source == "all" {
@@ -58,14 +318,40 @@ func (p *Processor) HandleFetchPrices(ctx context.Context, t *asynq.Task) error
 		sources = []string{payload.Source}
 	}
 
+	statuses := make([]providers.ProviderStatus, 0, len(sources))
 	for _, sourceName := range sources {
+		status := p.providerManager.Status(sourceName)
+		if run.exhausted() {
+			p.logger.Warn("Crawl budget exhausted, skipping source",
+				zap.String("source", sourceName),
+				zap.Int("max_requests", run.budget.MaxRequests),
+			)
+			status.Status = providers.StatusNoBudget
+			statuses = append(statuses, status)
+			continue
+		}
+		// A fan-out skips providers that keep failing; a run of one source still calls
+		// it, which also probes whether it recovered
+		if payload.Source == "all" && status.Status == providers.StatusCircuitOpen {
+			p.logger.Warn("Provider circuit is open, skipping source",
+				zap.String("source", sourceName),
+				zap.Timep("retry_at", status.RetryAt),
+			)
+			statuses = append(statuses, status)
+			continue
+		}
 		provider, err := p.providerManager.Get(sourceName)
 		if err != nil {
 			p.logger.Warn("Provider not found", zap.String("source", sourceName))
 			continue
 		}
+		statuses = append(statuses, status)
 
-		if err := p.fetchFromProvider(ctx, provider, sourceName); err != nil {
+		if timeout := p.providerTimeout(sourceName); timeout > 0 {
+			provider = &timeoutProvider{Provider: provider, sourceName: sourceName, timeout: timeout}
+		}
+		provider = &recordingProvider{Provider: provider, sourceName: sourceName, repo: p.providerFetchRepo, manager: p.providerManager, logger: p.logger}
+		if err := p.fetchFromProvider(ctx, run, provider, sourceName); err != nil {
 			p.logger.Error("Failed to fetch from provider",
 				zap.String("source", sourceName),
 				zap.Error(err),
@@ -73,68 +359,97 @@ func (p *Processor) HandleFetchPrices(ctx context.Context, t *asynq.Task) error
 		}
 	}
 
+	p.logger.Info("Finished fetch_prices job", zap.String("source", payload.Source), zap.Any("providers", statuses))
+	// Search responses are retired once per run rather than per product, so they stay
+	// cached while a run writes
+	if p.responseCache != nil {
+		if err := p.responseCache.InvalidateSearch(ctx); err != nil {
+			p.logger.Warn("Failed to invalidate cached search responses", zap.Error(err))
+		}
+	}
+
+	if _, err := p.providerFetchRepo.DeleteBefore(ctx, time.Now().Add(-providerFetchRetention)); err != nil {
+		p.logger.Warn("Failed to prune provider fetches", zap.Error(err))
+	}
+
+	if p.alertQueue != nil {
+		// Runs of several sources finishing together need a single evaluation
+		_, err := Enqueue(ctx, p.alertQueue, TypeEvaluateAlerts, &EvaluateAlertsPayload{}, asynq.Unique(evaluateAlertsUniqueTTL))
+		if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+			p.logger.Warn("Failed to enqueue evaluate_alerts job", zap.Error(err))
+		}
+	}
+
+	completed = ctx.Err() == nil
 	return nil
 }
 
-func (p *Processor) fetchFromProvider(ctx context.Context, provider providers.Provider, sourceName string) error {
-	// For demo provider, we use predefined search queries
-	// For public_html, we parse sample files
-	// For walmart/amazon, use predefined search queries
+func (p *Processor) fetchFromProvider(ctx context.Context, run *crawlRun, provider providers.Provider, sourceName string) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "fetch_prices.provider",
+		trace.WithAttributes(attribute.String("provider", sourceName)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	// demo, live, walmart, amazon, rakuten, aliexpress and google_shopping are searched for
+	// p.searchQueries; public_html
+	// parses every sample file. A provider that is not enabled or rejects its
+	// credentials (providers.IsFatal) is not called again in this run.
 
 	if sourceName == "demo" {
-		queries := []string{"headphones", "watch", "cable"}
-		for _, query := range queries {
-			candidates, err := provider.Search(ctx, query)
+		for _, query := range p.searchQueries(ctx, sourceName) {
+			if !run.take() {
+				break
+			}
+			candidates, err := p.search(ctx, run, provider, query)
+			if providers.IsFatal(err) {
+				return err
+			}
 			if err != nil {
 				p.logger.Error("Search failed", zap.Error(err))
 				continue
 			}
 
-			for _, candidate := range candidates {
-				if err := p.processCandidate(ctx, candidate, provider, sourceName); err != nil {
-					p.logger.Error("Failed to process candidate", zap.Error(err))
-				}
+			if err := p.processCandidates(ctx, run, candidates, provider, sourceName); err != nil {
+				return err
 			}
 		}
 	} else if sourceName == "public_html" {
 		// Search all products from sample files
+		if !run.take() {
+			return nil
+		}
 		candidates, err := provider.Search(ctx, "")
 		if err != nil {
 			return fmt.Errorf("failed to search: %w", err)
 		}
 
-		for _, candidate := range candidates {
-			if err := p.processCandidate(ctx, candidate, provider, sourceName); err != nil {
-				p.logger.Error("Failed to process candidate", zap.Error(err))
-			}
+		if err := p.processCandidates(ctx, run, candidates, provider, sourceName); err != nil {
+			return err
 		}
 	} else if sourceName == "live" {
-		// For live provider, use predefined search queries
-		// In production, these could come from a configuration or database
-		queries := []string{"headphones", "watch", "laptop"}
-		for _, query := range queries {
-			candidates, err := provider.Search(ctx, query)
+		for _, query := range p.searchQueries(ctx, sourceName) {
+			if !run.take() {
+				break
+			}
+			candidates, err := p.search(ctx, run, provider, query)
+			if providers.IsFatal(err) {
+				return err
+			}
 			if err != nil {
 				p.logger.Error("Search failed", zap.Error(err))
 				continue
 			}
 
 			// Limit number of products per query to avoid too many requests
-			maxProducts := 5
-			for i, candidate := range candidates {
-				if i >= maxProducts {
-					break
-				}
-				if err := p.processCandidate(ctx, candidate, provider, sourceName); err != nil {
-					p.logger.Error("Failed to process candidate", zap.Error(err))
-				}
+			if err := p.processCandidates(ctx, run, candidates, provider, sourceName); err != nil {
+				return err
 			}
 		}
-	} else if sourceName == "walmart" || sourceName == "amazon" {
-		// For official API providers, use predefined search queries
-		// In production, these could come from a configuration or database
-		queries := []string{"headphones", "laptop", "smartphone", "tablet", "watch", "minecraft", "game", "toy", "book"}
-		for i, query := range queries {
+	} else if sourceName == "walmart" || sourceName == "amazon" || sourceName == "rakuten" || sourceName == "aliexpress" || sourceName == "google_shopping" {
+		for i, query := range p.searchQueries(ctx, sourceName) {
+			if run.exhausted() {
+				break
+			}
 			// Add delay between requests to avoid rate limiting
 			if i > 0 {
 				// Wait 1 second between requests for rate limiting
@@ -145,70 +460,189 @@ func (p *Processor) fetchFromProvider(ctx context.Context, provider providers.Pr
 				}
 			}
 
-			candidates, err := provider.Search(ctx, query)
+			run.take()
+			candidates, err := p.search(ctx, run, provider, query)
+			if providers.IsFatal(err) {
+				return err
+			}
 			if err != nil {
 				p.logger.Error("Search failed", zap.Error(err), zap.String("query", query))
-				// If rate limited, wait longer before next request
-				if strings.Contains(err.Error(), "429") || strings.Contains(err.Error(), "Too many requests") {
-					p.logger.Warn("Rate limited, waiting 5 seconds", zap.String("query", query))
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					case <-time.After(5 * time.Second):
-					}
-				}
 				continue
 			}
 
 			// Limit number of products per query to avoid too many API requests
-			maxProducts := 5 // Reduced from 10 to avoid rate limiting
-			for j, candidate := range candidates {
-				if j >= maxProducts {
-					break
-				}
-				if err := p.processCandidate(ctx, candidate, provider, sourceName); err != nil {
-					p.logger.Error("Failed to process candidate", zap.Error(err))
-				}
+			if err := p.processCandidates(ctx, run, candidates, provider, sourceName); err != nil {
+				return err
 			}
 		}
 	}
 
+	if run.exhausted() {
+		p.logger.Warn("Crawl budget exhausted",
+			zap.String("source", sourceName),
+			zap.Int("max_requests", run.budget.MaxRequests),
+		)
+	}
+
+	return nil
+}
+
+// search runs a provider search whose request was already taken from the budget. A
+// rate-limited search is retried once after rateLimitBackoff if a request is left.
+func (p *Processor) search(ctx context.Context, run *crawlRun, provider providers.Provider, query string) ([]providers.ProductCandidate, error) {
+	candidates, err := provider.Search(ctx, query)
+	if !errors.Is(err, providers.ErrRateLimited) {
+		return candidates, err
+	}
+	p.logger.Warn("Rate limited, retrying search", zap.String("query", query), zap.Duration("backoff", rateLimitBackoff))
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(rateLimitBackoff):
+	}
+	if !run.take() {
+		return nil, err
+	}
+	return provider.Search(ctx, query)
+}
+
+// processCandidates processes the candidates of one search within the crawl budget. It
+// only returns an error that stops the provider (providers.IsFatal); others are logged.
+func (p *Processor) processCandidates(ctx context.Context, run *crawlRun, candidates []providers.ProductCandidate, provider providers.Provider, sourceName string) error {
+	for _, candidate := range run.candidates(sourceName, candidates) {
+		err := p.processCandidate(ctx, run, candidate, provider, sourceName)
+		if providers.IsFatal(err) {
+			return err
+		}
+		if err != nil {
+			p.logger.Error("Failed to process candidate", zap.Error(err))
+		}
+	}
 	return nil
 }
 
 func (p *Processor) processCandidate(
 	ctx context.Context,
+	run *crawlRun,
 	candidate providers.ProductCandidate,
 	provider providers.Provider,
 	sourceName string,
-) error {
+) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "fetch_prices.candidate",
+		trace.WithAttributes(attribute.String("provider", sourceName)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	// Without a request left for its offers the candidate is not matched or saved
+	if run.exhausted() {
+		return nil
+	}
+
+	if p.ingestRules != nil {
+		if reason := p.ingestRules.Check(candidate, sourceName); reason != "" {
+			p.logger.Info("Skipping candidate rejected by ingestion rules",
+				zap.String("provider", sourceName),
+				zap.String("title", candidate.Title),
+				zap.String("reason", reason),
+			)
+			span.SetAttributes(attribute.String("rejected", reason))
+			return nil
+		}
+		if reason := p.checkListingQuota(ctx, run, candidate, sourceName); reason != "" {
+			p.logger.Info("Skipping candidate over the source's listing quota",
+				zap.String("provider", sourceName),
+				zap.String("title", candidate.Title),
+				zap.String("reason", reason),
+			)
+			span.SetAttributes(attribute.String("rejected", reason))
+			return nil
+		}
+	}
+
 	var product *models.Product
-	var err error
+
+	// How the candidate was linked to its product, recorded on source_products
+	matchMethod := models.MatchMethodNew
+	matchConfidence := 1.0
+
+	// Identifiers come from what the provider reported: a model read from the title may
+	// name a component (a laptop's CPU), so it must not merge products by brand+model
+	identifiers := candidateIdentifiers(candidate, sourceName)
+
+	// Providers rarely report a model number, but electronics titles usually contain one,
+	// and brand+model is a much stronger scoring signal than the whole title
+	modelExtracted := false
+	if candidate.Model == nil || *candidate.Model == "" {
+		if model := matching.ExtractModel(candidate.Title); model != "" {
+			candidate.Model = &model
+			modelExtracted = true
+		}
+	}
 
 	// Try to find product by identifier first (for product unification)
-	if candidate.Identifier != nil && *candidate.Identifier != "" {
-		identifierType := getIdentifierType(sourceName)
-		if identifierType != "" {
-			_, existingProduct, err := p.identifierRepo.FindByTypeAndValue(identifierType, *candidate.Identifier)
-			if err != nil {
-				p.logger.Warn("Failed to lookup identifier", zap.Error(err))
-			} else if existingProduct != nil {
-				product = existingProduct
-				p.logger.Info("Found existing product by identifier",
-					zap.String("identifier_type", identifierType),
-					zap.String("identifier_value", *candidate.Identifier),
-					zap.String("product_id", product.ID.String()),
-				)
+	for _, identifier := range identifiers {
+		_, existingProduct, err := p.identifierRepo.FindByTypeAndValue(ctx, identifier.Type, identifier.Value)
+		if err != nil {
+			p.logger.Warn("Failed to lookup identifier", zap.Error(err))
+			continue
+		}
+		if existingProduct != nil {
+			product = existingProduct
+			matchMethod = models.MatchMethodIdentifier
+			if identifier.Type == matching.IdentifierTypeModel {
+				// Color/size variants can share a model number
+				matchConfidence = 0.95
 			}
+			p.logger.Info("Found existing product by identifier",
+				zap.String("identifier_type", identifier.Type),
+				zap.String("identifier_value", identifier.Value),
+				zap.String("product_id", product.ID.String()),
+			)
+			break
 		}
 	}
 
 	// Fallback to title-based search if no identifier match
 	if product == nil {
-		product, err = p.productRepo.FindByTitle(candidate.Title)
+		product, err = p.productRepo.FindByTitle(ctx, candidate.Title)
 		if err != nil {
 			return fmt.Errorf("failed to find product: %w", err)
 		}
+		if product != nil {
+			// Variants (colors, sizes) sometimes share a title, so this is not fully certain
+			matchMethod, matchConfidence = models.MatchMethodExactTitle, 0.9
+		}
+	}
+
+	// Fallback to fuzzy title match so minor title differences don't create duplicates
+	if product == nil {
+		if match := p.findSimilarProduct(ctx, candidate, identifiers); match != nil {
+			product = match.Product
+			matchMethod, matchConfidence = models.MatchMethodFuzzyTitle, match.Similarity
+		}
+	}
+
+	// Fallback to the perceptual hash of the product image, if enabled
+	var imageHash *imagehash.Hashes
+	if p.imageHasher != nil && candidate.ImageURL != nil && *candidate.ImageURL != "" {
+		imageHash = p.hashImage(ctx, *candidate.ImageURL, sourceName)
+	}
+	if product == nil && imageHash != nil {
+		if match := p.findByImageHash(ctx, candidate, *imageHash); match != nil {
+			product = match.Product
+			matchMethod, matchConfidence = models.MatchMethodImageHash, imagehash.Similarity(match.Distance)
+		}
+	}
+
+	// Fallback to nearest-neighbor search on title embeddings, if enabled
+	var titleEmbedding []float32
+	if product == nil && p.embedder != nil {
+		var match *repository.ProductSimilarity
+		match, titleEmbedding = p.findByEmbedding(ctx, candidate)
+		if match != nil {
+			product = match.Product
+			matchMethod, matchConfidence = models.MatchMethodEmbedding, match.Similarity
+		}
 	}
 
 	if product == nil {
@@ -217,78 +651,724 @@ func (p *Processor) processCandidate(
 			Brand:    candidate.Brand,
 			Model:    candidate.Model,
 			ImageURL: candidate.ImageURL,
+			Category: normalizeCategory(candidate.Category),
 		}
-		if err := p.productRepo.Create(product); err != nil {
+		if err := p.productRepo.Create(ctx, product); err != nil {
 			return fmt.Errorf("failed to create product: %w", err)
 		}
+		p.publish(ctx, events.NewProductCreated(product, sourceName))
 
-		// Save identifier if available
-		if candidate.Identifier != nil && *candidate.Identifier != "" {
-			identifierType := getIdentifierType(sourceName)
-			if identifierType != "" {
-				identifier := &models.ProductIdentifier{
-					ProductID: product.ID,
-					Type:      identifierType,
-					Value:     *candidate.Identifier,
-				}
-				if err := p.identifierRepo.Create(identifier); err != nil {
-					p.logger.Warn("Failed to save identifier", zap.Error(err))
-				} else {
-					p.logger.Info("Saved product identifier",
-						zap.String("identifier_type", identifierType),
-						zap.String("identifier_value", *candidate.Identifier),
-						zap.String("product_id", product.ID.String()),
-					)
-				}
+		if titleEmbedding != nil {
+			if err := p.embeddingRepo.Upsert(ctx, product.ID, p.embedder.Model(), titleEmbedding); err != nil {
+				p.logger.Warn("Failed to save product embedding", zap.Error(err))
 			}
 		}
 	} else {
-		// Update product info if needed
-		if candidate.Brand != nil {
+		// Update product info if needed, keeping fields edited by curators
+		curated := p.curatedFields(ctx, product.ID)
+		if candidate.Brand != nil && !curated[models.ProductFieldBrand] {
 			product.Brand = candidate.Brand
 		}
-		if candidate.Model != nil {
+		// A model parsed from the title never overrides one reported by a provider
+		if candidate.Model != nil && (!modelExtracted || product.Model == nil) && !curated[models.ProductFieldModel] {
 			product.Model = candidate.Model
 		}
-		if candidate.ImageURL != nil {
+		if candidate.ImageURL != nil && !curated[models.ProductFieldImageURL] {
 			product.ImageURL = candidate.ImageURL
 		}
-		if err := p.productRepo.Update(product); err != nil {
+		if product.Category == nil && !curated[models.ProductFieldCategory] {
+			product.Category = normalizeCategory(candidate.Category)
+		}
+		if err := p.productRepo.Update(ctx, product); err != nil {
 			p.logger.Warn("Failed to update product", zap.Error(err))
 		}
 	}
 
-	// Delete old offers from this source
-	if err := p.offerRepo.DeleteByProductIDAndSource(product.ID, sourceName); err != nil {
-		p.logger.Warn("Failed to delete old offers", zap.Error(err))
+	// Save all identifiers of the listing; identifiers already on another product mean
+	// both products are the same item, so they are merged
+	product = p.linkIdentifiers(ctx, product, identifiers)
+
+	if imageHash != nil {
+		if err := p.imageRepo.Upsert(ctx, product.ID, *candidate.ImageURL, *imageHash); err != nil {
+			p.logger.Warn("Failed to save product image hash", zap.Error(err))
+		}
 	}
 
-	// Fetch offers
-	offers, err := provider.FetchOffers(ctx, product)
+	p.saveSourceProduct(ctx, candidate, sourceName, product, matchMethod, matchConfidence)
+	run.linkProduct(candidate, sourceName, product, matchMethod)
+	span.SetAttributes(
+		attribute.String("product_id", product.ID.String()),
+		attribute.String("match_method", matchMethod),
+	)
+
+	unlock, err := p.lockProduct(ctx, product.ID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch offers: %w", err)
+		return fmt.Errorf("failed to lock product %s: %w", product.ID, err)
 	}
+	defer unlock()
 
-	// Recalculate shipping and save offers
 	now := time.Now()
+
+	// Remember the current offers (including delisted ones) so refreshed ones keep their
+	// ID and first_seen_at and price/stock changes can be published
+	previousOffers := make(map[string]*models.Offer)
+	existing, err := p.offerRepo.GetByProductIDAndSource(ctx, product.ID, sourceName)
+	if err != nil {
+		p.logger.Warn("Failed to load current offers", zap.Error(err))
+	}
+	for _, offer := range existing {
+		previousOffers[offerKey(offer)] = offer
+	}
+	// The median includes this source's offers, so it is read before they are refreshed
+	medianTotal, medianSamples, err := p.offerRepo.PriceHistoryMedian(ctx, product.ID, now.Add(-priceHistoryWindow))
+	if err != nil {
+		p.logger.Warn("Failed to load price history median", zap.Error(err))
+		medianSamples = 0
+	}
+
+	// Spend the request checked at the top on the offer fetch
+	run.take()
+
+	// Fetch offers; on failure the current offers stay listed
+	listingURL := ""
+	if candidate.SourceURL != nil {
+		listingURL = *candidate.SourceURL
+	}
+	offers, err := provider.FetchOffers(ctx, product, listingURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch offers: %w", err)
+	}
+
+	productCategory := ""
+	if product.Category != nil {
+		productCategory = *product.Category
+	}
+
+	// Recalculate shipping
+	priced := make([]*models.Offer, 0, len(offers))
 	for _, offer := range offers {
-		offer.ShippingToUSAmount = p.shippingCalc.CalculateShipping(offer.PriceAmount)
-		offer.TotalToUSAmount = p.shippingCalc.CalculateTotal(offer.PriceAmount)
+		if err := PriceOffer(p.shippingCalc, offer, productCategory); err != nil {
+			p.logger.Warn("Failed to price offer, skipping",
+				zap.String("product_id", product.ID.String()),
+				zap.String("seller", offer.Seller),
+				zap.String("currency", offer.Currency),
+				zap.Error(err),
+			)
+			continue
+		}
 		// Update price_updated_at when price information is refreshed
 		offer.PriceUpdatedAt = now
+		if offer.URL != nil {
+			canonical := canonicalurl.Canonicalize(*offer.URL)
+			offer.URL = &canonical
+			// Providers that link through an affiliate or redirect URL set the page's own
+			if offer.CanonicalURL == nil {
+				productURL := canonicalurl.ProductURL(canonical)
+				offer.CanonicalURL = &productURL
+			}
+		}
+		priced = append(priced, offer)
+	}
+
+	// Collapse duplicate listings, so they do not show up as separate offers; stored
+	// duplicates are delisted below as the fetch no longer returns them
+	offers, duplicates := dedupeOffers(priced)
+	if len(offers) < len(priced) {
+		p.logger.Info("Merged duplicate offers",
+			zap.String("product_id", product.ID.String()),
+			zap.String("provider", sourceName),
+			zap.Int("offers", len(priced)),
+			zap.Int("kept", len(offers)),
+		)
+	}
+	if limited := run.offers(offers); len(limited) < len(offers) {
+		p.logger.Info("Limiting offers to crawl budget",
+			zap.String("product_id", product.ID.String()),
+			zap.String("provider", sourceName),
+			zap.Int("offers", len(offers)),
+			zap.Int("max_offers", run.budget.MaxOffersPerProduct),
+		)
+		offers = limited
+	}
+
+	// Save offers
+	for _, offer := range offers {
+		previous := previousOffers[offerKey(offer)]
+		if previous != nil {
+			offer.ID = previous.ID
+			offer.CreatedAt = previous.CreatedAt
+			offer.FirstSeenAt = previous.FirstSeenAt
+		}
+		if reason := offerAnomaly(offer, previous, medianTotal, medianSamples, p.anomalyDropPercent); reason != "" {
+			offer.QuarantineReason = &reason
+			p.logger.Warn("Quarantining implausible offer",
+				zap.String("product_id", product.ID.String()),
+				zap.String("source", sourceName),
+				zap.String("seller", offer.Seller),
+				zap.String("reason", reason),
+				zap.Int("price_amount", offer.PriceAmount),
+				zap.String("currency", offer.Currency),
+				zap.Int("median_total", medianTotal),
+			)
+		}
 
-		if err := p.offerRepo.Upsert(offer); err != nil {
+		if err := p.offerRepo.Upsert(ctx, offer); err != nil {
 			p.logger.Error("Failed to upsert offer",
 				zap.String("product_id", product.ID.String()),
 				zap.String("seller", offer.Seller),
 				zap.Error(err),
 			)
+			continue
+		}
+		p.recordOfferMerges(ctx, offer, duplicates[offer])
+		if previous != nil {
+			p.recordStockChange(ctx, previous, offer)
+		}
+		// Changes from or to an implausible price are neither history nor news
+		if previous != nil && !previous.Quarantined() && !offer.Quarantined() {
+			p.recordPriceChange(ctx, previous, offer)
+			for _, event := range events.OfferChanges(previous, offer) {
+				p.publish(ctx, event)
+			}
+		}
+
+		if err := p.saveShippingOptions(ctx, offer, productCategory); err != nil {
+			p.logger.Warn("Failed to save shipping options",
+				zap.String("offer_id", offer.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	// Offers of this source that the fetch no longer returned were not seen since now
+	delisted, err := p.offerRepo.MarkDelisted(ctx, product.ID, sourceName, now)
+	if err != nil {
+		p.logger.Warn("Failed to mark delisted offers", zap.Error(err))
+	} else if delisted > 0 {
+		p.logger.Info("Marked offers as delisted",
+			zap.String("product_id", product.ID.String()),
+			zap.String("source", sourceName),
+			zap.Int64("delisted", delisted),
+		)
+	}
+
+	p.invalidateResponses(ctx, product.ID)
+
+	// The next full reindex repairs documents that fail here
+	if p.searchIndexer != nil {
+		if err := p.searchIndexer.IndexProducts(ctx, product.ID); err != nil {
+			p.logger.Warn("Failed to update search index",
+				zap.String("product_id", product.ID.String()),
+				zap.Error(err),
+			)
 		}
 	}
 
 	return nil
 }
 
+// offerKey identifies an offer within a product and source, matching the offers upsert
+// conflict target (seller, url)
+func offerKey(offer *models.Offer) string {
+	url := ""
+	if offer.URL != nil {
+		// Offers stored before URLs were canonicalized still match their refreshed version
+		url = canonicalurl.Canonicalize(*offer.URL)
+	}
+	return offer.Seller + "\x00" + url
+}
+
+// candidateIdentifiers returns the provider identifier, any cross-provider identifiers
+// (UPC, EAN, ...) reported for a candidate and its brand+model key
+func candidateIdentifiers(candidate providers.ProductCandidate, sourceName string) []providers.CandidateIdentifier {
+	identifiers := []providers.CandidateIdentifier{}
+	if candidate.Identifier != nil && *candidate.Identifier != "" {
+		if identifierType := getIdentifierType(sourceName); identifierType != "" {
+			identifiers = append(identifiers, providers.CandidateIdentifier{Type: identifierType, Value: *candidate.Identifier})
+		}
+	}
+	for _, identifier := range candidate.ExternalIdentifiers {
+		if identifier.Type != "" && identifier.Value != "" {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	if value := matching.ModelIdentifier(candidate.Brand, candidate.Model); value != "" {
+		identifiers = append(identifiers, providers.CandidateIdentifier{Type: matching.IdentifierTypeModel, Value: value})
+	}
+	return identifiers
+}
+
+// invalidateResponses retires the cached offers and compare responses of products; they
+// expire on their own if this fails
+func (p *Processor) invalidateResponses(ctx context.Context, productIDs ...uuid.UUID) {
+	if p.responseCache == nil {
+		return
+	}
+	for _, productID := range productIDs {
+		if err := p.responseCache.InvalidateProduct(ctx, productID); err != nil {
+			p.logger.Warn("Failed to invalidate cached responses",
+				zap.String("product_id", productID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// linkIdentifiers saves identifiers that are not yet known and merges any other product
+// that already owns one of them into a single product (the older one is kept). It returns
+// the product that remains.
+func (p *Processor) linkIdentifiers(ctx context.Context, product *models.Product, identifiers []providers.CandidateIdentifier) *models.Product {
+	for _, identifier := range identifiers {
+		_, owner, err := p.identifierRepo.FindByTypeAndValue(ctx, identifier.Type, identifier.Value)
+		if err != nil {
+			p.logger.Warn("Failed to lookup identifier", zap.Error(err))
+			continue
+		}
+
+		if owner == nil {
+			if err := p.identifierRepo.Create(ctx, &models.ProductIdentifier{
+				ProductID: product.ID,
+				Type:      identifier.Type,
+				Value:     identifier.Value,
+			}); err != nil {
+				p.logger.Warn("Failed to save identifier", zap.Error(err))
+				continue
+			}
+			p.logger.Info("Saved product identifier",
+				zap.String("identifier_type", identifier.Type),
+				zap.String("identifier_value", identifier.Value),
+				zap.String("product_id", product.ID.String()),
+			)
+			continue
+		}
+
+		if owner.ID != product.ID {
+			product = p.mergeProducts(ctx, product, owner, identifier)
+		}
+	}
+	return product
+}
+
+// mergeProducts merges two products found to share an identifier through the merge
+// candidate machinery, so the merge is recorded and a dismissed pair is never merged.
+// It returns the product that remains.
+func (p *Processor) mergeProducts(ctx context.Context, a, b *models.Product, shared providers.CandidateIdentifier) *models.Product {
+	kept, duplicate := a, b
+	if b.CreatedAt.Before(a.CreatedAt) {
+		kept, duplicate = b, a
+	}
+
+	detail := shared.Type + ":" + shared.Value
+	candidate := &models.MergeCandidate{
+		ProductID:          kept.ID,
+		DuplicateProductID: duplicate.ID,
+		Reason:             models.MergeReasonIdentifier,
+		Score:              1,
+		Detail:             &detail,
+	}
+	if err := p.mergeCandidateRepo.UpsertPending(ctx, candidate); err != nil {
+		if !errors.Is(err, repository.ErrMergeCandidateResolved) {
+			p.logger.Warn("Failed to save merge candidate", zap.Error(err))
+		}
+		return a
+	}
+	if err := p.mergeCandidateRepo.Merge(ctx, candidate.ID); err != nil {
+		p.logger.Warn("Failed to merge products", zap.String("merge_candidate_id", candidate.ID.String()), zap.Error(err))
+		return a
+	}
+
+	p.invalidateResponses(ctx, kept.ID, duplicate.ID)
+	// The kept product's document is refreshed with its offers
+	if p.searchIndexer != nil {
+		if err := p.searchIndexer.DeleteProducts(ctx, duplicate.ID); err != nil {
+			p.logger.Warn("Failed to delete merged product from search index",
+				zap.String("product_id", duplicate.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+	p.logger.Info("Merged products sharing an identifier",
+		zap.String("identifier", detail),
+		zap.String("product_id", kept.ID.String()),
+		zap.String("duplicate_product_id", duplicate.ID.String()),
+	)
+	return kept
+}
+
+// saveSourceProduct records the source listing of a candidate and how it was matched.
+// Listings without an identifier or URL cannot be keyed and are skipped.
+func (p *Processor) saveSourceProduct(ctx context.Context, candidate providers.ProductCandidate, sourceName string, product *models.Product, matchMethod string, matchConfidence float64) {
+	sourceID, sourceURL := listingKey(candidate)
+	if sourceID == "" {
+		return
+	}
+	title := candidate.Title
+	sp := &models.SourceProduct{
+		ProductID:       product.ID,
+		Provider:        sourceName,
+		SourceID:        sourceID,
+		URL:             sourceURL,
+		Title:           &title,
+		Brand:           candidate.Brand,
+		ImageURL:        candidate.ImageURL,
+		RawJSON:         candidate.Raw,
+		SchemaVersion:   candidate.SchemaVersion,
+		MatchMethod:     matchMethod,
+		MatchConfidence: matchConfidence,
+	}
+	// Providers report the language when they know it (page lang, configured locale)
+	language := candidate.Language
+	if language == "" {
+		language = locale.Detect(candidate.Title)
+	}
+	if language != "" {
+		sp.Language = &language
+		sp.Titles = p.localizedTitles(ctx, sourceName, sourceID, language, title)
+	}
+	if candidate.Snapshot != nil {
+		sp.SnapshotKey = &candidate.Snapshot.Key
+		sp.SnapshotAt = &candidate.Snapshot.FetchedAt
+	}
+	if err := p.sourceProductRepo.Upsert(ctx, sp); err != nil {
+		p.logger.Warn("Failed to upsert source product",
+			zap.String("source", sourceName),
+			zap.String("source_id", sourceID),
+			zap.Error(err),
+		)
+	}
+}
+
+// listingKey returns the source_id and canonical URL of a candidate's listing. Listings
+// without a provider ID are keyed by their canonical URL; without either the ID is "".
+// A provider ID shared by several merchants is qualified by the candidate's seller.
+func listingKey(candidate providers.ProductCandidate) (sourceID, sourceURL string) {
+	if candidate.SourceURL != nil {
+		sourceURL = canonicalurl.Canonicalize(*candidate.SourceURL)
+	}
+	sourceID = sourceURL
+	if candidate.Identifier != nil && *candidate.Identifier != "" {
+		sourceID = *candidate.Identifier
+		if candidate.Seller != "" {
+			sourceID += ":" + candidate.Seller
+		}
+	}
+	return sourceID, sourceURL
+}
+
+// localizedTitles returns the titles of a listing in language: its own title and, with
+// translation enabled, the translation into the other of Japanese and English. A failed
+// translation is retried on the next fetch.
+func (p *Processor) localizedTitles(ctx context.Context, sourceName, sourceID, language, title string) models.LocalizedTitles {
+	titles := models.LocalizedTitles{language: title}
+	var target string
+	switch language {
+	case locale.Japanese:
+		target = locale.English
+	case locale.English:
+		target = locale.Japanese
+	}
+	if p.translator == nil || target == "" {
+		return titles
+	}
+
+	existing, err := p.sourceProductRepo.FindByProviderAndSourceID(ctx, sourceName, sourceID)
+	if err != nil {
+		p.logger.Warn("Failed to find source product", zap.Error(err))
+	}
+	if existing != nil && existing.Title != nil && *existing.Title == title && existing.Titles[target] != "" {
+		// Kept by Upsert
+		return titles
+	}
+	translated, err := p.translator.Translate(ctx, title, language, target)
+	if err != nil {
+		p.logger.Warn("Failed to translate listing title",
+			zap.String("translator", p.translator.Name()),
+			zap.String("source", sourceName),
+			zap.String("source_id", sourceID),
+			zap.Error(err),
+		)
+		return titles
+	}
+	titles[target] = translated
+	return titles
+}
+
+// findSimilarProduct returns the best-scoring product (see matching.Score) among those
+// with a similar title, or nil if none scores at least the match score threshold. The
+// similarity of the result is its score.
+func (p *Processor) findSimilarProduct(ctx context.Context, candidate providers.ProductCandidate, identifiers []providers.CandidateIdentifier) *repository.ProductSimilarity {
+	// Compared like matching.Score does, so "WH-1000XM4" and full-width "ＷＨ１０００ＸＭ４"
+	// reach the scoring of a "WH1000XM4" product
+	matches, err := p.productRepo.FindSimilarByTitle(ctx, matching.NormalizeTitle(candidate.Title), similarTitlePrefilter, similarTitleCandidates)
+	if err != nil {
+		p.logger.Warn("Failed to find similar products", zap.Error(err))
+		return nil
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	productIDs := make([]uuid.UUID, len(matches))
+	for i, match := range matches {
+		productIDs[i] = match.Product.ID
+	}
+	productIdentifiers, err := p.identifierRepo.ListByProductIDs(ctx, productIDs)
+	if err != nil {
+		p.logger.Warn("Failed to load product identifiers", zap.Error(err))
+	}
+
+	listing := matching.Listing{Title: candidate.Title, Brand: candidate.Brand, Model: candidate.Model}
+	for _, identifier := range identifiers {
+		listing.Identifiers = append(listing.Identifiers, matching.Identifier{Type: identifier.Type, Value: identifier.Value})
+	}
+
+	var best *repository.ProductSimilarity
+	for _, match := range matches {
+		product := matching.Listing{Title: match.Product.Title, Brand: match.Product.Brand, Model: match.Product.Model}
+		for _, identifier := range productIdentifiers[match.Product.ID] {
+			product.Identifiers = append(product.Identifiers, matching.Identifier{Type: identifier.Type, Value: identifier.Value})
+		}
+		score := matching.Score(listing, product)
+		if score >= p.matchScoreThreshold && (best == nil || score > best.Similarity) {
+			best = &repository.ProductSimilarity{Product: match.Product, Similarity: score}
+		}
+	}
+	if best != nil {
+		p.logger.Info("Found existing product by similar title",
+			zap.String("title", candidate.Title),
+			zap.String("matched_title", best.Product.Title),
+			zap.Float64("score", best.Similarity),
+			zap.String("product_id", best.Product.ID.String()),
+		)
+	}
+	return best
+}
+
+// findByEmbedding returns the nearest product by title embedding whose similarity is above
+// the threshold and whose brand and model agree with the candidate. The candidate's title
+// embedding is returned as well (nil if it could not be computed) so it can be stored.
+func (p *Processor) findByEmbedding(ctx context.Context, candidate providers.ProductCandidate) (*repository.ProductSimilarity, []float32) {
+	vectors, err := p.embedder.Embed(ctx, []string{candidate.Title})
+	if err != nil {
+		p.logger.Warn("Failed to embed candidate title", zap.Error(err))
+		return nil, nil
+	}
+	vector := vectors[0]
+
+	matches, err := p.embeddingRepo.FindNearest(ctx, p.embedder.Model(), vector, 5)
+	if err != nil {
+		p.logger.Warn("Failed to find nearest products", zap.Error(err))
+		return nil, vector
+	}
+	for _, match := range matches {
+		if match.Similarity < p.embeddingThreshold {
+			break // sorted by similarity
+		}
+		if !matching.AttributesAgree(candidate.Brand, candidate.Model, match.Product.Brand, match.Product.Model) {
+			continue
+		}
+		p.logger.Info("Found existing product by title embedding",
+			zap.String("title", candidate.Title),
+			zap.String("matched_title", match.Product.Title),
+			zap.Float64("similarity", match.Similarity),
+			zap.String("product_id", match.Product.ID.String()),
+		)
+		return match, vector
+	}
+	return nil, vector
+}
+
+// hashImage returns the perceptual hashes of an image, reusing stored hashes of the same
+// URL. It returns nil if the image cannot be downloaded or decoded.
+func (p *Processor) hashImage(ctx context.Context, imageURL, sourceName string) *imagehash.Hashes {
+	hash, ok, err := p.imageRepo.FindHashByURL(ctx, imageURL)
+	if err != nil {
+		p.logger.Warn("Failed to lookup image hash", zap.Error(err))
+	}
+	if ok {
+		return &hash
+	}
+
+	hash, err = p.imageHasher.HashURL(ctx, sourceName, imageURL)
+	if err != nil {
+		p.logger.Warn("Failed to hash product image",
+			zap.String("image_url", imageURL),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return &hash
+}
+
+// findByImageHash returns the product with the closest image hash within the maximum
+// distance whose brand and model agree with the candidate. Variants often share a photo,
+// so the attribute check matters more here than for title matches.
+func (p *Processor) findByImageHash(ctx context.Context, candidate providers.ProductCandidate, hash imagehash.Hashes) *repository.ProductImageMatch {
+	matches, err := p.imageRepo.FindNearest(ctx, hash, p.imageMaxDistance, 5)
+	if err != nil {
+		p.logger.Warn("Failed to find products by image hash", zap.Error(err))
+		return nil
+	}
+	for _, match := range matches {
+		if !matching.AttributesAgree(candidate.Brand, candidate.Model, match.Product.Brand, match.Product.Model) {
+			continue
+		}
+		p.logger.Info("Found existing product by image hash",
+			zap.String("title", candidate.Title),
+			zap.String("matched_title", match.Product.Title),
+			zap.Int("distance", match.Distance),
+			zap.String("product_id", match.Product.ID.String()),
+		)
+		return match
+	}
+	return nil
+}
+
+// PriceOffer fills in shipping, fee line items, totals, duty and landed cost for an offer.
+// Non-USD prices are converted to USD for totals; the FX markup becomes a fee line item.
+// The fetch job prices every fetched offer with it, and the admin API manual offers.
+func PriceOffer(calc *shipping.Calculator, offer *models.Offer, productCategory string) error {
+	priceUSD, fxMarkup, err := calc.ConvertToUSD(offer.PriceAmount, offer.Currency)
+	if err != nil {
+		return err
+	}
+
+	// Offers that ship free (provider-reported or per-source threshold) pay no carrier shipping
+	offer.ShippingToUSAmount, offer.FreeShipping = calc.CalculateOfferShipping(offer.Source, productCategory, priceUSD, offer.FreeShipping)
+
+	offer.FeeItems = models.FeeItems{}
+	for _, line := range calc.ApplyFeeRules(offer.Source, productCategory, priceUSD) {
+		feeType := models.FeeTypeService
+		if line.Type == shipping.FeeLineDiscount {
+			feeType = models.FeeTypeDiscount
+		}
+		offer.FeeItems = append(offer.FeeItems, models.FeeItem{Type: feeType, Label: line.Label, Amount: line.Amount})
+	}
+	if fxMarkup > 0 {
+		offer.FeeItems = append(offer.FeeItems, models.FeeItem{Type: models.FeeTypeFXMarkup, Amount: fxMarkup})
+	}
+	offer.FeeAmount = offer.FeeItems.Total()
+	offer.TotalToUSAmount = priceUSD + offer.ShippingToUSAmount + offer.FeeAmount
+
+	// Estimate import duty for cross-border offers
+	originCountry := ""
+	if offer.ShipsFromCountry != nil {
+		originCountry = *offer.ShipsFromCountry
+	}
+	offer.DutyAmount = calc.EstimateDuty(priceUSD, productCategory, originCountry)
+	offer.LandedCostAmount = calc.CalculateLandedCost(offer.TotalToUSAmount, offer.DutyAmount)
+
+	fxRate, err := calc.FXRate(offer.Currency)
+	if err != nil {
+		return err
+	}
+	tax := 0
+	if offer.TaxAmount != nil {
+		tax = *offer.TaxAmount
+	}
+	offer.CostBreakdown = &models.CostBreakdown{
+		ItemAmount:       priceUSD,
+		ShippingAmount:   offer.ShippingToUSAmount,
+		FeeAmount:        offer.FeeAmount,
+		TaxAmount:        tax,
+		DutyAmount:       offer.DutyAmount,
+		TotalAmount:      offer.TotalToUSAmount,
+		LandedCostAmount: offer.LandedCostAmount,
+		OriginalAmount:   offer.PriceAmount,
+		OriginalCurrency: offer.Currency,
+		FXRate:           fxRate,
+	}
+	return nil
+}
+
+// recordPriceChange stores the price history entry of a refreshed offer whose price
+// differs from the previous fetch
+func (p *Processor) recordPriceChange(ctx context.Context, previous, current *models.Offer) {
+	if current.PriceAmount == previous.PriceAmount && current.Currency == previous.Currency {
+		return
+	}
+	change := &models.OfferPriceChange{
+		OfferID:            current.ID,
+		ProductID:          current.ProductID,
+		Source:             current.Source,
+		Seller:             current.Seller,
+		OldPriceAmount:     previous.PriceAmount,
+		NewPriceAmount:     current.PriceAmount,
+		Currency:           current.Currency,
+		OldTotalToUSAmount: previous.TotalToUSAmount,
+		NewTotalToUSAmount: current.TotalToUSAmount,
+	}
+	if err := p.priceChangeRepo.Record(ctx, change); err != nil {
+		p.logger.Warn("Failed to record price change",
+			zap.String("offer_id", current.ID.String()),
+			zap.Error(err),
+		)
+	}
+}
+
+// recordStockChange stores the stock history entry of a refreshed offer that went in or
+// out of stock since the previous fetch
+func (p *Processor) recordStockChange(ctx context.Context, previous, current *models.Offer) {
+	if p.stockEventRepo == nil || current.InStock == previous.InStock {
+		return
+	}
+	event := &models.StockEvent{
+		OfferID:            current.ID,
+		ProductID:          current.ProductID,
+		Source:             current.Source,
+		Seller:             current.Seller,
+		InStock:            current.InStock,
+		AvailabilityStatus: current.AvailabilityStatus,
+	}
+	if err := p.stockEventRepo.Record(ctx, event); err != nil {
+		p.logger.Warn("Failed to record stock change",
+			zap.String("offer_id", current.ID.String()),
+			zap.Error(err),
+		)
+	}
+}
+
+// saveShippingOptions stores economy/standard/express options for a saved offer
+func (p *Processor) saveShippingOptions(ctx context.Context, offer *models.Offer, productCategory string) error {
+	priceUSD, _, err := p.shippingCalc.ConvertToUSD(offer.PriceAmount, offer.Currency)
+	if err != nil {
+		return err
+	}
+
+	calculated := p.shippingCalc.CalculateOptions(
+		offer.Source,
+		productCategory,
+		priceUSD,
+		offer.FreeShipping,
+		offer.EstDeliveryDaysMin,
+		offer.EstDeliveryDaysMax,
+	)
+
+	options := make([]*models.OfferShippingOption, 0, len(calculated))
+	for _, option := range calculated {
+		daysMin, daysMax := option.DaysMin, option.DaysMax
+		options = append(options, &models.OfferShippingOption{
+			Speed:              option.Speed,
+			CostAmount:         option.CostCents,
+			EstDeliveryDaysMin: &daysMin,
+			EstDeliveryDaysMax: &daysMax,
+		})
+	}
+	return p.shippingOptionRepo.ReplaceForOffer(ctx, offer.ID, options)
+}
+
+// normalizeCategory maps a provider category label to a catalog category slug
+func normalizeCategory(label *string) *string {
+	if label == nil {
+		return nil
+	}
+	slug := category.Normalize(*label)
+	if slug == "" {
+		return nil
+	}
+	return &slug
+}
+
 // getIdentifierType returns the identifier type for a given source
 func getIdentifierType(sourceName string) string {
 	switch sourceName {
@@ -296,8 +1376,13 @@ func getIdentifierType(sourceName string) string {
 		return "itemId" // Walmart itemId
 	case "amazon":
 		return "ASIN" // Amazon ASIN
+	case "rakuten":
+		return "itemCode" // Rakuten Ichiba itemCode ("<shop>:<item>")
+	case "aliexpress":
+		return "productId" // AliExpress product ID
+	case "google_shopping":
+		return "googleProductId" // Google Shopping product ID, shared by its merchants
 	default:
 		return "" // Unknown source
 	}
 }
-
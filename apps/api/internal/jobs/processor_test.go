@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+	"github.com/pricecompare/api/internal/shipping"
+)
+
+// newTestProcessor builds a processor over the in-memory repositories that fetches from
+// the providers in manager
+func newTestProcessor(t *testing.T, store *memory.Store, manager *providers.Manager) *Processor {
+	t.Helper()
+	return NewProcessor(
+		store.Products(), store.Offers(), store.ProductIdentifiers(), store.SourceProducts(),
+		store.MergeCandidates(), store.OfferShippingOptions(), store.ProviderFetches(), store.OfferPriceChanges(),
+		manager, shipping.NewCalculator(shipping.Config{Mode: "TABLE", FXUSDJPY: 150}), 0.85, 0, zap.NewNop(),
+	)
+}
+
+// demoProviders registers provider as the demo source
+func demoProviders(provider providers.Provider) *providers.Manager {
+	manager := providers.NewManager()
+	manager.Register("demo", provider)
+	return manager
+}
+
+func TestHandleFetchPricesMatchesNormalizedTitles(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "SEL-24-70-GM"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	// The raw titles share too few trigrams for the prefilter
+	processor := newTestProcessor(t, store, demoProviders(&titleProvider{title: "ＳＥＬ２４７０ＧＭ"}))
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+	if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+		t.Fatalf("HandleFetchPrices() error = %v", err)
+	}
+
+	products, _, err := store.Products().Search(ctx, "", "", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].ID != product.ID {
+		t.Errorf("products = %v, want the listing matched to %s", products, product.ID)
+	}
+}
+
+func TestCandidateIdentifiers(t *testing.T) {
+	asin := "B0BXYCS74H"
+	candidate := providers.ProductCandidate{
+		Identifier: &asin,
+		ExternalIdentifiers: []providers.CandidateIdentifier{
+			{Type: "UPC", Value: "027242923782"},
+			{Type: "EAN", Value: ""},
+		},
+	}
+
+	got := candidateIdentifiers(candidate, "amazon")
+	want := []providers.CandidateIdentifier{{Type: "ASIN", Value: asin}, {Type: "UPC", Value: "027242923782"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("candidateIdentifiers() = %+v, want %+v", got, want)
+	}
+	// Sources without an identifier type only contribute the external identifiers
+	if got := candidateIdentifiers(candidate, "live"); len(got) != 1 || got[0] != want[1] {
+		t.Errorf("candidateIdentifiers() of live = %+v, want the UPC only", got)
+	}
+}
+
+func TestLinkIdentifiersMergesProductsSharingAnIdentifier(t *testing.T) {
+	ctx := context.Background()
+	identifiers := []providers.CandidateIdentifier{{Type: "ASIN", Value: "B0BXYCS74H"}, {Type: "UPC", Value: "027242923782"}}
+
+	for _, dismissed := range []bool{false, true} {
+		store := memory.New()
+		processor := newTestProcessor(t, store, providers.NewManager())
+		// older was created from a Walmart listing with the UPC, newer from the Amazon
+		// listing reporting both its ASIN and the UPC
+		older, newer := &models.Product{Title: "Sony WH-1000XM5"}, &models.Product{Title: "Sony WH1000XM5 Headphones"}
+		for _, product := range []*models.Product{older, newer} {
+			if err := store.Products().Create(ctx, product); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.ProductIdentifiers().Create(ctx, &models.ProductIdentifier{ProductID: older.ID, Type: "UPC", Value: "027242923782"}); err != nil {
+			t.Fatal(err)
+		}
+		if dismissed {
+			candidate := &models.MergeCandidate{ProductID: older.ID, DuplicateProductID: newer.ID, Reason: models.MergeReasonTitle}
+			if err := store.MergeCandidates().UpsertPending(ctx, candidate); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.MergeCandidates().Dismiss(ctx, candidate.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		kept := processor.linkIdentifiers(ctx, newer, identifiers)
+
+		_, asinOwner, _ := store.ProductIdentifiers().FindByTypeAndValue(ctx, "ASIN", "B0BXYCS74H")
+		remaining, _ := store.Products().GetByID(ctx, newer.ID)
+		if dismissed {
+			// An admin said the pair differs, so both products stay
+			if kept.ID != newer.ID || remaining == nil || asinOwner == nil || asinOwner.ID != newer.ID {
+				t.Errorf("dismissed pair: kept %v, newer %v, ASIN owner %v, want both products unchanged", kept.ID, remaining, asinOwner)
+			}
+			continue
+		}
+		if kept.ID != older.ID || remaining != nil {
+			t.Errorf("kept %v (newer still exists: %v), want the older product %v", kept.ID, remaining != nil, older.ID)
+		}
+		if asinOwner == nil || asinOwner.ID != older.ID {
+			t.Errorf("ASIN owner = %v, want the older product", asinOwner)
+		}
+		merged, _ := store.MergeCandidates().ListByStatus(ctx, models.MergeStatusMerged, 10)
+		if len(merged) != 1 || merged[0].Reason != models.MergeReasonIdentifier || merged[0].Detail == nil || *merged[0].Detail != "UPC:027242923782" {
+			t.Errorf("merged candidates = %+v, want one identifier merge on the UPC", merged)
+		}
+	}
+}
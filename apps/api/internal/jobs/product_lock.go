@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// productLockTTL bounds how long a worker that died while holding a product lock
+	// keeps the product blocked. A live worker renews it every productLockTTL/3.
+	productLockTTL = 5 * time.Minute
+	// productLockWait is how long a job waits for another job to finish a product
+	productLockWait = time.Minute
+	// productLockRetry is the polling interval while waiting for a Redis lock
+	productLockRetry = 100 * time.Millisecond
+)
+
+// ErrProductLocked is returned when another job kept a product locked for longer than
+// productLockWait
+var ErrProductLocked = errors.New("product is being processed by another job")
+
+// ProductLocker serializes the offer refreshes of one product, so two fetch_prices runs
+// (e.g. a scheduled and a manually triggered one) do not interleave their reads, upserts
+// and delistings of its offers
+type ProductLocker interface {
+	// Lock blocks until it holds the lock of productID or ctx ends, and returns the
+	// function releasing it
+	Lock(ctx context.Context, productID uuid.UUID) (unlock func(), err error)
+}
+
+// RedisProductLocker implements ProductLocker across workers with SET NX and a TTL that
+// is renewed while the lock is held. Each lock holds a random token, so a worker whose
+// lock expired cannot renew or release the lock another worker took over.
+type RedisProductLocker struct {
+	client *redis.Client
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewRedisProductLocker(client *redis.Client, logger *zap.Logger) *RedisProductLocker {
+	return &RedisProductLocker{client: client, ttl: productLockTTL, logger: logger}
+}
+
+// redisUnlockScript deletes a lock only if it still holds the caller's token
+var redisUnlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// redisRenewScript extends a lock's TTL (ARGV[2] milliseconds) only if it still holds
+// the caller's token
+var redisRenewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (l *RedisProductLocker) Lock(ctx context.Context, productID uuid.UUID) (func(), error) {
+	key := "lock:product:" + productID.String()
+	token := uuid.NewString()
+	ticker := time.NewTicker(productLockRetry)
+	defer ticker.Stop()
+	for {
+		acquired, err := l.client.SetNX(ctx, key, token, l.ttl).Result()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+		if acquired {
+			renewCtx, stopRenewal := context.WithCancel(context.Background())
+			renewed := make(chan struct{})
+			go func() {
+				defer close(renewed)
+				l.renew(renewCtx, key, token)
+			}()
+			return func() {
+				stopRenewal()
+				<-renewed
+				// The job's context may be done; the lock expires anyway if this fails
+				unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := redisUnlockScript.Run(unlockCtx, l.client, []string{key}, token).Err(); err != nil {
+					l.logger.Warn("Failed to release product lock", zap.String("key", key), zap.Error(err))
+				}
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew extends the lock's TTL every third of it until ctx ends or the lock is lost
+func (l *RedisProductLocker) renew(ctx context.Context, key, token string) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, err := redisRenewScript.Run(ctx, l.client, []string{key}, token, l.ttl.Milliseconds()).Int()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// The lock is still valid for the rest of its TTL, so retry on the next tick
+			l.logger.Warn("Failed to renew product lock", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		if held == 0 {
+			l.logger.Warn("Product lock expired before the job released it", zap.String("key", key))
+			return
+		}
+	}
+}
+
+// LocalProductLocker implements ProductLocker within one process, for QUEUE_MODE=inline
+// where all jobs run in the server process
+type LocalProductLocker struct {
+	mu   sync.Mutex
+	held map[uuid.UUID]chan struct{} // closed on release
+}
+
+func NewLocalProductLocker() *LocalProductLocker {
+	return &LocalProductLocker{held: make(map[uuid.UUID]chan struct{})}
+}
+
+func (l *LocalProductLocker) Lock(ctx context.Context, productID uuid.UUID) (func(), error) {
+	for {
+		l.mu.Lock()
+		released, busy := l.held[productID]
+		if !busy {
+			released = make(chan struct{})
+			l.held[productID] = released
+			l.mu.Unlock()
+			return func() {
+				l.mu.Lock()
+				delete(l.held, productID)
+				l.mu.Unlock()
+				close(released)
+			}, nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// lockProduct takes the product's lock if locking is enabled, waiting at most
+// productLockWait. The returned function releases it.
+func (p *Processor) lockProduct(ctx context.Context, productID uuid.UUID) (func(), error) {
+	if p.productLocker == nil {
+		return func() {}, nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, productLockWait)
+	defer cancel()
+	unlock, err := p.productLocker.Lock(waitCtx, productID)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrProductLocked
+		}
+		return nil, err
+	}
+	return unlock, nil
+}
@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestLocalProductLocker(t *testing.T) {
+	locker := NewLocalProductLocker()
+	productID := uuid.New()
+	unlock, err := locker.Lock(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other products are not blocked
+	unlockOther, err := locker.Lock(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, productID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock() of a held product error = %v, want DeadlineExceeded", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := locker.Lock(context.Background(), productID)
+		if err == nil {
+			unlock()
+		}
+		close(acquired)
+	}()
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiting Lock() was not woken by the release")
+	}
+}
+
+func TestRedisProductLocker(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	core, logs := observer.New(zap.WarnLevel)
+	locker := NewRedisProductLocker(client, zap.New(core))
+	locker.ttl = 150 * time.Millisecond
+	productID := uuid.New()
+	key := "lock:product:" + productID.String()
+
+	unlock, err := locker.Lock(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, productID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock() of a held product error = %v, want DeadlineExceeded", err)
+	}
+
+	// miniredis only expires keys on FastForward, so a TTL back at its full length
+	// shows the lock was renewed
+	server.SetTTL(key, time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for server.TTL(key) != locker.ttl {
+		if time.Now().After(deadline) {
+			t.Fatalf("TTL = %v, want the held lock renewed to %v", server.TTL(key), locker.ttl)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	unlock()
+	if server.Exists(key) {
+		t.Error("lock still held after unlock")
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %v, want no warnings", logs.All())
+	}
+
+	// A lock another worker took over after expiry is neither renewed nor released
+	unlock, err = locker.Lock(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Set(key, "other")
+	deadline = time.Now().Add(time.Second)
+	for logs.FilterMessage("Product lock expired before the job released it").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("lost lock was not reported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	unlock()
+	if got, _ := server.Get(key); got != "other" {
+		t.Errorf("lock = %q after unlock, want the other worker's", got)
+	}
+
+	// Release failures are logged
+	server.Del(key)
+	unlock, err = locker.Lock(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	unlock()
+	if logs.FilterMessage("Failed to release product lock").Len() != 1 {
+		t.Errorf("logged %v, want the failed release", logs.All())
+	}
+}
+
+// slowOfferProvider returns one product and counts how many offer fetches of it overlap
+type slowOfferProvider struct {
+	active     atomic.Int32
+	maxOverlap atomic.Int32
+}
+
+func (p *slowOfferProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	return []providers.ProductCandidate{{Title: "Nintendo Switch OLED Model"}}, nil
+}
+
+func (p *slowOfferProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	active := p.active.Add(1)
+	defer p.active.Add(-1)
+	for {
+		overlap := p.maxOverlap.Load()
+		if active <= overlap || p.maxOverlap.CompareAndSwap(overlap, active) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return []*models.Offer{{ProductID: product.ID, Source: "demo", Seller: "demo store", PriceAmount: 34999, Currency: "USD", InStock: true}}, nil
+}
+
+func TestHandleFetchPricesLocksProducts(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	// The product exists up front, so both runs match it instead of creating one each
+	if err := store.Products().Create(ctx, &models.Product{Title: "Nintendo Switch OLED Model"}); err != nil {
+		t.Fatal(err)
+	}
+	provider := &slowOfferProvider{}
+	processor := newTestProcessor(t, store, demoProviders(provider))
+	processor.EnableProductLocks(NewLocalProductLocker())
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+				t.Errorf("HandleFetchPrices() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if overlap := provider.maxOverlap.Load(); overlap != 1 {
+		t.Errorf("up to %d offer fetches of one product overlapped, want 1", overlap)
+	}
+}
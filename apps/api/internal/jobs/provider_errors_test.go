@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// failingProvider fails its first searches with errs[i] and then finds nothing
+type failingProvider struct {
+	errs     []error
+	searches int
+}
+
+func (p *failingProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	p.searches++
+	if p.searches <= len(p.errs) {
+		return nil, p.errs[p.searches-1]
+	}
+	return nil, nil
+}
+
+func (p *failingProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	return nil, nil
+}
+
+func TestHandleFetchPricesProviderErrors(t *testing.T) {
+	defer func(backoff time.Duration) { rateLimitBackoff = backoff }(rateLimitBackoff)
+	rateLimitBackoff = time.Millisecond
+
+	queries := len(DefaultSearchQueries["demo"])
+	tests := []struct {
+		name string
+		errs []error
+		want int // searches
+	}{
+		{"not enabled stops the provider", []error{fmt.Errorf("%w: demo", providers.ErrNotEnabled)}, 1},
+		{"rejected credentials stop the provider", []error{providers.StatusError(403, "forbidden")}, 1},
+		{"rate limited search is retried", []error{providers.StatusError(429, "too many requests")}, queries + 1},
+		{"unparsable response skips the query", []error{fmt.Errorf("%w: bad json", providers.ErrParse)}, queries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			provider := &failingProvider{errs: tt.errs}
+			processor := newTestProcessor(t, store, demoProviders(provider))
+
+			data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+			if err := processor.HandleFetchPrices(context.Background(), asynq.NewTask(TypeFetchPrices, data)); err != nil {
+				t.Fatalf("HandleFetchPrices() error = %v", err)
+			}
+			if provider.searches != tt.want {
+				t.Errorf("searches = %d, want %d", provider.searches, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// providerFetchRetention is how long provider call outcomes are kept for the stats endpoint
+const providerFetchRetention = 30 * 24 * time.Hour
+
+// recordingProvider records the outcome and result count of each Search and FetchOffers
+// call in provider_fetches, for the per-provider error rate in the admin stats and the
+// zero-result providers in the catalog report, and feeds it to the provider's circuit
+// breaker
+type recordingProvider struct {
+	providers.Provider
+	sourceName string
+	repo       repository.ProviderFetchStore
+	manager    *providers.Manager
+	logger     *zap.Logger
+}
+
+func (r *recordingProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	start := time.Now()
+	candidates, err := r.Provider.Search(ctx, query)
+	r.record(ctx, models.ProviderOperationSearch, time.Since(start), len(candidates), err)
+	return candidates, err
+}
+
+func (r *recordingProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	start := time.Now()
+	offers, err := r.Provider.FetchOffers(ctx, product, listingURL)
+	r.record(ctx, models.ProviderOperationFetchOffers, time.Since(start), len(offers), err)
+	return offers, err
+}
+
+func (r *recordingProvider) record(ctx context.Context, operation string, duration time.Duration, resultCount int, callErr error) {
+	// A cancelled job is not a provider failure
+	if callErr != nil && ctx.Err() != nil {
+		return
+	}
+	r.manager.RecordOutcome(r.sourceName, callErr)
+	if err := r.repo.Record(ctx, r.sourceName, operation, duration, resultCount, callErr); err != nil {
+		r.logger.Warn("Failed to record provider fetch",
+			zap.String("source", r.sourceName),
+			zap.String("operation", operation),
+			zap.Error(err),
+		)
+	}
+}
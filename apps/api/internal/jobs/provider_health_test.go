@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+func TestHandleFetchPricesSkipsOpenCircuits(t *testing.T) {
+	store := memory.New()
+	failing := &failingProvider{errs: []error{errors.New("connection refused"), errors.New("connection refused"), errors.New("connection refused")}}
+	healthy := &queryRecorder{}
+	manager := providers.NewManager()
+	manager.Register("live", failing)
+	manager.Register("demo", healthy)
+	manager.EnableCircuitBreaker(providers.NewBreaker(3, time.Hour))
+	processor := newTestProcessor(t, store, manager)
+	run := func(source string) {
+		data, _ := json.Marshal(FetchPricesPayload{Source: source})
+		if err := processor.HandleFetchPrices(context.Background(), asynq.NewTask(TypeFetchPrices, data)); err != nil {
+			t.Fatalf("HandleFetchPrices(%s) error = %v", source, err)
+		}
+	}
+
+	// Three failed searches open the circuit of live
+	run("all")
+	if status := manager.Status("live"); status.Status != providers.StatusCircuitOpen {
+		t.Fatalf("live status = %+v, want circuit_open", status)
+	}
+	searches, demoSearches := failing.searches, len(healthy.queries)
+
+	run("all")
+	if failing.searches != searches {
+		t.Errorf("fan-out searched live %d more times while its circuit is open", failing.searches-searches)
+	}
+	if len(healthy.queries) != 2*demoSearches {
+		t.Errorf("demo searched %d times, want %d", len(healthy.queries), 2*demoSearches)
+	}
+
+	// A run of live alone still calls it
+	run("live")
+	if failing.searches == searches {
+		t.Error("single-source run did not search live")
+	}
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+)
+
+// SetProviderTimeouts bounds each Search and FetchOffers call of a provider, keyed by
+// source name ("*" for the others). The HTTP timeout only bounds a single request, so a
+// provider making many requests per call (pagination, retries, offer lookups) could
+// otherwise use up the time of the whole job. Zero or missing means no deadline.
+func (p *Processor) SetProviderTimeouts(timeouts map[string]time.Duration) {
+	p.providerTimeouts = timeouts
+}
+
+// providerTimeout returns the deadline of one call of a provider
+func (p *Processor) providerTimeout(sourceName string) time.Duration {
+	if timeout, ok := p.providerTimeouts[sourceName]; ok {
+		return timeout
+	}
+	return p.providerTimeouts["*"]
+}
+
+// timeoutProvider runs each Search and FetchOffers call under its own deadline
+type timeoutProvider struct {
+	providers.Provider
+	sourceName string
+	timeout    time.Duration
+}
+
+func (t *timeoutProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	candidates, err := t.Provider.Search(callCtx, query)
+	return candidates, t.wrap(ctx, callCtx, models.ProviderOperationSearch, err)
+}
+
+func (t *timeoutProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	offers, err := t.Provider.FetchOffers(callCtx, product, listingURL)
+	return offers, t.wrap(ctx, callCtx, models.ProviderOperationFetchOffers, err)
+}
+
+// wrap names the provider deadline in errors caused by it, so they are not mistaken for
+// a cancelled job
+func (t *timeoutProvider) wrap(ctx, callCtx context.Context, operation string, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%s %s exceeded the provider timeout of %s: %w", t.sourceName, operation, t.timeout, err)
+}
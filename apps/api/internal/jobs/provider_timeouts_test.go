@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// hangingProvider blocks every call until its context is done
+type hangingProvider struct {
+	searches int
+}
+
+func (p *hangingProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	p.searches++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *hangingProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutProvider(t *testing.T) {
+	provider := &timeoutProvider{Provider: &hangingProvider{}, sourceName: "live", timeout: 10 * time.Millisecond}
+
+	_, err := provider.Search(context.Background(), "headphones")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Search() error = %v, want context.DeadlineExceeded", err)
+	}
+	if want := "live search exceeded the provider timeout of 10ms"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Search() error = %q, want prefix %q", err, want)
+	}
+
+	// A cancelled job is reported as such, not as a provider timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.FetchOffers(ctx, &models.Product{}, "")
+	if err != context.Canceled {
+		t.Errorf("FetchOffers() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestHandleFetchPricesProviderTimeouts(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	hanging := &hangingProvider{}
+	manager := providers.NewManager()
+	manager.Register("live", hanging)
+	processor := newTestProcessor(t, store, manager)
+	processor.SetProviderTimeouts(map[string]time.Duration{"live": 10 * time.Millisecond, "*": time.Hour})
+
+	data, _ := json.Marshal(FetchPricesPayload{Source: "live"})
+	done := make(chan error, 1)
+	go func() { done <- processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("HandleFetchPrices() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("HandleFetchPrices() did not stop the hanging provider")
+	}
+
+	// Every query still runs, each under its own deadline
+	if want := len(DefaultSearchQueries["live"]); hanging.searches != want {
+		t.Errorf("searches = %d, want %d", hanging.searches, want)
+	}
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+)
+
+// QueueStats is a snapshot of one job queue, reported by the deep health check
+type QueueStats struct {
+	Queue                string  `json:"queue"`
+	Pending              int     `json:"pending"`
+	Active               int     `json:"active"` // tasks being processed
+	Scheduled            int     `json:"scheduled"`
+	Retry                int     `json:"retry"`
+	OldestPendingSeconds float64 `json:"oldest_pending_seconds"` // 0 when nothing is pending
+	Paused               bool    `json:"paused"`
+}
+
+// QueueInspector reports the state of the job queues
+type QueueInspector interface {
+	QueueStats(ctx context.Context) ([]QueueStats, error)
+}
+
+// AsynqInspector reports the asynq queues stored in Redis
+type AsynqInspector struct {
+	inspector *asynq.Inspector
+}
+
+func NewAsynqInspector(inspector *asynq.Inspector) *AsynqInspector {
+	return &AsynqInspector{inspector: inspector}
+}
+
+// QueueStats implements QueueInspector. asynq creates a queue when the first task is
+// enqueued, so a fresh Redis reports no queues.
+func (i *AsynqInspector) QueueStats(ctx context.Context) ([]QueueStats, error) {
+	queues, err := i.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	stats := make([]QueueStats, 0, len(queues))
+	for _, queue := range queues {
+		info, err := i.inspector.GetQueueInfo(queue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue %q: %w", queue, err)
+		}
+		stats = append(stats, QueueStats{
+			Queue:                info.Queue,
+			Pending:              info.Pending,
+			Active:               info.Active,
+			Scheduled:            info.Scheduled,
+			Retry:                info.Retry,
+			OldestPendingSeconds: info.Latency.Seconds(),
+			Paused:               info.Paused,
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// reprocessBatchSize is the number of listings re-parsed per page
+const reprocessBatchSize = 500
+
+// ReprocessReport is the outcome of one reprocess_raw run
+type ReprocessReport struct {
+	Reparsed           int // listings updated to the current schema version
+	Failed             int // listings whose payload the current code could not parse
+	IdentifiersCreated int // identifiers the current code found that were not stored yet
+}
+
+// Reprocessor re-parses the raw provider payloads stored with source products using the
+// providers' current mapping code (providers.RawParser), so parser improvements apply to
+// listings fetched before them without refetching
+type Reprocessor struct {
+	sourceProductRepo repository.SourceProductStore
+	identifierRepo    repository.ProductIdentifierStore
+	providerManager   *providers.Manager
+	logger            *zap.Logger
+}
+
+func NewReprocessor(
+	sourceProductRepo repository.SourceProductStore,
+	identifierRepo repository.ProductIdentifierStore,
+	providerManager *providers.Manager,
+	logger *zap.Logger,
+) *Reprocessor {
+	return &Reprocessor{
+		sourceProductRepo: sourceProductRepo,
+		identifierRepo:    identifierRepo,
+		providerManager:   providerManager,
+		logger:            logger,
+	}
+}
+
+func (r *Reprocessor) HandleReprocessRaw(ctx context.Context, t *asynq.Task) error {
+	var payload ReprocessRawPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	r.logger.Info("Processing reprocess_raw job", zap.String("provider", payload.Provider))
+
+	names := []string{payload.Provider}
+	if payload.Provider == "" || payload.Provider == "all" {
+		names = r.providerManager.List()
+	}
+	var total ReprocessReport
+	for _, name := range names {
+		report, err := r.Run(ctx, name)
+		if err != nil {
+			return err
+		}
+		total.Reparsed += report.Reparsed
+		total.Failed += report.Failed
+		total.IdentifiersCreated += report.IdentifiersCreated
+	}
+
+	r.logger.Info("Completed reprocess_raw job",
+		zap.Int("reparsed", total.Reparsed),
+		zap.Int("failed", total.Failed),
+		zap.Int("identifiers_created", total.IdentifiersCreated),
+	)
+	return nil
+}
+
+// Run re-parses the listings of one provider stored with an older schema version.
+// Providers that do not implement providers.RawParser are skipped.
+func (r *Reprocessor) Run(ctx context.Context, providerName string) (ReprocessReport, error) {
+	var report ReprocessReport
+	provider, err := r.providerManager.Get(providerName)
+	if err != nil {
+		return report, err
+	}
+	parser, ok := provider.(providers.RawParser)
+	if !ok {
+		return report, nil
+	}
+	version := parser.RawSchemaVersion()
+
+	afterID := uuid.Nil
+	for {
+		page, err := r.sourceProductRepo.ListStaleRaw(ctx, providerName, version, afterID, reprocessBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list %s listings: %w", providerName, err)
+		}
+		for _, sp := range page {
+			afterID = sp.ID
+			candidate, err := parser.ParseRaw(sp.RawJSON)
+			if err != nil || candidate == nil {
+				// Left at its version, so a later fix of the parser retries it
+				report.Failed++
+				r.logger.Warn("Failed to re-parse listing",
+					zap.String("provider", providerName),
+					zap.String("source_product_id", sp.ID.String()),
+					zap.Error(err),
+				)
+				continue
+			}
+			if err := r.apply(ctx, sp, candidate, version, &report); err != nil {
+				return report, err
+			}
+		}
+		if len(page) < reprocessBatchSize {
+			return report, nil
+		}
+	}
+}
+
+// apply stores the re-parsed fields of a listing and the identifiers it newly yields
+func (r *Reprocessor) apply(ctx context.Context, sp *models.SourceProduct, candidate *providers.ProductCandidate, version int, report *ReprocessReport) error {
+	title := candidate.Title
+	sp.Title = &title
+	sp.Brand = candidate.Brand
+	sp.ImageURL = candidate.ImageURL
+	if candidate.SourceURL != nil {
+		sp.URL = canonicalurl.Canonicalize(*candidate.SourceURL)
+	}
+	if candidate.Language != "" {
+		sp.Language = &candidate.Language
+	}
+	sp.SchemaVersion = version
+	if err := r.sourceProductRepo.UpdateParsed(ctx, sp); err != nil {
+		return fmt.Errorf("failed to update listing %s: %w", sp.ID, err)
+	}
+	report.Reparsed++
+
+	// Identifiers owned by another product are merged by the next fetch (linkIdentifiers)
+	for _, identifier := range candidateIdentifiers(*candidate, sp.Provider) {
+		_, owner, err := r.identifierRepo.FindByTypeAndValue(ctx, identifier.Type, identifier.Value)
+		if err != nil {
+			r.logger.Warn("Failed to lookup identifier", zap.Error(err))
+			continue
+		}
+		if owner != nil {
+			continue
+		}
+		if err := r.identifierRepo.Create(ctx, &models.ProductIdentifier{
+			ProductID: sp.ProductID,
+			Type:      identifier.Type,
+			Value:     identifier.Value,
+		}); err != nil {
+			r.logger.Warn("Failed to save identifier", zap.Error(err))
+			continue
+		}
+		report.IdentifiersCreated++
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// rawParsingProvider parses payloads like {"name": "...", "brand": "...", "upc": "..."}
+// at schema version 2
+type rawParsingProvider struct {
+	failingProvider
+}
+
+func (p *rawParsingProvider) RawSchemaVersion() int { return 2 }
+
+func (p *rawParsingProvider) ParseRaw(raw []byte) (*providers.ProductCandidate, error) {
+	var item struct {
+		Name  string `json:"name"`
+		Brand string `json:"brand"`
+		UPC   string `json:"upc"`
+	}
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+	if item.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	candidate := &providers.ProductCandidate{Title: item.Name, Source: "walmart", SchemaVersion: 2}
+	if item.Brand != "" {
+		candidate.Brand = &item.Brand
+	}
+	if item.UPC != "" {
+		candidate.ExternalIdentifiers = []providers.CandidateIdentifier{{Type: "UPC", Value: item.UPC}}
+	}
+	return candidate, nil
+}
+
+func TestHandleReprocessRaw(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	product := &models.Product{Title: "Sony Headphones"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	oldTitle := "Sony Headphones"
+	listings := map[string]*models.SourceProduct{
+		"stale":   {RawJSON: []byte(`{"name":"Sony WH-1000XM5","brand":"Sony","upc":"027242923782"}`), SchemaVersion: 1},
+		"broken":  {RawJSON: []byte(`{"title":"renamed field"}`), SchemaVersion: 1},
+		"current": {RawJSON: []byte(`{"name":"Already current"}`), SchemaVersion: 2},
+		"no_raw":  {SchemaVersion: 0},
+	}
+	for sourceID, sp := range listings {
+		sp.ProductID = product.ID
+		sp.Provider = "walmart"
+		sp.SourceID = sourceID
+		sp.URL = "https://www.walmart.com/ip/" + sourceID
+		sp.Title = &oldTitle
+		if err := store.SourceProducts().Upsert(ctx, sp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := providers.NewManager()
+	manager.Register("walmart", &rawParsingProvider{})
+	manager.Register("demo", &failingProvider{}) // no RawParser, skipped
+	reprocessor := NewReprocessor(store.SourceProducts(), store.ProductIdentifiers(), manager, zap.NewNop())
+
+	report, err := reprocessor.Run(ctx, "walmart")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report != (ReprocessReport{Reparsed: 1, Failed: 1, IdentifiersCreated: 1}) {
+		t.Errorf("report = %+v", report)
+	}
+
+	stale, _ := store.SourceProducts().FindByProviderAndSourceID(ctx, "walmart", "stale")
+	if *stale.Title != "Sony WH-1000XM5" || stale.Brand == nil || *stale.Brand != "Sony" || stale.SchemaVersion != 2 {
+		t.Errorf("stale listing = %+v, want re-parsed at version 2", stale)
+	}
+	if stale.URL != "https://www.walmart.com/ip/stale" {
+		t.Errorf("URL = %q, want it kept when the payload has none", stale.URL)
+	}
+	if _, owner, _ := store.ProductIdentifiers().FindByTypeAndValue(ctx, "UPC", "027242923782"); owner == nil || owner.ID != product.ID {
+		t.Errorf("UPC owner = %v, want the listing's product", owner)
+	}
+	broken, _ := store.SourceProducts().FindByProviderAndSourceID(ctx, "walmart", "broken")
+	if *broken.Title != oldTitle || broken.SchemaVersion != 1 {
+		t.Errorf("broken listing = %+v, want it left unchanged", broken)
+	}
+
+	// Everything parsable is current now, so a full run changes nothing
+	data, _ := json.Marshal(ReprocessRawPayload{})
+	if err := reprocessor.HandleReprocessRaw(ctx, asynq.NewTask(TypeReprocessRaw, data)); err != nil {
+		t.Fatalf("HandleReprocessRaw() error = %v", err)
+	}
+	if report, _ := reprocessor.Run(ctx, "walmart"); report.Reparsed != 0 || report.Failed != 1 {
+		t.Errorf("second run report = %+v, want only the broken listing retried", report)
+	}
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/pricecompare/api/internal/repository"
+	"github.com/pricecompare/api/internal/searchindex"
+)
+
+// reindexBatchSize is the number of products read and indexed per request in a full reindex
+const reindexBatchSize = 500
+
+// SearchIndexer mirrors products and their cheapest offer into the search index
+type SearchIndexer struct {
+	productRepo repository.ProductStore
+	index       searchindex.Index
+	logger      *zap.Logger
+}
+
+func NewSearchIndexer(productRepo repository.ProductStore, index searchindex.Index, logger *zap.Logger) *SearchIndexer {
+	return &SearchIndexer{
+		productRepo: productRepo,
+		index:       index,
+		logger:      logger,
+	}
+}
+
+// IndexProducts refreshes the documents of the given products
+func (s *SearchIndexer) IndexProducts(ctx context.Context, ids ...uuid.UUID) error {
+	summaries, err := s.productRepo.GetSummaries(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to load products: %w", err)
+	}
+	return s.index.Upsert(ctx, searchDocuments(summaries, time.Now()))
+}
+
+// DeleteProducts removes the documents of products that no longer exist, e.g. merged
+// duplicates
+func (s *SearchIndexer) DeleteProducts(ctx context.Context, ids ...uuid.UUID) error {
+	docIDs := make([]string, len(ids))
+	for i, id := range ids {
+		docIDs[i] = id.String()
+	}
+	return s.index.Delete(ctx, docIDs)
+}
+
+// HandleReindexSearch rebuilds the whole index and then deletes documents of products
+// that no longer exist (e.g. merged duplicates)
+func (s *SearchIndexer) HandleReindexSearch(ctx context.Context, t *asynq.Task) error {
+	s.logger.Info("Processing reindex_search job")
+
+	if err := s.index.EnsureIndex(ctx); err != nil {
+		return fmt.Errorf("failed to prepare search index: %w", err)
+	}
+
+	// Whole seconds, as stored in indexed_at
+	start := time.Now().Truncate(time.Second)
+	indexed := 0
+	afterID := uuid.Nil
+	for {
+		summaries, err := s.productRepo.ListSummariesAfter(ctx, afterID, reindexBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to load products: %w", err)
+		}
+		if len(summaries) == 0 {
+			break
+		}
+		if err := s.index.Upsert(ctx, searchDocuments(summaries, start)); err != nil {
+			return fmt.Errorf("failed to index products: %w", err)
+		}
+		indexed += len(summaries)
+		afterID = summaries[len(summaries)-1].Product.ID
+	}
+
+	if err := s.index.DeleteIndexedBefore(ctx, start); err != nil {
+		return fmt.Errorf("failed to delete stale documents: %w", err)
+	}
+
+	s.logger.Info("Search index rebuilt", zap.Int("products", indexed))
+	return nil
+}
+
+func searchDocuments(summaries []*repository.ProductSummary, indexedAt time.Time) []searchindex.Document {
+	docs := make([]searchindex.Document, 0, len(summaries))
+	for _, summary := range summaries {
+		product := summary.Product
+		docs = append(docs, searchindex.Document{
+			ID:            product.ID.String(),
+			Title:         product.Title,
+			Brand:         product.Brand,
+			Model:         product.Model,
+			Category:      product.Category,
+			ImageURL:      product.ImageURL,
+			MinPriceCents: summary.MinPriceCents,
+			OfferCount:    summary.OfferCount,
+			Sources:       summary.Sources,
+			InStock:       summary.InStock,
+			UpdatedAt:     product.UpdatedAt.Unix(),
+			IndexedAt:     indexedAt.Unix(),
+		})
+	}
+	return docs
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// queryRecorder records the queries it is searched for and finds nothing
+type queryRecorder struct {
+	queries []string
+}
+
+func (p *queryRecorder) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	p.queries = append(p.queries, query)
+	return nil, nil
+}
+
+func (p *queryRecorder) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	return nil, nil
+}
+
+func TestHandleFetchPricesSearchQueries(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored []*models.SearchQuery
+		want   []string
+	}{
+		{name: "defaults without stored queries", want: DefaultSearchQueries["demo"]},
+		{
+			name: "enabled queries by priority",
+			stored: []*models.SearchQuery{
+				{Provider: "demo", Query: "keyboard", Priority: 1, Enabled: true},
+				{Provider: "demo", Query: "monitor", Priority: 5, Enabled: true},
+				{Provider: "demo", Query: "mouse", Priority: 9, Enabled: false},
+				{Provider: "live", Query: "camera", Priority: 9, Enabled: true},
+			},
+			want: []string{"monitor", "keyboard"},
+		},
+		{
+			name:   "all queries disabled",
+			stored: []*models.SearchQuery{{Provider: "demo", Query: "keyboard", Enabled: false}},
+		},
+		{
+			name:   "queries of other providers only",
+			stored: []*models.SearchQuery{{Provider: "amazon", Query: "camera", Enabled: true}},
+			want:   DefaultSearchQueries["demo"],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New()
+			for _, query := range tt.stored {
+				if err := store.SearchQueries().Create(ctx, query); err != nil {
+					t.Fatal(err)
+				}
+			}
+			recorder := &queryRecorder{}
+			processor := newTestProcessor(t, store, demoProviders(recorder))
+			processor.EnableSearchQueries(store.SearchQueries())
+
+			data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+			if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+				t.Fatalf("HandleFetchPrices() error = %v", err)
+			}
+			if !slices.Equal(recorder.queries, tt.want) {
+				t.Errorf("searched for %v, want %v", recorder.queries, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// stockProvider returns one product with a single offer whose stock can be toggled
+type stockProvider struct {
+	inStock bool
+}
+
+func (p *stockProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	return []providers.ProductCandidate{{Title: "Nintendo Switch OLED Model"}}, nil
+}
+
+func (p *stockProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	status := "out_of_stock"
+	if p.inStock {
+		status = "in_stock"
+	}
+	return []*models.Offer{{
+		ProductID:          product.ID,
+		Source:             "demo",
+		Seller:             "demo store",
+		PriceAmount:        34999,
+		Currency:           "USD",
+		InStock:            p.inStock,
+		AvailabilityStatus: &status,
+	}}, nil
+}
+
+func TestHandleFetchPricesRecordsStockChanges(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	provider := &stockProvider{}
+	processor := newTestProcessor(t, store, demoProviders(provider))
+	processor.EnableStockTracking(store.StockEvents())
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+
+	// The first fetch creates the offer; only later fetches that flip its stock count
+	for _, inStock := range []bool{true, true, false, false, true} {
+		provider.inStock = inStock
+		if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+			t.Fatalf("HandleFetchPrices() error = %v", err)
+		}
+	}
+
+	products, _, err := store.Products().Search(ctx, "Nintendo", "", 10, 0)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("Search() = %v, %v, want one product", products, err)
+	}
+	stockEvents, err := store.StockEvents().ListByProductID(ctx, products[0].ID, time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stockEvents) != 2 {
+		t.Fatalf("got %d stock events, want 2", len(stockEvents))
+	}
+	if restock := stockEvents[0]; !restock.InStock || restock.AvailabilityStatus == nil || *restock.AvailabilityStatus != "in_stock" {
+		t.Errorf("newest event = %+v, want the restock", restock)
+	}
+	if stockEvents[1].InStock || stockEvents[1].Seller != "demo store" {
+		t.Errorf("oldest event = %+v, want the demo store going out of stock", stockEvents[1])
+	}
+}
+
+// invalidationRecorder records the products whose cached responses were invalidated
+// and how often the search responses were
+type invalidationRecorder struct {
+	productIDs []uuid.UUID
+	searches   int
+}
+
+func (r *invalidationRecorder) InvalidateProduct(ctx context.Context, productID uuid.UUID) error {
+	r.productIDs = append(r.productIDs, productID)
+	return nil
+}
+
+func (r *invalidationRecorder) InvalidateSearch(ctx context.Context) error {
+	r.searches++
+	return nil
+}
+
+func TestHandleFetchPricesInvalidatesResponseCache(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	processor := newTestProcessor(t, store, demoProviders(&stockProvider{inStock: true}))
+	recorder := &invalidationRecorder{}
+	processor.EnableResponseCacheInvalidation(recorder)
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+	if err := processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data)); err != nil {
+		t.Fatalf("HandleFetchPrices() error = %v", err)
+	}
+
+	products, _, _ := store.Products().Search(ctx, "Nintendo", "", 10, 0)
+	if len(products) != 1 || len(recorder.productIDs) == 0 || recorder.productIDs[0] != products[0].ID {
+		t.Errorf("invalidated %v, want the product %v", recorder.productIDs, products)
+	}
+	if recorder.searches != 1 {
+		t.Errorf("search responses invalidated %d times, want once per run", recorder.searches)
+	}
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pricecompare/api/internal/tracing"
+)
+
+// TraceCarrier is embedded in task payloads to carry the enqueuer's trace context, since
+// asynq tasks have no headers
+type TraceCarrier struct {
+	TraceContext propagation.MapCarrier `json:"trace_context,omitempty"`
+}
+
+func (t *TraceCarrier) traceCarrier() *TraceCarrier {
+	return t
+}
+
+// tracedPayload is a task payload embedding TraceCarrier
+type tracedPayload interface {
+	traceCarrier() *TraceCarrier
+}
+
+// Enqueue enqueues a task of type taskType under a producer span and stores the span's
+// trace context in the payload, so the worker's span joins the same trace
+func Enqueue(ctx context.Context, client Enqueuer, taskType string, payload tracedPayload, opts ...asynq.Option) (info *asynq.TaskInfo, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "asynq.enqueue "+taskType,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("asynq.task.type", taskType)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	carrier := payload.traceCarrier()
+	carrier.TraceContext = propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier.TraceContext)
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	info, err = client.EnqueueContext(ctx, asynq.NewTask(taskType, data), opts...)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("asynq.task.id", info.ID), attribute.String("asynq.queue", info.Queue))
+	return info, nil
+}
+
+// TracingMiddleware runs each task under a consumer span that continues the trace stored
+// by Enqueue. Tasks without a trace context (e.g. scheduled ones) start a new trace.
+func TracingMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) (err error) {
+		var carrier TraceCarrier
+		if len(t.Payload()) > 0 {
+			_ = json.Unmarshal(t.Payload(), &carrier) // payloads without a trace context are fine
+		}
+		if carrier.TraceContext != nil {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, carrier.TraceContext)
+		}
+
+		attributes := []attribute.KeyValue{attribute.String("asynq.task.type", t.Type())}
+		if id, ok := asynq.GetTaskID(ctx); ok {
+			attributes = append(attributes, attribute.String("asynq.task.id", id))
+		}
+		if retry, ok := asynq.GetRetryCount(ctx); ok {
+			attributes = append(attributes, attribute.Int("asynq.task.retry", retry))
+		}
+		ctx, span := tracing.Tracer().Start(ctx, "asynq.process "+t.Type(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attributes...),
+		)
+		defer func() { tracing.End(span, err) }()
+
+		return next.ProcessTask(ctx, t)
+	})
+}
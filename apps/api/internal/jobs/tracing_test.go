@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddlewareContinuesEnqueuedTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	// What Enqueue stores in the payload
+	parentCtx, parent := provider.Tracer("test").Start(context.Background(), "enqueue")
+	payload := FetchPricesPayload{Source: "demo", TraceCarrier: TraceCarrier{TraceContext: propagation.MapCarrier{}}}
+	otel.GetTextMapPropagator().Inject(parentCtx, payload.TraceContext)
+	parent.End()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handlerTraceID trace.TraceID
+	handler := TracingMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		handlerTraceID = trace.SpanContextFromContext(ctx).TraceID()
+
+		// Payload fields still decode with the embedded carrier
+		var decoded FetchPricesPayload
+		if err := json.Unmarshal(task.Payload(), &decoded); err != nil || decoded.Source != "demo" {
+			t.Errorf("decoded payload = %+v, %v", decoded, err)
+		}
+		return nil
+	}))
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask(TypeFetchPrices, data)); err != nil {
+		t.Fatalf("ProcessTask() error = %v", err)
+	}
+
+	if handlerTraceID != parent.SpanContext().TraceID() {
+		t.Errorf("handler trace ID = %s, want %s", handlerTraceID, parent.SpanContext().TraceID())
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[1].Name() != "asynq.process "+TypeFetchPrices {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	if spans[1].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("consumer span parent = %s, want %s", spans[1].Parent().SpanID(), parent.SpanContext().SpanID())
+	}
+}
+
+func TestTracingMiddlewareWithoutPayload(t *testing.T) {
+	called := false
+	handler := TracingMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		called = true
+		return nil
+	}))
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask(TypeDetectDuplicates, nil)); err != nil {
+		t.Fatalf("ProcessTask() error = %v", err)
+	}
+	if !called {
+		t.Error("handler was not called")
+	}
+}
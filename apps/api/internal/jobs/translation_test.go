@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/providers"
+	"github.com/pricecompare/api/internal/repository/memory"
+)
+
+// titleProvider returns one listing with the given title
+type titleProvider struct {
+	title string
+}
+
+func (p *titleProvider) Search(ctx context.Context, query string) ([]providers.ProductCandidate, error) {
+	identifier := "B09XS7JWHH"
+	return []providers.ProductCandidate{{Title: p.title, Identifier: &identifier}}, nil
+}
+
+func (p *titleProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	return nil, nil
+}
+
+// fakeTranslator translates by looking up its dictionary and counts its calls
+type fakeTranslator struct {
+	dictionary map[string]string
+	calls      int
+}
+
+func (t *fakeTranslator) Name() string {
+	return "fake"
+}
+
+func (t *fakeTranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+	t.calls++
+	return t.dictionary[text] + " (" + from + "->" + to + ")", nil
+}
+
+func TestHandleFetchPricesTranslatesTitles(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	provider := &titleProvider{title: "ソニー ワイヤレスヘッドホン WH-1000XM5"}
+	processor := newTestProcessor(t, store, demoProviders(provider))
+	translator := &fakeTranslator{dictionary: map[string]string{
+		"ソニー ワイヤレスヘッドホン WH-1000XM5":   "Sony Wireless Headphones WH-1000XM5",
+		"ソニー ワイヤレスヘッドホン WH-1000XM5 黒": "Sony Wireless Headphones WH-1000XM5 Black",
+	}}
+	processor.EnableTranslation(translator)
+	data, _ := json.Marshal(FetchPricesPayload{Source: "demo"})
+
+	steps := []struct {
+		name      string
+		title     string
+		wantEN    string
+		wantCalls int
+	}{
+		{"new listing", "ソニー ワイヤレスヘッドホン WH-1000XM5", "Sony Wireless Headphones WH-1000XM5 (ja->en)", 1},
+		{"unchanged title keeps its translation", "ソニー ワイヤレスヘッドホン WH-1000XM5", "Sony Wireless Headphones WH-1000XM5 (ja->en)", 1},
+		{"changed title", "ソニー ワイヤレスヘッドホン WH-1000XM5 黒", "Sony Wireless Headphones WH-1000XM5 Black (ja->en)", 2},
+	}
+	for _, step := range steps {
+		provider.title = step.title
+		processor.HandleFetchPrices(ctx, asynq.NewTask(TypeFetchPrices, data))
+		listing, err := store.SourceProducts().FindByProviderAndSourceID(ctx, "demo", "B09XS7JWHH")
+		if err != nil || listing == nil {
+			t.Fatalf("%s: listing = %v, %v", step.name, listing, err)
+		}
+		if listing.Titles["ja"] != step.title || listing.Titles["en"] != step.wantEN {
+			t.Errorf("%s: titles = %v, want ja %q and en %q", step.name, listing.Titles, step.title, step.wantEN)
+		}
+		if translator.calls != step.wantCalls {
+			t.Errorf("%s: %d translations, want %d", step.name, translator.calls, step.wantCalls)
+		}
+	}
+}
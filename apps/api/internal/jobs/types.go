@@ -1,10 +1,73 @@
 package jobs
 
-const TypeFetchPrices = "fetch_prices"
+const (
+	TypeFetchPrices         = "fetch_prices"
+	TypeDetectDuplicates    = "detect_duplicates"
+	TypeReindexSearch       = "reindex_search"
+	TypeCatalogReport       = "catalog_report"
+	TypeEvaluateAlerts      = "evaluate_alerts"
+	TypeBackfillImageHashes = "backfill_image_hashes"
+	TypeBackfillEmbeddings  = "backfill_embeddings"
+	TypeMaintenance         = "db_maintenance"
+	TypeReprocessRaw        = "reprocess_raw"
+)
+
+// FetchSources are the valid FetchPricesPayload sources; "all" fetches from every
+// registered provider
+var FetchSources = []string{"demo", "public_html", "live", "walmart", "amazon", "rakuten", "aliexpress", "google_shopping", "all"}
+
+// DefaultSearchQueries are the queries fetch_prices searches each provider for while no
+// queries of that provider are stored (see Processor.EnableSearchQueries). public_html
+// reads every sample page instead of searching.
+var DefaultSearchQueries = map[string][]string{
+	"demo":            {"headphones", "watch", "cable"},
+	"live":            {"headphones", "watch", "laptop"},
+	"walmart":         {"headphones", "laptop", "smartphone", "tablet", "watch", "minecraft", "game", "toy", "book"},
+	"amazon":          {"headphones", "laptop", "smartphone", "tablet", "watch", "minecraft", "game", "toy", "book"},
+	"rakuten":         {"ヘッドホン", "ノートパソコン", "スマートフォン", "タブレット", "腕時計"},
+	"aliexpress":      {"headphones", "smartwatch", "phone case", "usb charger", "led strip"},
+	"google_shopping": {"headphones", "coffee grinder", "camping lantern", "mechanical keyboard", "yoga mat"},
+}
 
 type FetchPricesPayload struct {
-	Source string `json:"source"` // "demo", "public_html", or "all"
+	Source string `json:"source"` // one of FetchSources
+
+	// Optional overrides of the configured crawl budget (see CrawlBudget); 0 is unlimited
+	MaxCandidatesPerQuery *int `json:"max_candidates_per_query,omitempty"`
+	MaxOffersPerProduct   *int `json:"max_offers_per_product,omitempty"`
+	MaxRequests           *int `json:"max_requests,omitempty"`
+	TraceCarrier
 }
 
+type DetectDuplicatesPayload struct {
+	TraceCarrier
+}
 
+type ReindexSearchPayload struct {
+	TraceCarrier
+}
+
+type CatalogReportPayload struct {
+	TraceCarrier
+}
 
+type EvaluateAlertsPayload struct {
+	TraceCarrier
+}
+
+type BackfillImageHashesPayload struct {
+	TraceCarrier
+}
+
+type BackfillEmbeddingsPayload struct {
+	TraceCarrier
+}
+
+type MaintenancePayload struct {
+	TraceCarrier
+}
+
+type ReprocessRawPayload struct {
+	Provider string `json:"provider,omitempty"` // empty re-parses every provider
+	TraceCarrier
+}
@@ -0,0 +1,105 @@
+// Package locale handles the locales providers are queried in and the language of the
+// listings they return, so Japanese and English listings of one item can be told apart.
+package locale
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/width"
+)
+
+// Japanese and English are the listing languages Detect recognizes
+const (
+	Japanese = "ja"
+	English  = "en"
+)
+
+// Parse parses a BCP 47 locale such as "ja-JP" or "en_US" and returns it in canonical
+// form ("ja-JP", "en-US")
+func Parse(s string) (string, error) {
+	tag, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"))
+	if err != nil {
+		return "", err
+	}
+	return tag.String(), nil
+}
+
+// Language returns the language subtag of a locale, e.g. "ja" for "ja-JP", or "" if the
+// locale is empty or invalid
+func Language(locale string) string {
+	if locale == "" {
+		return ""
+	}
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return ""
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// Region returns the region of a locale, e.g. "JP" for "ja-JP", or "" if the locale has
+// no explicit region or is invalid
+func Region(locale string) string {
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return ""
+	}
+	region, confidence := tag.Region()
+	if confidence != language.Exact {
+		return ""
+	}
+	return region.String()
+}
+
+// AcceptLanguage returns an Accept-Language header value preferring locale, with its
+// language as a fallback, e.g. "ja-JP,ja;q=0.9". It returns "" for an empty locale.
+func AcceptLanguage(locale string) string {
+	lang := Language(locale)
+	if lang == "" {
+		return ""
+	}
+	if canonical, err := Parse(locale); err == nil && canonical != lang {
+		return canonical + "," + lang + ";q=0.9"
+	}
+	return lang
+}
+
+// nonEnglishWords are function words common in German, French and Spanish listing
+// titles that English titles do not use
+var nonEnglishWords = map[string]bool{
+	"und": true, "mit": true, "für": true, "der": true, "das": true,
+	"avec": true, "pour": true, "les": true, "et": true,
+	"con": true, "para": true, "sin": true, "del": true,
+}
+
+// Detect guesses the language of a listing title: Japanese if it contains kana or kanji,
+// English if its letters are plain ASCII once full-width forms are folded and none of its
+// words is a common German, French or Spanish function word, and "" otherwise (no letters,
+// accented letters, or another script)
+func Detect(text string) string {
+	text = width.Fold.String(text)
+	letters := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han):
+			return Japanese
+		case unicode.IsLetter(r):
+			if r > unicode.MaxASCII {
+				return ""
+			}
+			letters = true
+		}
+	}
+	if !letters {
+		return ""
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if nonEnglishWords[word] {
+			return ""
+		}
+	}
+	return English
+}
@@ -0,0 +1,76 @@
+package locale
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"ja-JP", "ja-JP", false},
+		{"en_US", "en-US", false},
+		{" EN-gb ", "en-GB", false},
+		{"ja", "ja", false},
+		{"not a locale", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	tests := map[string]string{
+		"ja-JP": "ja-JP,ja;q=0.9",
+		"en_US": "en-US,en;q=0.9",
+		"ja":    "ja",
+		"":      "",
+		"???":   "",
+	}
+	for in, want := range tests {
+		if got := AcceptLanguage(in); got != want {
+			t.Errorf("AcceptLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	tests := map[string]string{
+		"ja-JP": "JP",
+		"en_GB": "GB",
+		"ja":    "",
+		"":      "",
+	}
+	for in, want := range tests {
+		if got := Region(in); got != want {
+			t.Errorf("Region(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Sony WH-1000XM5 Wireless Noise Canceling Headphones", English},
+		{"ソニー ワイヤレスノイズキャンセリングヘッドホン WH-1000XM5", Japanese},
+		{"任天堂 Switch 本体", Japanese},
+		{"ＷＨ－１０００ＸＭ５", English},
+		{"1000 / 2000", ""},
+		{"Sony Kopfhörer WH-1000XM5", ""},
+		{"Casque sans fil Sony avec réduction de bruit", ""},
+		{"Sony Kabellose Kopfhorer mit Geräuschunterdrückung", ""},
+		{"Auriculares Sony con cancelación de ruido", ""},
+		{"Sony Headphones und Case", ""},
+		{"Беспроводные наушники Sony", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.title); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
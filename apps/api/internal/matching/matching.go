@@ -0,0 +1,44 @@
+// Package matching decides whether a provider candidate refers to an existing product.
+package matching
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// FoldWidth maps full-width letters, digits and symbols to ASCII (and half-width katakana
+// to full-width), so Japanese listings writing "ＷＨ－１０００ＸＭ５" match "WH-1000XM5"
+func FoldWidth(s string) string {
+	return width.Fold.String(s)
+}
+
+// Normalize lower-cases s and strips everything but letters and digits, so that
+// "WH-1000XM5", "wh 1000xm5" and "ＷＨ－１０００ＸＭ５" compare equal
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(FoldWidth(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// AttributesAgree reports whether brand and model of a candidate and a product do not
+// contradict each other. A missing value on either side counts as agreement.
+func AttributesAgree(candidateBrand, candidateModel, productBrand, productModel *string) bool {
+	return valuesAgree(candidateBrand, productBrand) && valuesAgree(candidateModel, productModel)
+}
+
+func valuesAgree(a, b *string) bool {
+	if a == nil || b == nil {
+		return true
+	}
+	na, nb := Normalize(*a), Normalize(*b)
+	if na == "" || nb == "" {
+		return true
+	}
+	return na == nb
+}
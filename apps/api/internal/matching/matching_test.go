@@ -0,0 +1,64 @@
+package matching
+
+import (
+	"testing"
+
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+func TestAttributesAgree(t *testing.T) {
+	tests := []struct {
+		name           string
+		candidateBrand *string
+		candidateModel *string
+		productBrand   *string
+		productModel   *string
+		expected       bool
+	}{
+		{
+			name:           "Same brand and model with different formatting",
+			candidateBrand: strx.Ptr("Sony"),
+			candidateModel: strx.Ptr("WH-1000XM5"),
+			productBrand:   strx.Ptr("SONY"),
+			productModel:   strx.Ptr("wh 1000xm5"),
+			expected:       true,
+		},
+		{
+			name:           "Full-width model of a Japanese listing",
+			candidateBrand: strx.Ptr("Sony"),
+			candidateModel: strx.Ptr("ＷＨ－１０００ＸＭ５"),
+			productBrand:   strx.Ptr("Sony"),
+			productModel:   strx.Ptr("WH-1000XM5"),
+			expected:       true,
+		},
+		{
+			name:           "Missing values agree",
+			candidateBrand: strx.Ptr("Sony"),
+			productModel:   strx.Ptr("WH-1000XM5"),
+			expected:       true,
+		},
+		{
+			name:           "Different brand",
+			candidateBrand: strx.Ptr("Sony"),
+			productBrand:   strx.Ptr("Bose"),
+			expected:       false,
+		},
+		{
+			name:           "Different model",
+			candidateBrand: strx.Ptr("Sony"),
+			candidateModel: strx.Ptr("WH-1000XM4"),
+			productBrand:   strx.Ptr("Sony"),
+			productModel:   strx.Ptr("WH-1000XM5"),
+			expected:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AttributesAgree(tt.candidateBrand, tt.candidateModel, tt.productBrand, tt.productModel)
+			if got != tt.expected {
+				t.Errorf("AttributesAgree() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
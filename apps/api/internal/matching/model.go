@@ -0,0 +1,87 @@
+package matching
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// IdentifierTypeModel is the product_identifiers type for brand+model keys (see ModelIdentifier)
+const IdentifierTypeModel = "model"
+
+var (
+	// modelTokenPattern matches SKU-like tokens: alphanumeric groups joined by "-" or ".",
+	// e.g. "WH-1000XM4", "SM-G991B", "KD-55X80K"
+	modelTokenPattern = regexp.MustCompile(`^[A-Za-z0-9]+(?:[-.][A-Za-z0-9]+)*$`)
+
+	// notModelPattern matches tokens that mix letters and digits but describe a quantity
+	// or spec rather than a model, e.g. "128GB", "2.4GHz", "1080p", "2-Pack"
+	notModelPattern = regexp.MustCompile(`(?i)^\d+(?:gb|tb|mb|kb|mah|wh|ghz|mhz|hz|mm|cm|in|inch|ft|oz|lb|lbs|kg|g|ml|l|w|v|k|p|x|pack|pk|pcs|pc|count|ct|ply|gen|th|st|nd|rd|mp|fps|rpm|hp|port|ports|piece|pieces|way|speed|slot|bay|day|year|yr)$`)
+
+	// specPattern matches interface and standard names such as "USB3.0", "HDMI2.1", "IPX7"
+	specPattern = regexp.MustCompile(`(?i)^(?:usb|hdmi|ddr|lpddr|gddr|pcie|wifi|ipx|ip|cat|lte)\d+[a-z]?$`)
+
+	// cpuPattern matches processor names listed in laptop and PC titles, e.g. "i7-1185G7",
+	// "i5-12400F", "N100" or "M2", matched against the normalized token
+	cpuPattern = regexp.MustCompile(`(?i)^(?:i[3579]\d{4,5}[a-z]{0,2}\d?|[nm]\d{1,3}|(?:ryzen|r)[3579]\d{4}[a-z]{0,2})$`)
+
+	// capacityPattern matches capacities combined in one token, e.g. "16GB+512GB" or "8G256G"
+	capacityPattern = regexp.MustCompile(`(?i)^(?:\d+(?:gb|tb|g|t))+$`)
+
+	// titleSeparators split a title into tokens; "-" and "." are kept as they occur in models.
+	// Non-ASCII runes separate too, since Japanese titles often run a model into kana
+	// without spaces (e.g. "ソニーWH-1000XM5ワイヤレス").
+	titleSeparators = func(r rune) bool {
+		return unicode.IsSpace(r) || r > unicode.MaxASCII || strings.ContainsRune(",;:/()[]{}|\"'!?+&*", r)
+	}
+)
+
+// minModelLength is the minimum number of letters and digits in a model number, so short
+// tokens like "S21" or "5G" that are ambiguous on their own are not extracted
+const minModelLength = 4
+
+// ExtractModel returns the most model-number-like token of a product title, e.g.
+// "WH-1000XM4" for "Sony WH-1000XM4 Wireless Noise Canceling Headphones", or "" if
+// the title has none. Tokens must contain both letters and digits; the longest wins.
+// Since a title can name a component's model instead of the product's, the result is a
+// matching signal only and is not used for a model identifier.
+func ExtractModel(title string) string {
+	best, bestLength := "", 0
+	for _, token := range strings.FieldsFunc(FoldWidth(title), titleSeparators) {
+		token = strings.Trim(token, "-.")
+		if !modelTokenPattern.MatchString(token) {
+			continue
+		}
+		normalized := Normalize(token)
+		if len(normalized) < minModelLength || isSpecToken(normalized) {
+			continue
+		}
+		if !strings.ContainsFunc(normalized, unicode.IsLetter) || !strings.ContainsFunc(normalized, unicode.IsDigit) {
+			continue
+		}
+		if len(normalized) > bestLength {
+			best, bestLength = token, len(normalized)
+		}
+	}
+	return best
+}
+
+// isSpecToken reports whether a normalized token names a quantity, interface or component
+// (a CPU in a laptop title) rather than the product's own model
+func isSpecToken(normalized string) bool {
+	return notModelPattern.MatchString(normalized) || specPattern.MatchString(normalized) ||
+		cpuPattern.MatchString(normalized) || capacityPattern.MatchString(normalized)
+}
+
+// ModelIdentifier returns the identifier value for a brand and model, e.g. "sony:wh1000xm4".
+// Model numbers are only unique within a brand, so "" is returned if either is missing.
+func ModelIdentifier(brand, model *string) string {
+	if brand == nil || model == nil {
+		return ""
+	}
+	normalizedBrand, normalizedModel := Normalize(*brand), Normalize(*model)
+	if normalizedBrand == "" || normalizedModel == "" {
+		return ""
+	}
+	return normalizedBrand + ":" + normalizedModel
+}
@@ -0,0 +1,45 @@
+package matching
+
+import (
+	"testing"
+
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+func TestExtractModel(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{"Sony WH-1000XM4 Wireless Noise Canceling Headphones", "WH-1000XM4"},
+		{"Samsung Galaxy S21 5G SM-G991B 128GB", "SM-G991B"},
+		{"Sony 55\" BRAVIA (KD-55X80K) 4K HDR TV", "KD-55X80K"},
+		{"Anker USB3.0 Hub, 4-Port, 2-Pack", ""},
+		{"Apple iPhone 13 128GB Blue", ""},
+		{"Logitech MX Master 3S Wireless Mouse", ""},
+		{"JBL Flip 6 Waterproof Speaker IPX7", ""},
+		{"Canon EOS R6 Mark II Body, 24.2MP", ""},
+		{"Dell XPS 13 9310 i7-1185G7 16GB", ""},
+		{"Lenovo IdeaPad Slim 3 i5-12450H 16GB+512GB 82RK00ABUS", "82RK00ABUS"},
+		{"Apple MacBook Air M2 8G256G", ""},
+		{"ソニー ワイヤレスノイズキャンセリングヘッドホン ＷＨ－１０００ＸＭ４ ブラック", "WH-1000XM4"},
+		{"ソニーWH-1000XM4ワイヤレスヘッドホン", "WH-1000XM4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := ExtractModel(tt.title); got != tt.expected {
+				t.Errorf("ExtractModel(%q) = %q, want %q", tt.title, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestModelIdentifier(t *testing.T) {
+	if got := ModelIdentifier(strx.Ptr("Sony"), strx.Ptr("WH-1000XM4")); got != "sony:wh1000xm4" {
+		t.Errorf("ModelIdentifier() = %q, want %q", got, "sony:wh1000xm4")
+	}
+	if got := ModelIdentifier(nil, strx.Ptr("WH-1000XM4")); got != "" {
+		t.Errorf("ModelIdentifier() without brand = %q, want empty", got)
+	}
+}
@@ -0,0 +1,139 @@
+package matching
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// modelMatchScore is the least score of a listing whose brand and model equal the
+	// product's, however differently the titles are worded
+	modelMatchScore = 0.9
+	// brandMatchBonus is added when both sides name the same brand
+	brandMatchBonus = 0.05
+)
+
+// gtinIdentifierTypes share one number space, like in the merge candidate detection
+var gtinIdentifierTypes = map[string]bool{"UPC": true, "EAN": true, "JAN": true, "GTIN": true}
+
+// modelJoinPattern matches "-" and "." between letters and digits of a title, so
+// "WH-1000XM4" and "WH1000XM4" become the same word
+var modelJoinPattern = regexp.MustCompile(`([a-z0-9])[-.]([a-z0-9])`)
+
+// Identifier is a typed product identifier such as an ASIN or a GTIN
+type Identifier struct {
+	Type  string
+	Value string
+}
+
+// Listing is what Score compares of a provider candidate or a stored product
+type Listing struct {
+	Title       string
+	Brand       *string
+	Model       *string
+	Identifiers []Identifier
+}
+
+// Score rates how likely two listings are the same product, from 0 to 1:
+//   - 1 if they share an identifier (GTIN types compared as one type)
+//   - 0 if both have GTINs but none in common, or brand or model contradict
+//   - otherwise the trigram similarity of the normalized titles, raised to at least
+//     modelMatchScore when both have the same model and increased by brandMatchBonus
+//     when both have the same brand
+func Score(a, b Listing) float64 {
+	shared, conflicting := compareIdentifiers(a.Identifiers, b.Identifiers)
+	if shared {
+		return 1
+	}
+	if conflicting || !AttributesAgree(a.Brand, a.Model, b.Brand, b.Model) {
+		return 0
+	}
+
+	score := TrigramSimilarity(NormalizeTitle(a.Title), NormalizeTitle(b.Title))
+	if bothEqual(a.Model, b.Model) {
+		score = max(score, modelMatchScore)
+	}
+	if bothEqual(a.Brand, b.Brand) {
+		score += brandMatchBonus
+	}
+	return min(score, 1)
+}
+
+// NormalizeTitle prepares a title for trigram comparison: widths are folded, letters
+// lower-cased and "-" or "." inside model numbers removed
+func NormalizeTitle(title string) string {
+	title = strings.ToLower(FoldWidth(title))
+	// Applied twice since matches cannot overlap ("a-b-c" needs two passes)
+	title = modelJoinPattern.ReplaceAllString(title, "$1$2")
+	return modelJoinPattern.ReplaceAllString(title, "$1$2")
+}
+
+// TrigramSimilarity is pg_trgm's similarity(): the Jaccard index of the trigram sets of
+// two strings, where each lowercased alphanumeric word is padded with two leading and
+// one trailing space
+func TrigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for trigram := range ta {
+		if tb[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	result := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			result[string(padded[i:i+3])] = true
+		}
+	}
+	return result
+}
+
+// compareIdentifiers reports whether the lists share an identifier, and whether both
+// have GTINs without a common one. Values are compared ignoring case and leading zeros.
+func compareIdentifiers(a, b []Identifier) (shared, conflicting bool) {
+	keys := make(map[string]bool, len(a))
+	aHasGTIN := false
+	for _, identifier := range a {
+		key := identifierKey(identifier)
+		keys[key] = true
+		aHasGTIN = aHasGTIN || strings.HasPrefix(key, "GTIN:")
+	}
+	bHasGTIN := false
+	for _, identifier := range b {
+		key := identifierKey(identifier)
+		if keys[key] {
+			return true, false
+		}
+		bHasGTIN = bHasGTIN || strings.HasPrefix(key, "GTIN:")
+	}
+	return false, aHasGTIN && bHasGTIN
+}
+
+func identifierKey(identifier Identifier) string {
+	idType := strings.ToUpper(identifier.Type)
+	if gtinIdentifierTypes[idType] {
+		idType = "GTIN"
+	}
+	return idType + ":" + strings.TrimLeft(strings.ToUpper(identifier.Value), "0")
+}
+
+// bothEqual reports whether both values are present and equal once normalized
+func bothEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	na := Normalize(*a)
+	return na != "" && na == Normalize(*b)
+}
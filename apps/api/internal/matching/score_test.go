@@ -0,0 +1,83 @@
+package matching
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Listing
+		atLeast float64
+		atMost  float64
+	}{
+		{
+			name:    "Model written with and without a hyphen",
+			a:       Listing{Title: "Sony WH-1000XM4", Brand: strx.Ptr("Sony"), Model: strx.Ptr("WH-1000XM4")},
+			b:       Listing{Title: "Sony WH1000XM4 Headphones", Brand: strx.Ptr("SONY"), Model: strx.Ptr("WH1000XM4")},
+			atLeast: 0.9,
+			atMost:  1,
+		},
+		{
+			name:    "Shared GTIN despite different titles",
+			a:       Listing{Title: "Noise Cancelling Headphones", Identifiers: []Identifier{{Type: "UPC", Value: "027242919419"}}},
+			b:       Listing{Title: "Sony WH-1000XM4", Identifiers: []Identifier{{Type: "EAN", Value: "0027242919419"}}},
+			atLeast: 1,
+			atMost:  1,
+		},
+		{
+			name:   "Different GTINs",
+			a:      Listing{Title: "Sony WH-1000XM4 Black", Identifiers: []Identifier{{Type: "UPC", Value: "027242919419"}}},
+			b:      Listing{Title: "Sony WH-1000XM4 Silver", Identifiers: []Identifier{{Type: "UPC", Value: "027242919426"}}},
+			atMost: 0,
+		},
+		{
+			name:   "Different models",
+			a:      Listing{Title: "Sony WH-1000XM4 Headphones", Model: strx.Ptr("WH-1000XM4")},
+			b:      Listing{Title: "Sony WH-1000XM5 Headphones", Model: strx.Ptr("WH-1000XM5")},
+			atMost: 0,
+		},
+		{
+			name:   "Unrelated titles",
+			a:      Listing{Title: "Apple AirPods Pro"},
+			b:      Listing{Title: "Sony WH-1000XM4 Headphones"},
+			atMost: 0.2,
+		},
+		{
+			name:    "Same brand raises title similarity",
+			a:       Listing{Title: "Anker PowerCore 10000 Portable Charger", Brand: strx.Ptr("Anker")},
+			b:       Listing{Title: "Anker PowerCore 10000 Portable Charger Black", Brand: strx.Ptr("Anker")},
+			atLeast: TrigramSimilarity("Anker PowerCore 10000 Portable Charger", "Anker PowerCore 10000 Portable Charger Black") + brandMatchBonus - 1e-9,
+			atMost:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := Score(tt.a, tt.b)
+			if score < tt.atLeast || score > tt.atMost {
+				t.Errorf("Score() = %v, want between %v and %v", score, tt.atLeast, tt.atMost)
+			}
+			if reverse := Score(tt.b, tt.a); math.Abs(reverse-score) > 1e-9 {
+				t.Errorf("Score() is not symmetric: %v and %v", score, reverse)
+			}
+		})
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := map[string]string{
+		"Sony WH-1000XM4":      "sony wh1000xm4",
+		"ＷＨ－１０００ＸＭ５":           "wh1000xm5",
+		"Galaxy SM-G991B-DS":   "galaxy smg991bds",
+		"USB-C Cable - 2 Pack": "usbc cable - 2 pack",
+	}
+	for title, want := range tests {
+		if got := NormalizeTitle(title); got != want {
+			t.Errorf("NormalizeTitle(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
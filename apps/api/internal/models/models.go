@@ -1,42 +1,214 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/money"
 )
 
 type Product struct {
-	ID        uuid.UUID  `json:"id"`
-	Title     string     `json:"title"`
-	Brand     *string    `json:"brand,omitempty"`
-	Model     *string    `json:"model,omitempty"`
-	ImageURL  *string    `json:"image_url,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Brand     *string   `json:"brand,omitempty"`
+	Model     *string   `json:"model,omitempty"`
+	ImageURL  *string   `json:"image_url,omitempty"`
+	Category  *string   `json:"category,omitempty"` // see internal/category
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Offer struct {
-	ID                 uuid.UUID  `json:"id"`
-	ProductID          uuid.UUID  `json:"product_id"`
-	Source             string     `json:"source"`
-	Seller             string     `json:"seller"`
-	PriceAmount        int        `json:"price_amount"`          // cents
-	Currency           string     `json:"currency"`
-	ShippingToUSAmount int        `json:"shipping_to_us_amount"` // cents
-	TotalToUSAmount    int        `json:"total_to_us_amount"`    // cents
-	EstDeliveryDaysMin *int       `json:"est_delivery_days_min,omitempty"`
-	EstDeliveryDaysMax *int       `json:"est_delivery_days_max,omitempty"`
-	InStock            bool       `json:"in_stock"`
-	URL                *string    `json:"url,omitempty"`
-	FetchedAt          time.Time  `json:"fetched_at"`
-	FeeAmount          int        `json:"fee_amount"`                     // cents
-	TaxAmount          *int       `json:"tax_amount,omitempty"`           // cents
-	AvailabilityStatus *string    `json:"availability_status,omitempty"`  // e.g. "in_stock", "out_of_stock", "preorder"
-	EstimatedDelivery  *time.Time `json:"estimated_delivery_date,omitempty"`
-	PriceUpdatedAt     time.Time  `json:"price_updated_at"` // when price info was last refreshed
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
+	ID                 uuid.UUID      `json:"id"`
+	ProductID          uuid.UUID      `json:"product_id"`
+	Source             string         `json:"source"`
+	Seller             string         `json:"seller"`
+	PriceAmount        int            `json:"price_amount"` // minor units of Currency (cents for USD, yen for JPY)
+	Currency           string         `json:"currency"`
+	ShippingToUSAmount int            `json:"shipping_to_us_amount"` // cents
+	TotalToUSAmount    int            `json:"total_to_us_amount"`    // cents
+	EstDeliveryDaysMin *int           `json:"est_delivery_days_min,omitempty"`
+	EstDeliveryDaysMax *int           `json:"est_delivery_days_max,omitempty"`
+	InStock            bool           `json:"in_stock"`
+	URL                *string        `json:"url,omitempty"`           // as returned by the provider; the API returns CanonicalURL here unless raw_urls=true
+	CanonicalURL       *string        `json:"canonical_url,omitempty"` // product page link without tracking or affiliate parameters, see canonicalurl.ProductURL
+	FetchedAt          time.Time      `json:"fetched_at"`
+	FeeAmount          int            `json:"fee_amount"`                    // cents, sum of FeeItems
+	TaxAmount          *int           `json:"tax_amount,omitempty"`          // cents
+	AvailabilityStatus *string        `json:"availability_status,omitempty"` // e.g. "in_stock", "out_of_stock", "preorder"
+	EstimatedDelivery  *time.Time     `json:"estimated_delivery_date,omitempty"`
+	PriceUpdatedAt     time.Time      `json:"price_updated_at"`             // when price info was last refreshed
+	ShipsFromCountry   *string        `json:"ships_from_country,omitempty"` // ISO 3166-1 alpha-2; nil means domestic (US)
+	DutyAmount         int            `json:"duty_amount"`                  // cents, estimated import duty
+	LandedCostAmount   int            `json:"landed_cost_amount"`           // cents, total + duty
+	FreeShipping       bool           `json:"free_shipping"`                // ships to the US at no shipping cost
+	FeeItems           FeeItems       `json:"fee_items"`                    // itemized fees (fee rules, FX markup)
+	CostBreakdown      *CostBreakdown `json:"cost_breakdown,omitempty"`     // how the totals above were derived
+	QuarantineReason   *string        `json:"quarantine_reason,omitempty"`  // set when the offer looks implausible, see OfferQuarantine*
+	FirstSeenAt        time.Time      `json:"first_seen_at"`                // first fetch that returned the listing
+	LastSeenAt         time.Time      `json:"last_seen_at"`                 // latest fetch that returned the listing
+	DelistedAt         *time.Time     `json:"delisted_at,omitempty"`        // set when a fetch of its source no longer returned it
+	Notes              *string        `json:"notes,omitempty"`              // admin remarks, kept across refreshes
+	ExpiresAt          *time.Time     `json:"expires_at,omitempty"`         // not published from then on (manual offers)
+	SellerRating       *float64       `json:"seller_rating,omitempty"`      // 0-5 stars, when the source reports one
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+
+	// ShippingOptions is populated by the compare endpoint (not stored on the offers row)
+	ShippingOptions []*OfferShippingOption `json:"shipping_options,omitempty"`
+	// SelectedSpeed is the shipping option applied to the totals above, if any
+	SelectedSpeed *string `json:"selected_speed,omitempty"`
+	// Converted holds the totals in the currency requested with ?currency=, see ConvertTotals
+	Converted *ConvertedTotals `json:"converted,omitempty"`
+}
+
+// ConvertedTotals are an offer's US totals converted to another currency for display.
+// Amounts are in minor units of Currency.
+type ConvertedTotals struct {
+	Currency         string  `json:"currency"`
+	FXRate           float64 `json:"fx_rate"` // units of Currency per USD
+	ShippingAmount   int     `json:"shipping_amount"`
+	TotalAmount      int     `json:"total_amount"`
+	LandedCostAmount int     `json:"landed_cost_amount"`
+}
+
+// ConvertTotals sets Converted to the shipping, total and landed cost converted from US
+// cents to currency at rate (units of currency per USD)
+func (o *Offer) ConvertTotals(currency string, rate float64) {
+	convert := func(cents int) int { return money.USD(cents).Convert(currency, rate).Amount }
+	o.Converted = &ConvertedTotals{
+		Currency:         money.NormalizeCurrency(currency),
+		FXRate:           rate,
+		ShippingAmount:   convert(o.ShippingToUSAmount),
+		TotalAmount:      convert(o.TotalToUSAmount),
+		LandedCostAmount: convert(o.LandedCostAmount),
+	}
+}
+
+// Price returns the offer price as money in its own currency
+func (o *Offer) Price() money.Money {
+	return money.New(o.PriceAmount, o.Currency)
+}
+
+// Expired reports whether the offer's expiry has passed. Expired offers are kept but, like
+// delisted ones, not published.
+func (o *Offer) Expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !o.ExpiresAt.After(now)
+}
+
+// Offer quarantine reasons. Quarantined offers are stored but not published.
+const (
+	OfferQuarantinePriceUnknown     = "price_unknown"      // no price (PriceAmount 0), see providers.Provider
+	OfferQuarantineCurrencyMismatch = "currency_mismatch"  // currency differs from the previous fetch
+	OfferQuarantinePriceBelowMedian = "price_below_median" // far below the product's price history
+)
+
+// Quarantined reports whether the offer is withheld from the offers, compare and search results
+func (o *Offer) Quarantined() bool {
+	return o.QuarantineReason != nil
+}
+
+// Delisted reports whether the listing disappeared from its source. Delisted offers are
+// kept for their history but, like quarantined ones, not published.
+func (o *Offer) Delisted() bool {
+	return o.DelistedAt != nil
+}
+
+// Fee line item types
+const (
+	FeeTypeService  = "service_fee" // proxy-buying / handling fee (fee rules, SHIPPING_FEE_PERCENT fallback)
+	FeeTypeDiscount = "discount"    // negative adjustment from a fee rule
+	FeeTypeFXMarkup = "fx_markup"   // currency conversion spread (FX_MARKUP_PERCENT)
+)
+
+// FeeItem is a single fee line item on an offer
+type FeeItem struct {
+	Type   string `json:"type"`
+	Label  string `json:"label,omitempty"` // fee rule name, if any
+	Amount int    `json:"amount"`          // cents; negative for discounts
+}
+
+// FeeItems is stored as a JSONB array on offers
+type FeeItems []FeeItem
+
+// Total returns the sum of all fee line items in cents
+func (f FeeItems) Total() int {
+	total := 0
+	for _, item := range f {
+		total += item.Amount
+	}
+	return total
+}
+
+// Value implements driver.Valuer
+func (f FeeItems) Value() (driver.Value, error) {
+	if f == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements sql.Scanner
+func (f *FeeItems) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*f = FeeItems{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return fmt.Errorf("unsupported type for FeeItems: %T", src)
+	}
+}
+
+// CostBreakdown records every component of an offer's totals at pricing time, so a
+// total can be explained in the UI and audited later. Amounts are USD cents.
+type CostBreakdown struct {
+	ItemAmount       int     `json:"item"`     // price converted to USD
+	ShippingAmount   int     `json:"shipping"` // carrier shipping
+	FeeAmount        int     `json:"fee"`      // sum of fee items
+	TaxAmount        int     `json:"tax"`
+	DutyAmount       int     `json:"duty"`
+	TotalAmount      int     `json:"total"`             // item + shipping + fee
+	LandedCostAmount int     `json:"landed_cost"`       // total + duty
+	OriginalAmount   int     `json:"original_amount"`   // price in the offer currency (minor units)
+	OriginalCurrency string  `json:"original_currency"` // offer currency
+	FXRate           float64 `json:"fx_rate"`           // units of original currency per USD (1 for USD)
+}
+
+// Value implements driver.Valuer
+func (b CostBreakdown) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan implements sql.Scanner
+func (b *CostBreakdown) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	default:
+		return fmt.Errorf("unsupported type for CostBreakdown: %T", src)
+	}
+}
+
+// OfferShippingOption is one delivery speed option for an offer
+type OfferShippingOption struct {
+	ID                 uuid.UUID `json:"id"`
+	OfferID            uuid.UUID `json:"offer_id"`
+	Speed              string    `json:"speed"`       // economy, standard, express
+	CostAmount         int       `json:"cost_amount"` // cents, carrier shipping (fees are on the offer)
+	EstDeliveryDaysMin *int      `json:"est_delivery_days_min,omitempty"`
+	EstDeliveryDaysMax *int      `json:"est_delivery_days_max,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // ProductIdentifier represents various identifiers like JAN/UPC/EAN/MPN/ASIN, etc.
@@ -51,16 +223,431 @@ type ProductIdentifier struct {
 
 // SourceProduct represents how a product appears on a specific provider (site)
 type SourceProduct struct {
-	ID        uuid.UUID  `json:"id"`
-	ProductID uuid.UUID  `json:"product_id"`
-	Provider  string     `json:"provider"`
-	SourceID  string     `json:"source_id"`
-	URL       string     `json:"url"`
-	Title     *string    `json:"title,omitempty"`
-	Brand     *string    `json:"brand,omitempty"`
-	ImageURL  *string    `json:"image_url,omitempty"`
-	RawJSON   []byte     `json:"raw_json,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID              uuid.UUID       `json:"id"`
+	ProductID       uuid.UUID       `json:"product_id"`
+	Provider        string          `json:"provider"`
+	SourceID        string          `json:"source_id"`
+	URL             string          `json:"url"`
+	Title           *string         `json:"title,omitempty"`
+	Brand           *string         `json:"brand,omitempty"`
+	ImageURL        *string         `json:"image_url,omitempty"`
+	RawJSON         []byte          `json:"raw_json,omitempty"`
+	SchemaVersion   int             `json:"schema_version"`         // provider mapping version that parsed RawJSON (0 = unknown)
+	MatchMethod     string          `json:"match_method"`           // how the listing was linked to the product
+	MatchConfidence float64         `json:"match_confidence"`       // 0..1
+	SnapshotKey     *string         `json:"snapshot_key,omitempty"` // archived raw HTML of the page
+	SnapshotAt      *time.Time      `json:"snapshot_at,omitempty"`
+	Language        *string         `json:"language,omitempty"` // listing language ("ja", "en")
+	Titles          LocalizedTitles `json:"titles,omitempty"`   // title per language: Title under Language, plus translations
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// LocalizedTitles maps a language ("ja", "en") to a listing title in that language
+type LocalizedTitles map[string]string
+
+// Value implements driver.Valuer
+func (t LocalizedTitles) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner
+func (t *LocalizedTitles) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = LocalizedTitles{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("unsupported type for LocalizedTitles: %T", src)
+	}
+}
+
+// Match methods recorded on source_products
+const (
+	MatchMethodIdentifier = "identifier"  // same provider identifier (ASIN, itemId, ...)
+	MatchMethodExactTitle = "exact_title" // identical title
+	MatchMethodFuzzyTitle = "fuzzy_title" // pg_trgm title similarity
+	MatchMethodEmbedding  = "embedding"   // title embedding nearest neighbor
+	MatchMethodImageHash  = "image_hash"  // perceptual hash of the product image
+	MatchMethodManual     = "manual"      // linked by an admin
+	MatchMethodNew        = "new"         // the listing created the product
+)
+
+// Merge candidate reasons
+const (
+	MergeReasonIdentifier = "identifier" // same identifier value on different products
+	MergeReasonTitle      = "title"      // near-identical titles with the same brand
+	MergeReasonManual     = "manual"     // merged by an admin
+)
+
+// Merge candidate statuses
+const (
+	MergeStatusPending   = "pending"
+	MergeStatusMerged    = "merged"
+	MergeStatusDismissed = "dismissed"
+)
+
+// MergeCandidate is a suggestion that DuplicateProductID should be merged into ProductID
+type MergeCandidate struct {
+	ID                 uuid.UUID  `json:"id"`
+	ProductID          uuid.UUID  `json:"product_id"`           // product that is kept
+	DuplicateProductID uuid.UUID  `json:"duplicate_product_id"` // product merged into ProductID
+	Reason             string     `json:"reason"`
+	Score              float64    `json:"score"`            // 0..1, 1 for identifier matches
+	Detail             *string    `json:"detail,omitempty"` // e.g. the shared identifier
+	Status             string     `json:"status"`
+	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+
+	// Populated by the admin endpoint (not stored on the merge_candidates row)
+	Product          *Product `json:"product,omitempty"`
+	DuplicateProduct *Product `json:"duplicate_product,omitempty"`
+}
+
+// Provider operations recorded in provider_fetches
+const (
+	ProviderOperationSearch      = "search"
+	ProviderOperationFetchOffers = "fetch_offers"
+)
+
+// ProviderStats aggregates offer freshness and call outcomes for one provider
+type ProviderStats struct {
+	Provider            string     `json:"provider"`
+	OffersCount         int        `json:"offers_count"`
+	NewestFetchedAt     *time.Time `json:"newest_fetched_at"`
+	AvgStalenessSeconds *float64   `json:"avg_staleness_seconds"` // mean age of the provider's offers
+	Requests            int        `json:"requests"`              // provider calls within the window
+	Errors              int        `json:"errors"`
+	Results             int        `json:"results"`    // candidates and offers returned by successful calls in the window
+	ErrorRate           *float64   `json:"error_rate"` // errors / requests, null without requests
+	LastError           *string    `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	Circuit             string     `json:"circuit"` // circuit breaker state: "closed", "open" or "half_open"
+}
+
+// TableStats are a table's row and vacuum statistics (pg_stat_user_tables), read by the
+// db_maintenance job to decide which tables to analyze or report for vacuuming
+type TableStats struct {
+	Table                string
+	LiveRows             int64
+	DeadRows             int64
+	ModifiedSinceAnalyze int64
+	LastVacuumAt         *time.Time // manual or autovacuum
+	LastAnalyzeAt        *time.Time // manual or autoanalyze
+}
+
+// OfferPriceChange is one change of an offer's price (offer_price_changes). Percentages
+// compare the US totals, so offers in other currencies are comparable.
+type OfferPriceChange struct {
+	ID                 int64     `json:"id"`
+	OfferID            uuid.UUID `json:"offer_id"`
+	ProductID          uuid.UUID `json:"product_id"`
+	Source             string    `json:"source"`
+	Seller             string    `json:"seller"`
+	OldPriceAmount     int       `json:"old_price_amount"`
+	NewPriceAmount     int       `json:"new_price_amount"`
+	Currency           string    `json:"currency"`
+	OldTotalToUSAmount int       `json:"old_total_to_us_amount"` // cents
+	NewTotalToUSAmount int       `json:"new_total_to_us_amount"` // cents
+	ChangePercent      float64   `json:"change_percent"`         // negative for price drops
+	ChangedAt          time.Time `json:"changed_at"`
+
+	// Populated by listing queries
+	ProductTitle string `json:"product_title,omitempty"`
+}
+
+// PriceDrop is a product whose offer got cheaper over a window of price history: the US
+// total before the first change in the window against the offer's current total
+type PriceDrop struct {
+	ProductID          uuid.UUID `json:"product_id"`
+	ProductTitle       string    `json:"product_title"`
+	ImageURL           *string   `json:"image_url,omitempty"`
+	OfferID            uuid.UUID `json:"offer_id"`
+	Source             string    `json:"source"`
+	Seller             string    `json:"seller"`
+	URL                *string   `json:"url,omitempty"`
+	OldTotalToUSAmount int       `json:"old_total_to_us_amount"` // cents
+	NewTotalToUSAmount int       `json:"new_total_to_us_amount"` // cents
+	DropPercent        float64   `json:"drop_percent"`           // positive
+	ChangedAt          time.Time `json:"changed_at"`             // latest change in the window
+}
+
+// StockEvent is one in/out-of-stock transition of an offer (stock_events). InStock is
+// the new state, so an event with InStock true is a restock.
+type StockEvent struct {
+	ID                 int64     `json:"id"`
+	OfferID            uuid.UUID `json:"offer_id"`
+	ProductID          uuid.UUID `json:"product_id"`
+	Source             string    `json:"source"`
+	Seller             string    `json:"seller"`
+	InStock            bool      `json:"in_stock"`
+	AvailabilityStatus *string   `json:"availability_status,omitempty"`
+	OccurredAt         time.Time `json:"occurred_at"`
+}
+
+// OfferMerge records an offer the fetch_prices job collapsed into another offer of the
+// same product and source (offer_merge_log)
+type OfferMerge struct {
+	ID                int64     `json:"id"`
+	ProductID         uuid.UUID `json:"product_id"`
+	Source            string    `json:"source"`
+	KeptOfferID       uuid.UUID `json:"kept_offer_id"`
+	KeptSeller        string    `json:"kept_seller"`
+	KeptURL           *string   `json:"kept_url,omitempty"`
+	MergedSeller      string    `json:"merged_seller"`
+	MergedURL         *string   `json:"merged_url,omitempty"`
+	MergedPriceAmount int       `json:"merged_price_amount"`
+	MergedCurrency    string    `json:"merged_currency"`
+	Reason            string    `json:"reason"` // one of the OfferMergeReason constants
+	MergedAt          time.Time `json:"merged_at"`
+}
+
+// Why an offer was merged into another
+const (
+	OfferMergeReasonSameURL      = "same_url"      // same page once tracking parameters are removed
+	OfferMergeReasonAffiliateTag = "affiliate_tag" // same page through another affiliate tag
+	OfferMergeReasonSellerName   = "seller_name"   // same seller spelled differently
+)
+
+// PriceChangePercent returns the change from oldAmount to newAmount in percent, 0 when
+// oldAmount is not positive
+func PriceChangePercent(oldAmount, newAmount int) float64 {
+	if oldAmount <= 0 {
+		return 0
+	}
+	return float64(newAmount-oldAmount) * 100 / float64(oldAmount)
+}
+
+// CatalogReport summarizes catalog growth and data quality over a period
+type CatalogReport struct {
+	From                        time.Time           `json:"from"`
+	To                          time.Time           `json:"to"`
+	NewProducts                 int                 `json:"new_products"`
+	PriceChangeThresholdPercent float64             `json:"price_change_threshold_percent"`
+	PriceChanges                int                 `json:"price_changes"`         // changes of at least the threshold, either way
+	LargestPriceChanges         []*OfferPriceChange `json:"largest_price_changes"` // by absolute percentage
+	ZeroResultProviders         []*ProviderStats    `json:"zero_result_providers"` // called in the period without returning anything
+	StaleAfterHours             int                 `json:"stale_after_hours"`
+	StaleOffers                 int                 `json:"stale_offers"` // offers not refreshed for StaleAfterHours
+	StaleOffersBySource         map[string]int      `json:"stale_offers_by_source"`
+}
+
+// Price alert channels
+const (
+	AlertChannelEmail   = "email"
+	AlertChannelWebhook = "webhook"
+)
+
+// PriceAlert asks to be notified once the cheapest published offer of a product costs at
+// most TargetPriceCents (US total). It fires once and is re-armed when the price rises
+// above the target again. Email alerts are only evaluated once the address has opened
+// the confirmation link carrying ConfirmToken.
+type PriceAlert struct {
+	ID               uuid.UUID  `json:"id"`
+	ProductID        uuid.UUID  `json:"product_id"`
+	Channel          string     `json:"channel"`
+	Target           string     `json:"target"` // email address or webhook URL
+	TargetPriceCents int        `json:"target_price_cents"`
+	ConfirmToken     string     `json:"-"`                      // only sent to the email address
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty"` // email alerts only
+	TriggeredAt      *time.Time `json:"triggered_at,omitempty"`
+	TriggeredCents   *int       `json:"triggered_cents,omitempty"` // cheapest total that fired the alert
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the alert is evaluated: webhook alerts always are, email
+// alerts once their address is confirmed
+func (a *PriceAlert) Active() bool {
+	return a.Channel != AlertChannelEmail || a.ConfirmedAt != nil
+}
+
+// Product fields curators can edit with PATCH /api/admin/products/:id
+const (
+	ProductFieldTitle    = "title"
+	ProductFieldBrand    = "brand"
+	ProductFieldModel    = "model"
+	ProductFieldImageURL = "image_url"
+	ProductFieldCategory = "category"
+)
+
+// FieldChange is the value of a product field before and after an edit; nil is unset
+type FieldChange struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+// FieldChanges maps edited product fields to their change
+type FieldChanges map[string]FieldChange
+
+// Value implements driver.Valuer
+func (f FieldChanges) Value() (driver.Value, error) {
+	if f == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements sql.Scanner
+func (f *FieldChanges) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*f = FieldChanges{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return fmt.Errorf("unsupported type for FieldChanges: %T", src)
+	}
+}
+
+// ProductRevision is one curator edit of a product's metadata (product_revisions)
+type ProductRevision struct {
+	ID                  int64        `json:"id"`
+	ProductID           uuid.UUID    `json:"product_id"`
+	Changes             FieldChanges `json:"changes"`
+	Reason              *string      `json:"reason,omitempty"`
+	MergedFromProductID *uuid.UUID   `json:"merged_from_product_id,omitempty"` // the product edited, when it was merged into ProductID
+	CreatedAt           time.Time    `json:"created_at"`
+}
+
+// TagCount is a product tag with the number of products carrying it
+type TagCount struct {
+	Tag          string `json:"tag"`
+	ProductCount int    `json:"product_count"`
 }
 
+// Fetch schedule origins
+const (
+	ScheduleOriginEnv = "env" // FETCH_CRON_<SOURCE>
+	ScheduleOriginAPI = "api" // POST /api/admin/schedules
+)
+
+// FetchSchedule runs the fetch_prices job for a source on a cron schedule
+type FetchSchedule struct {
+	ID        uuid.UUID `json:"id"`
+	Source    string    `json:"source"`
+	Cron      string    `json:"cron"` // standard 5-field spec or a descriptor such as "@every 6h"
+	Origin    string    `json:"origin"`
+	Paused    bool      `json:"paused"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SearchQuery is a query the fetch_prices job searches a provider for
+type SearchQuery struct {
+	ID        uuid.UUID `json:"id"`
+	Provider  string    `json:"provider"`
+	Query     string    `json:"query"`
+	Priority  int       `json:"priority"` // higher runs first
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// API key roles, each allowed everything the previous one is. public may call the
+// search, product and compare endpoints (third-party consumers); read may also call GET
+// admin routes, resolve-url and image-search; admin may call every protected route.
+const (
+	APIKeyRolePublic = "public"
+	APIKeyRoleRead   = "read"
+	APIKeyRoleAdmin  = "admin"
+)
+
+// APIKey authenticates requests to protected routes. The key itself is only shown when
+// it is created; KeyHash is its SHA-256 hash.
+type APIKey struct {
+	ID                 uuid.UUID  `json:"id"`
+	Name               string     `json:"name"`
+	Prefix             string     `json:"prefix"` // first characters of the key, to recognize it
+	KeyHash            string     `json:"-"`
+	Role               string     `json:"role"`
+	RateLimitPerMinute *int       `json:"rate_limit_per_minute"` // nil uses the role's default limit, 0 is unlimited
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// ComparisonSet is a named list of products an API key saved to compare. Anyone with
+// ShareToken can read it through the public share URL.
+type ComparisonSet struct {
+	ID         uuid.UUID   `json:"id"`
+	OwnerKeyID uuid.UUID   `json:"-"` // API key that created it; ADMIN_API_KEY and anonymous callers cannot save sets
+	Name       string      `json:"name"`
+	ProductIDs []uuid.UUID `json:"product_ids"`
+	ShareToken string      `json:"share_token"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// Job run statuses
+const (
+	JobRunStatusRunning   = "running"
+	JobRunStatusCompleted = "completed"
+	JobRunStatusFailed    = "failed" // the run panicked or its context was cancelled
+)
+
+// JobRun records one run of a job by its task ID (job_runs). For fetch_prices it lists
+// the products its candidates were linked to, so admins can see what a refresh created.
+// A retried task restarts its run.
+type JobRun struct {
+	ID         string         `json:"id"` // task ID returned when the job was enqueued
+	Type       string         `json:"type"`
+	Source     string         `json:"source,omitempty"`
+	Status     string         `json:"status"`
+	Products   JobRunProducts `json:"products"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// Job run product actions
+const (
+	JobRunProductCreated = "created" // the candidate created the product
+	JobRunProductUpdated = "updated" // the candidate was matched to an existing product
+)
+
+// JobRunProduct links one processed candidate to its product
+type JobRunProduct struct {
+	Source      string    `json:"source"`
+	Identifier  *string   `json:"identifier,omitempty"` // the candidate's listing ID at the source
+	Title       string    `json:"title"`
+	ProductID   uuid.UUID `json:"product_id"`
+	Action      string    `json:"action"`
+	MatchMethod string    `json:"match_method"` // see MatchMethod*
+}
+
+// JobRunProducts is stored as a JSONB array
+type JobRunProducts []JobRunProduct
+
+// Value implements driver.Valuer
+func (p JobRunProducts) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner
+func (p *JobRunProducts) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = JobRunProducts{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unsupported type for JobRunProducts: %T", src)
+	}
+}
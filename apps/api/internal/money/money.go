@@ -0,0 +1,96 @@
+// Package money represents amounts as integer minor units of a currency.
+// The number of minor units per major unit depends on the currency (ISO 4217
+// exponent): USD has 2 decimals, JPY has none, KWD has 3.
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed when a currency code is empty
+const DefaultCurrency = "USD"
+
+// exponents lists currencies whose exponent differs from the default of 2
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Money is an amount in minor units of Currency
+type Money struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NormalizeCurrency upper-cases a currency code; empty means DefaultCurrency
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// Exponent returns the number of decimal places of a currency's minor unit
+func Exponent(currency string) int {
+	if exp, ok := exponents[NormalizeCurrency(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// New returns amount minor units of currency
+func New(amount int, currency string) Money {
+	return Money{Amount: amount, Currency: NormalizeCurrency(currency)}
+}
+
+// USD returns an amount in US cents
+func USD(cents int) Money {
+	return Money{Amount: cents, Currency: "USD"}
+}
+
+// FromMajor converts a major-unit amount (e.g. 19.99 dollars, 1500 yen) to minor
+// units, rounding half away from zero. Use this instead of int(price * 100), which
+// truncates binary floating-point errors (19.99 * 100 = 1998.9999...).
+func FromMajor(major float64, currency string) Money {
+	currency = NormalizeCurrency(currency)
+	scale := math.Pow10(Exponent(currency))
+	return Money{Amount: int(math.Round(major * scale)), Currency: currency}
+}
+
+// Major returns the amount in major units (e.g. dollars, yen)
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(Exponent(m.Currency))
+}
+
+// Add returns m + other; both must be in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if NormalizeCurrency(m.Currency) != NormalizeCurrency(other.Currency) {
+		return Money{}, fmt.Errorf("currency mismatch: %s and %s", m.Currency, other.Currency)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: NormalizeCurrency(m.Currency)}, nil
+}
+
+// Percent returns percent% of m, rounded to the nearest minor unit
+func (m Money) Percent(percent float64) Money {
+	return Money{
+		Amount:   int(math.Round(float64(m.Amount) * percent / 100.0)),
+		Currency: NormalizeCurrency(m.Currency),
+	}
+}
+
+// Convert converts m to currency using rate (units of the target currency per
+// unit of m's currency), rounding to the target's minor unit
+func (m Money) Convert(currency string, rate float64) Money {
+	return FromMajor(m.Major()*rate, currency)
+}
+
+// String formats the amount with the currency's number of decimals, e.g. "19.99 USD"
+func (m Money) String() string {
+	currency := NormalizeCurrency(m.Currency)
+	return strconv.FormatFloat(m.Major(), 'f', Exponent(currency), 64) + " " + currency
+}
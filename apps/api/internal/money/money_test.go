@@ -0,0 +1,66 @@
+package money
+
+import "testing"
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		name     string
+		major    float64
+		currency string
+		expected Money
+	}{
+		{name: "USD rounds instead of truncating", major: 79.99, currency: "USD", expected: Money{Amount: 7999, Currency: "USD"}},
+		{name: "Empty currency defaults to USD", major: 19.99, currency: "", expected: Money{Amount: 1999, Currency: "USD"}},
+		{name: "JPY has no minor unit", major: 1500, currency: "jpy", expected: Money{Amount: 1500, Currency: "JPY"}},
+		{name: "KWD has three decimals", major: 1.234, currency: "KWD", expected: Money{Amount: 1234, Currency: "KWD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromMajor(tt.major, tt.currency); got != tt.expected {
+				t.Errorf("FromMajor(%v, %q) = %+v, want %+v", tt.major, tt.currency, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// ¥15,000 at 1/150 USD per yen is $100.00, i.e. 10000 cents (not 100)
+	got := New(15000, "JPY").Convert("USD", 1.0/150.0)
+	if got != USD(10000) {
+		t.Errorf("Convert() = %+v, want %+v", got, USD(10000))
+	}
+
+	// $10.00 at 153 yen per dollar is ¥1,530 (not 153000)
+	got = USD(1000).Convert("JPY", 153)
+	if got != New(1530, "JPY") {
+		t.Errorf("Convert() = %+v, want %+v", got, New(1530, "JPY"))
+	}
+}
+
+func TestAdd(t *testing.T) {
+	sum, err := USD(1999).Add(USD(1))
+	if err != nil || sum != USD(2000) {
+		t.Errorf("Add() = (%+v, %v), want (%+v, nil)", sum, err, USD(2000))
+	}
+	if _, err := USD(100).Add(New(100, "JPY")); err == nil {
+		t.Error("Add() with different currencies expected error, got nil")
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		money    Money
+		expected string
+	}{
+		{money: USD(1999), expected: "19.99 USD"},
+		{money: New(1500, "JPY"), expected: "1500 JPY"},
+		{money: New(1234, "KWD"), expected: "1.234 KWD"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.expected {
+			t.Errorf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// AlertEvent is the event field of price alert webhook payloads
+const AlertEvent = "price_alert"
+
+// Dispatcher delivers price alerts to the email address or webhook URL of each
+// subscription. Webhooks receive the PriceAlert as JSON; emails use the KindPriceAlert
+// template. Webhook URLs come from subscribers, so they may only reach public addresses.
+// Email addresses also come from subscribers, so they are first sent a confirmation link.
+type Dispatcher struct {
+	mailer     *SMTPSender // nil when email is not configured
+	confirmURL string      // confirmation tokens are appended to it
+	template   *Template
+	client     *http.Client
+}
+
+// NewDispatcher returns a dispatcher. confirmURL is the public URL of the confirmation
+// endpoint, which tokens are appended to; email alerts need it and mailer, without
+// either only webhooks are allowed.
+func NewDispatcher(mailer *SMTPSender, confirmURL string) *Dispatcher {
+	return &Dispatcher{
+		mailer:     mailer,
+		confirmURL: confirmURL,
+		template:   defaultTemplates[KindPriceAlert],
+		client:     &http.Client{Timeout: 10 * time.Second, Transport: httpclient.NewPublicTransport()},
+	}
+}
+
+// Channels returns the alert channels this dispatcher can deliver to
+func (d *Dispatcher) Channels() []string {
+	if d.mailer == nil || d.confirmURL == "" {
+		return []string{models.AlertChannelWebhook}
+	}
+	return []string{models.AlertChannelEmail, models.AlertChannelWebhook}
+}
+
+// SendConfirmation emails the link that confirms an email alert to its address
+func (d *Dispatcher) SendConfirmation(ctx context.Context, target, token string, confirmation AlertConfirmation) error {
+	if d.mailer == nil || d.confirmURL == "" {
+		return fmt.Errorf("email alerts are not configured")
+	}
+	confirmation.ConfirmURL = d.confirmURL + url.PathEscape(token)
+	msg, err := defaultTemplates[KindAlertConfirm].Render(confirmation)
+	if err != nil {
+		return fmt.Errorf("failed to render %s notification: %w", KindAlertConfirm, err)
+	}
+	return d.mailer.SendTo(ctx, []string{target}, msg)
+}
+
+// Dispatch delivers one alert to target over channel
+func (d *Dispatcher) Dispatch(ctx context.Context, channel, target string, alert PriceAlert) error {
+	alert.Event = AlertEvent
+	switch channel {
+	case models.AlertChannelEmail:
+		if d.mailer == nil {
+			return fmt.Errorf("email alerts are not configured")
+		}
+		msg, err := d.template.Render(alert)
+		if err != nil {
+			return fmt.Errorf("failed to render %s notification: %w", KindPriceAlert, err)
+		}
+		return d.mailer.SendTo(ctx, []string{target}, msg)
+	case models.AlertChannelWebhook:
+		return d.postWebhook(ctx, target, alert)
+	default:
+		return fmt.Errorf("unknown alert channel %q", channel)
+	}
+}
+
+func (d *Dispatcher) postWebhook(ctx context.Context, url string, alert PriceAlert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// The body is not reported: the error is stored with the alert, and the URL is the
+	// subscriber's, so its response could be an internal page's
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
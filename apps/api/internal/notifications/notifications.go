@@ -0,0 +1,240 @@
+// Package notifications renders alert templates and delivers them over email (SMTP) and
+// Slack incoming webhooks. Each alert kind is routed to its own set of channels.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Channel names used in routes (NOTIFY_ROUTES)
+const (
+	ChannelEmail = "email"
+	ChannelSlack = "slack"
+)
+
+// Alert kinds. DefaultRoute applies to kinds without a route of their own.
+const (
+	KindJobFailure    = "job_failure"
+	KindCatalogReport = "catalog_report"
+	KindPriceAlert    = "price_alert"         // sent to the subscriber, not through NOTIFY_ROUTES
+	KindAlertConfirm  = "price_alert_confirm" // sent to the subscriber, not through NOTIFY_ROUTES
+	DefaultRoute      = "*"
+)
+
+// Message is a rendered notification
+type Message struct {
+	Subject string
+	Body    string // plain text
+}
+
+// Sender delivers a message over one channel
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Template renders the subject and body of one alert kind with text/template
+type Template struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"time": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05 MST") },
+	"date": func(t time.Time) string { return t.UTC().Format("2006-01-02") },
+	"usd":  func(cents int) string { return fmt.Sprintf("$%d.%02d", cents/100, cents%100) },
+}
+
+// NewTemplate parses a subject and body template. Missing fields are errors rather than
+// "<no value>" in a delivered message.
+func NewTemplate(subject, body string) (*Template, error) {
+	subjectTmpl, err := template.New("subject").Funcs(templateFuncs).Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	bodyTmpl, err := template.New("body").Funcs(templateFuncs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	return &Template{subject: subjectTmpl, body: bodyTmpl}, nil
+}
+
+// MustTemplate is NewTemplate for built-in templates
+func MustTemplate(subject, body string) *Template {
+	t, err := NewTemplate(subject, body)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the templates with data
+func (t *Template) Render(data any) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	// Subjects become mail headers and Slack headings, so they are kept on one line
+	return Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Body:    strings.TrimSpace(body.String()),
+	}, nil
+}
+
+// JobFailure is the template data for KindJobFailure
+type JobFailure struct {
+	Type       string
+	TaskID     string
+	Queue      string
+	Retried    int
+	MaxRetry   int
+	Error      string
+	FailedAt   time.Time
+	Suppressed int // failures of the same type not alerted during the cooldown
+}
+
+// PriceAlert is the template data for KindPriceAlert and the JSON payload of alert webhooks
+type PriceAlert struct {
+	Event            string    `json:"event"` // always "price_alert"
+	AlertID          string    `json:"alert_id"`
+	ProductID        string    `json:"product_id"`
+	ProductTitle     string    `json:"product_title"`
+	TargetPriceCents int       `json:"target_price_cents"`
+	PriceCents       int       `json:"price_cents"` // cheapest US total
+	TriggeredAt      time.Time `json:"triggered_at"`
+}
+
+// AlertConfirmation is the template data for KindAlertConfirm
+type AlertConfirmation struct {
+	AlertID          string
+	ProductTitle     string
+	TargetPriceCents int
+	ConfirmURL       string
+}
+
+var defaultTemplates = map[string]*Template{
+	KindJobFailure: MustTemplate(
+		`[pricecompare] {{.Type}} job failed`,
+		`Job {{.Type}} ({{.TaskID}}) on queue {{.Queue}} failed after {{.Retried}} of {{.MaxRetry}} retries at {{time .FailedAt}}.
+
+Error: {{.Error}}
+{{if .Suppressed}}
+{{.Suppressed}} more {{.Type}} failures were not alerted since the previous notification.
+{{end}}`,
+	),
+	// Data is a *models.CatalogReport
+	KindCatalogReport: MustTemplate(
+		`[pricecompare] Catalog report {{date .To}}`,
+		`Catalog report for {{time .From}} to {{time .To}}
+
+New products: {{.NewProducts}}
+
+Price changes of {{printf "%g" .PriceChangeThresholdPercent}}% or more: {{.PriceChanges}}
+{{- range .LargestPriceChanges}}
+  {{printf "%+.1f%%" .ChangePercent}} {{.ProductTitle}} ({{.Source}}, {{.Seller}}): {{usd .OldTotalToUSAmount}} -> {{usd .NewTotalToUSAmount}}
+{{- end}}
+
+Providers without results: {{if not .ZeroResultProviders}}none{{end}}
+{{- range .ZeroResultProviders}}
+  {{.Provider}}: {{.Requests}} calls, {{.Errors}} errors{{with .LastError}}, last error: {{.}}{{end}}
+{{- end}}
+
+Offers not refreshed for {{.StaleAfterHours}} hours: {{.StaleOffers}}
+{{- range $source, $count := .StaleOffersBySource}}
+  {{$source}}: {{$count}}
+{{- end}}`,
+	),
+	KindPriceAlert: MustTemplate(
+		`[pricecompare] {{.ProductTitle}} is now {{usd .PriceCents}}`,
+		`The cheapest offer for {{.ProductTitle}} costs {{usd .PriceCents}} delivered to the US, at or below your target of {{usd .TargetPriceCents}} ({{time .TriggeredAt}}).
+
+You will not be alerted again until the price rises above the target.
+Alert ID: {{.AlertID}}`,
+	),
+	KindAlertConfirm: MustTemplate(
+		`[pricecompare] Confirm your price alert for {{.ProductTitle}}`,
+		`Someone asked to email this address once {{.ProductTitle}} costs {{usd .TargetPriceCents}} or less delivered to the US.
+
+Open this link to start the alert:
+{{.ConfirmURL}}
+
+If you did not ask for this alert, ignore this email and it will not be sent.
+Alert ID: {{.AlertID}}`,
+	),
+}
+
+// Notifier routes alerts to channels
+type Notifier struct {
+	senders   map[string]Sender
+	routes    map[string][]string // alert kind -> channels
+	templates map[string]*Template
+}
+
+// NewNotifier returns a notifier for the configured senders (channel name -> sender).
+// routes maps alert kinds (or DefaultRoute) to channel names; when it is empty every
+// alert goes to every sender. A kind routed to no channels is disabled.
+func NewNotifier(senders map[string]Sender, routes map[string][]string) *Notifier {
+	if len(routes) == 0 {
+		all := make([]string, 0, len(senders))
+		for channel := range senders {
+			all = append(all, channel)
+		}
+		routes = map[string][]string{DefaultRoute: all}
+	}
+	templates := make(map[string]*Template, len(defaultTemplates))
+	for kind, tmpl := range defaultTemplates {
+		templates[kind] = tmpl
+	}
+	return &Notifier{senders: senders, routes: routes, templates: templates}
+}
+
+// SetTemplate replaces the template of an alert kind
+func (n *Notifier) SetTemplate(kind string, tmpl *Template) {
+	n.templates[kind] = tmpl
+}
+
+// Channels returns the channels an alert kind is delivered to
+func (n *Notifier) Channels(kind string) []string {
+	if channels, ok := n.routes[kind]; ok {
+		return channels
+	}
+	return n.routes[DefaultRoute]
+}
+
+// Notify renders the template of kind with data and sends it to every routed channel.
+// A failing channel does not prevent delivery to the others; all failures are returned.
+func (n *Notifier) Notify(ctx context.Context, kind string, data any) error {
+	channels := n.Channels(kind)
+	if len(channels) == 0 {
+		return nil
+	}
+	tmpl, ok := n.templates[kind]
+	if !ok {
+		return fmt.Errorf("no notification template for %q", kind)
+	}
+	msg, err := tmpl.Render(data)
+	if err != nil {
+		return fmt.Errorf("failed to render %s notification: %w", kind, err)
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		sender, ok := n.senders[channel]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: channel is not configured", channel))
+			continue
+		}
+		if err := sender.Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
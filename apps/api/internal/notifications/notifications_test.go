@@ -0,0 +1,207 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pricecompare/api/internal/httpclient"
+)
+
+type recordingSender struct {
+	sent []Message
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg Message) error {
+	s.sent = append(s.sent, msg)
+	return s.err
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	failure := JobFailure{
+		Type:     "fetch_prices",
+		TaskID:   "t1",
+		Queue:    "default",
+		Retried:  25,
+		MaxRetry: 25,
+		Error:    "provider timeout",
+		FailedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name      string
+		routes    map[string][]string
+		wantEmail int
+		wantSlack int
+		wantErr   string // empty means success
+	}{
+		{name: "no routes sends to every channel", wantEmail: 1, wantSlack: 1},
+		{name: "kind route", routes: map[string][]string{KindJobFailure: {ChannelSlack}, DefaultRoute: {ChannelEmail}}, wantSlack: 1},
+		{name: "default route", routes: map[string][]string{DefaultRoute: {ChannelEmail}}, wantEmail: 1},
+		{name: "disabled kind", routes: map[string][]string{KindJobFailure: {}, DefaultRoute: {ChannelEmail}}},
+		{name: "unconfigured channel", routes: map[string][]string{KindJobFailure: {"sms", ChannelSlack}}, wantSlack: 1, wantErr: "sms: channel is not configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, slack := &recordingSender{}, &recordingSender{}
+			notifier := NewNotifier(map[string]Sender{ChannelEmail: email, ChannelSlack: slack}, tt.routes)
+
+			err := notifier.Notify(context.Background(), KindJobFailure, failure)
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Notify() error = %v, want %q", err, tt.wantErr)
+			}
+			if len(email.sent) != tt.wantEmail || len(slack.sent) != tt.wantSlack {
+				t.Errorf("sent email=%d slack=%d, want email=%d slack=%d", len(email.sent), len(slack.sent), tt.wantEmail, tt.wantSlack)
+			}
+		})
+	}
+}
+
+func TestNotifier_NotifyRendersTemplate(t *testing.T) {
+	sender := &recordingSender{err: errors.New("boom")}
+	notifier := NewNotifier(map[string]Sender{ChannelSlack: sender}, nil)
+
+	err := notifier.Notify(context.Background(), KindJobFailure, JobFailure{
+		Type: "detect_duplicates", TaskID: "t2", Queue: "default", Retried: 3, MaxRetry: 3,
+		Error: "deadlock detected", FailedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Suppressed: 4,
+	})
+	if err == nil || !strings.Contains(err.Error(), "slack: boom") {
+		t.Fatalf("Notify() error = %v, want the sender error", err)
+	}
+
+	msg := sender.sent[0]
+	if msg.Subject != "[pricecompare] detect_duplicates job failed" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	for _, want := range []string{"failed after 3 of 3 retries at 2026-01-02 03:04:05 UTC", "Error: deadlock detected", "4 more detect_duplicates failures"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("Body %q does not contain %q", msg.Body, want)
+		}
+	}
+
+	if err := notifier.Notify(context.Background(), KindJobFailure, map[string]any{"Type": "x"}); err == nil {
+		t.Error("Notify() with missing template fields succeeded, want a render error")
+	}
+}
+
+func TestSlackSender_Send(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "invalid token", status: http.StatusForbidden, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				w.Write([]byte("invalid_token"))
+			}))
+			defer server.Close()
+
+			err := NewSlackSender(server.URL).Send(context.Background(), Message{Subject: "Price drop", Body: "Now $10"})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got["text"] != "*Price drop*\nNow $10" {
+				t.Errorf("text = %q", got["text"])
+			}
+		})
+	}
+}
+
+func TestBuildEmail(t *testing.T) {
+	email := string(buildEmail("alerts@example.com", []string{"a@example.com", "b@example.com"},
+		Message{Subject: "値下がり: Sony", Body: "line 1\nline 2"}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	for _, want := range []string{
+		"From: alerts@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"Date: Fri, 02 Jan 2026 03:04:05 +0000\r\n",
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\nline 1\r\nline 2",
+	} {
+		if !strings.Contains(email, want) {
+			t.Errorf("email does not contain %q:\n%s", want, email)
+		}
+	}
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	alert := PriceAlert{
+		AlertID:          "a1",
+		ProductID:        "p1",
+		ProductTitle:     "Sony WH-1000XM5",
+		TargetPriceCents: 25000,
+		PriceCents:       24999,
+		TriggeredAt:      time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+
+	var got PriceAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(nil, "")
+	if err := dispatcher.Dispatch(context.Background(), "webhook", server.URL, alert); !errors.Is(err, httpclient.ErrNonPublicAddress) {
+		t.Errorf("Dispatch(webhook) to a loopback address error = %v, want ErrNonPublicAddress", err)
+	}
+	// The test server is on loopback
+	dispatcher.client = server.Client()
+	if err := dispatcher.Dispatch(context.Background(), "webhook", server.URL, alert); err != nil {
+		t.Fatalf("Dispatch(webhook) error = %v", err)
+	}
+	if got.Event != AlertEvent || got.AlertID != "a1" || got.PriceCents != 24999 {
+		t.Errorf("webhook payload = %+v", got)
+	}
+
+	if err := dispatcher.Dispatch(context.Background(), "email", "buyer@example.com", alert); err == nil {
+		t.Error("Dispatch(email) succeeded without SMTP")
+	}
+	if channels := dispatcher.Channels(); len(channels) != 1 || channels[0] != "webhook" {
+		t.Errorf("Channels() = %v, want only webhook", channels)
+	}
+
+	msg, err := defaultTemplates[KindPriceAlert].Render(alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "[pricecompare] Sony WH-1000XM5 is now $249.99" || !strings.Contains(msg.Body, "your target of $250.00") {
+		t.Errorf("rendered alert = %+v", msg)
+	}
+}
+
+func TestDispatcher_SendConfirmation(t *testing.T) {
+	confirmation := AlertConfirmation{AlertID: "a1", ProductTitle: "Sony WH-1000XM5", TargetPriceCents: 25000}
+	if err := NewDispatcher(nil, "https://api.example.com/api/alerts/confirm/").SendConfirmation(context.Background(), "buyer@example.com", "t1", confirmation); err == nil {
+		t.Error("SendConfirmation() succeeded without SMTP")
+	}
+
+	confirmation.ConfirmURL = "https://api.example.com/api/alerts/confirm/t1"
+	msg, err := defaultTemplates[KindAlertConfirm].Render(confirmation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "[pricecompare] Confirm your price alert for Sony WH-1000XM5" || !strings.Contains(msg.Body, "\nhttps://api.example.com/api/alerts/confirm/t1\n") {
+		t.Errorf("rendered confirmation = %+v", msg)
+	}
+}
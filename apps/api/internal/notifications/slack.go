@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SlackSender posts messages to a Slack incoming webhook
+type SlackSender struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackSender returns a sender for an incoming webhook URL
+func NewSlackSender(webhookURL string) *SlackSender {
+	return &SlackSender{webhookURL: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Send posts the subject in bold followed by the body
+func (s *SlackSender) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(map[string]string{"text": "*" + msg.Subject + "*\n" + msg.Body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPSender sends plain-text email. Port 465 uses implicit TLS; other ports upgrade
+// with STARTTLS when the server offers it.
+type SMTPSender struct {
+	addr     string // host:port
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// NewSMTPSender returns an email sender. username may be empty for a relay that does not
+// require authentication; net/smtp refuses to send credentials over an unencrypted
+// connection to anything but localhost.
+func NewSMTPSender(addr, username, password, from string, to []string) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", addr, err)
+	}
+	return &SMTPSender{addr: addr, host: host, username: username, password: password, from: from, to: to}, nil
+}
+
+// Send delivers the message to every configured recipient
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	return s.SendTo(ctx, s.to, msg)
+}
+
+// SendTo delivers the message to the given recipients instead, e.g. a price alert subscriber
+func (s *SMTPSender) SendTo(ctx context.Context, to []string, msg Message) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+
+	var conn net.Conn
+	var err error
+	if strings.HasSuffix(s.addr, ":465") {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", recipient, err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(buildEmail(s.from, to, msg, time.Now())); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildEmail formats a UTF-8 plain-text message with an encoded subject and a
+// quoted-printable body
+func buildEmail(from string, to []string, msg Message, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	body.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	body.Close()
+	return buf.Bytes()
+}
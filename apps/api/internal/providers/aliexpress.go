@@ -0,0 +1,393 @@
+package providers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/resolver"
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+// AliExpressProvider implements the AliExpress affiliate API of the Open Platform. Prices
+// are requested in USD for delivery to the US; offers ship from China.
+type AliExpressProvider struct {
+	httpClient *httpclient.Client
+	appKey     string
+	appSecret  string
+	trackingID string // Optional; offers then link to the promotion link
+	apiURL     string
+	enabled    bool
+	now        func() time.Time // signs the request timestamp
+}
+
+// NewAliExpressProvider creates a new AliExpress affiliate API provider
+func NewAliExpressProvider(httpClient *httpclient.Client) *AliExpressProvider {
+	appKey := os.Getenv("ALIEXPRESS_APP_KEY")
+	appSecret := os.Getenv("ALIEXPRESS_APP_SECRET")
+	apiURL := os.Getenv("ALIEXPRESS_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api-sg.aliexpress.com/sync"
+	}
+
+	return &AliExpressProvider{
+		httpClient: httpClient,
+		appKey:     appKey,
+		appSecret:  appSecret,
+		trackingID: os.Getenv("ALIEXPRESS_TRACKING_ID"),
+		apiURL:     apiURL,
+		enabled:    appKey != "" && appSecret != "",
+		now:        time.Now,
+	}
+}
+
+// IsEnabled returns whether the provider is enabled (has an app key and secret)
+func (p *AliExpressProvider) IsEnabled() bool {
+	return p.enabled
+}
+
+// Ping verifies the app key and secret with a minimal search
+func (p *AliExpressProvider) Ping(ctx context.Context) error {
+	_, err := p.call(ctx, "aliexpress.affiliate.product.query", url.Values{"keywords": {"test"}, "page_size": {"1"}})
+	return err
+}
+
+// MatchURL recognizes AliExpress item pages, see URLMatcher
+func (p *AliExpressProvider) MatchURL(u *url.URL) (string, string, bool) {
+	return resolver.AliExpressProductID(u)
+}
+
+// Search searches for products using the AliExpress affiliate API
+func (p *AliExpressProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+
+	items, err := p.call(ctx, "aliexpress.affiliate.product.query", url.Values{"keywords": {query}, "page_size": {"20"}})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]ProductCandidate, 0, len(items))
+	for _, raw := range items {
+		candidate, err := p.ParseRaw(raw)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		candidates = append(candidates, *candidate)
+	}
+	return candidates, nil
+}
+
+// aliExpressSchemaVersion is the version of ParseRaw's mapping; raise it when the mapping
+// changes so stored listings are re-parsed by the reprocess_raw job
+const aliExpressSchemaVersion = 1
+
+// aliExpressProduct is one product of a product.query or productdetail.get response
+type aliExpressProduct struct {
+	ProductID         int64  `json:"product_id"`
+	Title             string `json:"product_title"`
+	MainImageURL      string `json:"product_main_image_url"`
+	DetailURL         string `json:"product_detail_url"`
+	PromotionLink     string `json:"promotion_link"`
+	SalePrice         string `json:"target_sale_price"` // major units, e.g. "29.99"
+	SalePriceCurrency string `json:"target_sale_price_currency"`
+	EvaluateRate      string `json:"evaluate_rate"` // positive feedback, e.g. "97.4%"
+	ShopName          string `json:"shop_name"`
+	ShipToDays        string `json:"ship_to_days"` // e.g. "ship to US in 15 days" or "7-15"
+	CategoryName      string `json:"first_level_category_name"`
+}
+
+// RawSchemaVersion implements RawParser
+func (p *AliExpressProvider) RawSchemaVersion() int {
+	return aliExpressSchemaVersion
+}
+
+// ParseRaw maps one affiliate API product to a candidate, or nil for a product without a
+// title
+func (p *AliExpressProvider) ParseRaw(raw []byte) (*ProductCandidate, error) {
+	var product aliExpressProduct
+	if err := json.Unmarshal(raw, &product); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse AliExpress product: %w", ErrParse, err)
+	}
+	if product.Title == "" {
+		return nil, nil
+	}
+	identifier := ""
+	if product.ProductID > 0 {
+		identifier = strconv.FormatInt(product.ProductID, 10)
+	}
+	price, _ := strconv.ParseFloat(product.SalePrice, 64)
+	return &ProductCandidate{
+		Title:         product.Title,
+		ImageURL:      strx.NonEmptyPtr(product.MainImageURL),
+		Source:        "aliexpress",
+		Identifier:    strx.NonEmptyPtr(identifier),
+		SourceURL:     strx.NonEmptyPtr(product.DetailURL),
+		Category:      strx.NonEmptyPtr(product.CategoryName),
+		HasPrice:      price > 0,
+		Language:      "en",
+		Raw:           raw,
+		SchemaVersion: aliExpressSchemaVersion,
+	}, nil
+}
+
+// FetchOffers fetches the offer of a product: the matched listing by its product ID, or
+// else the first search result for the product's title (preferring one whose title
+// contains it)
+func (p *AliExpressProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	method := "aliexpress.affiliate.product.query"
+	params := url.Values{"keywords": {product.Title}, "page_size": {"10"}}
+	if listingURL != "" {
+		if u, err := url.Parse(listingURL); err == nil {
+			if _, productID, ok := resolver.AliExpressProductID(u); ok {
+				method = "aliexpress.affiliate.productdetail.get"
+				params = url.Values{"product_ids": {productID}}
+			}
+		}
+	}
+
+	rawProducts, err := p.call(ctx, method, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for product: %w", err)
+	}
+
+	var matched *aliExpressProduct
+	var matchedPrice float64
+	for _, raw := range rawProducts {
+		var item aliExpressProduct
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse AliExpress product: %w", ErrParse, err)
+		}
+		// Products without a price cannot be compared
+		price, err := strconv.ParseFloat(item.SalePrice, 64)
+		if item.Title == "" || err != nil || price <= 0 {
+			continue
+		}
+		if matched == nil || (params.Has("keywords") && strx.ContainsFold(item.Title, product.Title) && !strx.ContainsFold(matched.Title, product.Title)) {
+			matched = &item
+			matchedPrice = price
+		}
+	}
+	if matched == nil {
+		return []*models.Offer{}, nil
+	}
+
+	seller := matched.ShopName
+	if seller == "" {
+		seller = "AliExpress"
+	}
+	offerURL := matched.DetailURL
+	if matched.PromotionLink != "" {
+		offerURL = matched.PromotionLink
+	}
+	currency := matched.SalePriceCurrency
+	if currency == "" {
+		currency = "USD"
+	}
+	daysMin, daysMax := parseAliExpressDeliveryDays(matched.ShipToDays)
+
+	now := time.Now()
+	offer := &models.Offer{
+		ID:                 uuid.New(),
+		ProductID:          product.ID,
+		Source:             "aliexpress",
+		Seller:             seller,
+		PriceAmount:        money.FromMajor(matchedPrice, currency).Amount,
+		Currency:           currency,
+		ShippingToUSAmount: 0, // Will be calculated by shipping calculator
+		TotalToUSAmount:    0, // Will be calculated by shipping calculator
+		EstDeliveryDaysMin: daysMin,
+		EstDeliveryDaysMax: daysMax,
+		ShipsFromCountry:   strx.Ptr("CN"), // for the import duty estimate
+		// The affiliate API only lists products available to ship_to_country
+		InStock:            true,
+		AvailabilityStatus: strx.Ptr("in_stock"),
+		URL:                strx.NonEmptyPtr(offerURL),
+		CanonicalURL:       strx.NonEmptyPtr(matched.DetailURL), // the promotion link is a redirect
+		SellerRating:       aliExpressSellerRating(matched.EvaluateRate),
+		PriceUpdatedAt:     now,
+		FetchedAt:          now,
+	}
+	return []*models.Offer{offer}, nil
+}
+
+// aliExpressDays matches the day counts of ship_to_days: "15" in "ship to US in 15
+// days", or both ends of "7-15 days"
+var aliExpressDays = regexp.MustCompile(`(\d+)(?:\s*[-~]\s*(\d+))?`)
+
+// parseAliExpressDeliveryDays parses ship_to_days into the delivery estimate: a range
+// sets both ends, a single count ("in 15 days") only the latest day
+func parseAliExpressDeliveryDays(shipToDays string) (*int, *int) {
+	match := aliExpressDays.FindStringSubmatch(shipToDays)
+	if match == nil {
+		return nil, nil
+	}
+	first, err := strconv.Atoi(match[1])
+	if err != nil || first <= 0 {
+		return nil, nil
+	}
+	if match[2] == "" {
+		return nil, intPtr(first)
+	}
+	last, err := strconv.Atoi(match[2])
+	if err != nil || last < first {
+		return nil, intPtr(first)
+	}
+	return intPtr(first), intPtr(last)
+}
+
+// aliExpressSellerRating converts the positive feedback rate ("97.4%") to the 0-5 stars
+// of Offer.SellerRating, or nil without one
+func aliExpressSellerRating(evaluateRate string) *float64 {
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(evaluateRate), "%"), 64)
+	if err != nil || rate <= 0 || rate > 100 {
+		return nil
+	}
+	stars := rate / 100 * 5
+	return &stars
+}
+
+// call calls an affiliate API method with params and returns its products raw, so they
+// can be stored with the listing and re-parsed later (see ParseRaw)
+func (p *AliExpressProvider) call(ctx context.Context, method string, params url.Values) ([]json.RawMessage, error) {
+	if !p.enabled {
+		return nil, fmt.Errorf("%w: AliExpress API (ALIEXPRESS_APP_KEY or ALIEXPRESS_APP_SECRET not set)", ErrNotEnabled)
+	}
+
+	params.Set("method", method)
+	params.Set("app_key", p.appKey)
+	params.Set("sign_method", "sha256")
+	params.Set("timestamp", strconv.FormatInt(p.now().UnixMilli(), 10))
+	params.Set("target_currency", "USD")
+	params.Set("target_language", "EN")
+	if method == "aliexpress.affiliate.productdetail.get" {
+		params.Set("country", "US")
+	} else {
+		params.Set("ship_to_country", "US")
+	}
+	if p.trackingID != "" {
+		params.Set("tracking_id", p.trackingID)
+	}
+	params.Set("sign", p.sign(params))
+	requestURL := p.apiURL + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", p.httpClient.UserAgent("aliexpress"))
+	req.Header.Set("Accept", "application/json")
+
+	// For API endpoints, we use direct HTTP client (robots.txt check is not needed for API)
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("aliexpress")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from AliExpress API: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp.StatusCode, fmt.Sprintf("AliExpress API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
+	}
+	return parseAliExpressResponse(method, body)
+}
+
+// sign computes the Open Platform signature of params: HMAC-SHA256 with the app secret
+// over the parameters sorted by name, each name followed by its value
+func (p *AliExpressProvider) sign(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if key != "sign" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha256.New, []byte(p.appSecret))
+	for _, key := range keys {
+		mac.Write([]byte(key))
+		mac.Write([]byte(params.Get(key)))
+	}
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// aliExpressNoResults is the resp_code of a query without results
+const aliExpressNoResults = 405
+
+// parseAliExpressResponse returns the products of a method's response. Errors come with
+// status 200: as an error_response for the call itself (credentials, signature, call
+// limits), or as a resp_code other than 200 for the query.
+func parseAliExpressResponse(method string, body []byte) ([]json.RawMessage, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse AliExpress API response: %w", ErrParse, err)
+	}
+	if raw, ok := envelope["error_response"]; ok {
+		var apiError struct {
+			Code    string `json:"code"`
+			Message string `json:"msg"`
+		}
+		json.Unmarshal(raw, &apiError)
+		message := fmt.Sprintf("AliExpress API error %s: %s", apiError.Code, apiError.Message)
+		switch {
+		case strings.Contains(apiError.Code, "Signature") || strings.Contains(strings.ToLower(apiError.Code), "appkey"):
+			return nil, fmt.Errorf("%w: %s", ErrAuth, message)
+		case strings.Contains(apiError.Code, "Limit"):
+			return nil, fmt.Errorf("%w: %s", ErrRateLimited, message)
+		}
+		return nil, fmt.Errorf("%s", message)
+	}
+
+	raw, ok := envelope[strings.ReplaceAll(method, ".", "_")+"_response"]
+	if !ok {
+		return nil, fmt.Errorf("%w: AliExpress API response without %s result", ErrParse, method)
+	}
+	var response struct {
+		RespResult struct {
+			RespCode int    `json:"resp_code"`
+			RespMsg  string `json:"resp_msg"`
+			Result   struct {
+				Products struct {
+					Product []json.RawMessage `json:"product"`
+				} `json:"products"`
+			} `json:"result"`
+		} `json:"resp_result"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse AliExpress API response: %w", ErrParse, err)
+	}
+	switch response.RespResult.RespCode {
+	case http.StatusOK:
+		return response.RespResult.Result.Products.Product, nil
+	case aliExpressNoResults:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("AliExpress API returned code %d: %s", response.RespResult.RespCode, response.RespResult.RespMsg)
+}
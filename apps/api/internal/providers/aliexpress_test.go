@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAliExpressDeliveryDays(t *testing.T) {
+	tests := []struct {
+		shipToDays       string
+		wantMin, wantMax int // 0: nil
+	}{
+		{"7-15", 7, 15},
+		{"10 - 20 days", 10, 20},
+		{"ship to US in 12 days", 0, 12},
+		{"15", 0, 15},
+		{"20-10", 0, 20},
+		{"", 0, 0},
+		{"ship to US", 0, 0},
+	}
+	for _, tt := range tests {
+		gotMin, gotMax := parseAliExpressDeliveryDays(tt.shipToDays)
+		if derefInt(gotMin) != tt.wantMin || derefInt(gotMax) != tt.wantMax {
+			t.Errorf("parseAliExpressDeliveryDays(%q) = %d-%d, want %d-%d", tt.shipToDays, derefInt(gotMin), derefInt(gotMax), tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func derefInt(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func TestAliExpressSellerRating(t *testing.T) {
+	if got := aliExpressSellerRating("90%"); got == nil || *got != 4.5 {
+		t.Errorf("aliExpressSellerRating(90%%) = %v, want 4.5", got)
+	}
+	for _, rate := range []string{"", "n/a", "0%", "120%"} {
+		if got := aliExpressSellerRating(rate); got != nil {
+			t.Errorf("aliExpressSellerRating(%q) = %v, want nil", rate, *got)
+		}
+	}
+}
+
+func TestParseAliExpressResponseErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		want error // nil: a plain error
+	}{
+		{`{"error_response":{"type":"ISV","code":"IncompleteSignature","msg":"The request signature does not conform to platform standards"}}`, ErrAuth},
+		{`{"error_response":{"type":"ISV","code":"InvalidAppKey","msg":"Invalid app key"}}`, ErrAuth},
+		{`{"error_response":{"type":"ISP","code":"ApiCallLimit","msg":"Api call limit"}}`, ErrRateLimited},
+		{`{"aliexpress_affiliate_product_query_response":{"resp_result":{"resp_code":402,"resp_msg":"Invalid input parameters"}}}`, nil},
+		{`{"other_response":{}}`, ErrParse},
+	}
+	for _, tt := range tests {
+		_, err := parseAliExpressResponse("aliexpress.affiliate.product.query", []byte(tt.body))
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("parseAliExpressResponse(%s) = %v, want %v", tt.body, err, tt.want)
+		}
+	}
+
+	products, err := parseAliExpressResponse("aliexpress.affiliate.product.query",
+		[]byte(`{"aliexpress_affiliate_product_query_response":{"resp_result":{"resp_code":405,"resp_msg":"The result is empty"}}}`))
+	if err != nil || len(products) != 0 {
+		t.Errorf("empty result = %v, %v, want no products", products, err)
+	}
+}
@@ -6,24 +6,49 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pricecompare/api/internal/models"
 	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/locale"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/resolver"
+	"github.com/pricecompare/api/internal/util/strx"
 )
 
 // AmazonOfficialProvider implements Amazon Product Advertising API 5.0
 type AmazonOfficialProvider struct {
-	httpClient     *httpclient.Client
-	accessKey      string
-	secretKey      string
-	associateTag   string
-	apiEndpoint    string
-	apiRegion      string
-	enabled        bool
+	httpClient    *httpclient.Client
+	accessKey     string
+	secretKey     string
+	associateTag  string
+	apiEndpoint   string
+	apiRegion     string
+	enabled       bool
+	locale        string // Optional BCP 47 locale, see SetLocale
+	marketplace   string
+	fixedEndpoint bool // AMAZON_API_ENDPOINT is set, SetLocale keeps apiEndpoint and apiRegion
+}
+
+// amazonMarketplace is a PA-API marketplace and the host and AWS region serving it
+type amazonMarketplace struct {
+	marketplace string
+	host        string
+	region      string
+}
+
+// amazonMarketplaces maps locale regions to PA-API marketplaces
+var amazonMarketplaces = map[string]amazonMarketplace{
+	"US": {"www.amazon.com", "webservices.amazon.com", "us-east-1"},
+	"JP": {"www.amazon.co.jp", "webservices.amazon.co.jp", "us-west-2"},
+	"GB": {"www.amazon.co.uk", "webservices.amazon.co.uk", "eu-west-1"},
+	"DE": {"www.amazon.de", "webservices.amazon.de", "eu-west-1"},
+	"FR": {"www.amazon.fr", "webservices.amazon.fr", "eu-west-1"},
+	"CA": {"www.amazon.ca", "webservices.amazon.ca", "us-east-1"},
 }
 
 // NewAmazonOfficialProvider creates a new Amazon official API provider
@@ -43,25 +68,56 @@ func NewAmazonOfficialProvider(httpClient *httpclient.Client) *AmazonOfficialPro
 	enabled := accessKey != "" && secretKey != "" && associateTag != ""
 
 	return &AmazonOfficialProvider{
-		httpClient:   httpClient,
-		accessKey:    accessKey,
-		secretKey:    secretKey,
-		associateTag: associateTag,
-		apiEndpoint:  apiEndpoint,
-		apiRegion:    apiRegion,
-		enabled:      enabled,
+		httpClient:    httpClient,
+		accessKey:     accessKey,
+		secretKey:     secretKey,
+		associateTag:  associateTag,
+		apiEndpoint:   apiEndpoint,
+		apiRegion:     apiRegion,
+		enabled:       enabled,
+		marketplace:   "www.amazon.com",
+		fixedEndpoint: os.Getenv("AMAZON_API_ENDPOINT") != "",
 	}
 }
 
+// SetLocale requests listings in locale (e.g. "ja-JP") via LanguagesOfPreference and
+// switches to the marketplace of its region and the PA-API host serving it
+// (www.amazon.co.jp via webservices.amazon.co.jp in us-west-2 for JP). Regions without
+// a known marketplace use www.amazon.com. An AMAZON_API_ENDPOINT set in the environment
+// keeps its endpoint and region.
+func (p *AmazonOfficialProvider) SetLocale(loc string) {
+	p.locale = loc
+	marketplace, ok := amazonMarketplaces[locale.Region(loc)]
+	if !ok {
+		marketplace = amazonMarketplaces["US"]
+	}
+	p.marketplace = marketplace.marketplace
+	if !p.fixedEndpoint {
+		p.apiEndpoint = marketplace.host
+		p.apiRegion = marketplace.region
+	}
+}
+
+// MatchURL recognizes Amazon product pages, see URLMatcher
+func (p *AmazonOfficialProvider) MatchURL(u *url.URL) (string, string, bool) {
+	return resolver.AmazonASIN(u)
+}
+
 // IsEnabled returns whether the provider is enabled (has required API keys)
 func (p *AmazonOfficialProvider) IsEnabled() bool {
 	return p.enabled
 }
 
+// Ping verifies the signing keys and associate tag with a minimal SearchItems request
+func (p *AmazonOfficialProvider) Ping(ctx context.Context) error {
+	_, err := p.Search(ctx, "test")
+	return err
+}
+
 // Search searches for products using Amazon Product Advertising API
 func (p *AmazonOfficialProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
 	if !p.enabled {
-		return nil, fmt.Errorf("Amazon API provider is not enabled (AMAZON_ACCESS_KEY, AMAZON_SECRET_KEY, or AMAZON_ASSOCIATE_TAG not set)")
+		return nil, fmt.Errorf("%w: Amazon API (AMAZON_ACCESS_KEY, AMAZON_SECRET_KEY, or AMAZON_ASSOCIATE_TAG not set)", ErrNotEnabled)
 	}
 
 	if query == "" {
@@ -70,14 +126,14 @@ func (p *AmazonOfficialProvider) Search(ctx context.Context, query string) ([]Pr
 
 	// Build PA-API 5.0 request
 	params := map[string]string{
-		"Operation":     "SearchItems",
-		"Keywords":      query,
-		"SearchIndex":   "All",
-		"ItemCount":    "10",
-		"Resources":    "Images.Primary.Large,ItemInfo.Title,ItemInfo.ByLineInfo,ItemInfo.ExternalIds",
-		"PartnerTag":   p.associateTag,
-		"PartnerType":  "Associates",
-		"Marketplace": "www.amazon.com",
+		"Operation":   "SearchItems",
+		"Keywords":    query,
+		"SearchIndex": "All",
+		"ItemCount":   "10",
+		"Resources":   "Images.Primary.Large,ItemInfo.Title,ItemInfo.ByLineInfo,ItemInfo.ExternalIds",
+		"PartnerTag":  p.associateTag,
+		"PartnerType": "Associates",
+		"Marketplace": p.marketplace,
 	}
 
 	// Create signed request
@@ -87,50 +143,26 @@ func (p *AmazonOfficialProvider) Search(ctx context.Context, query string) ([]Pr
 	}
 
 	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("amazon")}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute Amazon API request: %w", err)
+		return nil, fmt.Errorf("failed to execute Amazon API request: %w", redactURLError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Amazon API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, StatusError(resp.StatusCode, fmt.Sprintf("Amazon API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
 	}
 
-	// Parse response
+	// Parse response. Items are kept raw so they can be stored with the listing and
+	// re-parsed later (see ParseRaw).
 	var apiResponse struct {
 		SearchResult struct {
-			Items []struct {
-				ASIN string `json:"ASIN"`
-				DetailPageURL string `json:"DetailPageURL"`
-				Images struct {
-					Primary struct {
-						Large struct {
-							URL string `json:"URL"`
-						} `json:"Large"`
-					} `json:"Primary"`
-				} `json:"Images"`
-				ItemInfo struct {
-					Title struct {
-						DisplayValue string `json:"DisplayValue"`
-					} `json:"Title"`
-					ByLineInfo struct {
-						Brand struct {
-							DisplayValue string `json:"DisplayValue"`
-						} `json:"Brand"`
-					} `json:"ByLineInfo"`
-					ExternalIds struct {
-						EANs struct {
-							DisplayValues []string `json:"DisplayValues"`
-						} `json:"EANs"`
-						UPCs struct {
-							DisplayValues []string `json:"DisplayValues"`
-						} `json:"UPCs"`
-					} `json:"ExternalIds"`
-				} `json:"ItemInfo"`
-			} `json:"Items"`
+			Items []json.RawMessage `json:"Items"`
 		} `json:"SearchResult"`
 	}
 
@@ -140,37 +172,105 @@ func (p *AmazonOfficialProvider) Search(ctx context.Context, query string) ([]Pr
 	}
 
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse Amazon API response: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse Amazon API response: %w", ErrParse, err)
 	}
 
 	// Convert to ProductCandidate
 	candidates := make([]ProductCandidate, 0, len(apiResponse.SearchResult.Items))
-	for _, item := range apiResponse.SearchResult.Items {
-		brand := ""
-		if item.ItemInfo.ByLineInfo.Brand.DisplayValue != "" {
-			brand = item.ItemInfo.ByLineInfo.Brand.DisplayValue
+	for _, raw := range apiResponse.SearchResult.Items {
+		candidate, err := p.ParseRaw(raw)
+		if err != nil {
+			return nil, err
 		}
+		candidates = append(candidates, *candidate)
+	}
 
-		imageURL := ""
-		if item.Images.Primary.Large.URL != "" {
-			imageURL = item.Images.Primary.Large.URL
-		}
+	return candidates, nil
+}
+
+// amazonSchemaVersion is the version of ParseRaw's mapping; raise it when the mapping
+// changes so stored listings are re-parsed by the reprocess_raw job
+const amazonSchemaVersion = 1
+
+// amazonSearchItem is one item of a PA-API SearchItems response
+type amazonSearchItem struct {
+	ASIN          string `json:"ASIN"`
+	DetailPageURL string `json:"DetailPageURL"`
+	Images        struct {
+		Primary struct {
+			Large struct {
+				URL string `json:"URL"`
+			} `json:"Large"`
+		} `json:"Primary"`
+	} `json:"Images"`
+	ItemInfo struct {
+		Title struct {
+			DisplayValue string `json:"DisplayValue"`
+		} `json:"Title"`
+		ByLineInfo struct {
+			Brand struct {
+				DisplayValue string `json:"DisplayValue"`
+			} `json:"Brand"`
+		} `json:"ByLineInfo"`
+		ExternalIds struct {
+			EANs struct {
+				DisplayValues []string `json:"DisplayValues"`
+			} `json:"EANs"`
+			UPCs struct {
+				DisplayValues []string `json:"DisplayValues"`
+			} `json:"UPCs"`
+		} `json:"ExternalIds"`
+	} `json:"ItemInfo"`
+}
 
-		candidates = append(candidates, ProductCandidate{
-			Title:    item.ItemInfo.Title.DisplayValue,
-			Brand:    stringPtr(brand),
-			ImageURL: stringPtr(imageURL),
-			Source:   "amazon",
-		})
+// RawSchemaVersion implements RawParser
+func (p *AmazonOfficialProvider) RawSchemaVersion() int {
+	return amazonSchemaVersion
+}
+
+// ParseRaw maps one SearchItems item to a candidate
+func (p *AmazonOfficialProvider) ParseRaw(raw []byte) (*ProductCandidate, error) {
+	var item amazonSearchItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Amazon item: %w", ErrParse, err)
 	}
 
-	return candidates, nil
+	brand := ""
+	if item.ItemInfo.ByLineInfo.Brand.DisplayValue != "" {
+		brand = item.ItemInfo.ByLineInfo.Brand.DisplayValue
+	}
+
+	imageURL := ""
+	if item.Images.Primary.Large.URL != "" {
+		imageURL = item.Images.Primary.Large.URL
+	}
+
+	externalIdentifiers := []CandidateIdentifier{}
+	for _, upc := range item.ItemInfo.ExternalIds.UPCs.DisplayValues {
+		externalIdentifiers = append(externalIdentifiers, CandidateIdentifier{Type: "UPC", Value: upc})
+	}
+	for _, ean := range item.ItemInfo.ExternalIds.EANs.DisplayValues {
+		externalIdentifiers = append(externalIdentifiers, CandidateIdentifier{Type: "EAN", Value: ean})
+	}
+
+	return &ProductCandidate{
+		Title:               item.ItemInfo.Title.DisplayValue,
+		Brand:               strx.NonEmptyPtr(brand),
+		ImageURL:            strx.NonEmptyPtr(imageURL),
+		Source:              "amazon",
+		Identifier:          strx.NonEmptyPtr(item.ASIN),
+		SourceURL:           strx.NonEmptyPtr(item.DetailPageURL),
+		ExternalIdentifiers: externalIdentifiers,
+		Language:            locale.Language(p.locale),
+		Raw:                 raw,
+		SchemaVersion:       amazonSchemaVersion,
+	}, nil
 }
 
 // FetchOffers fetches offers for a product using Amazon Product Advertising API
-func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *models.Product) ([]*models.Offer, error) {
+func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
 	if !p.enabled {
-		return nil, fmt.Errorf("Amazon API provider is not enabled (AMAZON_ACCESS_KEY, AMAZON_SECRET_KEY, or AMAZON_ASSOCIATE_TAG not set)")
+		return nil, fmt.Errorf("%w: Amazon API (AMAZON_ACCESS_KEY, AMAZON_SECRET_KEY, or AMAZON_ASSOCIATE_TAG not set)", ErrNotEnabled)
 	}
 
 	// Try to find ASIN from product_identifiers
@@ -193,14 +293,14 @@ func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *model
 	// Fetch item details using GetItems operation
 	// We need ASIN - for now, search and use first result
 	searchParams := map[string]string{
-		"Operation":     "SearchItems",
-		"Keywords":      product.Title,
-		"SearchIndex":   "All",
-		"ItemCount":    "1",
-		"Resources":    "Offers.Listings.Price,Offers.Listings.Availability,Offers.Listings.DeliveryInfo,ItemInfo.Title,ItemInfo.ByLineInfo,ItemInfo.ExternalIds",
-		"PartnerTag":   p.associateTag,
-		"PartnerType":  "Associates",
-		"Marketplace": "www.amazon.com",
+		"Operation":   "SearchItems",
+		"Keywords":    product.Title,
+		"SearchIndex": "All",
+		"ItemCount":   "1",
+		"Resources":   "Offers.Listings.Price,Offers.Listings.Availability,Offers.Listings.DeliveryInfo,ItemInfo.Title,ItemInfo.ByLineInfo,ItemInfo.ExternalIds",
+		"PartnerTag":  p.associateTag,
+		"PartnerType": "Associates",
+		"Marketplace": p.marketplace,
 	}
 
 	req, err := p.createSignedRequest(ctx, searchParams)
@@ -208,36 +308,40 @@ func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *model
 		return nil, fmt.Errorf("failed to create signed request: %w", err)
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("amazon")}
 	resp, err := client.Do(req)
 	if err != nil {
-		return p.createOffersFromSearch(ctx, product, candidates)
+		return nil, fmt.Errorf("failed to execute Amazon API request: %w", redactURLError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return p.createOffersFromSearch(ctx, product, candidates)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, StatusError(resp.StatusCode, fmt.Sprintf("Amazon API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
 	}
 
 	var itemResponse struct {
 		SearchResult struct {
 			Items []struct {
-				ASIN string `json:"ASIN"`
+				ASIN          string `json:"ASIN"`
 				DetailPageURL string `json:"DetailPageURL"`
-				Offers struct {
+				Offers        struct {
 					Listings []struct {
 						Price struct {
-							Amount    float64 `json:"Amount"`
-							Currency  string  `json:"Currency"`
+							Amount   float64 `json:"Amount"`
+							Currency string  `json:"Currency"`
 						} `json:"Price"`
 						Availability struct {
 							Message string `json:"Message"`
 							Type    string `json:"Type"`
 						} `json:"Availability"`
 						DeliveryInfo struct {
-							IsAmazonFulfilled bool `json:"IsAmazonFulfilled"`
+							IsAmazonFulfilled      bool `json:"IsAmazonFulfilled"`
 							IsFreeShippingEligible bool `json:"IsFreeShippingEligible"`
-							IsPrimeEligible bool `json:"IsPrimeEligible"`
+							IsPrimeEligible        bool `json:"IsPrimeEligible"`
 						} `json:"DeliveryInfo"`
 						MerchantInfo struct {
 							Name string `json:"Name"`
@@ -250,22 +354,26 @@ func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *model
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return p.createOffersFromSearch(ctx, product, candidates)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if err := json.Unmarshal(body, &itemResponse); err != nil {
-		return p.createOffersFromSearch(ctx, product, candidates)
+		return nil, fmt.Errorf("%w: failed to parse Amazon API response: %w", ErrParse, err)
 	}
 
 	if len(itemResponse.SearchResult.Items) == 0 {
-		return p.createOffersFromSearch(ctx, product, candidates)
+		return []*models.Offer{}, nil
 	}
 
 	item := itemResponse.SearchResult.Items[0]
 	offers := make([]*models.Offer, 0, len(item.Offers.Listings))
 
 	for _, listing := range item.Offers.Listings {
-		priceAmount := int(listing.Price.Amount * 100) // Convert to cents
+		// Listings without a price (e.g. the Price resource was not returned) cannot be compared
+		if listing.Price.Amount <= 0 || listing.Price.Currency == "" {
+			continue
+		}
+		priceAmount := money.FromMajor(listing.Price.Amount, listing.Price.Currency).Amount // minor units of the listing currency
 		availabilityStatus := "in_stock"
 		inStock := true
 		if listing.Availability.Type == "Now" || strings.Contains(strings.ToLower(listing.Availability.Message), "in stock") {
@@ -289,17 +397,20 @@ func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *model
 			Seller:             seller,
 			PriceAmount:        priceAmount,
 			Currency:           listing.Price.Currency,
-			ShippingToUSAmount: 0, // Will be calculated by shipping calculator
-			TotalToUSAmount:    0, // Will be calculated by shipping calculator
+			ShippingToUSAmount: 0,         // Will be calculated by shipping calculator
+			TotalToUSAmount:    0,         // Will be calculated by shipping calculator
 			EstDeliveryDaysMin: intPtr(1), // Prime eligible items
 			EstDeliveryDaysMax: intPtr(3),
 			InStock:            inStock,
-			AvailabilityStatus: stringPtr(availabilityStatus),
-			URL:                stringPtr(item.DetailPageURL),
+			AvailabilityStatus: strx.NonEmptyPtr(availabilityStatus),
+			URL:                strx.NonEmptyPtr(item.DetailPageURL),
 			PriceUpdatedAt:     now,
 			FetchedAt:          now,
 		}
 
+		// Prime and free-shipping-eligible listings ship at no cost
+		offer.FreeShipping = listing.DeliveryInfo.IsPrimeEligible || listing.DeliveryInfo.IsFreeShippingEligible
+
 		// Adjust delivery days based on Prime eligibility
 		if listing.DeliveryInfo.IsPrimeEligible {
 			offer.EstDeliveryDaysMin = intPtr(1)
@@ -312,54 +423,30 @@ func (p *AmazonOfficialProvider) FetchOffers(ctx context.Context, product *model
 		offers = append(offers, offer)
 	}
 
-	// If no offers found, create a default offer
-	if len(offers) == 0 {
-		return p.createOffersFromSearch(ctx, product, candidates)
-	}
-
 	return offers, nil
 }
 
-// createOffersFromSearch creates offers from search results when detailed item fetch fails
-func (p *AmazonOfficialProvider) createOffersFromSearch(ctx context.Context, product *models.Product, candidates []ProductCandidate) ([]*models.Offer, error) {
-	now := time.Now()
-	offer := &models.Offer{
-		ID:                 uuid.New(),
-		ProductID:          product.ID,
-		Source:             "amazon",
-		Seller:             "Amazon",
-		PriceAmount:        0, // Would need to fetch actual price
-		Currency:           "USD",
-		ShippingToUSAmount: 0,
-		TotalToUSAmount:    0,
-		EstDeliveryDaysMin: intPtr(1),
-		EstDeliveryDaysMax: intPtr(3),
-		InStock:            true,
-		AvailabilityStatus: stringPtr("in_stock"),
-		PriceUpdatedAt:     now,
-		FetchedAt:          now,
-	}
-
-	return []*models.Offer{offer}, nil
-}
-
 // createSignedRequest creates a signed request for Amazon Product Advertising API 5.0
 // PA-API 5.0 uses POST requests with JSON body and AWS Signature Version 4
 func (p *AmazonOfficialProvider) createSignedRequest(ctx context.Context, params map[string]string) (*http.Request, error) {
 	// PA-API 5.0 uses POST with JSON body
 	// For simplicity, we'll use a simplified approach with query parameters
 	// In production, you should use AWS SDK or proper AWS Signature Version 4
-	
+
 	// Build request payload (simplified - actual PA-API 5.0 uses JSON)
 	payload := map[string]interface{}{
-		"Operation":     params["Operation"],
-		"Keywords":      params["Keywords"],
-		"SearchIndex":   params["SearchIndex"],
-		"ItemCount":    params["ItemCount"],
-		"Resources":    params["Resources"],
-		"PartnerTag":   p.associateTag,
-		"PartnerType":  "Associates",
-		"Marketplace":  params["Marketplace"],
+		"Operation":   params["Operation"],
+		"Keywords":    params["Keywords"],
+		"SearchIndex": params["SearchIndex"],
+		"ItemCount":   params["ItemCount"],
+		"Resources":   params["Resources"],
+		"PartnerTag":  p.associateTag,
+		"PartnerType": "Associates",
+		"Marketplace": params["Marketplace"],
+	}
+	if canonical, err := locale.Parse(p.locale); err == nil {
+		// PA-API spells locales with an underscore, e.g. "ja_JP"
+		payload["LanguagesOfPreference"] = []string{strings.ReplaceAll(canonical, "-", "_")}
 	}
 
 	jsonBody, err := json.Marshal(payload)
@@ -376,7 +463,7 @@ func (p *AmazonOfficialProvider) createSignedRequest(ctx context.Context, params
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("User-Agent", "PriceCompareBot/1.0")
+	req.Header.Set("User-Agent", p.httpClient.UserAgent("amazon"))
 	req.Header.Set("X-Amz-Target", "com.amazon.paapi5.v1.ProductAdvertisingAPIv1.SearchItems")
 	req.Header.Set("X-Amz-Date", time.Now().UTC().Format("20060102T150405Z"))
 
@@ -387,4 +474,3 @@ func (p *AmazonOfficialProvider) createSignedRequest(ctx context.Context, params
 
 	return req, nil
 }
-
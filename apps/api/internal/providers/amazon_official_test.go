@@ -0,0 +1,29 @@
+package providers
+
+import "testing"
+
+func TestAmazonParseRawIdentifiers(t *testing.T) {
+	raw := []byte(`{
+		"ASIN": "B0BXYCS74H",
+		"DetailPageURL": "https://www.amazon.com/dp/B0BXYCS74H",
+		"ItemInfo": {
+			"Title": {"DisplayValue": "Sony WH-1000XM5"},
+			"ExternalIds": {
+				"UPCs": {"DisplayValues": ["027242923782"]},
+				"EANs": {"DisplayValues": ["4548736132566"]}
+			}
+		}
+	}`)
+
+	candidate, err := (&AmazonOfficialProvider{}).ParseRaw(raw)
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+	if candidate.Identifier == nil || *candidate.Identifier != "B0BXYCS74H" {
+		t.Errorf("identifier = %v, want the ASIN", candidate.Identifier)
+	}
+	want := []CandidateIdentifier{{Type: "UPC", Value: "027242923782"}, {Type: "EAN", Value: "4548736132566"}}
+	if len(candidate.ExternalIdentifiers) != len(want) || candidate.ExternalIdentifiers[0] != want[0] || candidate.ExternalIdentifiers[1] != want[1] {
+		t.Errorf("external identifiers = %+v, want %+v", candidate.ExternalIdentifiers, want)
+	}
+}
@@ -0,0 +1,33 @@
+package providers
+
+// Kinds of data behind an offer, by the type of provider that fetched it
+const (
+	KindOfficialAPI = "official_api" // the retailer's own product API
+	KindShoppingAPI = "shopping_api" // a shopping search API reporting other merchants' offers
+	KindLiveFetch   = "live_fetch"   // public pages fetched honoring robots.txt and rate limits
+	KindDemo        = "demo"         // built-in or sample data, never a real price
+	KindManual      = "manual"       // entered by an admin, e.g. a price quoted over the phone
+	KindUnknown     = "unknown"      // a source no longer (or not yet) known to this build
+)
+
+// sourceKinds maps each provider's offer Source to the kind of its data. Offers keep
+// their source after a provider is disabled, so this does not depend on what is registered.
+var sourceKinds = map[string]string{
+	"amazon":          KindOfficialAPI, // AmazonOfficialProvider (PA-API)
+	"walmart":         KindOfficialAPI, // WalmartOfficialProvider
+	"rakuten":         KindOfficialAPI, // RakutenProvider (Ichiba Item Search API)
+	"aliexpress":      KindOfficialAPI, // AliExpressProvider (affiliate API)
+	"google_shopping": KindShoppingAPI, // GoogleShoppingProvider (SerpAPI)
+	"live":            KindLiveFetch,   // LiveProvider
+	"demo":            KindDemo,        // DemoProvider
+	"public_html":     KindDemo,        // PublicHTMLProvider reads bundled sample pages
+	"manual":          KindManual,      // POST /api/admin/offers, no provider
+}
+
+// SourceKind returns the kind of data of an offer source
+func SourceKind(source string) string {
+	if kind, ok := sourceKinds[source]; ok {
+		return kind
+	}
+	return KindUnknown
+}
@@ -0,0 +1,26 @@
+package providers
+
+import "testing"
+
+func TestSourceKind(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"amazon", KindOfficialAPI},
+		{"rakuten", KindOfficialAPI},
+		{"aliexpress", KindOfficialAPI},
+		{"google_shopping", KindShoppingAPI},
+		{"walmart", KindOfficialAPI},
+		{"live", KindLiveFetch},
+		{"demo", KindDemo},
+		{"public_html", KindDemo},
+		{"ebay", KindUnknown},
+		{"", KindUnknown},
+	}
+	for _, tt := range tests {
+		if got := SourceKind(tt.source); got != tt.want {
+			t.Errorf("SourceKind(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/util/strx"
 )
 
 type DemoProvider struct {
@@ -22,22 +23,22 @@ func NewDemoProvider() *DemoProvider {
 	provider.mockProducts = []ProductCandidate{
 		{
 			Title:    "Wireless Bluetooth Headphones",
-			Brand:    stringPtr("AudioTech"),
-			Model:    stringPtr("ATH-500BT"),
-			ImageURL: stringPtr("https://images.unsplash.com/photo-1505740420928-5e560c06d30e?w=400&h=300&fit=crop"),
+			Brand:    strx.Ptr("AudioTech"),
+			Model:    strx.Ptr("ATH-500BT"),
+			ImageURL: strx.Ptr("https://images.unsplash.com/photo-1505740420928-5e560c06d30e?w=400&h=300&fit=crop"),
 			Source:   "demo",
 		},
 		{
 			Title:    "Smart Watch Pro",
-			Brand:    stringPtr("TechTime"),
-			Model:    stringPtr("TT-SW-2024"),
-			ImageURL: stringPtr("https://images.unsplash.com/photo-1523275335684-37898b6baf30?w=400&h=300&fit=crop"),
+			Brand:    strx.Ptr("TechTime"),
+			Model:    strx.Ptr("TT-SW-2024"),
+			ImageURL: strx.Ptr("https://images.unsplash.com/photo-1523275335684-37898b6baf30?w=400&h=300&fit=crop"),
 			Source:   "demo",
 		},
 		{
 			Title:    "USB-C Charging Cable",
-			Brand:    stringPtr("ChargeMax"),
-			ImageURL: stringPtr("https://images.unsplash.com/photo-1587825140708-dfaf72ae4b04?w=400&h=300&fit=crop"),
+			Brand:    strx.Ptr("ChargeMax"),
+			ImageURL: strx.Ptr("https://images.unsplash.com/photo-1587825140708-dfaf72ae4b04?w=400&h=300&fit=crop"),
 			Source:   "demo",
 		},
 	}
@@ -48,10 +49,8 @@ func NewDemoProvider() *DemoProvider {
 func (p *DemoProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
 	// Simple mock search - return all products if query matches any word
 	results := []ProductCandidate{}
-	queryLower := toLower(query)
-
 	for _, product := range p.mockProducts {
-		if contains(toLower(product.Title), queryLower) {
+		if strx.ContainsFold(product.Title, query) {
 			results = append(results, product)
 		}
 	}
@@ -59,7 +58,7 @@ func (p *DemoProvider) Search(ctx context.Context, query string) ([]ProductCandi
 	return results, nil
 }
 
-func (p *DemoProvider) FetchOffers(ctx context.Context, product *models.Product) ([]*models.Offer, error) {
+func (p *DemoProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
 	// Generate mock offers based on product
 	offers := []*models.Offer{
 		{
@@ -74,7 +73,7 @@ func (p *DemoProvider) FetchOffers(ctx context.Context, product *models.Product)
 			EstDeliveryDaysMin: intPtr(3),
 			EstDeliveryDaysMax: intPtr(5),
 			InStock:            true,
-			URL:                stringPtr("https://example.com/seller-a/product"),
+			URL:                strx.Ptr("https://example.com/seller-a/product"),
 			FetchedAt:          time.Now(),
 		},
 		{
@@ -89,7 +88,7 @@ func (p *DemoProvider) FetchOffers(ctx context.Context, product *models.Product)
 			EstDeliveryDaysMin: intPtr(5),
 			EstDeliveryDaysMax: intPtr(7),
 			InStock:            true,
-			URL:                stringPtr("https://example.com/seller-b/product"),
+			URL:                strx.Ptr("https://example.com/seller-b/product"),
 			FetchedAt:          time.Now(),
 		},
 		{
@@ -104,32 +103,10 @@ func (p *DemoProvider) FetchOffers(ctx context.Context, product *models.Product)
 			EstDeliveryDaysMin: intPtr(7),
 			EstDeliveryDaysMax: intPtr(10),
 			InStock:            false,
-			URL:                stringPtr("https://example.com/seller-c/product"),
+			URL:                strx.Ptr("https://example.com/seller-c/product"),
 			FetchedAt:          time.Now(),
 		},
 	}
 
 	return offers, nil
 }
-
-func toLower(s string) string {
-	lower := ""
-	for _, r := range s {
-		if r >= 'A' && r <= 'Z' {
-			lower += string(r + 32)
-		} else {
-			lower += string(r)
-		}
-	}
-	return lower
-}
-
-func contains(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/ratelimit"
+)
+
+// Kinds of provider failure. Providers wrap them (with %w) so the fetch job can tell
+// with errors.Is whether to skip a query, back off and retry, or stop using the provider
+// for the rest of the run.
+var (
+	ErrNotEnabled  = errors.New("provider is not enabled")        // missing credentials, live fetch disabled or outside the crawl window
+	ErrRateLimited = errors.New("provider rate limit exceeded")   // HTTP 429 after the client's retries
+	ErrAuth        = errors.New("provider rejected credentials")  // HTTP 401 or 403
+	ErrParse       = errors.New("provider response not parsable") // malformed body or unexpected content type
+	ErrNotFound    = errors.New("provider has no such page")      // HTTP 404
+	ErrBlocked     = errors.New("site refused the crawler")       // HTTP 403 from a crawled website, see siteStatusError
+)
+
+// StatusError returns the error of a non-2xx response, wrapping the kind of failure the
+// status code means
+func StatusError(status int, message string) error {
+	switch status {
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", ErrRateLimited, message)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrAuth, message)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, message)
+	}
+	return errors.New(message)
+}
+
+// siteStatusError is StatusError for pages of crawled websites, where a 403 means the
+// site refuses the crawler at that host rather than that credentials are wrong: it is
+// ErrBlocked, which does not stop the provider.
+func siteStatusError(status int, message string) error {
+	if status == http.StatusForbidden {
+		return fmt.Errorf("%w: %s", ErrBlocked, message)
+	}
+	return StatusError(status, message)
+}
+
+// checkContentType is httpclient.CheckContentType with its error marked as ErrParse
+func checkContentType(resp *http.Response, expected httpclient.ContentKind) error {
+	if err := httpclient.CheckContentType(resp, expected); err != nil {
+		return fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	return nil
+}
+
+// fetchError prefixes an httpclient error with message, marking live fetch being
+// disabled or the site's crawl window being closed as ErrNotEnabled and an unexpected
+// content type as ErrParse
+func fetchError(message string, err error) error {
+	switch {
+	case errors.Is(err, httpclient.ErrLiveFetchDisabled), errors.Is(err, httpclient.ErrOutsideCrawlWindow):
+		return fmt.Errorf("%s: %w: %w", message, ErrNotEnabled, err)
+	case errors.Is(err, httpclient.ErrUnexpectedContentType):
+		return fmt.Errorf("%s: %w: %w", message, ErrParse, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// redactURLError removes the query from the URL of a *url.Error, which API providers put
+// their credentials in (api_key, applicationId, app_key, sign): the message of a failed
+// request is logged and stored in provider_fetches.error. Other errors are returned as is.
+func redactURLError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	redacted := *urlErr
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		u.RawQuery, u.Fragment = "", ""
+		redacted.URL = u.String()
+	} else {
+		redacted.URL = ""
+	}
+	return &redacted
+}
+
+// IsFatal reports whether err means the provider cannot serve any request of this run,
+// so further queries would fail the same way. That includes a rate limit wait over the
+// budget (RATE_LIMIT_MAX_WAIT_SECONDS): the limit is set too low for the run's requests.
+func IsFatal(err error) bool {
+	return errors.Is(err, ErrNotEnabled) || errors.Is(err, ErrAuth) || errors.Is(err, ratelimit.ErrRateBudgetExceeded)
+}
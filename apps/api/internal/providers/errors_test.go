@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/ratelimit"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error // nil: no kind
+	}{
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusUnauthorized, ErrAuth},
+		{http.StatusForbidden, ErrAuth},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusInternalServerError, nil},
+	}
+	kinds := []error{ErrNotEnabled, ErrRateLimited, ErrAuth, ErrParse, ErrNotFound}
+
+	for _, tt := range tests {
+		err := StatusError(tt.status, fmt.Sprintf("API returned status %d", tt.status))
+		for _, kind := range kinds {
+			if got := errors.Is(err, kind); got != (kind == tt.want) {
+				t.Errorf("StatusError(%d): errors.Is(%v) = %v", tt.status, kind, got)
+			}
+		}
+	}
+}
+
+func TestSiteStatusError(t *testing.T) {
+	err := siteStatusError(http.StatusForbidden, "search page returned status 403")
+	if !errors.Is(err, ErrBlocked) || errors.Is(err, ErrAuth) || IsFatal(err) {
+		t.Errorf("403 from a site: %v is not a non-fatal ErrBlocked", err)
+	}
+	if err := siteStatusError(http.StatusNotFound, "product page returned status 404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("404 from a site: %v is not ErrNotFound", err)
+	}
+}
+
+func TestFetchError(t *testing.T) {
+	err := fetchError("failed to fetch search page", fmt.Errorf("%w, cannot access external URL", httpclient.ErrLiveFetchDisabled))
+	if !errors.Is(err, ErrNotEnabled) || !IsFatal(err) || !errors.Is(err, httpclient.ErrLiveFetchDisabled) {
+		t.Errorf("disabled live fetch: %v is not a fatal ErrNotEnabled", err)
+	}
+
+	err = fetchError("failed to fetch search page", &httpclient.ContentTypeError{URL: "https://shop.example.com", Expected: httpclient.ContentHTML, ContentType: "application/pdf"})
+	if !errors.Is(err, ErrParse) || IsFatal(err) {
+		t.Errorf("unexpected content type: %v is not a non-fatal ErrParse", err)
+	}
+
+	err = fetchError("failed to fetch search page", fmt.Errorf("rate limit wait failed: %w", ratelimit.ErrRateBudgetExceeded))
+	if !IsFatal(err) {
+		t.Errorf("rate limit wait over budget: %v is not fatal", err)
+	}
+}
+
+func TestRedactURLError(t *testing.T) {
+	err := redactURLError(&url.Error{Op: "Get", URL: "https://app.rakuten.co.jp/services/api/IchibaItem/Search/20220601?applicationId=secret&keyword=tv", Err: context.DeadlineExceeded})
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("redactURLError() = %q, contains the credential", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("redactURLError() = %v, does not wrap the cause", err)
+	}
+}
@@ -0,0 +1,314 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/httpfixture"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+)
+
+// newFixtureClient returns an httpclient.Client answering every request, robots.txt
+// included, from the recorded fixtures in testdata/fixtures. To record new fixtures, run
+// the server with PROVIDER_FIXTURE_MODE=record and PROVIDER_FIXTURE_DIR pointing here.
+func newFixtureClient(t *testing.T) *httpclient.Client {
+	t.Helper()
+	client := httpclient.New(&httpclient.Config{
+		AllowLiveFetch:      true,
+		UserAgent:           "TestBot/1.0",
+		RobotsCacheTTLHours: 24,
+		HTTPTimeoutSeconds:  10,
+		ProviderRateLimits:  make(map[string]httpclient.RateLimitConfig),
+		DefaultRateLimit:    httpclient.RateLimitConfig{RPS: 100, Burst: 100},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	client.SetTransport(httpfixture.NewReplayer("testdata/fixtures"))
+	return client
+}
+
+func newFixtureWalmartProvider(t *testing.T) *WalmartOfficialProvider {
+	return &WalmartOfficialProvider{
+		httpClient: newFixtureClient(t),
+		apiKey:     "test-key",
+		apiBaseURL: "https://walmart-data.p.rapidapi.com",
+		apiHost:    "walmart-data.p.rapidapi.com",
+		enabled:    true,
+	}
+}
+
+func TestWalmartSearchFixture(t *testing.T) {
+	candidates, err := newFixtureWalmartProvider(t).Search(context.Background(), "sony headphones")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("candidates = %+v, want 2 (the empty ad slot skipped)", candidates)
+	}
+	got := candidates[0]
+	if got.Title != "Sony WH-1000XM5 Wireless Noise Canceling Headphones, Black" || got.Identifier == nil || *got.Identifier != "5461164337" || got.Source != "walmart" {
+		t.Errorf("first candidate = %+v", got)
+	}
+	// The stored payload re-parses to the same listing (see RawParser)
+	reparsed, err := newFixtureWalmartProvider(t).ParseRaw(got.Raw)
+	if err != nil || reparsed == nil || reparsed.Title != got.Title || reparsed.SchemaVersion != walmartSchemaVersion {
+		t.Errorf("ParseRaw() = %+v, %v", reparsed, err)
+	}
+}
+
+func TestWalmartFetchOffersFixture(t *testing.T) {
+	offers, err := newFixtureWalmartProvider(t).FetchOffers(context.Background(), &models.Product{Title: "sony headphones"}, "")
+	if err != nil {
+		t.Fatalf("FetchOffers() error = %v", err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("offers = %+v, want 1", offers)
+	}
+	offer := offers[0]
+	if offer.PriceAmount != 29800 || offer.Currency != "USD" || !offer.InStock || !offer.FreeShipping {
+		t.Errorf("offer = %+v, want the in-stock minPrice of $298.00 with free shipping", offer)
+	}
+	if offer.EstDeliveryDaysMin == nil || *offer.EstDeliveryDaysMin != 2 {
+		t.Errorf("EstDeliveryDaysMin = %v, want 2", offer.EstDeliveryDaysMin)
+	}
+}
+
+func newFixtureRakutenProvider(t *testing.T) *RakutenProvider {
+	return &RakutenProvider{
+		httpClient:    newFixtureClient(t),
+		applicationID: "test-app",
+		apiURL:        "https://app.rakuten.co.jp/services/api/IchibaItem/Search/20220601",
+		enabled:       true,
+	}
+}
+
+func TestRakutenSearchFixture(t *testing.T) {
+	candidates, err := newFixtureRakutenProvider(t).Search(context.Background(), "sony headphones")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("candidates = %+v, want 1 (the unnamed item skipped)", candidates)
+	}
+	got := candidates[0]
+	if got.Identifier == nil || *got.Identifier != "sonystore:wh-1000xm5" || got.Source != "rakuten" || got.Language != "ja" || !got.HasPrice {
+		t.Errorf("candidate = %+v", got)
+	}
+	if got.ImageURL == nil || *got.ImageURL != "https://thumbnail.image.rakuten.co.jp/@0_mall/sonystore/cabinet/wh-1000xm5.jpg" {
+		t.Errorf("ImageURL = %v, want the full-size image", got.ImageURL)
+	}
+	reparsed, err := newFixtureRakutenProvider(t).ParseRaw(got.Raw)
+	if err != nil || reparsed == nil || reparsed.Title != got.Title || reparsed.SchemaVersion != rakutenSchemaVersion {
+		t.Errorf("ParseRaw() = %+v, %v", reparsed, err)
+	}
+}
+
+func TestRakutenFetchOffersFixture(t *testing.T) {
+	// The listing the product was matched from is looked up by its itemCode
+	offers, err := newFixtureRakutenProvider(t).FetchOffers(context.Background(), &models.Product{Title: "Sony WH-1000XM5"}, "https://item.rakuten.co.jp/sonystore/wh-1000xm5/")
+	if err != nil {
+		t.Fatalf("FetchOffers() error = %v", err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("offers = %+v, want 1", offers)
+	}
+	offer := offers[0]
+	if offer.PriceAmount != 59400 || offer.Currency != "JPY" || !offer.InStock || offer.FreeShipping || offer.ShipsFromCountry == nil || *offer.ShipsFromCountry != "JP" {
+		t.Errorf("offer = %+v, want ¥59,400 in stock without free shipping to the US", offer)
+	}
+	if offer.Seller != "ソニーストア 楽天市場店" || offer.URL == nil || *offer.URL != "https://item.rakuten.co.jp/sonystore/wh-1000xm5/" {
+		t.Errorf("offer seller = %q, URL = %v", offer.Seller, offer.URL)
+	}
+}
+
+func TestRakutenNotEnabled(t *testing.T) {
+	_, err := (&RakutenProvider{}).Search(context.Background(), "headphones")
+	if !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("Search() error = %v, want ErrNotEnabled", err)
+	}
+}
+
+func newFixtureAliExpressProvider(t *testing.T) *AliExpressProvider {
+	return &AliExpressProvider{
+		httpClient: newFixtureClient(t),
+		appKey:     "test-app",
+		appSecret:  "test-secret",
+		apiURL:     "https://api-sg.aliexpress.com/sync",
+		enabled:    true,
+		// The signed timestamp is part of the recorded URL
+		now: func() time.Time { return time.UnixMilli(1760000000000) },
+	}
+}
+
+func TestAliExpressSearchFixture(t *testing.T) {
+	candidates, err := newFixtureAliExpressProvider(t).Search(context.Background(), "sony headphones")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("candidates = %+v, want 1 (the untitled product skipped)", candidates)
+	}
+	got := candidates[0]
+	if got.Identifier == nil || *got.Identifier != "1005004878211234" || got.Source != "aliexpress" || !got.HasPrice {
+		t.Errorf("candidate = %+v", got)
+	}
+	if got.Category == nil || *got.Category != "Consumer Electronics" {
+		t.Errorf("Category = %v", got.Category)
+	}
+	reparsed, err := newFixtureAliExpressProvider(t).ParseRaw(got.Raw)
+	if err != nil || reparsed == nil || reparsed.Title != got.Title || reparsed.SchemaVersion != aliExpressSchemaVersion {
+		t.Errorf("ParseRaw() = %+v, %v", reparsed, err)
+	}
+}
+
+func TestAliExpressFetchOffersFixture(t *testing.T) {
+	// The listing the product was matched from is looked up by its product ID
+	offers, err := newFixtureAliExpressProvider(t).FetchOffers(context.Background(), &models.Product{Title: "Sony WH-1000XM5"}, "https://www.aliexpress.com/item/1005004878211234.html")
+	if err != nil {
+		t.Fatalf("FetchOffers() error = %v", err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("offers = %+v, want 1", offers)
+	}
+	offer := offers[0]
+	if offer.PriceAmount != 28450 || offer.Currency != "USD" || offer.ShipsFromCountry == nil || *offer.ShipsFromCountry != "CN" {
+		t.Errorf("offer = %+v, want $284.50 shipped from China", offer)
+	}
+	// "ship to US in 12 days" is the latest day only
+	if offer.EstDeliveryDaysMin != nil || offer.EstDeliveryDaysMax == nil || *offer.EstDeliveryDaysMax != 12 {
+		t.Errorf("delivery days = %v-%v, want -12", offer.EstDeliveryDaysMin, offer.EstDeliveryDaysMax)
+	}
+	if offer.SellerRating == nil || *offer.SellerRating < 4.86 || *offer.SellerRating > 4.88 {
+		t.Errorf("SellerRating = %v, want 4.87 (97.4%% positive)", offer.SellerRating)
+	}
+	if offer.Seller != "Sony Audio Official Store" || offer.URL == nil || *offer.URL != "https://s.click.aliexpress.com/e/_DlXyZ12" {
+		t.Errorf("offer seller = %q, URL = %v", offer.Seller, offer.URL)
+	}
+	if offer.CanonicalURL == nil || *offer.CanonicalURL != "https://www.aliexpress.com/item/1005004878211234.html" {
+		t.Errorf("CanonicalURL = %v, want the item page", offer.CanonicalURL)
+	}
+}
+
+func TestAliExpressNotEnabled(t *testing.T) {
+	_, err := (&AliExpressProvider{}).Search(context.Background(), "headphones")
+	if !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("Search() error = %v, want ErrNotEnabled", err)
+	}
+}
+
+func TestLiveSearchFixture(t *testing.T) {
+	provider := &LiveProvider{httpClient: newFixtureClient(t), baseURL: "https://shop.example.com"}
+
+	candidates, err := provider.Search(context.Background(), "headphones")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("candidates = %+v, want 3 product cards", candidates)
+	}
+	first := candidates[0]
+	if first.Title != "Sony WH-1000XM5 Wireless Headphones" || !first.HasPrice || first.Language != "en" {
+		t.Errorf("first candidate = %+v", first)
+	}
+	if first.SourceURL == nil || *first.SourceURL != "https://shop.example.com/products/sony-wh-1000xm5?ref=search" {
+		t.Errorf("SourceURL = %v, want the absolute product link", first.SourceURL)
+	}
+	if second := candidates[1]; second.ImageURL == nil || *second.ImageURL != "https://cdn.example.com/bose-qc45.jpg" {
+		t.Errorf("second ImageURL = %v, want the data-src image", second.ImageURL)
+	}
+	if promo := candidates[2]; promo.HasPrice {
+		t.Errorf("promo card = %+v, want no price", promo)
+	}
+}
+
+func TestFixtureMissing(t *testing.T) {
+	provider := &LiveProvider{httpClient: newFixtureClient(t), baseURL: "https://shop.example.com"}
+
+	_, err := provider.Search(context.Background(), "unrecorded query")
+	if !errors.Is(err, httpfixture.ErrNoFixture) {
+		t.Errorf("Search() error = %v, want ErrNoFixture", err)
+	}
+}
+
+func newFixtureGoogleShoppingProvider(t *testing.T) *GoogleShoppingProvider {
+	return &GoogleShoppingProvider{
+		httpClient: newFixtureClient(t),
+		apiKey:     "test-key",
+		apiURL:     "https://serpapi.com/search.json",
+		enabled:    true,
+	}
+}
+
+func TestGoogleShoppingSearchFixture(t *testing.T) {
+	candidates, err := newFixtureGoogleShoppingProvider(t).Search(context.Background(), "sony headphones")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("candidates = %+v, want 3 (the used listing skipped)", candidates)
+	}
+	got := candidates[0]
+	if got.Identifier == nil || *got.Identifier != "4887235756540435899" || got.Source != "google_shopping" || !got.HasPrice {
+		t.Errorf("first candidate = %+v", got)
+	}
+	// Every merchant of the fan-out is its own candidate with its price
+	if got.Seller != "Best Buy" || got.Price == nil || *got.Price != (money.Money{Amount: 32999, Currency: "USD"}) {
+		t.Errorf("first candidate seller = %q, price = %v", got.Seller, got.Price)
+	}
+	if second := candidates[1]; second.Seller != "Crutchfield" || second.Price == nil || second.Price.Amount != 9999 {
+		t.Errorf("second candidate seller = %q, price = %v", second.Seller, second.Price)
+	}
+	// Candidates link to the merchant, or to Google's product page without a merchant link
+	if got.SourceURL == nil || !strings.HasPrefix(*got.SourceURL, "https://www.bestbuy.com/") {
+		t.Errorf("first SourceURL = %v, want the Best Buy page", got.SourceURL)
+	}
+	if last := candidates[2]; last.SourceURL == nil || *last.SourceURL != "https://www.google.com/shopping/product/3302918475520913468?gl=us" {
+		t.Errorf("last SourceURL = %v, want the Google product page", last.SourceURL)
+	}
+	reparsed, err := newFixtureGoogleShoppingProvider(t).ParseRaw(got.Raw)
+	if err != nil || reparsed == nil || reparsed.Title != got.Title || reparsed.SchemaVersion != googleShoppingSchemaVersion {
+		t.Errorf("ParseRaw() = %+v, %v", reparsed, err)
+	}
+}
+
+func TestGoogleShoppingFetchOffersFixture(t *testing.T) {
+	offers, err := newFixtureGoogleShoppingProvider(t).FetchOffers(context.Background(), &models.Product{Title: "Sony WH-1000XM5"}, "")
+	if err != nil {
+		t.Fatalf("FetchOffers() error = %v", err)
+	}
+	// One offer per merchant at its lowest price; the refurbished listing and the
+	// WH-1000XM4 are left out
+	if len(offers) != 2 {
+		t.Fatalf("offers = %+v, want 2", offers)
+	}
+	tests := []struct {
+		seller       string
+		price        int
+		freeShipping bool
+		url          string
+	}{
+		{"Best Buy", 32999, true, "https://www.bestbuy.com/site/sony-wh1000xm5-wireless-noise-canceling-over-the-ear-headphones-black/6505727.p?skuId=6505727"},
+		{"Audio Advice", 33900, false, "https://www.audioadvice.com/sony-wh-1000xm5-wireless-noise-canceling-headphones"},
+	}
+	for i, tt := range tests {
+		offer := offers[i]
+		if offer.Seller != tt.seller || offer.PriceAmount != tt.price || offer.Currency != "USD" || offer.FreeShipping != tt.freeShipping {
+			t.Errorf("offer %d = %+v, want %s at %d", i, offer, tt.seller, tt.price)
+		}
+		if offer.Source != "google_shopping" || offer.URL == nil || *offer.URL != tt.url {
+			t.Errorf("offer %d source = %q, URL = %v", i, offer.Source, offer.URL)
+		}
+	}
+}
+
+func TestGoogleShoppingNotEnabled(t *testing.T) {
+	_, err := (&GoogleShoppingProvider{}).Search(context.Background(), "headphones")
+	if !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("Search() error = %v, want ErrNotEnabled", err)
+	}
+}
@@ -0,0 +1,282 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/resolver"
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+// GoogleShoppingProvider searches Google Shopping through SerpAPI. A single query returns
+// the listings of many merchants, so it covers long-tail stores no other provider
+// fetches: each listing is a candidate, and FetchOffers returns one offer per merchant.
+type GoogleShoppingProvider struct {
+	httpClient *httpclient.Client
+	apiKey     string
+	apiURL     string
+	enabled    bool
+}
+
+// NewGoogleShoppingProvider creates a new Google Shopping (SerpAPI) provider
+func NewGoogleShoppingProvider(httpClient *httpclient.Client) *GoogleShoppingProvider {
+	apiKey := os.Getenv("SERPAPI_API_KEY")
+	apiURL := os.Getenv("SERPAPI_API_URL")
+	if apiURL == "" {
+		apiURL = "https://serpapi.com/search.json"
+	}
+
+	return &GoogleShoppingProvider{
+		httpClient: httpClient,
+		apiKey:     apiKey,
+		apiURL:     apiURL,
+		enabled:    apiKey != "",
+	}
+}
+
+// IsEnabled returns whether the provider is enabled (has an API key)
+func (p *GoogleShoppingProvider) IsEnabled() bool {
+	return p.enabled
+}
+
+// Ping verifies the API key with a minimal search
+func (p *GoogleShoppingProvider) Ping(ctx context.Context) error {
+	_, err := p.searchResults(ctx, "test", 1)
+	return err
+}
+
+// MatchURL recognizes Google Shopping product pages, see URLMatcher
+func (p *GoogleShoppingProvider) MatchURL(u *url.URL) (string, string, bool) {
+	return resolver.GoogleProductID(u)
+}
+
+// Search searches Google Shopping, returning a candidate per merchant listing
+func (p *GoogleShoppingProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+
+	results, err := p.searchResults(ctx, query, 40)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]ProductCandidate, 0, len(results))
+	for _, raw := range results {
+		candidate, err := p.ParseRaw(raw)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		candidates = append(candidates, *candidate)
+	}
+	return candidates, nil
+}
+
+// googleShoppingSchemaVersion is the version of ParseRaw's mapping; raise it when the
+// mapping changes so stored listings are re-parsed by the reprocess_raw job
+const googleShoppingSchemaVersion = 2
+
+// googleShoppingResult is one of the shopping_results of a SerpAPI google_shopping search
+type googleShoppingResult struct {
+	Title               string  `json:"title"`
+	ProductID           string  `json:"product_id"`   // Google's product, shared by the merchants listing it
+	ProductLink         string  `json:"product_link"` // the product page on Google Shopping
+	Link                string  `json:"link"`         // the merchant's page
+	Merchant            string  `json:"source"`
+	ExtractedPrice      float64 `json:"extracted_price"` // major units of the gl country's currency
+	Thumbnail           string  `json:"thumbnail"`
+	Delivery            string  `json:"delivery"` // e.g. "Free delivery" or "$5.99 delivery"
+	SecondHandCondition string  `json:"second_hand_condition"`
+}
+
+// RawSchemaVersion implements RawParser
+func (p *GoogleShoppingProvider) RawSchemaVersion() int {
+	return googleShoppingSchemaVersion
+}
+
+// ParseRaw maps one shopping result to a candidate of its merchant, with the merchant's
+// price and page, or nil for a result without a title or a used or refurbished listing.
+// Identifier is Google's product ID, which the merchants share; Seller keys the listing.
+func (p *GoogleShoppingProvider) ParseRaw(raw []byte) (*ProductCandidate, error) {
+	var result googleShoppingResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Google Shopping result: %w", ErrParse, err)
+	}
+	if result.Title == "" || result.SecondHandCondition != "" {
+		return nil, nil
+	}
+	sourceURL := result.Link
+	if sourceURL == "" {
+		sourceURL = result.ProductLink
+	}
+	var price *money.Money
+	if result.ExtractedPrice > 0 {
+		listed := money.FromMajor(result.ExtractedPrice, "USD") // searched with gl=us
+		price = &listed
+	}
+	return &ProductCandidate{
+		Title:         result.Title,
+		ImageURL:      strx.NonEmptyPtr(result.Thumbnail),
+		Source:        "google_shopping",
+		Identifier:    strx.NonEmptyPtr(result.ProductID),
+		Seller:        result.Merchant,
+		Price:         price,
+		SourceURL:     strx.NonEmptyPtr(sourceURL),
+		HasPrice:      price != nil,
+		Language:      "en",
+		Raw:           raw,
+		SchemaVersion: googleShoppingSchemaVersion,
+	}, nil
+}
+
+// FetchOffers searches for the product's title and returns an offer for each merchant
+// listing it, at the merchant's lowest price. Results whose title contains the product's
+// are kept; without any, the first result is taken, as by the other API providers.
+func (p *GoogleShoppingProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	rawResults, err := p.searchResults(ctx, product.Title, 20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for product: %w", err)
+	}
+
+	var matched, first []googleShoppingResult
+	for _, raw := range rawResults {
+		var result googleShoppingResult
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse Google Shopping result: %w", ErrParse, err)
+		}
+		// Results without a price or merchant cannot be compared
+		if result.Title == "" || result.Merchant == "" || result.ExtractedPrice <= 0 || result.SecondHandCondition != "" {
+			continue
+		}
+		if first == nil {
+			first = []googleShoppingResult{result}
+		}
+		if strx.ContainsFold(result.Title, product.Title) {
+			matched = append(matched, result)
+		}
+	}
+	if matched == nil {
+		matched = first
+	}
+
+	// One offer per merchant: its cheapest listing
+	cheapest := make(map[string]googleShoppingResult)
+	var merchants []string
+	for _, result := range matched {
+		current, ok := cheapest[result.Merchant]
+		if !ok {
+			merchants = append(merchants, result.Merchant)
+		}
+		if !ok || result.ExtractedPrice < current.ExtractedPrice {
+			cheapest[result.Merchant] = result
+		}
+	}
+
+	now := time.Now()
+	offers := make([]*models.Offer, 0, len(merchants))
+	for _, merchant := range merchants {
+		result := cheapest[merchant]
+		offerURL := result.Link
+		if offerURL == "" {
+			offerURL = result.ProductLink
+		}
+		offers = append(offers, &models.Offer{
+			ID:                 uuid.New(),
+			ProductID:          product.ID,
+			Source:             "google_shopping",
+			Seller:             merchant,
+			PriceAmount:        money.FromMajor(result.ExtractedPrice, "USD").Amount,
+			Currency:           "USD", // searched with gl=us
+			ShippingToUSAmount: 0,     // Will be calculated by shipping calculator
+			TotalToUSAmount:    0,     // Will be calculated by shipping calculator
+			// Google Shopping only lists products the merchant has available
+			InStock:            true,
+			AvailabilityStatus: strx.Ptr("in_stock"),
+			URL:                strx.NonEmptyPtr(offerURL),
+			FreeShipping:       strings.HasPrefix(strings.ToLower(result.Delivery), "free"),
+			PriceUpdatedAt:     now,
+			FetchedAt:          now,
+		})
+	}
+	return offers, nil
+}
+
+// searchResults runs a google_shopping search for query in the US and returns up to num
+// shopping results raw, so they can be stored with the listing and re-parsed later (see
+// ParseRaw)
+func (p *GoogleShoppingProvider) searchResults(ctx context.Context, query string, num int) ([]json.RawMessage, error) {
+	if !p.enabled {
+		return nil, fmt.Errorf("%w: Google Shopping API (SERPAPI_API_KEY not set)", ErrNotEnabled)
+	}
+
+	params := url.Values{
+		"engine":  {"google_shopping"},
+		"q":       {query},
+		"gl":      {"us"},
+		"hl":      {"en"},
+		"num":     {fmt.Sprint(num)},
+		"api_key": {p.apiKey},
+	}
+	searchURL := p.apiURL + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", p.httpClient.UserAgent("google_shopping"))
+	req.Header.Set("Accept", "application/json")
+
+	// For API endpoints, we use direct HTTP client (robots.txt check is not needed for API)
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("google_shopping")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from Google Shopping API: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// An invalid key is 401, running out of searches 429
+		return nil, StatusError(resp.StatusCode, fmt.Sprintf("Google Shopping API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
+	}
+	return parseGoogleShoppingResponse(body)
+}
+
+// parseGoogleShoppingResponse returns the shopping results of a search. A search Google
+// found nothing for is reported as an error with status 200, and returns no results.
+func parseGoogleShoppingResponse(body []byte) ([]json.RawMessage, error) {
+	var response struct {
+		Error           string            `json:"error"`
+		ShoppingResults []json.RawMessage `json:"shopping_results"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Google Shopping API response: %w", ErrParse, err)
+	}
+	if response.Error != "" {
+		if strings.Contains(response.Error, "hasn't returned any results") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Google Shopping API error: %s", response.Error)
+	}
+	return response.ShoppingResults, nil
+}
@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseGoogleShoppingResponse(t *testing.T) {
+	results, err := parseGoogleShoppingResponse([]byte(`{"error": "Google hasn't returned any results for this query."}`))
+	if err != nil || results != nil {
+		t.Errorf("no results = %v, %v, want none without an error", results, err)
+	}
+
+	_, err = parseGoogleShoppingResponse([]byte(`{"error": "Unsupported ` + "`gl`" + ` parameter."}`))
+	if err == nil || errors.Is(err, ErrParse) {
+		t.Errorf("API error = %v, want a plain error", err)
+	}
+
+	if _, err := parseGoogleShoppingResponse([]byte(`<html>`)); !errors.Is(err, ErrParse) {
+		t.Errorf("invalid body error = %v, want ErrParse", err)
+	}
+}
@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit states of a provider
+const (
+	CircuitClosed   = "closed"    // calls are made
+	CircuitOpen     = "open"      // the provider failed repeatedly and is skipped until the cooldown ends
+	CircuitHalfOpen = "half_open" // the cooldown ended; the next call decides whether to close or reopen
+)
+
+// Breaker is a per-provider circuit breaker. A provider failing threshold calls in a row
+// is skipped by fan-outs for cooldown, so an outage costs one failure per call instead
+// of a timeout per query. It is safe for concurrent use.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	circuits  map[string]*circuit
+	now       func() time.Time
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time // zero while closed
+}
+
+// NewBreaker creates a breaker opening after threshold consecutive failures
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+		now:       time.Now,
+	}
+}
+
+// State returns the circuit state of a provider and, while open, when it half-opens
+func (b *Breaker) State(name string) (string, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[name]
+	if !ok || c.openedAt.IsZero() {
+		return CircuitClosed, time.Time{}
+	}
+	retryAt := c.openedAt.Add(b.cooldown)
+	if b.now().Before(retryAt) {
+		return CircuitOpen, retryAt
+	}
+	return CircuitHalfOpen, time.Time{}
+}
+
+// Record counts the outcome of a provider call. A missing page, a cancelled caller and
+// a provider that is not enabled say nothing about the provider's health and are ignored.
+func (b *Breaker) Record(name string, err error) {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNotEnabled) || errors.Is(err, context.Canceled) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{}
+		b.circuits[name] = c
+	}
+	if err == nil {
+		c.failures = 0
+		c.openedAt = time.Time{}
+		return
+	}
+	c.failures++
+	// A failing half-open call reopens the circuit for another cooldown
+	if c.failures >= b.threshold || !c.openedAt.IsZero() {
+		c.openedAt = b.now()
+	}
+}
+
+// Provider statuses in a fan-out
+const (
+	StatusAvailable   = "available"    // the provider is consulted
+	StatusCircuitOpen = "circuit_open" // skipped, see Breaker
+	StatusNoBudget    = "no_budget"    // skipped, the run's request budget is used up
+)
+
+// ProviderStatus tells whether a fan-out consults a provider
+type ProviderStatus struct {
+	Provider string     `json:"provider"`
+	Status   string     `json:"status"`
+	Circuit  string     `json:"circuit"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"` // when an open circuit half-opens
+}
+
+// EnableCircuitBreaker makes Status report providers skipped by breaker. Callers record
+// call outcomes with RecordOutcome.
+func (m *Manager) EnableCircuitBreaker(breaker *Breaker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.breaker = breaker
+}
+
+// RecordOutcome feeds the outcome of a provider call to the circuit breaker, if enabled
+func (m *Manager) RecordOutcome(name string, err error) {
+	m.mu.RLock()
+	breaker := m.breaker
+	m.mu.RUnlock()
+	if breaker != nil {
+		breaker.Record(name, err)
+	}
+}
+
+// Status returns whether a fan-out should consult a provider
+func (m *Manager) Status(name string) ProviderStatus {
+	m.mu.RLock()
+	breaker := m.breaker
+	m.mu.RUnlock()
+	status := ProviderStatus{Provider: name, Status: StatusAvailable, Circuit: CircuitClosed}
+	if breaker == nil {
+		return status
+	}
+	circuit, retryAt := breaker.State(name)
+	status.Circuit = circuit
+	if circuit == CircuitOpen {
+		status.Status = StatusCircuitOpen
+		status.RetryAt = &retryAt
+	}
+	return status
+}
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+	failure := errors.New("connection refused")
+
+	state := func() string {
+		state, _ := breaker.State("live")
+		return state
+	}
+
+	breaker.Record("live", failure)
+	breaker.Record("live", StatusError(404, "not found")) // ignored
+	breaker.Record("live", context.Canceled)              // ignored
+	if got := state(); got != CircuitClosed {
+		t.Fatalf("after 1 failure: state = %q, want closed", got)
+	}
+	breaker.Record("live", failure)
+	if got, retryAt := breaker.State("live"); got != CircuitOpen || !retryAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("after 2 failures: state = %q, retry at %v, want open until %v", got, retryAt, now.Add(time.Minute))
+	}
+	if got, _ := breaker.State("amazon"); got != CircuitClosed {
+		t.Errorf("other provider: state = %q, want closed", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := state(); got != CircuitHalfOpen {
+		t.Fatalf("after cooldown: state = %q, want half_open", got)
+	}
+	breaker.Record("live", failure)
+	if got := state(); got != CircuitOpen {
+		t.Fatalf("after a failed half-open call: state = %q, want open", got)
+	}
+
+	now = now.Add(time.Minute)
+	breaker.Record("live", nil)
+	if got := state(); got != CircuitClosed {
+		t.Fatalf("after a successful call: state = %q, want closed", got)
+	}
+}
+
+func TestManagerStatus(t *testing.T) {
+	manager := NewManager()
+	if status := manager.Status("live"); status.Status != StatusAvailable || status.Circuit != CircuitClosed {
+		t.Errorf("without breaker: status = %+v, want available", status)
+	}
+
+	manager.EnableCircuitBreaker(NewBreaker(1, time.Minute))
+	manager.RecordOutcome("live", errors.New("timeout"))
+	status := manager.Status("live")
+	if status.Status != StatusCircuitOpen || status.Circuit != CircuitOpen || status.RetryAt == nil {
+		t.Errorf("after failure: status = %+v, want circuit_open with retry_at", status)
+	}
+}
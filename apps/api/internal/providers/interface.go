@@ -3,17 +3,36 @@ package providers
 import (
 	"context"
 	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/snapshots"
 )
 
 // ProductCandidate represents a product found during search
 type ProductCandidate struct {
-	Title      string
-	Brand      *string
-	Model      *string
-	ImageURL   *string
-	Source     string
-	Identifier *string // Optional identifier (e.g., itemId for Walmart, ASIN for Amazon)
-	SourceURL  *string // Product URL from the source
+	Title         string
+	Brand         *string
+	Model         *string
+	ImageURL      *string
+	Source        string
+	Identifier    *string             // Optional identifier (e.g., itemId for Walmart, ASIN for Amazon)
+	Seller        string              // Merchant of the listing, set by providers whose Identifier is shared by the merchants listing a product (Google Shopping); their listings are keyed per merchant
+	Price         *money.Money        // Listed price, when the search result shows one
+	SourceURL     *string             // Product URL from the source
+	Category      *string             // Optional provider category label (normalized via internal/category)
+	Snapshot      *snapshots.Snapshot // Archived raw HTML of the page the candidate was parsed from
+	HasPrice      bool                // The search listing showed a price (set by HTML providers, see ingest.Rules)
+	Language      string              // Listing language ("ja", "en"); "" if unknown, then detected from the title
+	Raw           []byte              // Provider payload of the listing, stored as source_products.raw_json (RawParser)
+	SchemaVersion int                 // RawSchemaVersion of the code that parsed Raw
+
+	// ExternalIdentifiers are cross-provider identifiers of the same listing (UPC, EAN, ...)
+	ExternalIdentifiers []CandidateIdentifier
+}
+
+// CandidateIdentifier is a typed identifier value, e.g. {Type: "UPC", Value: "012345678905"}
+type CandidateIdentifier struct {
+	Type  string
+	Value string
 }
 
 // Provider interface for fetching product information
@@ -21,9 +40,25 @@ type Provider interface {
 	// Search searches for products by query
 	Search(ctx context.Context, query string) ([]ProductCandidate, error)
 
-	// FetchOffers fetches offers for a product
-	FetchOffers(ctx context.Context, product *models.Product) ([]*models.Offer, error)
+	// FetchOffers fetches offers for a product. Offers must carry the listed price; a
+	// provider that cannot determine it should leave the listing out. Offers with a
+	// PriceAmount of 0 are stored as quarantined (price_unknown) and never published.
+	// listingURL is the URL of the listing the product was matched from, if known, so a
+	// provider can read that page instead of locating it.
+	FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error)
 }
 
+// RawParser is implemented by providers whose candidates carry their raw API payload in
+// Raw. ParseRaw maps one stored payload with the current code; RawSchemaVersion must be
+// raised whenever that mapping changes, so listings parsed by older code are re-parsed
+// from source_products.raw_json without refetching (the reprocess_raw job).
+type RawParser interface {
+	RawSchemaVersion() int
+	ParseRaw(raw []byte) (*ProductCandidate, error)
+}
 
-
+// Pinger is implemented by providers that can verify their credentials and connectivity
+// with a cheap request, used by the startup self-test
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -1,19 +1,38 @@
 package providers
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/google/uuid"
-	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/canonicalurl"
 	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/locale"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/render"
+	"github.com/pricecompare/api/internal/snapshots"
+	"github.com/pricecompare/api/internal/util/strx"
 )
 
+// maxPageBytes limits the size of a page that is read, parsed and archived; larger pages
+// are rejected rather than parsed incomplete
+const maxPageBytes = 10 << 20
+
+// blockedHostBackoff is how long pages of a host that answered 403 are not requested
+const blockedHostBackoff = 30 * time.Minute
+
 // LiveProvider is a provider for live fetching from external websites
 // This provider uses the httpclient which automatically applies:
 // - robots.txt checking
@@ -22,7 +41,22 @@ import (
 // - ALLOW_LIVE_FETCH control
 type LiveProvider struct {
 	httpClient *httpclient.Client
-	baseURL    string // Base URL for the target website (e.g., "https://example.com")
+	baseURL    string          // Base URL for the target website (e.g., "https://example.com")
+	snapshots  snapshots.Store // Optional raw HTML archive
+	logger     *slog.Logger
+	locale     string          // Optional BCP 47 locale sent as Accept-Language, see SetLocale
+	renderer   render.Renderer // Optional headless browser for HTML pages, see EnableRendering
+
+	// Sitemap mode, see EnableSitemapMode
+	sitemapPatterns []*regexp.Regexp
+	sitemapMaxPages int
+	sitemapMu       sync.Mutex
+	sitemapURLs     []string // product pages found by the last discovery
+	sitemapLoadedAt time.Time
+	sitemapLoading  chan struct{} // closed when the running discovery ends, nil without one
+
+	blockedMu    sync.Mutex
+	blockedHosts map[string]time.Time // host -> until when it is skipped after a 403, see request
 }
 
 // NewLiveProvider creates a new live provider
@@ -34,12 +68,120 @@ func NewLiveProvider(httpClient *httpclient.Client) *LiveProvider {
 	}
 	return &LiveProvider{
 		httpClient: httpClient,
-		baseURL:    baseURL,
+		// Page URLs are built by appending paths, so no trailing slash
+		baseURL: strings.TrimSuffix(canonicalurl.Canonicalize(baseURL), "/"),
 	}
 }
 
+// EnableSnapshots archives the raw HTML of every fetched page in store. Archiving
+// failures are logged and do not fail the fetch.
+func (p *LiveProvider) EnableSnapshots(store snapshots.Store, logger *slog.Logger) {
+	p.snapshots = store
+	p.logger = logger
+}
+
+// EnableRendering loads the site's HTML pages (search and product pages) in a headless
+// browser instead of fetching them, for sites that render their listings client-side.
+// The renderer runs the same compliance checks as a fetch.
+func (p *LiveProvider) EnableRendering(renderer render.Renderer) {
+	p.renderer = renderer
+}
+
+// getPage fetches an HTML page of the site, or renders it with rendering enabled
+func (p *LiveProvider) getPage(ctx context.Context, pageURL string) (*http.Response, error) {
+	return p.request(pageURL, func() (*http.Response, error) {
+		if p.renderer != nil {
+			return p.renderer.Render(ctx, "live", pageURL, p.requestHeader())
+		}
+		return p.httpClient.GetWithHeader(ctx, "live", pageURL, httpclient.ContentHTML, p.requestHeader())
+	})
+}
+
+// request runs fetch for pageURL unless its host refused the crawler (HTTP 403) in the
+// last blockedHostBackoff. A 403 only blocks that host: the provider's other requests
+// go on.
+func (p *LiveProvider) request(pageURL string, fetch func() (*http.Response, error)) (*http.Response, error) {
+	host := ""
+	if u, err := url.Parse(pageURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	p.blockedMu.Lock()
+	until, blocked := p.blockedHosts[host]
+	p.blockedMu.Unlock()
+	if blocked && time.Now().Before(until) {
+		return nil, fmt.Errorf("%w: %s returned status 403, skipping it until %s", ErrBlocked, host, until.Format(time.RFC3339))
+	}
+
+	resp, err := fetch()
+	if err == nil && resp.StatusCode == http.StatusForbidden && host != "" {
+		p.blockedMu.Lock()
+		if p.blockedHosts == nil {
+			p.blockedHosts = make(map[string]time.Time)
+		}
+		p.blockedHosts[host] = time.Now().Add(blockedHostBackoff)
+		p.blockedMu.Unlock()
+	}
+	return resp, err
+}
+
+// SetLocale requests pages in locale (e.g. "ja-JP") via Accept-Language. Listings
+// are tagged with the page language, or the language of locale if the page has none.
+func (p *LiveProvider) SetLocale(locale string) {
+	p.locale = locale
+}
+
+// requestHeader returns the headers sent with every page request
+func (p *LiveProvider) requestHeader() http.Header {
+	header := http.Header{}
+	if acceptLanguage := locale.AcceptLanguage(p.locale); acceptLanguage != "" {
+		header.Set("Accept-Language", acceptLanguage)
+	}
+	return header
+}
+
+// pageLanguage returns the language of a parsed page from <html lang>, falling back to
+// the configured locale
+func (p *LiveProvider) pageLanguage(doc *goquery.Document) string {
+	if lang, ok := doc.Find("html").First().Attr("lang"); ok {
+		if language := locale.Language(strings.TrimSpace(lang)); language != "" {
+			return language
+		}
+	}
+	return locale.Language(p.locale)
+}
+
+// readPage reads a fetched page and archives it when snapshots are enabled. The
+// snapshot is nil when archiving is disabled or failed.
+func (p *LiveProvider) readPage(ctx context.Context, pageURL string, body io.Reader) ([]byte, *snapshots.Snapshot, error) {
+	html, err := io.ReadAll(io.LimitReader(body, maxPageBytes+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	if len(html) > maxPageBytes {
+		return nil, nil, fmt.Errorf("page is larger than %d bytes", maxPageBytes)
+	}
+	if p.snapshots == nil {
+		return html, nil, nil
+	}
+	snapshot, err := p.snapshots.Put(ctx, pageURL, time.Now(), html)
+	if err != nil {
+		p.logger.Warn("Failed to archive page snapshot", "url", pageURL, "error", err)
+		return html, nil, nil
+	}
+	return html, &snapshot, nil
+}
+
+// Ping runs the live fetch, robots.txt and rate limit checks for the search page
+// without fetching it
+func (p *LiveProvider) Ping(ctx context.Context) error {
+	return p.httpClient.Preflight(ctx, "live", p.baseURL+"/search")
+}
+
 // Search searches for products on external websites
 func (p *LiveProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
+	if p.sitemapPatterns != nil {
+		return p.searchSitemap(ctx, query)
+	}
 	if query == "" {
 		return nil, fmt.Errorf("search query is required for live provider")
 	}
@@ -49,23 +191,43 @@ func (p *LiveProvider) Search(ctx context.Context, query string) ([]ProductCandi
 	searchURL := fmt.Sprintf("%s/search?q=%s", p.baseURL, url.QueryEscape(query))
 
 	// Fetch the search page using httpclient (with compliance checks)
-	resp, err := p.httpClient.Get(ctx, "live", searchURL)
+	resp, err := p.getPage(ctx, searchURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch search page: %w", err)
+		return nil, fetchError("failed to fetch search page", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("search page returned status %d", resp.StatusCode)
+		return nil, siteStatusError(resp.StatusCode, fmt.Sprintf("search page returned status %d", resp.StatusCode))
+	}
+
+	html, snapshot, err := p.readPage(ctx, searchURL, resp.Body)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse HTML
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse HTML: %w", ErrParse, err)
 	}
 
 	var products []ProductCandidate
+	language := p.pageLanguage(doc)
+
+	// Structured data (JSON-LD, microdata, OpenGraph) beats guessing at CSS classes
+	for _, structured := range extractStructuredProducts(doc) {
+		if structured.Name == "" {
+			continue
+		}
+		candidate := structured.candidate("live", searchURL)
+		candidate.Snapshot = snapshot
+		candidate.Language = language
+		products = append(products, candidate)
+	}
+	if len(products) > 0 {
+		return products, nil
+	}
 
 	// Parse product listings - common e-commerce selectors
 	doc.Find(".product, .item, [data-product], .product-item, .product-card").Each(func(i int, s *goquery.Selection) {
@@ -89,22 +251,27 @@ func (p *LiveProvider) Search(ctx context.Context, query string) ([]ProductCandi
 			imageURL, _ = s.Find("img").First().Attr("data-src")
 		}
 		// Make absolute URL if relative
-		if imageURL != "" && !strings.HasPrefix(imageURL, "http") {
-			if strings.HasPrefix(imageURL, "/") {
-				imageURL = p.baseURL + imageURL
-			} else {
-				imageURL = p.baseURL + "/" + imageURL
-			}
-		}
+		imageURL = canonicalurl.Resolve(searchURL, imageURL)
+
+		// Product page link, so the listing can be recorded in source_products
+		productLink, _ := s.Find("a").First().Attr("href")
+		productLink = canonicalurl.Resolve(searchURL, productLink)
 
 		// Extract brand from title
 		brand := extractBrand(title)
 
+		// Product cards show a price; navigation and promo blocks usually do not
+		priceText := strings.TrimSpace(s.Find(".price, [data-price], .product-price, [itemprop='price'], .amount").First().Text())
+
 		products = append(products, ProductCandidate{
-			Title:    title,
-			Brand:    brand,
-			ImageURL: stringPtr(imageURL),
-			Source:   "live",
+			Title:     title,
+			Brand:     brand,
+			ImageURL:  strx.NonEmptyPtr(imageURL),
+			Source:    "live",
+			SourceURL: strx.NonEmptyPtr(productLink),
+			Snapshot:  snapshot,
+			HasPrice:  parsePrice(priceText) > 0,
+			Language:  language,
 		})
 	})
 
@@ -115,10 +282,14 @@ func (p *LiveProvider) Search(ctx context.Context, query string) ([]ProductCandi
 			title := strings.TrimSpace(s.Find("h1, h2, h3, .title, a").First().Text())
 			if title != "" && len(title) < 200 {
 				brand := extractBrand(title)
+				priceText := strings.TrimSpace(s.Find(".price, [data-price], .product-price, [itemprop='price'], .amount").First().Text())
 				products = append(products, ProductCandidate{
-					Title:  title,
-					Brand:  brand,
-					Source: "live",
+					Title:    title,
+					Brand:    brand,
+					Source:   "live",
+					Snapshot: snapshot,
+					HasPrice: parsePrice(priceText) > 0,
+					Language: language,
 				})
 			}
 		})
@@ -128,11 +299,15 @@ func (p *LiveProvider) Search(ctx context.Context, query string) ([]ProductCandi
 }
 
 // FetchOffers fetches offers from external websites
-func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product) ([]*models.Offer, error) {
+func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
 	// Build product detail URL
 	// This is a generic implementation - adjust URL pattern based on target site
 	// For now, we'll try to construct a URL from the product title
 	productURL := fmt.Sprintf("%s/product/%s", p.baseURL, url.QueryEscape(strings.ToLower(strings.ReplaceAll(product.Title, " ", "-"))))
+	// The page the product was found on (e.g. from a sitemap) beats a guessed URL
+	if listingURL != "" && p.isSiteURL(listingURL) {
+		productURL = listingURL
+	}
 
 	// If product has a URL stored, use it
 	if product.ImageURL != nil && strings.HasPrefix(*product.ImageURL, "http") {
@@ -141,7 +316,12 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 	}
 
 	// Fetch the product page using httpclient (with compliance checks)
-	resp, err := p.httpClient.Get(ctx, "live", productURL)
+	resp, err := p.getPage(ctx, productURL)
+	if errors.Is(err, httpclient.ErrUnexpectedContentType) || errors.Is(err, render.ErrRender) || errors.Is(err, ErrBlocked) {
+		// The site answered, but not with a page, the browser failed or the site refuses
+		// the crawler; a mock offer would hide that
+		return nil, fetchError("failed to fetch product page", err)
+	}
 	if err != nil {
 		// If product page not found, create a mock offer from search results
 		// In a real implementation, you might want to store product URLs during search
@@ -149,15 +329,31 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, siteStatusError(resp.StatusCode, fmt.Sprintf("product page returned status %d", resp.StatusCode))
+	}
 	if resp.StatusCode != 200 {
 		// If page not found, return mock offers
 		return p.createMockOffersFromProduct(product), nil
 	}
 
+	// The archived product page is found by its URL (GET /api/admin/snapshots)
+	html, _, err := p.readPage(ctx, productURL, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse HTML
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse HTML: %w", ErrParse, err)
+	}
+
+	// Structured data describes the page's product first; listed related products follow
+	for _, structured := range extractStructuredProducts(doc) {
+		if offers := structured.offers(product.ID, "live", p.siteName(), productURL); len(offers) > 0 {
+			return offers, nil
+		}
 	}
 
 	var offers []*models.Offer
@@ -181,13 +377,7 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 
 		// Get product URL
 		productLink, _ := s.Find("a").First().Attr("href")
-		if productLink != "" && !strings.HasPrefix(productLink, "http") {
-			if strings.HasPrefix(productLink, "/") {
-				productLink = p.baseURL + productLink
-			} else {
-				productLink = p.baseURL + "/" + productLink
-			}
-		}
+		productLink = canonicalurl.Resolve(productURL, productLink)
 
 		// Check availability
 		inStock := true
@@ -213,7 +403,7 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 				EstDeliveryDaysMin: estDeliveryDaysMin,
 				EstDeliveryDaysMax: estDeliveryDaysMax,
 				InStock:            inStock,
-				URL:                stringPtr(productLink),
+				URL:                strx.NonEmptyPtr(productLink),
 				FetchedAt:          time.Now(),
 			})
 		}
@@ -228,13 +418,7 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 		if priceAmount > 0 {
 			seller := strings.TrimSpace(doc.Find(".seller, .vendor, .store, [data-seller]").First().Text())
 			if seller == "" {
-				// Try to extract from domain name
-				u, err := url.Parse(p.baseURL)
-				if err == nil {
-					seller = u.Host
-				} else {
-					seller = "Live Site"
-				}
+				seller = p.siteName()
 			}
 
 			productLink := productURL // Use the URL we requested
@@ -251,7 +435,7 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 				EstDeliveryDaysMin: intPtr(5),
 				EstDeliveryDaysMax: intPtr(10),
 				InStock:            true,
-				URL:                stringPtr(productLink),
+				URL:                strx.NonEmptyPtr(productLink),
 				FetchedAt:          time.Now(),
 			})
 		}
@@ -265,6 +449,22 @@ func (p *LiveProvider) FetchOffers(ctx context.Context, product *models.Product)
 	return offers, nil
 }
 
+// siteName returns the seller name of offers that do not state one: the site's host
+func (p *LiveProvider) siteName() string {
+	u, err := url.Parse(p.baseURL)
+	if err != nil || u.Host == "" {
+		return "Live Site"
+	}
+	return u.Host
+}
+
+// isSiteURL reports whether pageURL is on the target website
+func (p *LiveProvider) isSiteURL(pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	base, baseErr := url.Parse(p.baseURL)
+	return err == nil && baseErr == nil && strings.EqualFold(u.Hostname(), base.Hostname())
+}
+
 // createMockOffersFromProduct creates mock offers when actual scraping fails
 // This is a fallback to ensure the system continues to work
 func (p *LiveProvider) createMockOffersFromProduct(product *models.Product) []*models.Offer {
@@ -292,7 +492,7 @@ func (p *LiveProvider) createMockOffersFromProduct(product *models.Product) []*m
 			EstDeliveryDaysMin: intPtr(7),
 			EstDeliveryDaysMax: intPtr(14),
 			InStock:            true,
-			URL:                stringPtr(p.baseURL),
+			URL:                strx.NonEmptyPtr(p.baseURL),
 			FetchedAt:          time.Now(),
 		},
 	}
@@ -305,7 +505,7 @@ func estimateDeliveryDays(text string) (*int, *int) {
 	}
 
 	text = strings.ToLower(text)
-	
+
 	// Look for patterns like "3-5 days", "5 days", "1 week", etc.
 	// This is a simple heuristic - adjust based on actual site format
 	if strings.Contains(text, "1-2") || strings.Contains(text, "1 to 2") {
@@ -327,4 +527,3 @@ func estimateDeliveryDays(text string) (*int, *int) {
 	// Default estimate
 	return intPtr(5), intPtr(10)
 }
-
@@ -0,0 +1,170 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/render"
+)
+
+func TestLiveProviderLocale(t *testing.T) {
+	tests := []struct {
+		name               string
+		locale             string
+		page               string
+		wantAcceptLanguage string
+		wantLanguage       string
+	}{
+		{"page lang wins", "en-US", `<html lang="ja">`, "en-US,en;q=0.9", "ja"},
+		{"locale without page lang", "ja-JP", `<html>`, "ja-JP,ja;q=0.9", "ja"},
+		{"no locale", "", `<html>`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acceptLanguage string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					http.NotFound(w, r)
+					return
+				}
+				acceptLanguage = r.Header.Get("Accept-Language")
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte(tt.page + `<body><div class="product"><h3>Sony WH-1000XM5</h3><span class="price">$299.99</span></div></body></html>`))
+			}))
+			defer server.Close()
+
+			client := httpclient.New(&httpclient.Config{
+				AllowLiveFetch:      true,
+				UserAgent:           "TestBot/1.0",
+				RobotsCacheTTLHours: 24,
+				HTTPTimeoutSeconds:  10,
+				ProviderRateLimits:  make(map[string]httpclient.RateLimitConfig),
+				DefaultRateLimit:    httpclient.RateLimitConfig{RPS: 10, Burst: 10},
+			}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+			provider := &LiveProvider{httpClient: client, baseURL: server.URL}
+			provider.SetLocale(tt.locale)
+
+			candidates, err := provider.Search(context.Background(), "headphones")
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if acceptLanguage != tt.wantAcceptLanguage {
+				t.Errorf("Accept-Language = %q, want %q", acceptLanguage, tt.wantAcceptLanguage)
+			}
+			if len(candidates) != 1 || candidates[0].Language != tt.wantLanguage {
+				t.Errorf("candidates = %+v, want one with language %q", candidates, tt.wantLanguage)
+			}
+		})
+	}
+}
+
+func TestLiveProviderRendering(t *testing.T) {
+	// The site itself only serves a shell that renders its listings client-side
+	siteRequests := 0
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siteRequests++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><body><div id="app"></div><script src="/app.js"></script></body></html>`))
+	}))
+	defer site.Close()
+	var renderedURL string
+	browser := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		renderedURL = body.URL
+		w.Write([]byte(`<html><body><div id="app"><div class="product"><h3>Sony WH-1000XM5</h3><span class="price">$299.99</span></div></div></body></html>`))
+	}))
+	defer browser.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := httpclient.New(&httpclient.Config{
+		AllowLiveFetch:      true,
+		UserAgent:           "TestBot/1.0",
+		RobotsCacheTTLHours: 24,
+		HTTPTimeoutSeconds:  10,
+		ProviderRateLimits:  make(map[string]httpclient.RateLimitConfig),
+		DefaultRateLimit:    httpclient.RateLimitConfig{RPS: 10, Burst: 10},
+	}, logger, nil)
+	provider := &LiveProvider{httpClient: client, baseURL: site.URL}
+	provider.EnableRendering(render.NewBrowserless(render.Config{URL: browser.URL, Timeout: 5 * time.Second}, client, logger))
+
+	candidates, err := provider.Search(context.Background(), "headphones")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Title != "Sony WH-1000XM5" {
+		t.Errorf("candidates = %+v, want the rendered listing", candidates)
+	}
+	if renderedURL != site.URL+"/search?q=headphones" || siteRequests != 0 {
+		t.Errorf("rendered %q with %d direct site requests, want the search page rendered only", renderedURL, siteRequests)
+	}
+}
+
+func TestLiveProviderReadPageLimit(t *testing.T) {
+	provider := &LiveProvider{}
+	if html, _, err := provider.readPage(context.Background(), "https://example.com/", strings.NewReader(strings.Repeat("a", maxPageBytes))); err != nil || len(html) != maxPageBytes {
+		t.Errorf("readPage() of a page at the limit = %d bytes, %v", len(html), err)
+	}
+	if _, _, err := provider.readPage(context.Background(), "https://example.com/", strings.NewReader(strings.Repeat("a", maxPageBytes+1))); err == nil {
+		t.Error("readPage() of a page over the limit returned no error, want it rejected instead of truncated")
+	}
+}
+
+func TestLiveProviderBlockedHost(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := httpclient.New(&httpclient.Config{
+		AllowLiveFetch:      true,
+		UserAgent:           "TestBot/1.0",
+		RobotsCacheTTLHours: 24,
+		HTTPTimeoutSeconds:  10,
+		ProviderRateLimits:  make(map[string]httpclient.RateLimitConfig),
+		DefaultRateLimit:    httpclient.RateLimitConfig{RPS: 100, Burst: 100},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	provider := &LiveProvider{httpClient: client, baseURL: server.URL}
+
+	_, err := provider.Search(context.Background(), "headphones")
+	if !errors.Is(err, ErrBlocked) || IsFatal(err) {
+		t.Fatalf("Search() of a site answering 403 error = %v, want a non-fatal ErrBlocked", err)
+	}
+	// The host is skipped without another request
+	_, err = provider.Search(context.Background(), "speakers")
+	if !errors.Is(err, ErrBlocked) || requests != 1 {
+		t.Errorf("second Search() error = %v after %d requests, want ErrBlocked after 1", err, requests)
+	}
+	if _, err := provider.FetchOffers(context.Background(), &models.Product{Title: "Sony WH-1000XM5"}, ""); !errors.Is(err, ErrBlocked) {
+		t.Errorf("FetchOffers() on the blocked host error = %v, want ErrBlocked", err)
+	}
+
+	// Other hosts are still requested
+	fetched := false
+	provider.request("https://other.example.com/products/a", func() (*http.Response, error) {
+		fetched = true
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	if !fetched {
+		t.Error("a page of another host was not requested")
+	}
+}
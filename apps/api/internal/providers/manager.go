@@ -2,23 +2,67 @@ package providers
 
 import (
 	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/pricecompare/api/internal/resolver"
 )
 
+// URLMatcher is implemented by providers that recognize their own product page URLs.
+// Registering such a provider adds its matcher to the manager's URL resolver.
+type URLMatcher interface {
+	MatchURL(u *url.URL) (identifierType, identifier string, ok bool)
+}
+
 type Manager struct {
+	mu        sync.RWMutex
 	providers map[string]Provider
+	urls      *resolver.Registry
+	breaker   *Breaker // optional, see EnableCircuitBreaker
 }
 
 func NewManager() *Manager {
 	return &Manager{
 		providers: make(map[string]Provider),
+		urls:      resolver.Default(),
 	}
 }
 
 func (m *Manager) Register(name string, provider Provider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.providers[name] = provider
+	m.registerURLMatcher(name, provider)
+}
+
+// URLResolver returns the resolver for product page URLs: the built-in matchers plus
+// those of registered providers implementing URLMatcher
+func (m *Manager) URLResolver() *resolver.Registry {
+	return m.urls
+}
+
+// registerURLMatcher adds the matcher of a provider implementing URLMatcher. Matchers
+// stay registered when the provider is replaced, as URLs can be resolved without it.
+func (m *Manager) registerURLMatcher(name string, provider Provider) {
+	if matcher, ok := provider.(URLMatcher); ok {
+		m.urls.Register(name, matcher.MatchURL)
+	}
+}
+
+// Replace swaps the whole set of registered providers, e.g. when provider toggles are
+// reloaded. Jobs already running keep the provider they got from Get.
+func (m *Manager) Replace(providers map[string]Provider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.providers = providers
+	for name, provider := range providers {
+		m.registerURLMatcher(name, provider)
+	}
 }
 
 func (m *Manager) Get(name string) (Provider, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	provider, ok := m.providers[name]
 	if !ok {
 		return nil, fmt.Errorf("provider %s not found", name)
@@ -27,12 +71,11 @@ func (m *Manager) Get(name string) (Provider, error) {
 }
 
 func (m *Manager) List() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	names := make([]string, 0, len(m.providers))
 	for name := range m.providers {
 		names = append(names, name)
 	}
 	return names
 }
-
-
-
@@ -12,8 +12,13 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/google/uuid"
 	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/util/strx"
 )
 
+// samplePageURL stands in for the address of sample pages when resolving their links
+const samplePageURL = "https://example.com/product"
+
 type PublicHTMLProvider struct {
 	samplesDir string
 	userAgent  string
@@ -54,7 +59,7 @@ func (p *PublicHTMLProvider) Search(ctx context.Context, query string) ([]Produc
 	return candidates, nil
 }
 
-func (p *PublicHTMLProvider) FetchOffers(ctx context.Context, product *models.Product) ([]*models.Offer, error) {
+func (p *PublicHTMLProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
 	// Search for offers in all sample files
 	files, err := filepath.Glob(filepath.Join(p.samplesDir, "*.html"))
 	if err != nil {
@@ -87,6 +92,16 @@ func (p *PublicHTMLProvider) parseHTMLFile(filepath string) ([]ProductCandidate,
 
 	var products []ProductCandidate
 
+	// Structured data (JSON-LD, microdata, OpenGraph) beats guessing at CSS classes
+	for _, structured := range extractStructuredProducts(doc) {
+		if structured.Name != "" {
+			products = append(products, structured.candidate("public_html", samplePageURL))
+		}
+	}
+	if len(products) > 0 {
+		return products, nil
+	}
+
 	// Parse based on common e-commerce HTML structure
 	// Looking for product listings with title, price, etc.
 	doc.Find(".product, .item, [data-product]").Each(func(i int, s *goquery.Selection) {
@@ -108,7 +123,7 @@ func (p *PublicHTMLProvider) parseHTMLFile(filepath string) ([]ProductCandidate,
 			products = append(products, ProductCandidate{
 				Title:    title,
 				Brand:    brand,
-				ImageURL: stringPtr(imageURL),
+				ImageURL: strx.NonEmptyPtr(imageURL),
 				Source:   "public_html",
 			})
 		}
@@ -140,6 +155,12 @@ func (p *PublicHTMLProvider) parseOffersFromHTML(filepath string, productID uuid
 		return nil, err
 	}
 
+	for _, structured := range extractStructuredProducts(doc) {
+		if offers := structured.offers(productID, "public_html", "Sample Site", samplePageURL); len(offers) > 0 {
+			return offers, nil
+		}
+	}
+
 	var offers []*models.Offer
 
 	// Parse offers from HTML
@@ -173,7 +194,7 @@ func (p *PublicHTMLProvider) parseOffersFromHTML(filepath string, productID uuid
 				EstDeliveryDaysMin: intPtr(5),
 				EstDeliveryDaysMax: intPtr(10),
 				InStock:            true,
-				URL:                stringPtr(url),
+				URL:                strx.NonEmptyPtr(url),
 				FetchedAt:          time.Now(),
 			})
 		}
@@ -197,7 +218,7 @@ func (p *PublicHTMLProvider) parseOffersFromHTML(filepath string, productID uuid
 				EstDeliveryDaysMin: intPtr(7),
 				EstDeliveryDaysMax: intPtr(14),
 				InStock:            true,
-				URL:                stringPtr("https://example.com/product"),
+				URL:                strx.NonEmptyPtr(samplePageURL),
 				FetchedAt:          time.Now(),
 			})
 		}
@@ -210,12 +231,11 @@ func parsePrice(text string) int {
 	// Remove currency symbols and whitespace
 	text = strings.ReplaceAll(text, "$", "")
 	text = strings.ReplaceAll(text, "USD", "")
-	text = strings.ReplaceAll(text, ",", "")
-	text = strings.TrimSpace(text)
+	text = normalizeDecimal(text)
 
 	// Try to parse as float
 	if price, err := strconv.ParseFloat(text, 64); err == nil {
-		return int(price * 100) // Convert to cents
+		return money.FromMajor(price, "USD").Amount // Convert to cents
 	}
 
 	return 0
@@ -243,4 +263,3 @@ func extractBrand(title string) *string {
 	}
 	return nil
 }
-
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/pricecompare/api/internal/util/strx"
 )
 
 func TestParsePrice(t *testing.T) {
@@ -15,7 +17,7 @@ func TestParsePrice(t *testing.T) {
 		{
 			name:     "Dollar sign",
 			input:    "$79.99",
-			expected: 7998, // 浮動小数点演算の丸め誤差により7998になる
+			expected: 7999,
 		},
 		{
 			name:     "Plain number",
@@ -32,6 +34,16 @@ func TestParsePrice(t *testing.T) {
 			input:    "$1,299.99",
 			expected: 129999,
 		},
+		{
+			name:     "Decimal comma with dot grouping",
+			input:    "1.299,00",
+			expected: 129900,
+		},
+		{
+			name:     "Decimal comma",
+			input:    "$12,99",
+			expected: 1299,
+		},
 		{
 			name:     "Invalid",
 			input:    "invalid",
@@ -98,7 +110,7 @@ func TestExtractBrand(t *testing.T) {
 		{
 			name:     "Brand at start",
 			title:    "Sony WH-1000XM4 Headphones",
-			expected: stringPtr("Sony"),
+			expected: strx.Ptr("Sony"),
 		},
 		{
 			name:     "Single word",
@@ -123,4 +135,3 @@ func TestExtractBrand(t *testing.T) {
 		})
 	}
 }
-
@@ -0,0 +1,281 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/resolver"
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+// RakutenProvider implements the Rakuten Ichiba Item Search API. Prices are in JPY; the
+// fetch job converts them to USD totals with the FX rates (see shipping.Calculator).
+type RakutenProvider struct {
+	httpClient    *httpclient.Client
+	applicationID string
+	affiliateID   string // Optional; offers then link to the affiliate URL
+	apiURL        string
+	enabled       bool
+}
+
+// NewRakutenProvider creates a new Rakuten Ichiba API provider
+func NewRakutenProvider(httpClient *httpclient.Client) *RakutenProvider {
+	applicationID := os.Getenv("RAKUTEN_APPLICATION_ID")
+	apiURL := os.Getenv("RAKUTEN_API_URL")
+	if apiURL == "" {
+		apiURL = "https://app.rakuten.co.jp/services/api/IchibaItem/Search/20220601"
+	}
+
+	return &RakutenProvider{
+		httpClient:    httpClient,
+		applicationID: applicationID,
+		affiliateID:   os.Getenv("RAKUTEN_AFFILIATE_ID"),
+		apiURL:        apiURL,
+		enabled:       applicationID != "",
+	}
+}
+
+// IsEnabled returns whether the provider is enabled (has an application ID)
+func (p *RakutenProvider) IsEnabled() bool {
+	return p.enabled
+}
+
+// Ping verifies the application ID with a minimal search
+func (p *RakutenProvider) Ping(ctx context.Context) error {
+	_, err := p.searchItems(ctx, url.Values{"keyword": {"test"}, "hits": {"1"}})
+	return err
+}
+
+// MatchURL recognizes Rakuten Ichiba item pages, see URLMatcher
+func (p *RakutenProvider) MatchURL(u *url.URL) (string, string, bool) {
+	return resolver.RakutenItemCode(u)
+}
+
+// Search searches for products using the Rakuten Ichiba API
+func (p *RakutenProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+
+	items, err := p.searchItems(ctx, url.Values{"keyword": {query}})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]ProductCandidate, 0, len(items))
+	for _, raw := range items {
+		candidate, err := p.ParseRaw(raw)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		candidates = append(candidates, *candidate)
+	}
+	return candidates, nil
+}
+
+// rakutenSchemaVersion is the version of ParseRaw's mapping; raise it when the mapping
+// changes so stored listings are re-parsed by the reprocess_raw job
+const rakutenSchemaVersion = 1
+
+// rakutenItem is one item of an Item Search response (formatVersion=2)
+type rakutenItem struct {
+	ItemName        string   `json:"itemName"`
+	ItemCode        string   `json:"itemCode"`  // "<shop>:<item>"
+	ItemPrice       int      `json:"itemPrice"` // yen, including consumption tax
+	ItemURL         string   `json:"itemUrl"`
+	AffiliateURL    string   `json:"affiliateUrl"`
+	ShopName        string   `json:"shopName"`
+	MediumImageURLs []string `json:"mediumImageUrls"`
+	Availability    int      `json:"availability"` // 1 = can be ordered
+}
+
+// RawSchemaVersion implements RawParser
+func (p *RakutenProvider) RawSchemaVersion() int {
+	return rakutenSchemaVersion
+}
+
+// ParseRaw maps one Item Search item to a candidate, or nil for an item without a name
+func (p *RakutenProvider) ParseRaw(raw []byte) (*ProductCandidate, error) {
+	var item rakutenItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Rakuten item: %w", ErrParse, err)
+	}
+	if item.ItemName == "" {
+		return nil, nil
+	}
+	return &ProductCandidate{
+		Title:         item.ItemName,
+		ImageURL:      strx.NonEmptyPtr(rakutenImageURL(item.MediumImageURLs)),
+		Source:        "rakuten",
+		Identifier:    strx.NonEmptyPtr(item.ItemCode),
+		SourceURL:     strx.NonEmptyPtr(item.ItemURL),
+		HasPrice:      item.ItemPrice > 0,
+		Language:      "ja",
+		Raw:           raw,
+		SchemaVersion: rakutenSchemaVersion,
+	}, nil
+}
+
+// rakutenImageURL returns the first image without its "?_ex=128x128" thumbnail size,
+// which serves the full-size image
+func rakutenImageURL(imageURLs []string) string {
+	if len(imageURLs) == 0 {
+		return ""
+	}
+	imageURL, _, _ := strings.Cut(imageURLs[0], "?")
+	return imageURL
+}
+
+// FetchOffers fetches the offer of a product: the matched listing by its itemCode, or
+// else the first search result for the product's title (preferring one whose name
+// contains it)
+func (p *RakutenProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
+	params := url.Values{"keyword": {product.Title}, "hits": {"10"}}
+	if listingURL != "" {
+		if u, err := url.Parse(listingURL); err == nil {
+			if _, itemCode, ok := resolver.RakutenItemCode(u); ok {
+				params = url.Values{"itemCode": {itemCode}}
+			}
+		}
+	}
+
+	rawItems, err := p.searchItems(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for product: %w", err)
+	}
+
+	var matched *rakutenItem
+	for _, raw := range rawItems {
+		var item rakutenItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse Rakuten item: %w", ErrParse, err)
+		}
+		// Items without a price cannot be compared
+		if item.ItemName == "" || item.ItemPrice <= 0 {
+			continue
+		}
+		if matched == nil || (params.Has("keyword") && strx.ContainsFold(item.ItemName, product.Title) && !strx.ContainsFold(matched.ItemName, product.Title)) {
+			matched = &item
+		}
+	}
+	if matched == nil {
+		return []*models.Offer{}, nil
+	}
+
+	seller := matched.ShopName
+	if seller == "" {
+		seller = "Rakuten Ichiba"
+	}
+	// The affiliate URL redirects to the item page, which stays the canonical URL
+	offerURL := matched.ItemURL
+	if matched.AffiliateURL != "" {
+		offerURL = matched.AffiliateURL
+	}
+	availabilityStatus := "out_of_stock"
+	if matched.Availability == 1 {
+		availabilityStatus = "in_stock"
+	}
+
+	now := time.Now()
+	offer := &models.Offer{
+		ID:                 uuid.New(),
+		ProductID:          product.ID,
+		Source:             "rakuten",
+		Seller:             seller,
+		PriceAmount:        money.FromMajor(float64(matched.ItemPrice), "JPY").Amount,
+		Currency:           "JPY",
+		ShippingToUSAmount: 0, // Will be calculated by shipping calculator
+		TotalToUSAmount:    0, // Will be calculated by shipping calculator
+		// Shipped from Japan, through a forwarding service for most shops
+		EstDeliveryDaysMin: intPtr(7),
+		EstDeliveryDaysMax: intPtr(14),
+		ShipsFromCountry:   strx.Ptr("JP"), // for the import duty estimate
+		InStock:            matched.Availability == 1,
+		AvailabilityStatus: strx.NonEmptyPtr(availabilityStatus),
+		URL:                strx.NonEmptyPtr(offerURL),
+		CanonicalURL:       strx.NonEmptyPtr(matched.ItemURL),
+		FreeShipping:       false, // postageFlag (送料込) only covers delivery within Japan
+		PriceUpdatedAt:     now,
+		FetchedAt:          now,
+	}
+	return []*models.Offer{offer}, nil
+}
+
+// searchItems calls Item Search with params and returns its items raw, so they can be
+// stored with the listing and re-parsed later (see ParseRaw)
+func (p *RakutenProvider) searchItems(ctx context.Context, params url.Values) ([]json.RawMessage, error) {
+	if !p.enabled {
+		return nil, fmt.Errorf("%w: Rakuten API (RAKUTEN_APPLICATION_ID not set)", ErrNotEnabled)
+	}
+
+	params.Set("applicationId", p.applicationID)
+	if p.affiliateID != "" {
+		params.Set("affiliateId", p.affiliateID)
+	}
+	params.Set("format", "json")
+	params.Set("formatVersion", "2")
+	searchURL := p.apiURL + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", p.httpClient.UserAgent("rakuten"))
+	req.Header.Set("Accept", "application/json")
+
+	// For API endpoints, we use direct HTTP client (robots.txt check is not needed for API)
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("rakuten")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from Rakuten API: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, rakutenStatusError(resp.StatusCode, body)
+	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
+	}
+
+	var apiResponse struct {
+		Items []json.RawMessage `json:"Items"`
+	}
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Rakuten API response: %w", ErrParse, err)
+	}
+	return apiResponse.Items, nil
+}
+
+// rakutenStatusError is StatusError for the API's errors, which reports an invalid
+// application ID as 400 wrong_parameter rather than 401
+func rakutenStatusError(status int, body []byte) error {
+	var apiError struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.Unmarshal(body, &apiError)
+	message := fmt.Sprintf("Rakuten API returned status %d: %s", status, string(body))
+	if status == http.StatusBadRequest && strings.Contains(apiError.Description, "applicationId") {
+		return fmt.Errorf("%w: %s", ErrAuth, message)
+	}
+	return StatusError(status, message)
+}
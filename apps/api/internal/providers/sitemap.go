@@ -0,0 +1,342 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+const (
+	// sitemapCacheTTL is how long discovered product URLs are reused across searches
+	sitemapCacheTTL = time.Hour
+	// maxSitemaps bounds the sitemaps read per discovery, following sitemap indexes
+	maxSitemaps = 50
+	// maxSitemapBytes is the largest (uncompressed) sitemap the protocol allows
+	maxSitemapBytes = 50 << 20
+)
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// parseSitemap returns the page URLs of a urlset, or the sitemap URLs of a sitemap
+// index, decoding r as it is read
+func parseSitemap(r io.Reader) (pages, sitemaps []string, err error) {
+	decoder := xml.NewDecoder(r)
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to parse sitemap: %w", ErrParse, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == "" {
+			root = start.Name.Local
+			if root != "urlset" && root != "sitemapindex" {
+				return nil, nil, fmt.Errorf("%w: <%s> is not a sitemap", ErrParse, root)
+			}
+			continue
+		}
+		if (root == "urlset" && start.Name.Local != "url") || (root == "sitemapindex" && start.Name.Local != "sitemap") {
+			continue
+		}
+		var entry sitemapLoc
+		if err := decoder.DecodeElement(&entry, &start); err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to parse sitemap: %w", ErrParse, err)
+		}
+		loc := strings.TrimSpace(entry.Loc)
+		if loc == "" {
+			continue
+		}
+		if root == "urlset" {
+			pages = append(pages, loc)
+		} else {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	if root == "" {
+		return nil, nil, fmt.Errorf("%w: empty sitemap", ErrParse)
+	}
+	return pages, sitemaps, nil
+}
+
+// sitemapReader returns the sitemap read from body, gunzipping gzip-compressed ones
+// (e.g. sitemap.xml.gz), which fails once more than limit bytes are read
+func sitemapReader(body io.Reader, limit int64) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	var r io.Reader = buffered
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compressed sitemap: %w", err)
+		}
+		r = gz
+	}
+	return &sizeLimitedReader{r: r, remaining: limit, limit: limit}, nil
+}
+
+// sizeLimitedReader reads r until more than limit bytes came from it, then fails
+// instead of truncating like io.LimitReader
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("sitemap is larger than %d bytes", l.limit)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, fmt.Errorf("sitemap is larger than %d bytes", l.limit)
+	}
+	return n, err
+}
+
+// EnableSitemapMode makes Search read product pages listed in the site's sitemaps
+// instead of its search page. Sitemaps are taken from the Sitemap lines of robots.txt,
+// or /sitemap.xml without any, following sitemap indexes. Pages whose URL matches
+// none of patterns are ignored; at most maxPages pages are read per search.
+func (p *LiveProvider) EnableSitemapMode(patterns []*regexp.Regexp, maxPages int) {
+	p.sitemapPatterns = patterns
+	p.sitemapMaxPages = maxPages
+}
+
+// searchSitemap returns a candidate for each product page of the sitemaps whose URL
+// contains every word of query (all pages for an empty query)
+func (p *LiveProvider) searchSitemap(ctx context.Context, query string) ([]ProductCandidate, error) {
+	pageURLs, err := p.sitemapProductURLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	var candidates []ProductCandidate
+	for _, pageURL := range pageURLs {
+		if p.sitemapMaxPages > 0 && len(candidates) >= p.sitemapMaxPages {
+			break
+		}
+		if !strx.ContainsAll(strings.ToLower(pageURL), words) {
+			continue
+		}
+		candidate, err := p.fetchProductPage(ctx, pageURL)
+		if IsFatal(err) || ctx.Err() != nil {
+			return candidates, err
+		}
+		if err != nil || candidate == nil {
+			continue // Skip pages that fail or do not look like a product
+		}
+		candidates = append(candidates, *candidate)
+	}
+	return candidates, nil
+}
+
+// sitemapProductURLs returns the product page URLs of the site's sitemaps, cached for
+// sitemapCacheTTL. One discovery runs at a time; other searches wait for its result
+// without holding the lock.
+func (p *LiveProvider) sitemapProductURLs(ctx context.Context) ([]string, error) {
+	for {
+		p.sitemapMu.Lock()
+		if p.sitemapURLs != nil && time.Since(p.sitemapLoadedAt) < sitemapCacheTTL {
+			pageURLs := p.sitemapURLs
+			p.sitemapMu.Unlock()
+			return pageURLs, nil
+		}
+		if loading := p.sitemapLoading; loading != nil {
+			p.sitemapMu.Unlock()
+			select {
+			case <-loading:
+				continue // Use its result, or discover again if it failed
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		loading := make(chan struct{})
+		p.sitemapLoading = loading
+		p.sitemapMu.Unlock()
+
+		pageURLs, err := p.discoverSitemapProductURLs(ctx)
+
+		p.sitemapMu.Lock()
+		if err == nil {
+			p.sitemapURLs = pageURLs
+			p.sitemapLoadedAt = time.Now()
+		}
+		p.sitemapLoading = nil
+		p.sitemapMu.Unlock()
+		close(loading)
+		return pageURLs, err
+	}
+}
+
+// discoverSitemapProductURLs reads the site's sitemaps for product page URLs
+func (p *LiveProvider) discoverSitemapProductURLs(ctx context.Context) ([]string, error) {
+	queue, err := p.httpClient.Sitemaps(ctx, p.baseURL)
+	if err != nil {
+		return nil, fetchError("failed to read robots.txt sitemaps", err)
+	}
+	if len(queue) == 0 {
+		queue = []string{p.baseURL + "/sitemap.xml"}
+	}
+
+	base, _ := url.Parse(p.baseURL)
+	seen := make(map[string]bool)
+	pageURLs := []string{}
+	var firstErr error
+	for read := 0; len(queue) > 0 && read < maxSitemaps; read++ {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if seen[sitemapURL] {
+			continue
+		}
+		seen[sitemapURL] = true
+
+		pages, children, err := p.fetchSitemap(ctx, sitemapURL)
+		if err != nil {
+			if IsFatal(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		queue = append(queue, children...)
+		for _, page := range pages {
+			if p.isSitemapProductURL(base, page) {
+				pageURLs = append(pageURLs, canonicalurl.Canonicalize(page))
+			}
+		}
+	}
+	if len(pageURLs) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return pageURLs, nil
+}
+
+// fetchSitemap reads one sitemap
+func (p *LiveProvider) fetchSitemap(ctx context.Context, sitemapURL string) (pages, sitemaps []string, err error) {
+	// Compressed sitemaps are served as application/gzip and the like, not XML
+	expect := httpclient.ContentXML
+	if u, err := url.Parse(sitemapURL); err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".gz") {
+		expect = httpclient.ContentAny
+	}
+	resp, err := p.request(sitemapURL, func() (*http.Response, error) {
+		return p.httpClient.GetWithHeader(ctx, "live", sitemapURL, expect, nil)
+	})
+	if err != nil {
+		return nil, nil, fetchError("failed to fetch sitemap", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, nil, siteStatusError(resp.StatusCode, fmt.Sprintf("sitemap %s returned status %d", sitemapURL, resp.StatusCode))
+	}
+	body, err := sitemapReader(resp.Body, maxSitemapBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseSitemap(body)
+}
+
+// isSitemapProductURL reports whether a sitemap page is a product page of the site
+func (p *LiveProvider) isSitemapProductURL(base *url.URL, pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil || base == nil || !strings.EqualFold(u.Hostname(), base.Hostname()) {
+		return false
+	}
+	for _, pattern := range p.sitemapPatterns {
+		if pattern.MatchString(pageURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchProductPage reads a product page into a candidate; nil if it has no title
+func (p *LiveProvider) fetchProductPage(ctx context.Context, pageURL string) (*ProductCandidate, error) {
+	resp, err := p.getPage(ctx, pageURL)
+	if err != nil {
+		return nil, fetchError("failed to fetch product page", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, siteStatusError(resp.StatusCode, fmt.Sprintf("product page returned status %d", resp.StatusCode))
+	}
+
+	html, snapshot, err := p.readPage(ctx, pageURL, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse HTML: %w", ErrParse, err)
+	}
+
+	for _, structured := range extractStructuredProducts(doc) {
+		if structured.Name == "" {
+			continue
+		}
+		candidate := structured.candidate("live", pageURL)
+		candidate.SourceURL = strx.NonEmptyPtr(pageURL)
+		candidate.Snapshot = snapshot
+		candidate.Language = p.pageLanguage(doc)
+		return &candidate, nil
+	}
+
+	title, _ := doc.Find("meta[property='og:title']").First().Attr("content")
+	if title = strings.TrimSpace(title); title == "" {
+		title = strings.TrimSpace(doc.Find("h1").First().Text())
+	}
+	if title == "" {
+		title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	if title == "" {
+		return nil, nil
+	}
+	if len(title) > 200 {
+		title = title[:200]
+	}
+
+	imageURL, _ := doc.Find("meta[property='og:image']").First().Attr("content")
+	if imageURL == "" {
+		imageURL, _ = doc.Find("img").First().Attr("src")
+	}
+	priceText := strings.TrimSpace(doc.Find(".price, [data-price], .product-price, [itemprop='price']").First().Text())
+
+	return &ProductCandidate{
+		Title:     title,
+		Brand:     extractBrand(title),
+		ImageURL:  strx.NonEmptyPtr(canonicalurl.Resolve(pageURL, imageURL)),
+		Source:    "live",
+		SourceURL: strx.NonEmptyPtr(pageURL),
+		Snapshot:  snapshot,
+		HasPrice:  parsePrice(priceText) > 0,
+		Language:  p.pageLanguage(doc),
+	}, nil
+}
@@ -0,0 +1,131 @@
+package providers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/pricecompare/api/internal/httpclient"
+)
+
+func TestParseSitemap(t *testing.T) {
+	pages, sitemaps, err := parseSitemap(strings.NewReader(`<?xml version="1.0"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://shop.example.com/products/a </loc></url>
+  <url><loc></loc></url>
+</urlset>`))
+	if err != nil || len(pages) != 1 || pages[0] != "https://shop.example.com/products/a" || len(sitemaps) != 0 {
+		t.Errorf("urlset: pages = %v, sitemaps = %v, err = %v", pages, sitemaps, err)
+	}
+
+	pages, sitemaps, err = parseSitemap(strings.NewReader(`<sitemapindex><sitemap><loc>https://shop.example.com/s1.xml</loc></sitemap></sitemapindex>`))
+	if err != nil || len(pages) != 0 || len(sitemaps) != 1 {
+		t.Errorf("sitemapindex: pages = %v, sitemaps = %v, err = %v", pages, sitemaps, err)
+	}
+
+	if _, _, err := parseSitemap(strings.NewReader(`<html></html>`)); !errors.Is(err, ErrParse) {
+		t.Errorf("html: err = %v, want ErrParse", err)
+	}
+}
+
+func TestSitemapReader(t *testing.T) {
+	sitemap := `<urlset><url><loc>https://shop.example.com/products/a</loc></url></urlset>`
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(sitemap))
+	gz.Close()
+
+	for name, body := range map[string][]byte{"plain": []byte(sitemap), "gzip": compressed.Bytes()} {
+		r, err := sitemapReader(bytes.NewReader(body), int64(len(sitemap)))
+		if err != nil {
+			t.Fatalf("%s: sitemapReader() error = %v", name, err)
+		}
+		if pages, _, err := parseSitemap(r); err != nil || len(pages) != 1 {
+			t.Errorf("%s: pages = %v, err = %v", name, pages, err)
+		}
+	}
+
+	// Larger sitemaps fail rather than parse a truncated document
+	r, err := sitemapReader(bytes.NewReader(compressed.Bytes()), int64(len(sitemap))-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("reading an oversized sitemap error = %v, want a size error", err)
+	}
+}
+
+func TestLiveProviderSitemapMode(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/products.xml.gz</loc></sitemap></sitemapindex>`, server.URL)
+		case "/products.xml.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			fmt.Fprintf(gz, `<urlset>
+<url><loc>%[1]s/products/sony-wh-1000xm5</loc></url>
+<url><loc>%[1]s/products/bose-qc45</loc></url>
+<url><loc>%[1]s/blog/sony-review</loc></url>
+<url><loc>https://other.example.com/products/sony-a7</loc></url>
+</urlset>`, server.URL)
+		case "/products/sony-wh-1000xm5":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><meta property="og:title" content="Sony WH-1000XM5"></head><body><span class="price">$299.99</span></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.New(&httpclient.Config{
+		AllowLiveFetch:      true,
+		UserAgent:           "TestBot/1.0",
+		RobotsCacheTTLHours: 24,
+		HTTPTimeoutSeconds:  10,
+		ProviderRateLimits:  make(map[string]httpclient.RateLimitConfig),
+		DefaultRateLimit:    httpclient.RateLimitConfig{RPS: 100, Burst: 100},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	provider := &LiveProvider{httpClient: client, baseURL: server.URL}
+	provider.EnableSitemapMode([]*regexp.Regexp{regexp.MustCompile(`/products/`)}, 10)
+
+	candidates, err := provider.Search(context.Background(), "Sony")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("candidates = %+v, want only the Sony product page", candidates)
+	}
+	got := candidates[0]
+	if got.Title != "Sony WH-1000XM5" || !got.HasPrice || got.SourceURL == nil || *got.SourceURL != server.URL+"/products/sony-wh-1000xm5" {
+		t.Errorf("candidate = %+v", got)
+	}
+}
+
+func TestSitemapProductURLsWaitsWithoutLock(t *testing.T) {
+	provider := &LiveProvider{}
+	provider.sitemapLoading = make(chan struct{}) // a discovery that does not end
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := provider.sitemapProductURLs(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("sitemapProductURLs() while another discovery runs error = %v, want context.Canceled", err)
+	}
+	// The lock is free for others meanwhile
+	if !provider.sitemapMu.TryLock() {
+		t.Fatal("sitemap lock is held while waiting for a discovery")
+	}
+	provider.sitemapMu.Unlock()
+}
@@ -0,0 +1,463 @@
+package providers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/canonicalurl"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/util/strx"
+)
+
+// structuredProduct is a product described by a page's structured data: schema.org
+// Product JSON-LD or microdata, or OpenGraph product tags. HTML providers read it before
+// falling back to CSS class guesses, which differ from site to site.
+type structuredProduct struct {
+	Name     string
+	Brand    string
+	SKU      string
+	GTIN     string // GTIN-8/12/13/14 (UPC, EAN/JAN)
+	ImageURL string
+	URL      string
+	Offers   []structuredOffer
+}
+
+// structuredOffer is a schema.org Offer (or the low price of an AggregateOffer)
+type structuredOffer struct {
+	PriceAmount  int // minor units of Currency
+	Currency     string
+	Availability string // "in_stock", "out_of_stock", "preorder"; "" if not stated
+	Seller       string
+	URL          string
+}
+
+// extractStructuredProducts returns the products described by the page's JSON-LD, or
+// without any microdata, or without any OpenGraph product tags
+func extractStructuredProducts(doc *goquery.Document) []structuredProduct {
+	if products := jsonLDProducts(doc); len(products) > 0 {
+		return products
+	}
+	if products := microdataProducts(doc); len(products) > 0 {
+		return products
+	}
+	if product, ok := openGraphProduct(doc); ok {
+		return []structuredProduct{product}
+	}
+	return nil
+}
+
+// jsonLDProducts returns the schema.org Products of the page's JSON-LD scripts, including
+// those nested in an @graph or an ItemList
+func jsonLDProducts(doc *goquery.Document) []structuredProduct {
+	var products []structuredProduct
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return // Skip malformed scripts
+		}
+		collectJSONLDProducts(data, &products)
+	})
+	return products
+}
+
+func collectJSONLDProducts(node any, products *[]structuredProduct) {
+	switch v := node.(type) {
+	case []any:
+		for _, item := range v {
+			collectJSONLDProducts(item, products)
+		}
+	case map[string]any:
+		if jsonLDHasType(v, "Product") {
+			*products = append(*products, jsonLDProduct(v))
+			return
+		}
+		for _, key := range []string{"@graph", "itemListElement", "item", "mainEntity"} {
+			if child, ok := v[key]; ok {
+				collectJSONLDProducts(child, products)
+			}
+		}
+	}
+}
+
+func jsonLDProduct(v map[string]any) structuredProduct {
+	product := structuredProduct{
+		Name:     jsonLDText(v["name"]),
+		Brand:    jsonLDName(v["brand"]),
+		SKU:      jsonLDText(v["sku"]),
+		ImageURL: jsonLDURL(v["image"]),
+		URL:      jsonLDText(v["url"]),
+	}
+	for _, key := range []string{"gtin13", "gtin12", "gtin14", "gtin8", "gtin"} {
+		if gtin := jsonLDText(v[key]); gtin != "" {
+			product.GTIN = gtin
+			break
+		}
+	}
+	for _, node := range jsonLDList(v["offers"]) {
+		offer, ok := node.(map[string]any)
+		if !ok {
+			continue
+		}
+		// An AggregateOffer states its cheapest price, and may list its offers
+		if nested := jsonLDList(offer["offers"]); len(nested) > 0 {
+			for _, node := range nested {
+				if nestedOffer, ok := node.(map[string]any); ok {
+					product.Offers = append(product.Offers, jsonLDOffer(nestedOffer))
+				}
+			}
+			continue
+		}
+		product.Offers = append(product.Offers, jsonLDOffer(offer))
+	}
+	return product
+}
+
+func jsonLDOffer(v map[string]any) structuredOffer {
+	price := jsonLDText(v["price"])
+	if price == "" {
+		price = jsonLDText(v["lowPrice"])
+	}
+	currency := jsonLDText(v["priceCurrency"])
+	if spec, ok := v["priceSpecification"].(map[string]any); ok {
+		if price == "" {
+			price = jsonLDText(spec["price"])
+		}
+		if currency == "" {
+			currency = jsonLDText(spec["priceCurrency"])
+		}
+	}
+	return structuredOffer{
+		PriceAmount:  structuredPrice(price, currency),
+		Currency:     money.NormalizeCurrency(currency),
+		Availability: structuredAvailability(jsonLDText(v["availability"])),
+		Seller:       jsonLDName(v["seller"]),
+		URL:          jsonLDText(v["url"]),
+	}
+}
+
+// jsonLDHasType reports whether a node's @type is (or includes) typeName
+func jsonLDHasType(v map[string]any, typeName string) bool {
+	for _, t := range jsonLDList(v["@type"]) {
+		if s, ok := t.(string); ok && (s == typeName || strings.HasSuffix(s, "/"+typeName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonLDList returns a value that may be a single node or an array as a slice
+func jsonLDList(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// jsonLDText returns a string or number value as text
+func jsonLDText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		if len(v) > 0 {
+			return jsonLDText(v[0])
+		}
+	}
+	return ""
+}
+
+// jsonLDName returns a value that is either a name or a Thing (Brand, Organization)
+func jsonLDName(value any) string {
+	for _, node := range jsonLDList(value) {
+		if thing, ok := node.(map[string]any); ok {
+			return jsonLDText(thing["name"])
+		}
+		return jsonLDText(node)
+	}
+	return ""
+}
+
+// jsonLDURL returns a value that is either a URL or an ImageObject
+func jsonLDURL(value any) string {
+	for _, node := range jsonLDList(value) {
+		if object, ok := node.(map[string]any); ok {
+			if url := jsonLDText(object["url"]); url != "" {
+				return url
+			}
+			return jsonLDText(object["contentUrl"])
+		}
+		return jsonLDText(node)
+	}
+	return ""
+}
+
+// microdataProducts returns the schema.org Product items of the page's microdata
+func microdataProducts(doc *goquery.Document) []structuredProduct {
+	var products []structuredProduct
+	doc.Find(`[itemscope][itemtype*="schema.org/Product"]`).Each(func(_ int, item *goquery.Selection) {
+		product := structuredProduct{
+			Name:     microdataProp(item, "name"),
+			Brand:    microdataProp(item, "brand"),
+			SKU:      microdataProp(item, "sku"),
+			ImageURL: microdataProp(item, "image"),
+			URL:      microdataProp(item, "url"),
+		}
+		for _, name := range []string{"gtin13", "gtin12", "gtin14", "gtin8", "gtin"} {
+			if gtin := microdataProp(item, name); gtin != "" {
+				product.GTIN = gtin
+				break
+			}
+		}
+		microdataProps(item, "offers").Each(func(_ int, offer *goquery.Selection) {
+			price := microdataProp(offer, "price")
+			if price == "" {
+				price = microdataProp(offer, "lowPrice")
+			}
+			currency := microdataProp(offer, "priceCurrency")
+			product.Offers = append(product.Offers, structuredOffer{
+				PriceAmount:  structuredPrice(price, currency),
+				Currency:     money.NormalizeCurrency(currency),
+				Availability: structuredAvailability(microdataProp(offer, "availability")),
+				Seller:       microdataProp(offer, "seller"),
+				URL:          microdataProp(offer, "url"),
+			})
+		})
+		products = append(products, product)
+	})
+	return products
+}
+
+// microdataProps returns the elements carrying property name of item, leaving out those
+// of items nested in it
+func microdataProps(item *goquery.Selection, name string) *goquery.Selection {
+	return item.Find(`[itemprop~="` + name + `"]`).FilterFunction(func(_ int, prop *goquery.Selection) bool {
+		return prop.Parent().Closest("[itemscope]").IsSelection(item)
+	})
+}
+
+// microdataProp returns the value of property name of item. A property that is itself an
+// item (a Brand or an Organization) has the value of its name.
+func microdataProp(item *goquery.Selection, name string) string {
+	prop := microdataProps(item, name).First()
+	if prop.Length() == 0 {
+		return ""
+	}
+	if _, ok := prop.Attr("itemscope"); ok {
+		return microdataProp(prop, "name")
+	}
+	if value, ok := prop.Attr("content"); ok {
+		return strings.TrimSpace(value)
+	}
+	switch goquery.NodeName(prop) {
+	case "a", "link":
+		return strings.TrimSpace(prop.AttrOr("href", ""))
+	case "img":
+		return strings.TrimSpace(prop.AttrOr("src", ""))
+	}
+	return strings.TrimSpace(prop.Text())
+}
+
+// openGraphProduct returns the product of the page's OpenGraph tags, if it is a product
+// page (og:type product or a product:price:amount tag)
+func openGraphProduct(doc *goquery.Document) (structuredProduct, bool) {
+	meta := func(properties ...string) string {
+		for _, property := range properties {
+			if value, ok := doc.Find(`meta[property="` + property + `"]`).First().Attr("content"); ok && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+		return ""
+	}
+
+	price := meta("product:price:amount", "og:price:amount")
+	if price == "" && meta("og:type") != "product" {
+		return structuredProduct{}, false
+	}
+	product := structuredProduct{
+		Name:     meta("og:title"),
+		Brand:    meta("product:brand", "og:brand"),
+		SKU:      meta("product:retailer_item_id"),
+		GTIN:     meta("product:upc", "product:ean", "product:gtin"),
+		ImageURL: meta("og:image"),
+		URL:      meta("og:url"),
+	}
+	if price != "" {
+		currency := meta("product:price:currency", "og:price:currency")
+		product.Offers = []structuredOffer{{
+			PriceAmount:  structuredPrice(price, currency),
+			Currency:     money.NormalizeCurrency(currency),
+			Availability: structuredAvailability(meta("product:availability", "og:availability")),
+		}}
+	}
+	return product, true
+}
+
+// structuredPrice converts a machine-readable price ("1299.00", "$1,299", "1.299,00 €")
+// to minor units of currency; 0 if it is not a price
+func structuredPrice(text, currency string) int {
+	price, err := strconv.ParseFloat(normalizeDecimal(text), 64)
+	if err != nil || price <= 0 {
+		return 0
+	}
+	return money.FromMajor(price, currency).Amount
+}
+
+// normalizeDecimal keeps the number of a price text with "." as its decimal separator,
+// telling the separators apart: with both "." and ",", the last one is the decimal
+// separator ("1,299.00", "1.299,00"); a separator that repeats groups thousands
+// ("1.299.000"); a lone "," groups thousands before three digits ("1,299") and is
+// decimal otherwise ("12,99"); a lone "." is decimal.
+func normalizeDecimal(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' {
+			b.WriteRune(r)
+		}
+	}
+	number := b.String()
+
+	dot, comma := strings.LastIndex(number, "."), strings.LastIndex(number, ",")
+	decimal := -1
+	switch {
+	case dot >= 0 && comma >= 0:
+		decimal = max(dot, comma)
+	case comma >= 0:
+		if strings.Count(number, ",") == 1 && len(number)-comma-1 != 3 {
+			decimal = comma
+		}
+	case dot >= 0:
+		if strings.Count(number, ".") == 1 {
+			decimal = dot
+		}
+	}
+
+	b.Reset()
+	for i, r := range number {
+		switch {
+		case i == decimal:
+			b.WriteByte('.')
+		case r != '.' && r != ',':
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// structuredAvailability maps a schema.org ItemAvailability ("https://schema.org/InStock")
+// or an OpenGraph availability ("instock", "oos") to an offer availability status
+func structuredAvailability(value string) string {
+	value = value[strings.LastIndex(value, "/")+1:]
+	switch strings.ToLower(strings.ReplaceAll(value, " ", "")) {
+	case "instock", "limitedavailability", "instoreonly", "onlineonly":
+		return "in_stock"
+	case "outofstock", "oos", "soldout", "discontinued":
+		return "out_of_stock"
+	case "preorder", "presale", "backorder", "pending":
+		return "preorder"
+	}
+	return ""
+}
+
+// candidate returns the product as a search candidate of source. Relative URLs are
+// resolved against pageURL.
+func (sp structuredProduct) candidate(source, pageURL string) ProductCandidate {
+	title := sp.Name
+	if len(title) > 200 {
+		title = title[:200]
+	}
+	brand := strx.NonEmptyPtr(sp.Brand)
+	if brand == nil {
+		brand = extractBrand(title)
+	}
+	candidate := ProductCandidate{
+		Title:      title,
+		Brand:      brand,
+		ImageURL:   strx.NonEmptyPtr(canonicalurl.Resolve(pageURL, sp.ImageURL)),
+		Source:     source,
+		Identifier: strx.NonEmptyPtr(sp.SKU),
+		HasPrice:   sp.priced(),
+	}
+	if sp.URL != "" {
+		candidate.SourceURL = strx.NonEmptyPtr(canonicalurl.Resolve(pageURL, sp.URL))
+	}
+	if identifierType := gtinType(sp.GTIN); identifierType != "" {
+		candidate.ExternalIdentifiers = []CandidateIdentifier{{Type: identifierType, Value: sp.GTIN}}
+	}
+	return candidate
+}
+
+// offers returns the priced offers of the product as offers of source for productID.
+// Offers without a seller are sold by seller; those without a URL are on pageURL.
+func (sp structuredProduct) offers(productID uuid.UUID, source, seller, pageURL string) []*models.Offer {
+	var offers []*models.Offer
+	for _, o := range sp.Offers {
+		if o.PriceAmount <= 0 {
+			continue
+		}
+		offerSeller := o.Seller
+		if offerSeller == "" {
+			offerSeller = seller
+		}
+		offerURL := pageURL
+		if o.URL != "" {
+			offerURL = canonicalurl.Resolve(pageURL, o.URL)
+		}
+		offers = append(offers, &models.Offer{
+			ID:                 uuid.New(),
+			ProductID:          productID,
+			Source:             source,
+			Seller:             offerSeller,
+			PriceAmount:        o.PriceAmount,
+			Currency:           o.Currency,
+			ShippingToUSAmount: 0, // Will be calculated by shipping calculator
+			TotalToUSAmount:    0, // Will be calculated by shipping calculator
+			EstDeliveryDaysMin: intPtr(5),
+			EstDeliveryDaysMax: intPtr(10),
+			InStock:            o.Availability != "out_of_stock",
+			AvailabilityStatus: strx.NonEmptyPtr(o.Availability),
+			URL:                strx.NonEmptyPtr(offerURL),
+			FetchedAt:          time.Now(),
+		})
+	}
+	return offers
+}
+
+// priced reports whether any offer of the product states a price
+func (sp structuredProduct) priced() bool {
+	for _, o := range sp.Offers {
+		if o.PriceAmount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gtinType returns the product_identifiers type of a GTIN by its length; "" if it is not one
+func gtinType(gtin string) string {
+	for _, r := range gtin {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	switch len(gtin) {
+	case 12:
+		return "UPC"
+	case 13:
+		return "EAN"
+	case 8, 14:
+		return "GTIN"
+	}
+	return ""
+}
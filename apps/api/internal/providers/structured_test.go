@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+)
+
+func TestExtractStructuredProducts(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want structuredProduct
+	}{
+		{
+			name: "JSON-LD in a graph",
+			page: `<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+				{"@type":"WebPage","name":"Shop"},
+				{"@type":"Product","name":"Sony WH-1000XM5","brand":{"@type":"Brand","name":"Sony"},"sku":"WH1000XM5B","gtin13":"4548736132566",
+				 "image":["/img/xm5.jpg"],"offers":{"@type":"Offer","price":"44000","priceCurrency":"JPY","availability":"https://schema.org/InStock",
+				 "seller":{"@type":"Organization","name":"Sony Store"}}}]}</script>`,
+			want: structuredProduct{Name: "Sony WH-1000XM5", Brand: "Sony", SKU: "WH1000XM5B", GTIN: "4548736132566", ImageURL: "/img/xm5.jpg",
+				Offers: []structuredOffer{{PriceAmount: 44000, Currency: "JPY", Availability: "in_stock", Seller: "Sony Store"}}},
+		},
+		{
+			name: "JSON-LD aggregate offer",
+			page: `<script type="application/ld+json">{"@type":"Product","name":"Bose QC45","offers":{"@type":"AggregateOffer","lowPrice":279.5,"priceCurrency":"USD"}}</script>`,
+			want: structuredProduct{Name: "Bose QC45", Offers: []structuredOffer{{PriceAmount: 27950, Currency: "USD"}}},
+		},
+		{
+			name: "microdata",
+			page: `<div itemscope itemtype="https://schema.org/Product">
+				<h1 itemprop="name">Apple AirPods Pro</h1>
+				<div itemprop="brand" itemscope itemtype="https://schema.org/Brand"><span itemprop="name">Apple</span></div>
+				<meta itemprop="gtin12" content="194253397168">
+				<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+					<span itemprop="price" content="249.00">$249.00</span><meta itemprop="priceCurrency" content="USD">
+					<link itemprop="availability" href="https://schema.org/OutOfStock">
+					<div itemprop="seller" itemscope itemtype="https://schema.org/Organization"><span itemprop="name">Gadget Shop</span></div>
+				</div></div>`,
+			want: structuredProduct{Name: "Apple AirPods Pro", Brand: "Apple", GTIN: "194253397168",
+				Offers: []structuredOffer{{PriceAmount: 24900, Currency: "USD", Availability: "out_of_stock", Seller: "Gadget Shop"}}},
+		},
+		{
+			name: "OpenGraph",
+			page: `<meta property="og:type" content="product"><meta property="og:title" content="Nintendo Switch">
+				<meta property="product:price:amount" content="299.99"><meta property="product:price:currency" content="USD">
+				<meta property="product:availability" content="preorder">`,
+			want: structuredProduct{Name: "Nintendo Switch", Offers: []structuredOffer{{PriceAmount: 29999, Currency: "USD", Availability: "preorder"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><head></head><body>" + tt.page + "</body></html>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			products := extractStructuredProducts(doc)
+			if len(products) != 1 {
+				t.Fatalf("products = %+v, want 1", products)
+			}
+			got := products[0]
+			if got.Name != tt.want.Name || got.Brand != tt.want.Brand || got.SKU != tt.want.SKU || got.GTIN != tt.want.GTIN || got.ImageURL != tt.want.ImageURL {
+				t.Errorf("product = %+v, want %+v", got, tt.want)
+			}
+			if len(got.Offers) != len(tt.want.Offers) || (len(got.Offers) > 0 && got.Offers[0] != tt.want.Offers[0]) {
+				t.Errorf("offers = %+v, want %+v", got.Offers, tt.want.Offers)
+			}
+		})
+	}
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><meta property="og:type" content="website"></head><body><div class="price">$10</div></body></html>`))
+	if products := extractStructuredProducts(doc); len(products) != 0 {
+		t.Errorf("page without structured data = %+v, want none", products)
+	}
+}
+
+func TestStructuredProductCandidateAndOffers(t *testing.T) {
+	product := structuredProduct{Name: "Sony WH-1000XM5", SKU: "WH1000XM5B", GTIN: "027242923782", ImageURL: "/img/xm5.jpg",
+		Offers: []structuredOffer{{PriceAmount: 29999, Currency: "USD", Availability: "out_of_stock"}, {Currency: "USD"}}}
+
+	candidate := product.candidate("live", "https://shop.example.com/p/xm5")
+	if candidate.Brand == nil || *candidate.Brand != "Sony" || candidate.Identifier == nil || *candidate.Identifier != "WH1000XM5B" || !candidate.HasPrice {
+		t.Errorf("candidate = %+v", candidate)
+	}
+	if candidate.ImageURL == nil || *candidate.ImageURL != "https://shop.example.com/img/xm5.jpg" {
+		t.Errorf("image URL = %v, want it resolved against the page", candidate.ImageURL)
+	}
+	if len(candidate.ExternalIdentifiers) != 1 || candidate.ExternalIdentifiers[0] != (CandidateIdentifier{Type: "UPC", Value: "027242923782"}) {
+		t.Errorf("external identifiers = %+v, want the UPC", candidate.ExternalIdentifiers)
+	}
+
+	offers := product.offers(uuid.New(), "live", "shop.example.com", "https://shop.example.com/p/xm5")
+	if len(offers) != 1 {
+		t.Fatalf("offers = %d, want only the priced one", len(offers))
+	}
+	if offers[0].Seller != "shop.example.com" || offers[0].InStock || *offers[0].AvailabilityStatus != "out_of_stock" || *offers[0].URL != "https://shop.example.com/p/xm5" {
+		t.Errorf("offer = %+v", offers[0])
+	}
+}
+
+func TestStructuredPrice(t *testing.T) {
+	tests := []struct {
+		text     string
+		currency string
+		want     int
+	}{
+		{"1299.00", "USD", 129900},
+		{"$1,299", "USD", 129900},
+		{"$1,299.50", "USD", 129950},
+		{"1.299,00 €", "EUR", 129900},
+		{"1.299.000", "JPY", 1299000},
+		{"12,99", "EUR", 1299},
+		{"1 299,5", "EUR", 129950},
+		{"¥44,000", "JPY", 44000},
+		{"free", "USD", 0},
+	}
+	for _, tt := range tests {
+		if got := structuredPrice(tt.text, tt.currency); got != tt.want {
+			t.Errorf("structuredPrice(%q, %s) = %d, want %d", tt.text, tt.currency, got, tt.want)
+		}
+	}
+}
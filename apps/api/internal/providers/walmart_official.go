@@ -12,8 +12,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pricecompare/api/internal/models"
 	"github.com/pricecompare/api/internal/httpclient"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/money"
+	"github.com/pricecompare/api/internal/resolver"
+	"github.com/pricecompare/api/internal/util/strx"
 )
 
 // WalmartOfficialProvider implements Walmart Data API
@@ -64,10 +67,16 @@ func (p *WalmartOfficialProvider) IsEnabled() bool {
 	return p.enabled
 }
 
+// Ping verifies the API key with a minimal search; there is no cheaper authenticated endpoint
+func (p *WalmartOfficialProvider) Ping(ctx context.Context) error {
+	_, err := p.Search(ctx, "test")
+	return err
+}
+
 // Search searches for products using Walmart API
 func (p *WalmartOfficialProvider) Search(ctx context.Context, query string) ([]ProductCandidate, error) {
 	if !p.enabled {
-		return nil, fmt.Errorf("Walmart API provider is not enabled (WALMART_API_KEY not set)")
+		return nil, fmt.Errorf("%w: Walmart API (WALMART_API_KEY not set)", ErrNotEnabled)
 	}
 
 	if query == "" {
@@ -93,43 +102,32 @@ func (p *WalmartOfficialProvider) Search(ctx context.Context, query string) ([]P
 		req.Header.Set("X-RapidAPI-Key", p.apiKey)
 		req.Header.Set("X-RapidAPI-Host", p.apiHost)
 	}
-	req.Header.Set("User-Agent", "PriceCompareBot/1.0")
+	req.Header.Set("User-Agent", p.httpClient.UserAgent("walmart"))
 	req.Header.Set("Accept", "application/json")
 
 	// For API endpoints, we use direct HTTP client (robots.txt check is not needed for API)
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("walmart")}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch from Walmart API: %w", err)
+		return nil, fmt.Errorf("failed to fetch from Walmart API: %w", redactURLError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		// Log detailed error for debugging
-		return nil, fmt.Errorf("Walmart API returned status %d for URL %s: %s", resp.StatusCode, searchURL, string(body))
+		return nil, StatusError(resp.StatusCode, fmt.Sprintf("Walmart API returned status %d for URL %s: %s", resp.StatusCode, searchURL, string(body)))
+	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
 	}
 
-	// Parse response - RapidAPI Walmart Data API format
+	// Parse response - RapidAPI Walmart Data API format. Items are kept raw so they can be
+	// stored with the listing and re-parsed later (see ParseRaw).
 	var apiResponse struct {
-		SearchTerms     string `json:"searchTerms"`
-		AggregatedCount int    `json:"aggregatedCount"`
-		SearchResult    [][]struct {
-			Name         string `json:"name"`
-			Image        string `json:"image"`
-			Price        float64 `json:"price"`
-			PriceInfo    struct {
-				LinePrice string `json:"linePrice"`
-				MinPrice  float64 `json:"minPrice"`
-			} `json:"priceInfo"`
-			ProductLink                  string `json:"productLink"`
-			AvailabilityStatusDisplayValue string `json:"availabilityStatusDisplayValue"`
-			IsOutOfStock                bool   `json:"isOutOfStock"`
-			FulfillmentBadgeGroups      []struct {
-				Text    string `json:"text"`
-				SlaText string `json:"slaText"`
-			} `json:"fulfillmentBadgeGroups"`
-		} `json:"searchResult"`
+		SearchTerms     string              `json:"searchTerms"`
+		AggregatedCount int                 `json:"aggregatedCount"`
+		SearchResult    [][]json.RawMessage `json:"searchResult"`
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -138,37 +136,72 @@ func (p *WalmartOfficialProvider) Search(ctx context.Context, query string) ([]P
 	}
 
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse Walmart API response: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse Walmart API response: %w", ErrParse, err)
 	}
 
 	// Convert to ProductCandidate
 	// searchResult is a 2D array, first element contains the products
 	candidates := make([]ProductCandidate, 0)
 	if len(apiResponse.SearchResult) > 0 {
-		for _, item := range apiResponse.SearchResult[0] {
-			if item.Name == "" {
+		for _, raw := range apiResponse.SearchResult[0] {
+			candidate, err := p.ParseRaw(raw)
+			if err != nil {
+				return nil, err
+			}
+			if candidate == nil {
 				continue // Skip empty entries (ads, etc.)
 			}
-			// Extract itemId from Walmart URL
-			// Format: https://www.walmart.com/ip/.../5461164337?...
-			itemId := extractWalmartItemId(item.ProductLink)
-			candidates = append(candidates, ProductCandidate{
-				Title:      item.Name,
-				ImageURL:   stringPtr(item.Image),
-				Source:     "walmart",
-				Identifier: itemId,
-				SourceURL:  stringPtr(item.ProductLink),
-			})
+			candidates = append(candidates, *candidate)
 		}
 	}
 
 	return candidates, nil
 }
 
+// walmartSchemaVersion is the version of ParseRaw's mapping; raise it when the mapping
+// changes so stored listings are re-parsed by the reprocess_raw job
+const walmartSchemaVersion = 1
+
+// walmartSearchItem is one item of the searchResult of the Walmart Data API
+type walmartSearchItem struct {
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ProductLink string `json:"productLink"`
+}
+
+// RawSchemaVersion implements RawParser
+func (p *WalmartOfficialProvider) RawSchemaVersion() int {
+	return walmartSchemaVersion
+}
+
+// ParseRaw maps one search result item to a candidate, or nil for an empty entry
+func (p *WalmartOfficialProvider) ParseRaw(raw []byte) (*ProductCandidate, error) {
+	var item walmartSearchItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Walmart search item: %w", ErrParse, err)
+	}
+	if item.Name == "" {
+		return nil, nil
+	}
+	// Extract itemId from Walmart URL
+	// Format: https://www.walmart.com/ip/.../5461164337?...
+	itemId := extractWalmartItemId(item.ProductLink)
+	return &ProductCandidate{
+		Title:         item.Name,
+		ImageURL:      strx.NonEmptyPtr(item.Image),
+		Source:        "walmart",
+		Identifier:    itemId,
+		SourceURL:     strx.NonEmptyPtr(item.ProductLink),
+		Language:      "en",
+		Raw:           raw,
+		SchemaVersion: walmartSchemaVersion,
+	}, nil
+}
+
 // FetchOffers fetches offers for a product using Walmart Data API
-func (p *WalmartOfficialProvider) FetchOffers(ctx context.Context, product *models.Product) ([]*models.Offer, error) {
+func (p *WalmartOfficialProvider) FetchOffers(ctx context.Context, product *models.Product, listingURL string) ([]*models.Offer, error) {
 	if !p.enabled {
-		return nil, fmt.Errorf("Walmart API provider is not enabled (WALMART_API_KEY not set)")
+		return nil, fmt.Errorf("%w: Walmart API (WALMART_API_KEY not set)", ErrNotEnabled)
 	}
 
 	// Search for the product to get item details
@@ -205,33 +238,36 @@ func (p *WalmartOfficialProvider) createOffersFromSearch(ctx context.Context, pr
 		req.Header.Set("X-RapidAPI-Key", p.apiKey)
 		req.Header.Set("X-RapidAPI-Host", p.apiHost)
 	}
-	req.Header.Set("User-Agent", "PriceCompareBot/1.0")
+	req.Header.Set("User-Agent", p.httpClient.UserAgent("walmart"))
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: p.httpClient.TransportFor("walmart")}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch search results: %w", err)
+		return nil, fmt.Errorf("failed to fetch search results: %w", redactURLError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return []*models.Offer{}, nil
 	}
+	if err := checkContentType(resp, httpclient.ContentJSON); err != nil {
+		return nil, err
+	}
 
 	var searchResponse struct {
 		SearchResult [][]struct {
-			Name         string `json:"name"`
-			Image        string `json:"image"`
-			Price        float64 `json:"price"`
-			PriceInfo    struct {
-				LinePrice string `json:"linePrice"`
+			Name      string  `json:"name"`
+			Image     string  `json:"image"`
+			Price     float64 `json:"price"`
+			PriceInfo struct {
+				LinePrice string  `json:"linePrice"`
 				MinPrice  float64 `json:"minPrice"`
 			} `json:"priceInfo"`
-			ProductLink                  string `json:"productLink"`
+			ProductLink                    string `json:"productLink"`
 			AvailabilityStatusDisplayValue string `json:"availabilityStatusDisplayValue"`
-			IsOutOfStock                bool   `json:"isOutOfStock"`
-			FulfillmentBadgeGroups      []struct {
+			IsOutOfStock                   bool   `json:"isOutOfStock"`
+			FulfillmentBadgeGroups         []struct {
 				Text    string `json:"text"`
 				SlaText string `json:"slaText"`
 			} `json:"fulfillmentBadgeGroups"`
@@ -250,17 +286,17 @@ func (p *WalmartOfficialProvider) createOffersFromSearch(ctx context.Context, pr
 	// Find matching product by title similarity
 	// searchResult is a 2D array, first element contains the products
 	var matchedProduct *struct {
-		Name         string `json:"name"`
-		Image        string `json:"image"`
-		Price        float64 `json:"price"`
-		PriceInfo    struct {
-			LinePrice string `json:"linePrice"`
+		Name      string  `json:"name"`
+		Image     string  `json:"image"`
+		Price     float64 `json:"price"`
+		PriceInfo struct {
+			LinePrice string  `json:"linePrice"`
 			MinPrice  float64 `json:"minPrice"`
 		} `json:"priceInfo"`
-		ProductLink                  string `json:"productLink"`
+		ProductLink                    string `json:"productLink"`
 		AvailabilityStatusDisplayValue string `json:"availabilityStatusDisplayValue"`
-		IsOutOfStock                bool   `json:"isOutOfStock"`
-		FulfillmentBadgeGroups      []struct {
+		IsOutOfStock                   bool   `json:"isOutOfStock"`
+		FulfillmentBadgeGroups         []struct {
 			Text    string `json:"text"`
 			SlaText string `json:"slaText"`
 		} `json:"fulfillmentBadgeGroups"`
@@ -299,7 +335,7 @@ func (p *WalmartOfficialProvider) createOffersFromSearch(ctx context.Context, pr
 	if matchedProduct.PriceInfo.MinPrice > 0 {
 		priceFloat = matchedProduct.PriceInfo.MinPrice
 	}
-	priceAmount := int(priceFloat * 100) // Convert to cents
+	priceAmount := money.FromMajor(priceFloat, "USD").Amount // Convert to cents
 
 	// Parse shipping message for delivery days from fulfillmentBadgeGroups
 	shippingMessage := ""
@@ -340,8 +376,9 @@ func (p *WalmartOfficialProvider) createOffersFromSearch(ctx context.Context, pr
 		EstDeliveryDaysMin: estMinDays,
 		EstDeliveryDaysMax: estMaxDays,
 		InStock:            !matchedProduct.IsOutOfStock,
-		AvailabilityStatus: stringPtr(availabilityStatus),
-		URL:                stringPtr(matchedProduct.ProductLink),
+		AvailabilityStatus: strx.NonEmptyPtr(availabilityStatus),
+		URL:                strx.NonEmptyPtr(matchedProduct.ProductLink),
+		FreeShipping:       strings.Contains(strings.ToLower(shippingMessage), "free shipping"),
 		PriceUpdatedAt:     now,
 		FetchedAt:          now,
 	}
@@ -352,7 +389,7 @@ func (p *WalmartOfficialProvider) createOffersFromSearch(ctx context.Context, pr
 // estimateDeliveryDaysFromShipping estimates delivery days from shipping message
 func estimateDeliveryDaysFromShipping(shippingMsg string) (*int, *int) {
 	shippingLower := strings.ToLower(shippingMsg)
-	
+
 	if strings.Contains(shippingLower, "arrives today") || strings.Contains(shippingLower, "delivery today") {
 		return intPtr(0), intPtr(1)
 	} else if strings.Contains(shippingLower, "arrives tomorrow") || strings.Contains(shippingLower, "delivery tomorrow") {
@@ -364,45 +401,28 @@ func estimateDeliveryDaysFromShipping(shippingMsg string) (*int, *int) {
 	} else if strings.Contains(shippingLower, "free shipping") {
 		return intPtr(3), intPtr(7)
 	}
-	
+
 	// Default estimate
 	return intPtr(3), intPtr(7)
 }
 
+// MatchURL recognizes Walmart product pages, see URLMatcher
+func (p *WalmartOfficialProvider) MatchURL(u *url.URL) (string, string, bool) {
+	return resolver.WalmartItemID(u)
+}
+
 // extractWalmartItemId extracts itemId from Walmart product URL
 // Format: https://www.walmart.com/ip/.../5461164337?...
 func extractWalmartItemId(urlStr string) *string {
 	if urlStr == "" {
 		return nil
 	}
-	// Parse URL to extract path
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return nil
 	}
-	// Path format: /ip/.../5461164337
-	// Extract the last numeric segment before query params
-	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
-	for i := len(pathParts) - 1; i >= 0; i-- {
-		part := pathParts[i]
-		// Check if this part is numeric (itemId)
-		if len(part) > 0 {
-			// Try to find numeric segment (itemId is typically 8-10 digits)
-			if matched := strings.TrimSpace(part); len(matched) >= 6 {
-				// Check if it's numeric
-				isNumeric := true
-				for _, r := range matched {
-					if r < '0' || r > '9' {
-						isNumeric = false
-						break
-					}
-				}
-				if isNumeric {
-					return &matched
-				}
-			}
-		}
+	if _, itemID, ok := resolver.WalmartItemID(u); ok {
+		return &itemID
 	}
 	return nil
 }
-
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Dispatcher shares a fixed number of concurrent outbound requests between providers, so
+// a burst of one provider's jobs cannot take every slot. Waiting requests are served by
+// start-time fair queuing: each request of a provider advances the provider's virtual
+// time by 1/weight, and a freed slot goes to the request with the earliest start, so
+// providers get slots in proportion to their weights while all of them are busy.
+type Dispatcher struct {
+	mu          sync.Mutex
+	slots       int // 0 is unlimited
+	weights     map[string]float64
+	active      int
+	virtualTime float64
+	finish      map[string]float64 // virtual time of the provider's last queued request
+	waiting     []*dispatchWaiter
+	seq         uint64
+}
+
+type dispatchWaiter struct {
+	start   float64
+	seq     uint64 // FIFO among equal starts
+	ready   chan struct{}
+	granted bool
+}
+
+// NewDispatcher creates a dispatcher with slots concurrent requests (0 is unlimited).
+// Providers missing from weights have weight 1.
+func NewDispatcher(slots int, weights map[string]float64) *Dispatcher {
+	return &Dispatcher{
+		slots:   slots,
+		weights: weights,
+		finish:  make(map[string]float64),
+	}
+}
+
+// SetConfig replaces the number of slots and the weights, e.g. on a config reload
+func (d *Dispatcher) SetConfig(slots int, weights map[string]float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.slots = slots
+	d.weights = weights
+	d.dispatchLocked()
+}
+
+// Acquire waits for a slot for a request of providerKey. The caller must call release
+// once the request is done.
+func (d *Dispatcher) Acquire(ctx context.Context, providerKey string) (release func(), err error) {
+	d.mu.Lock()
+	if d.slots <= 0 && len(d.waiting) == 0 {
+		d.mu.Unlock()
+		return func() {}, nil
+	}
+	weight := d.weights[providerKey]
+	if weight <= 0 {
+		weight = 1
+	}
+	start := max(d.virtualTime, d.finish[providerKey])
+	d.finish[providerKey] = start + 1/weight
+	d.seq++
+	w := &dispatchWaiter{start: start, seq: d.seq, ready: make(chan struct{})}
+	d.waiting = append(d.waiting, w)
+	d.dispatchLocked()
+	d.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return d.releaseFunc(), nil
+	case <-ctx.Done():
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if w.granted {
+			d.active--
+			d.dispatchLocked()
+		} else {
+			d.removeLocked(w)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (d *Dispatcher) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.active--
+			d.dispatchLocked()
+		})
+	}
+}
+
+// dispatchLocked grants free slots to the waiting requests with the earliest start
+func (d *Dispatcher) dispatchLocked() {
+	for len(d.waiting) > 0 && (d.slots <= 0 || d.active < d.slots) {
+		next := 0
+		for i, w := range d.waiting[1:] {
+			if w.start < d.waiting[next].start || (w.start == d.waiting[next].start && w.seq < d.waiting[next].seq) {
+				next = i + 1
+			}
+		}
+		w := d.waiting[next]
+		d.removeLocked(w)
+		d.virtualTime = w.start
+		d.active++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+func (d *Dispatcher) removeLocked(w *dispatchWaiter) {
+	for i, waiting := range d.waiting {
+		if waiting == w {
+			d.waiting = append(d.waiting[:i], d.waiting[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_WeightedOrder(t *testing.T) {
+	d := NewDispatcher(1, map[string]float64{"walmart": 3})
+	hold, err := d.Acquire(context.Background(), "hold")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Queue four requests of each provider while the only slot is taken
+	granted := make(chan string, 8)
+	for _, key := range []string{"walmart", "walmart", "walmart", "walmart", "live", "live", "live", "live"} {
+		queued := waitingCount(d) + 1
+		go func(key string) {
+			release, err := d.Acquire(context.Background(), key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			granted <- key
+			release()
+		}(key)
+		for waitingCount(d) < queued {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	hold()
+
+	var order []string
+	for i := 0; i < 8; i++ {
+		order = append(order, <-granted)
+	}
+	want := "walmart live walmart walmart walmart live live live"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestDispatcher_Cancel(t *testing.T) {
+	d := NewDispatcher(1, nil)
+	hold, _ := d.Acquire(context.Background(), "live")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := d.Acquire(ctx, "walmart"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() error = %v, want DeadlineExceeded", err)
+	}
+	if n := waitingCount(d); n != 0 {
+		t.Errorf("waiting = %d after cancel, want 0", n)
+	}
+
+	hold()
+	hold() // releasing twice frees one slot
+	release, err := d.Acquire(context.Background(), "walmart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if d.active != 0 {
+		t.Errorf("active = %d, want 0", d.active)
+	}
+}
+
+func TestDispatcher_Unlimited(t *testing.T) {
+	d := NewDispatcher(0, nil)
+	for i := 0; i < 100; i++ {
+		if _, err := d.Acquire(context.Background(), "live"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Limiting later applies to new requests only
+	d.SetConfig(1, nil)
+	release, err := d.Acquire(context.Background(), "live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func waitingCount(d *Dispatcher) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.waiting)
+}
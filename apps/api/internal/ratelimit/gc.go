@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// CollectIdle removes the limiters (per provider, per host and per crawl delay) whose
+// bucket is full again and returns how many it removed. A full bucket carries no state:
+// the limiter created on the next request behaves the same, so removing it changes no
+// wait while the maps stop growing with every host and site ever requested. (A request
+// that got the limiter just before may still take a token from the removed one.)
+func (m *Manager) CollectIdle() int {
+	now := time.Now()
+	m.mu.Lock()
+	removed := collectFull(m.limiters, now) + collectFull(m.hostRateLimiters, now)
+	m.mu.Unlock()
+
+	m.hostMu.Lock()
+	removed += collectFull(m.crawlDelayLimiters, now)
+	m.hostMu.Unlock()
+	return removed
+}
+
+// collectFull removes the limiters of limiters that are full at now. The caller holds
+// the lock of the map.
+func collectFull(limiters map[string]*rate.Limiter, now time.Time) int {
+	removed := 0
+	for key, limiter := range limiters {
+		if limiter.Limit() == rate.Inf || limiter.TokensAt(now) >= float64(limiter.Burst()) {
+			delete(limiters, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunGC removes idle limiters every interval until ctx is done, see CollectIdle
+func (m *Manager) RunGC(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed := m.CollectIdle(); removed > 0 {
+				m.logger.Debug("Removed idle rate limiters", "count", removed)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// HostBuckets are token buckets shared by the server instances, so a host's limit holds
+// however many instances and jobs send requests to it
+type HostBuckets interface {
+	// Reserve takes the next token of host's bucket and returns how long the caller has
+	// to wait before using it
+	Reserve(ctx context.Context, host string, config RateLimitConfig) (time.Duration, error)
+}
+
+// WaitHostRateLimit waits for a token of the rate limit of host (per target host, across
+// providers), in addition to the provider's own limit. With shared buckets (see
+// SetHostBuckets) the limit holds across instances; if they cannot be reached, each
+// instance limits its own requests.
+func (m *Manager) WaitHostRateLimit(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	m.mu.RLock()
+	config, ok := m.hostRateConfigs[host]
+	if !ok {
+		config = m.defaultHostConfig
+	}
+	buckets := m.hostBuckets
+	m.mu.RUnlock()
+	if config.RPS <= 0 || host == "" {
+		return nil
+	}
+
+	if buckets != nil {
+		wait, err := buckets.Reserve(ctx, host, config)
+		if err == nil {
+			return sleep(ctx, wait)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.logger.Warn("Shared host rate limit unavailable, limiting locally", "host", host, "error", err)
+	}
+	return m.getHostRateLimiter(host, config).Wait(ctx)
+}
+
+// getHostRateLimiter gets or creates the local limiter of host
+func (m *Manager) getHostRateLimiter(host string, config RateLimitConfig) *rate.Limiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	limiter, ok := m.hostRateLimiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(config.RPS), config.Burst)
+		m.hostRateLimiters[host] = limiter
+	}
+	return limiter
+}
+
+// SetHostConfigs replaces the per-host rate limits: configs for the hosts listed and
+// defaultConfig for all others (RPS 0 leaves them unlimited)
+func (m *Manager) SetHostConfigs(configs map[string]RateLimitConfig, defaultConfig RateLimitConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hostRateConfigs = make(map[string]RateLimitConfig, len(configs))
+	for host, config := range configs {
+		m.hostRateConfigs[strings.ToLower(host)] = config
+	}
+	m.defaultHostConfig = defaultConfig
+	for host, limiter := range m.hostRateLimiters {
+		config, ok := m.hostRateConfigs[host]
+		if !ok {
+			config = defaultConfig
+		}
+		limiter.SetLimit(rate.Limit(config.RPS))
+		limiter.SetBurst(config.Burst)
+	}
+}
+
+// SetHostBuckets shares the per-host rate limits across instances through buckets
+func (m *Manager) SetHostBuckets(buckets HostBuckets) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hostBuckets = buckets
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
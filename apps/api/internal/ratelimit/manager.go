@@ -2,19 +2,33 @@ package ratelimit
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
 // Manager manages rate limiters per provider
 type Manager struct {
-	limiters map[string]*rate.Limiter
-	configs  map[string]RateLimitConfig
+	limiters      map[string]*rate.Limiter
+	configs       map[string]RateLimitConfig
 	defaultConfig RateLimitConfig
-	mu       sync.RWMutex
-	logger   *slog.Logger
+	mu            sync.RWMutex
+	logger        *slog.Logger
+
+	crawlDelayLimiters map[string]*rate.Limiter // host -> one request per its crawl delay, see WaitCrawlDelay
+	hostMu             sync.Mutex
+
+	hostRateConfigs   map[string]RateLimitConfig // host -> its own limit, see WaitHostRateLimit
+	defaultHostConfig RateLimitConfig            // RPS 0 leaves hosts unlimited
+	hostRateLimiters  map[string]*rate.Limiter
+	hostBuckets       HostBuckets // shared across instances, nil limits each instance on its own
+
+	maxWait time.Duration            // longest wait Wait accepts, 0 is unlimited; see SetMaxWait
+	stats   map[string]*limiterStats // provider -> its counters, see Stats
+	statsMu sync.Mutex
 }
 
 // RateLimitConfig holds rate limit configuration
@@ -26,16 +40,64 @@ type RateLimitConfig struct {
 // NewManager creates a new rate limit manager
 func NewManager(configs map[string]RateLimitConfig, defaultConfig RateLimitConfig, logger *slog.Logger) *Manager {
 	return &Manager{
-		limiters:      make(map[string]*rate.Limiter),
-		configs:       configs,
-		defaultConfig: defaultConfig,
-		logger:        logger,
+		limiters:           make(map[string]*rate.Limiter),
+		crawlDelayLimiters: make(map[string]*rate.Limiter),
+		hostRateLimiters:   make(map[string]*rate.Limiter),
+		stats:              make(map[string]*limiterStats),
+		configs:            configs,
+		defaultConfig:      defaultConfig,
+		logger:             logger,
 	}
 }
 
-// Wait waits for rate limit token for the given provider
+// Wait waits for rate limit token for the given provider. A token further away than the
+// wait budget (see SetMaxWait) or the context's deadline is not waited for: Wait returns
+// an ErrRateBudgetExceeded or context error at once.
 func (m *Manager) Wait(ctx context.Context, providerKey string) error {
 	limiter := m.getLimiter(providerKey)
+	m.mu.RLock()
+	maxWait := m.maxWait
+	m.mu.RUnlock()
+
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return fmt.Errorf("rate limit of %s has a burst of 0", providerKey)
+	}
+	delay := reservation.Delay()
+	if maxWait > 0 && delay > maxWait {
+		reservation.Cancel()
+		m.recordBudgetExceeded(providerKey)
+		return fmt.Errorf("%w: %s would wait %s, more than %s", ErrRateBudgetExceeded, providerKey, delay.Round(time.Millisecond), maxWait)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		reservation.Cancel()
+		return fmt.Errorf("rate limit wait of %s would exceed context deadline: %w", delay.Round(time.Millisecond), context.DeadlineExceeded)
+	}
+	if err := sleep(ctx, delay); err != nil {
+		reservation.Cancel()
+		return err
+	}
+	m.recordWait(providerKey, delay)
+	return nil
+}
+
+// WaitCrawlDelay waits until a request to host is at least delay after the previous one, for
+// the Crawl-delay of the host's robots.txt. It applies across providers, in addition to
+// their own limits. A delay of 0 does not wait.
+func (m *Manager) WaitCrawlDelay(ctx context.Context, host string, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	m.hostMu.Lock()
+	limiter, ok := m.crawlDelayLimiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(delay), 1)
+		m.crawlDelayLimiters[host] = limiter
+	} else if limiter.Limit() != rate.Every(delay) {
+		// The robots.txt changed
+		limiter.SetLimit(rate.Every(delay))
+	}
+	m.hostMu.Unlock()
 	return limiter.Wait(ctx)
 }
 
@@ -71,3 +133,38 @@ func (m *Manager) getLimiter(providerKey string) *rate.Limiter {
 	return limiter
 }
 
+// SetConfig sets the rate limit of one provider, e.g. from the admin API. Its limiter is
+// swapped for a new one under the lock, so every later request sees the new rate and
+// burst together; requests already waiting finish at the old rate. SetConfigs (a config
+// reload) replaces it again.
+func (m *Manager) SetConfig(providerKey string, config RateLimitConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Copy on write: SetConfigs keeps the caller's map
+	configs := make(map[string]RateLimitConfig, len(m.configs)+1)
+	for key, c := range m.configs {
+		configs[key] = c
+	}
+	configs[providerKey] = config
+	m.configs = configs
+	m.limiters[providerKey] = rate.NewLimiter(rate.Limit(config.RPS), config.Burst)
+}
+
+// SetConfigs replaces the rate limit configuration. Existing limiters are updated in
+// place, so requests already waiting on them pick up the new limits.
+func (m *Manager) SetConfigs(configs map[string]RateLimitConfig, defaultConfig RateLimitConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.configs = configs
+	m.defaultConfig = defaultConfig
+	for providerKey, limiter := range m.limiters {
+		config, ok := configs[providerKey]
+		if !ok {
+			config = defaultConfig
+		}
+		limiter.SetLimit(rate.Limit(config.RPS))
+		limiter.SetBurst(config.Burst)
+	}
+}
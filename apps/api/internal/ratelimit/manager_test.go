@@ -2,8 +2,10 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -80,5 +82,260 @@ func TestManager_DefaultConfig(t *testing.T) {
 	}
 }
 
+func TestManager_SetConfigs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	manager := NewManager(map[string]RateLimitConfig{"test": {RPS: 1, Burst: 1}}, RateLimitConfig{RPS: 1, Burst: 1}, logger)
+
+	// Creates the limiter with the old config
+	existing := manager.getLimiter("test")
+
+	manager.SetConfigs(map[string]RateLimitConfig{"test": {RPS: 50, Burst: 5}}, RateLimitConfig{RPS: 2, Burst: 3})
+
+	if existing.Limit() != 50 || existing.Burst() != 5 {
+		t.Errorf("existing limiter = %v/%d, want 50/5", existing.Limit(), existing.Burst())
+	}
+	if limiter := manager.getLimiter("other"); limiter.Limit() != 2 || limiter.Burst() != 3 {
+		t.Errorf("new limiter = %v/%d, want default 2/3", limiter.Limit(), limiter.Burst())
+	}
+}
+
+func TestManager_SetConfig(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	configs := map[string]RateLimitConfig{"test": {RPS: 1, Burst: 1}}
+	manager := NewManager(configs, RateLimitConfig{RPS: 1, Burst: 1}, logger)
+	old := manager.getLimiter("test")
+
+	manager.SetConfig("test", RateLimitConfig{RPS: 50, Burst: 5})
+	manager.SetConfig("new", RateLimitConfig{RPS: 20, Burst: 2})
+
+	if limiter := manager.getLimiter("test"); limiter == old || limiter.Limit() != 50 || limiter.Burst() != 5 {
+		t.Errorf("test limiter = %v/%d, want a new 50/5 limiter", limiter.Limit(), limiter.Burst())
+	}
+	if limiter := manager.getLimiter("new"); limiter.Limit() != 20 || limiter.Burst() != 2 {
+		t.Errorf("new limiter = %v/%d, want 20/2", limiter.Limit(), limiter.Burst())
+	}
+	if configs["test"].RPS != 1 || len(configs) != 1 {
+		t.Errorf("SetConfig changed the configs passed to NewManager: %v", configs)
+	}
+}
+
+func TestManager_CollectIdle(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	manager := NewManager(map[string]RateLimitConfig{
+		"busy": {RPS: 0.01, Burst: 1},
+	}, RateLimitConfig{RPS: 100, Burst: 1}, logger)
+	manager.SetHostConfigs(nil, RateLimitConfig{RPS: 100, Burst: 1})
+	ctx := context.Background()
+
+	for _, provider := range []string{"busy", "idle"} {
+		if err := manager.Wait(ctx, provider); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.WaitHostRateLimit(ctx, "shop.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.WaitCrawlDelay(ctx, "shop.example.com", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // refills the 100 RPS buckets
+
+	// busy and the crawl delay limiter are still empty
+	if removed := manager.CollectIdle(); removed != 2 {
+		t.Errorf("CollectIdle() = %d, want 2", removed)
+	}
+	if _, ok := manager.limiters["busy"]; !ok {
+		t.Error("CollectIdle() removed the busy limiter")
+	}
+	if _, ok := manager.limiters["idle"]; ok {
+		t.Error("CollectIdle() kept the idle limiter")
+	}
+
+	// The idle provider keeps its counters
+	stats := manager.Stats()
+	if len(stats) != 2 || stats[1].Provider != "idle" || stats[1].Waits != 1 || stats[1].TokensAvailable != 1 {
+		t.Errorf("Stats() = %+v, want idle with 1 wait and a full bucket", stats)
+	}
+}
+
+func TestManager_WaitCrawlDelay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	manager := NewManager(nil, RateLimitConfig{RPS: 100, Burst: 100}, logger)
+	ctx := context.Background()
+
+	// The first request to a host does not wait, the next one waits for the delay
+	start := time.Now()
+	if err := manager.WaitCrawlDelay(ctx, "shop.example.com", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.WaitCrawlDelay(ctx, "shop.example.com", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request waited %v, want the crawl delay", elapsed)
+	}
+
+	// Other hosts and hosts without a delay are not held up
+	start = time.Now()
+	if err := manager.WaitCrawlDelay(ctx, "other.example.com", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := manager.WaitCrawlDelay(ctx, "fast.example.com", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Millisecond {
+		t.Errorf("requests to other hosts waited %v", elapsed)
+	}
+
+	// A delay longer than the deadline fails instead of waiting
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	manager.WaitCrawlDelay(ctx, "slow.example.com", time.Hour)
+	if err := manager.WaitCrawlDelay(ctx, "slow.example.com", time.Hour); err == nil {
+		t.Error("WaitCrawlDelay() with a delay past the deadline error = nil")
+	}
+}
+
+// fakeHostBuckets hands out the reservations of a shared store, or fails while err is set
+type fakeHostBuckets struct {
+	mu       sync.Mutex
+	reserved map[string]int
+	err      error
+}
 
+func (b *fakeHostBuckets) Reserve(ctx context.Context, host string, config RateLimitConfig) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.reserved[host]++
+	return 0, nil
+}
+
+func TestManager_WaitHostRateLimit(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	manager := NewManager(nil, RateLimitConfig{RPS: 100, Burst: 100}, logger)
+	manager.SetHostConfigs(map[string]RateLimitConfig{"Slow.example.com": {RPS: 20, Burst: 1}}, RateLimitConfig{})
+	ctx := context.Background()
+
+	// Hosts without a limit (the default RPS is 0) are not held up
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := manager.WaitHostRateLimit(ctx, "fast.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Millisecond {
+		t.Errorf("requests to an unlimited host waited %v", elapsed)
+	}
+
+	// The second request to a limited host waits for its next token
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		if err := manager.WaitHostRateLimit(ctx, "slow.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request waited %v, want the host's interval", elapsed)
+	}
+
+	// A default limit applies to every other host
+	manager.SetHostConfigs(nil, RateLimitConfig{RPS: 20, Burst: 1})
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		if err := manager.WaitHostRateLimit(ctx, "fast.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request waited %v, want the default interval", elapsed)
+	}
+
+	// Shared buckets take the reservations, and the local limiters take over while they
+	// fail
+	buckets := &fakeHostBuckets{reserved: make(map[string]int)}
+	manager.SetHostBuckets(buckets)
+	if err := manager.WaitHostRateLimit(ctx, "shared.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if buckets.reserved["shared.example.com"] != 1 {
+		t.Errorf("shared reservations = %v, want one for shared.example.com", buckets.reserved)
+	}
+	buckets.err = errors.New("connection refused")
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		if err := manager.WaitHostRateLimit(ctx, "shared.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request without the shared buckets waited %v, want the local interval", elapsed)
+	}
+}
 
+func TestManager_MaxWait(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	manager := NewManager(map[string]RateLimitConfig{
+		"slow": {RPS: 0.01, Burst: 1}, // next token after 100s
+	}, RateLimitConfig{RPS: 1.0, Burst: 1}, logger)
+	manager.SetMaxWait(time.Second)
+
+	ctx := context.Background()
+	if err := manager.Wait(ctx, "slow"); err != nil {
+		t.Fatalf("Wait() error = %v, want nil", err)
+	}
+	start := time.Now()
+	err := manager.Wait(ctx, "slow")
+	if !errors.Is(err, ErrRateBudgetExceeded) {
+		t.Fatalf("Wait() error = %v, want ErrRateBudgetExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Wait() over budget blocked for %v", elapsed)
+	}
+
+	// A wait longer than the context's deadline fails at once as well
+	manager.SetMaxWait(0)
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	start = time.Now()
+	if err := manager.Wait(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Wait() past the deadline blocked for %v", elapsed)
+	}
+}
+
+func TestManager_Stats(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	manager := NewManager(map[string]RateLimitConfig{
+		"fast": {RPS: 20.0, Burst: 1},
+		"slow": {RPS: 0.01, Burst: 1},
+	}, RateLimitConfig{RPS: 1.0, Burst: 1}, logger)
+	manager.SetMaxWait(time.Second)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := manager.Wait(ctx, "fast"); err != nil {
+			t.Fatalf("Wait() error = %v, want nil", err)
+		}
+	}
+	manager.Wait(ctx, "slow")
+	manager.Wait(ctx, "slow")
+
+	stats := manager.Stats()
+	if len(stats) != 2 || stats[0].Provider != "fast" || stats[1].Provider != "slow" {
+		t.Fatalf("Stats() = %+v, want fast and slow", stats)
+	}
+	fast, slow := stats[0], stats[1]
+	if fast.Waits != 2 || fast.WaitTime < 25*time.Millisecond || fast.BudgetExceeded != 0 {
+		t.Errorf("fast stats = %+v, want 2 waits, one of ~50ms", fast)
+	}
+	if slow.Waits != 1 || slow.BudgetExceeded != 1 || slow.TokensAvailable >= 0.5 {
+		t.Errorf("slow stats = %+v, want 1 wait, 1 over budget and no token", slow)
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHostBuckets implements HostBuckets in Redis as GCRA buckets: each host's key holds
+// the time its next token is free, advanced by one interval per reservation. The script
+// reads the Redis clock, so instances with skewed clocks share one timeline.
+type RedisHostBuckets struct {
+	client *redis.Client
+}
+
+func NewRedisHostBuckets(client *redis.Client) *RedisHostBuckets {
+	return &RedisHostBuckets{client: client}
+}
+
+// redisReserveScript reserves the next token of KEYS[1] for an interval of ARGV[1]
+// milliseconds and a burst tolerance of ARGV[2] milliseconds, and returns the
+// milliseconds until it may be used. Times are milliseconds, which Lua numbers hold
+// exactly.
+var redisReserveScript = redis.NewScript(`
+local now = redis.call("TIME")
+now = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local tat = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tat < now then
+	tat = now
+end
+local wait = tat - tolerance - now
+if wait < 0 then
+	wait = 0
+end
+tat = tat + interval
+redis.call("SET", KEYS[1], string.format("%d", tat), "PX", tat - now + 1000)
+return wait
+`)
+
+func (b *RedisHostBuckets) Reserve(ctx context.Context, host string, config RateLimitConfig) (time.Duration, error) {
+	interval := max(time.Duration(float64(time.Second)/config.RPS).Milliseconds(), 1)
+	burst := int64(max(config.Burst, 1))
+	wait, err := redisReserveScript.Run(ctx, b.client, []string{"ratelimit:host:" + host},
+		interval, (burst-1)*interval).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisHostBuckets(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newBuckets := func() *RedisHostBuckets {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisHostBuckets(client)
+	}
+	first, second := newBuckets(), newBuckets()
+	config := RateLimitConfig{RPS: 10, Burst: 2}
+	ctx := context.Background()
+
+	// The burst is free, then each reservation waits one more interval. Both clients
+	// share the bucket, as instances do.
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, wantWait := range want {
+		buckets := first
+		if i%2 == 1 {
+			buckets = second
+		}
+		wait, err := buckets.Reserve(ctx, "shop.example.com", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != wantWait {
+			t.Errorf("reservation %d waits %v, want %v", i, wait, wantWait)
+		}
+	}
+
+	// Other hosts have their own buckets
+	if wait, err := first.Reserve(ctx, "other.example.com", config); err != nil || wait != 0 {
+		t.Errorf("Reserve() of another host = %v, %v, want 0", wait, err)
+	}
+
+	// Tokens refill over time
+	server.SetTime(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC))
+	if wait, err := first.Reserve(ctx, "shop.example.com", config); err != nil || wait != 0 {
+		t.Errorf("Reserve() after a second = %v, %v, want 0", wait, err)
+	}
+
+	// The key expires once the bucket is full again
+	if ttl := server.TTL("ratelimit:host:shop.example.com"); ttl <= 0 || ttl > 2*time.Second {
+		t.Errorf("bucket key TTL = %v, want about the time until the bucket refills", ttl)
+	}
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrRateBudgetExceeded is returned by Wait when the next token of a provider is further
+// away than the wait budget, which means its limit is set far below the requests made
+var ErrRateBudgetExceeded = errors.New("rate limit wait exceeds budget")
+
+// LimiterStats are the state and counters of a provider's rate limiter since startup
+type LimiterStats struct {
+	Provider        string
+	TokensAvailable float64 // negative while requests wait for tokens
+	Waits           int64   // tokens taken by Wait
+	WaitTime        time.Duration
+	BudgetExceeded  int64 // Wait calls refused with ErrRateBudgetExceeded
+}
+
+type limiterStats struct {
+	waits          int64
+	waitTime       time.Duration
+	budgetExceeded int64
+}
+
+// SetMaxWait sets the longest Wait waits for a token before returning
+// ErrRateBudgetExceeded; 0 waits as long as the context allows
+func (m *Manager) SetMaxWait(maxWait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxWait = maxWait
+}
+
+// Stats returns the stats of each provider's limiter, sorted by provider. Providers whose
+// idle limiter was removed (see CollectIdle) keep their counters and a full bucket.
+func (m *Manager) Stats() []LimiterStats {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	m.mu.RLock()
+	limiters := make(map[string]float64, len(m.limiters))
+	for providerKey, limiter := range m.limiters {
+		limiters[providerKey] = limiter.Tokens()
+	}
+	for providerKey := range m.stats {
+		if _, ok := limiters[providerKey]; !ok {
+			config, ok := m.configs[providerKey]
+			if !ok {
+				config = m.defaultConfig
+			}
+			limiters[providerKey] = float64(config.Burst)
+		}
+	}
+	m.mu.RUnlock()
+
+	stats := make([]LimiterStats, 0, len(limiters))
+	for providerKey, tokens := range limiters {
+		s := LimiterStats{Provider: providerKey, TokensAvailable: tokens}
+		if counters, ok := m.stats[providerKey]; ok {
+			s.Waits = counters.waits
+			s.WaitTime = counters.waitTime
+			s.BudgetExceeded = counters.budgetExceeded
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+func (m *Manager) recordWait(providerKey string, wait time.Duration) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	counters := m.countersLocked(providerKey)
+	counters.waits++
+	counters.waitTime += wait
+}
+
+func (m *Manager) recordBudgetExceeded(providerKey string) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	m.countersLocked(providerKey).budgetExceeded++
+}
+
+// countersLocked gets or creates the counters of a provider. m.statsMu must be held.
+func (m *Manager) countersLocked(providerKey string) *limiterStats {
+	counters, ok := m.stats[providerKey]
+	if !ok {
+		counters = &limiterStats{}
+		m.stats[providerKey] = counters
+	}
+	return counters
+}
@@ -0,0 +1,180 @@
+// Package render loads pages in a headless browser, for sites that render their prices
+// client-side so that the HTML the server sends has none. The browser runs remotely
+// (browserless' /content API) and returns the page's HTML after its scripts ran, which
+// the providers parse like a fetched page.
+//
+// A page is only rendered after the checks of a direct fetch passed (ALLOW_LIVE_FETCH,
+// crawl windows, robots.txt and the provider's rate limit), and every render is audited
+// like a request. The checks cover the page URL; the scripts and API calls the page
+// makes itself are loaded by the browser.
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pricecompare/api/internal/audit"
+)
+
+// ErrRender is returned when the browser could not render a page
+var ErrRender = errors.New("render failed")
+
+// Renderer returns the HTML of a page after its scripts ran, as a response of the page:
+// its StatusCode is the page's status and its Body the rendered HTML
+type Renderer interface {
+	Render(ctx context.Context, providerKey, pageURL string, header http.Header) (*http.Response, error)
+}
+
+// Checker runs the compliance checks of a page fetch without fetching the page;
+// *httpclient.Client implements it
+type Checker interface {
+	Preflight(ctx context.Context, providerKey, targetURL string) error
+	UserAgent(providerKey string) string
+}
+
+// Config configures the browserless endpoint
+type Config struct {
+	URL          string        // browserless base URL, e.g. http://browserless:3000
+	Token        string        // optional API token
+	Timeout      time.Duration // page load deadline in the browser
+	WaitSelector string        // optional CSS selector to wait for, e.g. the price element
+}
+
+// Browserless renders pages through browserless' /content API
+type Browserless struct {
+	cfg        Config
+	checker    Checker
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+func NewBrowserless(cfg Config, checker Checker, logger *slog.Logger) *Browserless {
+	return &Browserless{
+		cfg:     cfg,
+		checker: checker,
+		// The browser has cfg.Timeout for the page; leave it time to answer
+		httpClient: &http.Client{Timeout: cfg.Timeout + 10*time.Second},
+		logger:     logger,
+	}
+}
+
+// contentRequest is the body of a /content request
+type contentRequest struct {
+	URL                 string            `json:"url"`
+	UserAgent           string            `json:"userAgent,omitempty"`
+	SetExtraHTTPHeaders map[string]string `json:"setExtraHTTPHeaders,omitempty"`
+	GotoOptions         gotoOptions       `json:"gotoOptions"`
+	WaitForSelector     *waitForSelector  `json:"waitForSelector,omitempty"`
+}
+
+type gotoOptions struct {
+	WaitUntil string `json:"waitUntil"`
+	Timeout   int64  `json:"timeout"` // milliseconds
+}
+
+type waitForSelector struct {
+	Selector string `json:"selector"`
+	Timeout  int64  `json:"timeout"` // milliseconds
+}
+
+// Render checks pageURL like a fetch and has the browser load it. header (e.g.
+// Accept-Language) is sent with the page request.
+func (b *Browserless) Render(ctx context.Context, providerKey, pageURL string, header http.Header) (*http.Response, error) {
+	startTime := time.Now()
+	userAgent := b.checker.UserAgent(providerKey)
+	if err := b.checker.Preflight(ctx, providerKey, pageURL); err != nil {
+		b.audit(startTime, providerKey, pageURL, userAgent, false, 0, err)
+		return nil, err
+	}
+
+	body := contentRequest{
+		URL:         pageURL,
+		UserAgent:   userAgent,
+		GotoOptions: gotoOptions{WaitUntil: "networkidle2", Timeout: b.cfg.Timeout.Milliseconds()},
+	}
+	if len(header) > 0 {
+		body.SetExtraHTTPHeaders = make(map[string]string, len(header))
+		for name := range header {
+			body.SetExtraHTTPHeaders[name] = header.Get(name)
+		}
+	}
+	if b.cfg.WaitSelector != "" {
+		body.WaitForSelector = &waitForSelector{Selector: b.cfg.WaitSelector, Timeout: b.cfg.Timeout.Milliseconds()}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimSuffix(b.cfg.URL, "/") + "/content"
+	requestURL := endpoint
+	if b.cfg.Token != "" {
+		requestURL += "?token=" + url.QueryEscape(b.cfg.Token)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		// The token is not quoted in the error, which is logged and audited
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = endpoint
+		}
+		err = fmt.Errorf("%w: %w", ErrRender, err)
+		b.audit(startTime, providerKey, pageURL, userAgent, true, 0, err)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		err := fmt.Errorf("%w: browserless returned status %d: %s", ErrRender, resp.StatusCode, strings.TrimSpace(string(message)))
+		b.audit(startTime, providerKey, pageURL, userAgent, true, 0, err)
+		return nil, err
+	}
+
+	// browserless reports the status of the page itself in X-Response-Code
+	if code, err := strconv.Atoi(resp.Header.Get("X-Response-Code")); err == nil && code > 0 {
+		resp.StatusCode = code
+		resp.Status = strconv.Itoa(code) + " " + http.StatusText(code)
+	}
+	b.audit(startTime, providerKey, pageURL, userAgent, true, resp.StatusCode, nil)
+	return resp, nil
+}
+
+// audit logs a render like httpclient logs a request; robotsAllowed is whether the
+// checks passed
+func (b *Browserless) audit(startTime time.Time, providerKey, pageURL, userAgent string, robotsAllowed bool, status int, err error) {
+	entry := audit.Entry{
+		Timestamp:     startTime,
+		Provider:      providerKey,
+		Method:        "RENDER",
+		URL:           pageURL,
+		Status:        status,
+		DurationMs:    time.Since(startTime).Milliseconds(),
+		UserAgent:     userAgent,
+		RobotsAllowed: robotsAllowed,
+	}
+	if parsed, parseErr := url.Parse(pageURL); parseErr == nil {
+		entry.Host = parsed.Host
+		entry.Path = parsed.Path
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.ContentType = "text/html"
+	}
+	audit.LogRequest(b.logger, entry)
+}
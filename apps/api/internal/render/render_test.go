@@ -0,0 +1,115 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeChecker struct {
+	err     error
+	checked []string
+}
+
+func (c *fakeChecker) Preflight(ctx context.Context, providerKey, targetURL string) error {
+	c.checked = append(c.checked, targetURL)
+	return c.err
+}
+
+func (c *fakeChecker) UserAgent(providerKey string) string {
+	return "TestBot/1.0"
+}
+
+func TestBrowserless_Render(t *testing.T) {
+	var got contentRequest
+	var gotToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/content" {
+			t.Errorf("path = %s, want /content", r.URL.Path)
+		}
+		gotToken = r.URL.Query().Get("token")
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.URL == "https://shop.example.com/missing" {
+			w.Header().Set("X-Response-Code", "404")
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><span class="price">$19.99</span></html>`))
+	}))
+	defer server.Close()
+
+	checker := &fakeChecker{}
+	renderer := NewBrowserless(Config{URL: server.URL + "/", Token: "secret", Timeout: 5 * time.Second, WaitSelector: ".price"},
+		checker, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	header := http.Header{"Accept-Language": []string{"ja-JP"}}
+
+	resp, err := renderer.Render(context.Background(), "live", "https://shop.example.com/item/1", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `<html><span class="price">$19.99</span></html>` {
+		t.Errorf("Render = %d %s", resp.StatusCode, body)
+	}
+	if got.URL != "https://shop.example.com/item/1" || got.UserAgent != "TestBot/1.0" || gotToken != "secret" ||
+		got.SetExtraHTTPHeaders["Accept-Language"] != "ja-JP" || got.GotoOptions.Timeout != 5000 ||
+		got.WaitForSelector == nil || got.WaitForSelector.Selector != ".price" {
+		t.Errorf("content request = %+v, token %q", got, gotToken)
+	}
+
+	// The status of the page itself is reported, not the browserless one
+	resp, err = renderer.Render(context.Background(), "live", "https://shop.example.com/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Render of a missing page status = %d, want 404", resp.StatusCode)
+	}
+
+	// A page failing the checks is not rendered
+	checker.err = errors.New("robots.txt disallows access")
+	got = contentRequest{}
+	if _, err := renderer.Render(context.Background(), "live", "https://shop.example.com/private", nil); !errors.Is(err, checker.err) {
+		t.Errorf("Render of a disallowed page error = %v", err)
+	}
+	if got.URL != "" {
+		t.Errorf("disallowed page was rendered: %+v", got)
+	}
+	if len(checker.checked) != 3 {
+		t.Errorf("checked %v, want every page", checker.checked)
+	}
+}
+
+func TestBrowserless_RenderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "timeout waiting for selector", http.StatusRequestTimeout)
+	}))
+	defer server.Close()
+
+	renderer := NewBrowserless(Config{URL: server.URL, Timeout: time.Second}, &fakeChecker{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := renderer.Render(context.Background(), "live", "https://shop.example.com/item/1", nil); !errors.Is(err, ErrRender) {
+		t.Errorf("Render error = %v, want ErrRender", err)
+	}
+}
+
+func TestBrowserless_RenderErrorHidesToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // refuse connections
+
+	renderer := NewBrowserless(Config{URL: server.URL, Token: "secret-token", Timeout: time.Second}, &fakeChecker{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := renderer.Render(context.Background(), "live", "https://shop.example.com/item/1", nil)
+	if !errors.Is(err, ErrRender) {
+		t.Fatalf("Render error = %v, want ErrRender", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Render error = %q, contains the token", err)
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/models"
+)
+
+const apiKeyColumns = `
+	id, name, prefix, key_hash, role, rate_limit_per_minute, last_used_at, revoked_at, created_at
+`
+
+type APIKeyRepository struct {
+	db *DB
+}
+
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Role,
+		&key.RateLimitPerMinute,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Create stores a new key and sets its ID and creation time
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO api_keys (id, name, prefix, key_hash, role, rate_limit_per_minute, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		key.ID, key.Name, key.Prefix, key.KeyHash, key.Role, key.RateLimitPerMinute, key.CreatedAt,
+	)
+	return err
+}
+
+// GetByHash returns the key with the hash, revoked or not, or nil if there is none
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// List returns all keys, oldest first
+func (r *APIKeyRepository) List(ctx context.Context) ([]*models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables a key. It returns sql.ErrNoRows if the key does not exist or is
+// already revoked.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
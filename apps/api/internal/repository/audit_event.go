@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pricecompare/api/internal/audit"
+)
+
+type AuditEventRepository struct {
+	db *DB
+}
+
+func NewAuditEventRepository(db *DB) *AuditEventRepository {
+	return &AuditEventRepository{db: db}
+}
+
+// WriteBatch inserts a batch of audit events in a single transaction. It implements
+// audit.BatchWriter for AUDIT_SINK=postgres.
+func (r *AuditEventRepository) WriteBatch(ctx context.Context, events []audit.Event) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO audit_events (type, logged_at, payload) VALUES ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, event.Type, event.Time, payload); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
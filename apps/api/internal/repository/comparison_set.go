@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pricecompare/api/internal/models"
+)
+
+const comparisonSetColumns = `
+	id, owner_key_id, name, product_ids, share_token, created_at, updated_at
+`
+
+type ComparisonSetRepository struct {
+	db *DB
+}
+
+func NewComparisonSetRepository(db *DB) *ComparisonSetRepository {
+	return &ComparisonSetRepository{db: db}
+}
+
+func scanComparisonSet(row rowScanner) (*models.ComparisonSet, error) {
+	var set models.ComparisonSet
+	var productIDs []string
+	if err := row.Scan(
+		&set.ID,
+		&set.OwnerKeyID,
+		&set.Name,
+		pq.Array(&productIDs),
+		&set.ShareToken,
+		&set.CreatedAt,
+		&set.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	set.ProductIDs = make([]uuid.UUID, 0, len(productIDs))
+	for _, value := range productIDs {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		set.ProductIDs = append(set.ProductIDs, id)
+	}
+	return &set, nil
+}
+
+// Create stores a new set and sets its ID and timestamps
+func (r *ComparisonSetRepository) Create(ctx context.Context, set *models.ComparisonSet) error {
+	set.ID = uuid.New()
+	set.CreatedAt = time.Now()
+	set.UpdatedAt = set.CreatedAt
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO comparison_sets (id, owner_key_id, name, product_ids, share_token, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		set.ID, set.OwnerKeyID, set.Name, pq.Array(uuidStrings(set.ProductIDs)), set.ShareToken, set.CreatedAt, set.UpdatedAt,
+	)
+	return err
+}
+
+func (r *ComparisonSetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ComparisonSet, error) {
+	return r.getOne(ctx, `SELECT `+comparisonSetColumns+` FROM comparison_sets WHERE id = $1`, id)
+}
+
+func (r *ComparisonSetRepository) GetByShareToken(ctx context.Context, token string) (*models.ComparisonSet, error) {
+	return r.getOne(ctx, `SELECT `+comparisonSetColumns+` FROM comparison_sets WHERE share_token = $1`, token)
+}
+
+func (r *ComparisonSetRepository) getOne(ctx context.Context, query string, arg any) (*models.ComparisonSet, error) {
+	set, err := scanComparisonSet(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return set, err
+}
+
+// ListByOwner returns the sets of an API key, oldest first
+func (r *ComparisonSetRepository) ListByOwner(ctx context.Context, ownerKeyID uuid.UUID) ([]*models.ComparisonSet, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+comparisonSetColumns+` FROM comparison_sets WHERE owner_key_id = $1 ORDER BY created_at, id`, ownerKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []*models.ComparisonSet{}
+	for rows.Next() {
+		set, err := scanComparisonSet(rows)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, rows.Err()
+}
+
+// Delete deletes a set of ownerKeyID. It returns sql.ErrNoRows if the key has no such
+// set.
+func (r *ComparisonSetRepository) Delete(ctx context.Context, id, ownerKeyID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM comparison_sets WHERE id = $1 AND owner_key_id = $2`, id, ownerKeyID)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pricecompare/api/internal/shipping"
+)
+
+type FeeRuleRepository struct {
+	db *DB
+}
+
+func NewFeeRuleRepository(db *DB) *FeeRuleRepository {
+	return &FeeRuleRepository{db: db}
+}
+
+// ListEnabled returns all enabled fee rules ordered by priority
+func (r *FeeRuleRepository) ListEnabled(ctx context.Context) ([]shipping.FeeRule, error) {
+	query := `
+		SELECT name, source, category, min_price_amount, max_price_amount,
+		       percent, fixed_amount, priority
+		FROM fee_rules
+		WHERE enabled = TRUE
+		ORDER BY priority ASC, created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []shipping.FeeRule{}
+	for rows.Next() {
+		var rule shipping.FeeRule
+		var source, category sql.NullString
+		var minPrice, maxPrice sql.NullInt64
+		if err := rows.Scan(
+			&rule.Name,
+			&source,
+			&category,
+			&minPrice,
+			&maxPrice,
+			&rule.Percent,
+			&rule.FixedCents,
+			&rule.Priority,
+		); err != nil {
+			return nil, err
+		}
+		rule.Source = source.String
+		rule.Category = category.String
+		if minPrice.Valid {
+			v := int(minPrice.Int64)
+			rule.MinPriceCents = &v
+		}
+		if maxPrice.Valid {
+			v := int(maxPrice.Int64)
+			rule.MaxPriceCents = &v
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
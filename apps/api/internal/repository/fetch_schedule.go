@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pricecompare/api/internal/models"
+)
+
+const fetchScheduleColumns = `id, source, cron, origin, paused, created_at, updated_at`
+
+type FetchScheduleRepository struct {
+	db *DB
+}
+
+func NewFetchScheduleRepository(db *DB) *FetchScheduleRepository {
+	return &FetchScheduleRepository{db: db}
+}
+
+func scanFetchSchedule(row rowScanner) (*models.FetchSchedule, error) {
+	var schedule models.FetchSchedule
+	if err := row.Scan(
+		&schedule.ID,
+		&schedule.Source,
+		&schedule.Cron,
+		&schedule.Origin,
+		&schedule.Paused,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// Create stores a new schedule and sets its ID, origin (api) and timestamps
+func (r *FetchScheduleRepository) Create(ctx context.Context, schedule *models.FetchSchedule) error {
+	schedule.ID = uuid.New()
+	schedule.Origin = models.ScheduleOriginAPI
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = schedule.CreatedAt
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO fetch_schedules (id, source, cron, origin, paused, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		schedule.ID, schedule.Source, schedule.Cron, schedule.Origin, schedule.Paused, schedule.CreatedAt, schedule.UpdatedAt,
+	)
+	return err
+}
+
+func (r *FetchScheduleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.FetchSchedule, error) {
+	schedule, err := scanFetchSchedule(r.db.QueryRowContext(ctx,
+		`SELECT `+fetchScheduleColumns+` FROM fetch_schedules WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return schedule, err
+}
+
+// List returns all schedules, oldest first
+func (r *FetchScheduleRepository) List(ctx context.Context) ([]*models.FetchSchedule, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+fetchScheduleColumns+` FROM fetch_schedules ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*models.FetchSchedule{}
+	for rows.Next() {
+		schedule, err := scanFetchSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *FetchScheduleRepository) SetPaused(ctx context.Context, id uuid.UUID, paused bool) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE fetch_schedules SET paused = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		id, paused,
+	)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *FetchScheduleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM fetch_schedules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+// ReplaceConfigured makes the env schedules match crons (source -> cron spec): new
+// sources are added, changed specs updated and missing sources removed. Whether a
+// schedule is paused is kept across restarts.
+func (r *FetchScheduleRepository) ReplaceConfigured(ctx context.Context, crons map[string]string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sources := make([]string, 0, len(crons))
+	for source, spec := range crons {
+		sources = append(sources, source)
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO fetch_schedules (id, source, cron, origin)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (source) WHERE origin = 'env'
+			DO UPDATE SET cron = EXCLUDED.cron, updated_at = CURRENT_TIMESTAMP
+			WHERE fetch_schedules.cron <> EXCLUDED.cron`,
+			uuid.New(), source, spec, models.ScheduleOriginEnv,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM fetch_schedules WHERE origin = $1 AND NOT (source = ANY($2::text[]))`,
+		models.ScheduleOriginEnv, pq.Array(sources),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// Store interfaces are implemented by the Postgres repositories in this package and by
+// the in-memory repositories in repository/memory (REPOSITORY_BACKEND=memory), so the
+// API and its handlers can run without a database. Errors and not-found results follow
+// the Postgres implementations: getters return nil, nil for a missing row and mutations
+// of a missing row return sql.ErrNoRows.
+
+type ProductStore interface {
+	Create(ctx context.Context, product *models.Product) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Product, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Product, error)
+	CountCreatedSince(ctx context.Context, since time.Time) (int, error)
+	Search(ctx context.Context, query, tag string, limit, offset int) ([]*models.Product, int, error)
+	SearchWithMinPrice(ctx context.Context, query string, filter ProductSearchFilter, limit, offset int) ([]*ProductSearchResult, int, error)
+	SearchFacets(ctx context.Context, query string, filter ProductSearchFilter) (map[string]map[string]int, error)
+	FindByTitle(ctx context.Context, title string) (*models.Product, error)
+	FindSimilarByTitle(ctx context.Context, title string, threshold float64, limit int) ([]*ProductSimilarity, error)
+	Update(ctx context.Context, product *models.Product) error
+	GetSummaries(ctx context.Context, ids []uuid.UUID) ([]*ProductSummary, error)
+	ListSummariesAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]*ProductSummary, error)
+}
+
+type ProductRevisionStore interface {
+	Apply(ctx context.Context, product *models.Product, revision *models.ProductRevision) error
+	ListByProductID(ctx context.Context, productID uuid.UUID, limit int) ([]*models.ProductRevision, error)
+	EditedFields(ctx context.Context, productID uuid.UUID) (map[string]bool, error)
+}
+
+type ProductTagStore interface {
+	Add(ctx context.Context, productIDs []uuid.UUID, tags []string) (int64, error)
+	Remove(ctx context.Context, productIDs []uuid.UUID, tags []string) (int64, error)
+	ListByProductID(ctx context.Context, productID uuid.UUID) ([]string, error)
+	ListTags(ctx context.Context) ([]*models.TagCount, error)
+}
+
+type OfferStore interface {
+	Create(ctx context.Context, offer *models.Offer) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Offer, error)
+	Update(ctx context.Context, offer *models.Offer) error
+	GetByProductID(ctx context.Context, productID uuid.UUID) ([]*models.Offer, error)
+	GetByProductIDWithSort(ctx context.Context, productID uuid.UUID, sortKey string) ([]*models.Offer, error)
+	Upsert(ctx context.Context, offer *models.Offer) error
+	GetByProductIDAndSource(ctx context.Context, productID uuid.UUID, source string) ([]*models.Offer, error)
+	MarkDelisted(ctx context.Context, productID uuid.UUID, source string, seenBefore time.Time) (int64, error)
+	GetPageByProductID(ctx context.Context, productID uuid.UUID, includeDelisted bool, limit, offset int) ([]*models.Offer, int, error)
+	CountFetchedBefore(ctx context.Context, before time.Time) (map[string]int, error)
+	CountListed(ctx context.Context) (map[string]int, error)
+	ListQuarantined(ctx context.Context, limit int) ([]*models.Offer, error)
+	PriceHistoryMedian(ctx context.Context, productID uuid.UUID, since time.Time) (int, int, error)
+	DeleteExpired(ctx context.Context, source string, before time.Time) ([]uuid.UUID, error)
+	GetCheapestBySource(ctx context.Context, productIDs []uuid.UUID) ([]*models.Offer, error)
+}
+
+type OfferPriceChangeStore interface {
+	Record(ctx context.Context, change *models.OfferPriceChange) error
+	CountSince(ctx context.Context, since time.Time, minPercent float64) (int, error)
+	ListLargestSince(ctx context.Context, since time.Time, minPercent float64, limit int) ([]*models.OfferPriceChange, error)
+	ListDropsSince(ctx context.Context, since time.Time, minDropPercent float64, limit int) ([]*models.PriceDrop, error)
+}
+
+type StockEventStore interface {
+	Record(ctx context.Context, event *models.StockEvent) error
+	ListByProductID(ctx context.Context, productID uuid.UUID, since time.Time, limit int) ([]*models.StockEvent, error)
+}
+
+type OfferMergeLogStore interface {
+	Record(ctx context.Context, merge *models.OfferMerge) (bool, error)
+	ListByProductID(ctx context.Context, productID uuid.UUID, limit int) ([]*models.OfferMerge, error)
+}
+
+type PriceAlertStore interface {
+	Create(ctx context.Context, alert *models.PriceAlert) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.PriceAlert, error)
+	Confirm(ctx context.Context, token string, at time.Time) (*models.PriceAlert, error)
+	List(ctx context.Context) ([]*models.PriceAlert, error)
+	MarkTriggered(ctx context.Context, id uuid.UUID, at *time.Time, cents *int) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type APIKeyStore interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	List(ctx context.Context) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID, at time.Time) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+type ComparisonSetStore interface {
+	Create(ctx context.Context, set *models.ComparisonSet) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.ComparisonSet, error)
+	GetByShareToken(ctx context.Context, token string) (*models.ComparisonSet, error)
+	ListByOwner(ctx context.Context, ownerKeyID uuid.UUID) ([]*models.ComparisonSet, error)
+	Delete(ctx context.Context, id, ownerKeyID uuid.UUID) error
+}
+
+type OfferShippingOptionStore interface {
+	ReplaceForOffer(ctx context.Context, offerID uuid.UUID, options []*models.OfferShippingOption) error
+	GetByOfferIDs(ctx context.Context, offerIDs []uuid.UUID) (map[uuid.UUID][]*models.OfferShippingOption, error)
+}
+
+type ProductIdentifierStore interface {
+	FindByTypeAndValue(ctx context.Context, idType, value string) (*models.ProductIdentifier, *models.Product, error)
+	Create(ctx context.Context, ident *models.ProductIdentifier) error
+	ListByProductIDs(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]*models.ProductIdentifier, error)
+}
+
+type SourceProductStore interface {
+	FindByProviderAndSourceID(ctx context.Context, provider, sourceID string) (*models.SourceProduct, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.SourceProduct, error)
+	Upsert(ctx context.Context, sp *models.SourceProduct) error
+	ListLowConfidence(ctx context.Context, maxConfidence float64, limit int) ([]*models.SourceProduct, error)
+	ListByProductID(ctx context.Context, productID uuid.UUID) ([]*models.SourceProduct, error)
+	Relink(ctx context.Context, id, productID uuid.UUID) error
+	DeleteOrphaned(ctx context.Context, before time.Time) (int64, error)
+	ListStaleRaw(ctx context.Context, provider string, schemaVersion int, afterID uuid.UUID, limit int) ([]*models.SourceProduct, error)
+	UpdateParsed(ctx context.Context, sp *models.SourceProduct) error
+	CountByProvider(ctx context.Context, provider string, since time.Time) (int, error)
+}
+
+type MergeCandidateStore interface {
+	FindIdentifierDuplicates(ctx context.Context) ([]*models.MergeCandidate, error)
+	FindTitleDuplicates(ctx context.Context, threshold float64, limit int) ([]*models.MergeCandidate, error)
+	UpsertPending(ctx context.Context, candidate *models.MergeCandidate) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.MergeCandidate, error)
+	ListByStatus(ctx context.Context, status string, limit int) ([]*models.MergeCandidate, error)
+	Dismiss(ctx context.Context, id uuid.UUID) error
+	Merge(ctx context.Context, id uuid.UUID) error
+}
+
+type ProductImageStore interface {
+	FindHashByURL(ctx context.Context, imageURL string) (hashes imagehash.Hashes, ok bool, err error)
+	Upsert(ctx context.Context, productID uuid.UUID, imageURL string, hashes imagehash.Hashes) error
+	FindNearest(ctx context.Context, hashes imagehash.Hashes, maxDistance, limit int) ([]*ProductImageMatch, error)
+	ListUnhashed(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.Product, error)
+}
+
+type ProductEmbeddingStore interface {
+	Upsert(ctx context.Context, productID uuid.UUID, model string, vector []float32) error
+	FindNearest(ctx context.Context, model string, vector []float32, limit int) ([]*ProductSimilarity, error)
+	ListUnembedded(ctx context.Context, model string, afterID uuid.UUID, limit int) ([]*models.Product, error)
+}
+
+type ProviderFetchStore interface {
+	Record(ctx context.Context, provider, operation string, duration time.Duration, resultCount int, callErr error) error
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	Stats(ctx context.Context, since time.Time) ([]*models.ProviderStats, error)
+	LastSuccessAt(ctx context.Context) (map[string]time.Time, error)
+}
+
+type FetchScheduleStore interface {
+	Create(ctx context.Context, schedule *models.FetchSchedule) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.FetchSchedule, error)
+	List(ctx context.Context) ([]*models.FetchSchedule, error)
+	SetPaused(ctx context.Context, id uuid.UUID, paused bool) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ReplaceConfigured(ctx context.Context, crons map[string]string) error
+}
+
+type SearchQueryStore interface {
+	Create(ctx context.Context, query *models.SearchQuery) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.SearchQuery, error)
+	List(ctx context.Context, provider string) ([]*models.SearchQuery, error)
+	Update(ctx context.Context, query *models.SearchQuery) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type JobRunStore interface {
+	Start(ctx context.Context, run *models.JobRun) error
+	Finish(ctx context.Context, run *models.JobRun) error
+	GetByID(ctx context.Context, id string) (*models.JobRun, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MaintenanceStore is the Postgres-only part of the db_maintenance job
+type MaintenanceStore interface {
+	DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error)
+	DeleteOfferMergesBefore(ctx context.Context, before time.Time) (int64, error)
+	TableStats(ctx context.Context) ([]*models.TableStats, error)
+	Analyze(ctx context.Context, table string) error
+}
+
+var (
+	_ ProductStore             = (*ProductRepository)(nil)
+	_ ProductRevisionStore     = (*ProductRevisionRepository)(nil)
+	_ ProductTagStore          = (*ProductTagRepository)(nil)
+	_ OfferStore               = (*OfferRepository)(nil)
+	_ OfferPriceChangeStore    = (*OfferPriceChangeRepository)(nil)
+	_ StockEventStore          = (*StockEventRepository)(nil)
+	_ OfferMergeLogStore       = (*OfferMergeLogRepository)(nil)
+	_ OfferShippingOptionStore = (*OfferShippingOptionRepository)(nil)
+	_ ProductIdentifierStore   = (*ProductIdentifierRepository)(nil)
+	_ SourceProductStore       = (*SourceProductRepository)(nil)
+	_ MergeCandidateStore      = (*MergeCandidateRepository)(nil)
+	_ ProductImageStore        = (*ProductImageRepository)(nil)
+	_ ProductEmbeddingStore    = (*ProductEmbeddingRepository)(nil)
+	_ ProviderFetchStore       = (*ProviderFetchRepository)(nil)
+	_ FetchScheduleStore       = (*FetchScheduleRepository)(nil)
+	_ SearchQueryStore         = (*SearchQueryRepository)(nil)
+	_ MaintenanceStore         = (*MaintenanceRepository)(nil)
+	_ APIKeyStore              = (*APIKeyRepository)(nil)
+	_ ComparisonSetStore       = (*ComparisonSetRepository)(nil)
+	_ JobRunStore              = (*JobRunRepository)(nil)
+)
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type JobRunRepository struct {
+	db *DB
+}
+
+func NewJobRunRepository(db *DB) *JobRunRepository {
+	return &JobRunRepository{db: db}
+}
+
+// Start stores a run as running, replacing an earlier attempt of the same task
+func (r *JobRunRepository) Start(ctx context.Context, run *models.JobRun) error {
+	run.Status = models.JobRunStatusRunning
+	run.StartedAt = time.Now()
+	run.FinishedAt = nil
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO job_runs (id, type, source, status, products, started_at, finished_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULL)
+		ON CONFLICT (id) DO UPDATE SET
+			type = EXCLUDED.type, source = EXCLUDED.source, status = EXCLUDED.status,
+			products = EXCLUDED.products, started_at = EXCLUDED.started_at, finished_at = NULL`,
+		run.ID, run.Type, run.Source, run.Status, run.Products, run.StartedAt,
+	)
+	return err
+}
+
+// Finish stores the final status (completed or failed) and products of a run
+func (r *JobRunRepository) Finish(ctx context.Context, run *models.JobRun) error {
+	now := time.Now()
+	run.FinishedAt = &now
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE job_runs SET status = $2, products = $3, finished_at = $4 WHERE id = $1`,
+		run.ID, run.Status, run.Products, run.FinishedAt,
+	)
+	return err
+}
+
+// GetByID returns a run, or nil if there is none with the task ID
+func (r *JobRunRepository) GetByID(ctx context.Context, id string) (*models.JobRun, error) {
+	var run models.JobRun
+	var source sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, type, source, status, products, started_at, finished_at FROM job_runs WHERE id = $1`, id,
+	).Scan(&run.ID, &run.Type, &source, &run.Status, &run.Products, &run.StartedAt, &run.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.Source = source.String
+	return &run, nil
+}
+
+// DeleteBefore deletes runs started before before and returns how many
+func (r *JobRunRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM job_runs WHERE started_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+// MaintenanceRepository runs the Postgres housekeeping of the db_maintenance job
+type MaintenanceRepository struct {
+	db *DB
+}
+
+func NewMaintenanceRepository(db *DB) *MaintenanceRepository {
+	return &MaintenanceRepository{db: db}
+}
+
+// DeleteAuditEventsBefore prunes audit events logged before before
+func (r *MaintenanceRepository) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_events WHERE logged_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteOfferMergesBefore prunes offer merges logged before before
+func (r *MaintenanceRepository) DeleteOfferMergesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM offer_merge_log WHERE merged_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// TableStats returns the row and vacuum statistics of the tables in the current schema
+func (r *MaintenanceRepository) TableStats(ctx context.Context) ([]*models.TableStats, error) {
+	query := `
+		SELECT relname, n_live_tup, n_dead_tup, n_mod_since_analyze,
+		       GREATEST(last_vacuum, last_autovacuum),
+		       GREATEST(last_analyze, last_autoanalyze)
+		FROM pg_stat_user_tables
+		WHERE schemaname = current_schema()
+		ORDER BY relname
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*models.TableStats{}
+	for rows.Next() {
+		s := &models.TableStats{}
+		if err := rows.Scan(&s.Table, &s.LiveRows, &s.DeadRows, &s.ModifiedSinceAnalyze, &s.LastVacuumAt, &s.LastAnalyzeAt); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// Analyze refreshes the planner statistics of a table
+func (r *MaintenanceRepository) Analyze(ctx context.Context, table string) error {
+	_, err := r.db.ExecContext(ctx, `ANALYZE `+pq.QuoteIdentifier(table))
+	return err
+}
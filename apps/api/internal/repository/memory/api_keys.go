@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type apiKeys struct{ s *Store }
+
+func (r apiKeys) Create(ctx context.Context, key *models.APIKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key.ID = uuid.New()
+	key.CreatedAt = r.s.now()
+	r.s.apiKeys[key.ID] = clone(key)
+	return nil
+}
+
+func (r apiKeys) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, key := range r.s.apiKeys {
+		if key.KeyHash == hash {
+			return clone(key), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r apiKeys) List(ctx context.Context) ([]*models.APIKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	keys := make([]*models.APIKey, 0, len(r.s.apiKeys))
+	for _, key := range r.s.apiKeys {
+		keys = append(keys, clone(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID.String() < keys[j].ID.String()
+	})
+	return keys, nil
+}
+
+func (r apiKeys) Revoke(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key, ok := r.s.apiKeys[id]
+	if !ok || key.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	key.RevokedAt = &at
+	return nil
+}
+
+func (r apiKeys) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if key, ok := r.s.apiKeys[id]; ok {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
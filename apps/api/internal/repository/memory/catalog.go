@@ -0,0 +1,1027 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/matching"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+type products struct{ s *Store }
+
+func (r products) Create(ctx context.Context, product *models.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	product.ID = uuid.New()
+	product.CreatedAt = now
+	product.UpdatedAt = now
+	r.s.products[product.ID] = clone(product)
+	return nil
+}
+
+func (r products) CountCreatedSince(ctx context.Context, since time.Time) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	count := 0
+	for _, product := range r.s.products {
+		if !product.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r products) GetByID(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	product, ok := r.s.products[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(product), nil
+}
+
+func (r products) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	result := []*models.Product{}
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if product, ok := r.s.products[id]; ok && !seen[id] {
+			seen[id] = true
+			result = append(result, clone(product))
+		}
+	}
+	return result, nil
+}
+
+// Search matches products whose title, brand or model words start with every query
+// word, whose title, brand or model contains the query, whose title is similar to it, or
+// that have an identifier equal to the query
+func (r products) Search(ctx context.Context, query, tag string, limit, offset int) ([]*models.Product, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	matches := r.s.searchLocked(query, repository.ProductSearchFilter{Tag: tag})
+	return pageOf(matches, limit, offset), len(matches), nil
+}
+
+// SearchWithMinPrice aggregates the published offers of each product on the Search page
+func (r products) SearchWithMinPrice(ctx context.Context, query string, filter repository.ProductSearchFilter, limit, offset int) ([]*repository.ProductSearchResult, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	matches := r.s.searchLocked(query, filter)
+	page := pageOf(matches, limit, offset)
+	results := make([]*repository.ProductSearchResult, 0, len(page))
+	for _, product := range page {
+		result := &repository.ProductSearchResult{Product: product}
+		for _, offer := range r.s.offers {
+			if offer.ProductID != product.ID || !r.s.publishedLocked(offer) {
+				continue
+			}
+			result.OfferCount++
+			if offer.InStock {
+				result.InStockCount++
+			}
+			if result.MinPriceCents == nil || offer.TotalToUSAmount < *result.MinPriceCents {
+				price := offer.TotalToUSAmount
+				result.MinPriceCents = &price
+			}
+		}
+		results = append(results, result)
+	}
+	return results, len(matches), nil
+}
+
+func (r products) SearchFacets(ctx context.Context, query string, filter repository.ProductSearchFilter) (map[string]map[string]int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	facets := map[string]map[string]int{"brand": {}, "sources": {}, "price": {}, "in_stock": {}}
+	for _, product := range r.s.searchLocked(query, filter) {
+		if product.Brand != nil && *product.Brand != "" {
+			facets["brand"][*product.Brand]++
+		}
+		sources := make(map[string]bool)
+		var minTotal *int
+		inStock := false
+		for _, offer := range r.s.offers {
+			if offer.ProductID != product.ID || !r.s.offerMatchesLocked(offer, filter) {
+				continue
+			}
+			sources[offer.Source] = true
+			if minTotal == nil || offer.TotalToUSAmount < *minTotal {
+				total := offer.TotalToUSAmount
+				minTotal = &total
+			}
+			inStock = inStock || offer.InStock
+		}
+		for source := range sources {
+			facets["sources"][source]++
+		}
+		if minTotal != nil {
+			// Like width_bucket: the number of bounds at or below the total
+			bucket := sort.Search(len(repository.PriceFacetBounds), func(i int) bool {
+				return repository.PriceFacetBounds[i] > int64(*minTotal)
+			})
+			facets["price"][repository.PriceFacetLabel(bucket)]++
+		}
+		facets["in_stock"][strconv.FormatBool(inStock)]++
+	}
+	return facets, nil
+}
+
+// searchLocked returns clones of the products matching query and filter, ranked like
+// the Postgres repository: the share of query words that prefix a title word (brand and
+// model words count half, like their lower weight) plus title similarity, then most
+// recently updated first
+func (s *Store) searchLocked(query string, filter repository.ProductSearchFilter) []*models.Product {
+	lowerQuery := strings.ToLower(query)
+	queryWords := searchWords(query)
+	identified := make(map[uuid.UUID]bool)
+	for _, ident := range s.identifiers {
+		if ident.Value == query {
+			identified[ident.ProductID] = true
+		}
+	}
+	contains := func(value *string) bool {
+		return value != nil && strings.Contains(strings.ToLower(*value), lowerQuery)
+	}
+
+	var matches []*models.Product
+	ranks := make(map[uuid.UUID]float64)
+	for _, product := range s.products {
+		if filter.Tag != "" && !s.productTags[product.ID][filter.Tag] {
+			continue
+		}
+		if filter.Brand != "" && (product.Brand == nil || !strings.EqualFold(*product.Brand, filter.Brand)) {
+			continue
+		}
+		if !s.hasFilteredOfferLocked(product.ID, filter) {
+			continue
+		}
+		if query == "" {
+			matches = append(matches, clone(product))
+			continue
+		}
+
+		titleWords := searchWords(product.Title)
+		var otherWords []string
+		for _, value := range []*string{product.Brand, product.Model} {
+			if value != nil {
+				otherWords = append(otherWords, searchWords(*value)...)
+			}
+		}
+		textRank, allWords := 0.0, len(queryWords) > 0
+		for _, word := range queryWords {
+			switch {
+			case hasPrefixWord(titleWords, word):
+				textRank++
+			case hasPrefixWord(otherWords, word):
+				textRank += 0.5
+			default:
+				allWords = false
+			}
+		}
+		titleSimilarity := similarity(product.Title, query)
+		if allWords || contains(&product.Title) || contains(product.Brand) || contains(product.Model) ||
+			titleSimilarity >= trigramThreshold || identified[product.ID] {
+			matches = append(matches, clone(product))
+			if allWords {
+				ranks[product.ID] = textRank / float64(len(queryWords))
+			}
+			ranks[product.ID] += titleSimilarity
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if ranks[matches[i].ID] != ranks[matches[j].ID] {
+			return ranks[matches[i].ID] > ranks[matches[j].ID]
+		}
+		return matches[i].UpdatedAt.After(matches[j].UpdatedAt)
+	})
+	return matches
+}
+
+// hasFilteredOfferLocked reports whether a product has a published offer of the filter's
+// source, price range and stock; it is true without those filters
+func (s *Store) hasFilteredOfferLocked(productID uuid.UUID, filter repository.ProductSearchFilter) bool {
+	if filter.Source == "" && filter.MinPriceCents == nil && filter.MaxPriceCents == nil && !filter.InStock {
+		return true
+	}
+	for _, offer := range s.offers {
+		if offer.ProductID == productID && s.offerMatchesLocked(offer, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// offerMatchesLocked reports whether an offer is published and passes the filter's
+// source, price range and stock conditions
+func (s *Store) offerMatchesLocked(offer *models.Offer, filter repository.ProductSearchFilter) bool {
+	return s.publishedLocked(offer) &&
+		(filter.Source == "" || offer.Source == filter.Source) &&
+		(filter.MinPriceCents == nil || offer.TotalToUSAmount >= *filter.MinPriceCents) &&
+		(filter.MaxPriceCents == nil || offer.TotalToUSAmount <= *filter.MaxPriceCents) &&
+		(!filter.InStock || offer.InStock)
+}
+
+func (s *Store) publishedLocked(offer *models.Offer) bool {
+	return !offer.Quarantined() && !offer.Delisted() && !offer.Expired(s.now())
+}
+
+// searchWords splits text into lower-case words of letters and digits, like the
+// Postgres prefix query
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hasPrefixWord(words []string, prefix string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r products) FindByTitle(ctx context.Context, title string) (*models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, product := range r.s.products {
+		if product.Title == title {
+			return clone(product), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r products) FindSimilarByTitle(ctx context.Context, title string, threshold float64, limit int) ([]*repository.ProductSimilarity, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	matches := []*repository.ProductSimilarity{}
+	for _, product := range r.s.products {
+		score := similarity(matching.NormalizeTitle(product.Title), title)
+		if score >= trigramThreshold && score >= threshold {
+			matches = append(matches, &repository.ProductSimilarity{Product: clone(product), Similarity: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (r products) Update(ctx context.Context, product *models.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	product.UpdatedAt = r.s.now()
+	stored, ok := r.s.products[product.ID]
+	if !ok {
+		return nil
+	}
+	updated := clone(product)
+	updated.CreatedAt = stored.CreatedAt
+	r.s.products[product.ID] = updated
+	return nil
+}
+
+func (r products) GetSummaries(ctx context.Context, ids []uuid.UUID) ([]*repository.ProductSummary, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var summaries []*repository.ProductSummary
+	for _, id := range ids {
+		if product, ok := r.s.products[id]; ok {
+			summaries = append(summaries, r.s.summarizeLocked(product))
+		}
+	}
+	return summaries, nil
+}
+
+func (r products) ListSummariesAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]*repository.ProductSummary, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var page []*models.Product
+	for id, product := range r.s.products {
+		if bytes.Compare(id[:], afterID[:]) > 0 {
+			page = append(page, product)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return bytes.Compare(page[i].ID[:], page[j].ID[:]) < 0 })
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	var summaries []*repository.ProductSummary
+	for _, product := range page {
+		summaries = append(summaries, r.s.summarizeLocked(product))
+	}
+	return summaries, nil
+}
+
+func (s *Store) summarizeLocked(product *models.Product) *repository.ProductSummary {
+	summary := &repository.ProductSummary{Product: clone(product), Sources: []string{}}
+	sources := make(map[string]bool)
+	for _, offer := range s.offers {
+		if offer.ProductID != product.ID || offer.Quarantined() || offer.Delisted() || offer.Expired(s.now()) {
+			continue
+		}
+		summary.OfferCount++
+		if summary.MinPriceCents == nil || offer.TotalToUSAmount < *summary.MinPriceCents {
+			price := offer.TotalToUSAmount
+			summary.MinPriceCents = &price
+		}
+		summary.InStock = summary.InStock || offer.InStock
+		if !sources[offer.Source] {
+			sources[offer.Source] = true
+			summary.Sources = append(summary.Sources, offer.Source)
+		}
+	}
+	sort.Strings(summary.Sources)
+	return summary
+}
+
+type offers struct{ s *Store }
+
+func (r offers) Create(ctx context.Context, offer *models.Offer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	offer.ID = uuid.New()
+	offer.FetchedAt = now
+	if offer.PriceUpdatedAt.IsZero() {
+		offer.PriceUpdatedAt = now
+	}
+	offer.CreatedAt = now
+	offer.UpdatedAt = now
+	offer.FirstSeenAt = now
+	offer.LastSeenAt = now
+	r.s.offers[offer.ID] = clone(offer)
+	return nil
+}
+
+// existsLocked reports whether another offer has the (product, source, seller, URL) of
+// offer, the unique key of the offers table
+func (r offers) existsLocked(offer *models.Offer) bool {
+	for _, existing := range r.s.offers {
+		if existing.ID != offer.ID && existing.ProductID == offer.ProductID && existing.Source == offer.Source &&
+			existing.Seller == offer.Seller && urlValue(existing.URL) == urlValue(offer.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r offers) GetByID(ctx context.Context, id uuid.UUID) (*models.Offer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	if offer, ok := r.s.offers[id]; ok {
+		return clone(offer), nil
+	}
+	return nil, nil
+}
+
+// Update saves the columns of the Postgres OfferRepository.Update
+func (r offers) Update(ctx context.Context, offer *models.Offer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	existing, ok := r.s.offers[offer.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if r.existsLocked(&models.Offer{ID: offer.ID, ProductID: existing.ProductID, Source: existing.Source, Seller: offer.Seller, URL: offer.URL}) {
+		return repository.ErrOfferExists
+	}
+	existing.Seller = offer.Seller
+	existing.URL = offer.URL
+	existing.PriceAmount = offer.PriceAmount
+	existing.Currency = offer.Currency
+	existing.ShippingToUSAmount = offer.ShippingToUSAmount
+	existing.TotalToUSAmount = offer.TotalToUSAmount
+	existing.EstDeliveryDaysMin = offer.EstDeliveryDaysMin
+	existing.EstDeliveryDaysMax = offer.EstDeliveryDaysMax
+	existing.InStock = offer.InStock
+	existing.FeeAmount = offer.FeeAmount
+	existing.ShipsFromCountry = offer.ShipsFromCountry
+	existing.DutyAmount = offer.DutyAmount
+	existing.LandedCostAmount = offer.LandedCostAmount
+	existing.FreeShipping = offer.FreeShipping
+	existing.FeeItems = offer.FeeItems
+	existing.CostBreakdown = offer.CostBreakdown
+	existing.PriceUpdatedAt = offer.PriceUpdatedAt
+	existing.Notes = offer.Notes
+	existing.ExpiresAt = offer.ExpiresAt
+	existing.UpdatedAt = r.s.now()
+	offer.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r offers) GetByProductID(ctx context.Context, productID uuid.UUID) ([]*models.Offer, error) {
+	return r.GetByProductIDWithSort(ctx, productID, "total")
+}
+
+// GetByProductIDWithSort supports the sort keys of the Postgres OfferRepository
+func (r offers) GetByProductIDWithSort(ctx context.Context, productID uuid.UUID, sortKey string) ([]*models.Offer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	result := make([]*models.Offer, 0)
+	for _, offer := range r.s.offers {
+		if offer.ProductID == productID && !offer.Quarantined() && !offer.Delisted() && !offer.Expired(r.s.now()) {
+			result = append(result, clone(offer))
+		}
+	}
+
+	deliveryDays := func(o *models.Offer) int {
+		switch {
+		case o.EstDeliveryDaysMin != nil:
+			return *o.EstDeliveryDaysMin
+		case o.EstDeliveryDaysMax != nil:
+			return *o.EstDeliveryDaysMax
+		}
+		return 9999
+	}
+	less := func(a, b *models.Offer) bool {
+		if a.TotalToUSAmount != b.TotalToUSAmount {
+			return a.TotalToUSAmount < b.TotalToUSAmount
+		}
+		return a.PriceUpdatedAt.After(b.PriceUpdatedAt)
+	}
+	switch sortKey {
+	case "landed_cost":
+		less = func(a, b *models.Offer) bool {
+			if a.LandedCostAmount != b.LandedCostAmount {
+				return a.LandedCostAmount < b.LandedCostAmount
+			}
+			return a.TotalToUSAmount < b.TotalToUSAmount
+		}
+	case "fastest":
+		less = func(a, b *models.Offer) bool {
+			if deliveryDays(a) != deliveryDays(b) {
+				return deliveryDays(a) < deliveryDays(b)
+			}
+			return a.TotalToUSAmount < b.TotalToUSAmount
+		}
+	case "newest":
+		less = func(a, b *models.Offer) bool { return a.PriceUpdatedAt.After(b.PriceUpdatedAt) }
+	case "in_stock":
+		less = func(a, b *models.Offer) bool {
+			if a.InStock != b.InStock {
+				return a.InStock
+			}
+			return a.TotalToUSAmount < b.TotalToUSAmount
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return less(result[i], result[j]) })
+	return result, nil
+}
+
+// Upsert inserts an offer or updates the one with the same product, source, seller and
+// URL, keeping its ID and first_seen_at and relisting it
+func (r offers) Upsert(ctx context.Context, offer *models.Offer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	if offer.ID == uuid.Nil {
+		offer.ID = uuid.New()
+	}
+	offer.FetchedAt = now
+	if offer.PriceUpdatedAt.IsZero() {
+		offer.PriceUpdatedAt = now
+	}
+	offer.UpdatedAt = now
+	if offer.CreatedAt.IsZero() {
+		offer.CreatedAt = now
+	}
+	if offer.FirstSeenAt.IsZero() {
+		offer.FirstSeenAt = now
+	}
+	offer.LastSeenAt = now
+	offer.DelistedAt = nil
+
+	stored := clone(offer)
+	for _, existing := range r.s.offers {
+		if existing.ProductID == offer.ProductID && existing.Source == offer.Source &&
+			existing.Seller == offer.Seller && urlValue(existing.URL) == urlValue(offer.URL) {
+			// Columns not in the Postgres DO UPDATE SET list keep their stored value
+			stored.ID = existing.ID
+			stored.Currency = existing.Currency
+			stored.CreatedAt = existing.CreatedAt
+			stored.FirstSeenAt = existing.FirstSeenAt
+			stored.Notes = existing.Notes
+			stored.ExpiresAt = existing.ExpiresAt
+			offer.ID = existing.ID
+			offer.FirstSeenAt = existing.FirstSeenAt
+			break
+		}
+	}
+	r.s.offers[stored.ID] = stored
+	return nil
+}
+
+func urlValue(url *string) string {
+	if url == nil {
+		return ""
+	}
+	return *url
+}
+
+func (r offers) GetByProductIDAndSource(ctx context.Context, productID uuid.UUID, source string) ([]*models.Offer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var result []*models.Offer
+	for _, offer := range r.s.offers {
+		if offer.ProductID == productID && offer.Source == source {
+			result = append(result, clone(offer))
+		}
+	}
+	return result, nil
+}
+
+func (r offers) MarkDelisted(ctx context.Context, productID uuid.UUID, source string, seenBefore time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var delisted int64
+	for _, offer := range r.s.offers {
+		if offer.ProductID == productID && offer.Source == source && !offer.Delisted() && offer.LastSeenAt.Before(seenBefore) {
+			delistedAt := seenBefore
+			offer.DelistedAt = &delistedAt
+			offer.UpdatedAt = seenBefore
+			delisted++
+		}
+	}
+	return delisted, nil
+}
+
+func (r offers) GetCheapestBySource(ctx context.Context, productIDs []uuid.UUID) ([]*models.Offer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	type productSource struct {
+		productID uuid.UUID
+		source    string
+	}
+	wanted := make(map[uuid.UUID]bool, len(productIDs))
+	for _, id := range productIDs {
+		wanted[id] = true
+	}
+	cheapest := make(map[productSource]*models.Offer)
+	for _, offer := range r.s.offers {
+		if !wanted[offer.ProductID] || !r.s.publishedLocked(offer) {
+			continue
+		}
+		key := productSource{offer.ProductID, offer.Source}
+		current, ok := cheapest[key]
+		if !ok || offer.TotalToUSAmount < current.TotalToUSAmount ||
+			(offer.TotalToUSAmount == current.TotalToUSAmount && offer.PriceUpdatedAt.After(current.PriceUpdatedAt)) {
+			cheapest[key] = offer
+		}
+	}
+
+	result := make([]*models.Offer, 0, len(cheapest))
+	for _, offer := range cheapest {
+		result = append(result, clone(offer))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ProductID != result[j].ProductID {
+			return bytes.Compare(result[i].ProductID[:], result[j].ProductID[:]) < 0
+		}
+		return result[i].Source < result[j].Source
+	})
+	return result, nil
+}
+
+func (r offers) DeleteExpired(ctx context.Context, source string, before time.Time) ([]uuid.UUID, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	productIDs := make([]uuid.UUID, 0)
+	for id, offer := range r.s.offers {
+		if offer.Source == source && offer.ExpiresAt != nil && offer.ExpiresAt.Before(before) {
+			delete(r.s.offers, id)
+			delete(r.s.shippingOptions, id)
+			productIDs = append(productIDs, offer.ProductID)
+		}
+	}
+	return productIDs, nil
+}
+
+func (r offers) GetPageByProductID(ctx context.Context, productID uuid.UUID, includeDelisted bool, limit, offset int) ([]*models.Offer, int, error) {
+	listed, err := r.GetByProductID(ctx, productID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if includeDelisted {
+		r.s.mu.RLock()
+		var delisted []*models.Offer
+		for _, offer := range r.s.offers {
+			if offer.ProductID == productID && offer.Delisted() && !offer.Quarantined() && !offer.Expired(r.s.now()) {
+				delisted = append(delisted, clone(offer))
+			}
+		}
+		r.s.mu.RUnlock()
+		sort.Slice(delisted, func(i, j int) bool { return delisted[i].DelistedAt.After(*delisted[j].DelistedAt) })
+		listed = append(listed, delisted...)
+	}
+	return append(make([]*models.Offer, 0), pageOf(listed, limit, offset)...), len(listed), nil
+}
+
+func (r offers) CountFetchedBefore(ctx context.Context, before time.Time) (map[string]int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, offer := range r.s.offers {
+		if offer.FetchedAt.Before(before) && !offer.Delisted() {
+			counts[offer.Source]++
+		}
+	}
+	return counts, nil
+}
+
+func (r offers) CountListed(ctx context.Context) (map[string]int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, offer := range r.s.offers {
+		if !offer.Delisted() {
+			counts[offer.Source]++
+		}
+	}
+	return counts, nil
+}
+
+func (r offers) ListQuarantined(ctx context.Context, limit int) ([]*models.Offer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	result := make([]*models.Offer, 0)
+	for _, offer := range r.s.offers {
+		if offer.Quarantined() {
+			result = append(result, clone(offer))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UpdatedAt.After(result[j].UpdatedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// PriceHistoryMedian interpolates between the middle prices like percentile_cont
+func (r offers) PriceHistoryMedian(ctx context.Context, productID uuid.UUID, since time.Time) (int, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var prices []int
+	for _, offer := range r.s.offers {
+		if offer.ProductID == productID && !offer.Quarantined() && !offer.Delisted() && !offer.Expired(r.s.now()) {
+			prices = append(prices, offer.TotalToUSAmount)
+		}
+	}
+	for _, change := range r.s.priceChanges {
+		if change.ProductID == productID && !change.ChangedAt.Before(since) {
+			prices = append(prices, change.OldTotalToUSAmount)
+		}
+	}
+	if len(prices) == 0 {
+		return 0, 0, nil
+	}
+	sort.Ints(prices)
+	mid := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return prices[mid], len(prices), nil
+	}
+	return int(math.Round(float64(prices[mid-1]+prices[mid]) / 2)), len(prices), nil
+}
+
+type shippingOptions struct{ s *Store }
+
+func (r shippingOptions) ReplaceForOffer(ctx context.Context, offerID uuid.UUID, options []*models.OfferShippingOption) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	stored := make([]*models.OfferShippingOption, 0, len(options))
+	for _, option := range options {
+		if option.ID == uuid.Nil {
+			option.ID = uuid.New()
+		}
+		option.OfferID = offerID
+		option.CreatedAt = now
+		option.UpdatedAt = now
+		stored = append(stored, clone(option))
+	}
+	r.s.shippingOptions[offerID] = stored
+	return nil
+}
+
+func (r shippingOptions) GetByOfferIDs(ctx context.Context, offerIDs []uuid.UUID) (map[uuid.UUID][]*models.OfferShippingOption, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	result := make(map[uuid.UUID][]*models.OfferShippingOption)
+	for _, offerID := range offerIDs {
+		for _, option := range r.s.shippingOptions[offerID] {
+			result[offerID] = append(result[offerID], clone(option))
+		}
+		sort.SliceStable(result[offerID], func(i, j int) bool {
+			return result[offerID][i].CostAmount < result[offerID][j].CostAmount
+		})
+	}
+	return result, nil
+}
+
+type identifiers struct{ s *Store }
+
+func (r identifiers) FindByTypeAndValue(ctx context.Context, idType, value string) (*models.ProductIdentifier, *models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, ident := range r.s.identifiers {
+		if ident.Type == idType && ident.Value == value {
+			product, ok := r.s.products[ident.ProductID]
+			if !ok {
+				return nil, nil, nil
+			}
+			return clone(ident), clone(product), nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func (r identifiers) Create(ctx context.Context, ident *models.ProductIdentifier) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.identifiers {
+		if existing.Type == ident.Type && existing.Value == ident.Value {
+			return fmt.Errorf("duplicate identifier %s:%s", ident.Type, ident.Value)
+		}
+	}
+	if _, ok := r.s.products[ident.ProductID]; !ok {
+		return fmt.Errorf("product %s does not exist", ident.ProductID)
+	}
+	now := r.s.now()
+	if ident.ID == uuid.Nil {
+		ident.ID = uuid.New()
+	}
+	ident.CreatedAt = now
+	ident.UpdatedAt = now
+	r.s.identifiers[ident.ID] = clone(ident)
+	return nil
+}
+
+func (r identifiers) ListByProductIDs(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]*models.ProductIdentifier, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	wanted := make(map[uuid.UUID]bool, len(productIDs))
+	for _, id := range productIDs {
+		wanted[id] = true
+	}
+	result := make(map[uuid.UUID][]*models.ProductIdentifier)
+	for _, ident := range r.s.identifiers {
+		if wanted[ident.ProductID] {
+			result[ident.ProductID] = append(result[ident.ProductID], clone(ident))
+		}
+	}
+	for _, idents := range result {
+		sort.Slice(idents, func(i, j int) bool {
+			if idents[i].Type != idents[j].Type {
+				return idents[i].Type < idents[j].Type
+			}
+			return idents[i].Value < idents[j].Value
+		})
+	}
+	return result, nil
+}
+
+type sourceProducts struct{ s *Store }
+
+func (r sourceProducts) FindByProviderAndSourceID(ctx context.Context, provider, sourceID string) (*models.SourceProduct, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, sp := range r.s.sourceProducts {
+		if sp.Provider == provider && sp.SourceID == sourceID {
+			return clone(sp), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r sourceProducts) GetByID(ctx context.Context, id uuid.UUID) (*models.SourceProduct, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	sp, ok := r.s.sourceProducts[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(sp), nil
+}
+
+// Upsert inserts a listing or updates the one with the same provider and source ID,
+// keeping its ID and previous snapshot when sp has none, and its titles when its title
+// did not change
+func (r sourceProducts) Upsert(ctx context.Context, sp *models.SourceProduct) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	if sp.ID == uuid.Nil {
+		sp.ID = uuid.New()
+	}
+	if sp.CreatedAt.IsZero() {
+		sp.CreatedAt = now
+	}
+	sp.UpdatedAt = now
+
+	stored := clone(sp)
+	stored.Titles = maps.Clone(sp.Titles)
+	for _, existing := range r.s.sourceProducts {
+		if existing.Provider == sp.Provider && existing.SourceID == sp.SourceID {
+			stored.ID = existing.ID
+			stored.CreatedAt = existing.CreatedAt
+			if stored.SnapshotKey == nil {
+				stored.SnapshotKey = existing.SnapshotKey
+			}
+			if stored.SnapshotAt == nil {
+				stored.SnapshotAt = existing.SnapshotAt
+			}
+			if stored.Language == nil {
+				stored.Language = existing.Language
+			}
+			if (existing.Title == nil && stored.Title == nil) || (existing.Title != nil && stored.Title != nil && *existing.Title == *stored.Title) {
+				titles := maps.Clone(existing.Titles)
+				if titles == nil {
+					titles = models.LocalizedTitles{}
+				}
+				maps.Copy(titles, stored.Titles)
+				stored.Titles = titles
+			}
+			sp.ID = existing.ID
+			break
+		}
+	}
+	r.s.sourceProducts[stored.ID] = stored
+	return nil
+}
+
+func (r sourceProducts) ListLowConfidence(ctx context.Context, maxConfidence float64, limit int) ([]*models.SourceProduct, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	result := []*models.SourceProduct{}
+	for _, sp := range r.s.sourceProducts {
+		if sp.MatchConfidence < maxConfidence {
+			result = append(result, clone(sp))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].MatchConfidence != result[j].MatchConfidence {
+			return result[i].MatchConfidence < result[j].MatchConfidence
+		}
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (r sourceProducts) ListByProductID(ctx context.Context, productID uuid.UUID) ([]*models.SourceProduct, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	result := []*models.SourceProduct{}
+	for _, sp := range r.s.sourceProducts {
+		if sp.ProductID == productID {
+			result = append(result, clone(sp))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	return result, nil
+}
+
+func (r sourceProducts) Relink(ctx context.Context, id, productID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sp, ok := r.s.sourceProducts[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	sp.ProductID = productID
+	sp.MatchMethod = models.MatchMethodManual
+	sp.MatchConfidence = 1
+	sp.UpdatedAt = r.s.now()
+	return nil
+}
+
+func (r sourceProducts) CountByProvider(ctx context.Context, provider string, since time.Time) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	count := 0
+	for _, sp := range r.s.sourceProducts {
+		if sp.Provider == provider && !sp.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r sourceProducts) ListStaleRaw(ctx context.Context, provider string, schemaVersion int, afterID uuid.UUID, limit int) ([]*models.SourceProduct, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	page := []*models.SourceProduct{}
+	for id, sp := range r.s.sourceProducts {
+		if sp.Provider == provider && sp.SchemaVersion < schemaVersion && sp.RawJSON != nil && bytes.Compare(id[:], afterID[:]) > 0 {
+			page = append(page, clone(sp))
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return bytes.Compare(page[i].ID[:], page[j].ID[:]) < 0 })
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, nil
+}
+
+func (r sourceProducts) UpdateParsed(ctx context.Context, sp *models.SourceProduct) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.sourceProducts[sp.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.URL = sp.URL
+	stored.Title = sp.Title
+	stored.Brand = sp.Brand
+	stored.ImageURL = sp.ImageURL
+	if sp.Language != nil {
+		stored.Language = sp.Language
+	}
+	stored.SchemaVersion = sp.SchemaVersion
+	return nil
+}
+
+func (r sourceProducts) DeleteOrphaned(ctx context.Context, before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	type listing struct {
+		productID uuid.UUID
+		provider  string
+	}
+	offered := make(map[listing]bool)
+	for _, offer := range r.s.offers {
+		offered[listing{offer.ProductID, offer.Source}] = true
+	}
+	var deleted int64
+	for id, sp := range r.s.sourceProducts {
+		if sp.UpdatedAt.Before(before) && !offered[listing{sp.ProductID, sp.Provider}] {
+			delete(r.s.sourceProducts, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type comparisonSets struct{ s *Store }
+
+func (r comparisonSets) Create(ctx context.Context, set *models.ComparisonSet) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	set.ID = uuid.New()
+	set.CreatedAt = r.s.now()
+	set.UpdatedAt = set.CreatedAt
+	r.s.comparisonSets[set.ID] = cloneComparisonSet(set)
+	return nil
+}
+
+func (r comparisonSets) GetByID(ctx context.Context, id uuid.UUID) (*models.ComparisonSet, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	set, ok := r.s.comparisonSets[id]
+	if !ok {
+		return nil, nil
+	}
+	return cloneComparisonSet(set), nil
+}
+
+func (r comparisonSets) GetByShareToken(ctx context.Context, token string) (*models.ComparisonSet, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, set := range r.s.comparisonSets {
+		if set.ShareToken == token {
+			return cloneComparisonSet(set), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r comparisonSets) ListByOwner(ctx context.Context, ownerKeyID uuid.UUID) ([]*models.ComparisonSet, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	sets := []*models.ComparisonSet{}
+	for _, set := range r.s.comparisonSets {
+		if set.OwnerKeyID == ownerKeyID {
+			sets = append(sets, cloneComparisonSet(set))
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		if !sets[i].CreatedAt.Equal(sets[j].CreatedAt) {
+			return sets[i].CreatedAt.Before(sets[j].CreatedAt)
+		}
+		return sets[i].ID.String() < sets[j].ID.String()
+	})
+	return sets, nil
+}
+
+func (r comparisonSets) Delete(ctx context.Context, id, ownerKeyID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	set, ok := r.s.comparisonSets[id]
+	if !ok || set.OwnerKeyID != ownerKeyID {
+		return sql.ErrNoRows
+	}
+	delete(r.s.comparisonSets, id)
+	return nil
+}
+
+// cloneComparisonSet also copies the product IDs, which clone would share
+func cloneComparisonSet(set *models.ComparisonSet) *models.ComparisonSet {
+	c := clone(set)
+	c.ProductIDs = slices.Clone(set.ProductIDs)
+	return c
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type fetchSchedules struct{ s *Store }
+
+func (r fetchSchedules) Create(ctx context.Context, schedule *models.FetchSchedule) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	schedule.ID = uuid.New()
+	schedule.Origin = models.ScheduleOriginAPI
+	schedule.CreatedAt = r.s.now()
+	schedule.UpdatedAt = schedule.CreatedAt
+	r.s.fetchSchedules[schedule.ID] = clone(schedule)
+	return nil
+}
+
+func (r fetchSchedules) GetByID(ctx context.Context, id uuid.UUID) (*models.FetchSchedule, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	schedule, ok := r.s.fetchSchedules[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(schedule), nil
+}
+
+func (r fetchSchedules) List(ctx context.Context) ([]*models.FetchSchedule, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	schedules := make([]*models.FetchSchedule, 0, len(r.s.fetchSchedules))
+	for _, schedule := range r.s.fetchSchedules {
+		schedules = append(schedules, clone(schedule))
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+		}
+		return schedules[i].ID.String() < schedules[j].ID.String()
+	})
+	return schedules, nil
+}
+
+func (r fetchSchedules) SetPaused(ctx context.Context, id uuid.UUID, paused bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	schedule, ok := r.s.fetchSchedules[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	schedule.Paused = paused
+	schedule.UpdatedAt = r.s.now()
+	return nil
+}
+
+func (r fetchSchedules) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.fetchSchedules[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.s.fetchSchedules, id)
+	return nil
+}
+
+func (r fetchSchedules) ReplaceConfigured(ctx context.Context, crons map[string]string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	configured := make(map[string]bool)
+	for id, schedule := range r.s.fetchSchedules {
+		if schedule.Origin != models.ScheduleOriginEnv {
+			continue
+		}
+		spec, ok := crons[schedule.Source]
+		if !ok {
+			delete(r.s.fetchSchedules, id)
+			continue
+		}
+		configured[schedule.Source] = true
+		if schedule.Cron != spec {
+			schedule.Cron = spec
+			schedule.UpdatedAt = now
+		}
+	}
+	for source, spec := range crons {
+		if configured[source] {
+			continue
+		}
+		schedule := &models.FetchSchedule{
+			ID:        uuid.New(),
+			Source:    source,
+			Cron:      spec,
+			Origin:    models.ScheduleOriginEnv,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		r.s.fetchSchedules[schedule.ID] = schedule
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type jobRuns struct{ s *Store }
+
+func (r jobRuns) Start(ctx context.Context, run *models.JobRun) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	run.Status = models.JobRunStatusRunning
+	run.StartedAt = r.s.now()
+	run.FinishedAt = nil
+	r.s.jobRuns[run.ID] = cloneJobRun(run)
+	return nil
+}
+
+func (r jobRuns) Finish(ctx context.Context, run *models.JobRun) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	run.FinishedAt = &now
+	if existing, ok := r.s.jobRuns[run.ID]; ok {
+		existing.Status = run.Status
+		existing.Products = slices.Clone(run.Products)
+		existing.FinishedAt = run.FinishedAt
+	}
+	return nil
+}
+
+func (r jobRuns) GetByID(ctx context.Context, id string) (*models.JobRun, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	run, ok := r.s.jobRuns[id]
+	if !ok {
+		return nil, nil
+	}
+	return cloneJobRun(run), nil
+}
+
+func (r jobRuns) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var deleted int64
+	for id, run := range r.s.jobRuns {
+		if run.StartedAt.Before(before) {
+			delete(r.s.jobRuns, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func cloneJobRun(run *models.JobRun) *models.JobRun {
+	c := clone(run)
+	c.Products = slices.Clone(run.Products)
+	return c
+}
@@ -0,0 +1,433 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// gtinIdentifierTypes share one number space, like in the Postgres MergeCandidateRepository
+var gtinIdentifierTypes = map[string]bool{"UPC": true, "EAN": true, "JAN": true, "GTIN": true}
+
+type mergeCandidates struct{ s *Store }
+
+func (r mergeCandidates) FindIdentifierDuplicates(ctx context.Context) ([]*models.MergeCandidate, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	type pair struct{ kept, duplicate uuid.UUID }
+	found := make(map[pair]bool)
+	candidates := []*models.MergeCandidate{}
+	for _, a := range r.s.identifiers {
+		for _, b := range r.s.identifiers {
+			if a.ProductID == b.ProductID || !identifiersMatch(a, b) {
+				continue
+			}
+			pa, pb := r.s.products[a.ProductID], r.s.products[b.ProductID]
+			if pa == nil || pb == nil || !olderThan(pa, pb) || found[pair{pa.ID, pb.ID}] {
+				continue
+			}
+			found[pair{pa.ID, pb.ID}] = true
+			detail := a.Type + ":" + a.Value
+			candidates = append(candidates, &models.MergeCandidate{
+				ProductID:          pa.ID,
+				DuplicateProductID: pb.ID,
+				Reason:             models.MergeReasonIdentifier,
+				Score:              1,
+				Detail:             &detail,
+			})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].ProductID != candidates[j].ProductID {
+			return candidates[i].ProductID.String() < candidates[j].ProductID.String()
+		}
+		return candidates[i].DuplicateProductID.String() < candidates[j].DuplicateProductID.String()
+	})
+	return candidates, nil
+}
+
+// identifiersMatch compares values ignoring case and leading zeros, and types ignoring
+// case or both being GTIN types
+func identifiersMatch(a, b *models.ProductIdentifier) bool {
+	normalize := func(value string) string { return strings.TrimLeft(strings.ToUpper(value), "0") }
+	if normalize(a.Value) != normalize(b.Value) {
+		return false
+	}
+	typeA, typeB := strings.ToUpper(a.Type), strings.ToUpper(b.Type)
+	return typeA == typeB || (gtinIdentifierTypes[typeA] && gtinIdentifierTypes[typeB])
+}
+
+func (r mergeCandidates) FindTitleDuplicates(ctx context.Context, threshold float64, limit int) ([]*models.MergeCandidate, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	candidates := []*models.MergeCandidate{}
+	for _, a := range r.s.products {
+		for _, b := range r.s.products {
+			if a.Brand == nil || b.Brand == nil || !strings.EqualFold(*a.Brand, *b.Brand) || !olderThan(a, b) {
+				continue
+			}
+			score := similarity(a.Title, b.Title)
+			if score >= trigramThreshold && score >= threshold {
+				candidates = append(candidates, &models.MergeCandidate{
+					ProductID:          a.ID,
+					DuplicateProductID: b.ID,
+					Reason:             models.MergeReasonTitle,
+					Score:              score,
+				})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+func (r mergeCandidates) UpsertPending(ctx context.Context, candidate *models.MergeCandidate) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+	if candidate.ID == uuid.Nil {
+		candidate.ID = uuid.New()
+	}
+	candidate.Status = models.MergeStatusPending
+	candidate.CreatedAt = now
+	candidate.UpdatedAt = now
+
+	for _, existing := range r.s.mergeCandidates {
+		if existing.ProductID != candidate.ProductID || existing.DuplicateProductID != candidate.DuplicateProductID {
+			continue
+		}
+		if existing.Status != models.MergeStatusPending {
+			return repository.ErrMergeCandidateResolved
+		}
+		existing.Reason = candidate.Reason
+		existing.Score = candidate.Score
+		existing.Detail = candidate.Detail
+		existing.UpdatedAt = now
+		candidate.ID = existing.ID
+		return nil
+	}
+	r.s.mergeCandidates[candidate.ID] = clone(candidate)
+	return nil
+}
+
+func (r mergeCandidates) GetByID(ctx context.Context, id uuid.UUID) (*models.MergeCandidate, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	candidate, ok := r.s.mergeCandidates[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(candidate), nil
+}
+
+func (r mergeCandidates) ListByStatus(ctx context.Context, status string, limit int) ([]*models.MergeCandidate, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	candidates := []*models.MergeCandidate{}
+	for _, candidate := range r.s.mergeCandidates {
+		if candidate.Status == status {
+			candidates = append(candidates, clone(candidate))
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].CreatedAt.Before(candidates[j].CreatedAt)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+func (r mergeCandidates) Dismiss(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	candidate, ok := r.s.mergeCandidates[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if candidate.Status != models.MergeStatusPending {
+		return repository.ErrMergeCandidateResolved
+	}
+	now := r.s.now()
+	candidate.Status = models.MergeStatusDismissed
+	candidate.ResolvedAt = &now
+	candidate.UpdatedAt = now
+	return nil
+}
+
+// Merge moves the duplicate's rows to the kept product and deletes the duplicate, like
+// the Postgres MergeCandidateRepository.Merge
+func (r mergeCandidates) Merge(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	candidate, ok := r.s.mergeCandidates[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if candidate.Status != models.MergeStatusPending {
+		return repository.ErrMergeCandidateResolved
+	}
+	keptID, duplicateID := candidate.ProductID, candidate.DuplicateProductID
+	now := r.s.now()
+
+	for _, offer := range r.s.offers {
+		if offer.ProductID != duplicateID {
+			continue
+		}
+		collides := false
+		for _, kept := range r.s.offers {
+			if kept.ProductID == keptID && kept.Source == offer.Source && kept.Seller == offer.Seller && urlValue(kept.URL) == urlValue(offer.URL) {
+				collides = true
+				break
+			}
+		}
+		if !collides {
+			offer.ProductID = keptID
+			offer.UpdatedAt = now
+		}
+	}
+	for _, ident := range r.s.identifiers {
+		if ident.ProductID == duplicateID {
+			ident.ProductID = keptID
+			ident.UpdatedAt = now
+		}
+	}
+	for _, sp := range r.s.sourceProducts {
+		if sp.ProductID == duplicateID {
+			sp.ProductID = keptID
+			sp.UpdatedAt = now
+		}
+	}
+	for _, change := range r.s.priceChanges {
+		if change.ProductID == duplicateID {
+			change.ProductID = keptID
+		}
+	}
+	for _, event := range r.s.stockEvents {
+		if event.ProductID == duplicateID {
+			event.ProductID = keptID
+		}
+	}
+	for _, alert := range r.s.priceAlerts {
+		if alert.ProductID == duplicateID {
+			alert.ProductID = keptID
+			alert.UpdatedAt = now
+		}
+	}
+	for _, revision := range r.s.revisions {
+		if revision.ProductID == duplicateID {
+			revision.ProductID = keptID
+			if revision.MergedFromProductID == nil {
+				revision.MergedFromProductID = &duplicateID
+			}
+		}
+	}
+	for tag := range r.s.productTags[duplicateID] {
+		r.s.tagLocked(keptID, tag)
+	}
+	for _, image := range r.s.images {
+		if image.productID == duplicateID && !r.s.hasImageLocked(keptID, image.imageURL) {
+			image.productID = keptID
+		}
+	}
+	for otherID, other := range r.s.mergeCandidates {
+		if otherID != id && other.Status == models.MergeStatusPending &&
+			(other.ProductID == duplicateID || other.DuplicateProductID == duplicateID) {
+			delete(r.s.mergeCandidates, otherID)
+		}
+	}
+
+	candidate.Status = models.MergeStatusMerged
+	candidate.ResolvedAt = &now
+	candidate.UpdatedAt = now
+	r.s.deleteProductLocked(duplicateID)
+	return nil
+}
+
+func (s *Store) hasImageLocked(productID uuid.UUID, imageURL string) bool {
+	for _, image := range s.images {
+		if image.productID == productID && image.imageURL == imageURL {
+			return true
+		}
+	}
+	return false
+}
+
+type images struct{ s *Store }
+
+func (r images) FindHashByURL(ctx context.Context, imageURL string) (imagehash.Hashes, bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, image := range r.s.images {
+		if image.imageURL == imageURL {
+			return image.hashes, true, nil
+		}
+	}
+	return imagehash.Hashes{}, false, nil
+}
+
+func (r images) Upsert(ctx context.Context, productID uuid.UUID, imageURL string, hashes imagehash.Hashes) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, image := range r.s.images {
+		if image.productID == productID && image.imageURL == imageURL {
+			image.hashes = hashes
+			return nil
+		}
+	}
+	r.s.images = append(r.s.images, &productImage{productID: productID, imageURL: imageURL, hashes: hashes})
+	return nil
+}
+
+func (r images) FindNearest(ctx context.Context, hashes imagehash.Hashes, maxDistance, limit int) ([]*repository.ProductImageMatch, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	closest := make(map[uuid.UUID]*repository.ProductImageMatch)
+	for _, image := range r.s.images {
+		distance := bits.OnesCount64(image.hashes.PHash ^ hashes.PHash)
+		dhashDistance := bits.OnesCount64(image.hashes.DHash ^ hashes.DHash)
+		product, ok := r.s.products[image.productID]
+		if distance > maxDistance || !ok {
+			continue
+		}
+		if match, ok := closest[image.productID]; !ok || distance < match.Distance ||
+			(distance == match.Distance && dhashDistance < *match.DHashDistance) {
+			closest[image.productID] = &repository.ProductImageMatch{
+				Product:       clone(product),
+				ImageURL:      image.imageURL,
+				Distance:      distance,
+				DHashDistance: &dhashDistance,
+			}
+		}
+	}
+
+	matches := make([]*repository.ProductImageMatch, 0, len(closest))
+	for _, match := range closest {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		if *matches[i].DHashDistance != *matches[j].DHashDistance {
+			return *matches[i].DHashDistance < *matches[j].DHashDistance
+		}
+		return matches[i].Product.UpdatedAt.After(matches[j].Product.UpdatedAt)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (r images) ListUnhashed(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	page := []*models.Product{}
+	for id, product := range r.s.products {
+		if bytes.Compare(id[:], afterID[:]) <= 0 || product.ImageURL == nil || *product.ImageURL == "" {
+			continue
+		}
+		if !r.s.hasImageLocked(id, *product.ImageURL) {
+			page = append(page, clone(product))
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return bytes.Compare(page[i].ID[:], page[j].ID[:]) < 0 })
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, nil
+}
+
+type embeddings struct{ s *Store }
+
+func (r embeddings) Upsert(ctx context.Context, productID uuid.UUID, model string, vector []float32) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.embeddings[embeddingKey{productID: productID, model: model}] = append([]float32(nil), vector...)
+	return nil
+}
+
+// FindNearest ranks all embeddings of the model by cosine similarity
+func (r embeddings) FindNearest(ctx context.Context, model string, vector []float32, limit int) ([]*repository.ProductSimilarity, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	matches := []*repository.ProductSimilarity{}
+	for key, stored := range r.s.embeddings {
+		product, ok := r.s.products[key.productID]
+		if key.model != model || !ok {
+			continue
+		}
+		matches = append(matches, &repository.ProductSimilarity{Product: clone(product), Similarity: cosineSimilarity(stored, vector)})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (r embeddings) ListUnembedded(ctx context.Context, model string, afterID uuid.UUID, limit int) ([]*models.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	page := []*models.Product{}
+	for id, product := range r.s.products {
+		if bytes.Compare(id[:], afterID[:]) <= 0 {
+			continue
+		}
+		if _, ok := r.s.embeddings[embeddingKey{productID: id, model: model}]; !ok {
+			page = append(page, clone(product))
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return bytes.Compare(page[i].ID[:], page[j].ID[:]) < 0 })
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, nil
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,234 @@
+// Package memory implements the repository store interfaces in memory, for running the
+// API without Postgres (REPOSITORY_BACKEND=memory) and for handler tests. Data is lost on
+// restart. Queries mirror the Postgres repositories closely enough for development:
+// title similarity uses the pg_trgm algorithm, full-text search is approximated by word
+// prefix matching, and deleting a product cascades like the foreign keys do.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/matching"
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+// trigramThreshold is pg_trgm.similarity_threshold, applied by the % operator
+const trigramThreshold = 0.3
+
+// Store holds all tables. The accessors return views implementing the store interfaces;
+// all views of one Store share its data.
+type Store struct {
+	mu              sync.RWMutex
+	products        map[uuid.UUID]*models.Product
+	offers          map[uuid.UUID]*models.Offer
+	shippingOptions map[uuid.UUID][]*models.OfferShippingOption // by offer ID
+	identifiers     map[uuid.UUID]*models.ProductIdentifier
+	sourceProducts  map[uuid.UUID]*models.SourceProduct
+	mergeCandidates map[uuid.UUID]*models.MergeCandidate
+	images          []*productImage
+	embeddings      map[embeddingKey][]float32
+	fetches         []*providerFetch
+	priceChanges    []*models.OfferPriceChange
+	priceChangeSeq  int64 // last offer_price_changes ID (BIGSERIAL)
+	stockEvents     []*models.StockEvent
+	stockEventSeq   int64 // last stock_events ID (BIGSERIAL)
+	offerMerges     []*models.OfferMerge
+	offerMergeSeq   int64 // last offer_merge_log ID (BIGSERIAL)
+	priceAlerts     map[uuid.UUID]*models.PriceAlert
+	revisions       []*models.ProductRevision
+	productTags     map[uuid.UUID]map[string]bool
+	fetchSchedules  map[uuid.UUID]*models.FetchSchedule
+	searchQueries   map[uuid.UUID]*models.SearchQuery
+	apiKeys         map[uuid.UUID]*models.APIKey
+	comparisonSets  map[uuid.UUID]*models.ComparisonSet
+	jobRuns         map[string]*models.JobRun
+	revisionSeq     int64 // last product_revisions ID (BIGSERIAL)
+	now             func() time.Time
+}
+
+type productImage struct {
+	productID uuid.UUID
+	imageURL  string
+	hashes    imagehash.Hashes
+}
+
+type embeddingKey struct {
+	productID uuid.UUID
+	model     string
+}
+
+type providerFetch struct {
+	provider  string
+	operation string
+	success   bool
+	err       *string
+	results   int
+	createdAt time.Time
+}
+
+func New() *Store {
+	return &Store{
+		products:        make(map[uuid.UUID]*models.Product),
+		offers:          make(map[uuid.UUID]*models.Offer),
+		shippingOptions: make(map[uuid.UUID][]*models.OfferShippingOption),
+		identifiers:     make(map[uuid.UUID]*models.ProductIdentifier),
+		sourceProducts:  make(map[uuid.UUID]*models.SourceProduct),
+		mergeCandidates: make(map[uuid.UUID]*models.MergeCandidate),
+		embeddings:      make(map[embeddingKey][]float32),
+		priceAlerts:     make(map[uuid.UUID]*models.PriceAlert),
+		productTags:     make(map[uuid.UUID]map[string]bool),
+		fetchSchedules:  make(map[uuid.UUID]*models.FetchSchedule),
+		searchQueries:   make(map[uuid.UUID]*models.SearchQuery),
+		apiKeys:         make(map[uuid.UUID]*models.APIKey),
+		comparisonSets:  make(map[uuid.UUID]*models.ComparisonSet),
+		jobRuns:         make(map[string]*models.JobRun),
+		now:             time.Now,
+	}
+}
+
+func (s *Store) Products() repository.ProductStore { return products{s} }
+
+func (s *Store) Offers() repository.OfferStore { return offers{s} }
+
+func (s *Store) OfferPriceChanges() repository.OfferPriceChangeStore { return priceChanges{s} }
+
+func (s *Store) StockEvents() repository.StockEventStore { return stockEvents{s} }
+
+func (s *Store) OfferMergeLog() repository.OfferMergeLogStore { return offerMerges{s} }
+
+func (s *Store) OfferShippingOptions() repository.OfferShippingOptionStore {
+	return shippingOptions{s}
+}
+
+func (s *Store) ProductIdentifiers() repository.ProductIdentifierStore { return identifiers{s} }
+
+func (s *Store) SourceProducts() repository.SourceProductStore { return sourceProducts{s} }
+
+func (s *Store) MergeCandidates() repository.MergeCandidateStore { return mergeCandidates{s} }
+
+func (s *Store) ProductImages() repository.ProductImageStore { return images{s} }
+
+func (s *Store) ProductEmbeddings() repository.ProductEmbeddingStore { return embeddings{s} }
+
+func (s *Store) ProviderFetches() repository.ProviderFetchStore { return providerFetches{s} }
+
+func (s *Store) PriceAlerts() repository.PriceAlertStore { return priceAlerts{s} }
+
+func (s *Store) ProductRevisions() repository.ProductRevisionStore { return productRevisions{s} }
+
+func (s *Store) ProductTags() repository.ProductTagStore { return productTags{s} }
+
+func (s *Store) FetchSchedules() repository.FetchScheduleStore { return fetchSchedules{s} }
+
+func (s *Store) SearchQueries() repository.SearchQueryStore { return searchQueries{s} }
+
+func (s *Store) APIKeys() repository.APIKeyStore { return apiKeys{s} }
+
+func (s *Store) ComparisonSets() repository.ComparisonSetStore { return comparisonSets{s} }
+
+func (s *Store) JobRuns() repository.JobRunStore { return jobRuns{s} }
+
+// clone returns a shallow copy, so callers cannot modify stored rows
+func clone[T any](row *T) *T {
+	c := *row
+	return &c
+}
+
+// deleteProductLocked deletes a product and the rows referencing it
+// (ON DELETE CASCADE). The caller holds the write lock.
+func (s *Store) deleteProductLocked(id uuid.UUID) {
+	delete(s.products, id)
+	for offerID, offer := range s.offers {
+		if offer.ProductID == id {
+			delete(s.offers, offerID)
+			delete(s.shippingOptions, offerID)
+		}
+	}
+	for identID, ident := range s.identifiers {
+		if ident.ProductID == id {
+			delete(s.identifiers, identID)
+		}
+	}
+	for spID, sp := range s.sourceProducts {
+		if sp.ProductID == id {
+			delete(s.sourceProducts, spID)
+		}
+	}
+	for candidateID, candidate := range s.mergeCandidates {
+		if candidate.ProductID == id {
+			delete(s.mergeCandidates, candidateID)
+		}
+	}
+	for key := range s.embeddings {
+		if key.productID == id {
+			delete(s.embeddings, key)
+		}
+	}
+	kept := s.images[:0]
+	for _, image := range s.images {
+		if image.productID != id {
+			kept = append(kept, image)
+		}
+	}
+	s.images = kept
+	keptChanges := s.priceChanges[:0]
+	for _, change := range s.priceChanges {
+		if change.ProductID != id {
+			keptChanges = append(keptChanges, change)
+		}
+	}
+	s.priceChanges = keptChanges
+	keptStockEvents := s.stockEvents[:0]
+	for _, event := range s.stockEvents {
+		if event.ProductID != id {
+			keptStockEvents = append(keptStockEvents, event)
+		}
+	}
+	s.stockEvents = keptStockEvents
+	keptMerges := s.offerMerges[:0]
+	for _, merge := range s.offerMerges {
+		if merge.ProductID != id {
+			keptMerges = append(keptMerges, merge)
+		}
+	}
+	s.offerMerges = keptMerges
+	for alertID, alert := range s.priceAlerts {
+		if alert.ProductID == id {
+			delete(s.priceAlerts, alertID)
+		}
+	}
+	keptRevisions := s.revisions[:0]
+	for _, revision := range s.revisions {
+		if revision.ProductID != id {
+			keptRevisions = append(keptRevisions, revision)
+		}
+	}
+	s.revisions = keptRevisions
+	delete(s.productTags, id)
+}
+
+// olderThan orders products like (created_at, id) < (created_at, id) in Postgres
+func olderThan(a, b *models.Product) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// similarity is pg_trgm's similarity(), like the Postgres repositories use
+func similarity(a, b string) float64 {
+	return matching.TrigramSimilarity(a, b)
+}
+
+// pageOf returns the items of a LIMIT/OFFSET page
+func pageOf[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+func TestSimilarity(t *testing.T) {
+	// Expected values are SELECT similarity(a, b) in Postgres with pg_trgm
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"word", "word", 1},
+		{"word", "two words", 4.0 / 11},
+		{"Sony WH-1000XM5", "sony wh 1000xm5", 1},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRankingAndFilters(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	sony, bose := "Sony", "Bose"
+	products := make(map[string]*models.Product)
+	for _, product := range []*models.Product{
+		{Title: "Wireless Noise Cancelling Headphones", Brand: &sony},
+		{Title: "QuietComfort Headphones", Brand: &bose},
+		{Title: "Headphone Stand", Brand: &bose},
+	} {
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		products[product.Title] = product
+	}
+	for title, offer := range map[string]*models.Offer{
+		"Wireless Noise Cancelling Headphones": {Source: "amazon", TotalToUSAmount: 29900},
+		"QuietComfort Headphones":              {Source: "walmart", TotalToUSAmount: 34900},
+		"Headphone Stand":                      {Source: "amazon", TotalToUSAmount: 2500},
+	} {
+		offer.ProductID = products[title].ID
+		offer.Seller = "seller"
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	price := func(cents int) *int { return &cents }
+	tests := []struct {
+		name   string
+		query  string
+		filter repository.ProductSearchFilter
+		want   []string
+	}{
+		{name: "prefix", query: "wireless headph", want: []string{"Wireless Noise Cancelling Headphones"}},
+		{name: "typo", query: "quietcomfort headphnes", want: []string{"QuietComfort Headphones"}},
+		{name: "ranked by title similarity", query: "headphone", want: []string{"Headphone Stand", "QuietComfort Headphones", "Wireless Noise Cancelling Headphones"}},
+		{name: "brand", query: "headphone", filter: repository.ProductSearchFilter{Brand: "BOSE"}, want: []string{"Headphone Stand", "QuietComfort Headphones"}},
+		{name: "source", query: "headphone", filter: repository.ProductSearchFilter{Source: "amazon"}, want: []string{"Headphone Stand", "Wireless Noise Cancelling Headphones"}},
+		{name: "price range", filter: repository.ProductSearchFilter{MinPriceCents: price(10000), MaxPriceCents: price(30000)}, want: []string{"Wireless Noise Cancelling Headphones"}},
+		{name: "source and price apply to one offer", filter: repository.ProductSearchFilter{Source: "walmart", MaxPriceCents: price(30000)}},
+	}
+	for _, tt := range tests {
+		results, total, err := store.Products().SearchWithMinPrice(ctx, tt.query, tt.filter, 20, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.Product.Title)
+		}
+		if total != len(tt.want) || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q (total %d), want %q", tt.name, got, total, tt.want)
+		}
+	}
+}
+
+func TestOfferUpsertKeepsID(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	product := &models.Product{Title: "Widget"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	url := "https://example.com/widget"
+	first := &models.Offer{ProductID: product.ID, Source: "amazon", Seller: "acme", URL: &url, PriceAmount: 1000, Currency: "USD"}
+	if err := store.Offers().Upsert(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	second := &models.Offer{ProductID: product.ID, Source: "amazon", Seller: "acme", URL: &url, PriceAmount: 900, Currency: "JPY"}
+	if err := store.Offers().Upsert(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("upsert assigned a new ID %s, want %s", second.ID, first.ID)
+	}
+	offers, err := store.Offers().GetByProductID(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("got %d offers, want 1", len(offers))
+	}
+	if offers[0].PriceAmount != 900 || offers[0].Currency != "USD" {
+		t.Errorf("got price %d %s, want 900 USD (currency is not updated)", offers[0].PriceAmount, offers[0].Currency)
+	}
+}
+
+func TestMergeMovesRowsAndDeletesDuplicate(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	kept := &models.Product{Title: "Widget"}
+	duplicate := &models.Product{Title: "Widget"}
+	for _, product := range []*models.Product{kept, duplicate} {
+		if err := store.Products().Create(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	url := "https://example.com/widget"
+	for _, offer := range []*models.Offer{
+		{ProductID: kept.ID, Source: "amazon", Seller: "acme", URL: &url},
+		{ProductID: duplicate.ID, Source: "amazon", Seller: "acme", URL: &url},
+		{ProductID: duplicate.ID, Source: "walmart", Seller: "acme"},
+	} {
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.ProductIdentifiers().Create(ctx, &models.ProductIdentifier{ProductID: duplicate.ID, Type: "UPC", Value: "0123"}); err != nil {
+		t.Fatal(err)
+	}
+	alert := &models.PriceAlert{ProductID: duplicate.ID, Channel: models.AlertChannelWebhook, Target: "https://example.com/hook", TargetPriceCents: 1000}
+	if err := store.PriceAlerts().Create(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+
+	edited, _ := store.Products().GetByID(ctx, duplicate.ID)
+	title := "Widget Pro"
+	edited.Title = title
+	revision := &models.ProductRevision{Changes: models.FieldChanges{models.ProductFieldTitle: {New: &title}}}
+	if err := store.ProductRevisions().Apply(ctx, edited, revision); err != nil {
+		t.Fatal(err)
+	}
+
+	candidate := &models.MergeCandidate{ProductID: kept.ID, DuplicateProductID: duplicate.ID, Reason: models.MergeReasonTitle, Score: 1}
+	if err := store.MergeCandidates().UpsertPending(ctx, candidate); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MergeCandidates().Merge(ctx, candidate.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, _ := store.ProductRevisions().ListByProductID(ctx, kept.ID, 10)
+	if len(revisions) != 1 || revisions[0].MergedFromProductID == nil || *revisions[0].MergedFromProductID != duplicate.ID {
+		t.Errorf("kept product revisions = %+v, want the duplicate's", revisions)
+	}
+	if fields, _ := store.ProductRevisions().EditedFields(ctx, kept.ID); len(fields) != 0 {
+		t.Errorf("EditedFields() of the kept product = %v, want none from the duplicate's revisions", fields)
+	}
+
+	if product, _ := store.Products().GetByID(ctx, duplicate.ID); product != nil {
+		t.Error("duplicate product still exists")
+	}
+	offers, _ := store.Offers().GetByProductID(ctx, kept.ID)
+	if len(offers) != 2 {
+		t.Errorf("kept product has %d offers, want 2 (the colliding amazon offer is dropped)", len(offers))
+	}
+	if _, product, _ := store.ProductIdentifiers().FindByTypeAndValue(ctx, "UPC", "0123"); product == nil || product.ID != kept.ID {
+		t.Error("identifier was not moved to the kept product")
+	}
+	if moved, _ := store.PriceAlerts().GetByID(ctx, alert.ID); moved == nil || moved.ProductID != kept.ID {
+		t.Error("price alert was not moved to the kept product")
+	}
+	merged, _ := store.MergeCandidates().GetByID(ctx, candidate.ID)
+	if merged == nil || merged.Status != models.MergeStatusMerged || merged.ResolvedAt == nil {
+		t.Errorf("candidate = %+v, want merged", merged)
+	}
+	if err := store.MergeCandidates().Merge(ctx, candidate.ID); err == nil {
+		t.Error("merging a resolved candidate succeeded")
+	}
+}
+
+func TestProductRevisionsApplyChecksVersion(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	product := &models.Product{Title: "Widget"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := store.Products().GetByID(ctx, product.ID)
+	second, _ := store.Products().GetByID(ctx, product.ID)
+
+	title := "Widget Pro"
+	first.Title = title
+	if err := store.ProductRevisions().Apply(ctx, first, &models.ProductRevision{Changes: models.FieldChanges{models.ProductFieldTitle: {New: &title}}}); err != nil {
+		t.Fatal(err)
+	}
+	second.Title = "Widget Mini"
+	err := store.ProductRevisions().Apply(ctx, second, &models.ProductRevision{Changes: models.FieldChanges{models.ProductFieldTitle: {New: &second.Title}}})
+	if !errors.Is(err, repository.ErrProductModified) {
+		t.Errorf("Apply() of a stale product error = %v, want ErrProductModified", err)
+	}
+	if stored, _ := store.Products().GetByID(ctx, product.ID); stored.Title != title {
+		t.Errorf("stored title = %q, want %q", stored.Title, title)
+	}
+}
+
+func TestPriceHistoryMedian(t *testing.T) {
+	ctx := context.Background()
+	store := New()
+	product := &models.Product{Title: "Widget"}
+	if err := store.Products().Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	reason := models.OfferQuarantinePriceUnknown
+	for _, offer := range []*models.Offer{
+		{ProductID: product.ID, Source: "amazon", Seller: "a", TotalToUSAmount: 1000},
+		{ProductID: product.ID, Source: "walmart", Seller: "b", TotalToUSAmount: 1300},
+		{ProductID: product.ID, Source: "live", Seller: "c", TotalToUSAmount: 1, QuarantineReason: &reason},
+	} {
+		if err := store.Offers().Create(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.OfferPriceChanges().Record(ctx, &models.OfferPriceChange{ProductID: product.ID, OldTotalToUSAmount: 1200, NewTotalToUSAmount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	median, samples, err := store.Offers().PriceHistoryMedian(ctx, product.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if median != 1200 || samples != 3 {
+		t.Errorf("PriceHistoryMedian() = %d over %d prices, want 1200 over 3", median, samples)
+	}
+	// Only published offers remain once the history is out of the window
+	median, samples, _ = store.Offers().PriceHistoryMedian(ctx, product.ID, time.Now().Add(time.Hour))
+	if median != 1150 || samples != 2 {
+		t.Errorf("PriceHistoryMedian() = %d over %d prices, want 1150 over 2", median, samples)
+	}
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type offerMerges struct{ s *Store }
+
+func (r offerMerges) Record(ctx context.Context, merge *models.OfferMerge) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, logged := range r.s.offerMerges {
+		if logged.KeptOfferID == merge.KeptOfferID && logged.MergedSeller == merge.MergedSeller && optionalEqual(logged.MergedURL, merge.MergedURL) {
+			return false, nil
+		}
+	}
+	r.s.offerMergeSeq++
+	merge.ID = r.s.offerMergeSeq
+	merge.MergedAt = r.s.now()
+	r.s.offerMerges = append(r.s.offerMerges, clone(merge))
+	return true, nil
+}
+
+// optionalEqual treats a nil string as empty, like the unique index does
+func optionalEqual(a, b *string) bool {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return value(a) == value(b)
+}
+
+func (r offerMerges) ListByProductID(ctx context.Context, productID uuid.UUID, limit int) ([]*models.OfferMerge, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Merges are appended in order, so walking backwards lists the newest first
+	result := []*models.OfferMerge{}
+	for i := len(r.s.offerMerges) - 1; i >= 0 && len(result) < limit; i-- {
+		if merge := r.s.offerMerges[i]; merge.ProductID == productID {
+			result = append(result, clone(merge))
+		}
+	}
+	return result, nil
+}
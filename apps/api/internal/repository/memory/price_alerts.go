@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type priceAlerts struct{ s *Store }
+
+func (r priceAlerts) Create(ctx context.Context, alert *models.PriceAlert) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	alert.ID = uuid.New()
+	alert.CreatedAt = r.s.now()
+	alert.UpdatedAt = alert.CreatedAt
+	r.s.priceAlerts[alert.ID] = clone(alert)
+	return nil
+}
+
+func (r priceAlerts) GetByID(ctx context.Context, id uuid.UUID) (*models.PriceAlert, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	alert, ok := r.s.priceAlerts[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(alert), nil
+}
+
+func (r priceAlerts) Confirm(ctx context.Context, token string, at time.Time) (*models.PriceAlert, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, alert := range r.s.priceAlerts {
+		if alert.ConfirmToken == "" || alert.ConfirmToken != token {
+			continue
+		}
+		if alert.ConfirmedAt == nil {
+			alert.ConfirmedAt = &at
+			alert.UpdatedAt = r.s.now()
+		}
+		return clone(alert), nil
+	}
+	return nil, nil
+}
+
+func (r priceAlerts) List(ctx context.Context) ([]*models.PriceAlert, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	alerts := make([]*models.PriceAlert, 0, len(r.s.priceAlerts))
+	for _, alert := range r.s.priceAlerts {
+		alerts = append(alerts, clone(alert))
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+		}
+		return alerts[i].ID.String() < alerts[j].ID.String()
+	})
+	return alerts, nil
+}
+
+func (r priceAlerts) MarkTriggered(ctx context.Context, id uuid.UUID, at *time.Time, cents *int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	alert, ok := r.s.priceAlerts[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	alert.TriggeredAt = at
+	alert.TriggeredCents = cents
+	alert.UpdatedAt = r.s.now()
+	return nil
+}
+
+func (r priceAlerts) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.priceAlerts[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.s.priceAlerts, id)
+	return nil
+}
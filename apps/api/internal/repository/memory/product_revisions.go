@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+type productRevisions struct{ s *Store }
+
+func (r productRevisions) Apply(ctx context.Context, product *models.Product, revision *models.ProductRevision) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.products[product.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !stored.UpdatedAt.Equal(product.UpdatedAt) {
+		return repository.ErrProductModified
+	}
+	now := r.s.now()
+	stored.Title = product.Title
+	stored.Brand = product.Brand
+	stored.Model = product.Model
+	stored.ImageURL = product.ImageURL
+	stored.Category = product.Category
+	stored.UpdatedAt = now
+	product.UpdatedAt = now
+
+	r.s.revisionSeq++
+	revision.ID = r.s.revisionSeq
+	revision.ProductID = product.ID
+	revision.CreatedAt = now
+	r.s.revisions = append(r.s.revisions, clone(revision))
+	return nil
+}
+
+func (r productRevisions) ListByProductID(ctx context.Context, productID uuid.UUID, limit int) ([]*models.ProductRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Revisions are appended in ID order, so newest first is reverse order
+	revisions := []*models.ProductRevision{}
+	for i := len(r.s.revisions) - 1; i >= 0 && len(revisions) < limit; i-- {
+		if r.s.revisions[i].ProductID == productID {
+			revisions = append(revisions, clone(r.s.revisions[i]))
+		}
+	}
+	return revisions, nil
+}
+
+func (r productRevisions) EditedFields(ctx context.Context, productID uuid.UUID) (map[string]bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	fields := make(map[string]bool)
+	for _, revision := range r.s.revisions {
+		if revision.ProductID == productID && revision.MergedFromProductID == nil {
+			for field := range revision.Changes {
+				fields[field] = true
+			}
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type productTags struct{ s *Store }
+
+// tagLocked tags an existing product and reports whether the tag is new. The caller
+// holds the write lock.
+func (s *Store) tagLocked(productID uuid.UUID, tag string) bool {
+	if _, ok := s.products[productID]; !ok || s.productTags[productID][tag] {
+		return false
+	}
+	if s.productTags[productID] == nil {
+		s.productTags[productID] = make(map[string]bool)
+	}
+	s.productTags[productID][tag] = true
+	return true
+}
+
+func (r productTags) Add(ctx context.Context, productIDs []uuid.UUID, tags []string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var added int64
+	for _, productID := range productIDs {
+		for _, tag := range tags {
+			if r.s.tagLocked(productID, tag) {
+				added++
+			}
+		}
+	}
+	return added, nil
+}
+
+func (r productTags) Remove(ctx context.Context, productIDs []uuid.UUID, tags []string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var removed int64
+	for _, productID := range productIDs {
+		for _, tag := range tags {
+			if r.s.productTags[productID][tag] {
+				delete(r.s.productTags[productID], tag)
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+func (r productTags) ListByProductID(ctx context.Context, productID uuid.UUID) ([]string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	tags := []string{}
+	for tag := range r.s.productTags[productID] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (r productTags) ListTags(ctx context.Context) ([]*models.TagCount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, tags := range r.s.productTags {
+		for tag := range tags {
+			counts[tag]++
+		}
+	}
+	result := []*models.TagCount{}
+	for tag, count := range counts {
+		result = append(result, &models.TagCount{Tag: tag, ProductCount: count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Tag < result[j].Tag })
+	return result, nil
+}
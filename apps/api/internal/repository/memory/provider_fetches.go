@@ -0,0 +1,245 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type providerFetches struct{ s *Store }
+
+func (r providerFetches) Record(ctx context.Context, provider, operation string, duration time.Duration, resultCount int, callErr error) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	fetch := &providerFetch{provider: provider, operation: operation, success: callErr == nil, createdAt: r.s.now()}
+	if callErr != nil {
+		message := callErr.Error()
+		fetch.err = &message
+	} else {
+		fetch.results = resultCount
+	}
+	r.s.fetches = append(r.s.fetches, fetch)
+	return nil
+}
+
+func (r providerFetches) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	kept := r.s.fetches[:0]
+	for _, fetch := range r.s.fetches {
+		if !fetch.createdAt.Before(before) {
+			kept = append(kept, fetch)
+		}
+	}
+	deleted := int64(len(r.s.fetches) - len(kept))
+	r.s.fetches = kept
+	return deleted, nil
+}
+
+func (r providerFetches) LastSuccessAt(ctx context.Context) (map[string]time.Time, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	last := make(map[string]time.Time)
+	for _, fetch := range r.s.fetches {
+		if fetch.success && fetch.createdAt.After(last[fetch.provider]) {
+			last[fetch.provider] = fetch.createdAt
+		}
+	}
+	return last, nil
+}
+
+// Stats aggregates like the Postgres ProviderFetchRepository.Stats
+func (r providerFetches) Stats(ctx context.Context, since time.Time) ([]*models.ProviderStats, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	now := r.s.now()
+	byProvider := make(map[string]*models.ProviderStats)
+	get := func(provider string) *models.ProviderStats {
+		if _, ok := byProvider[provider]; !ok {
+			byProvider[provider] = &models.ProviderStats{Provider: provider}
+		}
+		return byProvider[provider]
+	}
+
+	staleness := make(map[string]float64)
+	for _, offer := range r.s.offers {
+		if offer.Delisted() {
+			continue
+		}
+		stats := get(offer.Source)
+		stats.OffersCount++
+		if stats.NewestFetchedAt == nil || offer.FetchedAt.After(*stats.NewestFetchedAt) {
+			fetchedAt := offer.FetchedAt
+			stats.NewestFetchedAt = &fetchedAt
+		}
+		staleness[offer.Source] += now.Sub(offer.FetchedAt).Seconds()
+	}
+	for provider, total := range staleness {
+		stats := byProvider[provider]
+		avg := total / float64(stats.OffersCount)
+		stats.AvgStalenessSeconds = &avg
+	}
+
+	for _, fetch := range r.s.fetches {
+		if fetch.createdAt.Before(since) {
+			continue
+		}
+		stats := get(fetch.provider)
+		stats.Requests++
+		stats.Results += fetch.results
+		if !fetch.success {
+			stats.Errors++
+			if stats.LastErrorAt == nil || !fetch.createdAt.Before(*stats.LastErrorAt) {
+				createdAt := fetch.createdAt
+				stats.LastErrorAt = &createdAt
+				stats.LastError = fetch.err
+			}
+		}
+	}
+
+	result := make([]*models.ProviderStats, 0, len(byProvider))
+	for _, stats := range byProvider {
+		if stats.Requests > 0 {
+			rate := float64(stats.Errors) / float64(stats.Requests)
+			stats.ErrorRate = &rate
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Provider < result[j].Provider })
+	return result, nil
+}
+
+type priceChanges struct{ s *Store }
+
+func (r priceChanges) Record(ctx context.Context, change *models.OfferPriceChange) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.priceChangeSeq++
+	change.ID = r.s.priceChangeSeq
+	change.ChangedAt = r.s.now()
+	r.s.priceChanges = append(r.s.priceChanges, clone(change))
+	return nil
+}
+
+// matchingLocked returns the changes since since that moved the US total by at least
+// minPercent, with ChangePercent and ProductTitle set
+func (r priceChanges) matchingLocked(since time.Time, minPercent float64) []*models.OfferPriceChange {
+	changes := []*models.OfferPriceChange{}
+	for _, change := range r.s.priceChanges {
+		if change.ChangedAt.Before(since) || change.OldTotalToUSAmount <= 0 {
+			continue
+		}
+		c := clone(change)
+		c.ChangePercent = models.PriceChangePercent(c.OldTotalToUSAmount, c.NewTotalToUSAmount)
+		if math.Abs(c.ChangePercent) < minPercent {
+			continue
+		}
+		if product, ok := r.s.products[c.ProductID]; ok {
+			c.ProductTitle = product.Title
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+func (r priceChanges) CountSince(ctx context.Context, since time.Time, minPercent float64) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return len(r.matchingLocked(since, minPercent)), nil
+}
+
+func (r priceChanges) ListLargestSince(ctx context.Context, since time.Time, minPercent float64, limit int) ([]*models.OfferPriceChange, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	changes := r.matchingLocked(since, minPercent)
+	sort.SliceStable(changes, func(i, j int) bool {
+		if a, b := math.Abs(changes[i].ChangePercent), math.Abs(changes[j].ChangePercent); a != b {
+			return a > b
+		}
+		return changes[i].ChangedAt.After(changes[j].ChangedAt)
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+func (r priceChanges) ListDropsSince(ctx context.Context, since time.Time, minDropPercent float64, limit int) ([]*models.PriceDrop, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// The first change of each offer since since has its total before the window
+	first := make(map[uuid.UUID]*models.OfferPriceChange)
+	latest := make(map[uuid.UUID]time.Time)
+	for _, change := range r.s.priceChanges {
+		if change.ChangedAt.Before(since) {
+			continue
+		}
+		if f, ok := first[change.OfferID]; !ok || change.ChangedAt.Before(f.ChangedAt) {
+			first[change.OfferID] = change
+		}
+		if change.ChangedAt.After(latest[change.OfferID]) {
+			latest[change.OfferID] = change.ChangedAt
+		}
+	}
+
+	byProduct := make(map[uuid.UUID]*models.PriceDrop)
+	for offerID, change := range first {
+		offer, ok := r.s.offers[offerID]
+		if !ok || !r.s.publishedLocked(offer) || change.OldTotalToUSAmount <= 0 {
+			continue
+		}
+		product, ok := r.s.products[offer.ProductID]
+		if !ok {
+			continue
+		}
+		percent := -models.PriceChangePercent(change.OldTotalToUSAmount, offer.TotalToUSAmount)
+		if percent < minDropPercent {
+			continue
+		}
+		if best, ok := byProduct[offer.ProductID]; ok && best.DropPercent >= percent {
+			continue
+		}
+		product, offer = clone(product), clone(offer)
+		byProduct[offer.ProductID] = &models.PriceDrop{
+			ProductID:          product.ID,
+			ProductTitle:       product.Title,
+			ImageURL:           product.ImageURL,
+			OfferID:            offer.ID,
+			Source:             offer.Source,
+			Seller:             offer.Seller,
+			URL:                offer.URL,
+			OldTotalToUSAmount: change.OldTotalToUSAmount,
+			NewTotalToUSAmount: offer.TotalToUSAmount,
+			DropPercent:        percent,
+			ChangedAt:          latest[offerID],
+		}
+	}
+
+	drops := make([]*models.PriceDrop, 0, len(byProduct))
+	for _, drop := range byProduct {
+		drops = append(drops, drop)
+	}
+	sort.Slice(drops, func(i, j int) bool {
+		if drops[i].DropPercent != drops[j].DropPercent {
+			return drops[i].DropPercent > drops[j].DropPercent
+		}
+		return drops[i].ChangedAt.After(drops[j].ChangedAt)
+	})
+	if len(drops) > limit {
+		drops = drops[:limit]
+	}
+	return drops, nil
+}
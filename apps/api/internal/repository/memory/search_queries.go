@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+	"github.com/pricecompare/api/internal/repository"
+)
+
+type searchQueries struct{ s *Store }
+
+// existsLocked reports whether another query of the provider has the same text
+// (UNIQUE (provider, query))
+func (r searchQueries) existsLocked(query *models.SearchQuery) bool {
+	for _, existing := range r.s.searchQueries {
+		if existing.ID != query.ID && existing.Provider == query.Provider && existing.Query == query.Query {
+			return true
+		}
+	}
+	return false
+}
+
+func (r searchQueries) Create(ctx context.Context, query *models.SearchQuery) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	query.ID = uuid.New()
+	if r.existsLocked(query) {
+		return repository.ErrSearchQueryExists
+	}
+	query.CreatedAt = r.s.now()
+	query.UpdatedAt = query.CreatedAt
+	r.s.searchQueries[query.ID] = clone(query)
+	return nil
+}
+
+func (r searchQueries) GetByID(ctx context.Context, id uuid.UUID) (*models.SearchQuery, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	query, ok := r.s.searchQueries[id]
+	if !ok {
+		return nil, nil
+	}
+	return clone(query), nil
+}
+
+func (r searchQueries) List(ctx context.Context, provider string) ([]*models.SearchQuery, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	queries := []*models.SearchQuery{}
+	for _, query := range r.s.searchQueries {
+		if provider == "" || query.Provider == provider {
+			queries = append(queries, clone(query))
+		}
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		a, b := queries[i], queries[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return queries, nil
+}
+
+func (r searchQueries) Update(ctx context.Context, query *models.SearchQuery) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	existing, ok := r.s.searchQueries[query.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	query.Provider = existing.Provider
+	if r.existsLocked(query) {
+		return repository.ErrSearchQueryExists
+	}
+	existing.Query = query.Query
+	existing.Priority = query.Priority
+	existing.Enabled = query.Enabled
+	existing.UpdatedAt = r.s.now()
+	query.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r searchQueries) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.searchQueries[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.s.searchQueries, id)
+	return nil
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type stockEvents struct{ s *Store }
+
+func (r stockEvents) Record(ctx context.Context, event *models.StockEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.stockEventSeq++
+	event.ID = r.s.stockEventSeq
+	event.OccurredAt = r.s.now()
+	r.s.stockEvents = append(r.s.stockEvents, clone(event))
+	return nil
+}
+
+func (r stockEvents) ListByProductID(ctx context.Context, productID uuid.UUID, since time.Time, limit int) ([]*models.StockEvent, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Events are appended in order, so walking backwards lists the newest first
+	result := []*models.StockEvent{}
+	for i := len(r.s.stockEvents) - 1; i >= 0 && len(result) < limit; i-- {
+		event := r.s.stockEvents[i]
+		if event.ProductID == productID && !event.OccurredAt.Before(since) {
+			result = append(result, clone(event))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// ErrMergeCandidateResolved is returned when merging or dismissing a suggestion that is no longer pending
+var ErrMergeCandidateResolved = errors.New("merge candidate is already resolved")
+
+// gtinIdentifierTypes are barcode identifier types that share one number space,
+// so e.g. a UPC and an EAN with the same digits refer to the same item
+var gtinIdentifierTypes = []string{"UPC", "EAN", "JAN", "GTIN"}
+
+const mergeCandidateColumns = `
+	id, product_id, duplicate_product_id, reason, score, detail, status, resolved_at,
+	created_at, updated_at
+`
+
+type MergeCandidateRepository struct {
+	db *DB
+}
+
+func NewMergeCandidateRepository(db *DB) *MergeCandidateRepository {
+	return &MergeCandidateRepository{db: db}
+}
+
+func scanMergeCandidate(row rowScanner) (*models.MergeCandidate, error) {
+	var candidate models.MergeCandidate
+	if err := row.Scan(
+		&candidate.ID,
+		&candidate.ProductID,
+		&candidate.DuplicateProductID,
+		&candidate.Reason,
+		&candidate.Score,
+		&candidate.Detail,
+		&candidate.Status,
+		&candidate.ResolvedAt,
+		&candidate.CreatedAt,
+		&candidate.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &candidate, nil
+}
+
+// FindIdentifierDuplicates returns pairs of products that share an identifier value
+// (ignoring case and leading zeros). The older product is kept.
+func (r *MergeCandidateRepository) FindIdentifierDuplicates(ctx context.Context) ([]*models.MergeCandidate, error) {
+	query := `
+		SELECT DISTINCT ON (pa.id, pb.id) pa.id, pb.id, a.type || ':' || a.value
+		FROM product_identifiers a
+		JOIN product_identifiers b
+		  ON a.product_id <> b.product_id
+		 AND ltrim(upper(a.value), '0') = ltrim(upper(b.value), '0')
+		 AND (upper(a.type) = upper(b.type) OR (upper(a.type) = ANY($1) AND upper(b.type) = ANY($1)))
+		JOIN products pa ON pa.id = a.product_id
+		JOIN products pb ON pb.id = b.product_id
+		WHERE (pa.created_at, pa.id) < (pb.created_at, pb.id)
+		ORDER BY pa.id, pb.id
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(gtinIdentifierTypes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*models.MergeCandidate{}
+	for rows.Next() {
+		candidate := models.MergeCandidate{Reason: models.MergeReasonIdentifier, Score: 1}
+		var detail string
+		if err := rows.Scan(&candidate.ProductID, &candidate.DuplicateProductID, &detail); err != nil {
+			return nil, err
+		}
+		candidate.Detail = &detail
+		candidates = append(candidates, &candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// FindTitleDuplicates returns pairs of products of the same brand whose titles have a
+// trigram similarity of at least threshold. The older product is kept.
+func (r *MergeCandidateRepository) FindTitleDuplicates(ctx context.Context, threshold float64, limit int) ([]*models.MergeCandidate, error) {
+	query := `
+		SELECT a.id, b.id, similarity(a.title, b.title) AS score
+		FROM products a
+		JOIN products b
+		  ON a.title % b.title
+		 AND lower(a.brand) = lower(b.brand)
+		WHERE (a.created_at, a.id) < (b.created_at, b.id)
+		  AND similarity(a.title, b.title) >= $1
+		ORDER BY score DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*models.MergeCandidate{}
+	for rows.Next() {
+		candidate := models.MergeCandidate{Reason: models.MergeReasonTitle}
+		if err := rows.Scan(&candidate.ProductID, &candidate.DuplicateProductID, &candidate.Score); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// UpsertPending stores a suggestion and sets candidate.ID to the stored row. Existing
+// pending suggestions for the same pair are refreshed; for merged or dismissed ones
+// nothing is changed and ErrMergeCandidateResolved is returned.
+func (r *MergeCandidateRepository) UpsertPending(ctx context.Context, candidate *models.MergeCandidate) error {
+	query := `
+		INSERT INTO merge_candidates (
+			id, product_id, duplicate_product_id, reason, score, detail, status,
+			created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (product_id, duplicate_product_id)
+		DO UPDATE SET
+			reason = EXCLUDED.reason,
+			score = EXCLUDED.score,
+			detail = EXCLUDED.detail,
+			updated_at = EXCLUDED.updated_at
+		WHERE merge_candidates.status = 'pending'
+		RETURNING id
+	`
+	now := time.Now()
+	if candidate.ID == uuid.Nil {
+		candidate.ID = uuid.New()
+	}
+	candidate.Status = models.MergeStatusPending
+	candidate.CreatedAt = now
+	candidate.UpdatedAt = now
+
+	err := r.db.QueryRowContext(ctx, query,
+		candidate.ID,
+		candidate.ProductID,
+		candidate.DuplicateProductID,
+		candidate.Reason,
+		candidate.Score,
+		candidate.Detail,
+		candidate.Status,
+		candidate.CreatedAt,
+		candidate.UpdatedAt,
+	).Scan(&candidate.ID)
+	if err == sql.ErrNoRows {
+		return ErrMergeCandidateResolved
+	}
+	return err
+}
+
+func (r *MergeCandidateRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.MergeCandidate, error) {
+	query := `SELECT ` + mergeCandidateColumns + ` FROM merge_candidates WHERE id = $1`
+	candidate, err := scanMergeCandidate(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return candidate, nil
+}
+
+// ListByStatus returns suggestions with the given status, highest score first
+func (r *MergeCandidateRepository) ListByStatus(ctx context.Context, status string, limit int) ([]*models.MergeCandidate, error) {
+	query := `
+		SELECT ` + mergeCandidateColumns + `
+		FROM merge_candidates
+		WHERE status = $1
+		ORDER BY score DESC, created_at ASC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*models.MergeCandidate{}
+	for rows.Next() {
+		candidate, err := scanMergeCandidate(rows)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// Dismiss marks a pending suggestion as dismissed so it is not suggested again.
+// It returns sql.ErrNoRows if the suggestion does not exist.
+func (r *MergeCandidateRepository) Dismiss(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE merge_candidates
+		SET status = $2, resolved_at = $3, updated_at = $3
+		WHERE id = $1 AND status = 'pending'
+	`
+	result, err := r.db.ExecContext(ctx, query, id, models.MergeStatusDismissed, time.Now())
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM merge_candidates WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+		return ErrMergeCandidateResolved
+	}
+	return nil
+}
+
+// Merge moves offers, identifiers, source products, image hashes and revisions of the
+// duplicate product to the kept product and deletes the duplicate, all in a single
+// transaction.
+// Offers that would collide with an existing offer of the kept product are dropped with
+// the duplicate.
+// It returns sql.ErrNoRows if the suggestion does not exist.
+func (r *MergeCandidateRepository) Merge(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var productID, duplicateID uuid.UUID
+	var status string
+	err = tx.QueryRowContext(ctx,
+		`SELECT product_id, duplicate_product_id, status FROM merge_candidates WHERE id = $1 FOR UPDATE`,
+		id,
+	).Scan(&productID, &duplicateID, &status)
+	if err != nil {
+		return err
+	}
+	if status != models.MergeStatusPending {
+		return ErrMergeCandidateResolved
+	}
+
+	statements := []string{
+		`UPDATE offers o SET product_id = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE o.product_id = $2
+		   AND NOT EXISTS (
+			SELECT 1 FROM offers k
+			WHERE k.product_id = $1 AND k.source = o.source AND k.seller = o.seller
+			  AND COALESCE(k.url, '') = COALESCE(o.url, '')
+		   )`,
+		`UPDATE product_identifiers SET product_id = $1, updated_at = CURRENT_TIMESTAMP WHERE product_id = $2`,
+		`UPDATE source_products SET product_id = $1, updated_at = CURRENT_TIMESTAMP WHERE product_id = $2`,
+		`UPDATE offer_price_changes SET product_id = $1 WHERE product_id = $2`,
+		`UPDATE stock_events SET product_id = $1 WHERE product_id = $2`,
+		`UPDATE price_alerts SET product_id = $1, updated_at = CURRENT_TIMESTAMP WHERE product_id = $2`,
+		`UPDATE product_revisions SET product_id = $1, merged_from_product_id = COALESCE(merged_from_product_id, $2)
+		 WHERE product_id = $2`,
+		`INSERT INTO product_tags (product_id, tag, created_at)
+		 SELECT $1, tag, created_at FROM product_tags WHERE product_id = $2
+		 ON CONFLICT (product_id, tag) DO NOTHING`,
+		`UPDATE product_images i SET product_id = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE i.product_id = $2
+		   AND NOT EXISTS (SELECT 1 FROM product_images k WHERE k.product_id = $1 AND k.image_url = i.image_url)`,
+		// Other pending suggestions involving the duplicate are obsolete once it is gone
+		`DELETE FROM merge_candidates
+		 WHERE status = 'pending' AND (product_id = $2 OR duplicate_product_id = $2)
+		   AND NOT (product_id = $1 AND duplicate_product_id = $2)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, productID, duplicateID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE merge_candidates SET status = $2, resolved_at = $3, updated_at = $3 WHERE id = $1`,
+		id, models.MergeStatusMerged, now,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = $1`, duplicateID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
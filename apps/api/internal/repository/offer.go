@@ -1,12 +1,47 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pricecompare/api/internal/models"
 )
 
+// ErrOfferExists is returned when a product already has an offer of the same source,
+// seller and URL
+var ErrOfferExists = errors.New("offer already exists")
+
+// offerPublished is the condition of offers shown by the offer and catalog endpoints
+const offerPublished = `quarantine_reason IS NULL AND delisted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+
+// offerColumns is the column list shared by offer INSERTs and SELECTs.
+// Keep it in sync with offerValues and scanOffer.
+const offerColumns = `
+	id, product_id, source, seller, price_amount, currency,
+	shipping_to_us_amount, total_to_us_amount,
+	est_delivery_days_min, est_delivery_days_max, in_stock, url, fetched_at,
+	fee_amount, tax_amount, availability_status, estimated_delivery_date, price_updated_at,
+	ships_from_country, duty_amount, landed_cost_amount, free_shipping, fee_items,
+	cost_breakdown, created_at, updated_at, quarantine_reason,
+	first_seen_at, last_seen_at, delisted_at, notes, expires_at, seller_rating,
+	canonical_url
+`
+
+const offerPlaceholders = `
+	$1, $2, $3, $4, $5, $6, $7, $8,
+	$9, $10, $11, $12, $13,
+	$14, $15, $16, $17, $18,
+	$19, $20, $21, $22, $23,
+	$24, $25, $26, $27,
+	$28, $29, $30, $31, $32, $33,
+	$34
+`
+
 type OfferRepository struct {
 	db *DB
 }
@@ -15,30 +50,13 @@ func NewOfferRepository(db *DB) *OfferRepository {
 	return &OfferRepository{db: db}
 }
 
-func (r *OfferRepository) Create(offer *models.Offer) error {
-	query := `
-		INSERT INTO offers (
-			id, product_id, source, seller, price_amount, currency,
-			shipping_to_us_amount, total_to_us_amount,
-			est_delivery_days_min, est_delivery_days_max, in_stock, url, fetched_at,
-			fee_amount, tax_amount, availability_status, estimated_delivery_date, price_updated_at,
-			created_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
-		        $9, $10, $11, $12, $13,
-		        $14, $15, $16, $17, $18,
-		        $19, $20)
-	`
-	now := time.Now()
-	offer.ID = uuid.New()
-	offer.FetchedAt = now
-	if offer.PriceUpdatedAt.IsZero() {
-		offer.PriceUpdatedAt = now
-	}
-	offer.CreatedAt = now
-	offer.UpdatedAt = now
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	_, err := r.db.Exec(query,
+func offerValues(offer *models.Offer) []any {
+	return []any{
 		offer.ID,
 		offer.ProductID,
 		offer.Source,
@@ -57,30 +75,149 @@ func (r *OfferRepository) Create(offer *models.Offer) error {
 		offer.AvailabilityStatus,
 		offer.EstimatedDelivery,
 		offer.PriceUpdatedAt,
+		offer.ShipsFromCountry,
+		offer.DutyAmount,
+		offer.LandedCostAmount,
+		offer.FreeShipping,
+		offer.FeeItems,
+		offer.CostBreakdown,
 		offer.CreatedAt,
 		offer.UpdatedAt,
-	)
+		offer.QuarantineReason,
+		offer.FirstSeenAt,
+		offer.LastSeenAt,
+		offer.DelistedAt,
+		offer.Notes,
+		offer.ExpiresAt,
+		offer.SellerRating,
+		offer.CanonicalURL,
+	}
+}
+
+func scanOffer(row rowScanner) (*models.Offer, error) {
+	var offer models.Offer
+	if err := row.Scan(
+		&offer.ID,
+		&offer.ProductID,
+		&offer.Source,
+		&offer.Seller,
+		&offer.PriceAmount,
+		&offer.Currency,
+		&offer.ShippingToUSAmount,
+		&offer.TotalToUSAmount,
+		&offer.EstDeliveryDaysMin,
+		&offer.EstDeliveryDaysMax,
+		&offer.InStock,
+		&offer.URL,
+		&offer.FetchedAt,
+		&offer.FeeAmount,
+		&offer.TaxAmount,
+		&offer.AvailabilityStatus,
+		&offer.EstimatedDelivery,
+		&offer.PriceUpdatedAt,
+		&offer.ShipsFromCountry,
+		&offer.DutyAmount,
+		&offer.LandedCostAmount,
+		&offer.FreeShipping,
+		&offer.FeeItems,
+		&offer.CostBreakdown,
+		&offer.CreatedAt,
+		&offer.UpdatedAt,
+		&offer.QuarantineReason,
+		&offer.FirstSeenAt,
+		&offer.LastSeenAt,
+		&offer.DelistedAt,
+		&offer.Notes,
+		&offer.ExpiresAt,
+		&offer.SellerRating,
+		&offer.CanonicalURL,
+	); err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+func (r *OfferRepository) Create(ctx context.Context, offer *models.Offer) error {
+	query := `INSERT INTO offers (` + offerColumns + `) VALUES (` + offerPlaceholders + `)`
+	now := time.Now()
+	offer.ID = uuid.New()
+	offer.FetchedAt = now
+	if offer.PriceUpdatedAt.IsZero() {
+		offer.PriceUpdatedAt = now
+	}
+	offer.CreatedAt = now
+	offer.UpdatedAt = now
+	offer.FirstSeenAt = now
+	offer.LastSeenAt = now
+
+	_, err := r.db.ExecContext(ctx, query, offerValues(offer)...)
+	if isUniqueViolation(err) {
+		return ErrOfferExists
+	}
 	return err
 }
 
-func (r *OfferRepository) GetByProductID(productID uuid.UUID) ([]*models.Offer, error) {
-	return r.GetByProductIDWithSort(productID, "total")
+func (r *OfferRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Offer, error) {
+	offer, err := scanOffer(r.db.QueryRowContext(ctx, `SELECT `+offerColumns+` FROM offers WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return offer, err
+}
+
+// Update saves the seller, URL, price, delivery, stock, totals, notes and expiry of an
+// offer, as edited by an admin
+func (r *OfferRepository) Update(ctx context.Context, offer *models.Offer) error {
+	offer.UpdatedAt = time.Now()
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE offers SET
+			seller = $2, url = $3, price_amount = $4, currency = $5,
+			shipping_to_us_amount = $6, total_to_us_amount = $7,
+			est_delivery_days_min = $8, est_delivery_days_max = $9, in_stock = $10,
+			fee_amount = $11, ships_from_country = $12, duty_amount = $13, landed_cost_amount = $14,
+			free_shipping = $15, fee_items = $16, cost_breakdown = $17, price_updated_at = $18,
+			notes = $19, expires_at = $20, updated_at = $21, canonical_url = $22
+		WHERE id = $1`,
+		offer.ID, offer.Seller, offer.URL, offer.PriceAmount, offer.Currency,
+		offer.ShippingToUSAmount, offer.TotalToUSAmount,
+		offer.EstDeliveryDaysMin, offer.EstDeliveryDaysMax, offer.InStock,
+		offer.FeeAmount, offer.ShipsFromCountry, offer.DutyAmount, offer.LandedCostAmount,
+		offer.FreeShipping, offer.FeeItems, offer.CostBreakdown, offer.PriceUpdatedAt,
+		offer.Notes, offer.ExpiresAt, offer.UpdatedAt, offer.CanonicalURL,
+	)
+	if isUniqueViolation(err) {
+		return ErrOfferExists
+	}
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *OfferRepository) GetByProductID(ctx context.Context, productID uuid.UUID) ([]*models.Offer, error) {
+	return r.GetByProductIDWithSort(ctx, productID, "total")
 }
 
 // GetByProductIDWithSort returns offers for a product with a specific sort key.
 // Supported sort keys:
 // - "total": sort by total_to_us_amount ASC, then price_updated_at DESC
+// - "landed_cost": sort by landed_cost_amount ASC (total + estimated import duty)
 // - "fastest": sort by estimated delivery days ASC, then total_to_us_amount ASC
 // - "newest": sort by price_updated_at DESC
 // - "in_stock": in-stock offers first, then cheapest
-func (r *OfferRepository) GetByProductIDWithSort(productID uuid.UUID, sortKey string) ([]*models.Offer, error) {
+// Quarantined, delisted and expired offers are not returned.
+func (r *OfferRepository) GetByProductIDWithSort(ctx context.Context, productID uuid.UUID, sortKey string) ([]*models.Offer, error) {
 	orderBy := `
 		ORDER BY total_to_us_amount ASC, price_updated_at DESC
 	`
 	switch sortKey {
+	case "landed_cost":
+		orderBy = `
+			ORDER BY landed_cost_amount ASC, total_to_us_amount ASC
+		`
 	case "fastest":
 		orderBy = `
-			ORDER BY 
+			ORDER BY
 				COALESCE(est_delivery_days_min, est_delivery_days_max, 9999) ASC,
 				total_to_us_amount ASC
 		`
@@ -95,15 +232,11 @@ func (r *OfferRepository) GetByProductIDWithSort(productID uuid.UUID, sortKey st
 	}
 
 	query := `
-		SELECT id, product_id, source, seller, price_amount, currency,
-		       shipping_to_us_amount, total_to_us_amount,
-		       est_delivery_days_min, est_delivery_days_max, in_stock, url, fetched_at,
-		       fee_amount, tax_amount, availability_status, estimated_delivery_date, price_updated_at,
-		       created_at, updated_at
+		SELECT ` + offerColumns + `
 		FROM offers
-		WHERE product_id = $1
+		WHERE product_id = $1 AND ` + offerPublished + `
 	` + orderBy
-	rows, err := r.db.Query(query, productID)
+	rows, err := r.db.QueryContext(ctx, query, productID)
 	if err != nil {
 		return nil, err
 	}
@@ -112,50 +245,23 @@ func (r *OfferRepository) GetByProductIDWithSort(productID uuid.UUID, sortKey st
 	// Always return an empty slice instead of nil so that JSON encodes [] (not null).
 	offers := make([]*models.Offer, 0)
 	for rows.Next() {
-		var offer models.Offer
-		if err := rows.Scan(
-			&offer.ID,
-			&offer.ProductID,
-			&offer.Source,
-			&offer.Seller,
-			&offer.PriceAmount,
-			&offer.Currency,
-			&offer.ShippingToUSAmount,
-			&offer.TotalToUSAmount,
-			&offer.EstDeliveryDaysMin,
-			&offer.EstDeliveryDaysMax,
-			&offer.InStock,
-			&offer.URL,
-			&offer.FetchedAt,
-			&offer.FeeAmount,
-			&offer.TaxAmount,
-			&offer.AvailabilityStatus,
-			&offer.EstimatedDelivery,
-			&offer.PriceUpdatedAt,
-			&offer.CreatedAt,
-			&offer.UpdatedAt,
-		); err != nil {
+		offer, err := scanOffer(rows)
+		if err != nil {
 			return nil, err
 		}
-		offers = append(offers, &offer)
+		offers = append(offers, offer)
 	}
 	return offers, rows.Err()
 }
 
-func (r *OfferRepository) Upsert(offer *models.Offer) error {
+// Upsert inserts an offer or refreshes the one with the same product, source, seller and
+// URL. A refreshed offer keeps its first_seen_at, is seen now and is relisted if it was
+// delisted.
+func (r *OfferRepository) Upsert(ctx context.Context, offer *models.Offer) error {
 	query := `
-		INSERT INTO offers (
-			id, product_id, source, seller, price_amount, currency,
-			shipping_to_us_amount, total_to_us_amount,
-			est_delivery_days_min, est_delivery_days_max, in_stock, url, fetched_at,
-			fee_amount, tax_amount, availability_status, estimated_delivery_date, price_updated_at,
-			created_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
-		        $9, $10, $11, $12, $13,
-		        $14, $15, $16, $17, $18,
-		        $19, $20)
-		ON CONFLICT (product_id, source, seller, COALESCE(url, '')) 
+		INSERT INTO offers (` + offerColumns + `)
+		VALUES (` + offerPlaceholders + `)
+		ON CONFLICT (product_id, source, seller, COALESCE(url, ''))
 		DO UPDATE SET
 			price_amount = EXCLUDED.price_amount,
 			shipping_to_us_amount = EXCLUDED.shipping_to_us_amount,
@@ -169,8 +275,19 @@ func (r *OfferRepository) Upsert(offer *models.Offer) error {
 			availability_status = EXCLUDED.availability_status,
 			estimated_delivery_date = EXCLUDED.estimated_delivery_date,
 			price_updated_at = EXCLUDED.price_updated_at,
-			updated_at = EXCLUDED.updated_at
-		RETURNING id
+			ships_from_country = EXCLUDED.ships_from_country,
+			duty_amount = EXCLUDED.duty_amount,
+			landed_cost_amount = EXCLUDED.landed_cost_amount,
+			free_shipping = EXCLUDED.free_shipping,
+			fee_items = EXCLUDED.fee_items,
+			cost_breakdown = EXCLUDED.cost_breakdown,
+			updated_at = EXCLUDED.updated_at,
+			quarantine_reason = EXCLUDED.quarantine_reason,
+			last_seen_at = EXCLUDED.last_seen_at,
+			seller_rating = EXCLUDED.seller_rating,
+			canonical_url = EXCLUDED.canonical_url,
+			delisted_at = NULL
+		RETURNING id, first_seen_at
 	`
 	now := time.Now()
 	if offer.ID == uuid.Nil {
@@ -184,35 +301,231 @@ func (r *OfferRepository) Upsert(offer *models.Offer) error {
 	if offer.CreatedAt.IsZero() {
 		offer.CreatedAt = now
 	}
+	if offer.FirstSeenAt.IsZero() {
+		offer.FirstSeenAt = now
+	}
+	offer.LastSeenAt = now
+	offer.DelistedAt = nil
 
-	err := r.db.QueryRow(query,
-		offer.ID,
-		offer.ProductID,
-		offer.Source,
-		offer.Seller,
-		offer.PriceAmount,
-		offer.Currency,
-		offer.ShippingToUSAmount,
-		offer.TotalToUSAmount,
-		offer.EstDeliveryDaysMin,
-		offer.EstDeliveryDaysMax,
-		offer.InStock,
-		offer.URL,
-		offer.FetchedAt,
-		offer.FeeAmount,
-		offer.TaxAmount,
-		offer.AvailabilityStatus,
-		offer.EstimatedDelivery,
-		offer.PriceUpdatedAt,
-		offer.CreatedAt,
-		offer.UpdatedAt,
-	).Scan(&offer.ID)
-	return err
+	return r.db.QueryRowContext(ctx, query, offerValues(offer)...).Scan(&offer.ID, &offer.FirstSeenAt)
 }
 
-func (r *OfferRepository) DeleteByProductIDAndSource(productID uuid.UUID, source string) error {
-	query := `DELETE FROM offers WHERE product_id = $1 AND source = $2`
-	_, err := r.db.Exec(query, productID, source)
-	return err
+// GetByProductIDAndSource returns the offers of one source for a product, including
+// quarantined and delisted ones
+func (r *OfferRepository) GetByProductIDAndSource(ctx context.Context, productID uuid.UUID, source string) ([]*models.Offer, error) {
+	query := `
+		SELECT ` + offerColumns + `
+		FROM offers
+		WHERE product_id = $1 AND source = $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, productID, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var offers []*models.Offer
+	for rows.Next() {
+		offer, err := scanOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	return offers, rows.Err()
+}
+
+// MarkDelisted marks the listed offers of one source for a product that were last seen
+// before seenBefore as delisted at seenBefore, and returns how many were delisted
+func (r *OfferRepository) MarkDelisted(ctx context.Context, productID uuid.UUID, source string, seenBefore time.Time) (int64, error) {
+	query := `
+		UPDATE offers
+		SET delisted_at = $3, updated_at = $3
+		WHERE product_id = $1 AND source = $2 AND delisted_at IS NULL AND last_seen_at < $3
+	`
+	result, err := r.db.ExecContext(ctx, query, productID, source, seenBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
+// DeleteExpired deletes the offers of source that expired before before, and returns
+// the product of each deleted offer. Their shipping options are deleted with them.
+func (r *OfferRepository) DeleteExpired(ctx context.Context, source string, before time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`DELETE FROM offers WHERE source = $1 AND expires_at < $2 RETURNING product_id`, source, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	productIDs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var productID uuid.UUID
+		if err := rows.Scan(&productID); err != nil {
+			return nil, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	return productIDs, rows.Err()
+}
+
+// GetCheapestBySource returns the cheapest published offer of each source for each of
+// the products, ordered by product and source
+func (r *OfferRepository) GetCheapestBySource(ctx context.Context, productIDs []uuid.UUID) ([]*models.Offer, error) {
+	offers := make([]*models.Offer, 0)
+	if len(productIDs) == 0 {
+		return offers, nil
+	}
+	query := `
+		SELECT DISTINCT ON (product_id, source) ` + offerColumns + `
+		FROM offers
+		WHERE product_id = ANY($1::uuid[]) AND ` + offerPublished + `
+		ORDER BY product_id, source, total_to_us_amount ASC, price_updated_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(uuidStrings(productIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		offer, err := scanOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	return offers, rows.Err()
+}
+
+// GetPageByProductID returns one page of a product's published offers, cheapest first
+// like the "total" sort, and the total number of offers. With includeDelisted, delisted
+// offers follow the listed ones, most recently delisted first.
+func (r *OfferRepository) GetPageByProductID(ctx context.Context, productID uuid.UUID, includeDelisted bool, limit, offset int) ([]*models.Offer, int, error) {
+	where := `WHERE product_id = $1 AND quarantine_reason IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+	if !includeDelisted {
+		where += ` AND delisted_at IS NULL`
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM offers `+where, productID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + offerColumns + `
+		FROM offers
+	` + where + `
+		ORDER BY delisted_at IS NOT NULL, delisted_at DESC, total_to_us_amount ASC, price_updated_at DESC, id
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, productID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	offers := make([]*models.Offer, 0)
+	for rows.Next() {
+		offer, err := scanOffer(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		offers = append(offers, offer)
+	}
+	return offers, total, rows.Err()
+}
+
+// CountFetchedBefore counts listed offers per source last refreshed before before
+func (r *OfferRepository) CountFetchedBefore(ctx context.Context, before time.Time) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT source, COUNT(*) FROM offers WHERE fetched_at < $1 AND delisted_at IS NULL GROUP BY source`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var source string
+		var count int
+		if err := rows.Scan(&source, &count); err != nil {
+			return nil, err
+		}
+		counts[source] = count
+	}
+	return counts, rows.Err()
+}
+
+// CountListed counts listed offers per source
+func (r *OfferRepository) CountListed(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT source, COUNT(*) FROM offers WHERE delisted_at IS NULL GROUP BY source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var source string
+		var count int
+		if err := rows.Scan(&source, &count); err != nil {
+			return nil, err
+		}
+		counts[source] = count
+	}
+	return counts, rows.Err()
+}
+
+// ListQuarantined returns up to limit quarantined offers, most recently fetched first
+func (r *OfferRepository) ListQuarantined(ctx context.Context, limit int) ([]*models.Offer, error) {
+	query := `
+		SELECT ` + offerColumns + `
+		FROM offers
+		WHERE quarantine_reason IS NOT NULL
+		ORDER BY updated_at DESC
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offers := make([]*models.Offer, 0)
+	for rows.Next() {
+		offer, err := scanOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	return offers, rows.Err()
+}
+
+// PriceHistoryMedian returns the median US total (cents) of a product's price history
+// and the number of prices it was computed from: the published offers plus the prices
+// that offer_price_changes recorded as replaced since since. The median is 0 without
+// any price.
+func (r *OfferRepository) PriceHistoryMedian(ctx context.Context, productID uuid.UUID, since time.Time) (int, int, error) {
+	query := `
+		SELECT COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY amount), 0), COUNT(*)
+		FROM (
+			SELECT total_to_us_amount AS amount
+			FROM offers
+			WHERE product_id = $1 AND ` + offerPublished + `
+			UNION ALL
+			SELECT old_total_to_us_amount
+			FROM offer_price_changes
+			WHERE product_id = $1 AND changed_at >= $2
+		) prices
+	`
+	var median float64
+	var samples int
+	if err := r.db.QueryRowContext(ctx, query, productID, since).Scan(&median, &samples); err != nil {
+		return 0, 0, err
+	}
+	return int(math.Round(median)), samples, nil
+}
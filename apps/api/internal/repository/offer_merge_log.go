@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/models"
+)
+
+type OfferMergeLogRepository struct {
+	db *DB
+}
+
+func NewOfferMergeLogRepository(db *DB) *OfferMergeLogRepository {
+	return &OfferMergeLogRepository{db: db}
+}
+
+// Record stores one merge decision and sets its ID and MergedAt. A merge of the same
+// seller and URL into the same offer that is already logged is skipped and reported as
+// false.
+func (r *OfferMergeLogRepository) Record(ctx context.Context, merge *models.OfferMerge) (bool, error) {
+	merge.MergedAt = time.Now()
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO offer_merge_log (product_id, source, kept_offer_id, kept_seller, kept_url, merged_seller, merged_url, merged_price_amount, merged_currency, reason, merged_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (kept_offer_id, merged_seller, (COALESCE(merged_url, ''))) DO NOTHING
+		RETURNING id`,
+		merge.ProductID, merge.Source, merge.KeptOfferID, merge.KeptSeller, merge.KeptURL, merge.MergedSeller, merge.MergedURL,
+		merge.MergedPriceAmount, merge.MergedCurrency, merge.Reason, merge.MergedAt,
+	).Scan(&merge.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListByProductID returns the merge decisions of a product, newest first
+func (r *OfferMergeLogRepository) ListByProductID(ctx context.Context, productID uuid.UUID, limit int) ([]*models.OfferMerge, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, product_id, source, kept_offer_id, kept_seller, kept_url, merged_seller, merged_url, merged_price_amount, merged_currency, reason, merged_at
+		FROM offer_merge_log
+		WHERE product_id = $1
+		ORDER BY merged_at DESC, id DESC
+		LIMIT $2`,
+		productID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	merges := []*models.OfferMerge{}
+	for rows.Next() {
+		var m models.OfferMerge
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.Source, &m.KeptOfferID, &m.KeptSeller, &m.KeptURL, &m.MergedSeller, &m.MergedURL,
+			&m.MergedPriceAmount, &m.MergedCurrency, &m.Reason, &m.MergedAt); err != nil {
+			return nil, err
+		}
+		merges = append(merges, &m)
+	}
+	return merges, rows.Err()
+}
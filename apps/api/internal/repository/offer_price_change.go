@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type OfferPriceChangeRepository struct {
+	db *DB
+}
+
+func NewOfferPriceChangeRepository(db *DB) *OfferPriceChangeRepository {
+	return &OfferPriceChangeRepository{db: db}
+}
+
+// Record stores one price change and sets its ID and ChangedAt
+func (r *OfferPriceChangeRepository) Record(ctx context.Context, change *models.OfferPriceChange) error {
+	change.ChangedAt = time.Now()
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO offer_price_changes (offer_id, product_id, source, seller, old_price_amount, new_price_amount,
+			currency, old_total_to_us_amount, new_total_to_us_amount, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		change.OfferID, change.ProductID, change.Source, change.Seller, change.OldPriceAmount, change.NewPriceAmount,
+		change.Currency, change.OldTotalToUSAmount, change.NewTotalToUSAmount, change.ChangedAt,
+	).Scan(&change.ID)
+}
+
+// changePercentSQL is models.PriceChangePercent on an offer_price_changes row
+const changePercentSQL = `(c.new_total_to_us_amount - c.old_total_to_us_amount) * 100.0 / c.old_total_to_us_amount`
+
+// CountSince counts changes since since whose US total moved by at least minPercent
+// either way
+func (r *OfferPriceChangeRepository) CountSince(ctx context.Context, since time.Time, minPercent float64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM offer_price_changes c
+		WHERE c.changed_at >= $1 AND c.old_total_to_us_amount > 0 AND ABS(`+changePercentSQL+`) >= $2`,
+		since, minPercent,
+	).Scan(&count)
+	return count, err
+}
+
+// ListLargestSince returns the changes since since that moved the US total by at least
+// minPercent, largest (absolute) first, with the product title
+func (r *OfferPriceChangeRepository) ListLargestSince(ctx context.Context, since time.Time, minPercent float64, limit int) ([]*models.OfferPriceChange, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT c.id, c.offer_id, c.product_id, c.source, c.seller, c.old_price_amount, c.new_price_amount,
+			c.currency, c.old_total_to_us_amount, c.new_total_to_us_amount, c.changed_at, p.title
+		FROM offer_price_changes c
+		JOIN products p ON p.id = c.product_id
+		WHERE c.changed_at >= $1 AND c.old_total_to_us_amount > 0 AND ABS(`+changePercentSQL+`) >= $2
+		ORDER BY ABS(`+changePercentSQL+`) DESC, c.changed_at DESC
+		LIMIT $3`,
+		since, minPercent, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*models.OfferPriceChange{}
+	for rows.Next() {
+		var c models.OfferPriceChange
+		if err := rows.Scan(
+			&c.ID, &c.OfferID, &c.ProductID, &c.Source, &c.Seller, &c.OldPriceAmount, &c.NewPriceAmount,
+			&c.Currency, &c.OldTotalToUSAmount, &c.NewTotalToUSAmount, &c.ChangedAt, &c.ProductTitle,
+		); err != nil {
+			return nil, err
+		}
+		c.ChangePercent = models.PriceChangePercent(c.OldTotalToUSAmount, c.NewTotalToUSAmount)
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
+
+// ListDropsSince returns the products whose published offers dropped the most since
+// since, largest drop first: each offer's US total before its first change since then
+// against its current total, the largest of a product's offers. Offers that went back up
+// by at least as much are not listed.
+func (r *OfferPriceChangeRepository) ListDropsSince(ctx context.Context, since time.Time, minDropPercent float64, limit int) ([]*models.PriceDrop, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT product_id, title, image_url, offer_id, source, seller, url, old_total, new_total, drop_percent, changed_at
+		FROM (
+			SELECT DISTINCT ON (o.product_id)
+				o.product_id, p.title, p.image_url, o.id AS offer_id, o.source, o.seller, o.url,
+				w.old_total, o.total_to_us_amount AS new_total,
+				(w.old_total - o.total_to_us_amount) * 100.0 / w.old_total AS drop_percent,
+				w.changed_at
+			FROM (
+				SELECT c.offer_id,
+					(array_agg(c.old_total_to_us_amount ORDER BY c.changed_at, c.id))[1] AS old_total,
+					MAX(c.changed_at) AS changed_at
+				FROM offer_price_changes c
+				WHERE c.changed_at >= $1
+				GROUP BY c.offer_id
+			) w
+			JOIN offers o ON o.id = w.offer_id
+			JOIN products p ON p.id = o.product_id
+			WHERE `+offerPublished+` AND w.old_total > 0
+			  AND (w.old_total - o.total_to_us_amount) * 100.0 / w.old_total >= $2
+			ORDER BY o.product_id, drop_percent DESC, w.changed_at DESC
+		) drops
+		ORDER BY drop_percent DESC, changed_at DESC
+		LIMIT $3`,
+		since, minDropPercent, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drops := []*models.PriceDrop{}
+	for rows.Next() {
+		var d models.PriceDrop
+		if err := rows.Scan(
+			&d.ProductID, &d.ProductTitle, &d.ImageURL, &d.OfferID, &d.Source, &d.Seller, &d.URL,
+			&d.OldTotalToUSAmount, &d.NewTotalToUSAmount, &d.DropPercent, &d.ChangedAt,
+		); err != nil {
+			return nil, err
+		}
+		drops = append(drops, &d)
+	}
+	return drops, rows.Err()
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pricecompare/api/internal/models"
+)
+
+type OfferShippingOptionRepository struct {
+	db *DB
+}
+
+func NewOfferShippingOptionRepository(db *DB) *OfferShippingOptionRepository {
+	return &OfferShippingOptionRepository{db: db}
+}
+
+// ReplaceForOffer replaces all shipping options of an offer in a single transaction
+func (r *OfferShippingOptionRepository) ReplaceForOffer(ctx context.Context, offerID uuid.UUID, options []*models.OfferShippingOption) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM offer_shipping_options WHERE offer_id = $1`, offerID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO offer_shipping_options (
+			id, offer_id, speed, cost_amount, est_delivery_days_min, est_delivery_days_max,
+			created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	now := time.Now()
+	for _, option := range options {
+		if option.ID == uuid.Nil {
+			option.ID = uuid.New()
+		}
+		option.OfferID = offerID
+		option.CreatedAt = now
+		option.UpdatedAt = now
+
+		if _, err := tx.ExecContext(ctx, query,
+			option.ID,
+			option.OfferID,
+			option.Speed,
+			option.CostAmount,
+			option.EstDeliveryDaysMin,
+			option.EstDeliveryDaysMax,
+			option.CreatedAt,
+			option.UpdatedAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetByOfferIDs returns shipping options grouped by offer ID
+func (r *OfferShippingOptionRepository) GetByOfferIDs(ctx context.Context, offerIDs []uuid.UUID) (map[uuid.UUID][]*models.OfferShippingOption, error) {
+	result := make(map[uuid.UUID][]*models.OfferShippingOption)
+	if len(offerIDs) == 0 {
+		return result, nil
+	}
+
+	ids := make([]string, 0, len(offerIDs))
+	for _, id := range offerIDs {
+		ids = append(ids, id.String())
+	}
+
+	query := `
+		SELECT id, offer_id, speed, cost_amount, est_delivery_days_min, est_delivery_days_max,
+		       created_at, updated_at
+		FROM offer_shipping_options
+		WHERE offer_id = ANY($1::uuid[])
+		ORDER BY cost_amount ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var option models.OfferShippingOption
+		if err := rows.Scan(
+			&option.ID,
+			&option.OfferID,
+			&option.Speed,
+			&option.CostAmount,
+			&option.EstDeliveryDaysMin,
+			&option.EstDeliveryDaysMax,
+			&option.CreatedAt,
+			&option.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result[option.OfferID] = append(result[option.OfferID], &option)
+	}
+	return result, rows.Err()
+}
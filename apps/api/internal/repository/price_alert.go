@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/models"
+)
+
+const priceAlertColumns = `
+	id, product_id, channel, target, target_price_cents, COALESCE(confirm_token, ''), confirmed_at,
+	triggered_at, triggered_cents, created_at, updated_at
+`
+
+type PriceAlertRepository struct {
+	db *DB
+}
+
+func NewPriceAlertRepository(db *DB) *PriceAlertRepository {
+	return &PriceAlertRepository{db: db}
+}
+
+func scanPriceAlert(row rowScanner) (*models.PriceAlert, error) {
+	var alert models.PriceAlert
+	if err := row.Scan(
+		&alert.ID,
+		&alert.ProductID,
+		&alert.Channel,
+		&alert.Target,
+		&alert.TargetPriceCents,
+		&alert.ConfirmToken,
+		&alert.ConfirmedAt,
+		&alert.TriggeredAt,
+		&alert.TriggeredCents,
+		&alert.CreatedAt,
+		&alert.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// Create stores a new alert and sets its ID and timestamps
+func (r *PriceAlertRepository) Create(ctx context.Context, alert *models.PriceAlert) error {
+	alert.ID = uuid.New()
+	alert.CreatedAt = time.Now()
+	alert.UpdatedAt = alert.CreatedAt
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO price_alerts (id, product_id, channel, target, target_price_cents, confirm_token, confirmed_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)`,
+		alert.ID, alert.ProductID, alert.Channel, alert.Target, alert.TargetPriceCents, alert.ConfirmToken, alert.ConfirmedAt, alert.CreatedAt, alert.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PriceAlertRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.PriceAlert, error) {
+	alert, err := scanPriceAlert(r.db.QueryRowContext(ctx,
+		`SELECT `+priceAlertColumns+` FROM price_alerts WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return alert, err
+}
+
+// Confirm marks the alert with the confirmation token as confirmed at at; confirming
+// again keeps the first time. Returns nil when no alert has the token.
+func (r *PriceAlertRepository) Confirm(ctx context.Context, token string, at time.Time) (*models.PriceAlert, error) {
+	alert, err := scanPriceAlert(r.db.QueryRowContext(ctx,
+		`UPDATE price_alerts SET confirmed_at = COALESCE(confirmed_at, $2), updated_at = CURRENT_TIMESTAMP
+		WHERE confirm_token = $1 RETURNING `+priceAlertColumns, token, at))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return alert, err
+}
+
+// List returns all alerts, oldest first
+func (r *PriceAlertRepository) List(ctx context.Context) ([]*models.PriceAlert, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+priceAlertColumns+` FROM price_alerts ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*models.PriceAlert{}
+	for rows.Next() {
+		alert, err := scanPriceAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// MarkTriggered records that the alert fired at the cheapest total cents. A nil at
+// re-arms the alert.
+func (r *PriceAlertRepository) MarkTriggered(ctx context.Context, id uuid.UUID, at *time.Time, cents *int) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE price_alerts SET triggered_at = $2, triggered_cents = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		id, at, cents,
+	)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *PriceAlertRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM price_alerts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+// requireRowAffected returns sql.ErrNoRows when a mutation matched no row
+func requireRowAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -1,13 +1,22 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pricecompare/api/internal/models"
 )
 
+// productColumns is the column list shared by product SELECTs.
+// Keep it in sync with scanProduct.
+const productColumns = `id, title, brand, model, image_url, category, created_at, updated_at`
+
 type ProductRepository struct {
 	db *DB
 }
@@ -16,134 +25,491 @@ func NewProductRepository(db *DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) Create(product *models.Product) error {
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var product models.Product
+	if err := row.Scan(
+		&product.ID,
+		&product.Title,
+		&product.Brand,
+		&product.Model,
+		&product.ImageURL,
+		&product.Category,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *ProductRepository) Create(ctx context.Context, product *models.Product) error {
 	query := `
-		INSERT INTO products (id, title, brand, model, image_url, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO products (id, title, brand, model, image_url, category, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 	now := time.Now()
 	product.ID = uuid.New()
 	product.CreatedAt = now
 	product.UpdatedAt = now
 
-	_, err := r.db.Exec(query,
+	_, err := r.db.ExecContext(ctx, query,
 		product.ID,
 		product.Title,
 		product.Brand,
 		product.Model,
 		product.ImageURL,
+		product.Category,
 		product.CreatedAt,
 		product.UpdatedAt,
 	)
 	return err
 }
 
-func (r *ProductRepository) GetByID(id uuid.UUID) (*models.Product, error) {
+func (r *ProductRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Product, error) {
 	query := `
-		SELECT id, title, brand, model, image_url, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM products
 		WHERE id = $1
 	`
-	var product models.Product
-	err := r.db.QueryRow(query, id).Scan(
-		&product.ID,
-		&product.Title,
-		&product.Brand,
-		&product.Model,
-		&product.ImageURL,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &product, nil
+	return product, nil
 }
 
-func (r *ProductRepository) Search(query string, limit int) ([]*models.Product, error) {
-	// Search across products (title, brand, model) and product_identifiers (JAN/UPC/EAN/MPN/ASIN)
-	sqlQuery := `
-		SELECT DISTINCT p.id, p.title, p.brand, p.model, p.image_url, p.created_at, p.updated_at
-		FROM products p
-		LEFT JOIN product_identifiers pi ON pi.product_id = p.id
-		WHERE to_tsvector('english', p.title) @@ plainto_tsquery('english', $1)
-		   OR p.title ILIKE $2
-		   OR p.brand ILIKE $2
-		   OR p.model ILIKE $2
-		   OR pi.value = $3
-		ORDER BY p.updated_at DESC
-		LIMIT $4
+// GetByIDs returns the given products in one query; unknown IDs are skipped
+func (r *ProductRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE id = ANY($1::uuid[])
 	`
-	searchPattern := "%" + query + "%"
-	rows, err := r.db.Query(sqlQuery, query, searchPattern, query, limit)
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(uuidStrings(ids)))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	products := []*models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// CountCreatedSince counts products created since since
+func (r *ProductRepository) CountCreatedSince(ctx context.Context, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM products WHERE created_at >= $1`, since).Scan(&count)
+	return count, err
+}
+
+// ProductSearchFilter narrows a product search; zero fields do not filter. Source, the
+// price range and InStock apply to the same offer: a product matches if one of its
+// published offers is from Source, has a total_to_us_amount within the range and, with
+// InStock, is in stock.
+type ProductSearchFilter struct {
+	Tag           string
+	Brand         string // case-insensitive
+	Source        string
+	MinPriceCents *int
+	MaxPriceCents *int
+	InStock       bool
+}
+
+// productSearchMatch matches products by the search_vector of their title, brand and
+// model with every query word as a prefix ($4, see prefixTSQuery), by a substring of them
+// ($2 ILIKE pattern), by title trigram similarity to catch typos ($1, the % operator), or
+// by any product_identifiers value (JAN/UPC/EAN/MPN/ASIN, $1), and applies the filters
+// ($3 tag, $5 brand and searchOfferFilter). An empty query matches every product.
+const productSearchMatch = `
+	FROM products p
+	WHERE ($1 = ''
+	   OR ($4 <> '' AND p.search_vector @@ to_tsquery('english', $4))
+	   OR p.title ILIKE $2
+	   OR p.brand ILIKE $2
+	   OR p.model ILIKE $2
+	   OR p.title % $1
+	   OR EXISTS (SELECT 1 FROM product_identifiers pi WHERE pi.product_id = p.id AND pi.value = $1))
+	  AND ($3 = '' OR EXISTS (SELECT 1 FROM product_tags pt WHERE pt.product_id = p.id AND pt.tag = $3))
+	  AND ($5 = '' OR lower(p.brand) = lower($5))
+	  AND (($6 = '' AND $7::int IS NULL AND $8::int IS NULL AND NOT $9::boolean) OR EXISTS (
+		SELECT 1 FROM offers o
+		WHERE o.product_id = p.id AND ` + offerPublished + searchOfferFilter + `))
+`
+
+// searchOfferFilter is the condition on an offer o of the $6 source, $7 and $8 price
+// range and $9 in stock filters
+const searchOfferFilter = `
+		  AND ($6 = '' OR o.source = $6)
+		  AND ($7::int IS NULL OR o.total_to_us_amount >= $7)
+		  AND ($8::int IS NULL OR o.total_to_us_amount <= $8)
+		  AND (NOT $9::boolean OR o.in_stock)
+`
+
+// productSearchRank ranks matches by full-text rank (title words weigh more than brand
+// and model) plus title similarity, so close typos still rank below exact words. It is
+// 0 for an empty query, which leaves the most recently updated products first.
+const productSearchRank = `
+	(CASE WHEN $4 = '' THEN 0 ELSE ts_rank(p.search_vector, to_tsquery('english', $4)) END
+	 + CASE WHEN $1 = '' THEN 0 ELSE similarity(p.title, $1) END)
+`
+
+// productSearchArgs are the arguments $1..$9 of productSearchMatch
+func productSearchArgs(query string, filter ProductSearchFilter) []any {
+	return []any{query, "%" + query + "%", filter.Tag, prefixTSQuery(query), filter.Brand, filter.Source, filter.MinPriceCents, filter.MaxPriceCents, filter.InStock}
+}
+
+// prefixTSQuery is the to_tsquery expression matching every word of query as a prefix
+// ("sony wh-1000" -> "sony:* & wh:* & 1000:*"), or "" if query has no words. Only
+// letters and digits are kept, so the expression is always valid.
+func prefixTSQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Search returns one page of the products matching query and tagged tag, best ranked
+// first, and the total number of matches
+func (r *ProductRepository) Search(ctx context.Context, query, tag string, limit, offset int) ([]*models.Product, int, error) {
+	args := productSearchArgs(query, ProductSearchFilter{Tag: tag})
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) `+productSearchMatch, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sqlQuery := `
+		SELECT p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at
+	` + productSearchMatch + `
+		ORDER BY ` + productSearchRank + ` DESC, p.updated_at DESC, p.id
+		LIMIT $10 OFFSET $11
+	`
+	rows, err := r.db.QueryContext(ctx, sqlQuery, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
 	var products []*models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, product)
+	}
+	return products, total, rows.Err()
+}
+
+// ProductSearchResult is a search match with aggregates over its published offers
+type ProductSearchResult struct {
+	Product       *models.Product
+	MinPriceCents *int // cheapest total_to_us_amount; nil without offers
+	OfferCount    int
+	InStockCount  int
+}
+
+// SearchWithMinPrice is Search with filters and the offer aggregates of each product on
+// the page, computed by a LATERAL subquery of the same query instead of one offer query
+// per product
+func (r *ProductRepository) SearchWithMinPrice(ctx context.Context, query string, filter ProductSearchFilter, limit, offset int) ([]*ProductSearchResult, int, error) {
+	args := productSearchArgs(query, filter)
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) `+productSearchMatch, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sqlQuery := `
+		SELECT p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at,
+			agg.min_total, agg.offer_count, agg.in_stock_count
+		FROM (
+			SELECT p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at,
+				` + productSearchRank + ` AS rank
+		` + productSearchMatch + `
+			ORDER BY rank DESC, p.updated_at DESC, p.id
+			LIMIT $10 OFFSET $11
+		) p
+		CROSS JOIN LATERAL (
+			SELECT MIN(o.total_to_us_amount) AS min_total,
+				COUNT(*) AS offer_count,
+				COUNT(*) FILTER (WHERE o.in_stock) AS in_stock_count
+			FROM offers o
+			WHERE o.product_id = p.id AND ` + offerPublished + `
+		) agg
+		ORDER BY p.rank DESC, p.updated_at DESC, p.id
+	`
+	rows, err := r.db.QueryContext(ctx, sqlQuery, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*ProductSearchResult
 	for rows.Next() {
 		var product models.Product
+		result := ProductSearchResult{Product: &product}
 		if err := rows.Scan(
 			&product.ID,
 			&product.Title,
 			&product.Brand,
 			&product.Model,
 			&product.ImageURL,
+			&product.Category,
 			&product.CreatedAt,
 			&product.UpdatedAt,
+			&result.MinPriceCents,
+			&result.OfferCount,
+			&result.InStockCount,
 		); err != nil {
+			return nil, 0, err
+		}
+		results = append(results, &result)
+	}
+	return results, total, rows.Err()
+}
+
+// PriceFacetBounds are the upper bounds (cents, exclusive) of the price buckets of
+// SearchFacets; the last bucket has no upper bound
+var PriceFacetBounds = []int64{2500, 5000, 10000, 25000, 50000}
+
+// PriceFacetLabel is the "price" facet value of bucket i of PriceFacetBounds: the range
+// of its cheapest totals in cents, e.g. "2500-5000" or "50000-"
+func PriceFacetLabel(i int) string {
+	lower, upper := int64(0), ""
+	if i > 0 {
+		lower = PriceFacetBounds[i-1]
+	}
+	if i < len(PriceFacetBounds) {
+		upper = strconv.FormatInt(PriceFacetBounds[i], 10)
+	}
+	return strconv.FormatInt(lower, 10) + "-" + upper
+}
+
+// SearchFacets counts all products matching query and filter by facet, in the shape of
+// searchindex.Result.Facets: "brand", "sources" (products with a matching offer of the
+// source), "price" (by the cheapest matching offer, see PriceFacetLabel) and "in_stock"
+// ("true" for products with a matching in-stock offer)
+func (r *ProductRepository) SearchFacets(ctx context.Context, query string, filter ProductSearchFilter) (map[string]map[string]int, error) {
+	sqlQuery := `
+		WITH matched AS (
+			SELECT p.id, p.brand
+		` + productSearchMatch + `
+		), matched_offers AS (
+			SELECT o.product_id, o.source, o.total_to_us_amount, o.in_stock
+			FROM offers o
+			JOIN matched m ON m.id = o.product_id
+			WHERE ` + offerPublished + searchOfferFilter + `
+		)
+		SELECT 'brand', brand, COUNT(*) FROM matched WHERE COALESCE(brand, '') <> '' GROUP BY brand
+		UNION ALL
+		SELECT 'sources', source, COUNT(DISTINCT product_id) FROM matched_offers GROUP BY source
+		UNION ALL
+		SELECT 'price', width_bucket(min_total, $10::bigint[])::text, COUNT(*)
+		FROM (SELECT MIN(total_to_us_amount) AS min_total FROM matched_offers GROUP BY product_id) prices
+		GROUP BY 2
+		UNION ALL
+		SELECT 'in_stock', (m.id IN (SELECT product_id FROM matched_offers WHERE in_stock))::text, COUNT(*)
+		FROM matched m
+		GROUP BY 2
+	`
+	rows, err := r.db.QueryContext(ctx, sqlQuery, append(productSearchArgs(query, filter), pq.Array(PriceFacetBounds))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := map[string]map[string]int{"brand": {}, "sources": {}, "price": {}, "in_stock": {}}
+	for rows.Next() {
+		var facet, value string
+		var count int
+		if err := rows.Scan(&facet, &value, &count); err != nil {
 			return nil, err
 		}
-		products = append(products, &product)
+		if facet == "price" {
+			bucket, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			value = PriceFacetLabel(bucket)
+		}
+		facets[facet][value] = count
 	}
-	return products, rows.Err()
+	return facets, rows.Err()
 }
 
-func (r *ProductRepository) FindByTitle(title string) (*models.Product, error) {
+func (r *ProductRepository) FindByTitle(ctx context.Context, title string) (*models.Product, error) {
 	query := `
-		SELECT id, title, brand, model, image_url, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM products
 		WHERE title = $1
 		LIMIT 1
 	`
-	var product models.Product
-	err := r.db.QueryRow(query, title).Scan(
-		&product.ID,
-		&product.Title,
-		&product.Brand,
-		&product.Model,
-		&product.ImageURL,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, title))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &product, nil
+	return product, nil
+}
+
+// ProductSimilarity is a product matched by a similarity search, with its score (0..1)
+type ProductSimilarity struct {
+	Product    *models.Product
+	Similarity float64
+}
+
+// FindSimilarByTitle returns products whose normalized title (see migration 043) has a
+// trigram similarity of at least threshold to title, which must be normalized with
+// matching.NormalizeTitle, most similar first. The % operator lets the trigram index
+// prefilter rows (at pg_trgm.similarity_threshold, 0.3 by default), so thresholds below
+// that have no effect.
+func (r *ProductRepository) FindSimilarByTitle(ctx context.Context, title string, threshold float64, limit int) ([]*ProductSimilarity, error) {
+	query := `
+		SELECT ` + productColumns + `, similarity(normalized_title, $1) AS score
+		FROM products
+		WHERE normalized_title % $1 AND similarity(normalized_title, $1) >= $2
+		ORDER BY score DESC
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, title, threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []*ProductSimilarity{}
+	for rows.Next() {
+		var product models.Product
+		var score float64
+		if err := rows.Scan(
+			&product.ID,
+			&product.Title,
+			&product.Brand,
+			&product.Model,
+			&product.ImageURL,
+			&product.Category,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&score,
+		); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &ProductSimilarity{Product: &product, Similarity: score})
+	}
+	return matches, rows.Err()
 }
 
-func (r *ProductRepository) Update(product *models.Product) error {
+func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
 	query := `
 		UPDATE products
-		SET title = $2, brand = $3, model = $4, image_url = $5, updated_at = $6
+		SET title = $2, brand = $3, model = $4, image_url = $5, category = $6, updated_at = $7
 		WHERE id = $1
 	`
 	product.UpdatedAt = time.Now()
-	_, err := r.db.Exec(query,
+	_, err := r.db.ExecContext(ctx, query,
 		product.ID,
 		product.Title,
 		product.Brand,
 		product.Model,
 		product.ImageURL,
+		product.Category,
 		product.UpdatedAt,
 	)
 	return err
 }
 
+// ProductSummary is a product with aggregates over its offers, e.g. for a search index
+type ProductSummary struct {
+	Product       *models.Product
+	MinPriceCents *int // cheapest total_to_us_amount; nil without offers
+	OfferCount    int
+	Sources       []string
+	InStock       bool // at least one offer is in stock
+}
+
+// productSummarySelect aggregates published (not quarantined or delisted) offers per product; callers
+// append WHERE/ORDER BY
+const productSummarySelect = `
+	SELECT p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at,
+		MIN(o.total_to_us_amount),
+		COUNT(o.id),
+		COALESCE(array_agg(DISTINCT o.source) FILTER (WHERE o.source IS NOT NULL), '{}'),
+		COALESCE(bool_or(o.in_stock), false)
+	FROM products p
+	LEFT JOIN offers o ON o.product_id = p.id AND o.quarantine_reason IS NULL AND o.delisted_at IS NULL
+		AND (o.expires_at IS NULL OR o.expires_at > NOW())
+`
+
+func (r *ProductRepository) querySummaries(ctx context.Context, query string, args ...any) ([]*ProductSummary, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*ProductSummary
+	for rows.Next() {
+		var product models.Product
+		var summary ProductSummary
+		if err := rows.Scan(
+			&product.ID,
+			&product.Title,
+			&product.Brand,
+			&product.Model,
+			&product.ImageURL,
+			&product.Category,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&summary.MinPriceCents,
+			&summary.OfferCount,
+			pq.Array(&summary.Sources),
+			&summary.InStock,
+		); err != nil {
+			return nil, err
+		}
+		summary.Product = &product
+		summaries = append(summaries, &summary)
+	}
+	return summaries, rows.Err()
+}
+
+// GetSummaries returns the summaries of the given products; unknown IDs are skipped
+func (r *ProductRepository) GetSummaries(ctx context.Context, ids []uuid.UUID) ([]*ProductSummary, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := productSummarySelect + `
+		WHERE p.id = ANY($1::uuid[])
+		GROUP BY p.id
+	`
+	return r.querySummaries(ctx, query, pq.Array(uuidStrings(ids)))
+}
+
+// ListSummariesAfter returns up to limit summaries ordered by product ID, starting after
+// afterID (uuid.Nil for the first page)
+func (r *ProductRepository) ListSummariesAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]*ProductSummary, error) {
+	query := productSummarySelect + `
+		WHERE p.id > $1
+		GROUP BY p.id
+		ORDER BY p.id
+		LIMIT $2
+	`
+	return r.querySummaries(ctx, query, afterID, limit)
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/embedding"
+	"github.com/pricecompare/api/internal/models"
+)
+
+type ProductEmbeddingRepository struct {
+	db *DB
+}
+
+func NewProductEmbeddingRepository(db *DB) *ProductEmbeddingRepository {
+	return &ProductEmbeddingRepository{db: db}
+}
+
+// Upsert stores the title embedding of a product for a model
+func (r *ProductEmbeddingRepository) Upsert(ctx context.Context, productID uuid.UUID, model string, vector []float32) error {
+	query := `
+		INSERT INTO product_embeddings (product_id, model, embedding, created_at, updated_at)
+		VALUES ($1, $2, $3::vector, $4, $4)
+		ON CONFLICT (product_id, model)
+		DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, productID, model, embedding.VectorLiteral(vector), time.Now())
+	return err
+}
+
+// FindNearest returns the products closest to vector by cosine similarity (1 - cosine
+// distance), most similar first, considering only embeddings of the same model
+func (r *ProductEmbeddingRepository) FindNearest(ctx context.Context, model string, vector []float32, limit int) ([]*ProductSimilarity, error) {
+	query := `
+		SELECT p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at,
+		       1 - (pe.embedding <=> $2::vector) AS score
+		FROM product_embeddings pe
+		JOIN products p ON p.id = pe.product_id
+		WHERE pe.model = $1
+		ORDER BY pe.embedding <=> $2::vector
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, model, embedding.VectorLiteral(vector), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []*ProductSimilarity{}
+	for rows.Next() {
+		var product models.Product
+		var score float64
+		if err := rows.Scan(
+			&product.ID,
+			&product.Title,
+			&product.Brand,
+			&product.Model,
+			&product.ImageURL,
+			&product.Category,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&score,
+		); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &ProductSimilarity{Product: &product, Similarity: score})
+	}
+	return matches, rows.Err()
+}
+
+// ListUnembedded returns products with no embedding for model, ordered by ID after
+// afterID, for the backfill_embeddings job
+func (r *ProductEmbeddingRepository) ListUnembedded(ctx context.Context, model string, afterID uuid.UUID, limit int) ([]*models.Product, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM products p
+		WHERE p.id > $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM product_embeddings pe
+			WHERE pe.product_id = p.id AND pe.model = $2
+		  )
+		ORDER BY p.id
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, afterID, model, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pricecompare/api/internal/models"
 )
 
@@ -17,10 +19,10 @@ func NewProductIdentifierRepository(db *DB) *ProductIdentifierRepository {
 }
 
 // FindByTypeAndValue returns the identifier and associated product if it exists.
-func (r *ProductIdentifierRepository) FindByTypeAndValue(idType, value string) (*models.ProductIdentifier, *models.Product, error) {
+func (r *ProductIdentifierRepository) FindByTypeAndValue(ctx context.Context, idType, value string) (*models.ProductIdentifier, *models.Product, error) {
 	query := `
 		SELECT pi.id, pi.product_id, pi.type, pi.value, pi.created_at, pi.updated_at,
-		       p.id, p.title, p.brand, p.model, p.image_url, p.created_at, p.updated_at
+		       p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at
 		FROM product_identifiers pi
 		JOIN products p ON p.id = pi.product_id
 		WHERE pi.type = $1 AND pi.value = $2
@@ -29,7 +31,7 @@ func (r *ProductIdentifierRepository) FindByTypeAndValue(idType, value string) (
 
 	var ident models.ProductIdentifier
 	var product models.Product
-	err := r.db.QueryRow(query, idType, value).Scan(
+	err := r.db.QueryRowContext(ctx, query, idType, value).Scan(
 		&ident.ID,
 		&ident.ProductID,
 		&ident.Type,
@@ -41,6 +43,7 @@ func (r *ProductIdentifierRepository) FindByTypeAndValue(idType, value string) (
 		&product.Brand,
 		&product.Model,
 		&product.ImageURL,
+		&product.Category,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 	)
@@ -53,7 +56,7 @@ func (r *ProductIdentifierRepository) FindByTypeAndValue(idType, value string) (
 	return &ident, &product, nil
 }
 
-func (r *ProductIdentifierRepository) Create(ident *models.ProductIdentifier) error {
+func (r *ProductIdentifierRepository) Create(ctx context.Context, ident *models.ProductIdentifier) error {
 	query := `
 		INSERT INTO product_identifiers (id, product_id, type, value, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
@@ -65,7 +68,7 @@ func (r *ProductIdentifierRepository) Create(ident *models.ProductIdentifier) er
 	ident.CreatedAt = now
 	ident.UpdatedAt = now
 
-	_, err := r.db.Exec(query,
+	_, err := r.db.ExecContext(ctx, query,
 		ident.ID,
 		ident.ProductID,
 		ident.Type,
@@ -76,4 +79,29 @@ func (r *ProductIdentifierRepository) Create(ident *models.ProductIdentifier) er
 	return err
 }
 
+// ListByProductIDs returns the identifiers of the given products keyed by product ID
+func (r *ProductIdentifierRepository) ListByProductIDs(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]*models.ProductIdentifier, error) {
+	result := make(map[uuid.UUID][]*models.ProductIdentifier)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, product_id, type, value, created_at, updated_at
+		FROM product_identifiers
+		WHERE product_id = ANY($1::uuid[])
+		ORDER BY product_id, type, value
+	`, pq.Array(uuidStrings(productIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var ident models.ProductIdentifier
+		if err := rows.Scan(&ident.ID, &ident.ProductID, &ident.Type, &ident.Value, &ident.CreatedAt, &ident.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result[ident.ProductID] = append(result[ident.ProductID], &ident)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/imagehash"
+	"github.com/pricecompare/api/internal/models"
+)
+
+type ProductImageRepository struct {
+	db *DB
+}
+
+func NewProductImageRepository(db *DB) *ProductImageRepository {
+	return &ProductImageRepository{db: db}
+}
+
+// ProductImageMatch is a product found by image hash, with the Hamming distances of its
+// closest image. DHashDistance is nil for images hashed before dHash was stored.
+type ProductImageMatch struct {
+	Product       *models.Product
+	ImageURL      string
+	Distance      int // pHash
+	DHashDistance *int
+}
+
+// FindHashByURL returns the stored hashes of an image URL on any product, so the same
+// image is not downloaded again. ok is false if the URL has not been hashed yet, or only
+// its pHash is stored.
+func (r *ProductImageRepository) FindHashByURL(ctx context.Context, imageURL string) (hashes imagehash.Hashes, ok bool, err error) {
+	var phash, dhash int64
+	err = r.db.QueryRowContext(ctx,
+		`SELECT phash, dhash FROM product_images WHERE image_url = $1 AND dhash IS NOT NULL LIMIT 1`,
+		imageURL,
+	).Scan(&phash, &dhash)
+	if err == sql.ErrNoRows {
+		return imagehash.Hashes{}, false, nil
+	}
+	if err != nil {
+		return imagehash.Hashes{}, false, err
+	}
+	return imagehash.Hashes{PHash: uint64(phash), DHash: uint64(dhash)}, true, nil
+}
+
+// Upsert stores the hashes of a product image
+func (r *ProductImageRepository) Upsert(ctx context.Context, productID uuid.UUID, imageURL string, hashes imagehash.Hashes) error {
+	query := `
+		INSERT INTO product_images (id, product_id, image_url, phash, dhash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (product_id, image_url)
+		DO UPDATE SET
+			phash = EXCLUDED.phash,
+			dhash = EXCLUDED.dhash,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, uuid.New(), productID, imageURL, int64(hashes.PHash), int64(hashes.DHash), time.Now())
+	return err
+}
+
+// FindNearest returns products having an image within maxDistance of the pHash, closest
+// first, with the dHash distance breaking ties. Distances are computed over all rows,
+// which is fine for catalogs up to a few hundred thousand images.
+func (r *ProductImageRepository) FindNearest(ctx context.Context, hashes imagehash.Hashes, maxDistance, limit int) ([]*ProductImageMatch, error) {
+	query := `
+		SELECT p.id, p.title, p.brand, p.model, p.image_url, p.category, p.created_at, p.updated_at,
+		       m.image_url, m.distance, m.dhash_distance
+		FROM (
+			SELECT DISTINCT ON (product_id) product_id, image_url, distance, dhash_distance
+			FROM (
+				SELECT product_id, image_url,
+				       bit_count((phash # $1)::bit(64)) AS distance,
+				       bit_count((dhash # $2)::bit(64)) AS dhash_distance
+				FROM product_images
+			) d
+			WHERE distance <= $3
+			ORDER BY product_id, distance, dhash_distance NULLS LAST
+		) m
+		JOIN products p ON p.id = m.product_id
+		ORDER BY m.distance, m.dhash_distance NULLS LAST, p.updated_at DESC
+		LIMIT $4
+	`
+	rows, err := r.db.QueryContext(ctx, query, int64(hashes.PHash), int64(hashes.DHash), maxDistance, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []*ProductImageMatch{}
+	for rows.Next() {
+		var product models.Product
+		var match ProductImageMatch
+		var dhashDistance sql.NullInt64
+		if err := rows.Scan(
+			&product.ID,
+			&product.Title,
+			&product.Brand,
+			&product.Model,
+			&product.ImageURL,
+			&product.Category,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&match.ImageURL,
+			&match.Distance,
+			&dhashDistance,
+		); err != nil {
+			return nil, err
+		}
+		if dhashDistance.Valid {
+			distance := int(dhashDistance.Int64)
+			match.DHashDistance = &distance
+		}
+		match.Product = &product
+		matches = append(matches, &match)
+	}
+	return matches, rows.Err()
+}
+
+// ListUnhashed returns products with an image URL that has no stored hashes (or no dHash
+// yet), ordered by ID after afterID, for the backfill_image_hashes job
+func (r *ProductImageRepository) ListUnhashed(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.Product, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM products p
+		WHERE p.id > $1
+		  AND COALESCE(p.image_url, '') <> ''
+		  AND NOT EXISTS (
+			SELECT 1 FROM product_images i
+			WHERE i.product_id = p.id AND i.image_url = p.image_url AND i.dhash IS NOT NULL
+		  )
+		ORDER BY p.id
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
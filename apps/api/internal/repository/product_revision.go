@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// ErrProductModified is returned when applying an edit to a product that changed since it
+// was read
+var ErrProductModified = errors.New("product was modified concurrently")
+
+type ProductRevisionRepository struct {
+	db *DB
+}
+
+func NewProductRevisionRepository(db *DB) *ProductRevisionRepository {
+	return &ProductRevisionRepository{db: db}
+}
+
+// Apply saves an edited product and records the revision describing the edit in a
+// single transaction, setting the revision's ID and CreatedAt. The product's UpdatedAt
+// must still be the stored one.
+// It returns sql.ErrNoRows if the product does not exist and ErrProductModified if it
+// was updated since it was read.
+func (r *ProductRevisionRepository) Apply(ctx context.Context, product *models.Product, revision *models.ProductRevision) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx,
+		`UPDATE products
+		SET title = $2, brand = $3, model = $4, image_url = $5, category = $6, updated_at = $7
+		WHERE id = $1 AND updated_at = $8`,
+		product.ID, product.Title, product.Brand, product.Model, product.ImageURL, product.Category, now, product.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, product.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrProductModified
+		}
+		return sql.ErrNoRows
+	} else if err != nil {
+		return err
+	}
+
+	revision.ProductID = product.ID
+	revision.CreatedAt = now
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO product_revisions (product_id, changes, reason, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		revision.ProductID, revision.Changes, revision.Reason, revision.CreatedAt,
+	).Scan(&revision.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	product.UpdatedAt = now
+	return nil
+}
+
+// ListByProductID returns the latest revisions of a product, newest first, including
+// those of products merged into it
+func (r *ProductRevisionRepository) ListByProductID(ctx context.Context, productID uuid.UUID, limit int) ([]*models.ProductRevision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, product_id, changes, reason, merged_from_product_id, created_at
+		FROM product_revisions
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		productID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.ProductRevision{}
+	for rows.Next() {
+		var revision models.ProductRevision
+		if err := rows.Scan(&revision.ID, &revision.ProductID, &revision.Changes, &revision.Reason, &revision.MergedFromProductID, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	return revisions, rows.Err()
+}
+
+// EditedFields returns the fields of a product changed by any of its own revisions, so
+// ingestion can leave curated values alone. Revisions of merged products edited their
+// values, not the kept product's.
+func (r *ProductRevisionRepository) EditedFields(ctx context.Context, productID uuid.UUID) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT jsonb_object_keys(changes) FROM product_revisions
+		WHERE product_id = $1 AND merged_from_product_id IS NULL`,
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := make(map[string]bool)
+	for rows.Next() {
+		var field string
+		if err := rows.Scan(&field); err != nil {
+			return nil, err
+		}
+		fields[field] = true
+	}
+	return fields, rows.Err()
+}
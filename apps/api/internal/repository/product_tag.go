@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pricecompare/api/internal/models"
+)
+
+type ProductTagRepository struct {
+	db *DB
+}
+
+func NewProductTagRepository(db *DB) *ProductTagRepository {
+	return &ProductTagRepository{db: db}
+}
+
+// uuidStrings converts IDs for a uuid[] parameter
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+	return values
+}
+
+// Add tags the given products with every tag and returns the number of tags added.
+// Unknown products and tags a product already has are skipped.
+func (r *ProductTagRepository) Add(ctx context.Context, productIDs []uuid.UUID, tags []string) (int64, error) {
+	if len(productIDs) == 0 || len(tags) == 0 {
+		return 0, nil
+	}
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO product_tags (product_id, tag)
+		SELECT p.id, t.tag
+		FROM products p CROSS JOIN unnest($2::text[]) AS t(tag)
+		WHERE p.id = ANY($1::uuid[])
+		ON CONFLICT (product_id, tag) DO NOTHING`,
+		pq.Array(uuidStrings(productIDs)), pq.Array(tags),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Remove removes every tag from the given products and returns the number of tags removed
+func (r *ProductTagRepository) Remove(ctx context.Context, productIDs []uuid.UUID, tags []string) (int64, error) {
+	if len(productIDs) == 0 || len(tags) == 0 {
+		return 0, nil
+	}
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM product_tags WHERE product_id = ANY($1::uuid[]) AND tag = ANY($2::text[])`,
+		pq.Array(uuidStrings(productIDs)), pq.Array(tags),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListByProductID returns the tags of a product in alphabetical order
+func (r *ProductTagRepository) ListByProductID(ctx context.Context, productID uuid.UUID) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT tag FROM product_tags WHERE product_id = $1 ORDER BY tag`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListTags returns every tag in use with its number of products, in alphabetical order
+func (r *ProductTagRepository) ListTags(ctx context.Context) ([]*models.TagCount, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT tag, COUNT(*) FROM product_tags GROUP BY tag ORDER BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*models.TagCount{}
+	for rows.Next() {
+		var count models.TagCount
+		if err := rows.Scan(&count.Tag, &count.ProductCount); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &count)
+	}
+	return counts, rows.Err()
+}
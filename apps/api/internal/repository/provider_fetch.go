@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pricecompare/api/internal/models"
+)
+
+type ProviderFetchRepository struct {
+	db *DB
+}
+
+func NewProviderFetchRepository(db *DB) *ProviderFetchRepository {
+	return &ProviderFetchRepository{db: db}
+}
+
+// Record stores the outcome of one provider call. resultCount is the number of
+// candidates or offers returned and is ignored for failed calls.
+func (r *ProviderFetchRepository) Record(ctx context.Context, provider, operation string, duration time.Duration, resultCount int, callErr error) error {
+	var errorMessage *string
+	results := &resultCount
+	if callErr != nil {
+		message := callErr.Error()
+		errorMessage = &message
+		results = nil
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO provider_fetches (provider, operation, success, error, duration_ms, result_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		provider, operation, callErr == nil, errorMessage, duration.Milliseconds(), results, time.Now(),
+	)
+	return err
+}
+
+// DeleteBefore prunes call records older than before
+func (r *ProviderFetchRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM provider_fetches WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// LastSuccessAt returns the time of the latest successful call of each provider with one
+// in the retained records
+func (r *ProviderFetchRepository) LastSuccessAt(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT provider, MAX(created_at) FROM provider_fetches WHERE success GROUP BY provider`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := make(map[string]time.Time)
+	for rows.Next() {
+		var provider string
+		var at time.Time
+		if err := rows.Scan(&provider, &at); err != nil {
+			return nil, err
+		}
+		last[provider] = at
+	}
+	return last, rows.Err()
+}
+
+// Stats aggregates offers per source and provider calls made since since. Providers
+// appear if they have offers or calls in the window.
+func (r *ProviderFetchRepository) Stats(ctx context.Context, since time.Time) ([]*models.ProviderStats, error) {
+	query := `
+		WITH offer_stats AS (
+			SELECT source AS provider,
+			       COUNT(*) AS offers_count,
+			       MAX(fetched_at) AS newest_fetched_at,
+			       AVG(EXTRACT(EPOCH FROM (NOW() - fetched_at))) AS avg_staleness_seconds
+			FROM offers
+			WHERE delisted_at IS NULL
+			GROUP BY source
+		),
+		fetch_stats AS (
+			SELECT provider,
+			       COUNT(*) AS requests,
+			       COUNT(*) FILTER (WHERE NOT success) AS errors,
+			       COALESCE(SUM(result_count), 0) AS results,
+			       MAX(created_at) FILTER (WHERE NOT success) AS last_error_at
+			FROM provider_fetches
+			WHERE created_at >= $1
+			GROUP BY provider
+		),
+		last_errors AS (
+			SELECT DISTINCT ON (provider) provider, error
+			FROM provider_fetches
+			WHERE created_at >= $1 AND NOT success
+			ORDER BY provider, created_at DESC
+		)
+		SELECT COALESCE(o.provider, f.provider),
+		       COALESCE(o.offers_count, 0), o.newest_fetched_at, o.avg_staleness_seconds,
+		       COALESCE(f.requests, 0), COALESCE(f.errors, 0), COALESCE(f.results, 0), l.error, f.last_error_at
+		FROM offer_stats o
+		FULL OUTER JOIN fetch_stats f ON f.provider = o.provider
+		LEFT JOIN last_errors l ON l.provider = COALESCE(o.provider, f.provider)
+		ORDER BY 1
+	`
+	rows, err := r.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*models.ProviderStats{}
+	for rows.Next() {
+		var s models.ProviderStats
+		if err := rows.Scan(
+			&s.Provider,
+			&s.OffersCount,
+			&s.NewestFetchedAt,
+			&s.AvgStalenessSeconds,
+			&s.Requests,
+			&s.Errors,
+			&s.Results,
+			&s.LastError,
+			&s.LastErrorAt,
+		); err != nil {
+			return nil, err
+		}
+		if s.Requests > 0 {
+			rate := float64(s.Errors) / float64(s.Requests)
+			s.ErrorRate = &rate
+		}
+		stats = append(stats, &s)
+	}
+	return stats, rows.Err()
+}
@@ -1,11 +1,14 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type DB struct {
@@ -13,7 +16,11 @@ type DB struct {
 }
 
 func NewDB(databaseURL string) (*DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+	// Queries made with a context become child spans of the caller's span
+	db, err := otelsql.Open("postgres", databaseURL,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -29,5 +36,17 @@ func NewDB(databaseURL string) (*DB, error) {
 	return &DB{db}, nil
 }
 
-
-
+// SchemaVersion returns the version recorded by golang-migrate in schema_migrations and
+// whether the last migration failed halfway (dirty)
+func (db *DB) SchemaVersion(ctx context.Context) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return version, dirty, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pricecompare/api/internal/models"
+)
+
+// ErrSearchQueryExists is returned when a provider already has the same query
+var ErrSearchQueryExists = errors.New("search query already exists")
+
+const searchQueryColumns = `id, provider, query, priority, enabled, created_at, updated_at`
+
+type SearchQueryRepository struct {
+	db *DB
+}
+
+func NewSearchQueryRepository(db *DB) *SearchQueryRepository {
+	return &SearchQueryRepository{db: db}
+}
+
+func scanSearchQuery(row rowScanner) (*models.SearchQuery, error) {
+	var query models.SearchQuery
+	if err := row.Scan(
+		&query.ID,
+		&query.Provider,
+		&query.Query,
+		&query.Priority,
+		&query.Enabled,
+		&query.CreatedAt,
+		&query.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &query, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Create stores a new query and sets its ID and timestamps
+func (r *SearchQueryRepository) Create(ctx context.Context, query *models.SearchQuery) error {
+	query.ID = uuid.New()
+	query.CreatedAt = time.Now()
+	query.UpdatedAt = query.CreatedAt
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO search_queries (id, provider, query, priority, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		query.ID, query.Provider, query.Query, query.Priority, query.Enabled, query.CreatedAt, query.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrSearchQueryExists
+	}
+	return err
+}
+
+func (r *SearchQueryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SearchQuery, error) {
+	query, err := scanSearchQuery(r.db.QueryRowContext(ctx,
+		`SELECT `+searchQueryColumns+` FROM search_queries WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return query, err
+}
+
+// List returns the queries of a provider ("" for every provider), including disabled
+// ones, in the order the fetch job runs them
+func (r *SearchQueryRepository) List(ctx context.Context, provider string) ([]*models.SearchQuery, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+searchQueryColumns+` FROM search_queries
+		WHERE $1 = '' OR provider = $1
+		ORDER BY provider, priority DESC, created_at, id`,
+		provider,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queries := []*models.SearchQuery{}
+	for rows.Next() {
+		query, err := scanSearchQuery(rows)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+// Update saves the query text, priority and enabled flag of a query
+func (r *SearchQueryRepository) Update(ctx context.Context, query *models.SearchQuery) error {
+	query.UpdatedAt = time.Now()
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE search_queries SET query = $2, priority = $3, enabled = $4, updated_at = $5 WHERE id = $1`,
+		query.ID, query.Query, query.Priority, query.Enabled, query.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrSearchQueryExists
+	}
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *SearchQueryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM search_queries WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
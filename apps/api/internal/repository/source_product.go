@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -8,6 +9,11 @@ import (
 	"github.com/pricecompare/api/internal/models"
 )
 
+const sourceProductColumns = `
+	id, product_id, provider, source_id, url, title, brand, image_url, raw_json, schema_version,
+	match_method, match_confidence, snapshot_key, snapshot_at, language, titles, created_at, updated_at
+`
+
 type SourceProductRepository struct {
 	db *DB
 }
@@ -16,16 +22,9 @@ func NewSourceProductRepository(db *DB) *SourceProductRepository {
 	return &SourceProductRepository{db: db}
 }
 
-func (r *SourceProductRepository) FindByProviderAndSourceID(provider, sourceID string) (*models.SourceProduct, error) {
-	query := `
-		SELECT id, product_id, provider, source_id, url, title, brand, image_url, raw_json, created_at, updated_at
-		FROM source_products
-		WHERE provider = $1 AND source_id = $2
-		LIMIT 1
-	`
-
+func scanSourceProduct(row rowScanner) (*models.SourceProduct, error) {
 	var sp models.SourceProduct
-	err := r.db.QueryRow(query, provider, sourceID).Scan(
+	if err := row.Scan(
 		&sp.ID,
 		&sp.ProductID,
 		&sp.Provider,
@@ -35,25 +34,46 @@ func (r *SourceProductRepository) FindByProviderAndSourceID(provider, sourceID s
 		&sp.Brand,
 		&sp.ImageURL,
 		&sp.RawJSON,
+		&sp.SchemaVersion,
+		&sp.MatchMethod,
+		&sp.MatchConfidence,
+		&sp.SnapshotKey,
+		&sp.SnapshotAt,
+		&sp.Language,
+		&sp.Titles,
 		&sp.CreatedAt,
 		&sp.UpdatedAt,
-	)
+	); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+func (r *SourceProductRepository) FindByProviderAndSourceID(ctx context.Context, provider, sourceID string) (*models.SourceProduct, error) {
+	query := `
+		SELECT ` + sourceProductColumns + `
+		FROM source_products
+		WHERE provider = $1 AND source_id = $2
+		LIMIT 1
+	`
+
+	sp, err := scanSourceProduct(r.db.QueryRowContext(ctx, query, provider, sourceID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &sp, nil
+	return sp, nil
 }
 
-func (r *SourceProductRepository) Upsert(sp *models.SourceProduct) error {
+func (r *SourceProductRepository) Upsert(ctx context.Context, sp *models.SourceProduct) error {
 	query := `
 		INSERT INTO source_products (
-			id, product_id, provider, source_id, url, title, brand, image_url, raw_json,
-			created_at, updated_at
+			id, product_id, provider, source_id, url, title, brand, image_url, raw_json, schema_version,
+			match_method, match_confidence, snapshot_key, snapshot_at, language, titles, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		ON CONFLICT (provider, source_id)
 		DO UPDATE SET
 			product_id = EXCLUDED.product_id,
@@ -62,6 +82,15 @@ func (r *SourceProductRepository) Upsert(sp *models.SourceProduct) error {
 			brand = EXCLUDED.brand,
 			image_url = EXCLUDED.image_url,
 			raw_json = EXCLUDED.raw_json,
+			schema_version = EXCLUDED.schema_version,
+			match_method = EXCLUDED.match_method,
+			match_confidence = EXCLUDED.match_confidence,
+			snapshot_key = COALESCE(EXCLUDED.snapshot_key, source_products.snapshot_key),
+			snapshot_at = COALESCE(EXCLUDED.snapshot_at, source_products.snapshot_at),
+			language = COALESCE(EXCLUDED.language, source_products.language),
+			-- Translations of an unchanged title are kept
+			titles = CASE WHEN source_products.title IS NOT DISTINCT FROM EXCLUDED.title
+				THEN source_products.titles || EXCLUDED.titles ELSE EXCLUDED.titles END,
 			updated_at = EXCLUDED.updated_at
 		RETURNING id
 	`
@@ -75,7 +104,7 @@ func (r *SourceProductRepository) Upsert(sp *models.SourceProduct) error {
 	}
 	sp.UpdatedAt = now
 
-	return r.db.QueryRow(query,
+	return r.db.QueryRowContext(ctx, query,
 		sp.ID,
 		sp.ProductID,
 		sp.Provider,
@@ -85,9 +114,186 @@ func (r *SourceProductRepository) Upsert(sp *models.SourceProduct) error {
 		sp.Brand,
 		sp.ImageURL,
 		sp.RawJSON,
+		sp.SchemaVersion,
+		sp.MatchMethod,
+		sp.MatchConfidence,
+		sp.SnapshotKey,
+		sp.SnapshotAt,
+		sp.Language,
+		sp.Titles,
 		sp.CreatedAt,
 		sp.UpdatedAt,
 	).Scan(&sp.ID)
 }
 
+// ListLowConfidence returns listings linked with a confidence below maxConfidence,
+// least confident first, so they can be re-evaluated
+func (r *SourceProductRepository) ListLowConfidence(ctx context.Context, maxConfidence float64, limit int) ([]*models.SourceProduct, error) {
+	query := `
+		SELECT ` + sourceProductColumns + `
+		FROM source_products
+		WHERE match_confidence < $1
+		ORDER BY match_confidence ASC, updated_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, maxConfidence, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sourceProducts := []*models.SourceProduct{}
+	for rows.Next() {
+		sp, err := scanSourceProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		sourceProducts = append(sourceProducts, sp)
+	}
+	return sourceProducts, rows.Err()
+}
+
+// Relink manually links a listing to a product with full confidence.
+// It returns sql.ErrNoRows if the listing does not exist.
+func (r *SourceProductRepository) Relink(ctx context.Context, id, productID uuid.UUID) error {
+	query := `
+		UPDATE source_products
+		SET product_id = $2, match_method = $3, match_confidence = 1, updated_at = $4
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query, id, productID, models.MatchMethodManual, time.Now())
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteOrphaned deletes listings not updated since before whose product has no offer
+// of their provider left, e.g. after the offers moved to another product, and returns
+// how many were deleted
+func (r *SourceProductRepository) DeleteOrphaned(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM source_products sp
+		WHERE sp.updated_at < $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM offers o
+			WHERE o.product_id = sp.product_id AND o.source = sp.provider
+		  )
+	`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
 
+// CountByProvider counts the listings of provider created at or after since (the zero
+// time counts all of them)
+func (r *SourceProductRepository) CountByProvider(ctx context.Context, provider string, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM source_products WHERE provider = $1 AND created_at >= $2
+	`, provider, since).Scan(&count)
+	return count, err
+}
+
+// ListStaleRaw returns listings of provider with a raw payload parsed by a mapping older
+// than schemaVersion, ordered by id after afterID (uuid.Nil to start), for re-parsing
+func (r *SourceProductRepository) ListStaleRaw(ctx context.Context, provider string, schemaVersion int, afterID uuid.UUID, limit int) ([]*models.SourceProduct, error) {
+	query := `
+		SELECT ` + sourceProductColumns + `
+		FROM source_products
+		WHERE provider = $1 AND schema_version < $2 AND raw_json IS NOT NULL AND id > $3
+		ORDER BY id
+		LIMIT $4
+	`
+	rows, err := r.db.QueryContext(ctx, query, provider, schemaVersion, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sourceProducts := []*models.SourceProduct{}
+	for rows.Next() {
+		sp, err := scanSourceProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		sourceProducts = append(sourceProducts, sp)
+	}
+	return sourceProducts, rows.Err()
+}
+
+// UpdateParsed stores the fields of a re-parsed listing and its schema version. The
+// product link and updated_at are left alone, as nothing was fetched, and so are the
+// titles: the next fetch of a listing whose title changed translates it again.
+// It returns sql.ErrNoRows if the listing does not exist.
+func (r *SourceProductRepository) UpdateParsed(ctx context.Context, sp *models.SourceProduct) error {
+	query := `
+		UPDATE source_products
+		SET url = $2, title = $3, brand = $4, image_url = $5, language = COALESCE($6, language), schema_version = $7
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query, sp.ID, sp.URL, sp.Title, sp.Brand, sp.ImageURL, sp.Language, sp.SchemaVersion)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListByProductID returns the listings linked to a product, most recently updated first
+func (r *SourceProductRepository) ListByProductID(ctx context.Context, productID uuid.UUID) ([]*models.SourceProduct, error) {
+	query := `
+		SELECT ` + sourceProductColumns + `
+		FROM source_products
+		WHERE product_id = $1
+		ORDER BY updated_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sourceProducts := []*models.SourceProduct{}
+	for rows.Next() {
+		sp, err := scanSourceProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		sourceProducts = append(sourceProducts, sp)
+	}
+	return sourceProducts, rows.Err()
+}
+
+// GetByID returns a listing, or nil if it does not exist
+func (r *SourceProductRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SourceProduct, error) {
+	query := `
+		SELECT ` + sourceProductColumns + `
+		FROM source_products
+		WHERE id = $1
+	`
+
+	sp, err := scanSourceProduct(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pricecompare/api/internal/models"
+)
+
+type StockEventRepository struct {
+	db *DB
+}
+
+func NewStockEventRepository(db *DB) *StockEventRepository {
+	return &StockEventRepository{db: db}
+}
+
+// Record stores one stock transition and sets its ID and OccurredAt
+func (r *StockEventRepository) Record(ctx context.Context, event *models.StockEvent) error {
+	event.OccurredAt = time.Now()
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO stock_events (offer_id, product_id, source, seller, in_stock, availability_status, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		event.OfferID, event.ProductID, event.Source, event.Seller, event.InStock, event.AvailabilityStatus, event.OccurredAt,
+	).Scan(&event.ID)
+}
+
+// ListByProductID returns the stock transitions of a product since since, newest first
+func (r *StockEventRepository) ListByProductID(ctx context.Context, productID uuid.UUID, since time.Time, limit int) ([]*models.StockEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, offer_id, product_id, source, seller, in_stock, availability_status, occurred_at
+		FROM stock_events
+		WHERE product_id = $1 AND occurred_at >= $2
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $3`,
+		productID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stockEvents := []*models.StockEvent{}
+	for rows.Next() {
+		var e models.StockEvent
+		if err := rows.Scan(&e.ID, &e.OfferID, &e.ProductID, &e.Source, &e.Seller, &e.InStock, &e.AvailabilityStatus, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		stockEvents = append(stockEvents, &e)
+	}
+	return stockEvents, rows.Err()
+}
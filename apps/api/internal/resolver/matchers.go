@@ -0,0 +1,131 @@
+package resolver
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// asinPattern matches Amazon Standard Identification Numbers, e.g. "B08N5WRWNW"
+var asinPattern = regexp.MustCompile(`^[A-Z0-9]{10}$`)
+
+// amazonHost matches Amazon marketplaces (amazon.com, amazon.co.jp, smile.amazon.de, ...)
+var amazonHost = regexp.MustCompile(`(^|\.)amazon\.[a-z.]+$`)
+
+// AmazonASIN matches Amazon product pages: /dp/ASIN, /gp/product/ASIN, /gp/aw/d/ASIN
+// and /<slug>/dp/ASIN
+func AmazonASIN(u *url.URL) (string, string, bool) {
+	if !amazonHost.MatchString(strings.ToLower(u.Hostname())) {
+		return "", "", false
+	}
+	segments := pathSegments(u)
+	for i, segment := range segments {
+		isMarker := segment == "dp" ||
+			(segment == "product" && i > 0 && segments[i-1] == "gp") ||
+			(segment == "d" && i > 1 && segments[i-1] == "aw" && segments[i-2] == "gp")
+		if !isMarker || i+1 >= len(segments) {
+			continue
+		}
+		asin := strings.ToUpper(segments[i+1])
+		if asinPattern.MatchString(asin) {
+			return "ASIN", asin, true
+		}
+	}
+	return "", "", false
+}
+
+// WalmartItemID matches Walmart product pages: /ip/<slug>/<itemId> and /ip/<itemId>
+func WalmartItemID(u *url.URL) (string, string, bool) {
+	if !hostIs(u.Hostname(), "walmart.com") {
+		return "", "", false
+	}
+	segments := pathSegments(u)
+	if len(segments) < 2 || segments[0] != "ip" {
+		return "", "", false
+	}
+	// The item ID is the last numeric segment; slugs may contain numbers too
+	for i := len(segments) - 1; i > 0; i-- {
+		if isDigits(segments[i], 6, 12) {
+			return "itemId", segments[i], true
+		}
+	}
+	return "", "", false
+}
+
+// EbayItemNumber matches eBay listings: /itm/<itemNumber> and /itm/<slug>/<itemNumber>
+func EbayItemNumber(u *url.URL) (string, string, bool) {
+	host := strings.ToLower(u.Hostname())
+	if !strings.HasPrefix(host, "ebay.") && !strings.Contains(host, ".ebay.") {
+		return "", "", false
+	}
+	segments := pathSegments(u)
+	if len(segments) < 2 || segments[0] != "itm" {
+		return "", "", false
+	}
+	for i := len(segments) - 1; i > 0; i-- {
+		if isDigits(segments[i], 9, 15) {
+			return "eBayItemNumber", segments[i], true
+		}
+	}
+	return "", "", false
+}
+
+// BestBuySKU matches Best Buy product pages: /site/<slug>/<sku>.p, with the SKU also
+// accepted from the skuId query parameter
+func BestBuySKU(u *url.URL) (string, string, bool) {
+	if !hostIs(u.Hostname(), "bestbuy.com") {
+		return "", "", false
+	}
+	segments := pathSegments(u)
+	if len(segments) < 2 || segments[0] != "site" {
+		return "", "", false
+	}
+	if sku, ok := strings.CutSuffix(segments[len(segments)-1], ".p"); ok && isDigits(sku, 5, 8) {
+		return "BestBuySKU", sku, true
+	}
+	if sku := u.Query().Get("skuId"); isDigits(sku, 5, 8) {
+		return "BestBuySKU", sku, true
+	}
+	return "", "", false
+}
+
+// aliExpressItemPage matches the path of AliExpress item pages, /item/<productId>.html
+var aliExpressItemPage = regexp.MustCompile(`^/item/(\d{8,20})\.html$`)
+
+// AliExpressProductID matches AliExpress item pages (aliexpress.com and its country
+// sites, aliexpress.us): /item/<productId>.html
+func AliExpressProductID(u *url.URL) (string, string, bool) {
+	if !hostIs(u.Hostname(), "aliexpress.com") && !hostIs(u.Hostname(), "aliexpress.us") {
+		return "", "", false
+	}
+	if match := aliExpressItemPage.FindStringSubmatch(u.Path); match != nil {
+		return "productId", match[1], true
+	}
+	return "", "", false
+}
+
+// GoogleProductID matches Google Shopping product pages, google.com/shopping/product/<id>,
+// as Google's product ID shared by the merchants listing the product
+func GoogleProductID(u *url.URL) (string, string, bool) {
+	if !hostIs(u.Hostname(), "google.com") {
+		return "", "", false
+	}
+	segments := pathSegments(u)
+	if len(segments) == 3 && segments[0] == "shopping" && segments[1] == "product" && isDigits(segments[2], 5, 25) {
+		return "googleProductId", segments[2], true
+	}
+	return "", "", false
+}
+
+// RakutenItemCode matches Rakuten Ichiba item pages, item.rakuten.co.jp/<shop>/<item>/,
+// as the Ichiba API's itemCode "<shop>:<item>"
+func RakutenItemCode(u *url.URL) (string, string, bool) {
+	if strings.ToLower(u.Hostname()) != "item.rakuten.co.jp" {
+		return "", "", false
+	}
+	segments := pathSegments(u)
+	if len(segments) < 2 {
+		return "", "", false
+	}
+	return "itemCode", segments[0] + ":" + segments[1], true
+}
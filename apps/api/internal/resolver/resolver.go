@@ -0,0 +1,114 @@
+// Package resolver extracts provider identifiers (ASIN, Walmart itemId, ...) from product
+// page URLs. Each provider registers a Matcher for its URLs, so POST /api/resolve-url
+// understands every registered provider without provider-specific code in the handler.
+package resolver
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Result is the identifier found in a product URL
+type Result struct {
+	Provider       string // provider name, e.g. "amazon"
+	IdentifierType string // product_identifiers type, e.g. "ASIN"
+	Identifier     string
+}
+
+// Matcher returns the identifier type and value of a product URL of its provider, or
+// ok=false if the URL is not one of its product pages. u is canonical (see canonicalurl).
+type Matcher func(u *url.URL) (identifierType, identifier string, ok bool)
+
+// Registry holds the URL matchers of each provider. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	matchers map[string]Matcher
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{matchers: make(map[string]Matcher)}
+}
+
+// Default returns a registry with the built-in matchers of Amazon, Walmart, eBay, Best
+// Buy, Rakuten Ichiba, AliExpress and Google Shopping
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register("amazon", AmazonASIN)
+	r.Register("walmart", WalmartItemID)
+	r.Register("ebay", EbayItemNumber)
+	r.Register("bestbuy", BestBuySKU)
+	r.Register("rakuten", RakutenItemCode)
+	r.Register("aliexpress", AliExpressProductID)
+	r.Register("google_shopping", GoogleProductID)
+	return r
+}
+
+// Register adds or replaces the matcher of a provider
+func (r *Registry) Register(provider string, matcher Matcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.matchers[provider] = matcher
+}
+
+// Providers returns the names of the registered providers, sorted
+func (r *Registry) Providers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.names()
+}
+
+// Resolve returns the identifier of a product URL, trying providers in name order so
+// the result does not depend on map iteration
+func (r *Registry) Resolve(u *url.URL) (Result, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, name := range r.names() {
+		if identifierType, identifier, ok := r.matchers[name](u); ok {
+			return Result{Provider: name, IdentifierType: identifierType, Identifier: identifier}, true
+		}
+	}
+	return Result{}, false
+}
+
+// names returns the registered provider names, sorted. r.mu must be held.
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.matchers))
+	for name := range r.matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hostIs reports whether host is domain or one of its subdomains
+func hostIs(host, domain string) bool {
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathSegments splits a URL path into its non-empty segments
+func pathSegments(u *url.URL) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// isDigits reports whether s consists of between min and max ASCII digits
+func isDigits(s string, min, max int) bool {
+	if len(s) < min || len(s) > max {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
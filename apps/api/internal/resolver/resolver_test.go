@@ -0,0 +1,75 @@
+package resolver
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultResolve(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want Result
+		ok   bool
+	}{
+		{"amazon dp", "https://www.amazon.com/dp/B08N5WRWNW", Result{"amazon", "ASIN", "B08N5WRWNW"}, true},
+		{"amazon slug dp", "https://www.amazon.co.jp/Sony-WH-1000XM4/dp/b08n5wrwnw/ref=sr_1_1", Result{"amazon", "ASIN", "B08N5WRWNW"}, true},
+		{"amazon gp product", "https://smile.amazon.com/gp/product/B08N5WRWNW", Result{"amazon", "ASIN", "B08N5WRWNW"}, true},
+		{"amazon mobile", "https://www.amazon.com/gp/aw/d/B08N5WRWNW", Result{"amazon", "ASIN", "B08N5WRWNW"}, true},
+		{"amazon not an ASIN", "https://www.amazon.com/dp/short", Result{}, false},
+		{"amazon search", "https://www.amazon.com/s?k=headphones", Result{}, false},
+		{"walmart", "https://www.walmart.com/ip/Sony-WH-1000XM4-Headphones/5461164337", Result{"walmart", "itemId", "5461164337"}, true},
+		{"walmart id only", "https://www.walmart.com/ip/5461164337", Result{"walmart", "itemId", "5461164337"}, true},
+		{"walmart browse", "https://www.walmart.com/browse/electronics/3944", Result{}, false},
+		{"ebay", "https://www.ebay.com/itm/175123456789", Result{"ebay", "eBayItemNumber", "175123456789"}, true},
+		{"ebay slug", "https://www.ebay.co.uk/itm/Sony-Headphones/175123456789", Result{"ebay", "eBayItemNumber", "175123456789"}, true},
+		{"bestbuy", "https://www.bestbuy.com/site/sony-wh-1000xm4/6408356.p", Result{"bestbuy", "BestBuySKU", "6408356"}, true},
+		{"bestbuy skuId", "https://www.bestbuy.com/site/sony-wh-1000xm4?skuId=6408356", Result{"bestbuy", "BestBuySKU", "6408356"}, true},
+		{"rakuten", "https://item.rakuten.co.jp/sonystore/wh-1000xm5/", Result{"rakuten", "itemCode", "sonystore:wh-1000xm5"}, true},
+		{"rakuten shop top", "https://www.rakuten.co.jp/sonystore/", Result{}, false},
+		{"aliexpress", "https://www.aliexpress.com/item/1005004878211234.html?spm=a2g0o.productlist", Result{"aliexpress", "productId", "1005004878211234"}, true},
+		{"aliexpress us", "https://www.aliexpress.us/item/3256804691234567.html", Result{"aliexpress", "productId", "3256804691234567"}, true},
+		{"aliexpress store", "https://www.aliexpress.com/store/912345", Result{}, false},
+		{"google shopping", "https://www.google.com/shopping/product/4887235756540435899?gl=us", Result{"google_shopping", "googleProductId", "4887235756540435899"}, true},
+		{"google search", "https://www.google.com/search?q=headphones&tbm=shop", Result{}, false},
+		{"lookalike host", "https://notwalmart.com/ip/5461164337", Result{}, false},
+		{"unknown site", "https://example.com/dp/B08N5WRWNW", Result{}, false},
+	}
+
+	registry := Default()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := registry.Resolve(u)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("Resolve(%s) = %+v, %v, want %+v, %v", tt.raw, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewRegistry()
+	u, _ := url.Parse("https://shop.example.com/p/42")
+	if _, ok := registry.Resolve(u); ok {
+		t.Fatal("empty registry resolved a URL")
+	}
+
+	registry.Register("example", func(u *url.URL) (string, string, bool) {
+		if u.Host != "shop.example.com" {
+			return "", "", false
+		}
+		return "exampleId", "42", true
+	})
+	got, ok := registry.Resolve(u)
+	if !ok || got != (Result{"example", "exampleId", "42"}) {
+		t.Errorf("Resolve() = %+v, %v", got, ok)
+	}
+	if providers := registry.Providers(); !reflect.DeepEqual(providers, []string{"example"}) {
+		t.Errorf("Providers() = %v", providers)
+	}
+}
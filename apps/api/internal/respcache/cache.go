@@ -0,0 +1,121 @@
+// Package respcache caches the JSON responses of the public read endpoints (search,
+// offers and compare), so repeated requests do not query the database each time.
+// Responses are keyed by a generation counter of their scope. Writes bump the counter of
+// the products they change, which retires the cached offers and compare responses of
+// the product; the search counter is bumped once per fetch run or admin change.
+package respcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const keyPrefix = "respcache:"
+
+// generationTTL keeps the generation counters well past the longest response TTL, so a
+// counter that expires and restarts at 0 cannot revive a response cached under it
+const generationTTL = 24 * time.Hour
+
+// ScopeSearch is the scope of search responses, which any product's offers may change
+const ScopeSearch = "search"
+
+// ScopeProduct is the scope of the responses of the product in the :id route parameter
+func ScopeProduct(c *fiber.Ctx) string {
+	return productScope(c.Params("id"))
+}
+
+func productScope(productID string) string {
+	return "product:" + productID
+}
+
+// Cache caches responses in a Store. Store errors are logged and the request is served
+// uncached.
+type Cache struct {
+	store  Store
+	logger *zap.Logger
+}
+
+func New(store Store, logger *zap.Logger) *Cache {
+	return &Cache{store: store, logger: logger}
+}
+
+// Middleware serves GET requests from the cache and caches their 200 responses for ttl.
+// scope returns the invalidation scope of the request (see ScopeSearch, ScopeProduct).
+// Responses carry X-Cache: HIT or MISS. A nil Cache or a ttl of 0 caches nothing.
+func (c *Cache) Middleware(ttl time.Duration, scope func(*fiber.Ctx) string) fiber.Handler {
+	if c == nil || ttl <= 0 {
+		return func(ctx *fiber.Ctx) error {
+			return ctx.Next()
+		}
+	}
+	return func(ctx *fiber.Ctx) error {
+		if ctx.Method() != fiber.MethodGet {
+			return ctx.Next()
+		}
+		key, err := c.key(ctx.UserContext(), scope(ctx), ctx.OriginalURL())
+		if err != nil {
+			c.logger.Warn("Failed to read response cache generation", zap.Error(err))
+			return ctx.Next()
+		}
+
+		cached, err := c.store.Get(ctx.UserContext(), key)
+		if err != nil {
+			c.logger.Warn("Failed to read response cache", zap.Error(err))
+		}
+		if cached != nil {
+			ctx.Set("X-Cache", "HIT")
+			ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+			return ctx.Send(cached)
+		}
+
+		ctx.Set("X-Cache", "MISS")
+		if err := ctx.Next(); err != nil {
+			return err
+		}
+		if ctx.Response().StatusCode() != fiber.StatusOK {
+			return nil
+		}
+		// The response body is reused by fasthttp once the request is done
+		body := append([]byte(nil), ctx.Response().Body()...)
+		if err := c.store.Set(ctx.UserContext(), key, body, ttl); err != nil {
+			c.logger.Warn("Failed to write response cache", zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// key is the cache key of a request URL under the current generation of scope
+func (c *Cache) key(ctx context.Context, scope, requestURL string) (string, error) {
+	generation, err := c.store.Get(ctx, keyPrefix+"gen:"+scope)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(requestURL))
+	return keyPrefix + scope + ":" + strconv.Itoa(parseGeneration(generation)) + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// InvalidateProduct retires the cached offers and compare responses of a product
+func (c *Cache) InvalidateProduct(ctx context.Context, productID uuid.UUID) error {
+	return c.store.Incr(ctx, keyPrefix+"gen:"+productScope(productID.String()), generationTTL)
+}
+
+// InvalidateSearch retires all cached search responses
+func (c *Cache) InvalidateSearch(ctx context.Context) error {
+	return c.store.Incr(ctx, keyPrefix+"gen:"+ScopeSearch, generationTTL)
+}
+
+func parseGeneration(value []byte) int {
+	generation, _ := strconv.Atoi(string(value))
+	return generation
+}
+
+func formatGeneration(generation int) []byte {
+	return []byte(strconv.Itoa(generation))
+}
@@ -0,0 +1,115 @@
+package respcache
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestCacheMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	cache := New(store, zap.NewNop())
+
+	calls := 0
+	handler := func(c *fiber.Ctx) error {
+		calls++
+		if c.Query("fail") != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad"})
+		}
+		return c.JSON(fiber.Map{"calls": calls})
+	}
+	app := fiber.New()
+	app.Get("/search", cache.Middleware(time.Minute, func(*fiber.Ctx) string { return ScopeSearch }), handler)
+	app.Get("/products/:id/offers", cache.Middleware(time.Minute, ScopeProduct), handler)
+
+	productID := uuid.New()
+	other := uuid.New()
+	offersPath := "/products/" + productID.String() + "/offers"
+	steps := []struct {
+		name      string
+		path      string
+		before    func()
+		wantCache string
+		wantBody  string
+	}{
+		{name: "first search", path: "/search?query=sony", wantCache: "MISS", wantBody: `{"calls":1}`},
+		{name: "repeated search", path: "/search?query=sony", wantCache: "HIT", wantBody: `{"calls":1}`},
+		{name: "other query", path: "/search?query=bose", wantCache: "MISS", wantBody: `{"calls":2}`},
+		{name: "first offers", path: offersPath, wantCache: "MISS", wantBody: `{"calls":3}`},
+		{name: "repeated offers", path: offersPath, wantCache: "HIT", wantBody: `{"calls":3}`},
+		{
+			name:      "another product's offers were written",
+			path:      offersPath,
+			before:    func() { cache.InvalidateProduct(context.Background(), other) },
+			wantCache: "HIT", wantBody: `{"calls":3}`,
+		},
+		{name: "search after a product write", path: "/search?query=sony", wantCache: "HIT", wantBody: `{"calls":1}`},
+		{
+			name:      "search after the run",
+			path:      "/search?query=sony",
+			before:    func() { cache.InvalidateSearch(context.Background()) },
+			wantCache: "MISS", wantBody: `{"calls":4}`,
+		},
+		{
+			name:      "this product's offers were written",
+			path:      offersPath,
+			before:    func() { cache.InvalidateProduct(context.Background(), productID) },
+			wantCache: "MISS", wantBody: `{"calls":5}`,
+		},
+		{name: "errors are not cached", path: "/search?fail=1", wantCache: "MISS"},
+		{name: "errors are not served from the cache", path: "/search?fail=1", wantCache: "MISS"},
+		{
+			name:      "expired",
+			path:      offersPath,
+			before:    func() { now = now.Add(time.Minute) },
+			wantCache: "MISS", wantBody: `{"calls":8}`,
+		},
+	}
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		resp, err := app.Test(httptest.NewRequest("GET", step.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Cache"); got != step.wantCache {
+			t.Errorf("%s: X-Cache = %q, want %q", step.name, got, step.wantCache)
+		}
+		if step.wantBody != "" && string(body) != step.wantBody {
+			t.Errorf("%s: body = %s, want %s", step.name, body, step.wantBody)
+		}
+	}
+}
+
+func TestCacheMiddleware_Disabled(t *testing.T) {
+	var cache *Cache
+	calls := 0
+	app := fiber.New()
+	app.Get("/search", cache.Middleware(time.Minute, func(*fiber.Ctx) string { return ScopeSearch }), func(c *fiber.Ctx) error {
+		calls++
+		return c.SendStatus(fiber.StatusOK)
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := app.Test(httptest.NewRequest("GET", "/search", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get("X-Cache") != "" {
+			t.Errorf("X-Cache = %q without a cache", resp.Header.Get("X-Cache"))
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
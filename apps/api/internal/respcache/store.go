@@ -0,0 +1,114 @@
+package respcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store holds cached responses and the generation counters that invalidate them
+type Store interface {
+	// Get returns the value of key, or nil if there is none
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr increments the counter of key and (re)sets its TTL
+	Incr(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// RedisStore shares the cache between API instances and the workers that invalidate it
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) error {
+	pipe := s.client.TxPipeline()
+	pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MemoryStore is a Store for a single process (QUEUE_MODE=inline without Redis), where
+// the jobs invalidating the cache run next to the handlers
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if !s.now().Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpiredLocked()
+	s.entries[key] = memoryEntry{value: value, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	generation := 0
+	if entry, ok := s.entries[key]; ok && s.now().Before(entry.expiresAt) {
+		generation = parseGeneration(entry.value)
+	}
+	s.entries[key] = memoryEntry{value: formatGeneration(generation + 1), expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+// evictExpiredLocked drops expired entries once the map has grown, so responses of
+// one-off URLs do not accumulate
+func (s *MemoryStore) evictExpiredLocked() {
+	if len(s.entries) < memoryEvictThreshold {
+		return
+	}
+	now := s.now()
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// memoryEvictThreshold is the number of entries above which Set sweeps expired ones
+const memoryEvictThreshold = 1000
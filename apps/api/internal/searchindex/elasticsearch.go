@@ -0,0 +1,243 @@
+package searchindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// facetSize is the number of buckets returned per facet
+const facetSize = 50
+
+// Elasticsearch is an Index backed by the Elasticsearch (or OpenSearch) REST API
+type Elasticsearch struct {
+	baseURL string
+	apiKey  string
+	index   string
+	client  *http.Client
+}
+
+// NewElasticsearch returns an Elasticsearch index client. apiKey is a base64 encoded API
+// key sent as "Authorization: ApiKey"; it may be empty when security is disabled.
+func NewElasticsearch(baseURL, apiKey, index string) *Elasticsearch {
+	return &Elasticsearch{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		index:   index,
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (e *Elasticsearch) headers() map[string]string {
+	if e.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "ApiKey " + e.apiKey}
+}
+
+func (e *Elasticsearch) indexURL(path string) string {
+	return e.baseURL + "/" + url.PathEscape(e.index) + path
+}
+
+// EnsureIndex creates the index with explicit mappings if it does not exist. Mapping
+// changes to an existing index require a new index and a full reindex.
+func (e *Elasticsearch) EnsureIndex(ctx context.Context) error {
+	notFound, err := doJSON(ctx, e.client, http.MethodHead, e.indexURL(""), e.headers(), nil, nil)
+	if err != nil || !notFound {
+		return err
+	}
+
+	textWithKeyword := map[string]any{"type": "text", "fields": map[string]any{"keyword": map[string]string{"type": "keyword"}}}
+	mappings := map[string]any{
+		"mappings": map[string]any{
+			"properties": map[string]any{
+				"id":              map[string]string{"type": "keyword"},
+				"title":           map[string]string{"type": "text"},
+				"brand":           textWithKeyword,
+				"model":           textWithKeyword,
+				"category":        textWithKeyword,
+				"image_url":       map[string]any{"type": "keyword", "index": false},
+				"min_price_cents": map[string]string{"type": "integer"},
+				"offer_count":     map[string]string{"type": "integer"},
+				"sources":         map[string]string{"type": "keyword"},
+				"in_stock":        map[string]string{"type": "boolean"},
+				"updated_at":      map[string]string{"type": "long"},
+				"indexed_at":      map[string]string{"type": "long"},
+			},
+		},
+	}
+	_, err = doJSON(ctx, e.client, http.MethodPut, e.indexURL(""), e.headers(), mappings, nil)
+	if err != nil && strings.Contains(err.Error(), "resource_already_exists_exception") {
+		return nil // created concurrently
+	}
+	return err
+}
+
+// Upsert indexes documents with the bulk API
+func (e *Elasticsearch) Upsert(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]any{"index": map[string]string{"_index": e.index, "_id": doc.ID}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string `json:"_id"`
+			Error *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if _, err := do(ctx, e.client, http.MethodPost, e.baseURL+"/_bulk", "application/x-ndjson", e.headers(), &body, &response); err != nil {
+		return err
+	}
+	if !response.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range response.Items {
+		for _, result := range item {
+			if result.Error != nil {
+				if failed == 0 {
+					first = fmt.Sprintf("%s: %s: %s", result.ID, result.Error.Type, result.Error.Reason)
+				}
+				failed++
+			}
+		}
+	}
+	return fmt.Errorf("bulk indexing failed for %d of %d documents (first: %s)", failed, len(docs), first)
+}
+
+// Delete deletes documents by ID with delete-by-query
+func (e *Elasticsearch) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	body := map[string]any{
+		"query": map[string]any{"ids": map[string][]string{"values": ids}},
+	}
+	_, err := doJSON(ctx, e.client, http.MethodPost, e.indexURL("/_delete_by_query?conflicts=proceed"), e.headers(), body, nil)
+	return err
+}
+
+// DeleteIndexedBefore deletes documents with delete-by-query
+func (e *Elasticsearch) DeleteIndexedBefore(ctx context.Context, t time.Time) error {
+	body := map[string]any{
+		"query": map[string]any{"range": map[string]any{"indexed_at": map[string]int64{"lt": t.Unix()}}},
+	}
+	_, err := doJSON(ctx, e.client, http.MethodPost, e.indexURL("/_delete_by_query?conflicts=proceed"), e.headers(), body, nil)
+	return err
+}
+
+// Search runs a fuzzy multi-field match with filters and terms aggregations as facets
+func (e *Elasticsearch) Search(ctx context.Context, query Query) (*Result, error) {
+	must := map[string]any{"match_all": map[string]any{}}
+	if query.Text != "" {
+		must = map[string]any{"multi_match": map[string]any{
+			"query":     query.Text,
+			"fields":    []string{"title^3", "brand^2", "model^2", "category"},
+			"fuzziness": "AUTO",
+		}}
+	}
+	filters := []any{}
+	term := func(field, value string) {
+		if value != "" {
+			filters = append(filters, map[string]any{"term": map[string]string{field: value}})
+		}
+	}
+	term("category.keyword", query.Category)
+	term("brand.keyword", query.Brand)
+	term("sources", query.Source)
+	if query.InStockOnly {
+		filters = append(filters, map[string]any{"term": map[string]bool{"in_stock": true}})
+	}
+	if query.MaxPriceCents != nil {
+		filters = append(filters, map[string]any{"range": map[string]any{"min_price_cents": map[string]int{"lte": *query.MaxPriceCents}}})
+	}
+
+	facetFields := map[string]string{"category": "category.keyword", "brand": "brand.keyword", "sources": "sources", "in_stock": "in_stock"}
+	aggs := make(map[string]any, len(FacetFields))
+	for _, name := range FacetFields {
+		aggs[name] = map[string]any{"terms": map[string]any{"field": facetFields[name], "size": facetSize}}
+	}
+
+	body := map[string]any{
+		"query":            map[string]any{"bool": map[string]any{"must": must, "filter": filters}},
+		"aggs":             aggs,
+		"from":             query.Offset,
+		"size":             query.Limit,
+		"track_total_hits": true,
+	}
+	switch query.Sort {
+	case SortPriceAsc:
+		body["sort"] = []any{map[string]any{"min_price_cents": map[string]string{"order": "asc", "missing": "_last"}}}
+	case SortPriceDesc:
+		body["sort"] = []any{map[string]any{"min_price_cents": map[string]string{"order": "desc", "missing": "_last"}}}
+	case SortNewest:
+		body["sort"] = []any{map[string]any{"updated_at": map[string]string{"order": "desc"}}}
+	}
+
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source Document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key         any    `json:"key"`
+				KeyAsString string `json:"key_as_string"`
+				DocCount    int    `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	notFound, err := doJSON(ctx, e.client, http.MethodPost, e.indexURL("/_search"), e.headers(), body, &response)
+	if err != nil {
+		return nil, err
+	}
+	if notFound {
+		return nil, fmt.Errorf("search index %q does not exist", e.index)
+	}
+
+	result := &Result{
+		Hits:   make([]Document, 0, len(response.Hits.Hits)),
+		Total:  response.Hits.Total.Value,
+		Facets: make(map[string]map[string]int, len(response.Aggregations)),
+	}
+	for _, hit := range response.Hits.Hits {
+		result.Hits = append(result.Hits, hit.Source)
+	}
+	for name, agg := range response.Aggregations {
+		counts := make(map[string]int, len(agg.Buckets))
+		for _, bucket := range agg.Buckets {
+			key := bucket.KeyAsString // booleans: "true" / "false"
+			if key == "" {
+				key = fmt.Sprint(bucket.Key)
+			}
+			counts[key] = bucket.DocCount
+		}
+		result.Facets[name] = counts
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package searchindex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Meilisearch is an Index backed by the Meilisearch REST API. Writes are asynchronous
+// tasks in Meilisearch, so they are acknowledged before they are searchable.
+type Meilisearch struct {
+	baseURL string
+	apiKey  string
+	index   string
+	client  *http.Client
+}
+
+// NewMeilisearch returns a Meilisearch index client. apiKey may be empty for an
+// unsecured development instance.
+func NewMeilisearch(baseURL, apiKey, index string) *Meilisearch {
+	return &Meilisearch{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		index:   index,
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (m *Meilisearch) headers() map[string]string {
+	if m.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + m.apiKey}
+}
+
+func (m *Meilisearch) indexURL(path string) string {
+	return m.baseURL + "/indexes/" + url.PathEscape(m.index) + path
+}
+
+// EnsureIndex creates the index (a no-op task if it exists) and applies the settings
+func (m *Meilisearch) EnsureIndex(ctx context.Context) error {
+	if _, err := doJSON(ctx, m.client, http.MethodPost, m.baseURL+"/indexes", m.headers(),
+		map[string]string{"uid": m.index, "primaryKey": "id"}, nil); err != nil {
+		return err
+	}
+	settings := map[string]any{
+		"searchableAttributes": []string{"title", "brand", "model", "category"},
+		"filterableAttributes": []string{"category", "brand", "sources", "in_stock", "min_price_cents", "indexed_at"},
+		"sortableAttributes":   []string{"min_price_cents", "updated_at"},
+	}
+	_, err := doJSON(ctx, m.client, http.MethodPatch, m.indexURL("/settings"), m.headers(), settings, nil)
+	return err
+}
+
+// Upsert adds or replaces documents
+func (m *Meilisearch) Upsert(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	_, err := doJSON(ctx, m.client, http.MethodPost, m.indexURL("/documents?primaryKey=id"), m.headers(), docs, nil)
+	return err
+}
+
+// Delete deletes documents by ID in one batch
+func (m *Meilisearch) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := doJSON(ctx, m.client, http.MethodPost, m.indexURL("/documents/delete-batch"), m.headers(), ids, nil)
+	return err
+}
+
+// DeleteIndexedBefore deletes documents by filter (Meilisearch 1.2+)
+func (m *Meilisearch) DeleteIndexedBefore(ctx context.Context, t time.Time) error {
+	body := map[string]string{"filter": fmt.Sprintf("indexed_at < %d", t.Unix())}
+	_, err := doJSON(ctx, m.client, http.MethodPost, m.indexURL("/documents/delete"), m.headers(), body, nil)
+	return err
+}
+
+// Search runs a typo-tolerant search with filters and facet counts
+func (m *Meilisearch) Search(ctx context.Context, query Query) (*Result, error) {
+	filters := []string{}
+	if query.Category != "" {
+		filters = append(filters, "category = "+meiliQuote(query.Category))
+	}
+	if query.Brand != "" {
+		filters = append(filters, "brand = "+meiliQuote(query.Brand))
+	}
+	if query.Source != "" {
+		filters = append(filters, "sources = "+meiliQuote(query.Source))
+	}
+	if query.InStockOnly {
+		filters = append(filters, "in_stock = true")
+	}
+	if query.MaxPriceCents != nil {
+		filters = append(filters, fmt.Sprintf("min_price_cents <= %d", *query.MaxPriceCents))
+	}
+
+	body := map[string]any{
+		"q":      query.Text,
+		"filter": filters,
+		"facets": FacetFields,
+		"limit":  query.Limit,
+		"offset": query.Offset,
+	}
+	switch query.Sort {
+	case SortPriceAsc:
+		body["sort"] = []string{"min_price_cents:asc"}
+	case SortPriceDesc:
+		body["sort"] = []string{"min_price_cents:desc"}
+	case SortNewest:
+		body["sort"] = []string{"updated_at:desc"}
+	}
+
+	var response struct {
+		Hits               []Document                `json:"hits"`
+		EstimatedTotalHits int                       `json:"estimatedTotalHits"`
+		FacetDistribution  map[string]map[string]int `json:"facetDistribution"`
+	}
+	notFound, err := doJSON(ctx, m.client, http.MethodPost, m.indexURL("/search"), m.headers(), body, &response)
+	if err != nil {
+		return nil, err
+	}
+	if notFound {
+		return nil, fmt.Errorf("search index %q does not exist", m.index)
+	}
+
+	result := &Result{Hits: response.Hits, Total: response.EstimatedTotalHits, Facets: response.FacetDistribution}
+	if result.Hits == nil {
+		result.Hits = []Document{}
+	}
+	return result, nil
+}
+
+// meiliQuote quotes a string value for a Meilisearch filter expression
+func meiliQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
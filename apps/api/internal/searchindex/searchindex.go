@@ -0,0 +1,123 @@
+// Package searchindex mirrors products and their cheapest offer into an external search
+// engine (Meilisearch or Elasticsearch) for typo-tolerant, faceted search.
+package searchindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Document is a product as stored in the search index
+type Document struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Brand         *string  `json:"brand,omitempty"`
+	Model         *string  `json:"model,omitempty"`
+	Category      *string  `json:"category,omitempty"`
+	ImageURL      *string  `json:"image_url,omitempty"`
+	MinPriceCents *int     `json:"min_price_cents,omitempty"` // cheapest total_to_us_amount
+	OfferCount    int      `json:"offer_count"`
+	Sources       []string `json:"sources"`
+	InStock       bool     `json:"in_stock"`   // at least one offer is in stock
+	UpdatedAt     int64    `json:"updated_at"` // product updated_at, unix seconds
+	IndexedAt     int64    `json:"indexed_at"` // unix seconds; a full reindex deletes older documents
+}
+
+// Sort orders for Query.Sort
+const (
+	SortRelevance = "relevance"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+)
+
+// FacetFields are the fields counted in Result.Facets
+var FacetFields = []string{"category", "brand", "sources", "in_stock"}
+
+// Query is a full-text search with optional filters. Empty filters match everything.
+type Query struct {
+	Text          string
+	Category      string
+	Brand         string
+	Source        string
+	InStockOnly   bool
+	MaxPriceCents *int
+	Sort          string
+	Limit         int
+	Offset        int
+}
+
+// Result is one page of hits with the facet counts of all matches
+type Result struct {
+	Hits   []Document                `json:"hits"`
+	Total  int                       `json:"total"` // estimated by Meilisearch
+	Facets map[string]map[string]int `json:"facets"`
+}
+
+// Index is a search engine backend
+type Index interface {
+	// EnsureIndex creates the index and applies its settings; it is safe to call repeatedly
+	EnsureIndex(ctx context.Context) error
+	// Upsert adds or replaces documents by ID
+	Upsert(ctx context.Context, docs []Document) error
+	// Delete removes documents by ID; unknown IDs are ignored
+	Delete(ctx context.Context, ids []string) error
+	// DeleteIndexedBefore removes documents not refreshed since t
+	DeleteIndexedBefore(ctx context.Context, t time.Time) error
+	Search(ctx context.Context, query Query) (*Result, error)
+}
+
+const defaultTimeout = 15 * time.Second
+
+// doJSON sends body as JSON (nil for no body) and decodes a 2xx response into out (nil
+// to discard it). notFound reports a 404 without treating it as an error.
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out any) (notFound bool, err error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return false, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	return do(ctx, client, method, url, "application/json", headers, reader, out)
+}
+
+func do(ctx context.Context, client *http.Client, method, url, contentType string, headers map[string]string, body io.Reader, out any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("search index request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return true, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("search index %s %s returned status %d: %s", method, req.URL.Path, resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode search index response: %w", err)
+	}
+	return false, nil
+}
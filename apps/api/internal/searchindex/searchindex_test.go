@@ -0,0 +1,171 @@
+package searchindex
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMeilisearch_Search(t *testing.T) {
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/indexes/products/search" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected request %s %s (auth %q)", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{
+			"hits": [{"id": "p1", "title": "Sony WH-1000XM5", "min_price_cents": 29999, "offer_count": 2, "sources": ["walmart"], "in_stock": true}],
+			"estimatedTotalHits": 1,
+			"facetDistribution": {"brand": {"Sony": 1}, "in_stock": {"true": 1}}
+		}`))
+	}))
+	defer server.Close()
+
+	maxPrice := 50000
+	result, err := NewMeilisearch(server.URL+"/", "key", "products").Search(context.Background(), Query{
+		Text:          "sony headphnes",
+		Brand:         `So"ny`,
+		InStockOnly:   true,
+		MaxPriceCents: &maxPrice,
+		Sort:          SortPriceAsc,
+		Limit:         20,
+	})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	wantFilters := []any{`brand = "So\"ny"`, "in_stock = true", "min_price_cents <= 50000"}
+	if filters, _ := gotBody["filter"].([]any); len(filters) != len(wantFilters) {
+		t.Errorf("filter = %v, want %v", gotBody["filter"], wantFilters)
+	} else {
+		for i, want := range wantFilters {
+			if filters[i] != want {
+				t.Errorf("filter[%d] = %v, want %v", i, filters[i], want)
+			}
+		}
+	}
+	if sort, _ := gotBody["sort"].([]any); len(sort) != 1 || sort[0] != "min_price_cents:asc" {
+		t.Errorf("sort = %v", gotBody["sort"])
+	}
+
+	if result.Total != 1 || len(result.Hits) != 1 || result.Hits[0].ID != "p1" || *result.Hits[0].MinPriceCents != 29999 {
+		t.Errorf("result = %+v", result)
+	}
+	if result.Facets["brand"]["Sony"] != 1 {
+		t.Errorf("facets = %v", result.Facets)
+	}
+}
+
+func TestElasticsearch_Search(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/_search" || r.Header.Get("Authorization") != "ApiKey key" {
+			t.Errorf("unexpected request %s %s (auth %q)", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		for _, want := range []string{`"fuzziness":"AUTO"`, `"term":{"sources":"amazon"}`, `"brand.keyword"`} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("request body %s does not contain %s", body, want)
+			}
+		}
+		w.Write([]byte(`{
+			"hits": {"total": {"value": 3}, "hits": [{"_source": {"id": "p1", "title": "Sony WH-1000XM5", "in_stock": false}}]},
+			"aggregations": {
+				"sources": {"buckets": [{"key": "amazon", "doc_count": 3}]},
+				"in_stock": {"buckets": [{"key": 0, "key_as_string": "false", "doc_count": 2}, {"key": 1, "key_as_string": "true", "doc_count": 1}]}
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	result, err := NewElasticsearch(server.URL, "key", "products").Search(context.Background(), Query{Text: "sony", Source: "amazon", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if result.Total != 3 || len(result.Hits) != 1 || result.Hits[0].Title != "Sony WH-1000XM5" {
+		t.Errorf("result = %+v", result)
+	}
+	if result.Facets["sources"]["amazon"] != 3 || result.Facets["in_stock"]["false"] != 2 {
+		t.Errorf("facets = %v", result.Facets)
+	}
+}
+
+func TestElasticsearch_Upsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  string // empty means success
+	}{
+		{name: "all indexed", response: `{"errors": false, "items": [{"index": {"_id": "p1", "status": 201}}]}`},
+		{
+			name:     "partial failure",
+			response: `{"errors": true, "items": [{"index": {"_id": "p1", "status": 201}}, {"index": {"_id": "p2", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "bad"}}}]}`,
+			wantErr:  "failed for 1 of 2 documents (first: p2: mapper_parsing_exception: bad)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != "application/x-ndjson" {
+					t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			err := NewElasticsearch(server.URL, "", "products").Upsert(context.Background(), []Document{{ID: "p1"}, {ID: "p2"}})
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Upsert() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Upsert() error = %v, want %q", err, tt.wantErr)
+			}
+			if len(lines) != 4 || lines[0] != `{"index":{"_id":"p1","_index":"products"}}` {
+				t.Errorf("bulk body = %q", lines)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		newIndex func(url string) Index
+		wantPath string
+		wantBody string
+	}{
+		{"meilisearch", func(url string) Index { return NewMeilisearch(url, "", "products") }, "/indexes/products/documents/delete-batch", `["p1","p2"]`},
+		{"elasticsearch", func(url string) Index { return NewElasticsearch(url, "", "products") }, "/products/_delete_by_query", `{"query":{"ids":{"values":["p1","p2"]}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				body, _ := io.ReadAll(r.Body)
+				if r.Method != http.MethodPost || r.URL.Path != tt.wantPath || strings.TrimSpace(string(body)) != tt.wantBody {
+					t.Errorf("request = %s %s %s, want POST %s %s", r.Method, r.URL.Path, body, tt.wantPath, tt.wantBody)
+				}
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			index := tt.newIndex(server.URL)
+			if err := index.Delete(context.Background(), []string{"p1", "p2"}); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := index.Delete(context.Background(), nil); err != nil || requests != 1 {
+				t.Errorf("Delete(nil) error = %v after %d requests, want no request", err, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package selftest runs a set of independent checks against the running dependencies
+// (database, Redis, provider credentials, ...) and reports a pass/fail matrix, so a
+// deployment can be verified before it takes traffic.
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Check statuses
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Check is a single named check. Run returns a short detail for the report, or an error
+// when the check fails. Returning Skip(reason) marks the check as not applicable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of all checks, in the order they were given
+type Report struct {
+	Passed  bool     `json:"passed"` // true when no check failed; skipped checks do not count
+	Results []Result `json:"results"`
+}
+
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// Skip returns an error that marks a check as skipped rather than failed
+func Skip(reason string) error {
+	return &skipError{reason: reason}
+}
+
+// Run runs all checks concurrently, each with its own timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check, timeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Passed: true, Results: results}
+	for _, result := range results {
+		if result.Status == StatusFail {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check Check, timeout time.Duration) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result.Name = check.Name
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("panic: %v", r)
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	detail, err := check.Run(ctx)
+	var skip *skipError
+	switch {
+	case errors.As(err, &skip):
+		result.Status = StatusSkip
+		result.Detail = skip.reason
+	case err != nil:
+		result.Status = StatusFail
+		result.Detail = err.Error()
+	default:
+		result.Status = StatusPass
+		result.Detail = detail
+	}
+	return result
+}
+
+// WriteTable writes the report as an aligned text table followed by a summary line
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tTIME\tDETAIL")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%dms\t%s\n", result.Name, result.Status, result.DurationMs, result.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	summary := "PASS"
+	if !r.Passed {
+		summary = "FAIL"
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", summary)
+	return err
+}
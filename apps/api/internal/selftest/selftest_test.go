@@ -0,0 +1,103 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	pass := Check{Name: "pass", Run: func(ctx context.Context) (string, error) { return "ok", nil }}
+	fail := Check{Name: "fail", Run: func(ctx context.Context) (string, error) { return "", errors.New("connection refused") }}
+	skip := Check{Name: "skip", Run: func(ctx context.Context) (string, error) { return "", Skip("not configured") }}
+	slow := Check{Name: "slow", Run: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+	panics := Check{Name: "panics", Run: func(ctx context.Context) (string, error) { panic("boom") }}
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantPassed bool
+		want       []Result // Name, Status and Detail are compared
+	}{
+		{
+			name:       "all pass",
+			checks:     []Check{pass},
+			wantPassed: true,
+			want:       []Result{{Name: "pass", Status: StatusPass, Detail: "ok"}},
+		},
+		{
+			name:       "skipped checks do not fail the report",
+			checks:     []Check{pass, skip},
+			wantPassed: true,
+			want: []Result{
+				{Name: "pass", Status: StatusPass, Detail: "ok"},
+				{Name: "skip", Status: StatusSkip, Detail: "not configured"},
+			},
+		},
+		{
+			name:       "one failure fails the report and keeps order",
+			checks:     []Check{fail, pass},
+			wantPassed: false,
+			want: []Result{
+				{Name: "fail", Status: StatusFail, Detail: "connection refused"},
+				{Name: "pass", Status: StatusPass, Detail: "ok"},
+			},
+		},
+		{
+			name:       "timeout and panic are failures",
+			checks:     []Check{slow, panics},
+			wantPassed: false,
+			want: []Result{
+				{Name: "slow", Status: StatusFail, Detail: context.DeadlineExceeded.Error()},
+				{Name: "panics", Status: StatusFail, Detail: "panic: boom"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), tt.checks, 50*time.Millisecond)
+
+			if report.Passed != tt.wantPassed {
+				t.Errorf("Passed = %v, want %v", report.Passed, tt.wantPassed)
+			}
+			if len(report.Results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(report.Results), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := report.Results[i]
+				if got.Name != want.Name || got.Status != want.Status || got.Detail != want.Detail {
+					t.Errorf("result %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReport_WriteTable(t *testing.T) {
+	report := Report{
+		Passed: false,
+		Results: []Result{
+			{Name: "database", Status: StatusPass, Detail: "connected", DurationMs: 3},
+			{Name: "provider:walmart", Status: StatusFail, Detail: "status 401", DurationMs: 120},
+		},
+	}
+
+	var out strings.Builder
+	if err := report.WriteTable(&out); err != nil {
+		t.Fatalf("WriteTable() error = %v", err)
+	}
+
+	want := "CHECK             STATUS  TIME   DETAIL\n" +
+		"database          pass    3ms    connected\n" +
+		"provider:walmart  fail    120ms  status 401\n" +
+		"\nFAIL\n"
+	if out.String() != want {
+		t.Errorf("WriteTable() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
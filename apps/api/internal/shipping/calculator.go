@@ -2,65 +2,76 @@ package shipping
 
 import (
 	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pricecompare/api/internal/money"
 )
 
 type Calculator struct {
-	config Config
+	config atomic.Pointer[Config] // swapped as a whole by SetConfig
+
+	mu         sync.RWMutex
+	feeRules   []FeeRule  // see SetFeeRules; empty means a single FeePercent rule
+	rateSource RateSource // see SetRateSource; nil means the static FXUSDJPY rate
 }
 
 type Config struct {
 	Mode       string
-	FeePercent float64
-	FXUSDJPY   float64
+	FeePercent float64 // fallback fee when no fee rules are configured (see SetFeeRules)
+	FXUSDJPY   float64 // static JPY rate, used when no RateSource is set
+
+	// FXMarkupPercent mirrors card issuer spreads on currency conversion.
+	// It is separate from FeePercent and reported as its own fee line item.
+	FXMarkupPercent float64
+
+	// Import duty estimation for cross-border offers
+	DutyRates          map[string]float64 // category -> percent; nil uses DefaultDutyRates
+	DutyDefaultPercent float64            // used for categories missing from DutyRates
+	DutyDeMinimisCents int                // items at or below this value are duty free
+
+	// Per-source free shipping thresholds; nil uses DefaultFreeShippingRules
+	FreeShipping map[string]FreeShippingRule
+
+	// Per-category TABLE mode overrides; nil uses DefaultCategoryShippingTables
+	CategoryTables map[string]ShippingTable
 }
 
 func NewCalculator(config Config) *Calculator {
-	return &Calculator{config: config}
+	c := &Calculator{}
+	c.config.Store(&config)
+	return c
 }
 
-// CalculateShipping calculates shipping cost to US based on price amount (in cents)
-func (c *Calculator) CalculateShipping(priceAmountCents int) int {
-	priceUSD := float64(priceAmountCents) / 100.0
+// SetConfig replaces the calculator configuration (e.g. on a config reload). Fee rules
+// and the FX rate source are kept.
+func (c *Calculator) SetConfig(config Config) {
+	c.config.Store(&config)
+}
 
-	var shippingUSD float64
-	switch c.config.Mode {
+// baseShippingUSD returns the carrier shipping cost (without fee) for the configured mode
+func (c *Calculator) baseShippingUSD(priceUSD float64, productCategory string) float64 {
+	switch c.config.Load().Mode {
 	case "TABLE":
-		shippingUSD = c.calculateByTable(priceUSD)
+		return c.calculateByTable(priceUSD, productCategory)
 	default:
 		// Default flat rate
-		shippingUSD = 14.99
-	}
-
-	// Add fee percentage
-	feeAmount := priceUSD * (c.config.FeePercent / 100.0)
-	totalShipping := shippingUSD + feeAmount
-
-	// Convert back to cents
-	return int(math.Round(totalShipping * 100))
-}
-
-func (c *Calculator) calculateByTable(priceUSD float64) float64 {
-	if priceUSD < 20.0 {
-		return 9.99
-	} else if priceUSD < 50.0 {
 		return 14.99
-	} else {
-		return 19.99
 	}
 }
 
-// CalculateTotal calculates total amount (price + shipping) in cents
-func (c *Calculator) CalculateTotal(priceAmountCents int) int {
-	shipping := c.CalculateShipping(priceAmountCents)
-	return priceAmountCents + shipping
+func (c *Calculator) calculateByTable(priceUSD float64, productCategory string) float64 {
+	priceCents := int(math.Round(priceUSD * 100))
+	return float64(c.tableFor(productCategory).Lookup(priceCents)) / 100.0
 }
 
-// ConvertToJPY converts USD cents to JPY (for display purposes)
-func (c *Calculator) ConvertToJPY(usdCents int) int {
-	usdAmount := float64(usdCents) / 100.0
-	jpyAmount := usdAmount * c.config.FXUSDJPY
-	return int(math.Round(jpyAmount))
+// ConvertToJPY converts USD cents to JPY (for display purposes), including the FX markup.
+// It returns an error if there is no USD/JPY rate.
+func (c *Calculator) ConvertToJPY(usdCents int) (int, error) {
+	jpyPerUSD, err := c.FXRate("JPY")
+	if err != nil {
+		return 0, err
+	}
+	rate := jpyPerUSD * (1 + c.config.Load().FXMarkupPercent/100.0)
+	return money.USD(usdCents).Convert("JPY", rate).Amount, nil
 }
-
-
-
@@ -10,37 +10,38 @@ func TestCalculateShipping(t *testing.T) {
 	})
 
 	tests := []struct {
-		name           string
-		priceCents     int
-		expectedMin    int
-		expectedMax    int
+		name             string
+		priceCents       int
+		expectedShipping int
+		expectedFee      int
 	}{
 		{
-			name:        "Low price (< $20)",
-			priceCents:  1999, // $19.99
-			expectedMin: 1050, // $9.99 base + $0.60 fee (3% of $19.99) = $10.59 = 1059 cents
-			expectedMax: 1060,
+			name:             "Low price (< $20)",
+			priceCents:       1999, // $19.99
+			expectedShipping: 999,  // $9.99 base
+			expectedFee:      60,   // 3% of $19.99
 		},
 		{
-			name:        "Mid price ($20-$50)",
-			priceCents:  3999, // $39.99
-			expectedMin: 1600, // ~$14.99 base + ~$1.20 fee = ~$16.19
-			expectedMax: 1700,
+			name:             "Mid price ($20-$50)",
+			priceCents:       3999, // $39.99
+			expectedShipping: 1499, // $14.99 base
+			expectedFee:      120,
 		},
 		{
-			name:        "High price (> $50)",
-			priceCents:  9999, // $99.99
-			expectedMin: 2200, // ~$19.99 base + ~$3.00 fee = ~$22.99
-			expectedMax: 2400,
+			name:             "High price (> $50)",
+			priceCents:       9999, // $99.99
+			expectedShipping: 1999, // $19.99 base
+			expectedFee:      300,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := calc.CalculateShipping(tt.priceCents)
-			if result < tt.expectedMin || result > tt.expectedMax {
-				t.Errorf("CalculateShipping(%d) = %d, want between %d and %d",
-					tt.priceCents, result, tt.expectedMin, tt.expectedMax)
+			shipping, _ := calc.CalculateOfferShipping("live", "", tt.priceCents, false)
+			fee := calc.CalculateRuleFees("live", "", tt.priceCents)
+			if shipping != tt.expectedShipping || fee != tt.expectedFee {
+				t.Errorf("shipping and fee of %d = %d and %d, want %d and %d",
+					tt.priceCents, shipping, fee, tt.expectedShipping, tt.expectedFee)
 			}
 		})
 	}
@@ -54,12 +55,14 @@ func TestCalculateTotal(t *testing.T) {
 	})
 
 	priceCents := 4999 // $49.99
-	shipping := calc.CalculateShipping(priceCents)
-	total := calc.CalculateTotal(priceCents)
+	estimate, err := calc.Estimate(EstimateInput{PriceCents: priceCents})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	expectedTotal := priceCents + shipping
-	if total != expectedTotal {
-		t.Errorf("CalculateTotal(%d) = %d, want %d", priceCents, total, expectedTotal)
+	expectedTotal := priceCents + estimate.ShippingCents + estimate.FeeCents
+	if estimate.TotalCents != expectedTotal {
+		t.Errorf("Estimate(%d) total = %d, want %d", priceCents, estimate.TotalCents, expectedTotal)
 	}
 }
 
@@ -89,12 +92,17 @@ func TestConvertToJPY(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := calc.ConvertToJPY(tt.usdCents)
-			if result != tt.expectedJPY {
-				t.Errorf("ConvertToJPY(%d) = %d, want %d",
-					tt.usdCents, result, tt.expectedJPY)
+			result, err := calc.ConvertToJPY(tt.usdCents)
+			if err != nil || result != tt.expectedJPY {
+				t.Errorf("ConvertToJPY(%d) = %d, %v, want %d",
+					tt.usdCents, result, err, tt.expectedJPY)
 			}
 		})
 	}
-}
 
+	// Without a rate the conversion fails rather than showing a free price
+	unconfigured := NewCalculator(Config{Mode: "TABLE"})
+	if result, err := unconfigured.ConvertToJPY(1000); err == nil {
+		t.Errorf("ConvertToJPY() without a rate = %d, want an error", result)
+	}
+}
@@ -0,0 +1,235 @@
+package shipping
+
+import "time"
+
+// destinationZones is the time zone a destination's delivery dates are counted in. Fixed
+// offsets (standard time, of the most populous zone) are close enough for whole days and
+// do not depend on the tz database of the host.
+var destinationZones = map[string]*time.Location{
+	"US": time.FixedZone("EST", -5*60*60),
+	"CA": time.FixedZone("EST", -5*60*60),
+	"MX": time.FixedZone("CST", -6*60*60),
+	"GB": time.FixedZone("GMT", 0),
+	"DE": time.FixedZone("CET", 1*60*60),
+	"FR": time.FixedZone("CET", 1*60*60),
+	"JP": time.FixedZone("JST", 9*60*60),
+	"AU": time.FixedZone("AEST", 10*60*60),
+}
+
+// holiday is a public holiday without deliveries: a fixed date (Day > 0), the Nth
+// Weekday of Month (N < 0 counts from the end of the month), a day relative to
+// Easter Sunday (Easter true, Day is the offset), or the equinox of Month in Japan
+// (Equinox true, March or September)
+type holiday struct {
+	Month   time.Month
+	Day     int
+	Weekday time.Weekday
+	N       int
+	Easter  bool
+	Equinox bool
+}
+
+// destinationHolidays are the nationwide public holidays of each destination. Holidays
+// moved to a weekday when they fall on a weekend are not modeled.
+var destinationHolidays = map[string][]holiday{
+	"US": {
+		{Month: time.January, Day: 1},
+		{Month: time.January, Weekday: time.Monday, N: 3},    // Martin Luther King Jr. Day
+		{Month: time.May, Weekday: time.Monday, N: -1},       // Memorial Day
+		{Month: time.June, Day: 19},                          // Juneteenth
+		{Month: time.July, Day: 4},                           // Independence Day
+		{Month: time.September, Weekday: time.Monday, N: 1},  // Labor Day
+		{Month: time.November, Day: 11},                      // Veterans Day
+		{Month: time.November, Weekday: time.Thursday, N: 4}, // Thanksgiving
+		{Month: time.December, Day: 25},
+	},
+	"CA": {
+		{Month: time.January, Day: 1},
+		{Easter: true, Day: -2},                             // Good Friday
+		{Month: time.July, Day: 1},                          // Canada Day
+		{Month: time.September, Weekday: time.Monday, N: 1}, // Labour Day
+		{Month: time.December, Day: 25},
+		{Month: time.December, Day: 26},
+	},
+	"MX": {
+		{Month: time.January, Day: 1},
+		{Month: time.February, Weekday: time.Monday, N: 1}, // Constitution Day
+		{Month: time.March, Weekday: time.Monday, N: 3},    // Benito Juárez's birthday
+		{Month: time.May, Day: 1},
+		{Month: time.September, Day: 16},                   // Independence Day
+		{Month: time.November, Weekday: time.Monday, N: 3}, // Revolution Day
+		{Month: time.December, Day: 25},
+	},
+	"GB": {
+		{Month: time.January, Day: 1},
+		{Easter: true, Day: -2},                           // Good Friday
+		{Easter: true, Day: 1},                            // Easter Monday
+		{Month: time.May, Weekday: time.Monday, N: 1},     // Early May bank holiday
+		{Month: time.May, Weekday: time.Monday, N: -1},    // Spring bank holiday
+		{Month: time.August, Weekday: time.Monday, N: -1}, // Summer bank holiday
+		{Month: time.December, Day: 25},
+		{Month: time.December, Day: 26},
+	},
+	"DE": {
+		{Month: time.January, Day: 1},
+		{Easter: true, Day: -2}, // Karfreitag
+		{Easter: true, Day: 1},  // Ostermontag
+		{Month: time.May, Day: 1},
+		{Easter: true, Day: 39},       // Christi Himmelfahrt
+		{Easter: true, Day: 50},       // Pfingstmontag
+		{Month: time.October, Day: 3}, // Tag der Deutschen Einheit
+		{Month: time.December, Day: 25},
+		{Month: time.December, Day: 26},
+	},
+	"FR": {
+		{Month: time.January, Day: 1},
+		{Easter: true, Day: 1}, // Lundi de Pâques
+		{Month: time.May, Day: 1},
+		{Month: time.May, Day: 8},
+		{Easter: true, Day: 39}, // Ascension
+		{Easter: true, Day: 50}, // Lundi de Pentecôte
+		{Month: time.July, Day: 14},
+		{Month: time.August, Day: 15},
+		{Month: time.November, Day: 1},
+		{Month: time.November, Day: 11},
+		{Month: time.December, Day: 25},
+	},
+	"JP": {
+		{Month: time.January, Day: 1},
+		{Month: time.January, Day: 2}, // 年始休業
+		{Month: time.January, Day: 3},
+		{Month: time.January, Weekday: time.Monday, N: 2},   // 成人の日
+		{Month: time.February, Day: 11},                     // 建国記念の日
+		{Month: time.February, Day: 23},                     // 天皇誕生日
+		{Month: time.March, Equinox: true},                  // 春分の日
+		{Month: time.April, Day: 29},                        // 昭和の日
+		{Month: time.May, Day: 3},                           // 憲法記念日
+		{Month: time.May, Day: 4},                           // みどりの日
+		{Month: time.May, Day: 5},                           // こどもの日
+		{Month: time.July, Weekday: time.Monday, N: 3},      // 海の日
+		{Month: time.August, Day: 11},                       // 山の日
+		{Month: time.September, Weekday: time.Monday, N: 3}, // 敬老の日
+		{Month: time.September, Equinox: true},              // 秋分の日
+		{Month: time.October, Weekday: time.Monday, N: 2},   // スポーツの日
+		{Month: time.November, Day: 3},                      // 文化の日
+		{Month: time.November, Day: 23},                     // 勤労感謝の日
+	},
+	"AU": {
+		{Month: time.January, Day: 1},
+		{Month: time.January, Day: 26}, // Australia Day
+		{Easter: true, Day: -2},        // Good Friday
+		{Easter: true, Day: 1},         // Easter Monday
+		{Month: time.April, Day: 25},   // Anzac Day
+		{Month: time.December, Day: 25},
+		{Month: time.December, Day: 26},
+	},
+}
+
+// DeliveryWindow is the range of dates an order placed at a given time arrives in
+type DeliveryWindow struct {
+	Earliest time.Time // midnight in the destination's time zone
+	Latest   time.Time
+}
+
+// EstimateDeliveryWindow turns a delivery estimate in business days into the dates it
+// arrives in destination for an order placed at now. Day counts start on the next
+// business day in the destination's time zone; weekends and the destination's public
+// holidays are skipped.
+func EstimateDeliveryWindow(destination string, now time.Time, daysMin, daysMax int) (DeliveryWindow, error) {
+	destination, err := NormalizeDestination(destination)
+	if err != nil {
+		return DeliveryWindow{}, err
+	}
+	if daysMax < daysMin {
+		daysMin, daysMax = daysMax, daysMin
+	}
+	local := now.In(destinationZones[destination])
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	return DeliveryWindow{
+		Earliest: AddBusinessDays(destination, today, daysMin),
+		Latest:   AddBusinessDays(destination, today, daysMax),
+	}, nil
+}
+
+// AddBusinessDays returns the date days business days after day in destination
+func AddBusinessDays(destination string, day time.Time, days int) time.Time {
+	for days > 0 {
+		day = day.AddDate(0, 0, 1)
+		if IsBusinessDay(destination, day) {
+			days--
+		}
+	}
+	return day
+}
+
+// IsBusinessDay reports whether parcels are delivered in destination on the date of day:
+// a weekday that is not one of its public holidays
+func IsBusinessDay(destination string, day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	for _, h := range destinationHolidays[destination] {
+		if h.matches(day) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h holiday) matches(day time.Time) bool {
+	if h.Easter {
+		easter := easterSunday(day.Year())
+		date := time.Date(day.Year(), easter.Month(), easter.Day()+h.Day, 0, 0, 0, 0, time.UTC)
+		return date.Month() == day.Month() && date.Day() == day.Day()
+	}
+	if day.Month() != h.Month {
+		return false
+	}
+	if h.Equinox {
+		return day.Day() == equinoxDay(day.Year(), h.Month)
+	}
+	if h.Day > 0 {
+		return day.Day() == h.Day
+	}
+	if day.Weekday() != h.Weekday {
+		return false
+	}
+	if h.N > 0 {
+		return (day.Day()-1)/7+1 == h.N
+	}
+	// Counted from the end of the month: the last one is within its final 7 days
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return (daysInMonth-day.Day())/7+1 == -h.N
+}
+
+// equinoxDay returns the day of March (vernal) or September (autumnal) the equinox falls
+// on in Japan, by the approximation the National Astronomical Observatory's announced
+// dates follow from 1980 to 2099
+func equinoxDay(year int, month time.Month) int {
+	base := 20.8431
+	if month == time.September {
+		base = 23.2488
+	}
+	years := year - 1980
+	return int(base+0.242194*float64(years)) - years/4
+}
+
+// easterSunday computes the date of Easter Sunday (Gregorian calendar) with the
+// anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
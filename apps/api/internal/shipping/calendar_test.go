@@ -0,0 +1,101 @@
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateDeliveryWindow(t *testing.T) {
+	tests := []struct {
+		name         string
+		destination  string
+		now          time.Time
+		min, max     int
+		wantEarliest string
+		wantLatest   string
+	}{
+		{"skips Thanksgiving and the weekend", "US", time.Date(2026, 11, 25, 17, 0, 0, 0, time.UTC), 1, 3, "2026-11-27", "2026-12-01"},
+		// Friday evening in New York is already Saturday in Tokyo, and Monday is 勤労感謝の日
+		{"counts in the destination's time zone", "JP", time.Date(2026, 11, 20, 16, 0, 0, 0, time.UTC), 1, 1, "2026-11-24", "2026-11-24"},
+		{"same instant to the US", "US", time.Date(2026, 11, 20, 16, 0, 0, 0, time.UTC), 1, 1, "2026-11-23", "2026-11-23"},
+		{"skips Good Friday and Easter Monday", "GB", time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC), 1, 2, "2026-04-02", "2026-04-07"},
+		{"zero days is today", "DE", time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC), 0, 0, "2026-10-14", "2026-10-14"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := EstimateDeliveryWindow(tt.destination, tt.now, tt.min, tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := window.Earliest.Format(time.DateOnly); got != tt.wantEarliest {
+				t.Errorf("earliest = %s, want %s", got, tt.wantEarliest)
+			}
+			if got := window.Latest.Format(time.DateOnly); got != tt.wantLatest {
+				t.Errorf("latest = %s, want %s", got, tt.wantLatest)
+			}
+		})
+	}
+
+	if _, err := EstimateDeliveryWindow("BR", time.Now(), 1, 2); err == nil {
+		t.Error("EstimateDeliveryWindow() accepted an unsupported destination")
+	}
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	tests := []struct {
+		destination string
+		date        string
+		want        bool
+	}{
+		{"US", "2026-05-25", false}, // Memorial Day, last Monday of May
+		{"US", "2026-05-18", true},
+		{"US", "2026-09-07", false}, // Labor Day
+		{"GB", "2026-08-31", false}, // Summer bank holiday
+		{"DE", "2026-05-14", false}, // Christi Himmelfahrt
+		{"FR", "2026-07-14", false},
+		{"JP", "2026-01-12", false}, // 成人の日
+		{"JP", "2026-07-14", true},
+		{"JP", "2026-03-20", false}, // 春分の日
+		{"JP", "2026-09-23", false}, // 秋分の日
+		{"JP", "2024-09-23", true},  // 秋分の日 was the 22nd (a Sunday); substitute holidays are not modeled
+		{"US", "2026-03-20", true},
+		{"AU", "2026-01-26", false},
+		{"US", "2026-10-17", false}, // Saturday
+	}
+	for _, tt := range tests {
+		day, _ := time.Parse(time.DateOnly, tt.date)
+		if got := IsBusinessDay(tt.destination, day); got != tt.want {
+			t.Errorf("IsBusinessDay(%s, %s) = %v, want %v", tt.destination, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestEquinoxDay(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		want  int
+	}{
+		{2024, time.March, 20},
+		{2024, time.September, 22},
+		{2025, time.March, 20},
+		{2025, time.September, 23},
+		{2026, time.March, 20},
+		{2026, time.September, 23},
+		{2027, time.March, 21},
+		{2027, time.September, 23},
+	}
+	for _, tt := range tests {
+		if got := equinoxDay(tt.year, tt.month); got != tt.want {
+			t.Errorf("equinoxDay(%d, %s) = %d, want %d", tt.year, tt.month, got, tt.want)
+		}
+	}
+}
+
+func TestEasterSunday(t *testing.T) {
+	for year, want := range map[int]string{2024: "2024-03-31", 2025: "2025-04-20", 2026: "2026-04-05"} {
+		if got := easterSunday(year).Format(time.DateOnly); got != want {
+			t.Errorf("easterSunday(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package shipping
+
+import (
+	"math"
+	"strings"
+
+	"github.com/pricecompare/api/internal/category"
+)
+
+// DefaultDutyRates is a simplified HS-chapter based import duty table (percent of item value)
+// keyed by catalog category. Rates are approximations for estimation only.
+var DefaultDutyRates = map[string]float64{
+	category.Electronics: 0.0,  // HS 85
+	category.Computers:   0.0,  // HS 8471
+	category.Phones:      0.0,  // HS 8517
+	category.Audio:       2.0,  // HS 8518
+	category.Watches:     6.4,  // HS 91
+	category.Apparel:     16.0, // HS 61/62
+	category.Footwear:    10.0, // HS 64
+	category.Bags:        17.6, // HS 4202
+	category.Jewelry:     5.5,  // HS 71
+	category.Cosmetics:   4.9,  // HS 33
+	category.Toys:        0.0,  // HS 95
+	category.Games:       0.0,  // HS 9504
+	category.Books:       0.0,  // HS 49
+	category.Furniture:   0.0,  // HS 94
+	category.Home:        3.4,
+	category.Sports:      4.0,
+}
+
+// DutyRule is the import duty of a destination other than the US, where the configured
+// DutyRates, DutyDefaultPercent and DutyDeMinimisCents apply
+type DutyRule struct {
+	Percent        float64 // percent of the item value, for every category
+	DeMinimisCents int     // items at or below this value (USD cents) are duty free
+}
+
+// DestinationDutyRules are flat approximations of the duty to the non-US destinations of
+// DestinationMultipliers, for estimation only
+var DestinationDutyRules = map[string]DutyRule{
+	"CA": {Percent: 6.5, DeMinimisCents: 11000}, // CAD 150 under CUSMA
+	"MX": {Percent: 10.0, DeMinimisCents: 11700},
+	"GB": {Percent: 4.0, DeMinimisCents: 17000}, // GBP 135
+	"DE": {Percent: 4.0, DeMinimisCents: 16000}, // EUR 150
+	"FR": {Percent: 4.0, DeMinimisCents: 16000}, // EUR 150
+	"JP": {Percent: 3.0, DeMinimisCents: 6700},  // JPY 10,000
+	"AU": {Percent: 5.0, DeMinimisCents: 65000}, // AUD 1,000
+}
+
+// EstimateDuty estimates import duty (in cents) for an item shipped from originCountry to the US.
+// Domestic offers (empty origin or "US") and items at or below the de minimis value pay no duty.
+func (c *Calculator) EstimateDuty(priceAmountCents int, productCategory, originCountry string) int {
+	return c.EstimateDutyTo(priceAmountCents, productCategory, originCountry, defaultDestinationUS)
+}
+
+// EstimateDutyTo is EstimateDuty for an item shipped to destination. An empty origin means
+// the US, where offers are listed. Destinations other than the US use their
+// DestinationDutyRules; one without a rule pays no duty.
+func (c *Calculator) EstimateDutyTo(priceAmountCents int, productCategory, originCountry, destination string) int {
+	origin := strings.ToUpper(strings.TrimSpace(originCountry))
+	if origin == "" {
+		origin = defaultDestinationUS
+	}
+	destination = strings.ToUpper(strings.TrimSpace(destination))
+	if origin == destination {
+		return 0
+	}
+
+	var rate float64
+	if destination == defaultDestinationUS {
+		if priceAmountCents <= c.config.Load().DutyDeMinimisCents {
+			return 0
+		}
+		rate = c.dutyRate(productCategory)
+	} else {
+		rule, ok := DestinationDutyRules[destination]
+		if !ok || priceAmountCents <= rule.DeMinimisCents {
+			return 0
+		}
+		rate = rule.Percent
+	}
+	return int(math.Round(float64(priceAmountCents) * rate / 100.0))
+}
+
+// CalculateLandedCost returns the estimated landed cost (total + duty) in cents
+func (c *Calculator) CalculateLandedCost(totalAmountCents, dutyAmountCents int) int {
+	return totalAmountCents + dutyAmountCents
+}
+
+func (c *Calculator) dutyRate(productCategory string) float64 {
+	slug := category.Normalize(productCategory)
+	rates := c.config.Load().DutyRates
+	if rates == nil {
+		rates = DefaultDutyRates
+	}
+	if rate, ok := rates[slug]; ok {
+		return rate
+	}
+	return c.config.Load().DutyDefaultPercent
+}
@@ -0,0 +1,113 @@
+package shipping
+
+import "testing"
+
+func TestEstimateDuty(t *testing.T) {
+	calc := NewCalculator(Config{
+		Mode:               "TABLE",
+		FeePercent:         3.0,
+		FXUSDJPY:           150.0,
+		DutyDefaultPercent: 5.0,
+		DutyDeMinimisCents: 80000, // $800
+	})
+
+	tests := []struct {
+		name          string
+		priceCents    int
+		category      string
+		originCountry string
+		expected      int
+	}{
+		{
+			name:          "Domestic offer pays no duty",
+			priceCents:    100000,
+			category:      "apparel",
+			originCountry: "US",
+			expected:      0,
+		},
+		{
+			name:          "Empty origin is treated as domestic",
+			priceCents:    100000,
+			category:      "apparel",
+			originCountry: "",
+			expected:      0,
+		},
+		{
+			name:          "Below de minimis is duty free",
+			priceCents:    50000,
+			category:      "apparel",
+			originCountry: "JP",
+			expected:      0,
+		},
+		{
+			name:          "Apparel from JP above de minimis",
+			priceCents:    100000, // $1000
+			category:      "apparel",
+			originCountry: "jp",
+			expected:      16000, // 16%
+		},
+		{
+			name:          "Alias label is normalized",
+			priceCents:    100000,
+			category:      "Shoes",
+			originCountry: "JP",
+			expected:      10000, // footwear 10%
+		},
+		{
+			name:          "Unknown category uses default rate",
+			priceCents:    100000,
+			category:      "",
+			originCountry: "JP",
+			expected:      5000, // 5%
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := calc.EstimateDuty(tt.priceCents, tt.category, tt.originCountry)
+			if result != tt.expected {
+				t.Errorf("EstimateDuty(%d, %q, %q) = %d, want %d",
+					tt.priceCents, tt.category, tt.originCountry, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCalculateLandedCost(t *testing.T) {
+	calc := NewCalculator(Config{Mode: "TABLE"})
+
+	if got := calc.CalculateLandedCost(5998, 1200); got != 7198 {
+		t.Errorf("CalculateLandedCost(5998, 1200) = %d, want 7198", got)
+	}
+}
+
+func TestEstimateDutyTo(t *testing.T) {
+	calc := NewCalculator(Config{Mode: "TABLE", DutyDefaultPercent: 5.0, DutyDeMinimisCents: 80000})
+	for destination := range DestinationMultipliers {
+		if _, ok := DestinationDutyRules[destination]; !ok && destination != "US" {
+			t.Errorf("destination %s has no duty rule", destination)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		priceCents    int
+		originCountry string
+		destination   string
+		expected      int
+	}{
+		{"US offer to Japan above the Japanese de minimis", 10000, "", "JP", 300},
+		{"US offer to Japan at the de minimis", 6700, "", "JP", 0},
+		{"US de minimis does not apply to Canada", 50000, "US", "ca", 3250},
+		{"Japanese offer to Japan", 100000, "JP", "JP", 0},
+		{"Japanese offer to the US uses the configured rules", 100000, "JP", "US", 16000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calc.EstimateDutyTo(tt.priceCents, "apparel", tt.originCountry, tt.destination); got != tt.expected {
+				t.Errorf("EstimateDutyTo(%d, apparel, %q, %q) = %d, want %d",
+					tt.priceCents, tt.originCountry, tt.destination, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package shipping
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DestinationMultipliers scales the base (US) shipping cost per destination country.
+// Only destinations listed here are supported by Estimate.
+var DestinationMultipliers = map[string]float64{
+	"US": 1.0,
+	"CA": 1.3,
+	"MX": 1.4,
+	"GB": 1.6,
+	"DE": 1.6,
+	"FR": 1.6,
+	"JP": 1.8,
+	"AU": 2.0,
+}
+
+// Weight surcharge applied above the included weight
+const (
+	includedWeightGrams  = 1000
+	surchargePerKgUSD    = 2.50
+	defaultDestinationUS = "US"
+)
+
+// EstimateInput describes a what-if shipping estimate
+type EstimateInput struct {
+	Source      string // optional; enables per-source free-shipping and fee rules
+	Category    string // optional; enables per-category fee rules and shipping tables
+	PriceCents  int
+	WeightGrams *int
+	Destination string // ISO 3166-1 alpha-2, defaults to US
+}
+
+// Estimate is the result of a what-if shipping estimate
+type Estimate struct {
+	Destination   string
+	ShippingCents int // carrier shipping for the standard option
+	FeeCents      int
+	TotalCents    int
+	FreeShipping  bool
+	Options       []Option
+}
+
+// Estimate calculates shipping for an arbitrary price, weight and destination without
+// requiring a stored offer. Free-shipping rules only apply to US destinations.
+func (c *Calculator) Estimate(input EstimateInput) (*Estimate, error) {
+	if input.PriceCents < 0 {
+		return nil, fmt.Errorf("price must not be negative")
+	}
+
+	destination, err := NormalizeDestination(input.Destination)
+	if err != nil {
+		return nil, err
+	}
+	multiplier := DestinationMultipliers[destination]
+
+	free := destination == defaultDestinationUS && c.QualifiesForFreeShipping(input.Source, input.PriceCents)
+
+	baseUSD := c.baseShippingUSD(float64(input.PriceCents)/100.0, input.Category)*multiplier + weightSurchargeUSD(input.WeightGrams)
+	fee := c.CalculateRuleFees(input.Source, input.Category, input.PriceCents)
+
+	options := buildOptions(baseUSD, free, nil, nil)
+	var standardCost int
+	for _, option := range options {
+		if option.Speed == SpeedStandard {
+			standardCost = option.CostCents
+		}
+	}
+
+	return &Estimate{
+		Destination:   destination,
+		ShippingCents: standardCost,
+		FeeCents:      fee,
+		TotalCents:    input.PriceCents + standardCost + fee,
+		FreeShipping:  free,
+		Options:       options,
+	}, nil
+}
+
+// NormalizeDestination upper-cases a destination country, defaulting to US, and rejects
+// destinations missing from DestinationMultipliers
+func NormalizeDestination(destination string) (string, error) {
+	destination = strings.ToUpper(strings.TrimSpace(destination))
+	if destination == "" {
+		return defaultDestinationUS, nil
+	}
+	if _, ok := DestinationMultipliers[destination]; !ok {
+		return "", fmt.Errorf("unsupported destination: %s", destination)
+	}
+	return destination, nil
+}
+
+// CalculateOptionsTo is CalculateOptions for shipping to destination: the US cost is
+// scaled by DestinationMultipliers and free shipping (providerFree or per-source rules)
+// only applies to US destinations. Day ranges are the speed defaults.
+func (c *Calculator) CalculateOptionsTo(destination, source, productCategory string, priceAmountCents int, providerFree bool) ([]Option, error) {
+	destination, err := NormalizeDestination(destination)
+	if err != nil {
+		return nil, err
+	}
+	free := destination == defaultDestinationUS && (providerFree || c.QualifiesForFreeShipping(source, priceAmountCents))
+	baseUSD := c.baseShippingUSD(float64(priceAmountCents)/100.0, productCategory) * DestinationMultipliers[destination]
+
+	return buildOptions(baseUSD, free, nil, nil), nil
+}
+
+// weightSurchargeUSD charges per started kilogram above the included weight
+func weightSurchargeUSD(weightGrams *int) float64 {
+	if weightGrams == nil || *weightGrams <= includedWeightGrams {
+		return 0
+	}
+	extraKg := math.Ceil(float64(*weightGrams-includedWeightGrams) / 1000.0)
+	return extraKg * surchargePerKgUSD
+}
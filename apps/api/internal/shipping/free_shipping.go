@@ -0,0 +1,39 @@
+package shipping
+
+import "math"
+
+// FreeShippingRule describes when a source ships to the US at no shipping cost.
+// A MinOrderCents of 0 means every offer from the source ships free.
+type FreeShippingRule struct {
+	MinOrderCents int
+}
+
+// DefaultFreeShippingRules mirrors the public free-shipping policies of supported sources.
+// Amazon Prime eligibility is per listing and is reported by the provider instead.
+var DefaultFreeShippingRules = map[string]FreeShippingRule{
+	"walmart": {MinOrderCents: 3500}, // free over $35
+}
+
+// QualifiesForFreeShipping reports whether an offer from source at priceAmountCents ships free
+func (c *Calculator) QualifiesForFreeShipping(source string, priceAmountCents int) bool {
+	rules := c.config.Load().FreeShipping
+	if rules == nil {
+		rules = DefaultFreeShippingRules
+	}
+	rule, ok := rules[source]
+	if !ok {
+		return false
+	}
+	return priceAmountCents >= rule.MinOrderCents
+}
+
+// CalculateOfferShipping calculates the carrier shipping cost (in cents, without fee) for an
+// offer from source. providerFree is true when the provider reported the listing as shipping
+// free (e.g. Prime). The returned bool reports whether the offer ships free.
+// Fees are reported separately by ApplyFeeRules so they can be shown as line items.
+func (c *Calculator) CalculateOfferShipping(source, productCategory string, priceAmountCents int, providerFree bool) (int, bool) {
+	if providerFree || c.QualifiesForFreeShipping(source, priceAmountCents) {
+		return 0, true
+	}
+	return int(math.Round(c.baseShippingUSD(float64(priceAmountCents)/100.0, productCategory) * 100)), false
+}
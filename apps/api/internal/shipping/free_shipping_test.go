@@ -0,0 +1,71 @@
+package shipping
+
+import "testing"
+
+func TestCalculateOfferShipping(t *testing.T) {
+	calc := NewCalculator(Config{
+		Mode:       "TABLE",
+		FeePercent: 3.0,
+		FXUSDJPY:   150.0,
+		FreeShipping: map[string]FreeShippingRule{
+			"walmart": {MinOrderCents: 3500},
+			"rakuten": {MinOrderCents: 0},
+		},
+	})
+
+	tests := []struct {
+		name             string
+		source           string
+		priceCents       int
+		providerFree     bool
+		expectedShipping int
+		expectedFree     bool
+	}{
+		{
+			name:             "Walmart below threshold pays table shipping",
+			source:           "walmart",
+			priceCents:       1999,
+			expectedShipping: 999, // $9.99 table rate
+			expectedFree:     false,
+		},
+		{
+			name:             "Walmart at threshold ships free",
+			source:           "walmart",
+			priceCents:       3500,
+			expectedShipping: 0,
+			expectedFree:     true,
+		},
+		{
+			name:             "Always-free source",
+			source:           "rakuten",
+			priceCents:       1000,
+			expectedShipping: 0,
+			expectedFree:     true,
+		},
+		{
+			name:             "Provider-reported free shipping (Prime)",
+			source:           "amazon",
+			priceCents:       1000,
+			providerFree:     true,
+			expectedShipping: 0,
+			expectedFree:     true,
+		},
+		{
+			name:             "Unknown source pays table shipping",
+			source:           "live",
+			priceCents:       9999,
+			expectedShipping: 1999, // $19.99 table rate
+			expectedFree:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipping, free := calc.CalculateOfferShipping(tt.source, "", tt.priceCents, tt.providerFree)
+			if shipping != tt.expectedShipping || free != tt.expectedFree {
+				t.Errorf("CalculateOfferShipping(%q, %d, %v) = (%d, %v), want (%d, %v)",
+					tt.source, tt.priceCents, tt.providerFree, shipping, free, tt.expectedShipping, tt.expectedFree)
+			}
+		})
+	}
+}
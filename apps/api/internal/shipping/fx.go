@@ -0,0 +1,64 @@
+package shipping
+
+import (
+	"fmt"
+
+	"github.com/pricecompare/api/internal/money"
+)
+
+// RateSource provides exchange rates in units of currency per USD (see fx.Resolver)
+type RateSource interface {
+	Rate(currency string) (float64, error)
+}
+
+// SetRateSource makes FXRate use source instead of the static FXUSDJPY rate
+func (c *Calculator) SetRateSource(source RateSource) {
+	c.mu.Lock()
+	c.rateSource = source
+	c.mu.Unlock()
+}
+
+// ConvertToUSD converts an amount in the given currency (minor units of that currency,
+// e.g. cents for USD, yen for JPY) to USD cents. The FX markup (card issuer spread) is
+// returned separately so it can be shown as its own fee line item; it is zero for USD amounts.
+func (c *Calculator) ConvertToUSD(amount int, currency string) (usdCents int, fxMarkupCents int, err error) {
+	price := money.New(amount, currency)
+	if price.Currency == "USD" {
+		return price.Amount, 0, nil
+	}
+	rate, err := c.FXRate(price.Currency)
+	if err != nil {
+		return 0, 0, err
+	}
+	usd := price.Convert("USD", 1/rate)
+	return usd.Amount, c.CalculateFXMarkup(usd.Amount), nil
+}
+
+// CalculateFXMarkup returns the FX markup (FXMarkupPercent of the converted amount) in cents
+func (c *Calculator) CalculateFXMarkup(convertedCents int) int {
+	return money.USD(convertedCents).Percent(c.config.Load().FXMarkupPercent).Amount
+}
+
+// FXRate returns the rate used by ConvertToUSD, in units of currency per USD
+func (c *Calculator) FXRate(currency string) (float64, error) {
+	c.mu.RLock()
+	source := c.rateSource
+	c.mu.RUnlock()
+
+	currency = money.NormalizeCurrency(currency)
+	if source != nil && currency != "USD" {
+		return source.Rate(currency)
+	}
+
+	switch currency {
+	case "USD":
+		return 1, nil
+	case "JPY":
+		if c.config.Load().FXUSDJPY <= 0 {
+			return 0, fmt.Errorf("FX rate USD/JPY is not configured")
+		}
+		return c.config.Load().FXUSDJPY, nil
+	default:
+		return 0, fmt.Errorf("unsupported currency: %s", currency)
+	}
+}
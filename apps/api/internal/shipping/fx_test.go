@@ -0,0 +1,63 @@
+package shipping
+
+import "testing"
+
+func TestConvertToUSD(t *testing.T) {
+	calc := NewCalculator(Config{
+		Mode:            "TABLE",
+		FeePercent:      3.0,
+		FXUSDJPY:        150.0,
+		FXMarkupPercent: 2.0,
+	})
+
+	tests := []struct {
+		name           string
+		amount         int
+		currency       string
+		expectedUSD    int
+		expectedMarkup int
+		wantErr        bool
+	}{
+		{
+			name:           "USD is passed through without markup",
+			amount:         4999,
+			currency:       "USD",
+			expectedUSD:    4999,
+			expectedMarkup: 0,
+		},
+		{
+			name:           "JPY is converted with markup",
+			amount:         15000, // ¥15,000
+			currency:       "JPY",
+			expectedUSD:    10000, // $100.00
+			expectedMarkup: 200,   // 2% of $100.00
+		},
+		{
+			name:     "Unsupported currency",
+			amount:   1000,
+			currency: "EUR",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usd, markup, err := calc.ConvertToUSD(tt.amount, tt.currency)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertToUSD() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if usd != tt.expectedUSD || markup != tt.expectedMarkup {
+				t.Errorf("ConvertToUSD(%d, %q) = (%d, %d), want (%d, %d)",
+					tt.amount, tt.currency, usd, markup, tt.expectedUSD, tt.expectedMarkup)
+			}
+		})
+	}
+}
+
+func TestConvertToJPYWithMarkup(t *testing.T) {
+	calc := NewCalculator(Config{FXUSDJPY: 150.0, FXMarkupPercent: 2.0})
+
+	if got, err := calc.ConvertToJPY(1000); err != nil || got != 1530 {
+		t.Errorf("ConvertToJPY(1000) = %d, %v, want 1530", got, err)
+	}
+}
@@ -0,0 +1,206 @@
+package shipping
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pricecompare/api/internal/category"
+)
+
+// offerTotals prices an offer the way jobs.PriceOffer does (which imports this package,
+// so it cannot be used here): the USD item price, carrier shipping, net fees including
+// the FX markup, total and landed cost, all in cents
+type offerTotals struct {
+	item, shipping, fee, total, duty, landed int
+}
+
+func priceOffer(t *testing.T, calc *Calculator, source, productCategory string, amount int, currency, origin string, providerFree bool) offerTotals {
+	t.Helper()
+	item, fxMarkup, err := calc.ConvertToUSD(amount, currency)
+	if err != nil {
+		t.Fatalf("ConvertToUSD(%d %s) error = %v", amount, currency, err)
+	}
+	shippingCents, _ := calc.CalculateOfferShipping(source, productCategory, item, providerFree)
+	fee := calc.CalculateRuleFees(source, productCategory, item) + fxMarkup
+	total := item + shippingCents + fee
+	duty := calc.EstimateDuty(item, productCategory, origin)
+	return offerTotals{
+		item:     item,
+		shipping: shippingCents,
+		fee:      fee,
+		total:    total,
+		duty:     duty,
+		landed:   calc.CalculateLandedCost(total, duty),
+	}
+}
+
+// goldenConfig is the base configuration of the golden table: a 3% service fee, ¥150
+// per USD with a 2% FX markup and an $800 de minimis value
+func goldenConfig(mode string) Config {
+	return Config{
+		Mode:               mode,
+		FeePercent:         3.0,
+		FXUSDJPY:           150,
+		FXMarkupPercent:    2.0,
+		DutyDeMinimisCents: 80000,
+		DutyDefaultPercent: 5.0,
+	}
+}
+
+// goldenFeeRules replace the service fee in the "rules" cases
+var goldenFeeRules = []FeeRule{
+	{Name: "platform", Percent: 5},
+	{Name: "amazon_handling", Source: "amazon", FixedCents: 99},
+	{Name: "big_ticket_discount", MinPriceCents: intPtr(10000), Percent: -2},
+}
+
+// TestPricingGolden pins the exact totals of every mode, shipping band, free-shipping
+// rule, fee setup, currency and duty case. A change here changes every stored total, so
+// update the table only for an intended change of the fee math.
+func TestPricingGolden(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		rules        bool // goldenFeeRules instead of the 3% service fee
+		source       string
+		category     string
+		amount       int
+		currency     string
+		origin       string
+		providerFree bool
+		want         offerTotals
+	}{
+		// TABLE bands: < $20, < $50, the rest
+		{name: "table first band", mode: "TABLE", source: "live", amount: 1999, currency: "USD",
+			want: offerTotals{item: 1999, shipping: 999, fee: 60, total: 3058, landed: 3058}},
+		{name: "table second band starts at $20", mode: "TABLE", source: "live", amount: 2000, currency: "USD",
+			want: offerTotals{item: 2000, shipping: 1499, fee: 60, total: 3559, landed: 3559}},
+		{name: "table second band", mode: "TABLE", source: "live", amount: 4999, currency: "USD",
+			want: offerTotals{item: 4999, shipping: 1499, fee: 150, total: 6648, landed: 6648}},
+		{name: "table open band starts at $50", mode: "TABLE", source: "live", amount: 5000, currency: "USD",
+			want: offerTotals{item: 5000, shipping: 1999, fee: 150, total: 7149, landed: 7149}},
+		{name: "furniture table", mode: "TABLE", source: "live", category: category.Furniture, amount: 9999, currency: "USD",
+			want: offerTotals{item: 9999, shipping: 4999, fee: 300, total: 15298, landed: 15298}},
+		{name: "furniture open band", mode: "TABLE", source: "live", category: category.Furniture, amount: 10000, currency: "USD",
+			want: offerTotals{item: 10000, shipping: 7999, fee: 300, total: 18299, landed: 18299}},
+
+		// FLAT ignores bands and categories
+		{name: "flat low price", mode: "FLAT", source: "live", amount: 1999, currency: "USD",
+			want: offerTotals{item: 1999, shipping: 1499, fee: 60, total: 3558, landed: 3558}},
+		{name: "flat high price", mode: "FLAT", source: "live", amount: 9999, currency: "USD",
+			want: offerTotals{item: 9999, shipping: 1499, fee: 300, total: 11798, landed: 11798}},
+		{name: "flat furniture", mode: "FLAT", source: "live", category: category.Furniture, amount: 9999, currency: "USD",
+			want: offerTotals{item: 9999, shipping: 1499, fee: 300, total: 11798, landed: 11798}},
+
+		// Free shipping: walmart over $35, provider-reported (Prime)
+		{name: "walmart below the threshold", mode: "TABLE", source: "walmart", amount: 3499, currency: "USD",
+			want: offerTotals{item: 3499, shipping: 1499, fee: 105, total: 5103, landed: 5103}},
+		{name: "walmart at the threshold", mode: "TABLE", source: "walmart", amount: 3500, currency: "USD",
+			want: offerTotals{item: 3500, shipping: 0, fee: 105, total: 3605, landed: 3605}},
+		{name: "provider free shipping", mode: "TABLE", source: "amazon", amount: 2999, currency: "USD", providerFree: true,
+			want: offerTotals{item: 2999, shipping: 0, fee: 90, total: 3089, landed: 3089}},
+
+		// JPY converts at ¥150 and adds the 2% FX markup to the fees
+		{name: "yen price", mode: "TABLE", source: "live", amount: 3000, currency: "JPY",
+			want: offerTotals{item: 2000, shipping: 1499, fee: 100, total: 3599, landed: 3599}},
+		{name: "yen price with duty", mode: "TABLE", source: "live", category: category.Watches, amount: 150000, currency: "JPY", origin: "JP",
+			want: offerTotals{item: 100000, shipping: 1999, fee: 5000, total: 106999, duty: 6400, landed: 113399}},
+		{name: "at the de minimis value", mode: "TABLE", source: "live", category: category.Apparel, amount: 120000, currency: "JPY", origin: "JP",
+			want: offerTotals{item: 80000, shipping: 1999, fee: 4000, total: 85999, landed: 85999}},
+		{name: "default duty rate", mode: "TABLE", source: "live", amount: 90000, currency: "USD", origin: "CN",
+			want: offerTotals{item: 90000, shipping: 1999, fee: 2700, total: 94699, duty: 4500, landed: 99199}},
+		{name: "domestic origin pays no duty", mode: "TABLE", source: "live", category: category.Watches, amount: 90000, currency: "USD", origin: "US",
+			want: offerTotals{item: 90000, shipping: 1999, fee: 2700, total: 94699, landed: 94699}},
+
+		// Fee rules: per-source fixed fees and a discount over $100
+		{name: "rules with a source fee", mode: "TABLE", rules: true, source: "amazon", amount: 2999, currency: "USD",
+			want: offerTotals{item: 2999, shipping: 1499, fee: 249, total: 4747, landed: 4747}},
+		{name: "rules with a discount", mode: "TABLE", rules: true, source: "walmart", amount: 12000, currency: "USD",
+			want: offerTotals{item: 12000, shipping: 0, fee: 360, total: 12360, landed: 12360}},
+		{name: "rules in yen", mode: "FLAT", rules: true, source: "live", amount: 4500, currency: "JPY",
+			want: offerTotals{item: 3000, shipping: 1499, fee: 210, total: 4709, landed: 4709}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calc := NewCalculator(goldenConfig(tt.mode))
+			if tt.rules {
+				if err := calc.SetFeeRules(goldenFeeRules); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := priceOffer(t, calc, tt.source, tt.category, tt.amount, tt.currency, tt.origin, tt.providerFree)
+			if got != tt.want {
+				t.Errorf("%d %s = %+v, want %+v", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPricingMonotonic checks that a higher price never yields a lower total or landed
+// cost, for both modes and currencies, the categories with their own shipping table or
+// duty rate, and percentage fees. Per-source free-shipping thresholds are the intended
+// exception (see TestPricingFreeShippingThreshold); discount rules starting at a price
+// and custom tables with cheaper upper bands can break it by configuration.
+func TestPricingMonotonic(t *testing.T) {
+	// Uncategorized, the furniture table, duty free, 6.4% and 16% duty, the default rate
+	categories := []string{"", category.Furniture, category.Electronics, category.Watches, category.Apparel, "unlisted"}
+
+	for _, mode := range []string{"TABLE", "FLAT"} {
+		for _, feePercent := range []float64{0, 3, 12.5} {
+			config := goldenConfig(mode)
+			config.FeePercent = feePercent
+			calc := NewCalculator(config)
+
+			for _, productCategory := range categories {
+				for _, currency := range []string{"USD", "JPY"} {
+					name := fmt.Sprintf("%s/%.1f%%/%s/%s", mode, feePercent, productCategory, currency)
+					t.Run(name, func(t *testing.T) {
+						previous := priceOffer(t, calc, "live", productCategory, 0, currency, "JP", false)
+						for amount := 1; amount <= 300000; amount += step(amount) {
+							current := priceOffer(t, calc, "live", productCategory, amount, currency, "JP", false)
+							if current.total < previous.total || current.landed < previous.landed {
+								t.Fatalf("price %d %s: total %d, landed %d; a lower price had total %d, landed %d",
+									amount, currency, current.total, current.landed, previous.total, previous.landed)
+							}
+							if current.total < current.item {
+								t.Fatalf("price %d %s: total %d is below the item price %d", amount, currency, current.total, current.item)
+							}
+							previous = current
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// step walks every cent around the band limits and coarser in between, keeping the
+// sweep fast while visiting every boundary of the default tables and de minimis value
+func step(amount int) int {
+	for _, limit := range []int{2000, 5000, 10000, 80000, 120000} {
+		if amount > limit-200 && amount < limit+200 {
+			return 1
+		}
+	}
+	return 7
+}
+
+// TestPricingFreeShippingThreshold checks the intended exception to monotonicity: at a
+// free-shipping threshold the total drops, by no more than the shipping saved
+func TestPricingFreeShippingThreshold(t *testing.T) {
+	calc := NewCalculator(goldenConfig("TABLE"))
+	previous := priceOffer(t, calc, "walmart", "", 0, "USD", "", false)
+	for amount := 1; amount <= 20000; amount++ {
+		current := priceOffer(t, calc, "walmart", "", amount, "USD", "", false)
+		if current.total < previous.total {
+			if amount != DefaultFreeShippingRules["walmart"].MinOrderCents {
+				t.Fatalf("total dropped at %d, not at the free-shipping threshold", amount)
+			}
+			if previous.total-current.total > previous.shipping {
+				t.Fatalf("total dropped by %d at the threshold, more than the %d shipping saved", previous.total-current.total, previous.shipping)
+			}
+		}
+		previous = current
+	}
+}
@@ -0,0 +1,82 @@
+package shipping
+
+import "math"
+
+// Delivery speeds offered for each offer
+const (
+	SpeedEconomy  = "economy"
+	SpeedStandard = "standard"
+	SpeedExpress  = "express"
+)
+
+// Speeds lists the supported delivery speeds from slowest to fastest
+var Speeds = []string{SpeedEconomy, SpeedStandard, SpeedExpress}
+
+// Option is a single shipping option for an offer
+type Option struct {
+	Speed     string
+	CostCents int // carrier shipping, in cents (fees are separate)
+	DaysMin   int
+	DaysMax   int
+}
+
+// speedProfile scales the base shipping cost and sets a default day range per speed
+type speedProfile struct {
+	costMultiplier float64
+	daysMin        int
+	daysMax        int
+	freeEligible   bool // whether free-shipping rules apply to this speed
+}
+
+var speedProfiles = map[string]speedProfile{
+	SpeedEconomy:  {costMultiplier: 0.6, daysMin: 10, daysMax: 20, freeEligible: true},
+	SpeedStandard: {costMultiplier: 1.0, daysMin: 5, daysMax: 10, freeEligible: true},
+	SpeedExpress:  {costMultiplier: 2.0, daysMin: 2, daysMax: 4, freeEligible: false},
+}
+
+// IsValidSpeed reports whether speed is a supported delivery speed
+func IsValidSpeed(speed string) bool {
+	_, ok := speedProfiles[speed]
+	return ok
+}
+
+// CalculateOptions returns economy/standard/express shipping options for an offer.
+// providerFree has the same meaning as in CalculateOfferShipping. standardDaysMin/Max,
+// when non-nil, override the default standard day range with the provider's estimate.
+func (c *Calculator) CalculateOptions(source, productCategory string, priceAmountCents int, providerFree bool, standardDaysMin, standardDaysMax *int) []Option {
+	free := providerFree || c.QualifiesForFreeShipping(source, priceAmountCents)
+	baseUSD := c.baseShippingUSD(float64(priceAmountCents)/100.0, productCategory)
+
+	return buildOptions(baseUSD, free, standardDaysMin, standardDaysMax)
+}
+
+// buildOptions applies each speed profile to a base shipping cost (USD, without fee)
+func buildOptions(baseUSD float64, free bool, standardDaysMin, standardDaysMax *int) []Option {
+	options := make([]Option, 0, len(Speeds))
+	for _, speed := range Speeds {
+		profile := speedProfiles[speed]
+
+		cost := 0
+		if !(free && profile.freeEligible) {
+			cost = int(math.Round(baseUSD * profile.costMultiplier * 100))
+		}
+
+		daysMin, daysMax := profile.daysMin, profile.daysMax
+		if speed == SpeedStandard {
+			if standardDaysMin != nil {
+				daysMin = *standardDaysMin
+			}
+			if standardDaysMax != nil {
+				daysMax = *standardDaysMax
+			}
+		}
+
+		options = append(options, Option{
+			Speed:     speed,
+			CostCents: cost,
+			DaysMin:   daysMin,
+			DaysMax:   daysMax,
+		})
+	}
+	return options
+}
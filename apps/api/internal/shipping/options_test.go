@@ -0,0 +1,154 @@
+package shipping
+
+import "testing"
+
+func TestCalculateOptions(t *testing.T) {
+	calc := NewCalculator(Config{
+		Mode:         "TABLE",
+		FeePercent:   3.0,
+		FreeShipping: map[string]FreeShippingRule{"walmart": {MinOrderCents: 3500}},
+	})
+
+	t.Run("Paid shipping scales per speed", func(t *testing.T) {
+		options := calc.CalculateOptions("live", "", 1999, false, nil, nil)
+		if len(options) != 3 {
+			t.Fatalf("CalculateOptions() returned %d options, want 3", len(options))
+		}
+		expected := map[string]int{
+			SpeedEconomy:  599,  // $9.99 * 0.6
+			SpeedStandard: 999,  // $9.99
+			SpeedExpress:  1998, // $9.99 * 2
+		}
+		for _, option := range options {
+			if option.CostCents != expected[option.Speed] {
+				t.Errorf("%s cost = %d, want %d", option.Speed, option.CostCents, expected[option.Speed])
+			}
+		}
+	})
+
+	t.Run("Free shipping does not apply to express", func(t *testing.T) {
+		options := calc.CalculateOptions("walmart", "", 5000, false, nil, nil)
+		for _, option := range options {
+			switch option.Speed {
+			case SpeedEconomy, SpeedStandard:
+				if option.CostCents != 0 {
+					t.Errorf("%s cost = %d, want 0", option.Speed, option.CostCents)
+				}
+			case SpeedExpress:
+				if option.CostCents <= 0 {
+					t.Errorf("express cost = %d, want paid shipping", option.CostCents)
+				}
+			}
+		}
+	})
+
+	t.Run("Provider day range overrides standard", func(t *testing.T) {
+		minDays, maxDays := 1, 2
+		options := calc.CalculateOptions("amazon", "", 1000, true, &minDays, &maxDays)
+		for _, option := range options {
+			if option.Speed == SpeedStandard && (option.DaysMin != 1 || option.DaysMax != 2) {
+				t.Errorf("standard days = %d-%d, want 1-2", option.DaysMin, option.DaysMax)
+			}
+		}
+	})
+}
+
+func TestEstimate(t *testing.T) {
+	calc := NewCalculator(Config{
+		Mode:         "TABLE",
+		FeePercent:   3.0,
+		FreeShipping: map[string]FreeShippingRule{"walmart": {MinOrderCents: 3500}},
+	})
+
+	weight := 2500 // 2.5kg -> 2 extra kg
+
+	tests := []struct {
+		name             string
+		input            EstimateInput
+		expectedShipping int
+		expectedFree     bool
+		wantErr          bool
+	}{
+		{
+			name:             "Default destination is US",
+			input:            EstimateInput{PriceCents: 1999},
+			expectedShipping: 999,
+		},
+		{
+			name:             "Destination multiplier and weight surcharge",
+			input:            EstimateInput{PriceCents: 1999, Destination: "jp", WeightGrams: &weight},
+			expectedShipping: 2298, // $9.99 * 1.8 + $5.00 weight surcharge
+		},
+		{
+			name:             "Free shipping only for US",
+			input:            EstimateInput{Source: "walmart", PriceCents: 5000, Destination: "US"},
+			expectedShipping: 0,
+			expectedFree:     true,
+		},
+		{
+			name:             "Free shipping rule ignored abroad",
+			input:            EstimateInput{Source: "walmart", PriceCents: 5000, Destination: "CA"},
+			expectedShipping: 2599, // $19.99 * 1.3
+		},
+		{
+			name:    "Unsupported destination",
+			input:   EstimateInput{PriceCents: 1000, Destination: "ZZ"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := calc.Estimate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Estimate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if estimate.ShippingCents != tt.expectedShipping || estimate.FreeShipping != tt.expectedFree {
+				t.Errorf("Estimate() = (%d, %v), want (%d, %v)",
+					estimate.ShippingCents, estimate.FreeShipping, tt.expectedShipping, tt.expectedFree)
+			}
+		})
+	}
+
+	// The fee is reported on its own, not as part of the shipping cost
+	estimate, err := calc.Estimate(EstimateInput{PriceCents: 1999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.FeeCents != 60 {
+		t.Errorf("Estimate() fee = %d, want 60 (3%% of $19.99)", estimate.FeeCents)
+	}
+}
+
+func TestCalculateOptionsTo(t *testing.T) {
+	calc := NewCalculator(Config{
+		Mode:         "TABLE",
+		FreeShipping: map[string]FreeShippingRule{"walmart": {MinOrderCents: 3500}},
+	})
+
+	us, err := calc.CalculateOptionsTo("us", "walmart", "", 5000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jp, err := calc.CalculateOptionsTo("JP", "walmart", "", 5000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, speed := range Speeds {
+		if speed == SpeedStandard {
+			if us[i].CostCents != 0 {
+				t.Errorf("US standard cost = %d, want free", us[i].CostCents)
+			}
+			if jp[i].CostCents != 3598 { // $19.99 * 1.8, free shipping ignored abroad
+				t.Errorf("JP standard cost = %d, want 3598", jp[i].CostCents)
+			}
+		}
+	}
+
+	if _, err := calc.CalculateOptionsTo("ZZ", "walmart", "", 5000, false); err == nil {
+		t.Error("CalculateOptionsTo(ZZ) error = nil, want unsupported destination")
+	}
+}
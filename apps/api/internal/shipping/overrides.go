@@ -0,0 +1,46 @@
+package shipping
+
+import "github.com/pricecompare/api/internal/money"
+
+// Overrides are what-if pricing parameters, e.g. of the compare endpoint's fee_percent and
+// fx query parameters. Unset fields keep the configured values.
+type Overrides struct {
+	FeePercent *float64           // replaces the fee rules with a single percentage fee
+	FXRates    map[string]float64 // currency -> units per USD, replacing the configured rates
+}
+
+// IsZero reports whether o changes nothing
+func (o Overrides) IsZero() bool {
+	return o.FeePercent == nil && len(o.FXRates) == 0
+}
+
+// WithOverrides returns a copy of the calculator with o applied; c itself is not changed
+func (c *Calculator) WithOverrides(o Overrides) *Calculator {
+	config := *c.config.Load()
+	c.mu.RLock()
+	override := &Calculator{feeRules: c.feeRules, rateSource: c.rateSource}
+	c.mu.RUnlock()
+
+	if o.FeePercent != nil {
+		config.FeePercent = *o.FeePercent
+		override.feeRules = nil
+	}
+	if len(o.FXRates) > 0 {
+		override.rateSource = overrideRates{rates: o.FXRates, fallback: c}
+	}
+	override.config.Store(&config)
+	return override
+}
+
+// overrideRates serves the overridden rates and falls back to the original calculator's
+type overrideRates struct {
+	rates    map[string]float64
+	fallback *Calculator
+}
+
+func (r overrideRates) Rate(currency string) (float64, error) {
+	if rate, ok := r.rates[money.NormalizeCurrency(currency)]; ok {
+		return rate, nil
+	}
+	return r.fallback.FXRate(currency)
+}
@@ -0,0 +1,153 @@
+package shipping
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pricecompare/api/internal/category"
+)
+
+// Fee line types produced by fee rules
+const (
+	FeeLineFee      = "fee"
+	FeeLineDiscount = "discount"
+)
+
+// FeeRule is a single fee or discount rule. Empty conditions match everything.
+// A positive Percent/FixedCents is a fee, a negative one is a discount.
+type FeeRule struct {
+	Name          string  `yaml:"name" json:"name"`
+	Source        string  `yaml:"source,omitempty" json:"source,omitempty"`
+	Category      string  `yaml:"category,omitempty" json:"category,omitempty"`
+	MinPriceCents *int    `yaml:"min_price_cents,omitempty" json:"min_price_cents,omitempty"` // inclusive
+	MaxPriceCents *int    `yaml:"max_price_cents,omitempty" json:"max_price_cents,omitempty"` // exclusive
+	Percent       float64 `yaml:"percent,omitempty" json:"percent,omitempty"`
+	FixedCents    int     `yaml:"fixed_cents,omitempty" json:"fixed_cents,omitempty"`
+	Priority      int     `yaml:"priority,omitempty" json:"priority,omitempty"` // lower runs first
+}
+
+// FeeLine is the result of applying one matching rule to a price
+type FeeLine struct {
+	Type   string // FeeLineFee or FeeLineDiscount
+	Label  string // rule name
+	Amount int    // cents; negative for discounts
+}
+
+// Matches reports whether the rule applies to an offer
+func (r FeeRule) Matches(source, productCategory string, priceCents int) bool {
+	if r.Source != "" && r.Source != source {
+		return false
+	}
+	if r.Category != "" && category.Normalize(r.Category) != category.Normalize(productCategory) {
+		return false
+	}
+	if r.MinPriceCents != nil && priceCents < *r.MinPriceCents {
+		return false
+	}
+	if r.MaxPriceCents != nil && priceCents >= *r.MaxPriceCents {
+		return false
+	}
+	return true
+}
+
+// Validate checks that a rule is well-formed
+func (r FeeRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("fee rule name is required")
+	}
+	if r.MinPriceCents != nil && r.MaxPriceCents != nil && *r.MaxPriceCents <= *r.MinPriceCents {
+		return fmt.Errorf("fee rule %q: max_price_cents must be greater than min_price_cents", r.Name)
+	}
+	if r.Percent == 0 && r.FixedCents == 0 {
+		return fmt.Errorf("fee rule %q: percent or fixed_cents is required", r.Name)
+	}
+	return nil
+}
+
+// SetFeeRules replaces the fee rules used by ApplyFeeRules. Rules are applied in
+// priority order. An empty slice falls back to the single FeePercent rule.
+func (c *Calculator) SetFeeRules(rules []FeeRule) error {
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	sorted := make([]FeeRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	c.mu.Lock()
+	c.feeRules = sorted
+	c.mu.Unlock()
+	return nil
+}
+
+// FeeRules returns the active fee rules (the FeePercent fallback when none are configured)
+func (c *Calculator) FeeRules() []FeeRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.feeRules) == 0 {
+		return []FeeRule{{Name: "service_fee", Percent: c.config.Load().FeePercent}}
+	}
+	rules := make([]FeeRule, len(c.feeRules))
+	copy(rules, c.feeRules)
+	return rules
+}
+
+// ApplyFeeRules evaluates every matching rule for an offer and returns one line per rule
+func (c *Calculator) ApplyFeeRules(source, productCategory string, priceCents int) []FeeLine {
+	lines := []FeeLine{}
+	for _, rule := range c.FeeRules() {
+		if !rule.Matches(source, productCategory, priceCents) {
+			continue
+		}
+		amount := int(math.Round(float64(priceCents)*rule.Percent/100.0)) + rule.FixedCents
+		if amount == 0 {
+			continue
+		}
+		lineType := FeeLineFee
+		if amount < 0 {
+			lineType = FeeLineDiscount
+		}
+		lines = append(lines, FeeLine{Type: lineType, Label: rule.Name, Amount: amount})
+	}
+	return lines
+}
+
+// CalculateRuleFees returns the net amount (fees minus discounts) of all matching rules in cents
+func (c *Calculator) CalculateRuleFees(source, productCategory string, priceCents int) int {
+	total := 0
+	for _, line := range c.ApplyFeeRules(source, productCategory, priceCents) {
+		total += line.Amount
+	}
+	return total
+}
+
+// FeeRulesFile is the YAML document format for fee rules
+type FeeRulesFile struct {
+	Rules []FeeRule `yaml:"rules"`
+}
+
+// LoadFeeRulesFile reads fee rules from a YAML file
+func LoadFeeRulesFile(path string) ([]FeeRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fee rules file: %w", err)
+	}
+	var file FeeRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse fee rules file: %w", err)
+	}
+	for _, rule := range file.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return file.Rules, nil
+}
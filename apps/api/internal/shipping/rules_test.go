@@ -0,0 +1,128 @@
+package shipping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestApplyFeeRules(t *testing.T) {
+	calc := NewCalculator(Config{Mode: "TABLE", FeePercent: 3.0})
+	if err := calc.SetFeeRules([]FeeRule{
+		{Name: "high_value_discount", MinPriceCents: intPtr(50000), Percent: -1.0, Priority: 20},
+		{Name: "service_fee", Percent: 3.0, Priority: 10},
+		{Name: "amazon_handling", Source: "amazon", FixedCents: 200, Priority: 10},
+		{Name: "electronics_fee", Category: "electronics", Percent: 2.0, Priority: 15},
+	}); err != nil {
+		t.Fatalf("SetFeeRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		source     string
+		category   string
+		priceCents int
+		expected   []FeeLine
+	}{
+		{
+			name:       "Only the base fee matches",
+			source:     "walmart",
+			priceCents: 10000,
+			expected: []FeeLine{
+				{Type: FeeLineFee, Label: "service_fee", Amount: 300},
+			},
+		},
+		{
+			name:       "Source and category rules stack in priority order",
+			source:     "amazon",
+			category:   "Electronics",
+			priceCents: 10000,
+			expected: []FeeLine{
+				{Type: FeeLineFee, Label: "service_fee", Amount: 300},
+				{Type: FeeLineFee, Label: "amazon_handling", Amount: 200},
+				{Type: FeeLineFee, Label: "electronics_fee", Amount: 200},
+			},
+		},
+		{
+			name:       "Price band discount",
+			source:     "walmart",
+			priceCents: 60000,
+			expected: []FeeLine{
+				{Type: FeeLineFee, Label: "service_fee", Amount: 1800},
+				{Type: FeeLineDiscount, Label: "high_value_discount", Amount: -600},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := calc.ApplyFeeRules(tt.source, tt.category, tt.priceCents)
+			if len(lines) != len(tt.expected) {
+				t.Fatalf("ApplyFeeRules() = %+v, expected %+v", lines, tt.expected)
+			}
+			for i := range lines {
+				if lines[i] != tt.expected[i] {
+					t.Errorf("line %d = %+v, expected %+v", i, lines[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFeeRulesFallback(t *testing.T) {
+	calc := NewCalculator(Config{Mode: "TABLE", FeePercent: 3.0})
+
+	if got := calc.CalculateRuleFees("walmart", "", 10000); got != 300 {
+		t.Errorf("CalculateRuleFees() = %d, expected 300", got)
+	}
+}
+
+func TestSetFeeRulesValidation(t *testing.T) {
+	calc := NewCalculator(Config{Mode: "TABLE", FeePercent: 3.0})
+
+	tests := []struct {
+		name string
+		rule FeeRule
+	}{
+		{name: "Missing name", rule: FeeRule{Percent: 1.0}},
+		{name: "No amount", rule: FeeRule{Name: "empty"}},
+		{name: "Inverted price band", rule: FeeRule{Name: "band", Percent: 1.0, MinPriceCents: intPtr(500), MaxPriceCents: intPtr(100)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := calc.SetFeeRules([]FeeRule{tt.rule}); err == nil {
+				t.Error("SetFeeRules() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoadFeeRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fee_rules.yaml")
+	content := `
+rules:
+  - name: service_fee
+    percent: 3
+  - name: small_order_fee
+    max_price_cents: 2000
+    fixed_cents: 150
+    priority: 5
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadFeeRulesFile(path)
+	if err != nil {
+		t.Fatalf("LoadFeeRulesFile() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("LoadFeeRulesFile() returned %d rules, expected 2", len(rules))
+	}
+	if rules[1].MaxPriceCents == nil || *rules[1].MaxPriceCents != 2000 || rules[1].FixedCents != 150 {
+		t.Errorf("unexpected rule: %+v", rules[1])
+	}
+}
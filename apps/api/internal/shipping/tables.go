@@ -0,0 +1,117 @@
+package shipping
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pricecompare/api/internal/category"
+)
+
+// ShippingBand is one price band of a shipping table.
+// A nil MaxPriceCents is an open-ended band and must come last.
+type ShippingBand struct {
+	MaxPriceCents *int `yaml:"max_price_cents,omitempty"` // exclusive
+	CostCents     int  `yaml:"cost_cents"`
+}
+
+// ShippingTable is a price-banded carrier shipping table, ordered by MaxPriceCents
+type ShippingTable []ShippingBand
+
+func bandLimit(cents int) *int { return &cents }
+
+// DefaultShippingTable is the TABLE mode rate card used when no category override applies
+var DefaultShippingTable = ShippingTable{
+	{MaxPriceCents: bandLimit(2000), CostCents: 999},
+	{MaxPriceCents: bandLimit(5000), CostCents: 1499},
+	{CostCents: 1999},
+}
+
+// DefaultCategoryShippingTables overrides the default table for oversized categories
+var DefaultCategoryShippingTables = map[string]ShippingTable{
+	category.Furniture: {
+		{MaxPriceCents: bandLimit(10000), CostCents: 4999},
+		{CostCents: 7999},
+	},
+}
+
+// Lookup returns the cost of the band that contains priceCents
+func (t ShippingTable) Lookup(priceCents int) int {
+	for _, band := range t {
+		if band.MaxPriceCents == nil || priceCents < *band.MaxPriceCents {
+			return band.CostCents
+		}
+	}
+	if len(t) == 0 {
+		return 0
+	}
+	return t[len(t)-1].CostCents
+}
+
+// Validate checks that bands are in ascending order and end with an open-ended band
+func (t ShippingTable) Validate() error {
+	if len(t) == 0 {
+		return fmt.Errorf("shipping table has no bands")
+	}
+	previous := 0
+	for i, band := range t {
+		if band.CostCents < 0 {
+			return fmt.Errorf("band %d: cost_cents must not be negative", i)
+		}
+		if band.MaxPriceCents == nil {
+			if i != len(t)-1 {
+				return fmt.Errorf("band %d: only the last band may omit max_price_cents", i)
+			}
+			continue
+		}
+		if *band.MaxPriceCents <= previous {
+			return fmt.Errorf("band %d: max_price_cents must be ascending", i)
+		}
+		previous = *band.MaxPriceCents
+	}
+	if t[len(t)-1].MaxPriceCents != nil {
+		return fmt.Errorf("last band must omit max_price_cents")
+	}
+	return nil
+}
+
+// tableFor returns the TABLE mode rate card for a product category
+func (c *Calculator) tableFor(productCategory string) ShippingTable {
+	tables := c.config.Load().CategoryTables
+	if tables == nil {
+		tables = DefaultCategoryShippingTables
+	}
+	if productCategory != "" {
+		if table, ok := tables[category.Normalize(productCategory)]; ok {
+			return table
+		}
+	}
+	return DefaultShippingTable
+}
+
+// ShippingTablesFile is the YAML document format for per-category shipping tables
+type ShippingTablesFile struct {
+	Categories map[string]ShippingTable `yaml:"categories"`
+}
+
+// LoadShippingTablesFile reads per-category shipping tables from a YAML file.
+// Category keys are normalized to the category taxonomy.
+func LoadShippingTablesFile(path string) (map[string]ShippingTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shipping tables file: %w", err)
+	}
+	var file ShippingTablesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse shipping tables file: %w", err)
+	}
+	tables := make(map[string]ShippingTable, len(file.Categories))
+	for name, table := range file.Categories {
+		if err := table.Validate(); err != nil {
+			return nil, fmt.Errorf("shipping table %q: %w", name, err)
+		}
+		tables[category.Normalize(name)] = table
+	}
+	return tables, nil
+}
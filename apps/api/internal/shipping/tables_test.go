@@ -0,0 +1,84 @@
+package shipping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCategoryShippingTables(t *testing.T) {
+	calc := NewCalculator(Config{Mode: "TABLE", FeePercent: 3.0, FreeShipping: map[string]FreeShippingRule{}})
+
+	tests := []struct {
+		name             string
+		category         string
+		priceCents       int
+		expectedShipping int
+	}{
+		{name: "No category uses the default table", category: "", priceCents: 1999, expectedShipping: 999},
+		{name: "Category without override uses the default table", category: "electronics", priceCents: 6000, expectedShipping: 1999},
+		{name: "Furniture below $100", category: "furniture", priceCents: 6000, expectedShipping: 4999},
+		{name: "Furniture label above $100", category: "Furniture", priceCents: 25000, expectedShipping: 7999},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipping, _ := calc.CalculateOfferShipping("live", tt.category, tt.priceCents, false)
+			if shipping != tt.expectedShipping {
+				t.Errorf("CalculateOfferShipping() = %d, expected %d", shipping, tt.expectedShipping)
+			}
+		})
+	}
+}
+
+func TestShippingTableValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   ShippingTable
+		wantErr bool
+	}{
+		{name: "Default table", table: DefaultShippingTable, wantErr: false},
+		{name: "Empty table", table: ShippingTable{}, wantErr: true},
+		{name: "Missing open-ended band", table: ShippingTable{{MaxPriceCents: bandLimit(1000), CostCents: 500}}, wantErr: true},
+		{name: "Descending bands", table: ShippingTable{
+			{MaxPriceCents: bandLimit(5000), CostCents: 500},
+			{MaxPriceCents: bandLimit(1000), CostCents: 700},
+			{CostCents: 900},
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.table.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadShippingTablesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shipping_tables.yaml")
+	content := `
+categories:
+  Furniture:
+    - max_price_cents: 20000
+      cost_cents: 5999
+    - cost_cents: 9999
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := LoadShippingTablesFile(path)
+	if err != nil {
+		t.Fatalf("LoadShippingTablesFile() error = %v", err)
+	}
+	table, ok := tables["furniture"]
+	if !ok {
+		t.Fatalf("expected normalized furniture table, got %v", tables)
+	}
+	if got := table.Lookup(25000); got != 9999 {
+		t.Errorf("Lookup(25000) = %d, expected 9999", got)
+	}
+}
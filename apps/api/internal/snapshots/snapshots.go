@@ -0,0 +1,166 @@
+// Package snapshots archives the raw HTML of fetched pages in object storage, so pages
+// can be reparsed after a selector fix without refetching and shown when a price is
+// disputed.
+package snapshots
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrNotFound is returned by Get for a key that does not exist or is not a snapshot key
+var ErrNotFound = errors.New("snapshot not found")
+
+// timeLayout names snapshot objects; it sorts chronologically
+const timeLayout = "20060102T150405Z"
+
+// Snapshot describes one archived page
+type Snapshot struct {
+	Key       string    `json:"key"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Size      int64     `json:"size"` // compressed bytes
+}
+
+// Store archives and reads page snapshots
+type Store interface {
+	// Put stores html fetched from pageURL at fetchedAt
+	Put(ctx context.Context, pageURL string, fetchedAt time.Time, html []byte) (Snapshot, error)
+	// Get returns the decompressed HTML of a snapshot; keys Put did not name are not read
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the snapshots of a page, oldest first
+	List(ctx context.Context, pageURL string) ([]Snapshot, error)
+}
+
+// S3API is the subset of the S3 client used by S3Store
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// S3Store stores each snapshot as a gzipped object under
+// <prefix>/<sha256 of the URL>/<fetch time>.html.gz, so all snapshots of a page share a
+// prefix and the bucket's lifecycle rules control retention
+type S3Store struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+func NewS3Store(client S3API, bucket, prefix string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *S3Store) Put(ctx context.Context, pageURL string, fetchedAt time.Time, html []byte) (Snapshot, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(html); err != nil {
+		return Snapshot{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return Snapshot{}, err
+	}
+	size := int64(buf.Len())
+
+	key := s.urlPrefix(pageURL) + fetchedAt.UTC().Format(timeLayout) + ".html.gz"
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(buf.Bytes()),
+		ContentType:     aws.String("text/html; charset=utf-8"),
+		ContentEncoding: aws.String("gzip"),
+		Metadata:        map[string]string{"source-url": pageURL},
+	})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	return Snapshot{Key: key, FetchedAt: fetchedAt.UTC().Truncate(time.Second), Size: size}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	if !s.isSnapshotKey(key) {
+		return nil, ErrNotFound
+	}
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	defer out.Body.Close()
+
+	gz, err := gzip.NewReader(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s is not gzipped: %w", key, err)
+	}
+	return io.ReadAll(gz)
+}
+
+func (s *S3Store) List(ctx context.Context, pageURL string) ([]Snapshot, error) {
+	prefix := s.urlPrefix(pageURL)
+	snapshots := []Snapshot{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			fetchedAt, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".html.gz"))
+			if err != nil {
+				continue // not written by Put
+			}
+			snapshots = append(snapshots, Snapshot{Key: key, FetchedAt: fetchedAt, Size: aws.ToInt64(object.Size)})
+		}
+	}
+	return snapshots, nil
+}
+
+// isSnapshotKey reports whether key has the form of the keys written by Put, so other
+// objects of the bucket cannot be read through Get
+func (s *S3Store) isSnapshotKey(key string) bool {
+	rest := key
+	if prefix := path.Join(s.prefix); prefix != "" {
+		var ok bool
+		if rest, ok = strings.CutPrefix(key, prefix+"/"); !ok {
+			return false
+		}
+	}
+	urlHash, name, ok := strings.Cut(rest, "/")
+	if !ok || len(urlHash) != 32 {
+		return false
+	}
+	if _, err := hex.DecodeString(urlHash); err != nil {
+		return false
+	}
+	_, err := time.Parse(timeLayout, strings.TrimSuffix(name, ".html.gz"))
+	return err == nil && strings.HasSuffix(name, ".html.gz")
+}
+
+// urlPrefix is the key prefix shared by all snapshots of a page
+func (s *S3Store) urlPrefix(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return path.Join(s.prefix, hex.EncodeToString(sum[:16])) + "/"
+}
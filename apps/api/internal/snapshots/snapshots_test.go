@@ -0,0 +1,102 @@
+package snapshots
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// memoryS3 is an in-memory bucket
+type memoryS3 struct {
+	objects map[string][]byte
+}
+
+func (m *memoryS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[aws.ToString(params.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *memoryS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := m.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (m *memoryS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := []string{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(m.objects[key])))})
+	}
+	return out, nil
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	bucket := &memoryS3{objects: map[string][]byte{}}
+	store := NewS3Store(bucket, "bucket", "snapshots")
+	pageURL := "https://example.com/search?q=sony"
+	first := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	snapshot, err := store.Put(ctx, pageURL, first, []byte("<html>v1</html>"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	firstKey := snapshot.Key
+	if !strings.HasPrefix(firstKey, "snapshots/") || !strings.HasSuffix(firstKey, "/20260301T090000Z.html.gz") {
+		t.Errorf("key = %q", firstKey)
+	}
+	if !snapshot.FetchedAt.Equal(first) || snapshot.Size != int64(len(bucket.objects[firstKey])) {
+		t.Errorf("Put() = %+v", snapshot)
+	}
+	if _, err := store.Put(ctx, pageURL, first.Add(time.Hour), []byte("<html>v2</html>")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := store.Put(ctx, "https://example.com/other", first, []byte("<html></html>")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	html, err := store.Get(ctx, firstKey)
+	if err != nil || string(html) != "<html>v1</html>" {
+		t.Errorf("Get() = %q, %v", html, err)
+	}
+	if _, err := store.Get(ctx, "snapshots/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing key error = %v, want ErrNotFound", err)
+	}
+	// Other objects of the bucket are not served, even when they exist
+	bucket.objects["config/credentials.html.gz"] = bucket.objects[firstKey]
+	for _, key := range []string{"config/credentials.html.gz", strings.TrimPrefix(firstKey, "snapshots/"), "snapshots/../" + strings.TrimPrefix(firstKey, "snapshots/")} {
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrNotFound", key, err)
+		}
+	}
+
+	list, err := store.List(ctx, pageURL)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Key != firstKey || !list[1].FetchedAt.Equal(first.Add(time.Hour)) || list[0].Size == 0 {
+		t.Errorf("List() = %+v", list)
+	}
+}
@@ -0,0 +1,23 @@
+package tracing
+
+import "testing"
+
+func TestTracesURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		expected string
+	}{
+		{"http://otel-collector:4318", "http://otel-collector:4318/v1/traces"},
+		{"http://otel-collector:4318/", "http://otel-collector:4318/v1/traces"},
+		{"https://collector.example.com/otlp/v1/traces", "https://collector.example.com/otlp/v1/traces"},
+	}
+	for _, tt := range tests {
+		got, err := tracesURL(tt.endpoint)
+		if err != nil || got != tt.expected {
+			t.Errorf("tracesURL(%q) = %q, %v, want %q", tt.endpoint, got, err, tt.expected)
+		}
+	}
+	if _, err := tracesURL("otel-collector:4318"); err == nil {
+		t.Error("tracesURL() without a scheme: error = nil")
+	}
+}
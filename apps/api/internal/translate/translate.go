@@ -0,0 +1,164 @@
+// Package translate translates listing titles between languages with a pluggable
+// backend, so a Japanese listing can be shown with an English title and vice versa.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Translator translates a text from one language to another. Languages are ISO 639-1
+// codes as stored on source_products ("ja", "en").
+type Translator interface {
+	Name() string
+	Translate(ctx context.Context, text, from, to string) (string, error)
+}
+
+const defaultTimeout = 15 * time.Second
+
+// DeepLTranslator calls the DeepL API (https://api-free.deepl.com for free keys,
+// https://api.deepl.com for paid ones)
+type DeepLTranslator struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func NewDeepLTranslator(apiKey, baseURL string) *DeepLTranslator {
+	return &DeepLTranslator{
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (t *DeepLTranslator) Name() string {
+	return "deepl"
+}
+
+func (t *DeepLTranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+	var response struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	headers := map[string]string{"Authorization": "DeepL-Auth-Key " + t.apiKey}
+	body := map[string]any{
+		"text":        []string{text},
+		"source_lang": strings.ToUpper(from),
+		"target_lang": deepLTarget(to),
+	}
+	if err := postJSON(ctx, t.client, t.baseURL+"/v2/translate", headers, body, &response); err != nil {
+		return "", err
+	}
+	if len(response.Translations) == 0 || response.Translations[0].Text == "" {
+		return "", fmt.Errorf("DeepL returned no translation")
+	}
+	return response.Translations[0].Text, nil
+}
+
+// deepLTarget returns the DeepL target language of a language; English needs a variant
+func deepLTarget(language string) string {
+	if language == "en" {
+		return "EN-US"
+	}
+	return strings.ToUpper(language)
+}
+
+// OpenAITranslator asks an OpenAI chat model for the translation
+type OpenAITranslator struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenAITranslator(apiKey, model string) *OpenAITranslator {
+	return &OpenAITranslator{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: "https://api.openai.com/v1",
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (t *OpenAITranslator) Name() string {
+	return "openai"
+}
+
+func (t *OpenAITranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	prompt := fmt.Sprintf("Translate this product listing title from %s to %s. Keep brand names, model numbers and units as they are. Reply with the translated title only.", languageName(from), languageName(to))
+	headers := map[string]string{"Authorization": "Bearer " + t.apiKey}
+	body := map[string]any{
+		"model": t.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": prompt},
+			{"role": "user", "content": text},
+		},
+		"temperature": 0,
+	}
+	if err := postJSON(ctx, t.client, t.baseURL+"/chat/completions", headers, body, &response); err != nil {
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI returned no translation")
+	}
+	translated := strings.TrimSpace(response.Choices[0].Message.Content)
+	if translated == "" {
+		return "", fmt.Errorf("OpenAI returned an empty translation")
+	}
+	return translated, nil
+}
+
+func languageName(language string) string {
+	switch language {
+	case "ja":
+		return "Japanese"
+	case "en":
+		return "English"
+	}
+	return language
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("translation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("translation API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode translation response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeepLTranslator(t *testing.T) {
+	var got struct {
+		Text       []string `json:"text"`
+		SourceLang string   `json:"source_lang"`
+		TargetLang string   `json:"target_lang"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/translate" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "DeepL-Auth-Key test-key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(map[string]any{
+			"translations": []map[string]any{{"detected_source_language": "JA", "text": "Sony wireless headphones WH-1000XM5"}},
+		})
+	}))
+	defer server.Close()
+
+	translator := NewDeepLTranslator("test-key", server.URL+"/")
+	translated, err := translator.Translate(context.Background(), "ソニー ワイヤレスヘッドホン WH-1000XM5", "ja", "en")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if translated != "Sony wireless headphones WH-1000XM5" {
+		t.Errorf("Translate() = %q", translated)
+	}
+	if len(got.Text) != 1 || got.SourceLang != "JA" || got.TargetLang != "EN-US" {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestOpenAITranslator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": " ソニー ワイヤレスヘッドホン\n"}}},
+		})
+	}))
+	defer server.Close()
+
+	translator := NewOpenAITranslator("test-key", "gpt-4o-mini")
+	translator.baseURL = server.URL
+	translated, err := translator.Translate(context.Background(), "Sony Wireless Headphones", "en", "ja")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if translated != "ソニー ワイヤレスヘッドホン" {
+		t.Errorf("Translate() = %q", translated)
+	}
+}
+
+func TestTranslatorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", 456)
+	}))
+	defer server.Close()
+
+	if _, err := NewDeepLTranslator("test-key", server.URL).Translate(context.Background(), "a", "en", "ja"); err == nil {
+		t.Error("Translate() expected error when the API fails")
+	}
+}
@@ -0,0 +1,57 @@
+// Package strx holds the string helpers shared by providers and tests. Case-insensitive
+// matching uses Unicode case folding, so non-ASCII titles ("ÉCOUTEURS") match like ASCII
+// ones.
+package strx
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Ptr returns a pointer to s
+func Ptr(s string) *string {
+	return &s
+}
+
+// NonEmptyPtr returns a pointer to s, or nil if s is empty, for optional fields filled
+// from parsed values
+func NonEmptyPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ContainsFold reports whether substr is within s under Unicode case folding, like
+// strings.EqualFold for substrings
+func ContainsFold(s, substr string) bool {
+	if substr == "" {
+		return true
+	}
+	n := utf8.RuneCountInString(substr)
+	for i := range s {
+		// The n runes starting at i; simple case folding maps rune to rune
+		j := i
+		for k := 0; k < n && j < len(s); k++ {
+			_, size := utf8.DecodeRuneInString(s[j:])
+			j += size
+		}
+		if strings.EqualFold(s[i:j], substr) {
+			return true
+		}
+		if j == len(s) {
+			return false
+		}
+	}
+	return false
+}
+
+// ContainsAll reports whether s contains every one of substrs
+func ContainsAll(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			return false
+		}
+	}
+	return true
+}
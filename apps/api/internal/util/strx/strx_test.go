@@ -0,0 +1,47 @@
+package strx
+
+import "testing"
+
+func TestContainsFold(t *testing.T) {
+	tests := []struct {
+		s, substr string
+		want      bool
+	}{
+		{"Wireless Headphones Pro", "headphones", true},
+		{"Wireless Headphones Pro", "HEADPHONES PRO", true},
+		{"ÉCOUTEURS SANS FIL", "écouteurs", true},
+		{"Σony ΑΚΟΥΣΤΙΚΆ", "ακουστικά", true},
+		{"\u212Aelvin thermometer", "kelvin", true}, // KELVIN SIGN folds to k
+		{"ソニー ヘッドホン", "ヘッドホン", true},
+		{"Headphones", "headphones pro", false},
+		{"Café", "cafe", false},
+		{"anything", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := ContainsFold(tt.s, tt.substr); got != tt.want {
+			t.Errorf("ContainsFold(%q, %q) = %v, want %v", tt.s, tt.substr, got, tt.want)
+		}
+	}
+}
+
+func TestContainsAll(t *testing.T) {
+	if !ContainsAll("https://shop.example.com/sony-wh-1000xm5", []string{"sony", "1000xm5"}) {
+		t.Error("ContainsAll() = false with every word present")
+	}
+	if ContainsAll("https://shop.example.com/sony-wh-1000xm5", []string{"sony", "bose"}) {
+		t.Error("ContainsAll() = true with a word missing")
+	}
+}
+
+func TestPtr(t *testing.T) {
+	if p := Ptr(""); p == nil || *p != "" {
+		t.Errorf("Ptr(\"\") = %v, want a pointer to \"\"", p)
+	}
+	if NonEmptyPtr("") != nil {
+		t.Error("NonEmptyPtr(\"\") != nil")
+	}
+	if p := NonEmptyPtr("Sony"); p == nil || *p != "Sony" {
+		t.Errorf("NonEmptyPtr(\"Sony\") = %v", p)
+	}
+}
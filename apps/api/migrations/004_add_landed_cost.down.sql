@@ -0,0 +1,13 @@
+-- Rollback for 004_add_landed_cost.up.sql
+
+DROP INDEX IF EXISTS idx_offers_landed_cost_amount;
+
+ALTER TABLE offers
+    DROP COLUMN IF EXISTS ships_from_country,
+    DROP COLUMN IF EXISTS duty_amount,
+    DROP COLUMN IF EXISTS landed_cost_amount;
+
+DROP INDEX IF EXISTS idx_products_category;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS category;
@@ -0,0 +1,16 @@
+-- Product categories and import duty / landed cost estimation for cross-border offers
+
+ALTER TABLE products
+    ADD COLUMN category TEXT;
+
+CREATE INDEX idx_products_category ON products(category);
+
+ALTER TABLE offers
+    ADD COLUMN ships_from_country TEXT,
+    ADD COLUMN duty_amount INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN landed_cost_amount INTEGER NOT NULL DEFAULT 0;
+
+-- Existing offers are domestic, so landed cost equals the total
+UPDATE offers SET landed_cost_amount = total_to_us_amount;
+
+CREATE INDEX idx_offers_landed_cost_amount ON offers(landed_cost_amount);
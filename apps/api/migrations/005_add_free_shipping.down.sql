@@ -0,0 +1,3 @@
+-- Rollback for 005_add_free_shipping.up.sql
+ALTER TABLE offers
+    DROP COLUMN IF EXISTS free_shipping;
@@ -0,0 +1,3 @@
+-- Track whether an offer ships to the US for free (provider-reported or threshold rule)
+ALTER TABLE offers
+    ADD COLUMN free_shipping BOOLEAN NOT NULL DEFAULT false;
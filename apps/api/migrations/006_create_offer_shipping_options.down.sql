@@ -0,0 +1,3 @@
+-- Rollback for 006_create_offer_shipping_options.up.sql
+DROP INDEX IF EXISTS idx_offer_shipping_options_offer_id;
+DROP TABLE IF EXISTS offer_shipping_options;
@@ -0,0 +1,14 @@
+-- Per-offer shipping options (economy / standard / express)
+CREATE TABLE offer_shipping_options (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    offer_id UUID NOT NULL REFERENCES offers(id) ON DELETE CASCADE,
+    speed TEXT NOT NULL,
+    cost_amount INTEGER NOT NULL,
+    est_delivery_days_min INTEGER,
+    est_delivery_days_max INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (offer_id, speed)
+);
+
+CREATE INDEX idx_offer_shipping_options_offer_id ON offer_shipping_options(offer_id);
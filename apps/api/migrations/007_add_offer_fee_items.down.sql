@@ -0,0 +1,3 @@
+-- Rollback for 007_add_offer_fee_items.up.sql
+ALTER TABLE offers
+    DROP COLUMN IF EXISTS fee_items;
@@ -0,0 +1,3 @@
+-- Itemized fees (service fee, FX markup) on offers; fee_amount holds their sum
+ALTER TABLE offers
+    ADD COLUMN fee_items JSONB NOT NULL DEFAULT '[]'::jsonb;
@@ -0,0 +1,3 @@
+-- Rollback for 008_create_fee_rules.up.sql
+DROP INDEX IF EXISTS idx_fee_rules_enabled_priority;
+DROP TABLE IF EXISTS fee_rules;
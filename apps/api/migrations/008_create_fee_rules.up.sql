@@ -0,0 +1,17 @@
+-- Fee / discount rules evaluated by the shipping calculator (replaces the single SHIPPING_FEE_PERCENT)
+CREATE TABLE fee_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    source TEXT,
+    category TEXT,
+    min_price_amount INTEGER,
+    max_price_amount INTEGER,
+    percent NUMERIC(6, 3) NOT NULL DEFAULT 0,
+    fixed_amount INTEGER NOT NULL DEFAULT 0,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_fee_rules_enabled_priority ON fee_rules(enabled, priority);
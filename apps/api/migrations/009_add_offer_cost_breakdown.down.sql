@@ -0,0 +1,3 @@
+-- Rollback for 009_add_offer_cost_breakdown.up.sql
+ALTER TABLE offers
+    DROP COLUMN IF EXISTS cost_breakdown;
@@ -0,0 +1,3 @@
+-- Structured breakdown of how an offer's totals were derived (item, shipping, fee, tax, duty, FX rate)
+ALTER TABLE offers
+    ADD COLUMN cost_breakdown JSONB;
@@ -0,0 +1,2 @@
+-- Rollback for 010_add_products_title_trgm.up.sql
+DROP INDEX IF EXISTS idx_products_title_trgm;
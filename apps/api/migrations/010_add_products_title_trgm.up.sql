@@ -0,0 +1,4 @@
+-- Trigram index for fuzzy title matching of provider candidates
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_products_title_trgm ON products USING gin (title gin_trgm_ops);
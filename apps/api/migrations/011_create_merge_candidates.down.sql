@@ -0,0 +1,4 @@
+-- Rollback for 011_create_merge_candidates.up.sql
+DROP INDEX IF EXISTS idx_merge_candidates_duplicate_product_id;
+DROP INDEX IF EXISTS idx_merge_candidates_status;
+DROP TABLE IF EXISTS merge_candidates;
@@ -0,0 +1,19 @@
+-- Probable duplicate products found by the detect_duplicates job.
+-- duplicate_product_id has no foreign key so merged suggestions are kept for auditing
+-- after the duplicate product is deleted.
+CREATE TABLE merge_candidates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    duplicate_product_id UUID NOT NULL,
+    reason TEXT NOT NULL,
+    score NUMERIC(5, 4) NOT NULL,
+    detail TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (product_id, duplicate_product_id)
+);
+
+CREATE INDEX idx_merge_candidates_status ON merge_candidates(status);
+CREATE INDEX idx_merge_candidates_duplicate_product_id ON merge_candidates(duplicate_product_id);
@@ -0,0 +1,3 @@
+-- Rollback for 012_create_product_embeddings.up.sql
+DROP INDEX IF EXISTS idx_product_embeddings_model;
+DROP TABLE IF EXISTS product_embeddings;
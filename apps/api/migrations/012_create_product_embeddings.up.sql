@@ -0,0 +1,27 @@
+-- Title embeddings for nearest-neighbor product matching (EMBEDDING_BACKEND). The table
+-- needs the pgvector extension; on servers without it the migration only reports a notice,
+-- and embedding matching cannot be enabled until pgvector is installed and this file is
+-- run again with psql (it is idempotent).
+-- The vector column has no fixed dimension so different embedding models can coexist;
+-- vectors are only compared within the same model.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        RAISE NOTICE 'pgvector is not available, product_embeddings is not created';
+        RETURN;
+    END IF;
+
+    CREATE EXTENSION IF NOT EXISTS vector;
+
+    CREATE TABLE IF NOT EXISTS product_embeddings (
+        product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+        model TEXT NOT NULL,
+        embedding vector NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (product_id, model)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_product_embeddings_model ON product_embeddings(model);
+END
+$$;
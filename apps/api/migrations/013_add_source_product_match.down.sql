@@ -0,0 +1,5 @@
+-- Rollback for 013_add_source_product_match.up.sql
+DROP INDEX IF EXISTS idx_source_products_match_confidence;
+ALTER TABLE source_products
+    DROP COLUMN IF EXISTS match_confidence,
+    DROP COLUMN IF EXISTS match_method;
@@ -0,0 +1,7 @@
+-- How each source listing was linked to its product, so low-confidence links can be re-evaluated.
+-- Existing listings were all linked by identifier (ResolveURL).
+ALTER TABLE source_products
+    ADD COLUMN match_method TEXT NOT NULL DEFAULT 'identifier',
+    ADD COLUMN match_confidence NUMERIC(5, 4) NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_source_products_match_confidence ON source_products(match_confidence);
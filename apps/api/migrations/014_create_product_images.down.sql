@@ -0,0 +1,3 @@
+-- Rollback for 014_create_product_images.up.sql
+DROP INDEX IF EXISTS idx_product_images_image_url;
+DROP TABLE IF EXISTS product_images;
@@ -0,0 +1,14 @@
+-- Perceptual hashes (pHash) of product images, used as a matching signal during ingestion
+-- and for image search. phash holds the 64-bit hash as a signed BIGINT; similarity is the
+-- Hamming distance bit_count(phash # other).
+CREATE TABLE product_images (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    image_url TEXT NOT NULL,
+    phash BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (product_id, image_url)
+);
+
+CREATE INDEX idx_product_images_image_url ON product_images(image_url);
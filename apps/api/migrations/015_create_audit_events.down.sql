@@ -0,0 +1,3 @@
+-- Rollback for 015_create_audit_events.up.sql
+DROP INDEX IF EXISTS idx_audit_events_type_logged_at;
+DROP TABLE IF EXISTS audit_events;
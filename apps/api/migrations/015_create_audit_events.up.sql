@@ -0,0 +1,11 @@
+-- Audit events (outbound HTTP requests, FX fallbacks) when AUDIT_SINK=postgres.
+-- payload holds the full JSON record; type and logged_at are extracted for retention
+-- queries and pruning.
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    logged_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    payload JSONB NOT NULL
+);
+
+CREATE INDEX idx_audit_events_type_logged_at ON audit_events(type, logged_at);
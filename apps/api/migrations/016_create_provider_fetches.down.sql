@@ -0,0 +1,5 @@
+-- Rollback for 016_create_provider_fetches.up.sql
+DROP INDEX IF EXISTS idx_offers_source_fetched_at;
+DROP INDEX IF EXISTS idx_provider_fetches_created_at;
+DROP INDEX IF EXISTS idx_provider_fetches_provider_created_at;
+DROP TABLE IF EXISTS provider_fetches;
@@ -0,0 +1,18 @@
+-- Outcome of each provider call (search / fetch_offers) made by the fetch_prices job,
+-- used for per-provider error rates in GET /api/admin/stats/providers. Rows older than
+-- 30 days are pruned by the job.
+CREATE TABLE provider_fetches (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    operation VARCHAR(20) NOT NULL,
+    success BOOLEAN NOT NULL,
+    error TEXT,
+    duration_ms INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_provider_fetches_provider_created_at ON provider_fetches(provider, created_at);
+CREATE INDEX idx_provider_fetches_created_at ON provider_fetches(created_at);
+
+-- Per-source aggregation of offers (count, newest fetched_at, staleness)
+CREATE INDEX idx_offers_source_fetched_at ON offers(source, fetched_at);
@@ -0,0 +1,4 @@
+-- Rollback for 017_add_source_product_snapshot.up.sql
+ALTER TABLE source_products
+    DROP COLUMN IF EXISTS snapshot_at,
+    DROP COLUMN IF EXISTS snapshot_key;
@@ -0,0 +1,5 @@
+-- Latest raw HTML snapshot of the page a listing was parsed from (an object key in
+-- SNAPSHOT_S3_BUCKET), for reparsing after selector fixes and dispute resolution.
+ALTER TABLE source_products
+    ADD COLUMN snapshot_key TEXT,
+    ADD COLUMN snapshot_at TIMESTAMP WITH TIME ZONE;
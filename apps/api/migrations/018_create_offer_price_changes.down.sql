@@ -0,0 +1,3 @@
+-- Rollback for 018_create_offer_price_changes.up.sql
+ALTER TABLE provider_fetches DROP COLUMN IF EXISTS result_count;
+DROP TABLE IF EXISTS offer_price_changes;
@@ -0,0 +1,23 @@
+-- Price history: one row per change of an offer's price, recorded by the fetch_prices
+-- job when a refreshed offer's price differs from the stored one. Offers are replaced
+-- on every refresh but keep their ID, so offer_id has no foreign key.
+CREATE TABLE offer_price_changes (
+    id BIGSERIAL PRIMARY KEY,
+    offer_id UUID NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    source VARCHAR(50) NOT NULL,
+    seller VARCHAR(255) NOT NULL,
+    old_price_amount INTEGER NOT NULL,
+    new_price_amount INTEGER NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    old_total_to_us_amount INTEGER NOT NULL,
+    new_total_to_us_amount INTEGER NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_offer_price_changes_changed_at ON offer_price_changes(changed_at);
+CREATE INDEX idx_offer_price_changes_product_id ON offer_price_changes(product_id, changed_at);
+
+-- Number of candidates / offers a successful provider call returned, for spotting
+-- providers that silently return nothing
+ALTER TABLE provider_fetches ADD COLUMN result_count INTEGER;
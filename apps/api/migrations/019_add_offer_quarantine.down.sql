@@ -0,0 +1,3 @@
+-- Rollback for 019_add_offer_quarantine.up.sql
+DROP INDEX IF EXISTS idx_offers_quarantined;
+ALTER TABLE offers DROP COLUMN IF EXISTS quarantine_reason;
@@ -0,0 +1,7 @@
+-- Offers the fetch_prices job considers implausible (zero price, currency different
+-- from the previous fetch, total far below the product's price history) are stored
+-- with a reason and hidden from the offers, compare and search results until a later
+-- fetch looks plausible again.
+ALTER TABLE offers ADD COLUMN quarantine_reason VARCHAR(50);
+
+CREATE INDEX idx_offers_quarantined ON offers(updated_at) WHERE quarantine_reason IS NOT NULL;
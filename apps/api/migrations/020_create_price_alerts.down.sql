@@ -0,0 +1,2 @@
+-- Rollback for 020_create_price_alerts.up.sql
+DROP TABLE IF EXISTS price_alerts;
@@ -0,0 +1,16 @@
+-- Price drop alert subscriptions, evaluated by the evaluate_alerts job after every
+-- fetch_prices run. triggered_at is set when the alert fires and cleared when the
+-- cheapest total rises above target_price_cents again.
+CREATE TABLE price_alerts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,
+    target TEXT NOT NULL,
+    target_price_cents INTEGER NOT NULL,
+    triggered_at TIMESTAMP WITH TIME ZONE,
+    triggered_cents INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_price_alerts_product_id ON price_alerts(product_id);
@@ -0,0 +1,2 @@
+-- Rollback for 021_add_source_product_language.up.sql
+ALTER TABLE source_products DROP COLUMN IF EXISTS language;
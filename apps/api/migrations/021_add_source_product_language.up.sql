@@ -0,0 +1,4 @@
+-- Language of each provider listing ("ja", "en"), from the page, the provider locale
+-- (PROVIDER_LOCALES) or the title script, so Japanese and English listings of one
+-- product can be told apart when matching and displaying titles.
+ALTER TABLE source_products ADD COLUMN language VARCHAR(10);
@@ -0,0 +1,2 @@
+-- Rollback for 022_add_product_image_dhash.up.sql
+ALTER TABLE product_images DROP COLUMN IF EXISTS dhash;
@@ -0,0 +1,4 @@
+-- Difference hash (dHash) of product images, ranking image search results that have the
+-- same pHash distance. Rows hashed before this column existed are NULL until the
+-- backfill_image_hashes job or the next ingestion of the image hashes them again.
+ALTER TABLE product_images ADD COLUMN dhash BIGINT;
@@ -0,0 +1,5 @@
+-- Rollback for 023_add_offer_lifecycle.up.sql
+DROP INDEX IF EXISTS idx_offers_delisted;
+ALTER TABLE offers DROP COLUMN IF EXISTS delisted_at;
+ALTER TABLE offers DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE offers DROP COLUMN IF EXISTS first_seen_at;
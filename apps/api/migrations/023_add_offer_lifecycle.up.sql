@@ -0,0 +1,16 @@
+-- Offer lifecycle: instead of deleting a source's offers before each refresh, the
+-- fetch_prices job keeps them and marks offers missing from the latest fetch as
+-- delisted. first_seen_at / last_seen_at record how long a listing has been available;
+-- a delisted offer that is returned again is relisted (delisted_at cleared).
+ALTER TABLE offers ADD COLUMN first_seen_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE offers ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE offers ADD COLUMN delisted_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE offers SET first_seen_at = created_at, last_seen_at = fetched_at;
+
+ALTER TABLE offers ALTER COLUMN first_seen_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE offers ALTER COLUMN first_seen_at SET NOT NULL;
+ALTER TABLE offers ALTER COLUMN last_seen_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE offers ALTER COLUMN last_seen_at SET NOT NULL;
+
+CREATE INDEX idx_offers_delisted ON offers(product_id, delisted_at) WHERE delisted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Rollback for 024_create_product_revisions.up.sql
+DROP TABLE IF EXISTS product_revisions;
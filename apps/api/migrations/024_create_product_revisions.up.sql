@@ -0,0 +1,12 @@
+-- Curator edits of product metadata (PATCH /api/admin/products/:id). changes maps each
+-- edited field to its old and new value. Fields with a revision are no longer
+-- overwritten by ingestion.
+CREATE TABLE product_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    changes JSONB NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_product_revisions_product_id ON product_revisions(product_id, created_at DESC);
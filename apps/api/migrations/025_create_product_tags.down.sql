@@ -0,0 +1,2 @@
+-- Rollback for 025_create_product_tags.up.sql
+DROP TABLE IF EXISTS product_tags;
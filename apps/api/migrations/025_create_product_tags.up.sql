@@ -0,0 +1,11 @@
+-- Curator labels on products (e.g. "black friday deals"), set with the admin tag
+-- endpoints and filtered on with GET /api/search?tag=. Tags are stored normalized
+-- (lowercase, single spaces).
+CREATE TABLE product_tags (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_id, tag)
+);
+
+CREATE INDEX idx_product_tags_tag ON product_tags(tag);
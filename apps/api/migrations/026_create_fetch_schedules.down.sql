@@ -0,0 +1,2 @@
+-- Rollback for 026_create_fetch_schedules.up.sql
+DROP TABLE IF EXISTS fetch_schedules;
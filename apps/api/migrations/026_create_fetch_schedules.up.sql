@@ -0,0 +1,14 @@
+-- Recurring fetch_prices runs. Schedules with origin 'env' mirror the FETCH_CRON_<SOURCE>
+-- variables and are replaced at startup; 'api' schedules are managed through
+-- /api/admin/schedules. Paused schedules are kept but not run.
+CREATE TABLE fetch_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    source VARCHAR(50) NOT NULL,
+    cron VARCHAR(100) NOT NULL,
+    origin VARCHAR(10) NOT NULL DEFAULT 'api',
+    paused BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_fetch_schedules_env_source ON fetch_schedules(source) WHERE origin = 'env';
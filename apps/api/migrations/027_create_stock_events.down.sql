@@ -0,0 +1,2 @@
+-- Rollback for 027_create_stock_events.up.sql
+DROP TABLE IF EXISTS stock_events;
@@ -0,0 +1,16 @@
+-- Stock history: one row per in/out-of-stock transition of an offer, recorded by the
+-- fetch_prices job when a refreshed offer's in_stock differs from the stored one.
+-- Like offer_price_changes, offer_id has no foreign key because offers keep their ID
+-- across refreshes but may be replaced.
+CREATE TABLE stock_events (
+    id BIGSERIAL PRIMARY KEY,
+    offer_id UUID NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    source VARCHAR(50) NOT NULL,
+    seller VARCHAR(255) NOT NULL,
+    in_stock BOOLEAN NOT NULL,
+    availability_status VARCHAR(50),
+    occurred_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_events_product_id ON stock_events(product_id, occurred_at DESC);
@@ -0,0 +1,2 @@
+-- Rollback for 028_create_search_queries.up.sql
+DROP TABLE IF EXISTS search_queries;
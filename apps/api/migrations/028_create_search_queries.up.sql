@@ -0,0 +1,13 @@
+-- Search queries the fetch_prices job runs per provider, managed through
+-- /api/admin/queries. Enabled queries run in priority order (highest first); a
+-- provider without any rows uses the built-in defaults of the job.
+CREATE TABLE search_queries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR(50) NOT NULL,
+    query VARCHAR(200) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, query)
+);
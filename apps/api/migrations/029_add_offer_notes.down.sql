@@ -0,0 +1,3 @@
+-- Rollback for 029_add_offer_notes.up.sql
+ALTER TABLE offers DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE offers DROP COLUMN IF EXISTS notes;
@@ -0,0 +1,6 @@
+-- Offer notes and manual offers: admins annotate offers and enter offers of sources
+-- without a provider (source 'manual', e.g. prices quoted over the phone) through
+-- /api/admin/offers. An offer with expires_at is published until then. The
+-- fetch_prices job keeps both columns when it refreshes an offer.
+ALTER TABLE offers ADD COLUMN notes TEXT;
+ALTER TABLE offers ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
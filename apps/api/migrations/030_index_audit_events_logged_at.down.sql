@@ -0,0 +1,2 @@
+-- Rollback for 030_index_audit_events_logged_at.up.sql
+DROP INDEX IF EXISTS idx_audit_events_logged_at;
@@ -0,0 +1,2 @@
+-- The db_maintenance job prunes audit events of every type by logged_at
+CREATE INDEX idx_audit_events_logged_at ON audit_events(logged_at);
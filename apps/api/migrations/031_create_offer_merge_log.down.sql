@@ -0,0 +1,2 @@
+-- Rollback for 031_create_offer_merge_log.up.sql
+DROP TABLE IF EXISTS offer_merge_log;
@@ -0,0 +1,20 @@
+-- Offer deduplication decisions: one row per offer the fetch_prices job collapsed into
+-- another offer of the same product and source (same page, an affiliate-tagged link to
+-- it, or the same seller spelled differently). Like stock_events, offer ids have no
+-- foreign key because offers keep their ID across refreshes but may be replaced.
+CREATE TABLE offer_merge_log (
+    id BIGSERIAL PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    source VARCHAR(50) NOT NULL,
+    kept_offer_id UUID NOT NULL,
+    kept_seller VARCHAR(255) NOT NULL,
+    kept_url TEXT,
+    merged_seller VARCHAR(255) NOT NULL,
+    merged_url TEXT,
+    merged_price_amount INTEGER NOT NULL,
+    merged_currency TEXT NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    merged_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_offer_merge_log_product_id ON offer_merge_log(product_id, merged_at DESC);
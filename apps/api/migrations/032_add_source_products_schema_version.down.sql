@@ -0,0 +1,3 @@
+-- Rollback for 032_add_source_products_schema_version.up.sql
+DROP INDEX IF EXISTS idx_source_products_provider_schema_version;
+ALTER TABLE source_products DROP COLUMN IF EXISTS schema_version;
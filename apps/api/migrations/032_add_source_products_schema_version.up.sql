@@ -0,0 +1,7 @@
+-- Version of the provider mapping that parsed raw_json (providers.RawParser), so the
+-- reprocess_raw job can re-parse listings stored by older code. 0 is unknown.
+ALTER TABLE source_products ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_source_products_provider_schema_version
+    ON source_products(provider, schema_version)
+    WHERE raw_json IS NOT NULL;
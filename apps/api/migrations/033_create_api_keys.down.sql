@@ -0,0 +1,2 @@
+-- Rollback for 033_create_api_keys.up.sql
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,15 @@
+-- API keys for the admin, resolve-url and image-search routes. Only the SHA-256 hash of
+-- a key is stored; prefix keeps its first characters so it can be recognized in lists.
+-- rate_limit_per_minute overrides API_KEY_RATE_LIMIT_PER_MINUTE (NULL uses it, 0 is
+-- unlimited). Revoked keys are kept for their last_used_at.
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('read', 'admin')),
+    rate_limit_per_minute INTEGER CHECK (rate_limit_per_minute >= 0),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
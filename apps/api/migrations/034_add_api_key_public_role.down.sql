@@ -0,0 +1,4 @@
+-- Rollback for 034_add_api_key_public_role.up.sql
+DELETE FROM api_keys WHERE role = 'public';
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_role_check;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_role_check CHECK (role IN ('read', 'admin'));
@@ -0,0 +1,4 @@
+-- Public keys are issued to third-party consumers of the search, product and compare
+-- endpoints; they may not call the admin, resolve-url or image-search routes.
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_role_check;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_role_check CHECK (role IN ('public', 'read', 'admin'));
@@ -0,0 +1,5 @@
+-- Rollback for 035_add_products_search_vector.up.sql
+DROP INDEX IF EXISTS idx_products_brand_lower;
+CREATE INDEX IF NOT EXISTS idx_products_title ON products USING gin(to_tsvector('english', title));
+DROP INDEX IF EXISTS idx_products_search_vector;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,12 @@
+-- Weighted full-text vector of title (A), brand and model (B) for ranked product search
+-- with prefix matching. It replaces the title-only expression index of 001.
+ALTER TABLE products ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(brand, '') || ' ' || coalesce(model, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING gin (search_vector);
+DROP INDEX IF EXISTS idx_products_title;
+
+-- Case-insensitive brand filter of /api/search
+CREATE INDEX idx_products_brand_lower ON products (lower(brand));
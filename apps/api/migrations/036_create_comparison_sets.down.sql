@@ -0,0 +1,2 @@
+-- Rollback for 036_create_comparison_sets.up.sql
+DROP TABLE IF EXISTS comparison_sets;
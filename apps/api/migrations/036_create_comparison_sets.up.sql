@@ -0,0 +1,15 @@
+-- Named product lists saved through POST /api/comparisons. owner_key_id is the API key
+-- that created the set (the nil UUID for ADMIN_API_KEY, which is not stored), so it has
+-- no foreign key; revoked keys are kept, so their sets are too. share_token is the
+-- secret of the public share URL.
+CREATE TABLE comparison_sets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_key_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    product_ids UUID[] NOT NULL,
+    share_token VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_comparison_sets_owner_key_id ON comparison_sets(owner_key_id, created_at);
@@ -0,0 +1,2 @@
+-- Rollback for 037_add_offer_seller_rating.up.sql
+ALTER TABLE offers DROP COLUMN IF EXISTS seller_rating;
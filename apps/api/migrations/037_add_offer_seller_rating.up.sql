@@ -0,0 +1,5 @@
+-- Seller ratings: providers that report the seller's rating (0-5 stars) store it with the
+-- offer. The compare endpoint's deal score weighs it in; offers without a rating score
+-- neutral on it.
+ALTER TABLE offers ADD COLUMN seller_rating DOUBLE PRECISION
+    CHECK (seller_rating IS NULL OR (seller_rating >= 0 AND seller_rating <= 5));
@@ -0,0 +1,2 @@
+-- Rollback for 038_add_offers_search_filter_index.up.sql
+DROP INDEX IF EXISTS idx_offers_search_filters;
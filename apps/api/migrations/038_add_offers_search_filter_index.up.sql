@@ -0,0 +1,5 @@
+-- Source, price range and in_stock filters and facets of /api/search: the per-product
+-- offer lookup of the search query reads only listed, unquarantined offers, filtered by
+-- source and total; in_stock is included so the stock filter is checked in the index.
+CREATE INDEX idx_offers_search_filters ON offers(product_id, source, total_to_us_amount) INCLUDE (in_stock)
+    WHERE quarantine_reason IS NULL AND delisted_at IS NULL;
@@ -0,0 +1,2 @@
+-- Rollback for 039_add_source_product_titles.up.sql
+ALTER TABLE source_products DROP COLUMN IF EXISTS titles;
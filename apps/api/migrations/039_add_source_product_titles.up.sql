@@ -0,0 +1,8 @@
+-- Per-locale titles of a listing: its own title under its language and, with a translator
+-- configured (TRANSLATION_BACKEND), a translation into the other display language, so a
+-- Japanese listing has an English display title and vice versa.
+ALTER TABLE source_products ADD COLUMN titles JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+UPDATE source_products
+SET titles = jsonb_build_object(language, title)
+WHERE language IS NOT NULL AND title IS NOT NULL;
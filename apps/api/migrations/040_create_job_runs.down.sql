@@ -0,0 +1,2 @@
+-- Rollback for 040_create_job_runs.up.sql
+DROP TABLE IF EXISTS job_runs;
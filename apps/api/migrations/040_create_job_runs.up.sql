@@ -0,0 +1,15 @@
+-- Job runs: one row per fetch_prices task, keyed by the queue's task ID, with the
+-- products its candidates were created or matched as (products JSONB array of
+-- {source, identifier, title, product_id, action, match_method}). Read by
+-- GET /api/admin/jobs/:id; rows older than 30 days are deleted by the job.
+CREATE TABLE job_runs (
+    id VARCHAR(100) PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    source VARCHAR(50),
+    status VARCHAR(20) NOT NULL,
+    products JSONB NOT NULL DEFAULT '[]'::jsonb,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_job_runs_started_at ON job_runs(started_at);
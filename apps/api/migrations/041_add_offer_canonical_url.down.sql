@@ -0,0 +1,2 @@
+-- Rollback for 041_add_offer_canonical_url.up.sql
+ALTER TABLE offers DROP COLUMN IF EXISTS canonical_url;
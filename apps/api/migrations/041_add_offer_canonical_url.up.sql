@@ -0,0 +1,4 @@
+-- Canonical offer links: url keeps the link as the provider returned it (with its search,
+-- session and affiliate parameters), canonical_url the clean product page link the API
+-- returns by default. Offers stored before get theirs computed when they are returned.
+ALTER TABLE offers ADD COLUMN canonical_url TEXT;
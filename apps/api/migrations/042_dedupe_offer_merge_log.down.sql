@@ -0,0 +1,3 @@
+-- Rollback for 042_dedupe_offer_merge_log.up.sql
+DROP INDEX IF EXISTS idx_offer_merge_log_merged_at;
+DROP INDEX IF EXISTS idx_offer_merge_log_merge;
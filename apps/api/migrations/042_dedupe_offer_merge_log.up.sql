@@ -0,0 +1,12 @@
+-- Offer merges are logged once: fetches that collapse the same listing into the same
+-- kept offer again do not add rows. Rows older than OFFER_MERGE_LOG_RETENTION_DAYS are
+-- pruned by db_maintenance.
+DELETE FROM offer_merge_log l
+USING offer_merge_log earlier
+WHERE l.kept_offer_id = earlier.kept_offer_id
+  AND l.merged_seller = earlier.merged_seller
+  AND COALESCE(l.merged_url, '') = COALESCE(earlier.merged_url, '')
+  AND l.id > earlier.id;
+
+CREATE UNIQUE INDEX idx_offer_merge_log_merge ON offer_merge_log(kept_offer_id, merged_seller, (COALESCE(merged_url, '')));
+CREATE INDEX idx_offer_merge_log_merged_at ON offer_merge_log(merged_at);
@@ -0,0 +1,3 @@
+-- Rollback for 043_add_products_normalized_title.up.sql
+DROP INDEX IF EXISTS idx_products_normalized_title_trgm;
+ALTER TABLE products DROP COLUMN IF EXISTS normalized_title;
@@ -0,0 +1,11 @@
+-- Trigram prefilter of the title matcher on the titles as matching.NormalizeTitle sees
+-- them: NFKC folds full-width letters and digits, and "-" or "." between letters and
+-- digits are removed (applied twice, like the Go pattern, for "a-b-c")
+ALTER TABLE products ADD COLUMN normalized_title TEXT GENERATED ALWAYS AS (
+    regexp_replace(
+        regexp_replace(lower(normalize(title, NFKC)), '([a-z0-9])[-.]([a-z0-9])', '\1\2', 'g'),
+        '([a-z0-9])[-.]([a-z0-9])', '\1\2', 'g'
+    )
+) STORED;
+
+CREATE INDEX idx_products_normalized_title_trgm ON products USING gin (normalized_title gin_trgm_ops);
@@ -0,0 +1,2 @@
+-- Rollback for 044_keep_merged_product_revisions.up.sql
+ALTER TABLE product_revisions DROP COLUMN IF EXISTS merged_from_product_id;
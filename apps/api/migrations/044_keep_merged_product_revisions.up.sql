@@ -0,0 +1,4 @@
+-- Revisions of a product merged into another move to the kept product instead of being
+-- deleted with it. merged_from_product_id is the product they edited; they do not mark
+-- the kept product's fields as curated.
+ALTER TABLE product_revisions ADD COLUMN merged_from_product_id UUID;
@@ -0,0 +1,4 @@
+-- Rollback for 045_confirm_email_price_alerts.up.sql
+DROP INDEX IF EXISTS idx_price_alerts_confirm_token;
+ALTER TABLE price_alerts DROP COLUMN IF EXISTS confirmed_at;
+ALTER TABLE price_alerts DROP COLUMN IF EXISTS confirm_token;
@@ -0,0 +1,7 @@
+-- Email alerts stay inactive until the address opens the confirmation link sent with
+-- confirm_token. Email alerts created before this migration were never confirmed, so
+-- they are not evaluated until they are registered again.
+ALTER TABLE price_alerts ADD COLUMN confirm_token TEXT;
+ALTER TABLE price_alerts ADD COLUMN confirmed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX idx_price_alerts_confirm_token ON price_alerts(confirm_token);
@@ -89,6 +89,9 @@ export default function AdminJobsPage() {
                     <SelectItem value="all">すべて（有効なプロバイダ）</SelectItem>
                     <SelectItem value="walmart">Walmart（公式API）</SelectItem>
                     <SelectItem value="amazon">Amazon（公式API）</SelectItem>
+                    <SelectItem value="rakuten">楽天市場（公式API）</SelectItem>
+                    <SelectItem value="aliexpress">AliExpress（公式API）</SelectItem>
+                    <SelectItem value="google_shopping">Google Shopping（SerpAPI）</SelectItem>
                     {ENABLE_DEMO_PROVIDERS && (
                       <>
                         <SelectItem value="demo">Demo（開発・テスト用）</SelectItem>
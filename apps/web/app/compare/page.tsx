@@ -66,7 +66,7 @@ function ShippingBreakdown({ offer }: { offer: Offer }) {
   )
 }
 
-type SortKey = 'total' | 'fastest' | 'newest' | 'in_stock'
+type SortKey = 'total' | 'landed_cost' | 'fastest' | 'newest' | 'in_stock'
 
 export default function ComparePage() {
   const searchParams = useSearchParams()
@@ -205,6 +205,7 @@ export default function ComparePage() {
               </SelectTrigger>
               <SelectContent>
                 <SelectItem value="total">総額が安い順</SelectItem>
+                <SelectItem value="landed_cost">関税込み総額が安い順</SelectItem>
                 <SelectItem value="fastest">納期が早い順</SelectItem>
                 <SelectItem value="newest">更新日時が新しい順</SelectItem>
                 <SelectItem value="in_stock">在庫あり優先</SelectItem>
@@ -311,6 +312,11 @@ export default function ComparePage() {
                                 minute: '2-digit',
                               })
                             : '-'}
+                          {offer.stale && (
+                            <Badge variant="outline" className="ml-2 text-amber-700 border-amber-300">
+                              古い価格
+                            </Badge>
+                          )}
                         </TableCell>
                         <TableCell>
                           {offer.url ? (
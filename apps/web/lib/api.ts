@@ -7,6 +7,13 @@ const API_URL = (envApiUrl && !envApiUrl.includes('api:'))
   ? envApiUrl 
   : 'http://localhost:8080'
 
+// 管理 API・resolve-url 用の API キー（ブラウザに公開されるため開発環境専用）
+const API_KEY = process.env.NEXT_PUBLIC_API_KEY
+
+function withAPIKey(headers: Record<string, string>): Record<string, string> {
+  return API_KEY ? { ...headers, 'X-API-Key': API_KEY } : headers
+}
+
 // Schemas
 export const ProductSchema = z.object({
   id: z.string().uuid(),
@@ -14,6 +21,7 @@ export const ProductSchema = z.object({
   brand: z.string().nullable().optional(),
   model: z.string().nullable().optional(),
   image_url: z.string().nullable().optional(),
+  category: z.string().nullable().optional(),
   created_at: z.string(),
   updated_at: z.string(),
 })
@@ -37,8 +45,33 @@ export const OfferSchema = z.object({
   availability_status: z.string().nullable().optional(),
   estimated_delivery_date: z.string().nullable().optional(),
   price_updated_at: z.string(),
+  ships_from_country: z.string().nullable().optional(),
+  duty_amount: z.number().optional(),
+  landed_cost_amount: z.number().optional(),
+  free_shipping: z.boolean().optional(),
+  fee_items: z
+    .array(z.object({ type: z.string(), label: z.string().optional(), amount: z.number() }))
+    .nullable()
+    .optional(),
+  cost_breakdown: z
+    .object({
+      item: z.number(),
+      shipping: z.number(),
+      fee: z.number(),
+      tax: z.number(),
+      duty: z.number(),
+      total: z.number(),
+      landed_cost: z.number(),
+      original_amount: z.number(),
+      original_currency: z.string(),
+      fx_rate: z.number(),
+    })
+    .nullable()
+    .optional(),
   created_at: z.string(),
   updated_at: z.string(),
+  age_seconds: z.number().optional(),
+  stale: z.boolean().optional(),
 })
 
 export const ProductWithMinPriceSchema = ProductSchema.extend({
@@ -93,7 +126,7 @@ export async function getProductOffers(id: string): Promise<Offer[]> {
 
 export async function getProductOffersWithSort(
   id: string,
-  sort: 'total' | 'fastest' | 'newest' | 'in_stock' = 'total'
+  sort: 'total' | 'landed_cost' | 'fastest' | 'newest' | 'in_stock' = 'total'
 ): Promise<Offer[]> {
   const res = await fetch(`${API_URL}/api/products/${id}/compare?sort=${sort}`)
   if (!res.ok) {
@@ -113,7 +146,7 @@ export type ResolveURLResponse = {
 export async function resolveURL(url: string): Promise<ResolveURLResponse> {
   const res = await fetch(`${API_URL}/api/resolve-url`, {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: withAPIKey({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ url }),
   })
   if (!res.ok) {
@@ -128,7 +161,7 @@ export async function fetchPrices(
 ): Promise<{ job_id: string; status: string; source: string }> {
   const res = await fetch(`${API_URL}/api/admin/jobs/fetch_prices`, {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: withAPIKey({ 'Content-Type': 'application/json' }),
     body: source ? JSON.stringify({ source }) : JSON.stringify({}),
   })
   if (!res.ok) {
@@ -2,7 +2,14 @@
 const nextConfig = {
   reactStrictMode: true,
   output: 'standalone',
+  // サイトマップと商品フィードは API が生成する（API の SITE_URL がこのサイトの URL）
+  async rewrites() {
+    const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
+    return [
+      { source: '/sitemap.xml', destination: `${apiUrl}/sitemap.xml` },
+      { source: '/feeds/:path*', destination: `${apiUrl}/feeds/:path*` },
+    ]
+  },
 }
 
 module.exports = nextConfig
-